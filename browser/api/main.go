@@ -403,7 +403,7 @@ func fulltextSearch(c *gin.Context) {
 	// 获取全文搜索实例（需要先创建）
 	// 这里假设已经通过 AddFulltextSearch 创建了全文搜索
 	// 实际实现中可能需要从某个注册表中获取
-	fts, err := getFulltextSearch(collection, name)
+	fts, err := getFulltextSearch(collection)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error: fmt.Sprintf("Fulltext search not configured for collection: %v", err),
@@ -479,7 +479,7 @@ func vectorSearch(c *gin.Context) {
 	}
 
 	// 获取向量搜索实例
-	vs, err := getVectorSearch(collection, name, req.Field)
+	vs, err := getVectorSearch(collection, req.Field)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error: fmt.Sprintf("Vector search not configured: %v", err),
@@ -705,7 +705,8 @@ func getCollectionByName(name string) (rxdb.Collection, error) {
 var fulltextSearchCache = make(map[string]*rxdb.FulltextSearch)
 
 // getFulltextSearch 获取或创建全文搜索实例
-func getFulltextSearch(collection rxdb.Collection, collectionName string) (*rxdb.FulltextSearch, error) {
+func getFulltextSearch(collection rxdb.Collection) (*rxdb.FulltextSearch, error) {
+	collectionName := collection.Name()
 	key := collectionName
 	if fts, ok := fulltextSearchCache[key]; ok {
 		return fts, nil
@@ -745,7 +746,8 @@ func getFulltextSearch(collection rxdb.Collection, collectionName string) (*rxdb
 var vectorSearchCache = make(map[string]*rxdb.VectorSearch)
 
 // getVectorSearch 获取或创建向量搜索实例
-func getVectorSearch(collection rxdb.Collection, collectionName, field string) (*rxdb.VectorSearch, error) {
+func getVectorSearch(collection rxdb.Collection, field string) (*rxdb.VectorSearch, error) {
+	collectionName := collection.Name()
 	if field == "" {
 		field = "embedding"
 	}
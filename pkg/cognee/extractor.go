@@ -0,0 +1,162 @@
+package cognee
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Entity 抽取出的实体
+type Entity struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Relation 抽取出的实体关系
+type Relation struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Relation string `json:"relation"`
+}
+
+// EntityRelationExtractor 实体与关系抽取器接口
+type EntityRelationExtractor interface {
+	Extract(ctx context.Context, text string) ([]Entity, []Relation, error)
+}
+
+// PatternRule 基于正则表达式的实体识别规则
+type PatternRule struct {
+	EntityType string
+	Pattern    string
+}
+
+// DefaultCoOccurrenceRelation 规则抽取器在同一句子中共现的实体之间建立的默认关系名
+const DefaultCoOccurrenceRelation = "CO_OCCURS_WITH"
+
+// CreateExtractor 根据类型创建实体关系抽取器
+//
+// 支持的类型：
+//   - "rules"：本地规则抽取，无需 LLM。config 需包含 "patterns"（[]PatternRule），
+//     可选 "relation"（string）指定共现关系名
+func CreateExtractor(extractorType string, config map[string]any) (EntityRelationExtractor, error) {
+	switch extractorType {
+	case "rules":
+		patterns, ok := config["patterns"].([]PatternRule)
+		if !ok || len(patterns) == 0 {
+			return nil, fmt.Errorf("rules extractor requires non-empty 'patterns' ([]PatternRule)")
+		}
+		relation, _ := config["relation"].(string)
+		return NewRuleExtractor(patterns, relation)
+	default:
+		return nil, fmt.Errorf("unsupported extractor type: %s", extractorType)
+	}
+}
+
+// compiledRule 预编译的规则
+type compiledRule struct {
+	entityType string
+	re         *regexp.Regexp
+}
+
+// RuleExtractor 基于正则规则的实体抽取器
+// 关系通过共现启发式生成：同一句子中出现的两个实体之间建立一条关系
+type RuleExtractor struct {
+	rules    []compiledRule
+	relation string
+}
+
+// NewRuleExtractor 创建规则抽取器
+func NewRuleExtractor(patterns []PatternRule, relation string) (*RuleExtractor, error) {
+	if relation == "" {
+		relation = DefaultCoOccurrenceRelation
+	}
+
+	rules := make([]compiledRule, 0, len(patterns))
+	for _, p := range patterns {
+		if p.EntityType == "" {
+			return nil, fmt.Errorf("pattern %q missing entity type", p.Pattern)
+		}
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for %s: %w", p.EntityType, err)
+		}
+		rules = append(rules, compiledRule{entityType: p.EntityType, re: re})
+	}
+
+	return &RuleExtractor{rules: rules, relation: relation}, nil
+}
+
+// sentenceSplitter 句子分隔符（中英文标点与换行）
+var sentenceSplitter = regexp.MustCompile(`[.!?。！？;；\n]+\s*`)
+
+// Extract 从文本中抽取实体与共现关系
+func (e *RuleExtractor) Extract(ctx context.Context, text string) ([]Entity, []Relation, error) {
+	var entities []Entity
+	var relations []Relation
+	seenEntities := make(map[string]bool)
+	seenRelations := make(map[string]bool)
+
+	for _, sentence := range splitSentences(text) {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		// 按出现位置收集本句中的实体
+		var names []string
+		inSentence := make(map[string]bool)
+		for _, rule := range e.rules {
+			for _, match := range rule.re.FindAllString(sentence, -1) {
+				if match == "" || inSentence[match] {
+					continue
+				}
+				inSentence[match] = true
+				names = append(names, match)
+				if !seenEntities[match] {
+					seenEntities[match] = true
+					entities = append(entities, Entity{Name: match, Type: rule.entityType})
+				}
+			}
+		}
+
+		// 共现启发式：同一句中的实体两两建立关系
+		for i := 0; i < len(names); i++ {
+			for j := i + 1; j < len(names); j++ {
+				key := names[i] + "\x00" + names[j]
+				if seenRelations[key] {
+					continue
+				}
+				seenRelations[key] = true
+				relations = append(relations, Relation{
+					Source:   names[i],
+					Target:   names[j],
+					Relation: e.relation,
+				})
+			}
+		}
+	}
+
+	return entities, relations, nil
+}
+
+// splitSentences 将文本切分为句子
+// URL 与邮箱中的 "." 后不跟空白，因此只在标点后接空白或文本结尾时切分
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for _, loc := range sentenceSplitter.FindAllStringIndex(text, -1) {
+		sep := text[loc[0]:loc[1]]
+		// 对于英文句号等，要求其后为空白或文本结尾，避免切断 URL/邮箱
+		if loc[1] < len(text) && strings.TrimSpace(sep) == sep && !strings.ContainsAny(sep, "\n。！？；") {
+			continue
+		}
+		if s := strings.TrimSpace(text[start:loc[0]]); s != "" {
+			sentences = append(sentences, s)
+		}
+		start = loc[1]
+	}
+	if s := strings.TrimSpace(text[start:]); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
@@ -0,0 +1,63 @@
+package cognee
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRuleExtractor_EmailsAndURLs(t *testing.T) {
+	ctx := context.Background()
+
+	extractor, err := CreateExtractor("rules", map[string]any{
+		"patterns": []PatternRule{
+			{EntityType: "EMAIL", Pattern: `[\w.+-]+@[\w-]+(?:\.[\w-]+)+`},
+			{EntityType: "URL", Pattern: `https?://[^\s]+[^\s.,;!?]`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create extractor: %v", err)
+	}
+
+	doc := map[string]any{
+		"id": "memory-1",
+		"content": "Contact alice@example.com or visit https://example.com/docs for details. " +
+			"Bob can be reached at bob@example.org.",
+	}
+
+	entities, relations, err := extractor.Extract(ctx, doc["content"].(string))
+	if err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+
+	expectedEntities := []Entity{
+		{Name: "alice@example.com", Type: "EMAIL"},
+		{Name: "https://example.com/docs", Type: "URL"},
+		{Name: "bob@example.org", Type: "EMAIL"},
+	}
+	if !reflect.DeepEqual(entities, expectedEntities) {
+		t.Errorf("expected entities %v, got %v", expectedEntities, entities)
+	}
+
+	// 只有同一句中的邮箱与 URL 之间存在共现关系
+	expectedRelations := []Relation{
+		{Source: "alice@example.com", Target: "https://example.com/docs", Relation: DefaultCoOccurrenceRelation},
+	}
+	if !reflect.DeepEqual(relations, expectedRelations) {
+		t.Errorf("expected relations %v, got %v", expectedRelations, relations)
+	}
+}
+
+func TestCreateExtractor_Errors(t *testing.T) {
+	if _, err := CreateExtractor("unknown", nil); err == nil {
+		t.Error("expected error for unknown extractor type")
+	}
+	if _, err := CreateExtractor("rules", map[string]any{}); err == nil {
+		t.Error("expected error for missing patterns")
+	}
+	if _, err := CreateExtractor("rules", map[string]any{
+		"patterns": []PatternRule{{EntityType: "BAD", Pattern: "("}},
+	}); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
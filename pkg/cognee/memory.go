@@ -0,0 +1,300 @@
+package cognee
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mozhou-tech/rxdb-go/pkg/rxdb"
+	"github.com/sirupsen/logrus"
+)
+
+// MentionedInRelation 实体与其所在记忆之间的图关系名
+const MentionedInRelation = "MENTIONED_IN"
+
+// Embedder 向量嵌入生成器接口
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float64, error)
+	Dimensions() int
+}
+
+// Memory 一条记忆
+type Memory struct {
+	ID             string         `json:"id"`
+	Content        string         `json:"content"`
+	Dataset        string         `json:"dataset"`
+	Metadata       map[string]any `json:"metadata"`
+	Entities       []string       `json:"entities"`
+	CreatedAt      time.Time      `json:"created_at"`
+	LastAccessedAt time.Time      `json:"last_accessed_at"`
+}
+
+// MemoryServiceOptions 记忆服务配置选项
+type MemoryServiceOptions struct {
+	// WorkingDir 存储目录，默认为 ./cognee_storage
+	WorkingDir string
+	// Embedder 向量嵌入生成器（可选），为空时不启用向量检索
+	Embedder Embedder
+	// Extractor 实体关系抽取器（可选），为空时不写入知识图谱
+	Extractor EntityRelationExtractor
+}
+
+// MemoryService 基于 rxdb-go 的记忆服务
+// 记忆文档存放在 memories 集合中，并同步到全文索引、向量索引与知识图谱
+type MemoryService struct {
+	db        rxdb.Database
+	memories  rxdb.Collection
+	fulltext  *rxdb.FulltextSearch
+	vector    *rxdb.VectorSearch
+	graph     rxdb.GraphDatabase
+	embedder  Embedder
+	extractor EntityRelationExtractor
+}
+
+// NewMemoryService 创建记忆服务并初始化存储
+func NewMemoryService(ctx context.Context, opts MemoryServiceOptions) (*MemoryService, error) {
+	workingDir := opts.WorkingDir
+	if workingDir == "" {
+		workingDir = "./cognee_storage"
+	}
+
+	db, err := rxdb.CreateDatabase(ctx, rxdb.DatabaseOptions{
+		Name: "cognee",
+		Path: workingDir,
+		GraphOptions: &rxdb.GraphOptions{
+			Enabled: true,
+			Backend: "badger",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database: %w", err)
+	}
+
+	s := &MemoryService{
+		db:        db,
+		graph:     db.Graph(),
+		embedder:  opts.Embedder,
+		extractor: opts.Extractor,
+	}
+
+	memories, err := db.Collection(ctx, "memories", rxdb.Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		_ = db.Close(ctx)
+		return nil, fmt.Errorf("failed to create memories collection: %w", err)
+	}
+	s.memories = memories
+
+	fulltext, err := rxdb.AddFulltextSearch(memories, rxdb.FulltextSearchConfig{
+		Identifier: "memories_fulltext",
+		DocToString: func(doc map[string]any) string {
+			content, _ := doc["content"].(string)
+			return content
+		},
+	})
+	if err != nil {
+		_ = s.Close(ctx)
+		return nil, fmt.Errorf("failed to add fulltext search: %w", err)
+	}
+	s.fulltext = fulltext
+
+	if s.embedder != nil {
+		vector, err := rxdb.AddVectorSearch(memories, rxdb.VectorSearchConfig{
+			Identifier: "memories_vector",
+			DocToEmbedding: func(doc map[string]any) ([]float64, error) {
+				content, _ := doc["content"].(string)
+				return s.embedder.Embed(ctx, content)
+			},
+			Dimensions: s.embedder.Dimensions(),
+		})
+		if err != nil {
+			_ = s.Close(ctx)
+			return nil, fmt.Errorf("failed to add vector search: %w", err)
+		}
+		s.vector = vector
+	}
+
+	return s, nil
+}
+
+// AddMemory 写入一条记忆
+// ID 为空时自动生成；CreatedAt 为空时使用当前时间，LastAccessedAt 为空时与 CreatedAt 相同。
+// 配置了抽取器时，抽取出的实体会以 MENTIONED_IN 关系链接到该记忆，实体间关系写入知识图谱。
+func (s *MemoryService) AddMemory(ctx context.Context, mem Memory) (*Memory, error) {
+	if mem.Content == "" {
+		return nil, fmt.Errorf("memory content is empty")
+	}
+	if mem.ID == "" {
+		mem.ID = fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	if mem.CreatedAt.IsZero() {
+		mem.CreatedAt = time.Now()
+	}
+	if mem.LastAccessedAt.IsZero() {
+		mem.LastAccessedAt = mem.CreatedAt
+	}
+
+	var relations []Relation
+	if s.extractor != nil {
+		entities, rels, err := s.extractor.Extract(ctx, mem.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract entities: %w", err)
+		}
+		mem.Entities = mem.Entities[:0]
+		for _, e := range entities {
+			mem.Entities = append(mem.Entities, e.Name)
+		}
+		relations = rels
+	}
+
+	doc := map[string]any{
+		"id":               mem.ID,
+		"content":          mem.Content,
+		"dataset":          mem.Dataset,
+		"created_at":       mem.CreatedAt.Unix(),
+		"last_accessed_at": mem.LastAccessedAt.Unix(),
+	}
+	if mem.Metadata != nil {
+		doc["metadata"] = mem.Metadata
+	}
+	if len(mem.Entities) > 0 {
+		entities := make([]any, 0, len(mem.Entities))
+		for _, name := range mem.Entities {
+			entities = append(entities, name)
+		}
+		doc["entities"] = entities
+	}
+
+	if _, err := s.memories.Insert(ctx, doc); err != nil {
+		return nil, fmt.Errorf("failed to insert memory: %w", err)
+	}
+
+	if s.graph != nil {
+		for _, name := range mem.Entities {
+			if err := s.graph.Link(ctx, name, MentionedInRelation, mem.ID); err != nil {
+				logrus.WithError(err).Errorf("Failed to link entity %s to memory %s", name, mem.ID)
+			}
+		}
+		for _, rel := range relations {
+			if err := s.graph.Link(ctx, rel.Source, rel.Relation, rel.Target); err != nil {
+				logrus.WithError(err).Errorf("Failed to link nodes: %s -[%s]-> %s", rel.Source, rel.Relation, rel.Target)
+			}
+		}
+	}
+
+	return &mem, nil
+}
+
+// GetMemory 根据 ID 获取记忆，不存在时返回 rxdb 的 NotFound 错误
+func (s *MemoryService) GetMemory(ctx context.Context, id string) (*Memory, error) {
+	doc, err := s.memories.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return memoryFromDocument(doc), nil
+}
+
+// Touch 将记忆的 last_accessed_at 更新为当前时间，避免被 Prune 过早清理
+func (s *MemoryService) Touch(ctx context.Context, id string) error {
+	doc, err := s.memories.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return doc.Update(ctx, map[string]any{"last_accessed_at": time.Now().Unix()})
+}
+
+// Prune 删除超过 maxAge 未被访问的记忆，返回删除的数量
+// 记忆的时间以 last_accessed_at 为准，缺失时使用 created_at。
+// 被删除的记忆会通过变更事件从全文与向量索引中移除，其实体链接也会从知识图谱中删除。
+func (s *MemoryService) Prune(ctx context.Context, maxAge time.Duration) (int, error) {
+	if maxAge <= 0 {
+		return 0, fmt.Errorf("maxAge must be positive, got %s", maxAge)
+	}
+	cutoff := time.Now().Add(-maxAge).Unix()
+
+	docs, err := s.memories.All(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list memories: %w", err)
+	}
+
+	var expired []*Memory
+	ids := make([]string, 0)
+	for _, doc := range docs {
+		mem := memoryFromDocument(doc)
+		lastActive := mem.LastAccessedAt
+		if lastActive.IsZero() {
+			lastActive = mem.CreatedAt
+		}
+		if lastActive.Unix() < cutoff {
+			expired = append(expired, mem)
+			ids = append(ids, mem.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if err := s.memories.BulkRemove(ctx, ids); err != nil {
+		return 0, fmt.Errorf("failed to remove memories: %w", err)
+	}
+
+	if s.graph != nil {
+		for _, mem := range expired {
+			for _, name := range mem.Entities {
+				if err := s.graph.Unlink(ctx, name, MentionedInRelation, mem.ID); err != nil {
+					logrus.WithError(err).Errorf("Failed to unlink entity %s from memory %s", name, mem.ID)
+				}
+			}
+		}
+	}
+
+	return len(ids), nil
+}
+
+// Close 关闭记忆服务及其存储
+func (s *MemoryService) Close(ctx context.Context) error {
+	if s.fulltext != nil {
+		s.fulltext.Close()
+	}
+	if s.vector != nil {
+		s.vector.Close()
+	}
+	if s.db != nil {
+		return s.db.Close(ctx)
+	}
+	return nil
+}
+
+// memoryFromDocument 将 rxdb 文档转换为 Memory
+func memoryFromDocument(doc rxdb.Document) *Memory {
+	data := doc.Data()
+	mem := &Memory{ID: doc.ID()}
+	mem.Content, _ = data["content"].(string)
+	mem.Dataset, _ = data["dataset"].(string)
+	mem.Metadata, _ = data["metadata"].(map[string]any)
+	if entities, ok := data["entities"].([]any); ok {
+		for _, e := range entities {
+			if name, ok := e.(string); ok {
+				mem.Entities = append(mem.Entities, name)
+			}
+		}
+	}
+	mem.CreatedAt = unixField(data["created_at"])
+	mem.LastAccessedAt = unixField(data["last_accessed_at"])
+	return mem
+}
+
+// unixField 将 Unix 时间戳字段转换为 time.Time，缺失或类型不符时返回零值
+func unixField(v any) time.Time {
+	switch ts := v.(type) {
+	case int64:
+		return time.Unix(ts, 0)
+	case int:
+		return time.Unix(int64(ts), 0)
+	case float64:
+		return time.Unix(int64(ts), 0)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,218 @@
+package cognee
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mozhou-tech/rxdb-go/pkg/rxdb"
+)
+
+func newTestMemoryService(t *testing.T, extractor EntityRelationExtractor) *MemoryService {
+	t.Helper()
+	tmpDir, err := os.MkdirTemp("", "cognee-memory-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	service, err := NewMemoryService(context.Background(), MemoryServiceOptions{
+		WorkingDir: tmpDir,
+		Extractor:  extractor,
+	})
+	if err != nil {
+		t.Fatalf("failed to create memory service: %v", err)
+	}
+	t.Cleanup(func() { service.Close(context.Background()) })
+	return service
+}
+
+func TestMemoryService_Prune(t *testing.T) {
+	ctx := context.Background()
+
+	extractor, err := NewRuleExtractor([]PatternRule{
+		{EntityType: "PERSON", Pattern: `\b(?:Alice|Bob|Carol)\b`},
+	}, "KNOWS")
+	if err != nil {
+		t.Fatalf("failed to create extractor: %v", err)
+	}
+	service := newTestMemoryService(t, extractor)
+
+	now := time.Now()
+	memories := []Memory{
+		{ID: "old", Content: "Alice met Bob at the conference", CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: "old-touched", Content: "Carol wrote the design notes", CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: "old-accessed", Content: "Bob reviewed the notes", CreatedAt: now.Add(-72 * time.Hour), LastAccessedAt: now.Add(-time.Hour)},
+		{ID: "recent", Content: "Alice shipped the release", CreatedAt: now.Add(-time.Hour)},
+	}
+	for _, mem := range memories {
+		if _, err := service.AddMemory(ctx, mem); err != nil {
+			t.Fatalf("failed to add memory %s: %v", mem.ID, err)
+		}
+	}
+
+	if err := service.Touch(ctx, "old-touched"); err != nil {
+		t.Fatalf("touch failed: %v", err)
+	}
+	if err := service.Touch(ctx, "missing"); !rxdb.IsNotFoundError(err) {
+		t.Errorf("expected not found error when touching missing memory, got %v", err)
+	}
+
+	pruned, err := service.Prune(ctx, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if pruned != 1 {
+		t.Errorf("expected 1 pruned memory, got %d", pruned)
+	}
+
+	if _, err := service.GetMemory(ctx, "old"); !rxdb.IsNotFoundError(err) {
+		t.Errorf("expected old memory to be pruned, got %v", err)
+	}
+	for _, id := range []string{"old-touched", "old-accessed", "recent"} {
+		if _, err := service.GetMemory(ctx, id); err != nil {
+			t.Errorf("expected memory %s to be kept, got %v", id, err)
+		}
+	}
+
+	// 实体与被删除记忆之间的链接应从图中移除
+	neighbors, err := service.graph.GetNeighbors(ctx, "Alice", MentionedInRelation)
+	if err != nil {
+		t.Fatalf("failed to get neighbors: %v", err)
+	}
+	for _, n := range neighbors {
+		if n == "old" {
+			t.Errorf("expected graph link Alice -> old to be removed, got %v", neighbors)
+		}
+	}
+
+	// 全文索引通过变更事件异步移除被删除的记忆
+	deadline := time.Now().Add(2 * time.Second)
+	for service.fulltext.Count() != 3 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if count := service.fulltext.Count(); count != 3 {
+		t.Errorf("expected 3 memories in fulltext index, got %d", count)
+	}
+
+	pruned, err = service.Prune(ctx, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if pruned != 0 {
+		t.Errorf("expected nothing left to prune, got %d", pruned)
+	}
+}
+
+func TestMemoryService_SearchGraphTraversal(t *testing.T) {
+	ctx := context.Background()
+
+	extractor, err := NewRuleExtractor([]PatternRule{
+		{EntityType: "PERSON", Pattern: `\b(?:Alice|Bob|Carol|Dave)\b`},
+	}, "KNOWS")
+	if err != nil {
+		t.Fatalf("failed to create extractor: %v", err)
+	}
+	service := newTestMemoryService(t, extractor)
+
+	// m1 与 m2 通过共同实体 Bob 相连，m3 与查询实体无关
+	memories := []Memory{
+		{ID: "m1", Content: "Alice met Bob at the conference"},
+		{ID: "m2", Content: "Bob introduced Carol to the team"},
+		{ID: "m3", Content: "Dave wrote the release notes"},
+	}
+	for _, mem := range memories {
+		if _, err := service.AddMemory(ctx, mem); err != nil {
+			t.Fatalf("failed to add memory %s: %v", mem.ID, err)
+		}
+	}
+
+	// GRAPH 只返回直接提及查询实体的记忆
+	results, err := service.Search(ctx, "What do we know about Alice?", SearchOptions{Type: SearchTypeGraph})
+	if err != nil {
+		t.Fatalf("graph search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Memory.ID != "m1" {
+		t.Errorf("expected only m1 from graph search, got %v", results)
+	}
+
+	results, err = service.Search(ctx, "What do we know about Alice?", SearchOptions{Type: SearchTypeGraphTraversal})
+	if err != nil {
+		t.Fatalf("graph traversal search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 memories from graph traversal, got %d", len(results))
+	}
+	if results[0].Memory.ID != "m1" || results[0].Hops != 0 {
+		t.Errorf("expected m1 at hop 0 first, got %s at hop %d", results[0].Memory.ID, results[0].Hops)
+	}
+	if results[1].Memory.ID != "m2" || results[1].Hops != 1 {
+		t.Errorf("expected m2 at hop 1 second, got %s at hop %d", results[1].Memory.ID, results[1].Hops)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Errorf("expected closer memory to score higher, got %f <= %f", results[0].Score, results[1].Score)
+	}
+
+	if _, err := service.Search(ctx, "Alice", SearchOptions{Type: "UNKNOWN"}); err == nil {
+		t.Error("expected error for unsupported search type")
+	}
+}
+
+// mockLLMClient 记录提示词并返回固定回复的 LLM 客户端
+type mockLLMClient struct {
+	prompts  []string
+	response string
+}
+
+func (m *mockLLMClient) Complete(ctx context.Context, prompt string) (string, error) {
+	m.prompts = append(m.prompts, prompt)
+	return m.response, nil
+}
+
+func TestMemoryService_SummarizeDataset(t *testing.T) {
+	ctx := context.Background()
+	service := newTestMemoryService(t, nil)
+
+	now := time.Now()
+	memories := []Memory{
+		{ID: "m2", Dataset: "project", Content: "The release was shipped on Friday", CreatedAt: now.Add(-time.Hour)},
+		{ID: "m1", Dataset: "project", Content: "Alice drafted the design document", CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: "other", Dataset: "personal", Content: "Bought groceries after work", CreatedAt: now},
+	}
+	for _, mem := range memories {
+		if _, err := service.AddMemory(ctx, mem); err != nil {
+			t.Fatalf("failed to add memory %s: %v", mem.ID, err)
+		}
+	}
+
+	llm := &mockLLMClient{response: "  The team designed and shipped a release.\n"}
+	summary, err := service.SummarizeDataset(ctx, "project", llm)
+	if err != nil {
+		t.Fatalf("summarize failed: %v", err)
+	}
+	if summary != "The team designed and shipped a release." {
+		t.Errorf("unexpected summary: %q", summary)
+	}
+
+	if len(llm.prompts) != 1 {
+		t.Fatalf("expected 1 llm call, got %d", len(llm.prompts))
+	}
+	prompt := llm.prompts[0]
+	for _, excerpt := range []string{`"project"`, "1. Alice drafted the design document", "2. The release was shipped on Friday"} {
+		if !strings.Contains(prompt, excerpt) {
+			t.Errorf("expected prompt to contain %q, got:\n%s", excerpt, prompt)
+		}
+	}
+	if strings.Contains(prompt, "groceries") {
+		t.Errorf("expected prompt to exclude memories from other datasets, got:\n%s", prompt)
+	}
+
+	if _, err := service.SummarizeDataset(ctx, "empty", llm); err == nil {
+		t.Error("expected error for dataset without memories")
+	}
+	if _, err := service.SummarizeDataset(ctx, "project", nil); err == nil {
+		t.Error("expected error for nil llm client")
+	}
+}
@@ -0,0 +1,162 @@
+package cognee
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/mozhou-tech/rxdb-go/pkg/rxdb"
+)
+
+// 检索类型
+const (
+	// SearchTypeGraph 返回与查询中实体直接关联的记忆
+	SearchTypeGraph = "GRAPH"
+	// SearchTypeGraphTraversal 从查询实体出发做多跳广度优先遍历，返回可达实体关联的记忆
+	SearchTypeGraphTraversal = "GRAPH_TRAVERSAL"
+)
+
+// DefaultMaxHops GRAPH_TRAVERSAL 检索的默认最大跳数
+const DefaultMaxHops = 2
+
+// SearchOptions 记忆检索选项
+type SearchOptions struct {
+	// Type 检索类型：GRAPH（默认）或 GRAPH_TRAVERSAL
+	Type string
+	// Limit 返回结果数量上限，<= 0 时不限制
+	Limit int
+	// MaxHops GRAPH_TRAVERSAL 的最大跳数，<= 0 时使用 DefaultMaxHops
+	MaxHops int
+}
+
+// SearchResult 记忆检索结果
+type SearchResult struct {
+	Memory *Memory
+	// Score 相关性分数，按跳数衰减：1 / (Hops + 1)
+	Score float64
+	// Hops 从查询实体到关联该记忆的实体的最短跳数
+	Hops int
+}
+
+// Search 检索与查询相关的记忆
+// 查询文本经抽取器识别出实体后在知识图谱中检索，因此需要配置 Extractor。
+// 结果按跳数升序（分数降序）排列，跳数相同时按记忆 ID 排序。
+func (s *MemoryService) Search(ctx context.Context, query string, opts SearchOptions) ([]SearchResult, error) {
+	searchType := opts.Type
+	if searchType == "" {
+		searchType = SearchTypeGraph
+	}
+
+	var maxHops int
+	switch searchType {
+	case SearchTypeGraph:
+		maxHops = 0
+	case SearchTypeGraphTraversal:
+		maxHops = opts.MaxHops
+		if maxHops <= 0 {
+			maxHops = DefaultMaxHops
+		}
+	default:
+		return nil, fmt.Errorf("unsupported search type: %s", searchType)
+	}
+
+	if s.graph == nil {
+		return nil, fmt.Errorf("graph search requires a graph database")
+	}
+	if s.extractor == nil {
+		return nil, fmt.Errorf("graph search requires an extractor")
+	}
+
+	entities, _, err := s.extractor.Extract(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract entities: %w", err)
+	}
+
+	memoryHops, err := s.traverseMemories(ctx, entities, maxHops)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(memoryHops))
+	for id, hops := range memoryHops {
+		mem, err := s.GetMemory(ctx, id)
+		if err != nil {
+			if rxdb.IsNotFoundError(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get memory %s: %w", id, err)
+		}
+		results = append(results, SearchResult{
+			Memory: mem,
+			Score:  1 / float64(hops+1),
+			Hops:   hops,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Hops != results[j].Hops {
+			return results[i].Hops < results[j].Hops
+		}
+		return results[i].Memory.ID < results[j].Memory.ID
+	})
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
+
+// traverseMemories 从起始实体出发沿实体间关系（双向，不含 MENTIONED_IN）做广度优先遍历，
+// 返回每条可达记忆的最短跳数
+func (s *MemoryService) traverseMemories(ctx context.Context, entities []Entity, maxHops int) (map[string]int, error) {
+	visited := make(map[string]bool)
+	var frontier []string
+	for _, e := range entities {
+		if !visited[e.Name] {
+			visited[e.Name] = true
+			frontier = append(frontier, e.Name)
+		}
+	}
+
+	memoryHops := make(map[string]int)
+	for hops := 0; len(frontier) > 0 && hops <= maxHops; hops++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var next []string
+		for _, entity := range frontier {
+			memIDs, err := s.graph.GetNeighbors(ctx, entity, MentionedInRelation)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get memories of entity %s: %w", entity, err)
+			}
+			for _, id := range memIDs {
+				if _, ok := memoryHops[id]; !ok {
+					memoryHops[id] = hops
+				}
+			}
+
+			if hops == maxHops {
+				continue
+			}
+			edges, err := s.graph.Query().V(entity).Both().All(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to traverse entity %s: %w", entity, err)
+			}
+			for _, edge := range edges {
+				if edge.Predicate == MentionedInRelation {
+					continue
+				}
+				neighbor := edge.Object
+				if neighbor == entity {
+					neighbor = edge.Subject
+				}
+				if !visited[neighbor] {
+					visited[neighbor] = true
+					next = append(next, neighbor)
+				}
+			}
+		}
+		frontier = next
+	}
+	return memoryHops, nil
+}
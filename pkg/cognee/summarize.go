@@ -0,0 +1,87 @@
+package cognee
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/eino-ext/libs/acl/openai"
+	"github.com/cloudwego/eino/schema"
+)
+
+// LLMClient 大语言模型客户端接口
+type LLMClient interface {
+	Complete(ctx context.Context, prompt string) (string, error)
+}
+
+// OpenAILLMClient 基于 OpenAI Chat Completions 接口的 LLM 客户端
+type OpenAILLMClient struct {
+	client *openai.Client
+}
+
+// NewOpenAILLMClient 创建 OpenAI LLM 客户端
+func NewOpenAILLMClient(ctx context.Context, config *openai.Config) (*OpenAILLMClient, error) {
+	client, err := openai.NewClient(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return &OpenAILLMClient{client: client}, nil
+}
+
+// Complete 以单条用户消息调用模型并返回回复内容
+func (c *OpenAILLMClient) Complete(ctx context.Context, prompt string) (string, error) {
+	msg, err := c.client.Generate(ctx, []*schema.Message{schema.UserMessage(prompt)})
+	if err != nil {
+		return "", err
+	}
+	return msg.Content, nil
+}
+
+// summarizePromptHeader 数据集摘要提示词的开头
+const summarizePromptHeader = `You are given a list of memories from the dataset %q.
+Write a concise summary that captures the key facts, entities and themes across all memories.
+Do not invent information that is not present in the memories.
+
+Memories:
+`
+
+// SummarizeDataset 使用 LLM 生成数据集中全部记忆的摘要
+// 记忆按创建时间升序（相同时按 ID）列入提示词；数据集中没有记忆时返回错误。
+func (s *MemoryService) SummarizeDataset(ctx context.Context, dataset string, llm LLMClient) (string, error) {
+	if llm == nil {
+		return "", fmt.Errorf("llm client is required")
+	}
+
+	docs, err := s.memories.Find(map[string]any{"dataset": dataset}).Exec(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list memories: %w", err)
+	}
+	if len(docs) == 0 {
+		return "", fmt.Errorf("no memories found in dataset %q", dataset)
+	}
+
+	memories := make([]*Memory, 0, len(docs))
+	for _, doc := range docs {
+		memories = append(memories, memoryFromDocument(doc))
+	}
+	sort.Slice(memories, func(i, j int) bool {
+		if !memories[i].CreatedAt.Equal(memories[j].CreatedAt) {
+			return memories[i].CreatedAt.Before(memories[j].CreatedAt)
+		}
+		return memories[i].ID < memories[j].ID
+	})
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, summarizePromptHeader, dataset)
+	for i, mem := range memories {
+		fmt.Fprintf(&prompt, "%d. %s\n", i+1, mem.Content)
+	}
+	prompt.WriteString("\nSummary:")
+
+	summary, err := llm.Complete(ctx, prompt.String())
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize dataset: %w", err)
+	}
+	return strings.TrimSpace(summary), nil
+}
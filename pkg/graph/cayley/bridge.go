@@ -46,6 +46,8 @@ type RelationMapping struct {
 	TargetField string
 	// AutoLink 是否自动创建链接
 	AutoLink bool
+	// Weight 关系权重（用于推荐等加权算法），<= 0 时使用默认权重 1
+	Weight float64
 }
 
 // NewBridge 创建新的桥接实例
@@ -88,6 +90,9 @@ func (b *Bridge) AddRelationMapping(mapping *RelationMapping) {
 
 	key := fmt.Sprintf("%s:%s", mapping.Collection, mapping.Field)
 	b.relationMappings[key] = mapping
+	if mapping.Weight > 0 && b.graph != nil {
+		b.graph.SetRelationWeight(mapping.Relation, mapping.Weight)
+	}
 	logrus.WithFields(logrus.Fields{
 		"collection": mapping.Collection,
 		"field":      mapping.Field,
@@ -4,30 +4,31 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
-	"os"
 	"sync"
 
-	badgerdb "github.com/dgraph-io/badger/v4"
-	"github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
 	"github.com/sirupsen/logrus"
 )
 
 // Client 封装图数据库客户端
-// 支持内存和 Badger 持久化存储
+// 支持内存、Badger 和 LevelDB 持久化存储
 type Client struct {
 	// 内存存储（当 backend == "memory" 时使用）
 	quads map[string]map[string]map[string]bool // subject -> predicate -> object -> exists
-	// Badger 存储（当 backend == "badger" 时使用）
-	store   *badger.Store
+	nodes map[string][]byte                     // nodeID -> 节点属性（JSON）
+	// 持久化存储（当 backend 为 "badger" 或 "leveldb" 时使用）
+	store   kvStore
 	backend string
 	path    string
 	mu      sync.RWMutex
 	closed  bool
+
+	// 关系权重（用于推荐等加权算法），未设置的关系权重为 1
+	relationWeights map[string]float64
 }
 
 // Options 配置图数据库客户端选项
 type Options struct {
-	// Backend 存储后端类型：bolt, leveldb, badger, memory
+	// Backend 存储后端类型：badger, leveldb, memory
 	Backend string
 	// Path 存储路径
 	Path string
@@ -40,35 +41,26 @@ func NewClient(opts Options) (*Client, error) {
 	}
 
 	client := &Client{
-		backend: opts.Backend,
-		path:    opts.Path,
-		closed:  false,
+		backend:         opts.Backend,
+		path:            opts.Path,
+		closed:          false,
+		relationWeights: make(map[string]float64),
 	}
 
 	// 根据后端类型初始化存储
 	switch opts.Backend {
 	case "memory":
 		client.quads = make(map[string]map[string]map[string]bool)
+		client.nodes = make(map[string][]byte)
 		logrus.Info("[Graph] Using memory backend")
 
-	case "badger":
-		// 如果是文件存储，确保目录存在
-		if opts.Path == "" {
-			return nil, fmt.Errorf("badger backend requires a path")
-		}
-		if err := os.MkdirAll(opts.Path, 0755); err != nil {
-			return nil, fmt.Errorf("failed to create graph database directory: %w", err)
-		}
-
-		store, err := badger.Open(opts.Path, badger.Options{
-			InMemory:   false,
-			SyncWrites: false, // 异步写入性能更好
-		})
+	case "badger", "leveldb":
+		store, err := openKVStore(opts.Backend, opts.Path)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open badger store: %w", err)
+			return nil, err
 		}
 		client.store = store
-		logrus.WithField("path", opts.Path).Info("[Graph] Using Badger backend")
+		logrus.WithFields(logrus.Fields{"backend": opts.Backend, "path": opts.Path}).Info("[Graph] Using persistent backend")
 
 	default:
 		return nil, fmt.Errorf("unsupported backend: %s", opts.Backend)
@@ -88,9 +80,10 @@ func (c *Client) Close() error {
 
 	if c.backend == "memory" {
 		c.quads = nil
+		c.nodes = nil
 	} else if c.store != nil {
-		if err := c.store.Close(); err != nil {
-			return fmt.Errorf("failed to close badger store: %w", err)
+		if err := c.store.close(); err != nil {
+			return fmt.Errorf("failed to close %s store: %w", c.backend, err)
 		}
 		c.store = nil
 	}
@@ -135,18 +128,18 @@ func (c *Client) AddQuad(ctx context.Context, subject, predicate, object string,
 		return nil
 	}
 
-	// Badger 后端
+	// 持久化后端
 	if c.store == nil {
-		return fmt.Errorf("badger store not initialized")
+		return fmt.Errorf("graph store not initialized")
 	}
 
 	// 使用事务写入
-	return c.store.WithUpdate(ctx, func(txn *badgerdb.Txn) error {
+	return c.store.update(ctx, func(w kvWriter) error {
 		// 存储四元组（值存储为 1，表示存在）
 		key := quadKey(subject, predicate, object)
 		value := make([]byte, 8)
 		binary.BigEndian.PutUint64(value, 1)
-		if err := txn.Set(key, value); err != nil {
+		if err := w.set(key, value); err != nil {
 			return fmt.Errorf("failed to set quad: %w", err)
 		}
 
@@ -154,14 +147,14 @@ func (c *Client) AddQuad(ctx context.Context, subject, predicate, object string,
 		// SP 索引：subject -> predicate -> objects
 		spKey := append(indexKeySP(subject, predicate), []byte(":"+object)...)
 		spValue := []byte(object)
-		if err := txn.Set(spKey, spValue); err != nil {
+		if err := w.set(spKey, spValue); err != nil {
 			return fmt.Errorf("failed to set SP index: %w", err)
 		}
 
 		// PO 索引：predicate -> object -> subjects
 		poKey := append(indexKeyPO(predicate, object), []byte(":"+subject)...)
 		poValue := []byte(subject)
-		if err := txn.Set(poKey, poValue); err != nil {
+		if err := w.set(poKey, poValue); err != nil {
 			return fmt.Errorf("failed to set PO index: %w", err)
 		}
 
@@ -197,24 +190,24 @@ func (c *Client) RemoveQuad(ctx context.Context, subject, predicate, object stri
 		return nil
 	}
 
-	// Badger 后端
+	// 持久化后端
 	if c.store == nil {
-		return fmt.Errorf("badger store not initialized")
+		return fmt.Errorf("graph store not initialized")
 	}
 
-	return c.store.WithUpdate(ctx, func(txn *badgerdb.Txn) error {
+	return c.store.update(ctx, func(w kvWriter) error {
 		// 删除四元组
 		key := quadKey(subject, predicate, object)
-		if err := txn.Delete(key); err != nil {
+		if err := w.delete(key); err != nil {
 			return fmt.Errorf("failed to delete quad: %w", err)
 		}
 
 		// 删除索引
 		spKey := append(indexKeySP(subject, predicate), []byte(":"+object)...)
-		_ = txn.Delete(spKey) // 忽略错误，可能不存在
+		_ = w.delete(spKey) // 忽略错误，可能不存在
 
 		poKey := append(indexKeyPO(predicate, object), []byte(":"+subject)...)
-		_ = txn.Delete(poKey) // 忽略错误，可能不存在
+		_ = w.delete(poKey) // 忽略错误，可能不存在
 
 		logrus.WithFields(logrus.Fields{
 			"subject":   subject,
@@ -245,6 +238,28 @@ func (c *Client) Unlink(ctx context.Context, from, relation, to string) error {
 	return c.RemoveQuad(ctx, from, relation, to)
 }
 
+// SetRelationWeight 设置关系（谓词）的权重
+// 权重 <= 0 时恢复为默认权重 1
+func (c *Client) SetRelationWeight(relation string, weight float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if weight <= 0 {
+		delete(c.relationWeights, relation)
+		return
+	}
+	c.relationWeights[relation] = weight
+}
+
+// RelationWeight 返回关系（谓词）的权重，未设置时为 1
+func (c *Client) RelationWeight(relation string) float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if w, ok := c.relationWeights[relation]; ok {
+		return w
+	}
+	return 1
+}
+
 // Path 返回存储路径
 func (c *Client) Path() string {
 	return c.path
@@ -276,45 +291,37 @@ func (c *Client) getQuadsBySubject(ctx context.Context, subject string) (map[str
 		return result, nil
 	}
 
-	// Badger 后端
+	// 持久化后端
 	if c.store == nil {
-		return nil, fmt.Errorf("badger store not initialized")
+		return nil, fmt.Errorf("graph store not initialized")
 	}
 
 	result := make(map[string]map[string]bool)
 	prefix := []byte(fmt.Sprintf("quad:%s:", subject))
 
-	err := c.store.WithView(ctx, func(txn *badgerdb.Txn) error {
-		opts := badgerdb.DefaultIteratorOptions
-		opts.Prefix = prefix
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		for it.Rewind(); it.Valid(); it.Next() {
-			item := it.Item()
-			key := string(item.Key())
-			// 解析 key: quad:{subject}:{predicate}:{object}
-			// 跳过 "quad:" 和 subject
-			rest := key[len(prefix):]
-			// 找到第一个冒号，分割 predicate 和 object
-			predEnd := 0
-			for i, ch := range rest {
-				if ch == ':' {
-					predEnd = i
-					break
-				}
+	err := c.store.scanPrefix(ctx, prefix, func(k []byte) error {
+		key := string(k)
+		// 解析 key: quad:{subject}:{predicate}:{object}
+		// 跳过 "quad:" 和 subject
+		rest := key[len(prefix):]
+		// 找到第一个冒号，分割 predicate 和 object
+		predEnd := 0
+		for i, ch := range rest {
+			if ch == ':' {
+				predEnd = i
+				break
 			}
-			if predEnd == 0 {
-				continue
-			}
-			predicate := rest[:predEnd]
-			object := rest[predEnd+1:]
+		}
+		if predEnd == 0 {
+			return nil
+		}
+		predicate := rest[:predEnd]
+		object := rest[predEnd+1:]
 
-			if result[predicate] == nil {
-				result[predicate] = make(map[string]bool)
-			}
-			result[predicate][object] = true
+		if result[predicate] == nil {
+			result[predicate] = make(map[string]bool)
 		}
+		result[predicate][object] = true
 		return nil
 	})
 
@@ -341,44 +348,33 @@ func (c *Client) getQuadsByObject(ctx context.Context, object string) ([]QueryRe
 		return results, nil
 	}
 
-	// Badger 后端：遍历所有四元组查找指向 object 的
+	// 持久化后端：遍历所有四元组查找指向 object 的
 	if c.store == nil {
-		return nil, fmt.Errorf("badger store not initialized")
+		return nil, fmt.Errorf("graph store not initialized")
 	}
 
 	var results []QueryResult
 	prefix := []byte("quad:")
 
-	err := c.store.WithView(ctx, func(txn *badgerdb.Txn) error {
-		opts := badgerdb.DefaultIteratorOptions
-		opts.Prefix = prefix
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		for it.Rewind(); it.Valid(); it.Next() {
-			item := it.Item()
-			key := string(item.Key())
-			// 解析 key: quad:{subject}:{predicate}:{object}
-			if len(key) < len("quad:") {
-				continue
-			}
-			rest := key[len("quad:"):]
-			// 分割 subject:predicate:object
-			parts := splitKey(rest, ":")
-			if len(parts) < 3 {
-				continue
-			}
-			subject := parts[0]
-			predicate := parts[1]
-			obj := parts[2]
-
-			if obj == object {
-				results = append(results, QueryResult{
-					Subject:   subject,
-					Predicate: predicate,
-					Object:    object,
-				})
-			}
+	err := c.store.scanPrefix(ctx, prefix, func(k []byte) error {
+		key := string(k)
+		// 解析 key: quad:{subject}:{predicate}:{object}
+		rest := key[len(prefix):]
+		// 分割 subject:predicate:object
+		parts := splitKey(rest, ":")
+		if len(parts) < 3 {
+			return nil
+		}
+		subject := parts[0]
+		predicate := parts[1]
+		obj := parts[2]
+
+		if obj == object {
+			results = append(results, QueryResult{
+				Subject:   subject,
+				Predicate: predicate,
+				Object:    object,
+			})
 		}
 		return nil
 	})
@@ -402,3 +398,67 @@ func splitKey(s string, sep string) []string {
 	}
 	return parts
 }
+
+// AllQuads 返回存储中的所有四元组
+func (c *Client) AllQuads(ctx context.Context) ([]QueryResult, error) {
+	if c.backend == "memory" {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		var results []QueryResult
+		for subject, preds := range c.quads {
+			for pred, objects := range preds {
+				for obj := range objects {
+					results = append(results, QueryResult{
+						Subject:   subject,
+						Predicate: pred,
+						Object:    obj,
+					})
+				}
+			}
+		}
+		return results, nil
+	}
+
+	// 持久化后端
+	if c.store == nil {
+		return nil, fmt.Errorf("graph store not initialized")
+	}
+
+	var results []QueryResult
+	prefix := []byte("quad:")
+
+	err := c.store.scanPrefix(ctx, prefix, func(k []byte) error {
+		parts := splitKey(string(k[len(prefix):]), ":")
+		if len(parts) < 3 {
+			return nil
+		}
+		results = append(results, QueryResult{
+			Subject:   parts[0],
+			Predicate: parts[1],
+			Object:    parts[2],
+		})
+		return nil
+	})
+
+	return results, err
+}
+
+// Stats 统计图中的节点数与边数
+// 节点为所有四元组中出现过的 subject 与 object 去重后的集合，边为四元组数量
+func (c *Client) Stats(ctx context.Context) (nodeCount int64, edgeCount int64, err error) {
+	if c.IsClosed() {
+		return 0, 0, fmt.Errorf("graph database is closed")
+	}
+
+	quads, err := c.AllQuads(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	nodes := make(map[string]struct{})
+	for _, q := range quads {
+		nodes[q.Subject] = struct{}{}
+		nodes[q.Object] = struct{}{}
+	}
+	return int64(len(nodes)), int64(len(quads)), nil
+}
@@ -0,0 +1,296 @@
+package cayley
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OutEdges 返回以 nodeID 为 subject 的所有四元组（出边），按谓词与对象排序
+func (c *Client) OutEdges(ctx context.Context, nodeID string) ([]QueryResult, error) {
+	if c.IsClosed() {
+		return nil, fmt.Errorf("graph database is closed")
+	}
+
+	quads, err := c.getQuadsBySubject(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	var results []QueryResult
+	for pred, objects := range quads {
+		for obj := range objects {
+			results = append(results, QueryResult{Subject: nodeID, Predicate: pred, Object: obj})
+		}
+	}
+	sortQuads(results)
+	return results, nil
+}
+
+// InEdges 返回以 nodeID 为 object 的所有四元组（入边），按主语与谓词排序
+func (c *Client) InEdges(ctx context.Context, nodeID string) ([]QueryResult, error) {
+	if c.IsClosed() {
+		return nil, fmt.Errorf("graph database is closed")
+	}
+
+	results, err := c.getQuadsByObject(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	sortQuads(results)
+	return results, nil
+}
+
+// AllNodes 返回图中所有节点（四元组中出现过的 subject 与 object，以及设置过属性的节点），按 ID 排序
+func (c *Client) AllNodes(ctx context.Context) ([]string, error) {
+	if c.IsClosed() {
+		return nil, fmt.Errorf("graph database is closed")
+	}
+
+	quads, err := c.AllQuads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{})
+	for _, q := range quads {
+		seen[q.Subject] = struct{}{}
+		seen[q.Object] = struct{}{}
+	}
+
+	if c.backend == "memory" {
+		c.mu.RLock()
+		for id := range c.nodes {
+			seen[id] = struct{}{}
+		}
+		c.mu.RUnlock()
+	} else {
+		if c.store == nil {
+			return nil, fmt.Errorf("graph store not initialized")
+		}
+		prefix := nodeKey("")
+		err := c.store.scanPrefix(ctx, prefix, func(k []byte) error {
+			seen[string(k[len(prefix):])] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	nodes := make([]string, 0, len(seen))
+	for id := range seen {
+		nodes = append(nodes, id)
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// DeleteNode 删除节点属性
+// cascade 为 true 时在同一个事务中删除所有涉及该节点的四元组；
+// 为 false 时如果节点仍有出边或入边则返回错误，避免留下指向不存在节点的边
+func (c *Client) DeleteNode(ctx context.Context, nodeID string, cascade bool) error {
+	if nodeID == "" {
+		return fmt.Errorf("node id cannot be empty")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		logrus.Error("[Graph] DeleteNode failed - graph database is closed")
+		return fmt.Errorf("graph database is closed")
+	}
+
+	if c.backend == "memory" {
+		edges := c.memoryEdgesOf(nodeID)
+		if len(edges) > 0 && !cascade {
+			return fmt.Errorf("node %s still has %d edges", nodeID, len(edges))
+		}
+		for _, e := range edges {
+			delete(c.quads[e.Subject][e.Predicate], e.Object)
+			if len(c.quads[e.Subject][e.Predicate]) == 0 {
+				delete(c.quads[e.Subject], e.Predicate)
+			}
+			if len(c.quads[e.Subject]) == 0 {
+				delete(c.quads, e.Subject)
+			}
+		}
+		delete(c.nodes, nodeID)
+		logrus.WithFields(logrus.Fields{"node": nodeID, "edges": len(edges)}).Debug("[Graph] DeleteNode")
+		return nil
+	}
+
+	// 持久化后端
+	if c.store == nil {
+		return fmt.Errorf("graph store not initialized")
+	}
+
+	// 持有写锁期间收集边并删除，保证删除过程中不会有新边写入
+	var edges []QueryResult
+	prefix := []byte("quad:")
+	err := c.store.scanPrefix(ctx, prefix, func(k []byte) error {
+		parts := splitKey(string(k[len(prefix):]), ":")
+		if len(parts) < 3 {
+			return nil
+		}
+		if parts[0] == nodeID || parts[2] == nodeID {
+			edges = append(edges, QueryResult{Subject: parts[0], Predicate: parts[1], Object: parts[2]})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(edges) > 0 && !cascade {
+		return fmt.Errorf("node %s still has %d edges", nodeID, len(edges))
+	}
+
+	return c.store.update(ctx, func(w kvWriter) error {
+		for _, e := range edges {
+			if err := w.delete(quadKey(e.Subject, e.Predicate, e.Object)); err != nil {
+				return fmt.Errorf("failed to delete quad: %w", err)
+			}
+			_ = w.delete(append(indexKeySP(e.Subject, e.Predicate), []byte(":"+e.Object)...))
+			_ = w.delete(append(indexKeyPO(e.Predicate, e.Object), []byte(":"+e.Subject)...))
+		}
+		if err := w.delete(nodeKey(nodeID)); err != nil {
+			return fmt.Errorf("failed to delete node: %w", err)
+		}
+		logrus.WithFields(logrus.Fields{"node": nodeID, "edges": len(edges)}).Debug("[Graph] DeleteNode")
+		return nil
+	})
+}
+
+// memoryEdgesOf 返回内存存储中涉及 nodeID 的所有四元组，调用方需持有锁
+func (c *Client) memoryEdgesOf(nodeID string) []QueryResult {
+	var edges []QueryResult
+	for subject, preds := range c.quads {
+		for pred, objects := range preds {
+			for obj := range objects {
+				if subject == nodeID || obj == nodeID {
+					edges = append(edges, QueryResult{Subject: subject, Predicate: pred, Object: obj})
+				}
+			}
+		}
+	}
+	return edges
+}
+
+// sortQuads 按 subject、predicate、object 排序四元组
+func sortQuads(quads []QueryResult) {
+	sort.Slice(quads, func(i, j int) bool {
+		if quads[i].Subject != quads[j].Subject {
+			return quads[i].Subject < quads[j].Subject
+		}
+		if quads[i].Predicate != quads[j].Predicate {
+			return quads[i].Predicate < quads[j].Predicate
+		}
+		return quads[i].Object < quads[j].Object
+	})
+}
+
+// SubgraphOptions 子图提取选项
+type SubgraphOptions struct {
+	// MaxDepth 从种子节点出发的最大跳数，默认 1
+	MaxDepth int
+	// Relation 只沿指定谓词的边扩展，为空时使用所有边
+	Relation string
+	// MaxNodes 子图节点数上限（包括种子节点），<= 0 表示不限制
+	MaxNodes int
+	// Direction 扩展方向："out"（沿出边）、"in"（沿入边）或 "both"（默认）
+	Direction string
+}
+
+// Subgraph 从种子节点出发按广度优先提取子图
+// 返回的节点按访问顺序排列（种子节点在前），边为扩展过程中经过且两端都在子图中的边，
+// 深度为 MaxDepth 的节点不再扩展，因此它们之间的边不包含在结果中
+func (c *Client) Subgraph(ctx context.Context, seeds []string, opts SubgraphOptions) ([]string, []QueryResult, error) {
+	if c.IsClosed() {
+		return nil, nil, fmt.Errorf("graph database is closed")
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = 1
+	}
+	if opts.Direction == "" {
+		opts.Direction = "both"
+	}
+	followOut := opts.Direction == "out" || opts.Direction == "both"
+	followIn := opts.Direction == "in" || opts.Direction == "both"
+	if !followOut && !followIn {
+		return nil, nil, fmt.Errorf("unsupported direction: %s", opts.Direction)
+	}
+
+	quads, err := c.AllQuads(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 每个节点可沿扩展方向经过的边
+	adjacency := make(map[string][]QueryResult)
+	for _, q := range quads {
+		if opts.Relation != "" && q.Predicate != opts.Relation {
+			continue
+		}
+		if followOut {
+			adjacency[q.Subject] = append(adjacency[q.Subject], q)
+		}
+		if followIn && q.Subject != q.Object {
+			adjacency[q.Object] = append(adjacency[q.Object], q)
+		}
+	}
+	for _, edges := range adjacency {
+		sortQuads(edges)
+	}
+
+	full := func(nodes []string) bool {
+		return opts.MaxNodes > 0 && len(nodes) >= opts.MaxNodes
+	}
+	depth := make(map[string]int)
+	var nodes []string
+	for _, seed := range seeds {
+		if _, ok := depth[seed]; ok || full(nodes) {
+			continue
+		}
+		depth[seed] = 0
+		nodes = append(nodes, seed)
+	}
+
+	edgeSet := make(map[QueryResult]struct{})
+	var edges []QueryResult
+	for i := 0; i < len(nodes); i++ {
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		default:
+		}
+		current := nodes[i]
+		if depth[current] >= opts.MaxDepth {
+			continue
+		}
+		for _, q := range adjacency[current] {
+			neighbor := q.Object
+			if neighbor == current {
+				neighbor = q.Subject
+			}
+			if _, ok := depth[neighbor]; !ok {
+				if full(nodes) {
+					continue
+				}
+				depth[neighbor] = depth[current] + 1
+				nodes = append(nodes, neighbor)
+			}
+			if _, ok := edgeSet[q]; !ok {
+				edgeSet[q] = struct{}{}
+				edges = append(edges, q)
+			}
+		}
+	}
+	sortQuads(edges)
+
+	logrus.WithFields(logrus.Fields{
+		"seeds": len(seeds),
+		"nodes": len(nodes),
+		"edges": len(edges),
+	}).Debug("[Graph] Subgraph")
+	return nodes, edges, nil
+}
@@ -0,0 +1,180 @@
+package cayley
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	badgerdb "github.com/dgraph-io/badger/v4"
+	"github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// kvStore 持久化后端的键值存储抽象
+// 各后端使用相同的键格式（quad:/idx:），数据可通过重新写入四元组在后端之间迁移
+type kvStore interface {
+	// update 原子地执行一组写操作
+	update(ctx context.Context, fn func(w kvWriter) error) error
+	// get 读取指定键的值，键不存在时返回 nil, nil
+	get(ctx context.Context, key []byte) ([]byte, error)
+	// scanPrefix 按键顺序遍历指定前缀的所有键，key 仅在回调期间有效
+	scanPrefix(ctx context.Context, prefix []byte, fn func(key []byte) error) error
+	// close 关闭底层存储
+	close() error
+}
+
+// kvWriter 写操作接口
+type kvWriter interface {
+	set(key, value []byte) error
+	delete(key []byte) error
+}
+
+// openKVStore 按后端类型打开持久化存储
+func openKVStore(backend, path string) (kvStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%s backend requires a path", backend)
+	}
+	// 如果是文件存储，确保目录存在
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create graph database directory: %w", err)
+	}
+
+	switch backend {
+	case "badger":
+		store, err := badger.Open(path, badger.Options{
+			InMemory:   false,
+			SyncWrites: false, // 异步写入性能更好
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open badger store: %w", err)
+		}
+		return &badgerKV{store: store}, nil
+
+	case "leveldb":
+		db, err := leveldb.OpenFile(path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open leveldb store: %w", err)
+		}
+		return &levelKV{db: db}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported backend: %s", backend)
+	}
+}
+
+// badgerKV 基于 Badger 的 kvStore 实现
+type badgerKV struct {
+	store *badger.Store
+}
+
+type badgerWriter struct {
+	txn *badgerdb.Txn
+}
+
+func (w badgerWriter) set(key, value []byte) error { return w.txn.Set(key, value) }
+func (w badgerWriter) delete(key []byte) error     { return w.txn.Delete(key) }
+
+func (s *badgerKV) update(ctx context.Context, fn func(w kvWriter) error) error {
+	return s.store.WithUpdate(ctx, func(txn *badgerdb.Txn) error {
+		return fn(badgerWriter{txn: txn})
+	})
+}
+
+func (s *badgerKV) get(ctx context.Context, key []byte) ([]byte, error) {
+	var value []byte
+	err := s.store.WithView(ctx, func(txn *badgerdb.Txn) error {
+		item, err := txn.Get(key)
+		if err == badgerdb.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		value, err = item.ValueCopy(nil)
+		return err
+	})
+	return value, err
+}
+
+func (s *badgerKV) scanPrefix(ctx context.Context, prefix []byte, fn func(key []byte) error) error {
+	return s.store.WithView(ctx, func(txn *badgerdb.Txn) error {
+		opts := badgerdb.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := fn(it.Item().Key()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *badgerKV) close() error {
+	return s.store.Close()
+}
+
+// levelKV 基于 LevelDB 的 kvStore 实现
+type levelKV struct {
+	db *leveldb.DB
+}
+
+type levelWriter struct {
+	batch *leveldb.Batch
+}
+
+func (w levelWriter) set(key, value []byte) error {
+	w.batch.Put(key, value)
+	return nil
+}
+
+func (w levelWriter) delete(key []byte) error {
+	w.batch.Delete(key)
+	return nil
+}
+
+func (s *levelKV) update(ctx context.Context, fn func(w kvWriter) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// 写操作先收集到批处理中，回调成功后一次性原子写入
+	batch := new(leveldb.Batch)
+	if err := fn(levelWriter{batch: batch}); err != nil {
+		return err
+	}
+	return s.db.Write(batch, nil)
+}
+
+func (s *levelKV) get(ctx context.Context, key []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	value, err := s.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (s *levelKV) scanPrefix(ctx context.Context, prefix []byte, fn func(key []byte) error) error {
+	it := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer it.Release()
+
+	for it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(it.Key()); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+func (s *levelKV) close() error {
+	return s.db.Close()
+}
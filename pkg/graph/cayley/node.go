@@ -0,0 +1,143 @@
+package cayley
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// nodeKey 生成节点属性的 key
+func nodeKey(id string) []byte {
+	// 格式: node:{id}
+	return []byte("node:" + id)
+}
+
+// MergeNode 创建或更新节点属性
+// 按 RFC 7396 (JSON Merge Patch) 语义合并：值为 nil 的属性会被删除，
+// 嵌套对象递归合并，其他值直接覆盖
+func (c *Client) MergeNode(ctx context.Context, id string, attrs map[string]any) error {
+	if id == "" {
+		return fmt.Errorf("node id cannot be empty")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		logrus.Error("[Graph] MergeNode failed - graph database is closed")
+		return fmt.Errorf("graph database is closed")
+	}
+
+	if c.backend == "memory" {
+		merged, err := mergeNodeAttrs(c.nodes[id], attrs)
+		if err != nil {
+			return err
+		}
+		c.nodes[id] = merged
+		logrus.WithField("node", id).Debug("[Graph] MergeNode")
+		return nil
+	}
+
+	// 持久化后端
+	if c.store == nil {
+		return fmt.Errorf("graph store not initialized")
+	}
+
+	// 读-改-写在持有写锁的情况下进行，保证并发合并不会丢失更新
+	existing, err := c.store.get(ctx, nodeKey(id))
+	if err != nil {
+		return fmt.Errorf("failed to get node: %w", err)
+	}
+	merged, err := mergeNodeAttrs(existing, attrs)
+	if err != nil {
+		return err
+	}
+	return c.store.update(ctx, func(w kvWriter) error {
+		if err := w.set(nodeKey(id), merged); err != nil {
+			return fmt.Errorf("failed to set node: %w", err)
+		}
+		logrus.WithField("node", id).Debug("[Graph] MergeNode")
+		return nil
+	})
+}
+
+// GetNode 获取节点属性，节点不存在时返回 nil, nil
+func (c *Client) GetNode(ctx context.Context, id string) (map[string]any, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, fmt.Errorf("graph database is closed")
+	}
+
+	var data []byte
+	if c.backend == "memory" {
+		data = c.nodes[id]
+	} else {
+		if c.store == nil {
+			return nil, fmt.Errorf("graph store not initialized")
+		}
+		var err error
+		data, err = c.store.get(ctx, nodeKey(id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get node: %w", err)
+		}
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var attrs map[string]any
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal node attributes: %w", err)
+	}
+	return attrs, nil
+}
+
+// mergeNodeAttrs 将 patch 合并到已序列化的节点属性中，返回新的序列化结果
+func mergeNodeAttrs(existing []byte, patch map[string]any) ([]byte, error) {
+	target := make(map[string]any)
+	if existing != nil {
+		if err := json.Unmarshal(existing, &target); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal node attributes: %w", err)
+		}
+	}
+
+	// 先将 patch 规范化为 JSON 值，保证嵌套对象统一为 map[string]any
+	raw, err := json.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal node attributes: %w", err)
+	}
+	var normalized map[string]any
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal node attributes: %w", err)
+	}
+
+	merged, err := json.Marshal(mergePatch(target, normalized))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal node attributes: %w", err)
+	}
+	return merged, nil
+}
+
+// mergePatch 实现 RFC 7396 的合并算法
+func mergePatch(target, patch map[string]any) map[string]any {
+	if target == nil {
+		target = make(map[string]any)
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		if patchObj, ok := value.(map[string]any); ok {
+			targetObj, _ := target[key].(map[string]any)
+			target[key] = mergePatch(targetObj, patchObj)
+			continue
+		}
+		target[key] = value
+	}
+	return target
+}
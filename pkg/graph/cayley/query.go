@@ -3,6 +3,7 @@ package cayley
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"github.com/sirupsen/logrus"
 )
@@ -530,3 +531,91 @@ func (c *Client) FindPath(ctx context.Context, from, to string, maxDepth int, re
 	}).Debug("[Graph] FindPath completed")
 	return paths, nil
 }
+
+// recommendDamping 推荐算法中每一跳的衰减系数，越远的节点贡献越小
+const recommendDamping = 0.85
+
+// Recommend 基于个性化 PageRank（截断为 hops 步的加权随机游走）推荐相关节点
+// 边按无向处理，转移概率与关系权重成正比；返回按相关度降序排列的节点 ID，不包含起始节点
+func (c *Client) Recommend(ctx context.Context, nodeID string, hops int, limit int) ([]string, error) {
+	logrus.WithFields(logrus.Fields{
+		"nodeID": nodeID,
+		"hops":   hops,
+		"limit":  limit,
+	}).Debug("[Graph] Recommend")
+	if c.IsClosed() {
+		return nil, fmt.Errorf("graph database is closed")
+	}
+	if hops <= 0 {
+		hops = 2 // 默认两跳
+	}
+
+	quads, err := c.AllQuads(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 构建带权邻接表（无向）
+	adjacency := make(map[string]map[string]float64)
+	addEdge := func(from, to string, weight float64) {
+		if adjacency[from] == nil {
+			adjacency[from] = make(map[string]float64)
+		}
+		adjacency[from][to] += weight
+	}
+	for _, q := range quads {
+		if q.Subject == q.Object {
+			continue
+		}
+		weight := c.RelationWeight(q.Predicate)
+		addEdge(q.Subject, q.Object, weight)
+		addEdge(q.Object, q.Subject, weight)
+	}
+
+	// 每个节点的出边总权重，用于归一化转移概率
+	totals := make(map[string]float64, len(adjacency))
+	for node, edges := range adjacency {
+		for _, w := range edges {
+			totals[node] += w
+		}
+	}
+
+	scores := make(map[string]float64)
+	current := map[string]float64{nodeID: 1}
+	decay := 1.0
+	for step := 0; step < hops; step++ {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		next := make(map[string]float64)
+		for node, mass := range current {
+			for neighbor, w := range adjacency[node] {
+				next[neighbor] += mass * w / totals[node]
+			}
+		}
+		decay *= recommendDamping
+		for node, mass := range next {
+			scores[node] += decay * mass
+		}
+		current = next
+	}
+	delete(scores, nodeID)
+
+	nodes := make([]string, 0, len(scores))
+	for node := range scores {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if scores[nodes[i]] != scores[nodes[j]] {
+			return scores[nodes[i]] > scores[nodes[j]]
+		}
+		return nodes[i] < nodes[j]
+	})
+	if limit > 0 && len(nodes) > limit {
+		nodes = nodes[:limit]
+	}
+	return nodes, nil
+}
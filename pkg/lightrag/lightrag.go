@@ -262,13 +262,49 @@ func (r *LightRAG) InsertBatch(ctx context.Context, documents []map[string]any)
 
 // Query 执行查询
 func (r *LightRAG) Query(ctx context.Context, query string, param QueryParam) (string, error) {
-	results, err := r.Retrieve(ctx, query, param)
+	prompt, answer, err := r.answerPrompt(ctx, query, param)
 	if err != nil {
 		return "", err
 	}
+	if prompt == "" {
+		return answer, nil
+	}
+	return r.llm.Complete(ctx, prompt)
+}
+
+// QueryStream 执行查询并以流的形式返回回答，通道依次传递 LLM 生成的片段。
+// 所有片段拼接后与 Query 的返回值一致；无需调用 LLM 或 LLM 未实现 StreamingLLM 时整个回答作为单个片段发送。
+func (r *LightRAG) QueryStream(ctx context.Context, query string, param QueryParam) (<-chan string, error) {
+	prompt, answer, err := r.answerPrompt(ctx, query, param)
+	if err != nil {
+		return nil, err
+	}
+	if prompt != "" {
+		if streaming, ok := r.llm.(StreamingLLM); ok {
+			return streaming.CompleteStream(ctx, prompt)
+		}
+		answer, err = r.llm.Complete(ctx, prompt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tokens := make(chan string, 1)
+	tokens <- answer
+	close(tokens)
+	return tokens, nil
+}
+
+// answerPrompt 检索上下文并构造回答提示词。
+// 没有检索结果或未配置 LLM 时 prompt 为空，answer 即为最终回答。
+func (r *LightRAG) answerPrompt(ctx context.Context, query string, param QueryParam) (prompt string, answer string, err error) {
+	results, err := r.Retrieve(ctx, query, param)
+	if err != nil {
+		return "", "", err
+	}
 
 	if len(results) == 0 {
-		return "No relevant information found.", nil
+		return "", "No relevant information found.", nil
 	}
 
 	// 简单的上下文拼接
@@ -277,15 +313,15 @@ func (r *LightRAG) Query(ctx context.Context, query string, param QueryParam) (s
 		contextText += fmt.Sprintf("[%d] %s\n", i+1, res.Content)
 	}
 
-	if r.llm != nil {
-		promptStr, err := GetRAGAnswerPrompt(ctx, contextText, query)
-		if err != nil {
-			return "", fmt.Errorf("failed to get RAG answer prompt: %w", err)
-		}
-		return r.llm.Complete(ctx, promptStr)
+	if r.llm == nil {
+		return "", contextText, nil
 	}
 
-	return contextText, nil
+	prompt, err = GetRAGAnswerPrompt(ctx, contextText, query)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get RAG answer prompt: %w", err)
+	}
+	return prompt, "", nil
 }
 
 // Retrieve 执行检索
@@ -571,6 +607,65 @@ func (r *LightRAG) GetSubgraph(ctx context.Context, nodeID string, depth int) (*
 	return result, nil
 }
 
+// Stats 返回存储与索引的统计信息，可用于健康检查
+func (r *LightRAG) Stats(ctx context.Context) (*Stats, error) {
+	if !r.initialized {
+		return nil, fmt.Errorf("storages not initialized")
+	}
+
+	count, err := r.docs.Count(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	stats := &Stats{
+		DocumentCount: int64(count),
+		// 当前实现中每个文档作为一个整体分块进行索引
+		ChunkCount: int64(count),
+	}
+
+	// 只取 created_at 最大的一个文档，避免加载全部文档
+	latest, err := r.docs.Find(map[string]any{"created_at": map[string]any{"$exists": true}}).
+		Sort(map[string]string{"created_at": "desc"}).
+		Limit(1).
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find latest document: %w", err)
+	}
+	if len(latest) > 0 {
+		var createdAt int64
+		switch v := latest[0].Data()["created_at"].(type) {
+		case int64:
+			createdAt = v
+		case int:
+			createdAt = int64(v)
+		case float64:
+			createdAt = int64(v)
+		}
+		if createdAt > 0 {
+			stats.LastInsertedAt = time.Unix(createdAt, 0)
+		}
+	}
+
+	if r.fulltext != nil {
+		stats.FulltextIndexSize = int64(r.fulltext.Count())
+	}
+	if r.vector != nil {
+		stats.VectorIndexSize = int64(r.vector.Count())
+	}
+
+	if r.graph != nil {
+		graphStats, err := r.graph.Stats(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get graph stats: %w", err)
+		}
+		stats.GraphNodeCount = graphStats.NodeCount
+		stats.GraphEdgeCount = graphStats.EdgeCount
+	}
+
+	return stats, nil
+}
+
 // FinalizeStorages 关闭存储资源
 func (r *LightRAG) FinalizeStorages(ctx context.Context) error {
 	if r.fulltext != nil {
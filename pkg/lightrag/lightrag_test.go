@@ -732,3 +732,141 @@ func TestLightRAG_Insert_NotInitialized(t *testing.T) {
 		t.Errorf("expected error for uninitialized insert, got: %v", err)
 	}
 }
+
+func TestLightRAG_Stats(t *testing.T) {
+	ctx := context.Background()
+	workingDir := "./test_rag_stats"
+	defer os.RemoveAll(workingDir)
+
+	rag := New(Options{
+		WorkingDir: workingDir,
+		Embedder:   NewSimpleEmbedder(8),
+		LLM:        &SimpleLLM{},
+	})
+
+	if _, err := rag.Stats(ctx); err == nil {
+		t.Error("expected error when storages not initialized")
+	}
+
+	if err := rag.InitializeStorages(ctx); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	defer rag.FinalizeStorages(ctx)
+
+	before := time.Now().Add(-time.Second)
+	// SimpleLLM 对两篇文档都抽取 MockEntity -[MOCK_REL]-> OtherEntity
+	if err := rag.Insert(ctx, "first document"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if err := rag.Insert(ctx, "second document"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	// 等待后台索引与图谱抽取完成
+	var stats *Stats
+	var err error
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		stats, err = rag.Stats(ctx)
+		if err != nil {
+			t.Fatalf("Stats failed: %v", err)
+		}
+		if stats.FulltextIndexSize == 2 && stats.VectorIndexSize == 2 && stats.GraphEdgeCount == 3 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if stats.DocumentCount != 2 {
+		t.Errorf("expected DocumentCount 2, got %d", stats.DocumentCount)
+	}
+	if stats.ChunkCount != 2 {
+		t.Errorf("expected ChunkCount 2, got %d", stats.ChunkCount)
+	}
+	if stats.FulltextIndexSize != 2 {
+		t.Errorf("expected FulltextIndexSize 2, got %d", stats.FulltextIndexSize)
+	}
+	if stats.VectorIndexSize != 2 {
+		t.Errorf("expected VectorIndexSize 2, got %d", stats.VectorIndexSize)
+	}
+	// 节点：MockEntity、OtherEntity 以及两个文档 ID
+	if stats.GraphNodeCount != 4 {
+		t.Errorf("expected GraphNodeCount 4, got %d", stats.GraphNodeCount)
+	}
+	// 边：两条 APPEARS_IN 与一条 MOCK_REL
+	if stats.GraphEdgeCount != 3 {
+		t.Errorf("expected GraphEdgeCount 3, got %d", stats.GraphEdgeCount)
+	}
+	if stats.LastInsertedAt.Before(before.Truncate(time.Second)) || stats.LastInsertedAt.After(time.Now()) {
+		t.Errorf("unexpected LastInsertedAt: %v", stats.LastInsertedAt)
+	}
+}
+
+func TestLightRAG_QueryStream(t *testing.T) {
+	ctx := context.Background()
+	workingDir := "./test_rag_stream"
+	defer os.RemoveAll(workingDir)
+
+	rag := New(Options{
+		WorkingDir: workingDir,
+		LLM:        &SimpleLLM{},
+	})
+	if err := rag.InitializeStorages(ctx); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	defer rag.FinalizeStorages(ctx)
+
+	rag.Insert(ctx, "RxDB is a reactive database for JavaScript applications.")
+	time.Sleep(1 * time.Second)
+
+	param := QueryParam{Mode: ModeFulltext, Limit: 5}
+	expected, err := rag.Query(ctx, "What is RxDB?", param)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+
+	stream, err := rag.QueryStream(ctx, "What is RxDB?", param)
+	if err != nil {
+		t.Fatalf("query stream failed: %v", err)
+	}
+	var tokens []string
+	for token := range stream {
+		tokens = append(tokens, token)
+	}
+
+	if len(tokens) < 2 {
+		t.Errorf("expected response to be streamed in multiple tokens, got %d", len(tokens))
+	}
+	if got := strings.Join(tokens, ""); got != expected {
+		t.Errorf("expected streamed response %q, got %q", expected, got)
+	}
+
+	// 没有检索结果时整个回答作为单个片段返回
+	stream, err = rag.QueryStream(ctx, "zzzz", param)
+	if err != nil {
+		t.Fatalf("query stream failed: %v", err)
+	}
+	var got string
+	for token := range stream {
+		got += token
+	}
+	if got != "No relevant information found." {
+		t.Errorf("expected no result message, got %q", got)
+	}
+
+	// LLM 未实现 StreamingLLM 时回退到 Complete，整个回答作为单个片段返回
+	rag.llm = &FlexibleLLM{ResponseFunc: func(prompt string) (string, error) {
+		return "a complete answer", nil
+	}}
+	stream, err = rag.QueryStream(ctx, "What is RxDB?", param)
+	if err != nil {
+		t.Fatalf("query stream failed: %v", err)
+	}
+	tokens = tokens[:0]
+	for token := range stream {
+		tokens = append(tokens, token)
+	}
+	if len(tokens) != 1 || tokens[0] != "a complete answer" {
+		t.Errorf("expected single fallback token, got %q", tokens)
+	}
+}
@@ -53,6 +53,35 @@ func (l *SimpleLLM) Complete(ctx context.Context, prompt string) (string, error)
 	return "Simple LLM response", nil
 }
 
+// CompleteStream 模拟流式输出：将 Complete 的结果按单词切分后依次发送，
+// 每个片段保留其后的空格，拼接后与 Complete 的结果一致
+func (l *SimpleLLM) CompleteStream(ctx context.Context, prompt string) (<-chan string, error) {
+	response, err := l.Complete(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return streamWords(ctx, response), nil
+}
+
+// streamWords 将文本按单词切分后依次写入通道
+func streamWords(ctx context.Context, text string) <-chan string {
+	tokens := make(chan string)
+	go func() {
+		defer close(tokens)
+		for _, word := range strings.SplitAfter(text, " ") {
+			if word == "" {
+				continue
+			}
+			select {
+			case tokens <- word:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return tokens
+}
+
 // OpenAIConfig OpenAI 配置
 type OpenAIConfig struct {
 	APIKey  string
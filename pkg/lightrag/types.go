@@ -2,6 +2,7 @@ package lightrag
 
 import (
 	"context"
+	"time"
 )
 
 // QueryMode 查询模式
@@ -54,6 +55,17 @@ type GraphData struct {
 	Relationships []Relationship `json:"relationships"`
 }
 
+// Stats 存储与索引统计信息
+type Stats struct {
+	DocumentCount     int64     `json:"document_count"`      // 文档数量
+	ChunkCount        int64     `json:"chunk_count"`         // 分块数量
+	VectorIndexSize   int64     `json:"vector_index_size"`   // 向量索引条目数
+	FulltextIndexSize int64     `json:"fulltext_index_size"` // 全文索引条目数
+	GraphNodeCount    int64     `json:"graph_node_count"`    // 图节点数量
+	GraphEdgeCount    int64     `json:"graph_edge_count"`    // 图边数量
+	LastInsertedAt    time.Time `json:"last_inserted_at"`    // 最近一次插入时间
+}
+
 // Embedder 向量嵌入生成器接口（复用或参考 cognee.Embedder）
 type Embedder interface {
 	Embed(ctx context.Context, text string) ([]float64, error)
@@ -64,3 +76,11 @@ type Embedder interface {
 type LLM interface {
 	Complete(ctx context.Context, prompt string) (string, error)
 }
+
+// StreamingLLM 支持流式输出的语言模型接口（可选）。
+// LLM 同时实现该接口时 QueryStream 逐片段返回回答，否则整个回答作为单个片段返回。
+type StreamingLLM interface {
+	LLM
+	// CompleteStream 以流的形式返回生成结果，通道依次传递生成的片段，生成结束或 ctx 取消后关闭
+	CompleteStream(ctx context.Context, prompt string) (<-chan string, error)
+}
@@ -0,0 +1,789 @@
+package couchdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mozhou-tech/rxdb-go/pkg/rxdb"
+)
+
+// ReplicationState 同步状态。
+type ReplicationState string
+
+const (
+	StateIdle    ReplicationState = "idle"
+	StatePulling ReplicationState = "pulling"
+	StatePushing ReplicationState = "pushing"
+	StateError   ReplicationState = "error"
+	StateStopped ReplicationState = "stopped"
+)
+
+// ConflictHandler 冲突处理函数类型。
+// local 为本地文档，remote 为 CouchDB 上的文档（已转换为本地格式）；返回 nil 表示保留本地版本。
+type ConflictHandler func(local, remote map[string]any) map[string]any
+
+// ReplicationOptions 同步配置选项。
+type ReplicationOptions struct {
+	// URL CouchDB 服务地址，如 http://localhost:5984
+	URL string
+	// Database CouchDB 数据库名
+	Database string
+	// Username / Password Basic 认证凭据（可选）
+	Username string
+	Password string
+	// PrimaryKey 本地主键字段名，对应 CouchDB 的 _id（默认 "id"）
+	PrimaryKey string
+	// HeartbeatInterval _changes 长轮询的心跳间隔，出错后也按该间隔重试（默认 10 秒）
+	HeartbeatInterval time.Duration
+	// BatchSize 每次拉取的变更数量及每次 _bulk_docs 推送的文档数量（默认 100）
+	BatchSize int
+	// ConflictHandler 推送冲突或拉取到本地已修改文档时的处理函数（默认远程优先）
+	ConflictHandler ConflictHandler
+	// HTTPClient 自定义 HTTP 客户端。长轮询请求会持续到有变更为止，不应设置过短的 Timeout
+	HTTPClient *http.Client
+}
+
+// Replication CouchDB / PouchDB 双向同步客户端。
+// 拉取使用 _changes 长轮询，推送使用 _bulk_docs。
+// CouchDB 的修订号（_rev）由同步客户端按文档单独记录，不会写入本地文档的 _rev 字段，
+// 本地 _rev 也不会推送到 CouchDB，两套修订体系互不干扰。
+type Replication struct {
+	opts       ReplicationOptions
+	collection rxdb.Collection
+	state      ReplicationState
+	lastSeq    string
+	revs       map[string]string // 文档 ID -> 已知的 CouchDB 修订号
+	mu         sync.RWMutex
+	stopChan   chan struct{}
+	errChan    chan error
+	httpClient *http.Client
+}
+
+// changesResponse _changes 接口响应。
+type changesResponse struct {
+	Results []changeResult  `json:"results"`
+	LastSeq json.RawMessage `json:"last_seq"`
+	Pending int             `json:"pending"`
+}
+
+// changeResult _changes 中的单条变更。
+type changeResult struct {
+	Seq     json.RawMessage  `json:"seq"`
+	ID      string           `json:"id"`
+	Changes []map[string]any `json:"changes"`
+	Deleted bool             `json:"deleted"`
+	Doc     map[string]any   `json:"doc"`
+}
+
+// bulkDocsResult _bulk_docs 中单个文档的写入结果。
+type bulkDocsResult struct {
+	ID     string `json:"id"`
+	Rev    string `json:"rev"`
+	OK     bool   `json:"ok"`
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+// allDocsRow _all_docs 中的单个文档。
+type allDocsRow struct {
+	ID    string `json:"id"`
+	Value struct {
+		Rev string `json:"rev"`
+	} `json:"value"`
+}
+
+// NewReplication 创建新的同步实例。
+func NewReplication(collection rxdb.Collection, opts ReplicationOptions) (*Replication, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("couchdb URL is required")
+	}
+	if opts.Database == "" {
+		return nil, fmt.Errorf("database name is required")
+	}
+	opts.URL = strings.TrimRight(opts.URL, "/")
+	if opts.PrimaryKey == "" {
+		opts.PrimaryKey = "id"
+	}
+	if opts.HeartbeatInterval <= 0 {
+		opts.HeartbeatInterval = 10 * time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.ConflictHandler == nil {
+		opts.ConflictHandler = defaultConflictHandler
+	}
+
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+
+	return &Replication{
+		opts:       opts,
+		collection: collection,
+		state:      StateIdle,
+		revs:       make(map[string]string),
+		stopChan:   make(chan struct{}),
+		errChan:    make(chan error, 10),
+		httpClient: httpClient,
+	}, nil
+}
+
+// defaultConflictHandler 默认冲突处理：远程优先。
+func defaultConflictHandler(local, remote map[string]any) map[string]any {
+	return remote
+}
+
+// Start 启动双向同步：持续拉取 _changes，并将本地变更推送到 CouchDB。
+func (r *Replication) Start(ctx context.Context) error {
+	r.mu.Lock()
+	if r.state != StateIdle && r.state != StateStopped {
+		r.mu.Unlock()
+		return fmt.Errorf("replication already running")
+	}
+	r.state = StateIdle
+	r.stopChan = make(chan struct{})
+	stopChan := r.stopChan
+	r.mu.Unlock()
+
+	// Stop 时取消正在进行的长轮询
+	loopCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-stopChan:
+		case <-loopCtx.Done():
+		}
+		cancel()
+	}()
+
+	// 先订阅本地变更，避免遗漏启动期间的写入
+	changes := r.collection.Changes()
+	go r.pullLoop(loopCtx)
+	go r.pushLoop(loopCtx, changes)
+
+	return nil
+}
+
+// Stop 停止同步。
+func (r *Replication) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state == StateStopped {
+		return
+	}
+	r.state = StateStopped
+	close(r.stopChan)
+}
+
+// State 返回当前同步状态。
+func (r *Replication) State() ReplicationState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.state
+}
+
+// Errors 返回错误通道。
+func (r *Replication) Errors() <-chan error {
+	return r.errChan
+}
+
+// LastSeq 返回最近处理的 _changes 序列号，可用于观察同步进度。
+func (r *Replication) LastSeq() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastSeq
+}
+
+// PullOnce 拉取自上次同步以来的全部变更（非长轮询），直到没有待处理的变更。
+func (r *Replication) PullOnce(ctx context.Context) error {
+	for {
+		n, pending, err := r.pullChanges(ctx, false)
+		if err != nil {
+			return err
+		}
+		if n == 0 || pending == 0 {
+			return nil
+		}
+	}
+}
+
+// PushOnce 推送所有本地文档（用于初始化同步）。
+func (r *Replication) PushOnce(ctx context.Context) error {
+	docs, err := r.collection.All(ctx)
+	if err != nil {
+		return err
+	}
+
+	batch := make([]rxdb.ChangeEvent, 0, r.opts.BatchSize)
+	for _, doc := range docs {
+		batch = append(batch, rxdb.ChangeEvent{Op: rxdb.OperationUpdate, ID: doc.ID(), Doc: doc.Data()})
+		if len(batch) == r.opts.BatchSize {
+			if err := r.pushBatch(ctx, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		return r.pushBatch(ctx, batch)
+	}
+	return nil
+}
+
+// pullLoop 通过 _changes 长轮询持续拉取远程变更。
+func (r *Replication) pullLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if _, _, err := r.pullChanges(ctx, true); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			r.sendError(err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(r.opts.HeartbeatInterval):
+			}
+		}
+	}
+}
+
+// pullChanges 请求一批 _changes 并写入本地集合，返回处理的变更数与剩余待处理数。
+func (r *Replication) pullChanges(ctx context.Context, longpoll bool) (int, int, error) {
+	r.setState(StatePulling)
+	defer r.resetState(StatePulling)
+
+	params := url.Values{}
+	params.Set("include_docs", "true")
+	params.Set("limit", fmt.Sprintf("%d", r.opts.BatchSize))
+	if since := r.LastSeq(); since != "" {
+		params.Set("since", since)
+	}
+	if longpoll {
+		params.Set("feed", "longpoll")
+		params.Set("heartbeat", fmt.Sprintf("%d", r.opts.HeartbeatInterval.Milliseconds()))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.dbURL("_changes")+"?"+params.Encode(), nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create changes request: %w", err)
+	}
+	r.setHeaders(req)
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to pull from couchdb: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, 0, fmt.Errorf("couchdb changes failed: %s - %s", resp.Status, string(body))
+	}
+
+	var changes changesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&changes); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode changes response: %w", err)
+	}
+
+	for _, change := range changes.Results {
+		if err := r.processChange(ctx, change); err != nil {
+			r.sendError(err)
+		}
+	}
+
+	if seq := seqString(changes.LastSeq); seq != "" {
+		r.mu.Lock()
+		r.lastSeq = seq
+		r.mu.Unlock()
+	}
+	return len(changes.Results), changes.Pending, nil
+}
+
+// processChange 将一条远程变更写入本地集合。
+// 写入时使用 rxdb.ChangeSourceRemote 标记来源，推送循环据此跳过，避免回环。
+func (r *Replication) processChange(ctx context.Context, change changeResult) error {
+	if strings.HasPrefix(change.ID, "_design/") {
+		return nil
+	}
+	ctx = rxdb.WithChangeSource(ctx, rxdb.ChangeSourceRemote)
+
+	localDoc, err := r.collection.FindByID(ctx, change.ID)
+	if err != nil && !rxdb.IsNotFoundError(err) {
+		return fmt.Errorf("failed to find local document: %w", err)
+	}
+
+	if change.Deleted {
+		r.forgetRev(change.ID)
+		if localDoc == nil {
+			return nil
+		}
+		return r.collection.Remove(ctx, change.ID)
+	}
+	if change.Doc == nil {
+		return fmt.Errorf("change for document %s has no doc, include_docs is required", change.ID)
+	}
+
+	remoteRev, _ := change.Doc["_rev"].(string)
+	if localDoc != nil && remoteRev != "" && r.knownRev(change.ID) == remoteRev {
+		// 本地已是该修订（例如自己推送产生的变更）
+		return nil
+	}
+
+	remote := r.fromCouch(change.ID, change.Doc)
+	resolved := remote
+	if localDoc != nil {
+		resolved = r.opts.ConflictHandler(localDoc.Data(), remote)
+	}
+	r.rememberRev(change.ID, remoteRev)
+	if resolved == nil {
+		return nil
+	}
+	if localDoc == nil {
+		_, err = r.collection.Insert(ctx, resolved)
+	} else {
+		_, err = r.collection.Upsert(ctx, resolved)
+	}
+	return err
+}
+
+// pushLoop 监听本地变更并批量推送。
+func (r *Replication) pushLoop(ctx context.Context, changes <-chan rxdb.ChangeEvent) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-changes:
+			if !ok {
+				return
+			}
+			// 从远端拉取的变更无需再推送回去
+			if event.Source == rxdb.ChangeSourceRemote {
+				continue
+			}
+
+			// 合并已到达的变更为一批
+			batch := []rxdb.ChangeEvent{event}
+		collect:
+			for len(batch) < r.opts.BatchSize {
+				select {
+				case next, ok := <-changes:
+					if !ok {
+						break collect
+					}
+					if next.Source != rxdb.ChangeSourceRemote {
+						batch = append(batch, next)
+					}
+				default:
+					break collect
+				}
+			}
+
+			if err := r.pushBatch(ctx, batch); err != nil {
+				r.sendError(err)
+			}
+		}
+	}
+}
+
+// pushBatch 通过 _bulk_docs 推送一批本地变更，冲突的文档交给 ConflictHandler 处理。
+// 批中的 OperationTruncate 会清空远程数据库，其之前的变更因此无需推送。
+func (r *Replication) pushBatch(ctx context.Context, events []rxdb.ChangeEvent) error {
+	r.setState(StatePushing)
+	defer r.resetState(StatePushing)
+
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Op == rxdb.OperationTruncate {
+			if err := r.pushTruncate(ctx); err != nil {
+				return err
+			}
+			events = events[i+1:]
+			break
+		}
+	}
+
+	// 同一文档只保留最后一次变更
+	latest := make(map[string]rxdb.ChangeEvent, len(events))
+	order := make([]string, 0, len(events))
+	for _, event := range events {
+		if _, ok := latest[event.ID]; !ok {
+			order = append(order, event.ID)
+		}
+		latest[event.ID] = event
+	}
+
+	docs := make([]map[string]any, 0, len(order))
+	for _, id := range order {
+		event := latest[id]
+		rev := r.knownRev(id)
+		if event.Op == rxdb.OperationDelete {
+			if rev == "" {
+				var err error
+				if rev, err = r.fetchRev(ctx, id); err != nil {
+					return err
+				}
+				if rev == "" {
+					// 远程不存在，无需删除
+					continue
+				}
+			}
+			docs = append(docs, map[string]any{"_id": id, "_rev": rev, "_deleted": true})
+			continue
+		}
+		docs = append(docs, r.toCouch(id, rev, event.Doc))
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	results, err := r.bulkDocs(ctx, docs)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, res := range results {
+		switch {
+		case res.OK || (res.Error == "" && res.Rev != ""):
+			if latest[res.ID].Op == rxdb.OperationDelete {
+				r.forgetRev(res.ID)
+			} else {
+				r.rememberRev(res.ID, res.Rev)
+			}
+		case res.Error == "conflict":
+			if err := r.resolvePushConflict(ctx, latest[res.ID]); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", res.ID, err))
+			}
+		default:
+			errs = append(errs, fmt.Sprintf("%s: %s %s", res.ID, res.Error, res.Reason))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to push documents: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// pushTruncate 将本地的 Truncate 同步到 CouchDB：按 _all_docs 分批删除远程的所有文档（设计文档除外）。
+func (r *Replication) pushTruncate(ctx context.Context) error {
+	var errs []string
+	startKey := ""
+	for {
+		rows, err := r.allDocs(ctx, startKey)
+		if err != nil {
+			return err
+		}
+
+		docs := make([]map[string]any, 0, len(rows))
+		for _, row := range rows {
+			if row.ID == startKey || strings.HasPrefix(row.ID, "_design/") {
+				continue
+			}
+			docs = append(docs, map[string]any{"_id": row.ID, "_rev": row.Value.Rev, "_deleted": true})
+		}
+		if len(docs) > 0 {
+			results, err := r.bulkDocs(ctx, docs)
+			if err != nil {
+				return err
+			}
+			for _, res := range results {
+				if !res.OK && res.Error != "" {
+					errs = append(errs, fmt.Sprintf("%s: %s %s", res.ID, res.Error, res.Reason))
+				}
+			}
+		}
+
+		if len(rows) < r.opts.BatchSize || rows[len(rows)-1].ID == startKey {
+			break
+		}
+		startKey = rows[len(rows)-1].ID
+	}
+
+	r.mu.Lock()
+	r.revs = make(map[string]string)
+	r.mu.Unlock()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to truncate remote documents: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// allDocs 调用 _all_docs 从 startKey（包含）开始列出最多 BatchSize 个文档的 ID 与修订号。
+func (r *Replication) allDocs(ctx context.Context, startKey string) ([]allDocsRow, error) {
+	params := url.Values{}
+	params.Set("limit", fmt.Sprintf("%d", r.opts.BatchSize))
+	if startKey != "" {
+		key, err := json.Marshal(startKey)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("startkey", string(key))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.dbURL("_all_docs")+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	r.setHeaders(req)
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("all docs failed: %s - %s", resp.Status, string(body))
+	}
+
+	var result struct {
+		Rows []allDocsRow `json:"rows"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode all docs response: %w", err)
+	}
+	return result.Rows, nil
+}
+
+// resolvePushConflict 处理推送冲突：获取远程最新版本，按 ConflictHandler 决定结果。
+// 结果与远程一致时写入本地；否则写入本地并基于远程修订号重新推送。
+func (r *Replication) resolvePushConflict(ctx context.Context, event rxdb.ChangeEvent) error {
+	remoteDoc, err := r.fetchDoc(ctx, event.ID)
+	if err != nil {
+		return err
+	}
+	if remoteDoc == nil {
+		// 远程已删除，按新文档重新推送
+		r.forgetRev(event.ID)
+		if event.Op == rxdb.OperationDelete {
+			return nil
+		}
+		return r.pushBatch(ctx, []rxdb.ChangeEvent{event})
+	}
+
+	remoteRev, _ := remoteDoc["_rev"].(string)
+	r.rememberRev(event.ID, remoteRev)
+	remote := r.fromCouch(event.ID, remoteDoc)
+
+	local := event.Doc
+	if event.Op == rxdb.OperationDelete {
+		local = nil
+	}
+	resolved := r.opts.ConflictHandler(local, remote)
+	if resolved == nil {
+		// 保留本地版本，覆盖远程
+		if event.Op == rxdb.OperationDelete {
+			return r.pushBatch(ctx, []rxdb.ChangeEvent{event})
+		}
+		resolved = local
+	}
+
+	remoteCtx := rxdb.WithChangeSource(ctx, rxdb.ChangeSourceRemote)
+	if _, err := r.collection.Upsert(remoteCtx, resolved); err != nil {
+		return err
+	}
+	if reflect.DeepEqual(resolved, remote) {
+		return nil
+	}
+	return r.pushBatch(ctx, []rxdb.ChangeEvent{{Op: rxdb.OperationUpdate, ID: event.ID, Doc: resolved}})
+}
+
+// bulkDocs 调用 _bulk_docs 写入文档。
+func (r *Replication) bulkDocs(ctx context.Context, docs []map[string]any) ([]bulkDocsResult, error) {
+	body, err := json.Marshal(map[string]any{"docs": docs})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.dbURL("_bulk_docs"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.setHeaders(req)
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("bulk docs failed: %s - %s", resp.Status, string(respBody))
+	}
+
+	var results []bulkDocsResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, fmt.Errorf("failed to decode bulk docs response: %w", err)
+	}
+	return results, nil
+}
+
+// fetchDoc 获取远程文档，不存在时返回 nil。
+func (r *Replication) fetchDoc(ctx context.Context, id string) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.dbURL(url.PathEscape(id)), nil)
+	if err != nil {
+		return nil, err
+	}
+	r.setHeaders(req)
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("get doc failed: %s - %s", resp.Status, string(body))
+	}
+
+	var doc map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// fetchRev 获取远程文档当前的修订号，不存在时返回空字符串。
+func (r *Replication) fetchRev(ctx context.Context, id string) (string, error) {
+	doc, err := r.fetchDoc(ctx, id)
+	if err != nil || doc == nil {
+		return "", err
+	}
+	rev, _ := doc["_rev"].(string)
+	return rev, nil
+}
+
+// fromCouch 将 CouchDB 文档转换为本地文档：_id 映射为主键，移除 CouchDB 的系统字段。
+func (r *Replication) fromCouch(id string, doc map[string]any) map[string]any {
+	local := make(map[string]any, len(doc))
+	for k, v := range doc {
+		if strings.HasPrefix(k, "_") {
+			continue
+		}
+		local[k] = v
+	}
+	local[r.opts.PrimaryKey] = id
+	return local
+}
+
+// toCouch 将本地文档转换为 CouchDB 文档：主键映射为 _id，本地修订号替换为 CouchDB 修订号。
+func (r *Replication) toCouch(id, rev string, doc map[string]any) map[string]any {
+	revField := r.collection.Schema().RevField
+	couch := make(map[string]any, len(doc)+1)
+	for k, v := range doc {
+		if k == r.opts.PrimaryKey || k == revField || k == "_id" {
+			continue
+		}
+		couch[k] = v
+	}
+	couch["_id"] = id
+	if rev != "" {
+		couch["_rev"] = rev
+	}
+	return couch
+}
+
+// knownRev 返回记录的 CouchDB 修订号。
+func (r *Replication) knownRev(id string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.revs[id]
+}
+
+// rememberRev 记录文档的 CouchDB 修订号。
+func (r *Replication) rememberRev(id, rev string) {
+	if rev == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revs[id] = rev
+}
+
+// forgetRev 删除文档的修订号记录。
+func (r *Replication) forgetRev(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.revs, id)
+}
+
+// dbURL 返回数据库下指定路径的 URL。
+func (r *Replication) dbURL(path string) string {
+	return fmt.Sprintf("%s/%s/%s", r.opts.URL, url.PathEscape(r.opts.Database), path)
+}
+
+// setHeaders 设置 CouchDB 请求头。
+func (r *Replication) setHeaders(req *http.Request) {
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if r.opts.Username != "" {
+		req.SetBasicAuth(r.opts.Username, r.opts.Password)
+	}
+}
+
+// setState 设置同步状态（已停止时不变）。
+func (r *Replication) setState(state ReplicationState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state != StateStopped {
+		r.state = state
+	}
+}
+
+// resetState 操作结束后若仍处于 state 则恢复为空闲。
+func (r *Replication) resetState(state ReplicationState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state == state {
+		r.state = StateIdle
+	}
+}
+
+// sendError 发送错误到错误通道。
+func (r *Replication) sendError(err error) {
+	r.mu.Lock()
+	if r.state != StateStopped {
+		r.state = StateError
+	}
+	r.mu.Unlock()
+
+	select {
+	case r.errChan <- err:
+	default:
+		// 通道满时丢弃
+	}
+}
+
+// seqString 将 _changes 序列号转换为查询参数：CouchDB 2.x+ 为字符串，1.x 与 PouchDB 为数字。
+func seqString(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
@@ -0,0 +1,517 @@
+package couchdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mozhou-tech/rxdb-go/pkg/rxdb"
+	"github.com/mozhou-tech/rxdb-go/pkg/rxdb/testutil"
+)
+
+// fakeCouchDB 模拟 CouchDB 的 _changes、_bulk_docs 与单文档接口。
+type fakeCouchDB struct {
+	mu      sync.Mutex
+	db      string
+	seq     int
+	docs    map[string]map[string]any // 当前文档（含 _id/_rev）
+	seqs    map[string]int            // 文档最后一次变更的序列号
+	deleted map[string]bool
+	pushed  []map[string]any // 通过 _bulk_docs 收到的文档
+}
+
+func newFakeCouchDB(db string) *fakeCouchDB {
+	return &fakeCouchDB{
+		db:      db,
+		docs:    make(map[string]map[string]any),
+		seqs:    make(map[string]int),
+		deleted: make(map[string]bool),
+	}
+}
+
+// put 写入文档并生成新的修订号，rev 不匹配时返回冲突。
+func (f *fakeCouchDB) put(doc map[string]any) (string, bool) {
+	id, _ := doc["_id"].(string)
+	current, exists := f.docs[id]
+	rev, _ := doc["_rev"].(string)
+	if exists && !f.deleted[id] && current["_rev"] != rev {
+		return "", false
+	}
+	gen := 1
+	if exists {
+		prev, _ := current["_rev"].(string)
+		gen, _ = strconv.Atoi(strings.SplitN(prev, "-", 2)[0])
+		gen++
+	}
+	newRev := fmt.Sprintf("%d-%s", gen, id)
+	stored := make(map[string]any, len(doc))
+	for k, v := range doc {
+		stored[k] = v
+	}
+	stored["_rev"] = newRev
+	f.seq++
+	f.docs[id] = stored
+	f.seqs[id] = f.seq
+	f.deleted[id] = doc["_deleted"] == true
+	return newRev, true
+}
+
+func (f *fakeCouchDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("feed") == "longpoll" {
+		// 简化的长轮询：稍作等待再返回，避免客户端空转
+		time.Sleep(20 * time.Millisecond)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	prefix := "/" + f.db + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, prefix)
+
+	switch {
+	case path == "_changes" && r.Method == http.MethodGet:
+		since, _ := strconv.Atoi(strings.TrimSuffix(r.URL.Query().Get("since"), "-fake"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		ids := make([]string, 0, len(f.seqs))
+		for id, seq := range f.seqs {
+			if seq > since {
+				ids = append(ids, id)
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool { return f.seqs[ids[i]] < f.seqs[ids[j]] })
+		pending := 0
+		if limit > 0 && len(ids) > limit {
+			pending = len(ids) - limit
+			ids = ids[:limit]
+		}
+		results := make([]map[string]any, 0, len(ids))
+		lastSeq := since
+		for _, id := range ids {
+			doc := f.docs[id]
+			result := map[string]any{
+				"seq":     fmt.Sprintf("%d-fake", f.seqs[id]),
+				"id":      id,
+				"changes": []map[string]any{{"rev": doc["_rev"]}},
+				"doc":     doc,
+			}
+			if f.deleted[id] {
+				result["deleted"] = true
+			}
+			results = append(results, result)
+			lastSeq = f.seqs[id]
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"results":  results,
+			"last_seq": fmt.Sprintf("%d-fake", lastSeq),
+			"pending":  pending,
+		})
+	case path == "_all_docs" && r.Method == http.MethodGet:
+		var startKey string
+		if raw := r.URL.Query().Get("startkey"); raw != "" {
+			_ = json.Unmarshal([]byte(raw), &startKey)
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		ids := make([]string, 0, len(f.docs))
+		for id := range f.docs {
+			if !f.deleted[id] && id >= startKey {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		if limit > 0 && len(ids) > limit {
+			ids = ids[:limit]
+		}
+		rows := make([]map[string]any, 0, len(ids))
+		for _, id := range ids {
+			rows = append(rows, map[string]any{"id": id, "key": id, "value": map[string]any{"rev": f.docs[id]["_rev"]}})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"total_rows": len(rows), "rows": rows})
+	case path == "_bulk_docs" && r.Method == http.MethodPost:
+		var body struct {
+			Docs []map[string]any `json:"docs"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		results := make([]map[string]any, 0, len(body.Docs))
+		for _, doc := range body.Docs {
+			f.pushed = append(f.pushed, doc)
+			if rev, ok := f.put(doc); ok {
+				results = append(results, map[string]any{"ok": true, "id": doc["_id"], "rev": rev})
+			} else {
+				results = append(results, map[string]any{"id": doc["_id"], "error": "conflict", "reason": "Document update conflict."})
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(results)
+	case r.Method == http.MethodGet:
+		doc, ok := f.docs[path]
+		if !ok || f.deleted[path] {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]any{"error": "not_found", "reason": "missing"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(doc)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeCouchDB) pushedDocs() []map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]map[string]any(nil), f.pushed...)
+}
+
+func TestReplication_PullOnce(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewTestDatabase(t)
+	coll := testutil.NewTestCollection(t, db, "items", rxdb.Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	if _, err := coll.Insert(ctx, map[string]any{"id": "3", "name": "local three"}); err != nil {
+		t.Fatalf("failed to insert document: %v", err)
+	}
+
+	couch := newFakeCouchDB("items")
+	couch.put(map[string]any{"_id": "1", "name": "one"})
+	couch.put(map[string]any{"_id": "2", "name": "two"})
+	couch.put(map[string]any{"_id": "3", "name": "remote three"})
+	couch.put(map[string]any{"_id": "_design/app", "views": map[string]any{}})
+	couch.put(map[string]any{"_id": "4", "name": "four"})
+	couch.put(map[string]any{"_id": "4", "_rev": "1-4", "_deleted": true})
+	server := httptest.NewServer(couch)
+	defer server.Close()
+
+	repl, err := NewReplication(coll, ReplicationOptions{
+		URL:       server.URL,
+		Database:  "items",
+		BatchSize: 2, // 强制分页
+	})
+	if err != nil {
+		t.Fatalf("failed to create replication: %v", err)
+	}
+
+	changes := coll.Changes()
+	if err := repl.PullOnce(ctx); err != nil {
+		t.Fatalf("failed to pull: %v", err)
+	}
+	select {
+	case err := <-repl.Errors():
+		t.Fatalf("unexpected replication error: %v", err)
+	default:
+	}
+
+	for id, name := range map[string]string{"1": "one", "2": "two", "3": "remote three"} {
+		doc, err := coll.FindByID(ctx, id)
+		if err != nil {
+			t.Fatalf("failed to find document %s: %v", id, err)
+		}
+		if doc.GetString("name") != name {
+			t.Errorf("expected name %q for document %s, got %q", name, id, doc.GetString("name"))
+		}
+		// 本地修订号由 rxdb 维护，不应是 CouchDB 的修订号
+		if rev := doc.GetString("_rev"); strings.HasSuffix(rev, "-"+id) {
+			t.Errorf("expected local revision for document %s, got couchdb revision %q", id, rev)
+		}
+		if _, ok := doc.Data()["_id"]; ok {
+			t.Errorf("expected _id to be stripped from document %s", id)
+		}
+	}
+	if doc, err := coll.FindByID(ctx, "4"); err == nil && doc != nil {
+		t.Errorf("expected deleted document 4 not to exist locally")
+	}
+	if doc, err := coll.FindByID(ctx, "_design/app"); err == nil && doc != nil {
+		t.Errorf("expected design document to be skipped")
+	}
+	if got := repl.LastSeq(); got != "6-fake" {
+		t.Errorf("expected last seq 6-fake, got %q", got)
+	}
+
+	// 3 条写入事件，均标记为远程来源
+	for i := 0; i < 3; i++ {
+		select {
+		case event := <-changes:
+			if event.Source != rxdb.ChangeSourceRemote {
+				t.Errorf("expected remote change event, got %+v", event)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for change %d", i+1)
+		}
+	}
+
+	// 再次拉取无新变更
+	if err := repl.PullOnce(ctx); err != nil {
+		t.Fatalf("failed to pull: %v", err)
+	}
+	select {
+	case event := <-changes:
+		t.Errorf("unexpected change event: %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestReplication_PushUsesCouchRevisions(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewTestDatabase(t)
+	coll := testutil.NewTestCollection(t, db, "items", rxdb.Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	couch := newFakeCouchDB("items")
+	couch.put(map[string]any{"_id": "1", "name": "one"})
+	server := httptest.NewServer(couch)
+	defer server.Close()
+
+	repl, err := NewReplication(coll, ReplicationOptions{URL: server.URL, Database: "items"})
+	if err != nil {
+		t.Fatalf("failed to create replication: %v", err)
+	}
+	if err := repl.PullOnce(ctx); err != nil {
+		t.Fatalf("failed to pull: %v", err)
+	}
+
+	if err := repl.Start(ctx); err != nil {
+		t.Fatalf("failed to start replication: %v", err)
+	}
+	defer repl.Stop()
+
+	if _, err := coll.Upsert(ctx, map[string]any{"id": "1", "name": "one updated"}); err != nil {
+		t.Fatalf("failed to update document: %v", err)
+	}
+	if _, err := coll.Insert(ctx, map[string]any{"id": "2", "name": "two"}); err != nil {
+		t.Fatalf("failed to insert document: %v", err)
+	}
+
+	waitFor(t, func() bool {
+		couch.mu.Lock()
+		defer couch.mu.Unlock()
+		return couch.docs["1"]["name"] == "one updated" && couch.docs["2"] != nil
+	})
+
+	for _, doc := range couch.pushedDocs() {
+		switch doc["_id"] {
+		case "1":
+			// 已拉取的文档携带 CouchDB 修订号
+			if doc["_rev"] != "1-1" {
+				t.Errorf("expected couchdb revision 1-1 for document 1, got %v", doc["_rev"])
+			}
+		case "2":
+			if _, ok := doc["_rev"]; ok {
+				t.Errorf("expected new document 2 without revision, got %v", doc["_rev"])
+			}
+		}
+		if _, ok := doc["id"]; ok {
+			t.Errorf("expected primary key to be mapped to _id, got %v", doc)
+		}
+	}
+
+	// 删除携带最新修订号
+	if err := coll.Remove(ctx, "2"); err != nil {
+		t.Fatalf("failed to remove document: %v", err)
+	}
+	waitFor(t, func() bool {
+		couch.mu.Lock()
+		defer couch.mu.Unlock()
+		return couch.deleted["2"]
+	})
+	pushed := couch.pushedDocs()
+	last := pushed[len(pushed)-1]
+	if last["_deleted"] != true || last["_rev"] != "1-2" {
+		t.Errorf("expected delete of document 2 at revision 1-2, got %v", last)
+	}
+
+	select {
+	case err := <-repl.Errors():
+		t.Fatalf("unexpected replication error: %v", err)
+	default:
+	}
+}
+
+func TestReplication_PushTruncate(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewTestDatabase(t)
+	coll := testutil.NewTestCollection(t, db, "items", rxdb.Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	couch := newFakeCouchDB("items")
+	for i := 0; i < 5; i++ {
+		couch.put(map[string]any{"_id": fmt.Sprintf("remote-%d", i), "name": "remote"})
+	}
+	couch.put(map[string]any{"_id": "_design/app", "views": map[string]any{}})
+	server := httptest.NewServer(couch)
+	defer server.Close()
+
+	repl, err := NewReplication(coll, ReplicationOptions{URL: server.URL, Database: "items", BatchSize: 2})
+	if err != nil {
+		t.Fatalf("failed to create replication: %v", err)
+	}
+	if err := repl.PullOnce(ctx); err != nil {
+		t.Fatalf("failed to pull: %v", err)
+	}
+	if err := repl.Start(ctx); err != nil {
+		t.Fatalf("failed to start replication: %v", err)
+	}
+	defer repl.Stop()
+
+	if err := coll.Truncate(ctx); err != nil {
+		t.Fatalf("failed to truncate: %v", err)
+	}
+	if _, err := coll.Insert(ctx, map[string]any{"id": "fresh", "name": "after truncate"}); err != nil {
+		t.Fatalf("failed to insert document: %v", err)
+	}
+
+	// 远程文档全部删除（设计文档保留），Truncate 之后的写入照常推送
+	waitFor(t, func() bool {
+		couch.mu.Lock()
+		defer couch.mu.Unlock()
+		for i := 0; i < 5; i++ {
+			if !couch.deleted[fmt.Sprintf("remote-%d", i)] {
+				return false
+			}
+		}
+		return couch.docs["fresh"] != nil && !couch.deleted["fresh"]
+	})
+	couch.mu.Lock()
+	designDeleted := couch.deleted["_design/app"]
+	couch.mu.Unlock()
+	if designDeleted {
+		t.Error("design document should not be deleted")
+	}
+	for _, doc := range couch.pushedDocs() {
+		if doc["_id"] == "" {
+			t.Errorf("truncate should not be pushed as a document: %v", doc)
+		}
+	}
+
+	select {
+	case err := <-repl.Errors():
+		t.Fatalf("unexpected replication error: %v", err)
+	default:
+	}
+}
+
+func TestReplication_PushConflictRemoteWins(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewTestDatabase(t)
+	coll := testutil.NewTestCollection(t, db, "items", rxdb.Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	couch := newFakeCouchDB("items")
+	couch.put(map[string]any{"_id": "1", "name": "remote"})
+	server := httptest.NewServer(couch)
+	defer server.Close()
+
+	repl, err := NewReplication(coll, ReplicationOptions{URL: server.URL, Database: "items"})
+	if err != nil {
+		t.Fatalf("failed to create replication: %v", err)
+	}
+
+	// 本地不知道远程修订号，推送会产生冲突
+	if _, err := coll.Insert(ctx, map[string]any{"id": "1", "name": "local"}); err != nil {
+		t.Fatalf("failed to insert document: %v", err)
+	}
+	if err := repl.PushOnce(ctx); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+
+	doc, err := coll.FindByID(ctx, "1")
+	if err != nil {
+		t.Fatalf("failed to find document: %v", err)
+	}
+	if doc.GetString("name") != "remote" {
+		t.Errorf("expected remote version to win, got %q", doc.GetString("name"))
+	}
+	couch.mu.Lock()
+	remoteRev := couch.docs["1"]["_rev"]
+	couch.mu.Unlock()
+	if remoteRev != "1-1" {
+		t.Errorf("expected remote document to be unchanged, got revision %v", remoteRev)
+	}
+}
+
+func TestReplication_CouchDBIntegration(t *testing.T) {
+	couchURL := os.Getenv("COUCHDB_URL")
+	if couchURL == "" {
+		t.Skip("COUCHDB_URL not set, skipping integration test")
+	}
+
+	ctx := context.Background()
+	db := testutil.NewTestDatabase(t)
+	coll := testutil.NewTestCollection(t, db, "items", rxdb.Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	dbName := fmt.Sprintf("rxdb_go_test_%d", time.Now().UnixNano())
+	repl, err := NewReplication(coll, ReplicationOptions{
+		URL:               couchURL,
+		Database:          dbName,
+		Username:          os.Getenv("COUCHDB_USER"),
+		Password:          os.Getenv("COUCHDB_PASSWORD"),
+		HeartbeatInterval: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to create replication: %v", err)
+	}
+
+	createReq, _ := http.NewRequest(http.MethodPut, repl.opts.URL+"/"+dbName, nil)
+	repl.setHeaders(createReq)
+	resp, err := http.DefaultClient.Do(createReq)
+	if err != nil {
+		t.Fatalf("failed to create couchdb database: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("failed to create couchdb database: %s", resp.Status)
+	}
+	defer func() {
+		req, _ := http.NewRequest(http.MethodDelete, repl.opts.URL+"/"+dbName, nil)
+		repl.setHeaders(req)
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	if _, err := coll.Insert(ctx, map[string]any{"id": "local-1", "name": "local"}); err != nil {
+		t.Fatalf("failed to insert document: %v", err)
+	}
+	if err := repl.PushOnce(ctx); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+	remote, err := repl.fetchDoc(ctx, "local-1")
+	if err != nil || remote == nil {
+		t.Fatalf("expected pushed document in couchdb, got %v (err: %v)", remote, err)
+	}
+
+	if err := repl.Start(ctx); err != nil {
+		t.Fatalf("failed to start replication: %v", err)
+	}
+	defer repl.Stop()
+
+	if _, err := repl.bulkDocs(ctx, []map[string]any{{"_id": "remote-1", "name": "remote"}}); err != nil {
+		t.Fatalf("failed to write remote document: %v", err)
+	}
+	waitFor(t, func() bool {
+		doc, err := coll.FindByID(ctx, "remote-1")
+		return err == nil && doc != nil && doc.GetString("name") == "remote"
+	})
+}
+
+// waitFor 轮询直到条件成立或超时。
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
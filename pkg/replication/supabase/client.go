@@ -39,14 +39,25 @@ type ReplicationOptions struct {
 	PrimaryKey string
 	// UpdatedAtField 更新时间字段名（用于增量同步）
 	UpdatedAtField string
+	// DeletedField 软删除标记字段名，拉取到该字段为 true 的远程文档时删除本地文档（默认 "_deleted"）
+	DeletedField string
 	// PullInterval 拉取间隔
 	PullInterval time.Duration
 	// PushOnChange 是否在本地变更时立即推送
 	PushOnChange bool
-	// ConflictHandler 冲突处理函数
+	// ConflictHandler 冲突处理函数。本地文档通过 _conflictResolution 字段指定策略时优先使用该策略，
+	// 策略为 "custom" 或未设置时使用此函数
 	ConflictHandler ConflictHandler
 	// HTTPClient 自定义 HTTP 客户端
 	HTTPClient *http.Client
+	// UseRealtime 是否通过 Supabase Realtime（{SupabaseURL}/realtime/v1/websocket）订阅表的
+	// postgres_changes 事件，远程变更到达后立即应用到本地。HTTP 拉取仍按 PullInterval 执行，
+	// 用于初始同步，并在 Realtime 断线重连后补拉遗漏的变更
+	UseRealtime bool
+	// RealtimeSchema Realtime 订阅的数据库 schema，默认 public
+	RealtimeSchema string
+	// RealtimeReconnectInterval Realtime 断线后首次重连的等待时间，之后按指数退避，默认 1s
+	RealtimeReconnectInterval time.Duration
 }
 
 // Replication 同步客户端。
@@ -59,6 +70,10 @@ type Replication struct {
 	stopChan   chan struct{}
 	errChan    chan error
 	httpClient *http.Client
+	realtime   *RealtimeSubscription // UseRealtime 为 false 时为 nil
+
+	// 串行化定时拉取与 Realtime 重连后的补拉
+	pullMu sync.Mutex
 }
 
 // NewReplication 创建新的同步实例。
@@ -78,6 +93,9 @@ func NewReplication(collection rxdb.Collection, opts ReplicationOptions) (*Repli
 	if opts.UpdatedAtField == "" {
 		opts.UpdatedAtField = "updated_at"
 	}
+	if opts.DeletedField == "" {
+		opts.DeletedField = "_deleted"
+	}
 	if opts.PullInterval == 0 {
 		opts.PullInterval = 10 * time.Second
 	}
@@ -119,6 +137,13 @@ func (r *Replication) Start(ctx context.Context) error {
 	// 启动拉取循环
 	go r.pullLoop(ctx)
 
+	if r.opts.UseRealtime {
+		if err := r.startRealtime(ctx); err != nil {
+			r.Stop()
+			return err
+		}
+	}
+
 	// 如果配置了推送，监听本地变更
 	if r.opts.PushOnChange {
 		go r.pushLoop(ctx)
@@ -181,6 +206,10 @@ func (r *Replication) Stop() {
 	}
 	r.state = StateStopped
 	close(r.stopChan)
+	if r.realtime != nil {
+		r.realtime.Stop()
+		r.realtime = nil
+	}
 }
 
 // State 返回当前同步状态。
@@ -217,6 +246,9 @@ func (r *Replication) pullLoop(ctx context.Context) {
 
 // pull 从 Supabase 拉取数据。
 func (r *Replication) pull(ctx context.Context) {
+	r.pullMu.Lock()
+	defer r.pullMu.Unlock()
+
 	r.mu.Lock()
 	r.state = StatePulling
 	r.mu.Unlock()
@@ -275,12 +307,14 @@ func (r *Replication) pull(ctx context.Context) {
 }
 
 // processRemoteDoc 处理远程文档。
+// 写入本地集合时使用 rxdb.ChangeSourceRemote 标记来源，产生的变更事件 Source 为 "remote"。
 func (r *Replication) processRemoteDoc(ctx context.Context, remoteDoc map[string]any) error {
 	id, ok := remoteDoc[r.opts.PrimaryKey]
 	if !ok {
 		return fmt.Errorf("remote document missing primary key")
 	}
 	idStr := fmt.Sprintf("%v", id)
+	ctx = rxdb.WithChangeSource(ctx, rxdb.ChangeSourceRemote)
 
 	// 查找本地文档
 	localDoc, err := r.collection.FindByID(ctx, idStr)
@@ -288,6 +322,14 @@ func (r *Replication) processRemoteDoc(ctx context.Context, remoteDoc map[string
 		return fmt.Errorf("failed to find local document: %w", err)
 	}
 
+	// 远程已删除：删除本地文档（本地不存在时忽略）
+	if deleted, _ := remoteDoc[r.opts.DeletedField].(bool); deleted {
+		if localDoc == nil {
+			return nil
+		}
+		return r.collection.Remove(ctx, idStr)
+	}
+
 	if localDoc == nil {
 		// 本地不存在，直接插入
 		_, err := r.collection.Insert(ctx, remoteDoc)
@@ -295,8 +337,7 @@ func (r *Replication) processRemoteDoc(ctx context.Context, remoteDoc map[string
 	}
 
 	// 本地存在，检查冲突
-	localData := localDoc.Data()
-	resolved := r.opts.ConflictHandler(localData, remoteDoc)
+	resolved := r.resolveConflict(localDoc.Data(), remoteDoc)
 	if resolved != nil {
 		_, err := r.collection.Upsert(ctx, resolved)
 		return err
@@ -305,6 +346,62 @@ func (r *Replication) processRemoteDoc(ctx context.Context, remoteDoc map[string
 	return nil
 }
 
+// resolveConflict 按本地文档 _conflictResolution 字段指定的策略解决冲突，返回 nil 表示保留本地版本。
+func (r *Replication) resolveConflict(local, remote map[string]any) map[string]any {
+	strategy, _ := local[rxdb.ConflictResolutionField].(string)
+
+	var resolved map[string]any
+	switch strategy {
+	case rxdb.ConflictServerWins:
+		resolved = remote
+	case rxdb.ConflictClientWins:
+		return nil
+	case rxdb.ConflictLastWriteWins:
+		localTime, localOK := parseUpdatedAt(local[r.opts.UpdatedAtField])
+		remoteTime, remoteOK := parseUpdatedAt(remote[r.opts.UpdatedAtField])
+		if localOK && (!remoteOK || !remoteTime.After(localTime)) {
+			return nil
+		}
+		resolved = remote
+	default:
+		resolved = r.opts.ConflictHandler(local, remote)
+	}
+
+	// 远程文档不包含本地策略字段时保留，避免覆盖后丢失策略
+	if resolved != nil && strategy != "" {
+		if _, ok := resolved[rxdb.ConflictResolutionField]; !ok {
+			withStrategy := make(map[string]any, len(resolved)+1)
+			for k, v := range resolved {
+				withStrategy[k] = v
+			}
+			withStrategy[rxdb.ConflictResolutionField] = strategy
+			resolved = withStrategy
+		}
+	}
+	return resolved
+}
+
+// parseUpdatedAt 解析更新时间字段，支持 RFC3339 字符串和 Unix 毫秒时间戳。
+func parseUpdatedAt(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return parsed, true
+	case float64:
+		return time.UnixMilli(int64(t)), true
+	case int64:
+		return time.UnixMilli(t), true
+	case int:
+		return time.UnixMilli(int64(t)), true
+	case time.Time:
+		return t, true
+	}
+	return time.Time{}, false
+}
+
 // pushLoop 监听本地变更并推送。
 func (r *Replication) pushLoop(ctx context.Context) {
 	changes := r.collection.Changes()
@@ -318,6 +415,10 @@ func (r *Replication) pushLoop(ctx context.Context) {
 			if !ok {
 				return
 			}
+			// 从远端拉取的变更无需再推送回去
+			if event.Source == rxdb.ChangeSourceRemote {
+				continue
+			}
 			r.push(ctx, event)
 		}
 	}
@@ -345,6 +446,8 @@ func (r *Replication) push(ctx context.Context, event rxdb.ChangeEvent) {
 		err = r.pushUpdate(ctx, event.ID, event.Doc)
 	case rxdb.OperationDelete:
 		err = r.pushDelete(ctx, event.ID)
+	case rxdb.OperationTruncate:
+		err = r.pushTruncate(ctx)
 	}
 
 	if err != nil {
@@ -416,7 +519,17 @@ func (r *Replication) pushUpdate(ctx context.Context, id string, doc map[string]
 
 // pushDelete 推送删除操作。
 func (r *Replication) pushDelete(ctx context.Context, id string) error {
-	url := fmt.Sprintf("%s/rest/v1/%s?%s=eq.%s", r.opts.SupabaseURL, r.opts.Table, r.opts.PrimaryKey, id)
+	return r.deleteWhere(ctx, fmt.Sprintf("%s=eq.%s", r.opts.PrimaryKey, id))
+}
+
+// pushTruncate 将本地的 Truncate 同步到 Supabase：删除表中所有主键非空的行（PostgREST 的 DELETE 需要过滤条件）。
+func (r *Replication) pushTruncate(ctx context.Context) error {
+	return r.deleteWhere(ctx, fmt.Sprintf("%s=not.is.null", r.opts.PrimaryKey))
+}
+
+// deleteWhere 删除表中匹配 PostgREST 过滤条件 filter 的行。
+func (r *Replication) deleteWhere(ctx context.Context, filter string) error {
+	url := fmt.Sprintf("%s/rest/v1/%s?%s", r.opts.SupabaseURL, r.opts.Table, filter)
 
 	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
 	if err != nil {
@@ -483,3 +596,54 @@ func (r *Replication) PushOnce(ctx context.Context) error {
 
 	return nil
 }
+
+// startRealtime 订阅 Realtime 变更，远程变更与 HTTP 拉取一样经过冲突处理后写入本地。
+func (r *Replication) startRealtime(ctx context.Context) error {
+	rs, err := NewRealtimeSubscription(r.collection, RealtimeOptions{
+		SupabaseURL:       r.opts.SupabaseURL,
+		SupabaseKey:       r.opts.SupabaseKey,
+		Table:             r.opts.Table,
+		Schema:            r.opts.RealtimeSchema,
+		PrimaryKey:        r.opts.PrimaryKey,
+		ReconnectInterval: r.opts.RealtimeReconnectInterval,
+	})
+	if err != nil {
+		return err
+	}
+	rs.onChange = r.applyRealtimeChange
+	rs.onReconnect = r.pull
+	// 连接错误转发到 Replication 的错误通道
+	go func() {
+		for {
+			select {
+			case <-rs.stopChan:
+				return
+			case err := <-rs.Errors():
+				r.sendError(fmt.Errorf("realtime: %w", err))
+			}
+		}
+	}()
+
+	r.mu.Lock()
+	r.realtime = rs
+	r.mu.Unlock()
+	return rs.Start(ctx)
+}
+
+// applyRealtimeChange 应用一条 Realtime 推送的远程变更。
+func (r *Replication) applyRealtimeChange(ctx context.Context, change RealtimePayload) error {
+	switch change.EventType {
+	case RealtimeInsert, RealtimeUpdate:
+		return r.processRemoteDoc(ctx, change.New)
+	case RealtimeDelete:
+		id, ok := change.Old[r.opts.PrimaryKey]
+		if !ok {
+			return fmt.Errorf("delete event missing primary key")
+		}
+		err := r.collection.Remove(rxdb.WithChangeSource(ctx, rxdb.ChangeSourceRemote), fmt.Sprintf("%v", id))
+		if err != nil && !rxdb.IsNotFoundError(err) {
+			return err
+		}
+	}
+	return nil
+}
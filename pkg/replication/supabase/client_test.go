@@ -0,0 +1,277 @@
+package supabase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mozhou-tech/rxdb-go/pkg/rxdb"
+	"github.com/mozhou-tech/rxdb-go/pkg/rxdb/testutil"
+)
+
+func TestReplication_PullOnceEmitsRemoteChanges(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewTestDatabase(t)
+	coll := testutil.NewTestCollection(t, db, "items", rxdb.Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	// 本地已有的文档：3 将被远程更新，4 将被远程删除
+	for _, doc := range []map[string]any{
+		{"id": "3", "name": "local three"},
+		{"id": "4", "name": "local four"},
+	} {
+		if _, err := coll.Insert(ctx, doc); err != nil {
+			t.Fatalf("failed to insert document: %v", err)
+		}
+	}
+
+	remoteDocs := []map[string]any{
+		{"id": "1", "name": "one"},
+		{"id": "2", "name": "two"},
+		{"id": "3", "name": "remote three"},
+		{"id": "4", "name": "four", "_deleted": true},
+		{"id": "5", "name": "five"},
+		// 本地不存在的已删除文档不产生事件
+		{"id": "6", "name": "six", "_deleted": true},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/v1/items" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(remoteDocs)
+	}))
+	defer server.Close()
+
+	repl, err := NewReplication(coll, ReplicationOptions{
+		SupabaseURL: server.URL,
+		SupabaseKey: "test-key",
+		Table:       "items",
+	})
+	if err != nil {
+		t.Fatalf("failed to create replication: %v", err)
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events := coll.Watch(watchCtx, nil)
+	changes := coll.Changes()
+
+	if err := repl.PullOnce(ctx); err != nil {
+		t.Fatalf("failed to pull: %v", err)
+	}
+	select {
+	case err := <-repl.Errors():
+		t.Fatalf("unexpected replication error: %v", err)
+	default:
+	}
+
+	want := map[string]rxdb.Operation{
+		"1": rxdb.OperationInsert,
+		"2": rxdb.OperationInsert,
+		"3": rxdb.OperationUpdate,
+		"4": rxdb.OperationDelete,
+		"5": rxdb.OperationInsert,
+	}
+	got := make(map[string]rxdb.Operation)
+	for len(got) < len(want) {
+		select {
+		case event := <-events:
+			if _, dup := got[event.ID]; dup {
+				t.Fatalf("duplicate event for document %s", event.ID)
+			}
+			got[event.ID] = event.Op
+			if event.Source != rxdb.ChangeSourceRemote {
+				t.Errorf("expected source %q for document %s, got %q", rxdb.ChangeSourceRemote, event.ID, event.Source)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for watch events, got %v", got)
+		}
+	}
+	for id, op := range want {
+		if got[id] != op {
+			t.Errorf("expected %s event for document %s, got %s", op, id, got[id])
+		}
+	}
+	select {
+	case event := <-events:
+		t.Errorf("unexpected extra watch event: %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Changes() 收到相同的远程事件
+	for i := 0; i < len(want); i++ {
+		select {
+		case event := <-changes:
+			if event.Source != rxdb.ChangeSourceRemote {
+				t.Errorf("expected remote change event, got %+v", event)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for change %d", i+1)
+		}
+	}
+
+	// 本地写入的事件不带来源标记
+	if _, err := coll.Insert(ctx, map[string]any{"id": "7", "name": "local"}); err != nil {
+		t.Fatalf("failed to insert document: %v", err)
+	}
+	select {
+	case event := <-events:
+		if event.ID != "7" || event.Source != "" {
+			t.Errorf("expected local event for document 7 without source, got %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for local event")
+	}
+
+	if doc, err := coll.FindByID(ctx, "4"); err == nil && doc != nil {
+		t.Errorf("expected document 4 to be deleted locally")
+	}
+}
+
+func TestReplication_PerDocumentConflictResolution(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewTestDatabase(t)
+	coll := testutil.NewTestCollection(t, db, "items", rxdb.Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	local := []struct {
+		doc      map[string]any
+		strategy string
+	}{
+		{map[string]any{"id": "server", "name": "local", "updated_at": "2024-01-01T00:00:00Z"}, rxdb.ConflictServerWins},
+		{map[string]any{"id": "client", "name": "local", "updated_at": "2024-01-01T00:00:00Z"}, rxdb.ConflictClientWins},
+		{map[string]any{"id": "lww-local", "name": "local", "updated_at": "2024-06-01T00:00:00Z"}, rxdb.ConflictLastWriteWins},
+		{map[string]any{"id": "lww-remote", "name": "local", "updated_at": "2024-01-01T00:00:00Z"}, rxdb.ConflictLastWriteWins},
+		{map[string]any{"id": "custom", "name": "local", "updated_at": "2024-01-01T00:00:00Z"}, rxdb.ConflictCustom},
+		{map[string]any{"id": "default", "name": "local", "updated_at": "2024-01-01T00:00:00Z"}, ""},
+	}
+	for _, l := range local {
+		if _, err := coll.InsertWithOptions(ctx, l.doc, rxdb.DocumentOptions{ConflictResolution: l.strategy}); err != nil {
+			t.Fatalf("failed to insert document: %v", err)
+		}
+	}
+	if _, err := coll.InsertWithOptions(ctx, map[string]any{"id": "bad"}, rxdb.DocumentOptions{ConflictResolution: "first-wins"}); err == nil {
+		t.Error("expected error for unknown conflict resolution strategy")
+	}
+
+	// 所有文档在同一次拉取中与远程版本冲突
+	remoteDocs := []map[string]any{
+		{"id": "server", "name": "remote", "updated_at": "2024-03-01T00:00:00Z"},
+		{"id": "client", "name": "remote", "updated_at": "2024-03-01T00:00:00Z"},
+		{"id": "lww-local", "name": "remote", "updated_at": "2024-03-01T00:00:00Z"},
+		{"id": "lww-remote", "name": "remote", "updated_at": "2024-03-01T00:00:00Z"},
+		{"id": "custom", "name": "remote", "updated_at": "2024-03-01T00:00:00Z"},
+		{"id": "default", "name": "remote", "updated_at": "2024-03-01T00:00:00Z"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(remoteDocs)
+	}))
+	defer server.Close()
+
+	repl, err := NewReplication(coll, ReplicationOptions{
+		SupabaseURL: server.URL,
+		SupabaseKey: "test-key",
+		Table:       "items",
+		ConflictHandler: func(local, remote map[string]any) map[string]any {
+			merged := make(map[string]any, len(remote))
+			for k, v := range remote {
+				merged[k] = v
+			}
+			merged["name"] = fmt.Sprintf("%v+%v", local["name"], remote["name"])
+			return merged
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create replication: %v", err)
+	}
+	if err := repl.PullOnce(ctx); err != nil {
+		t.Fatalf("failed to pull: %v", err)
+	}
+	select {
+	case err := <-repl.Errors():
+		t.Fatalf("unexpected replication error: %v", err)
+	default:
+	}
+
+	want := map[string]string{
+		"server":     "remote",
+		"client":     "local",
+		"lww-local":  "local",
+		"lww-remote": "remote",
+		"custom":     "local+remote",
+		"default":    "local+remote",
+	}
+	for id, name := range want {
+		doc, err := coll.FindByID(ctx, id)
+		if err != nil {
+			t.Fatalf("failed to find document %s: %v", id, err)
+		}
+		if got := doc.GetString("name"); got != name {
+			t.Errorf("document %s: expected name %q, got %q", id, name, got)
+		}
+	}
+
+	// 远程版本覆盖后仍保留文档自身的策略
+	doc, err := coll.FindByID(ctx, "server")
+	if err != nil {
+		t.Fatalf("failed to find document: %v", err)
+	}
+	if got := doc.GetString(rxdb.ConflictResolutionField); got != rxdb.ConflictServerWins {
+		t.Errorf("expected strategy %q to be preserved, got %q", rxdb.ConflictServerWins, got)
+	}
+}
+
+func TestReplication_PushTruncate(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewTestDatabase(t)
+	coll := testutil.NewTestCollection(t, db, "items", rxdb.Schema{PrimaryKey: "id", RevField: "_rev"})
+	if _, err := coll.Insert(ctx, map[string]any{"id": "1", "name": "one"}); err != nil {
+		t.Fatalf("failed to insert document: %v", err)
+	}
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	repl, err := NewReplication(coll, ReplicationOptions{
+		SupabaseURL: server.URL,
+		SupabaseKey: "test-key",
+		Table:       "items",
+	})
+	if err != nil {
+		t.Fatalf("failed to create replication: %v", err)
+	}
+
+	changes := coll.Changes()
+	if err := coll.Truncate(ctx); err != nil {
+		t.Fatalf("failed to truncate: %v", err)
+	}
+	var event rxdb.ChangeEvent
+	select {
+	case event = <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for truncate event")
+	}
+	if event.Op != rxdb.OperationTruncate {
+		t.Fatalf("expected truncate event, got %+v", event)
+	}
+
+	repl.push(ctx, event)
+	select {
+	case err := <-repl.Errors():
+		t.Fatalf("unexpected replication error: %v", err)
+	default:
+	}
+	want := "DELETE /rest/v1/items?id=not.is.null"
+	if len(requests) != 1 || requests[0] != want {
+		t.Errorf("expected %q, got %v", want, requests)
+	}
+}
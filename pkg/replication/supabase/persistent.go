@@ -331,6 +331,8 @@ func (pr *PersistentReplication) processQueue(ctx context.Context) {
 			pushErr = pr.pushUpdateItem(ctx, item.DocID, item.Doc)
 		case rxdb.OperationDelete:
 			pushErr = pr.pushDeleteItem(ctx, item.DocID)
+		case rxdb.OperationTruncate:
+			pushErr = pr.pushTruncate(ctx)
 		}
 
 		if pushErr != nil {
@@ -373,6 +375,8 @@ func (pr *PersistentReplication) push(ctx context.Context, event rxdb.ChangeEven
 		err = pr.pushUpdateItem(ctx, event.ID, event.Doc)
 	case rxdb.OperationDelete:
 		err = pr.pushDeleteItem(ctx, event.ID)
+	case rxdb.OperationTruncate:
+		err = pr.pushTruncate(ctx)
 	}
 
 	if err != nil {
@@ -405,6 +409,10 @@ func (pr *PersistentReplication) pushLoop(ctx context.Context) {
 			if !ok {
 				return
 			}
+			// 从远端拉取的变更无需再推送回去
+			if event.Source == rxdb.ChangeSourceRemote {
+				continue
+			}
 			pr.push(ctx, event)
 		}
 	}
@@ -53,8 +53,10 @@ type RealtimeOptions struct {
 	Schema string
 	// PrimaryKey 主键字段名
 	PrimaryKey string
-	// ReconnectInterval 重连间隔
+	// ReconnectInterval 首次重连的等待时间，连续失败时按指数退避加倍，默认 1s
 	ReconnectInterval time.Duration
+	// MaxReconnectInterval 重连等待时间的上限，默认 1 分钟
+	MaxReconnectInterval time.Duration
 	// HeartbeatInterval 心跳间隔
 	HeartbeatInterval time.Duration
 }
@@ -69,6 +71,11 @@ type RealtimeSubscription struct {
 	errChan    chan error
 	connected  bool
 	ref        int
+
+	// onChange 处理数据变更，为 nil 时直接写入集合（Replication 使用它接入冲突处理）
+	onChange func(ctx context.Context, change RealtimePayload) error
+	// onReconnect 断线重连并重新订阅后调用，用于补拉断线期间遗漏的变更
+	onReconnect func(ctx context.Context)
 }
 
 // NewRealtimeSubscription 创建 Realtime 订阅。
@@ -89,7 +96,13 @@ func NewRealtimeSubscription(collection rxdb.Collection, opts RealtimeOptions) (
 		opts.PrimaryKey = "id"
 	}
 	if opts.ReconnectInterval == 0 {
-		opts.ReconnectInterval = 5 * time.Second
+		opts.ReconnectInterval = time.Second
+	}
+	if opts.MaxReconnectInterval == 0 {
+		opts.MaxReconnectInterval = time.Minute
+	}
+	if opts.MaxReconnectInterval < opts.ReconnectInterval {
+		opts.MaxReconnectInterval = opts.ReconnectInterval
 	}
 	if opts.HeartbeatInterval == 0 {
 		opts.HeartbeatInterval = 30 * time.Second
@@ -140,8 +153,10 @@ func (rs *RealtimeSubscription) Errors() <-chan error {
 	return rs.errChan
 }
 
-// connectLoop 连接循环。
+// connectLoop 连接循环。连接断开后按指数退避重连，成功订阅后退避时间恢复为 ReconnectInterval。
 func (rs *RealtimeSubscription) connectLoop(ctx context.Context) {
+	delay := rs.opts.ReconnectInterval
+	subscribedBefore := false
 	for {
 		select {
 		case <-ctx.Done():
@@ -151,9 +166,19 @@ func (rs *RealtimeSubscription) connectLoop(ctx context.Context) {
 		default:
 		}
 
-		if err := rs.connect(ctx); err != nil {
+		subscribed, err := rs.connect(ctx, subscribedBefore)
+		if err != nil {
+			select {
+			case <-rs.stopChan:
+				return
+			default:
+			}
 			rs.sendError(err)
 		}
+		if subscribed {
+			subscribedBefore = true
+			delay = rs.opts.ReconnectInterval
+		}
 
 		// 等待重连
 		select {
@@ -161,13 +186,17 @@ func (rs *RealtimeSubscription) connectLoop(ctx context.Context) {
 			return
 		case <-rs.stopChan:
 			return
-		case <-time.After(rs.opts.ReconnectInterval):
+		case <-time.After(delay):
+		}
+		if !subscribed {
+			delay = min(delay*2, rs.opts.MaxReconnectInterval)
 		}
 	}
 }
 
-// connect 建立 WebSocket 连接。
-func (rs *RealtimeSubscription) connect(ctx context.Context) error {
+// connect 建立 WebSocket 连接并订阅，直到连接断开。
+// 返回值 subscribed 表示是否成功发送了订阅请求；reconnect 为 true 时在订阅后调用 onReconnect。
+func (rs *RealtimeSubscription) connect(ctx context.Context, reconnect bool) (subscribed bool, err error) {
 	// 构建 WebSocket URL
 	wsURL := strings.Replace(rs.opts.SupabaseURL, "https://", "wss://", 1)
 	wsURL = strings.Replace(wsURL, "http://", "ws://", 1)
@@ -183,44 +212,78 @@ func (rs *RealtimeSubscription) connect(ctx context.Context) error {
 
 	conn, _, err := dialer.DialContext(ctx, wsURL, header)
 	if err != nil {
-		return fmt.Errorf("failed to connect to realtime: %w", err)
+		return false, fmt.Errorf("failed to connect to realtime: %w", err)
 	}
 
 	rs.mu.Lock()
+	select {
+	case <-rs.stopChan:
+		// Stop 在连接建立期间被调用
+		rs.mu.Unlock()
+		conn.Close()
+		return false, nil
+	default:
+	}
 	rs.conn = conn
 	rs.connected = true
 	rs.mu.Unlock()
 
 	// 订阅表变更
-	if err := rs.subscribe(); err != nil {
-		conn.Close()
-		return err
+	if err := rs.subscribe(conn); err != nil {
+		rs.disconnect(conn)
+		return false, err
+	}
+	if reconnect && rs.onReconnect != nil {
+		go rs.onReconnect(ctx)
 	}
 
 	// 启动心跳
-	go rs.heartbeatLoop(ctx)
+	go rs.heartbeatLoop(ctx, conn)
 
 	// 读取消息
-	return rs.readLoop(ctx)
+	return true, rs.readLoop(ctx)
 }
 
-// subscribe 订阅表变更。
-func (rs *RealtimeSubscription) subscribe() error {
-	rs.ref++
+// disconnect 关闭连接，conn 已被新连接替换时只关闭 conn 本身。
+func (rs *RealtimeSubscription) disconnect(conn *websocket.Conn) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.conn == conn {
+		rs.conn = nil
+		rs.connected = false
+	}
+	conn.Close()
+}
+
+// subscribe 订阅表变更（Realtime 的 postgres_changes 通道）。
+func (rs *RealtimeSubscription) subscribe(conn *websocket.Conn) error {
 	topic := fmt.Sprintf("realtime:%s:%s", rs.opts.Schema, rs.opts.Table)
+	return rs.send(conn, topic, "phx_join", map[string]any{
+		"config": map[string]any{
+			"postgres_changes": []any{
+				map[string]any{"event": "*", "schema": rs.opts.Schema, "table": rs.opts.Table},
+			},
+		},
+	})
+}
 
+// send 向连接写入一条消息。gorilla/websocket 不支持并发写，写入在 rs.mu 下串行进行。
+func (rs *RealtimeSubscription) send(conn *websocket.Conn, topic, event string, payload map[string]any) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.ref++
 	msg := map[string]any{
 		"topic":   topic,
-		"event":   "phx_join",
-		"payload": map[string]any{},
+		"event":   event,
+		"payload": payload,
 		"ref":     fmt.Sprintf("%d", rs.ref),
 	}
-
-	return rs.conn.WriteJSON(msg)
+	_ = conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return conn.WriteJSON(msg)
 }
 
-// heartbeatLoop 心跳循环。
-func (rs *RealtimeSubscription) heartbeatLoop(ctx context.Context) {
+// heartbeatLoop 心跳循环，conn 断开或被新连接替换后退出。
+func (rs *RealtimeSubscription) heartbeatLoop(ctx context.Context, conn *websocket.Conn) {
 	ticker := time.NewTicker(rs.opts.HeartbeatInterval)
 	defer ticker.Stop()
 
@@ -232,23 +295,14 @@ func (rs *RealtimeSubscription) heartbeatLoop(ctx context.Context) {
 			return
 		case <-ticker.C:
 			rs.mu.RLock()
-			conn := rs.conn
-			connected := rs.connected
+			current := rs.conn == conn && rs.connected
 			rs.mu.RUnlock()
 
-			if !connected || conn == nil {
+			if !current {
 				return
 			}
 
-			rs.ref++
-			msg := map[string]any{
-				"topic":   "phoenix",
-				"event":   "heartbeat",
-				"payload": map[string]any{},
-				"ref":     fmt.Sprintf("%d", rs.ref),
-			}
-
-			if err := conn.WriteJSON(msg); err != nil {
+			if err := rs.send(conn, "phoenix", "heartbeat", map[string]any{}); err != nil {
 				rs.sendError(fmt.Errorf("heartbeat failed: %w", err))
 				return
 			}
@@ -277,9 +331,7 @@ func (rs *RealtimeSubscription) readLoop(ctx context.Context) error {
 
 		var msg RealtimeMessage
 		if err := conn.ReadJSON(&msg); err != nil {
-			rs.mu.Lock()
-			rs.connected = false
-			rs.mu.Unlock()
+			rs.disconnect(conn)
 			return fmt.Errorf("read error: %w", err)
 		}
 
@@ -305,16 +357,60 @@ func (rs *RealtimeSubscription) handleMessage(ctx context.Context, msg RealtimeM
 	return nil
 }
 
-// handleChange 处理数据变更。
-func (rs *RealtimeSubscription) handleChange(ctx context.Context, payload json.RawMessage) error {
+// realtimeChangeData Realtime 服务端发送的 postgres_changes 负载（payload.data）。
+type realtimeChangeData struct {
+	Schema     string         `json:"schema"`
+	Table      string         `json:"table"`
+	CommitTime string         `json:"commit_timestamp"`
+	Type       RealtimeEvent  `json:"type"`
+	Record     map[string]any `json:"record"`
+	OldRecord  map[string]any `json:"old_record"`
+	Errors     []string       `json:"errors"`
+}
+
+// parseRealtimePayload 解析变更负载，同时支持服务端的 {"data": {type, record, old_record}} 格式
+// 与客户端库使用的 {eventType, new, old} 格式。
+func parseRealtimePayload(payload json.RawMessage) (RealtimePayload, error) {
+	var wrapper struct {
+		Data *realtimeChangeData `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &wrapper); err != nil {
+		return RealtimePayload{}, fmt.Errorf("failed to parse change payload: %w", err)
+	}
+	if wrapper.Data != nil {
+		d := wrapper.Data
+		return RealtimePayload{
+			Schema:     d.Schema,
+			Table:      d.Table,
+			CommitTime: d.CommitTime,
+			EventType:  d.Type,
+			New:        d.Record,
+			Old:        d.OldRecord,
+			Errors:     d.Errors,
+		}, nil
+	}
+
 	var change RealtimePayload
 	if err := json.Unmarshal(payload, &change); err != nil {
-		return fmt.Errorf("failed to parse change payload: %w", err)
+		return RealtimePayload{}, fmt.Errorf("failed to parse change payload: %w", err)
+	}
+	return change, nil
+}
+
+// handleChange 处理数据变更。
+func (rs *RealtimeSubscription) handleChange(ctx context.Context, payload json.RawMessage) error {
+	change, err := parseRealtimePayload(payload)
+	if err != nil {
+		return err
 	}
 
 	if len(change.Errors) > 0 {
 		return fmt.Errorf("realtime change errors: %v", change.Errors)
 	}
+	if rs.onChange != nil {
+		return rs.onChange(ctx, change)
+	}
+	ctx = rxdb.WithChangeSource(ctx, rxdb.ChangeSourceRemote)
 
 	switch change.EventType {
 	case RealtimeInsert:
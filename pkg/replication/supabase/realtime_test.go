@@ -0,0 +1,200 @@
+package supabase
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mozhou-tech/rxdb-go/pkg/rxdb"
+	"github.com/mozhou-tech/rxdb-go/pkg/rxdb/testutil"
+)
+
+// fakeRealtimeServer 模拟 Supabase 的 REST 与 Realtime 接口：每个 WebSocket 连接加入频道后
+// 通过 joined 通知测试，测试通过返回的连接推送 postgres_changes 消息。
+type fakeRealtimeServer struct {
+	*httptest.Server
+	joined chan *websocket.Conn
+	pulls  atomic.Int32
+}
+
+func newFakeRealtimeServer(t *testing.T, table string) *fakeRealtimeServer {
+	t.Helper()
+	s := &fakeRealtimeServer{joined: make(chan *websocket.Conn, 4)}
+	upgrader := websocket.Upgrader{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/v1/" + table:
+			s.pulls.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte("[]"))
+		case "/realtime/v1/websocket":
+			if r.URL.Query().Get("apikey") == "" {
+				http.Error(w, "missing apikey", http.StatusUnauthorized)
+				return
+			}
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			var join RealtimeMessage
+			if err := conn.ReadJSON(&join); err != nil || join.Event != "phx_join" {
+				t.Errorf("expected phx_join, got %+v (%v)", join, err)
+				return
+			}
+			var payload struct {
+				Config struct {
+					PostgresChanges []map[string]string `json:"postgres_changes"`
+				} `json:"config"`
+			}
+			_ = json.Unmarshal(join.Payload, &payload)
+			if len(payload.Config.PostgresChanges) != 1 || payload.Config.PostgresChanges[0]["table"] != table {
+				t.Errorf("expected postgres_changes subscription for %s, got %s", table, join.Payload)
+			}
+			_ = conn.WriteJSON(map[string]any{"event": "phx_reply", "topic": join.Topic, "ref": join.Ref, "payload": map[string]any{"status": "ok"}})
+			s.joined <- conn
+
+			// 读取心跳直到连接关闭
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return s
+}
+
+// waitJoined 等待客户端建立连接并加入频道。
+func (s *fakeRealtimeServer) waitJoined(t *testing.T) *websocket.Conn {
+	t.Helper()
+	select {
+	case conn := <-s.joined:
+		return conn
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for realtime subscription")
+		return nil
+	}
+}
+
+// pushChange 以 Realtime 服务端格式推送一条变更。
+func pushChange(t *testing.T, conn *websocket.Conn, eventType string, record, oldRecord map[string]any) {
+	t.Helper()
+	msg := map[string]any{
+		"event": "postgres_changes",
+		"topic": "realtime:public:items",
+		"payload": map[string]any{
+			"data": map[string]any{
+				"schema":           "public",
+				"table":            "items",
+				"commit_timestamp": time.Now().UTC().Format(time.RFC3339),
+				"type":             eventType,
+				"record":           record,
+				"old_record":       oldRecord,
+				"errors":           nil,
+			},
+			"ids": []any{1},
+		},
+	}
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatalf("failed to push change: %v", err)
+	}
+}
+
+func TestReplication_RealtimePushesRemoteChanges(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewTestDatabase(t)
+	coll := testutil.NewTestCollection(t, db, "items", rxdb.Schema{PrimaryKey: "id", RevField: "_rev"})
+	if _, err := coll.Insert(ctx, map[string]any{"id": "1", "name": "local"}); err != nil {
+		t.Fatalf("failed to insert document: %v", err)
+	}
+
+	server := newFakeRealtimeServer(t, "items")
+	defer server.Close()
+
+	repl, err := NewReplication(coll, ReplicationOptions{
+		SupabaseURL:               server.URL,
+		SupabaseKey:               "test-key",
+		Table:                     "items",
+		PullInterval:              time.Hour, // 确保变更来自 Realtime 推送而不是定时拉取
+		UseRealtime:               true,
+		RealtimeReconnectInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to create replication: %v", err)
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if err := repl.Start(runCtx); err != nil {
+		t.Fatalf("failed to start replication: %v", err)
+	}
+	defer repl.Stop()
+
+	conn := server.waitJoined(t)
+	changes := coll.Changes()
+
+	// 远程变更在 100ms 内应用到本地
+	expectChange := func(id string, op rxdb.Operation, push func()) {
+		t.Helper()
+		start := time.Now()
+		push()
+		deadline := time.After(100 * time.Millisecond)
+		for {
+			select {
+			case event := <-changes:
+				if event.ID != id {
+					continue
+				}
+				if event.Op != op || event.Source != rxdb.ChangeSourceRemote {
+					t.Fatalf("expected remote %s event for %s, got %+v", op, id, event)
+				}
+				t.Logf("%s %s applied after %v", op, id, time.Since(start))
+				return
+			case <-deadline:
+				t.Fatalf("remote %s of %s was not applied within 100ms", op, id)
+			}
+		}
+	}
+
+	expectChange("1", rxdb.OperationUpdate, func() {
+		pushChange(t, conn, "UPDATE", map[string]any{"id": "1", "name": "remote"}, map[string]any{"id": "1"})
+	})
+	doc, err := coll.FindByID(ctx, "1")
+	if err != nil || doc == nil || doc.GetString("name") != "remote" {
+		t.Fatalf("expected document 1 to be updated, got %v (%v)", doc, err)
+	}
+	expectChange("2", rxdb.OperationInsert, func() {
+		pushChange(t, conn, "INSERT", map[string]any{"id": "2", "name": "two"}, nil)
+	})
+	expectChange("1", rxdb.OperationDelete, func() {
+		pushChange(t, conn, "DELETE", nil, map[string]any{"id": "1"})
+	})
+
+	// 服务端断开后自动重连，重新订阅并补拉断线期间的变更
+	pullsBefore := server.pulls.Load()
+	conn.Close()
+	conn = server.waitJoined(t)
+	deadline := time.Now().Add(2 * time.Second)
+	for server.pulls.Load() == pullsBefore && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if server.pulls.Load() == pullsBefore {
+		t.Error("expected a catch-up pull after reconnecting")
+	}
+	expectChange("3", rxdb.OperationInsert, func() {
+		pushChange(t, conn, "INSERT", map[string]any{"id": "3", "name": "three"}, nil)
+	})
+
+	// 断线前推送的删除已生效
+	if doc, err := coll.FindByID(ctx, "1"); err == nil && doc != nil {
+		t.Error("expected document 1 to be deleted locally")
+	}
+}
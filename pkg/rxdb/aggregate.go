@@ -0,0 +1,508 @@
+package rxdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// AggregateStage 聚合管道阶段：MatchStage、GroupStage、SortStage、LimitStage、ProjectStage。
+type AggregateStage interface {
+	apply(c *collection, docs []map[string]any) ([]map[string]any, error)
+}
+
+// MatchStage 按 Mango 选择器过滤文档。作为第一个阶段时直接用于集合查询（可使用索引与 $text）。
+type MatchStage struct {
+	Filter map[string]any
+}
+
+// GroupStage 按 By 字段分组并计算累加器。
+// 输出文档的 _id 为分组键：By 为空时为 nil（所有文档一组），单字段时为字段值，多字段时为 {字段: 值}；
+// 字段缺失与值为 null 的文档归为同一组（键为 nil）。其余字段为 Accumulators 中各累加器的结果。
+type GroupStage struct {
+	By           []string
+	Accumulators map[string]Accumulator
+}
+
+// SortStage 按字段排序（"asc" / "desc"），多个字段按字段名顺序确定优先级，排序稳定。
+type SortStage struct {
+	Fields map[string]string
+}
+
+// LimitStage 保留前 N 个文档，N 必须为正数。
+type LimitStage struct {
+	N int
+}
+
+// ProjectStage 保留 Include 中的字段或删除 Exclude 中的字段（二者不能同时使用），支持点号路径。
+type ProjectStage struct {
+	Include []string
+	Exclude []string
+}
+
+// 累加器类型
+const (
+	accSum   = "sum"
+	accAvg   = "avg"
+	accMin   = "min"
+	accMax   = "max"
+	accCount = "count"
+	accPush  = "push"
+)
+
+// Accumulator 分组累加器，通过 Sum、Avg、Min、Max、Count、Push 创建。
+type Accumulator struct {
+	op    string
+	field string
+}
+
+// Sum 对字段求和，忽略非数值。
+// 全部为整数时按 int64 精确累加并返回 int64，出现小数或 int64 溢出时改用 float64 累加并返回 float64。
+func Sum(field string) Accumulator { return Accumulator{op: accSum, field: field} }
+
+// Avg 计算字段数值的平均值（float64），忽略非数值；组内没有数值时为 nil。
+func Avg(field string) Accumulator { return Accumulator{op: accAvg, field: field} }
+
+// Min 返回字段最小值（规则同 Collection.Min），忽略缺失值；组内没有值时为 nil。
+func Min(field string) Accumulator { return Accumulator{op: accMin, field: field} }
+
+// Max 返回字段最大值（规则同 Collection.Max），忽略缺失值；组内没有值时为 nil。
+func Max(field string) Accumulator { return Accumulator{op: accMax, field: field} }
+
+// Count 统计组内文档数（int）。
+func Count() Accumulator { return Accumulator{op: accCount} }
+
+// Push 将组内各文档的字段值收集为数组，忽略缺失值。
+func Push(field string) Accumulator { return Accumulator{op: accPush, field: field} }
+
+// Aggregate 在集合上执行聚合管道，返回最后一个阶段输出的文档。
+// 管道在内存中执行：第一个 MatchStage 用于筛选集合文档，否则扫描整个集合。
+func (c *collection) Aggregate(ctx context.Context, pipeline []AggregateStage) ([]map[string]any, error) {
+	var selector map[string]any
+	stages := pipeline
+	if len(stages) > 0 {
+		if m, ok := stages[0].(MatchStage); ok {
+			selector = m.Filter
+			stages = stages[1:]
+		}
+	}
+	if selector == nil {
+		selector = map[string]any{}
+	}
+
+	found, err := c.Find(selector).Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]map[string]any, len(found))
+	for i, doc := range found {
+		docs[i] = doc.Data()
+	}
+
+	for i, stage := range stages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if stage == nil {
+			return nil, NewError(ErrorTypeValidation, fmt.Sprintf("aggregate stage %d is nil", i), nil)
+		}
+		if docs, err = stage.apply(c, docs); err != nil {
+			return nil, err
+		}
+	}
+	return docs, nil
+}
+
+// Pipeline 创建链式聚合管道构建器，例如 Pipeline().Match(...).Group(...).Sort(...).Exec(ctx)。
+func (c *collection) Pipeline() *AggregatePipeline {
+	return &AggregatePipeline{collection: c}
+}
+
+// AggregatePipeline 链式聚合管道构建器，阶段按调用顺序执行。
+type AggregatePipeline struct {
+	collection *collection
+	stages     []AggregateStage
+}
+
+// Match 追加过滤阶段。
+func (p *AggregatePipeline) Match(filter map[string]any) *AggregatePipeline {
+	p.stages = append(p.stages, MatchStage{Filter: filter})
+	return p
+}
+
+// Group 追加分组阶段。
+func (p *AggregatePipeline) Group(by []string, accumulators map[string]Accumulator) *AggregatePipeline {
+	p.stages = append(p.stages, GroupStage{By: by, Accumulators: accumulators})
+	return p
+}
+
+// Sort 追加排序阶段。
+func (p *AggregatePipeline) Sort(fields map[string]string) *AggregatePipeline {
+	p.stages = append(p.stages, SortStage{Fields: fields})
+	return p
+}
+
+// Limit 追加数量限制阶段。
+func (p *AggregatePipeline) Limit(n int) *AggregatePipeline {
+	p.stages = append(p.stages, LimitStage{N: n})
+	return p
+}
+
+// Project 追加字段投影阶段。
+func (p *AggregatePipeline) Project(include, exclude []string) *AggregatePipeline {
+	p.stages = append(p.stages, ProjectStage{Include: include, Exclude: exclude})
+	return p
+}
+
+// Stages 返回已构建的管道阶段。
+func (p *AggregatePipeline) Stages() []AggregateStage {
+	return p.stages
+}
+
+// Exec 执行聚合管道。
+func (p *AggregatePipeline) Exec(ctx context.Context) ([]map[string]any, error) {
+	return p.collection.Aggregate(ctx, p.stages)
+}
+
+func (s MatchStage) apply(c *collection, docs []map[string]any) ([]map[string]any, error) {
+	q := c.Find(s.Filter)
+	if q.hasText() {
+		return nil, NewError(ErrorTypeValidation, "$text is only supported in the first $match stage", nil)
+	}
+	out := make([]map[string]any, 0, len(docs))
+	for _, doc := range docs {
+		if q.match(doc) {
+			out = append(out, doc)
+		}
+	}
+	return out, nil
+}
+
+// aggregateGroup 分组的中间状态。
+type aggregateGroup struct {
+	id   any
+	accs map[string]*accumulatorState
+}
+
+func (s GroupStage) apply(c *collection, docs []map[string]any) ([]map[string]any, error) {
+	for name := range s.Accumulators {
+		if name == "_id" {
+			return nil, NewError(ErrorTypeValidation, "group accumulator cannot be named _id", nil)
+		}
+	}
+	byParts := make([][]string, len(s.By))
+	for i, field := range s.By {
+		byParts[i] = splitQueryPath(field)
+	}
+
+	var groups []*aggregateGroup
+	index := make(map[string]*aggregateGroup)
+	for _, doc := range docs {
+		keyValues := make([]any, len(s.By))
+		for i, parts := range byParts {
+			keyValues[i] = getNestedValueByParts(doc, parts)
+		}
+		rawKey, err := json.Marshal(keyValues)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode group key: %w", err)
+		}
+
+		group, ok := index[string(rawKey)]
+		if !ok {
+			group = &aggregateGroup{id: groupID(s.By, keyValues), accs: make(map[string]*accumulatorState, len(s.Accumulators))}
+			for name, acc := range s.Accumulators {
+				group.accs[name] = &accumulatorState{acc: acc, parts: splitQueryPath(acc.field)}
+			}
+			index[string(rawKey)] = group
+			groups = append(groups, group)
+		}
+		for _, state := range group.accs {
+			state.add(doc)
+		}
+	}
+
+	out := make([]map[string]any, len(groups))
+	for i, group := range groups {
+		result := map[string]any{"_id": group.id}
+		for name, state := range group.accs {
+			result[name] = state.result()
+		}
+		out[i] = result
+	}
+	return out, nil
+}
+
+// groupID 根据分组字段构造输出文档的 _id。
+func groupID(by []string, values []any) any {
+	switch len(by) {
+	case 0:
+		return nil
+	case 1:
+		return values[0]
+	}
+	id := make(map[string]any, len(by))
+	for i, field := range by {
+		id[field] = values[i]
+	}
+	return id
+}
+
+// accumulatorState 单个累加器在分组内的状态。
+type accumulatorState struct {
+	acc   Accumulator
+	parts []string
+
+	count    int
+	intSum   int64
+	floatSum float64
+	isFloat  bool
+	best     any
+	values   []any
+}
+
+func (s *accumulatorState) add(doc map[string]any) {
+	if s.acc.op == accCount {
+		s.count++
+		return
+	}
+	v := getNestedValueByParts(doc, s.parts)
+	if v == nil {
+		return
+	}
+
+	switch s.acc.op {
+	case accSum, accAvg:
+		if !isNumeric(v) {
+			return
+		}
+		s.count++
+		s.addNumber(v)
+	case accMin:
+		if s.best == nil || compareValues(v, s.best) < 0 {
+			s.best = v
+		}
+	case accMax:
+		if s.best == nil || compareValues(v, s.best) > 0 {
+			s.best = v
+		}
+	case accPush:
+		s.values = append(s.values, v)
+	}
+}
+
+// addNumber 累加数值：整数按 int64 精确累加，出现小数或溢出后切换为 float64。
+func (s *accumulatorState) addNumber(v any) {
+	if !s.isFloat {
+		if n, ok := exactInt64(v); ok {
+			if (n > 0 && s.intSum > math.MaxInt64-n) || (n < 0 && s.intSum < math.MinInt64-n) {
+				s.isFloat = true
+				s.floatSum = float64(s.intSum) + float64(n)
+				return
+			}
+			s.intSum += n
+			return
+		}
+		s.isFloat = true
+		s.floatSum = float64(s.intSum)
+	}
+	s.floatSum += numberToFloat64(v)
+}
+
+func (s *accumulatorState) result() any {
+	switch s.acc.op {
+	case accCount:
+		return s.count
+	case accSum:
+		if s.isFloat {
+			return s.floatSum
+		}
+		return s.intSum
+	case accAvg:
+		if s.count == 0 {
+			return nil
+		}
+		if s.isFloat {
+			return s.floatSum / float64(s.count)
+		}
+		return float64(s.intSum) / float64(s.count)
+	case accMin, accMax:
+		return s.best
+	case accPush:
+		if s.values == nil {
+			return []any{}
+		}
+		return s.values
+	}
+	return nil
+}
+
+// exactInt64 在数值可以无损表示为 int64 时返回该整数。
+func exactInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		if uint64(n) <= math.MaxInt64 {
+			return int64(n), true
+		}
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		if n <= math.MaxInt64 {
+			return int64(n), true
+		}
+	case float32:
+		return exactInt64(float64(n))
+	case float64:
+		// 超过 2^53 的浮点数本身已不精确，按浮点累加
+		if n == math.Trunc(n) && math.Abs(n) <= 1<<53 {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
+
+// numberToFloat64 将任意数值类型转换为 float64。
+func numberToFloat64(v any) float64 {
+	switch n := v.(type) {
+	case int8:
+		return float64(n)
+	case int16:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case uint:
+		return float64(n)
+	case uint8:
+		return float64(n)
+	case uint16:
+		return float64(n)
+	case uint32:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	}
+	return toFloat64(v)
+}
+
+func (s SortStage) apply(c *collection, docs []map[string]any) ([]map[string]any, error) {
+	fields := make([]string, 0, len(s.Fields))
+	for field := range s.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	sortFields := make([]SortField, len(fields))
+	for i, field := range fields {
+		order := s.Fields[field]
+		switch order {
+		case "asc", "ASC", "":
+		case "desc", "DESC":
+			sortFields[i].Desc = true
+		default:
+			return nil, NewError(ErrorTypeValidation, fmt.Sprintf("invalid sort order %q for field %s", order, field), nil)
+		}
+		sortFields[i].Field = field
+		sortFields[i].SplitField = splitQueryPath(field)
+	}
+
+	out := append([]map[string]any(nil), docs...)
+	sort.SliceStable(out, func(i, j int) bool {
+		for _, sf := range sortFields {
+			cmp := compareValues(getNestedValueByParts(out[i], sf.SplitField), getNestedValueByParts(out[j], sf.SplitField))
+			if cmp == 0 {
+				continue
+			}
+			if sf.Desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+	return out, nil
+}
+
+func (s LimitStage) apply(c *collection, docs []map[string]any) ([]map[string]any, error) {
+	if s.N <= 0 {
+		return nil, NewError(ErrorTypeValidation, fmt.Sprintf("limit must be positive, got %d", s.N), nil)
+	}
+	if len(docs) > s.N {
+		docs = docs[:s.N]
+	}
+	return docs, nil
+}
+
+func (s ProjectStage) apply(c *collection, docs []map[string]any) ([]map[string]any, error) {
+	if len(s.Include) > 0 && len(s.Exclude) > 0 {
+		return nil, NewError(ErrorTypeValidation, "project cannot both include and exclude fields", nil)
+	}
+
+	out := make([]map[string]any, len(docs))
+	for i, doc := range docs {
+		if len(s.Include) > 0 {
+			projected := make(map[string]any, len(s.Include))
+			for _, field := range s.Include {
+				parts := splitQueryPath(field)
+				if fieldExistsInDocByParts(doc, parts) {
+					setNestedValue(projected, parts, deepCloneValue(getNestedValueByParts(doc, parts)))
+				}
+			}
+			out[i] = projected
+			continue
+		}
+
+		projected := doc
+		for _, field := range s.Exclude {
+			projected, _ = withoutNestedValue(projected, splitQueryPath(field))
+		}
+		out[i] = projected
+	}
+	return out, nil
+}
+
+// withoutNestedValue 返回删除嵌套字段后的文档，只复制路径上的 map，不修改原文档。
+// 第二个返回值表示字段是否存在并被删除，不存在时返回原文档。
+func withoutNestedValue(doc map[string]any, parts []string) (map[string]any, bool) {
+	value, ok := doc[parts[0]]
+	if !ok {
+		return doc, false
+	}
+	var nested map[string]any
+	if len(parts) > 1 {
+		m, isMap := value.(map[string]any)
+		if !isMap {
+			return doc, false
+		}
+		if nested, ok = withoutNestedValue(m, parts[1:]); !ok {
+			return doc, false
+		}
+	}
+
+	out := make(map[string]any, len(doc))
+	for k, v := range doc {
+		out[k] = v
+	}
+	if len(parts) > 1 {
+		out[parts[0]] = nested
+	} else {
+		delete(out, parts[0])
+	}
+	return out, true
+}
@@ -0,0 +1,228 @@
+package rxdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// AggregateDebounceDuration ObserveAggregate 的防抖窗口：首个相关变更到达后等待该时长，
+// 窗口内的所有变更只触发一次重新聚合。
+var AggregateDebounceDuration = 200 * time.Millisecond
+
+// ObserveAggregate 观察聚合结果的变化。pipeline 只能由可选的 MatchStage 与一个 GroupStage 组成。
+//
+// 订阅时立即发送一次结果，之后仅当变更涉及 MatchStage 过滤的文档或 GroupStage 引用的字段时，
+// 在 AggregateDebounceDuration 防抖窗口结束后重新聚合，结果与上次不同时才发送。
+// GroupStage.By 为空时发送的 map 为累加器名到结果的映射（集合为空时同样发送，如 Count 为 0）；
+// 按字段分组时 key 为分组键（字符串键直接使用，其余键为其 JSON 编码），value 为该组的累加器结果。
+// pipeline 不合法时 channel 直接关闭；ctx 取消时 channel 关闭。
+func (c *collection) ObserveAggregate(ctx context.Context, pipeline []AggregateStage) <-chan map[string]any {
+	resultChan := make(chan map[string]any, 1)
+
+	obs, err := c.newAggregateObserver(pipeline)
+	if err != nil {
+		c.logger.Warn("Invalid observable aggregate pipeline", "collection", c.name, "error", err)
+		close(resultChan)
+		return resultChan
+	}
+	// 先订阅再执行初始聚合，避免遗漏两者之间的变更
+	changes := c.Changes()
+
+	go func() {
+		defer close(resultChan)
+
+		var last map[string]any
+		emit := func(initial bool) bool {
+			queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			result, err := obs.exec(queryCtx)
+			cancel()
+			if err != nil {
+				if ctx.Err() == nil {
+					c.logger.Warn("Observable aggregate failed", "collection", c.name, "error", err)
+				}
+				return ctx.Err() == nil
+			}
+			if !initial && reflect.DeepEqual(last, result) {
+				return true
+			}
+			last = result
+			select {
+			case resultChan <- result:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if !emit(true) {
+			return
+		}
+
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case event, ok := <-changes:
+				if !ok {
+					return
+				}
+				// 窗口已开启时只需累积事件，不重置计时器
+				if timerC == nil && obs.affectedBy(event) {
+					timer = time.NewTimer(AggregateDebounceDuration)
+					timerC = timer.C
+				}
+			case <-timerC:
+				timer, timerC = nil, nil
+				if !emit(false) {
+					return
+				}
+			}
+		}
+	}()
+
+	return resultChan
+}
+
+// aggregateObserver 保存 ObserveAggregate 解析后的管道。
+type aggregateObserver struct {
+	c      *collection
+	filter map[string]any
+	match  *Query // 无 MatchStage 时为 nil
+	group  GroupStage
+	fields map[string]struct{} // GroupStage 引用字段的顶层字段名
+}
+
+func (c *collection) newAggregateObserver(pipeline []AggregateStage) (*aggregateObserver, error) {
+	obs := &aggregateObserver{c: c, fields: make(map[string]struct{})}
+	stages := pipeline
+	if len(stages) > 0 {
+		if m, ok := stages[0].(MatchStage); ok {
+			obs.filter = m.Filter
+			obs.match = c.Find(m.Filter)
+			stages = stages[1:]
+		}
+	}
+	if len(stages) != 1 {
+		return nil, NewError(ErrorTypeValidation, "observable aggregate pipeline must be an optional MatchStage followed by a GroupStage", nil)
+	}
+	group, ok := stages[0].(GroupStage)
+	if !ok {
+		return nil, NewError(ErrorTypeValidation, fmt.Sprintf("observable aggregate does not support %T", stages[0]), nil)
+	}
+	obs.group = group
+	for _, field := range group.By {
+		obs.fields[rootField(field)] = struct{}{}
+	}
+	for _, acc := range group.Accumulators {
+		if acc.field != "" {
+			obs.fields[rootField(acc.field)] = struct{}{}
+		}
+	}
+	return obs, nil
+}
+
+func (o *aggregateObserver) exec(ctx context.Context) (map[string]any, error) {
+	pipeline := []AggregateStage{o.group}
+	if o.match != nil {
+		pipeline = []AggregateStage{MatchStage{Filter: o.filter}, o.group}
+	}
+	rows, err := o.c.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(o.group.By) == 0 {
+		result := make(map[string]any, len(o.group.Accumulators))
+		if len(rows) == 0 {
+			// 没有文档时仍输出累加器的初始结果
+			for name, acc := range o.group.Accumulators {
+				result[name] = (&accumulatorState{acc: acc}).result()
+			}
+			return result, nil
+		}
+		for k, v := range rows[0] {
+			if k != "_id" {
+				result[k] = v
+			}
+		}
+		return result, nil
+	}
+
+	result := make(map[string]any, len(rows))
+	for _, row := range rows {
+		key, err := aggregateGroupKey(row["_id"])
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[string]any, len(row)-1)
+		for k, v := range row {
+			if k != "_id" {
+				values[k] = v
+			}
+		}
+		result[key] = values
+	}
+	return result, nil
+}
+
+// affectedBy 判断变更是否可能改变聚合结果：文档进入或离开 MatchStage 的结果集，
+// 或结果集内文档的分组字段、累加字段发生变化。
+func (o *aggregateObserver) affectedBy(event ChangeEvent) bool {
+	if event.Op == OperationTruncate || (event.Doc == nil && event.Old == nil) {
+		return true
+	}
+	if event.Doc == nil || event.Old == nil {
+		// 插入或删除：文档属于结果集时才影响聚合
+		doc := event.Doc
+		if doc == nil {
+			doc = event.Old
+		}
+		return o.match == nil || o.match.match(doc)
+	}
+
+	matchNew, matchOld := true, true
+	if o.match != nil {
+		matchNew, matchOld = o.match.match(event.Doc), o.match.match(event.Old)
+	}
+	if matchNew != matchOld {
+		return true
+	}
+	if !matchNew {
+		return false
+	}
+	for field := range o.fields {
+		if !jsonValuesEqual(event.Doc[field], event.Old[field]) {
+			return true
+		}
+	}
+	return false
+}
+
+// rootField 返回字段路径的顶层字段名，如 "items[*].price" 与 "items.price" 均为 "items"。
+func rootField(path string) string {
+	if i := strings.IndexAny(path, ".["); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// aggregateGroupKey 将分组 _id 转换为 ObserveAggregate 输出的 map key。
+func aggregateGroupKey(id any) (string, error) {
+	if s, ok := id.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(id)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode group key: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,447 @@
+package rxdb
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+)
+
+// benchSeed 基准数据生成使用的固定随机种子，保证不同运行之间的数据一致。
+const benchSeed = 42
+
+var benchCategories = []string{"books", "electronics", "food", "garden", "toys"}
+
+// newBenchCollection 在临时目录中创建用于基准测试的数据库和集合，测试结束时自动清理。
+func newBenchCollection(b *testing.B, indexes []Index) Collection {
+	b.Helper()
+
+	tmpDir, err := os.MkdirTemp("", "rxdb-bench-*")
+	if err != nil {
+		b.Fatalf("failed to create temp dir: %v", err)
+	}
+	b.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	ctx := context.Background()
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name:     "bench",
+		Path:     tmpDir,
+		LogLevel: "error",
+	})
+	if err != nil {
+		b.Fatalf("failed to create database: %v", err)
+	}
+	b.Cleanup(func() { db.Close(ctx) })
+
+	coll, err := db.Collection(ctx, "items", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		Indexes:    indexes,
+	})
+	if err != nil {
+		b.Fatalf("failed to create collection: %v", err)
+	}
+	return coll
+}
+
+// benchDocs 生成 n 个确定性的测试文档，ID 以 prefix 开头。
+func benchDocs(rng *rand.Rand, prefix string, n int) []map[string]any {
+	docs := make([]map[string]any, n)
+	for i := range docs {
+		docs[i] = map[string]any{
+			"id":       fmt.Sprintf("%s%07d", prefix, i),
+			"category": benchCategories[rng.Intn(len(benchCategories))],
+			"price":    float64(rng.Intn(10000)) / 100,
+			"title":    fmt.Sprintf("item %d about databases and search", rng.Intn(1000000)),
+		}
+	}
+	return docs
+}
+
+// seedBenchCollection 批量写入 n 个文档。
+func seedBenchCollection(b *testing.B, coll Collection, n int) []map[string]any {
+	b.Helper()
+	docs := benchDocs(rand.New(rand.NewSource(benchSeed)), "doc", n)
+	for start := 0; start < len(docs); start += 1000 {
+		end := start + 1000
+		if end > len(docs) {
+			end = len(docs)
+		}
+		if _, err := coll.BulkInsert(context.Background(), docs[start:end]); err != nil {
+			b.Fatalf("failed to seed documents: %v", err)
+		}
+	}
+	return docs
+}
+
+// reportDocsPerSec 以 docs/sec 报告吞吐量，docsPerOp 为每次迭代处理的文档数。
+func reportDocsPerSec(b *testing.B, docsPerOp int) {
+	if seconds := b.Elapsed().Seconds(); seconds > 0 {
+		b.ReportMetric(float64(docsPerOp*b.N)/seconds, "docs/sec")
+	}
+}
+
+func BenchmarkInsert(b *testing.B) {
+	ctx := context.Background()
+	coll := newBenchCollection(b, nil)
+	docs := benchDocs(rand.New(rand.NewSource(benchSeed)), "doc", b.N)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := coll.Insert(ctx, docs[i]); err != nil {
+			b.Fatalf("failed to insert: %v", err)
+		}
+	}
+	reportDocsPerSec(b, 1)
+}
+
+func BenchmarkBulkInsert(b *testing.B) {
+	for _, size := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("%d", size), func(b *testing.B) {
+			ctx := context.Background()
+			coll := newBenchCollection(b, nil)
+			rng := rand.New(rand.NewSource(benchSeed))
+			batches := make([][]map[string]any, b.N)
+			for i := range batches {
+				batches[i] = benchDocs(rng, fmt.Sprintf("b%d_", i), size)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := coll.BulkInsert(ctx, batches[i]); err != nil {
+					b.Fatalf("failed to bulk insert: %v", err)
+				}
+			}
+			reportDocsPerSec(b, size)
+		})
+	}
+}
+
+func BenchmarkFindByID(b *testing.B) {
+	ctx := context.Background()
+	coll := newBenchCollection(b, nil)
+	docs := seedBenchCollection(b, coll, 1000)
+	rng := rand.New(rand.NewSource(benchSeed))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		id := docs[rng.Intn(len(docs))]["id"].(string)
+		if _, err := coll.FindByID(ctx, id); err != nil {
+			b.Fatalf("failed to find by id: %v", err)
+		}
+	}
+	reportDocsPerSec(b, 1)
+}
+
+func BenchmarkAll(b *testing.B) {
+	ctx := context.Background()
+	coll := newBenchCollection(b, nil)
+	seedBenchCollection(b, coll, 1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		docs, err := coll.All(ctx)
+		if err != nil {
+			b.Fatalf("failed to get all: %v", err)
+		}
+		if len(docs) != 1000 {
+			b.Fatalf("expected 1000 documents, got %d", len(docs))
+		}
+	}
+	reportDocsPerSec(b, 1000)
+}
+
+func BenchmarkFind(b *testing.B) {
+	run := func(b *testing.B, indexes []Index) {
+		ctx := context.Background()
+		coll := newBenchCollection(b, indexes)
+		seedBenchCollection(b, coll, 1000)
+		qc := AsQueryCollection(coll)
+
+		b.ResetTimer()
+		matched := 0
+		for i := 0; i < b.N; i++ {
+			docs, err := qc.Find(map[string]any{"category": "books"}).Exec(ctx)
+			if err != nil {
+				b.Fatalf("failed to find: %v", err)
+			}
+			matched = len(docs)
+		}
+		reportDocsPerSec(b, matched)
+	}
+
+	b.Run("WithIndex", func(b *testing.B) {
+		run(b, []Index{{Fields: []string{"category"}, Name: "category_idx"}})
+	})
+	b.Run("WithoutIndex", func(b *testing.B) {
+		run(b, nil)
+	})
+}
+
+func BenchmarkSortLimit(b *testing.B) {
+	ctx := context.Background()
+	coll := newBenchCollection(b, nil)
+	seedBenchCollection(b, coll, 1000)
+	qc := AsQueryCollection(coll)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		docs, err := qc.Find(nil).Sort(map[string]string{"price": "desc"}).Limit(10).Exec(ctx)
+		if err != nil {
+			b.Fatalf("failed to find: %v", err)
+		}
+		if len(docs) != 10 {
+			b.Fatalf("expected 10 documents, got %d", len(docs))
+		}
+	}
+	reportDocsPerSec(b, 10)
+}
+
+// newBenchVectorSearch 写入 total 个 dimensions 维的随机向量文档并创建指定索引类型的向量搜索。
+func newBenchVectorSearch(b *testing.B, indexType string, total, dimensions int) *VectorSearch {
+	b.Helper()
+
+	ctx := context.Background()
+	coll := newBenchCollection(b, nil)
+	vectors := randomVectors(total, dimensions, benchSeed)
+	docs := make([]map[string]any, total)
+	for i := range docs {
+		embedding := make([]any, dimensions)
+		for j, v := range vectors[i] {
+			embedding[j] = v
+		}
+		docs[i] = map[string]any{"id": fmt.Sprintf("vec%05d", i), "embedding": embedding}
+	}
+	for start := 0; start < total; start += 1000 {
+		end := min(start+1000, total)
+		if _, err := coll.BulkInsert(ctx, docs[start:end]); err != nil {
+			b.Fatalf("failed to insert: %v", err)
+		}
+	}
+
+	vs, err := AddVectorSearch(coll, VectorSearchConfig{
+		Identifier: "bench-vectors",
+		Dimensions: dimensions,
+		DocToEmbedding: func(doc map[string]any) (Vector, error) {
+			raw, _ := doc["embedding"].([]any)
+			vec := make(Vector, len(raw))
+			for i, v := range raw {
+				vec[i], _ = v.(float64)
+			}
+			return vec, nil
+		},
+		DistanceMetric: "cosine",
+		IndexType:      indexType,
+	})
+	if err != nil {
+		b.Fatalf("failed to create vector search: %v", err)
+	}
+	b.Cleanup(vs.Close)
+	return vs
+}
+
+func BenchmarkVectorSearch_Search(b *testing.B) {
+	const (
+		total      = 10000
+		dimensions = 128
+	)
+
+	ctx := context.Background()
+	vs := newBenchVectorSearch(b, "", total, dimensions)
+	queries := randomVectors(16, dimensions, benchSeed+1)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := vs.Search(ctx, queries[i%len(queries)], VectorSearchOptions{Limit: 10}); err != nil {
+			b.Fatalf("failed to search: %v", err)
+		}
+	}
+	reportDocsPerSec(b, total)
+}
+
+// BenchmarkVectorSearch_BatchSearch 对比逐个调用 Search 与 BatchSearch 处理同一批查询的吞吐量（queries/sec）。
+// 多核机器上 batch 的吞吐量应随 CPU 核数成倍高于 sequential。
+func BenchmarkVectorSearch_BatchSearch(b *testing.B) {
+	const (
+		total      = 10000
+		dimensions = 128
+		batchSize  = 64
+	)
+
+	ctx := context.Background()
+	queries := randomVectors(batchSize, dimensions, benchSeed+1)
+	opts := VectorSearchOptions{Limit: 10}
+	reportQueriesPerSec := func(b *testing.B) {
+		if seconds := b.Elapsed().Seconds(); seconds > 0 {
+			b.ReportMetric(float64(batchSize*b.N)/seconds, "queries/sec")
+		}
+	}
+
+	for _, indexType := range []string{"flat", "hnsw"} {
+		vs := newBenchVectorSearch(b, indexType, total, dimensions)
+
+		b.Run(indexType+"/sequential", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, query := range queries {
+					if _, err := vs.Search(ctx, query, opts); err != nil {
+						b.Fatalf("failed to search: %v", err)
+					}
+				}
+			}
+			reportQueriesPerSec(b)
+		})
+		b.Run(indexType+"/batch", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := vs.BatchSearch(ctx, queries, opts); err != nil {
+					b.Fatalf("failed to search: %v", err)
+				}
+			}
+			reportQueriesPerSec(b)
+		})
+	}
+}
+
+func BenchmarkFulltextSearch_Find(b *testing.B) {
+	ctx := context.Background()
+	coll := newBenchCollection(b, nil)
+	seedBenchCollection(b, coll, 1000)
+
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier: "bench-fulltext",
+		DocToString: func(doc map[string]any) string {
+			title, _ := doc["title"].(string)
+			return title
+		},
+	})
+	if err != nil {
+		b.Fatalf("failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+
+	b.ResetTimer()
+	matched := 0
+	for i := 0; i < b.N; i++ {
+		docs, err := fts.Find(ctx, "databases search", FulltextSearchOptions{Limit: 10})
+		if err != nil {
+			b.Fatalf("failed to search: %v", err)
+		}
+		matched = len(docs)
+	}
+	reportDocsPerSec(b, matched)
+}
+
+func BenchmarkBulkRemove(b *testing.B) {
+	const batchSize = 100
+
+	ctx := context.Background()
+	coll := newBenchCollection(b, nil)
+	rng := rand.New(rand.NewSource(benchSeed))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		docs := benchDocs(rng, fmt.Sprintf("r%d_", i), batchSize)
+		if _, err := coll.BulkInsert(ctx, docs); err != nil {
+			b.Fatalf("failed to insert: %v", err)
+		}
+		ids := make([]string, len(docs))
+		for j, doc := range docs {
+			ids[j] = doc["id"].(string)
+		}
+		b.StartTimer()
+
+		if err := coll.BulkRemove(ctx, ids); err != nil {
+			b.Fatalf("failed to bulk remove: %v", err)
+		}
+	}
+	reportDocsPerSec(b, batchSize)
+}
+
+// BenchmarkTruncate 对比 Truncate 与删除后重建（二级索引 + 全文搜索）清空 10000 个文档的开销。
+// 集合没有 Drop 操作，DropAndRecreate 以删除全部文档、删除并重建索引、重建全文搜索实例来模拟。
+func BenchmarkTruncate(b *testing.B) {
+	const total = 10000
+
+	index := Index{Fields: []string{"category"}, Name: "category_idx"}
+	ftsConfig := FulltextSearchConfig{
+		Identifier: "bench-truncate",
+		DocToString: func(doc map[string]any) string {
+			title, _ := doc["title"].(string)
+			return title
+		},
+	}
+
+	setup := func(b *testing.B) (Collection, *FulltextSearch) {
+		coll := newBenchCollection(b, []Index{index})
+		fts, err := AddFulltextSearch(coll, ftsConfig)
+		if err != nil {
+			b.Fatalf("failed to create fulltext search: %v", err)
+		}
+		return coll, fts
+	}
+
+	// waitIndexed 等待全文索引处理完写入事件，避免后台索引与计时阶段重叠
+	waitIndexed := func(fts *FulltextSearch) {
+		last := -1
+		for count := fts.Count(); count != last; count = fts.Count() {
+			last = count
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	b.Run("Truncate", func(b *testing.B) {
+		ctx := context.Background()
+		coll, fts := setup(b)
+		defer fts.Close()
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			seedBenchCollection(b, coll, total)
+			waitIndexed(fts)
+			b.StartTimer()
+
+			if err := coll.Truncate(ctx); err != nil {
+				b.Fatalf("failed to truncate: %v", err)
+			}
+		}
+		reportDocsPerSec(b, total)
+	})
+
+	b.Run("DropAndRecreate", func(b *testing.B) {
+		ctx := context.Background()
+		coll, fts := setup(b)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			docs := seedBenchCollection(b, coll, total)
+			waitIndexed(fts)
+			ids := make([]string, len(docs))
+			for j, doc := range docs {
+				ids[j] = doc["id"].(string)
+			}
+			b.StartTimer()
+
+			fts.Close()
+			if err := coll.DropIndex(ctx, index.Name); err != nil {
+				b.Fatalf("failed to drop index: %v", err)
+			}
+			if err := coll.BulkRemove(ctx, ids); err != nil {
+				b.Fatalf("failed to bulk remove: %v", err)
+			}
+			if err := coll.CreateIndex(ctx, index); err != nil {
+				b.Fatalf("failed to create index: %v", err)
+			}
+			var err error
+			if fts, err = AddFulltextSearch(coll, ftsConfig); err != nil {
+				b.Fatalf("failed to create fulltext search: %v", err)
+			}
+		}
+		b.StopTimer()
+		fts.Close()
+		reportDocsPerSec(b, total)
+	})
+}
@@ -15,13 +15,13 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
 	bstore "github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
-	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 )
 
 // HookFunc 定义钩子函数类型。
@@ -47,7 +47,7 @@ func getSchemaVersion(schema Schema) int {
 type collection struct {
 	name          string
 	schema        Schema
-	store         *bstore.Store
+	store         *kvStore
 	db            Database // 关联的数据库
 	changes       chan ChangeEvent
 	mu            sync.RWMutex
@@ -57,12 +57,46 @@ type collection struct {
 	hashFn        func([]byte) string
 	broadcaster   *eventBroadcaster // 多实例事件广播器（如果启用）
 	password      string            // 数据库密码（用于字段加密）
+	maxDocSize    int               // 单文档大小上限（字节），0 表示不限制
+	writeLimit    *rate.Limiter     // 数据库共享的写入限流器，nil 表示不限制
 
 	// 订阅者管理
 	subscribersMu   sync.RWMutex
 	subscribers     map[uint64]chan ChangeEvent
 	subscriberIDGen uint64
 
+	// 可恢复变更流（Watch/WatchFrom），仅在 Schema.Changelog 启用时使用
+	cdcMu          sync.Mutex
+	cdcNext        int64              // 最近分配的序列号
+	cdcCommitted   int64              // 水位线：不大于它的序列号都已提交或已放弃
+	cdcFirst       int64              // 最早保留的序列号
+	cdcInflight    map[int64]struct{} // 已分配但所在事务尚未结束的序列号
+	cdcTrimMu      sync.Mutex         // 保证同一时间只有一个事务清理过期日志
+	cdcSubscribers map[uint64]chan struct{}
+	cdcSubIDGen    uint64
+
+	// 查询结果缓存（Query.Cache），变更时同步失效
+	queryCachesMu sync.Mutex
+	queryCaches   map[*queryCache]struct{}
+
+	// 已添加的全文搜索实例（$text 查询使用）
+	fulltextMu        sync.RWMutex
+	fulltexts         []*FulltextSearch
+	declaredFulltexts []*FulltextSearch // Schema.FulltextIndexes 创建的实例，数据库关闭时关闭
+
+	// 已打开的向量搜索实例（GetVectorIndex 使用）
+	vectorMu        sync.RWMutex
+	vectors         []*VectorSearch
+	declaredVectors []*VectorSearch // Schema.VectorIndexes 创建的实例，数据库关闭时关闭
+
+	// TTL 过期清理（Schema.TTL 未设置时为 nil）
+	ttlStop     chan struct{}
+	ttlStopOnce sync.Once
+	ttlDone     chan struct{}
+
+	// 串行化 FindOrCreate，保证查找与插入之间不被其他 FindOrCreate 插入
+	findOrCreateMu sync.Mutex
+
 	// 数据库级别事件回调（用于向数据库发送变更事件）
 	dbEventCallback func(event ChangeEvent)
 
@@ -80,6 +114,15 @@ type collection struct {
 	preCreate  []HookFunc
 	postCreate []HookFunc
 
+	// 生命周期钩子（SetHooks）
+	hooks SchemaHooks
+
+	// 自定义验证器（按注册顺序执行）
+	validators []Validator
+
+	// 读取转换器（作用于返回给调用方的文档）
+	readTransformer ReadTransformer
+
 	// 同步处理
 	resyncHandlers     []func(ctx context.Context, docID string) error
 	syncStatusHandlers []func() bool
@@ -91,10 +134,12 @@ type collection struct {
 	// 布隆过滤器优化
 	idBloomFilter     *BloomFilter
 	bloomNeedsRebuild bool
+
+	logger Logger // 内部日志器（继承自数据库）
 }
 
-func newCollection(ctx context.Context, db Database, store *bstore.Store, name string, schema Schema, hashFn func([]byte) string, broadcaster *eventBroadcaster, password string, dbEventCallback func(event ChangeEvent), beginOp func(ctx context.Context) error, endOp func()) (*collection, error) {
-	logrus.WithField("name", name).Debug("Creating collection")
+func newCollection(ctx context.Context, db Database, store *kvStore, name string, schema Schema, hashFn func([]byte) string, broadcaster *eventBroadcaster, password string, dbEventCallback func(event ChangeEvent), beginOp func(ctx context.Context) error, endOp func(), logger Logger) (*collection, error) {
+	logger.Debug("Creating collection", "name", name)
 
 	col := &collection{
 		name:            name,
@@ -107,6 +152,8 @@ func newCollection(ctx context.Context, db Database, store *bstore.Store, name s
 		broadcaster:     broadcaster,
 		password:        password,
 		subscribers:     make(map[uint64]chan ChangeEvent),
+		cdcInflight:     make(map[int64]struct{}),
+		cdcSubscribers:  make(map[uint64]chan struct{}),
 		dbEventCallback: dbEventCallback,
 		beginOp:         beginOp,
 		endOp:           endOp,
@@ -118,6 +165,7 @@ func newCollection(ctx context.Context, db Database, store *bstore.Store, name s
 		postRemove:      make([]HookFunc, 0),
 		preCreate:       make([]HookFunc, 0),
 		postCreate:      make([]HookFunc, 0),
+		logger:          logger,
 	}
 
 	// 调用 preCreate 钩子
@@ -128,10 +176,7 @@ func newCollection(ctx context.Context, db Database, store *bstore.Store, name s
 	}
 
 	// Badger 不需要预创建 bucket，使用键前缀来区分集合
-	logrus.WithFields(logrus.Fields{
-		"name":    name,
-		"indexes": len(schema.Indexes),
-	}).Debug("Collection created successfully")
+	logger.Debug("Collection created successfully", "name", name, "indexes", len(schema.Indexes))
 
 	// 调用 postCreate 钩子
 	for _, hook := range col.postCreate {
@@ -145,14 +190,18 @@ func newCollection(ctx context.Context, db Database, store *bstore.Store, name s
 	// 初始化布隆过滤器
 	col.idBloomFilter = NewBloomFilter(10000, 0.01)
 	if err := col.loadBloomFilter(ctx); err != nil {
-		logrus.WithField("collection", name).WithError(err).Debug("Failed to load bloom filter, initializing from storage")
+		logger.Debug("Failed to load bloom filter, initializing from storage", "collection", name, "error", err)
 		if err := col.initBloomFilter(ctx); err != nil {
-			logrus.WithField("collection", name).WithError(err).Warn("Failed to initialize bloom filter from storage")
+			logger.Warn("Failed to initialize bloom filter from storage", "collection", name, "error", err)
 		} else {
 			_ = col.saveBloomFilter(ctx)
 		}
 	}
 
+	if err := col.loadChangelogRange(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load changelog: %w", err)
+	}
+
 	// 获取存储的版本
 	storedVersion := 0
 	versionKey := fmt.Sprintf("%s_version", name)
@@ -177,6 +226,19 @@ func newCollection(ctx context.Context, db Database, store *bstore.Store, name s
 		}
 	}
 
+	// 同步 schema 声明的索引：在返回集合之前构建新增的索引
+	storedIndexes, err := col.loadIndexes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load indexes: %w", err)
+	}
+	col.schema.Indexes = mergeSchemaIndexes(storedIndexes, schema.Indexes, schema.DropMissingIndexes)
+	if err := col.updateIndexesOnSchemaChange(ctx, storedIndexes, col.schema.Indexes); err != nil {
+		return nil, fmt.Errorf("failed to update indexes: %w", err)
+	}
+	if err := col.saveIndexes(ctx); err != nil {
+		return nil, fmt.Errorf("failed to save indexes: %w", err)
+	}
+
 	return col, nil
 }
 
@@ -257,6 +319,12 @@ func (c *collection) Changes() <-chan ChangeEvent {
 
 // subscribe 创建一个新的订阅通道，每个订阅者都会收到所有变更事件的独立副本。
 func (c *collection) subscribe() <-chan ChangeEvent {
+	_, ch := c.subscribeWithID()
+	return ch
+}
+
+// subscribeWithID 与 subscribe 相同，同时返回可用于 unsubscribe 的订阅 ID（集合已关闭时为 0）。
+func (c *collection) subscribeWithID() (uint64, <-chan ChangeEvent) {
 	c.subscribersMu.Lock()
 	defer c.subscribersMu.Unlock()
 
@@ -265,7 +333,7 @@ func (c *collection) subscribe() <-chan ChangeEvent {
 	case <-c.closeChan:
 		ch := make(chan ChangeEvent)
 		close(ch)
-		return ch
+		return 0, ch
 	default:
 	}
 
@@ -274,10 +342,17 @@ func (c *collection) subscribe() <-chan ChangeEvent {
 	ch := make(chan ChangeEvent, 100)
 	c.subscribers[id] = ch
 
-	return ch
+	return id, ch
 }
 
-func (c *collection) emitChange(event ChangeEvent) {
+// unsubscribe 移除订阅者。通道不会被关闭，以免与正在进行的 emitChange 发送冲突。
+func (c *collection) unsubscribe(id uint64) {
+	c.subscribersMu.Lock()
+	defer c.subscribersMu.Unlock()
+	delete(c.subscribers, id)
+}
+
+func (c *collection) emitChange(ctx context.Context, event ChangeEvent) {
 	// 注意：调用者应已持有锁或在释放锁后调用
 	// 使用 closeChan 来安全地检测关闭状态，避免死锁
 	select {
@@ -286,6 +361,13 @@ func (c *collection) emitChange(event ChangeEvent) {
 	default:
 	}
 
+	if event.Source == "" {
+		event.Source = ChangeSourceFromContext(ctx)
+	}
+
+	// 先失效查询缓存，保证写入返回后的查询能看到最新结果
+	c.invalidateQueryCaches(event)
+
 	// 向所有订阅者发送事件
 	c.subscribersMu.RLock()
 	subscribers := make([]chan ChangeEvent, 0, len(c.subscribers))
@@ -477,7 +559,7 @@ func (c *collection) isPrimaryKeyField(field string) bool {
 }
 
 // updateIndexesInTx 在现有事务中更新索引（用于批量操作优化）
-func (c *collection) updateIndexesInTx(txn *badger.Txn, doc map[string]any, docID string, isDelete bool) error {
+func (c *collection) updateIndexesInTx(txn kvTxn, doc map[string]any, docID string, isDelete bool) error {
 	if len(c.schema.Indexes) == 0 {
 		return nil
 	}
@@ -489,19 +571,14 @@ func (c *collection) updateIndexesInTx(txn *badger.Txn, doc map[string]any, docI
 		}
 		bucketName := fmt.Sprintf("%s_idx_%s", c.name, indexName)
 
-		// 构建索引键
-		indexKeyParts := make([]interface{}, 0, len(idx.Fields))
-		for _, field := range idx.Fields {
-			value := getNestedValue(doc, field)
-			indexKeyParts = append(indexKeyParts, value)
-		}
 		// 使用新的编码方式：{values}\0{docID}，避免序列化开销并支持前缀扫描
-		indexKey := bstore.BucketKey(bucketName, string(encodeIndexKey(indexKeyParts, docID)))
-
-		if isDelete {
-			_ = txn.Delete(indexKey)
-		} else {
-			_ = txn.Set(indexKey, nil)
+		for _, entryKey := range indexEntryKeys(idx, doc, docID) {
+			indexKey := bstore.BucketKey(bucketName, string(entryKey))
+			if isDelete {
+				_ = txn.Delete(indexKey)
+			} else {
+				_ = txn.Set(indexKey, nil)
+			}
 		}
 	}
 	return nil
@@ -513,27 +590,77 @@ func (c *collection) updateIndexes(ctx context.Context, doc map[string]any, docI
 		return nil
 	}
 
-	return c.store.WithUpdate(ctx, func(txn *badger.Txn) error {
+	return c.store.WithUpdate(ctx, func(txn kvTxn) error {
 		return c.updateIndexesInTx(txn, doc, docID, isDelete)
 	})
 }
 
 // getNestedValue 获取嵌套字段值（用于索引）。
 func getNestedValue(doc map[string]any, path string) any {
-	if !strings.Contains(path, ".") {
+	if !strings.Contains(path, ".") && !strings.Contains(path, "[") {
 		return doc[path]
 	}
+	return getNestedValueByParts(doc, splitQueryPath(path))
+}
+
+// splitQueryPath 将点号路径拆分为路径段。
+// 段末尾的 "[*]" 表示遍历数组（如 "orders[*].sku"），与隐式写法 "orders.sku" 等价。
+func splitQueryPath(path string) []string {
 	parts := strings.Split(path, ".")
-	return getNestedValueByParts(doc, parts)
+	for i, part := range parts {
+		parts[i] = strings.TrimSuffix(part, "[*]")
+	}
+	return parts
+}
+
+// getNestedValuesByParts 沿路径收集叶子值，路径中间遇到数组时展开到每个元素（隐式 $elemMatch）。
+// 第二个返回值表示是否发生了数组展开；未展开时应使用 getNestedValueByParts 的结果。
+func getNestedValuesByParts(doc map[string]any, parts []string) ([]any, bool) {
+	var values []any
+	fanned := false
+	var walk func(current any, parts []string)
+	walk = func(current any, parts []string) {
+		if len(parts) == 0 {
+			values = append(values, current)
+			return
+		}
+		switch node := current.(type) {
+		case map[string]any:
+			if v, ok := node[parts[0]]; ok {
+				walk(v, parts[1:])
+			}
+		case []any:
+			if i, err := strconv.Atoi(parts[0]); err == nil {
+				if i >= 0 && i < len(node) {
+					walk(node[i], parts[1:])
+				}
+				return
+			}
+			fanned = true
+			for _, elem := range node {
+				walk(elem, parts)
+			}
+		}
+	}
+	walk(doc, parts)
+	return values, fanned
 }
 
 // getNestedValueByParts 使用预拆分路径获取嵌套字段值（高性能版）。
+// 数字路径段可用于访问数组元素（如 "items.0.name"）。
 func getNestedValueByParts(doc map[string]any, parts []string) any {
 	var current any = doc
 	for _, part := range parts {
-		if m, ok := current.(map[string]any); ok {
-			current = m[part]
-		} else {
+		switch node := current.(type) {
+		case map[string]any:
+			current = node[part]
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			current = node[i]
+		default:
 			return nil
 		}
 	}
@@ -574,15 +701,54 @@ func (c *collection) nextRevision(oldRev string, doc map[string]any) (string, er
 
 // Insert 向集合中插入一个新文档。
 func (c *collection) Insert(ctx context.Context, doc map[string]any) (Document, error) {
+	result, err := c.insert(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	return c.transformDocument(ctx, result)
+}
+
+// InsertWithOptions 按 opts 插入新文档，冲突处理策略保存在文档的 _conflictResolution 字段中。
+func (c *collection) InsertWithOptions(ctx context.Context, doc map[string]any, opts DocumentOptions) (Document, error) {
+	if opts.ConflictResolution != "" {
+		switch opts.ConflictResolution {
+		case ConflictServerWins, ConflictClientWins, ConflictLastWriteWins, ConflictCustom:
+		default:
+			return nil, NewError(ErrorTypeValidation, fmt.Sprintf("unknown ConflictResolution strategy: %s", opts.ConflictResolution), nil)
+		}
+		withOpts := make(map[string]any, len(doc)+1)
+		for k, v := range doc {
+			withOpts[k] = v
+		}
+		withOpts[ConflictResolutionField] = opts.ConflictResolution
+		doc = withOpts
+	}
+	return c.Insert(ctx, doc)
+}
+
+// insert 执行插入，返回未经读取转换器处理的文档。
+func (c *collection) insert(ctx context.Context, doc map[string]any) (Document, error) {
 	if doc == nil {
 		return nil, errors.New("document cannot be nil")
 	}
+	if err := c.waitWriteTokens(ctx, 1); err != nil {
+		return nil, err
+	}
 
-	// 1. 无需锁的准备阶段：应用默认值和基础验证
+	// 1. 无需锁的准备阶段：调用 BeforeInsert 钩子、应用默认值和基础验证
+	hooks := c.schemaHooks()
+	doc, err := runBeforeInsert(ctx, hooks, doc)
+	if err != nil {
+		return nil, err
+	}
+	c.stripVirtualFields(doc)
 	ApplyDefaults(c.schema, doc)
-	if err := ValidateDocument(c.schema, doc); err != nil {
+	if err := c.validateDocument(doc); err != nil {
 		return nil, NewError(ErrorTypeValidation, "schema validation failed", err)
 	}
+	if err := c.runValidators(ctx, doc); err != nil {
+		return nil, err
+	}
 	if err := c.validatePrimaryKey(doc); err != nil {
 		return nil, err
 	}
@@ -605,7 +771,7 @@ func (c *collection) Insert(ctx context.Context, doc map[string]any) (Document,
 	}
 	defer c.endOp()
 
-	logrus.WithField("collection", c.name).Debug("Inserting document into collection")
+	c.logger.Debug("Inserting document into collection", "collection", c.name)
 
 	c.mu.Lock()
 	if c.closed {
@@ -636,48 +802,21 @@ func (c *collection) Insert(ctx context.Context, doc map[string]any) (Document,
 		return nil, fmt.Errorf("failed to generate revision: %w", err)
 	}
 	doc[c.schema.RevField] = rev
-	c.mu.Unlock()
-
-	// 3. 计算密集型操作：克隆、加密、压缩、序列化（在锁外进行）
-	docForStorage := DeepCloneMap(doc)
-
-	if len(c.schema.EncryptedFields) > 0 && c.password != "" {
-		if err := encryptDocumentFields(docForStorage, c.schema.EncryptedFields, c.password); err != nil {
-			return nil, fmt.Errorf("failed to encrypt fields: %w", err)
-		}
-	}
-
-	// 应用键压缩
-	docForStorage = c.compressDocument(docForStorage)
-
-	data, err := json.Marshal(docForStorage)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal document: %w", err)
-	}
-
-	// 4. 写入阶段：重新加锁执行存储写入和索引更新
-	c.mu.Lock()
-	if c.closed {
-		c.mu.Unlock()
-		return nil, errors.New("collection is closed")
-	}
 
-	// 原子写入：使用事务同时写入文档和更新索引
-	err = c.store.WithUpdate(ctx, func(txn *badger.Txn) error {
+	// 3. 写入阶段：原子写入文档和更新索引
+	err = c.updateWithChangelog(ctx, func(txn kvTxn, changes *changelogTxn) error {
 		// 检查文档是否已存在（由于是在事务内，这提供了真正的原子性保证）
-		key := bstore.BucketKey(c.name, idStr)
-		if _, err := txn.Get(key); err == nil {
+		if _, err := txn.Get(bstore.BucketKey(c.name, idStr)); err == nil {
 			return NewError(ErrorTypeAlreadyExists, fmt.Sprintf("document with id %s already exists", idStr), nil).
 				WithContext("document_id", idStr)
-		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+		} else if !errors.Is(err, ErrKeyNotFound) {
 			return err
 		}
 
-		if err := txn.Set(key, data); err != nil {
+		if err := c.writeDocInTx(txn, idStr, doc, nil); err != nil {
 			return err
 		}
-		// 更新索引
-		return c.updateIndexesInTx(txn, doc, idStr, false)
+		return changes.add(idStr, OperationInsert)
 	})
 
 	if err != nil {
@@ -705,19 +844,54 @@ func (c *collection) Insert(ctx context.Context, doc map[string]any) (Document,
 
 	c.mu.Unlock()
 
-	// 5. 后置处理：在释放锁后调用钩子和发送事件
+	// 4. 后置处理：在释放锁后调用钩子和发送事件
 	for _, hook := range c.postSave {
 		_ = hook(ctx, doc, nil)
 	}
 	for _, hook := range c.postInsert {
 		_ = hook(ctx, doc, nil)
 	}
-	c.emitChange(changeEvent)
+	c.runAfterWrite(ctx, hooks, idStr, doc, nil)
+	c.emitChange(ctx, changeEvent)
 
 	return result, nil
 }
 
+// Upsert 插入或整体替换文档。
 func (c *collection) Upsert(ctx context.Context, doc map[string]any) (Document, error) {
+	result, err := c.upsert(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	return c.transformDocument(ctx, result)
+}
+
+// prepareUpsert 按 snapshot 是否存在调用 BeforeUpdate 或 BeforeInsert 钩子，返回经过 Schema 验证的待写入文档。
+// 与 insert 一致，新文档在钩子之后、验证之前应用默认值。
+func (c *collection) prepareUpsert(ctx context.Context, hooks SchemaHooks, id string, snapshot, doc map[string]any) (map[string]any, error) {
+	if snapshot != nil && hooks.BeforeUpdate != nil {
+		// runBeforeUpdate 已验证钩子的结果
+		return c.runBeforeUpdate(ctx, hooks, id, snapshot, doc)
+	}
+	if snapshot == nil {
+		var err error
+		if doc, err = runBeforeInsert(ctx, hooks, doc); err != nil {
+			return nil, err
+		}
+		c.stripVirtualFields(doc)
+		ApplyDefaults(c.schema, doc)
+	}
+	if err := c.checkHookResult(id, doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// upsert 执行插入或替换，返回未经读取转换器处理的文档。
+func (c *collection) upsert(ctx context.Context, doc map[string]any) (Document, error) {
+	if err := c.waitWriteTokens(ctx, 1); err != nil {
+		return nil, err
+	}
 	if err := c.beginOp(ctx); err != nil {
 		return nil, err
 	}
@@ -728,12 +902,10 @@ func (c *collection) Upsert(ctx context.Context, doc map[string]any) (Document,
 		c.mu.Unlock()
 		return nil, errors.New("collection is closed")
 	}
+	hooks := c.hooks
 	c.mu.Unlock()
 
-	// Schema 验证
-	if err := ValidateDocument(c.schema, doc); err != nil {
-		return nil, fmt.Errorf("schema validation failed: %w", err)
-	}
+	c.stripVirtualFields(doc)
 
 	// 验证并提取主键
 	if err := c.validatePrimaryKey(doc); err != nil {
@@ -748,91 +920,86 @@ func (c *collection) Upsert(ctx context.Context, doc map[string]any) (Document,
 	// 在事务中读取文档、验证、计算 revision 和写入
 	var oldDoc map[string]any
 	var rev string
-	err = c.store.WithUpdate(ctx, func(txn *badger.Txn) error {
-		key := bstore.BucketKey(c.name, idStr)
+	input := doc
+	hooked := hooks.BeforeInsert != nil || hooks.BeforeUpdate != nil
+	for attempt := 1; ; attempt++ {
+		// 先在事务外读取现有文档，调用钩子、为新文档应用默认值并验证；事务中确认文档未被并发修改
+		// （未设置 Before* 钩子时只需确认文档是否存在没有变化）
+		var snapshot map[string]any
+		if snapshot, err = c.loadStoredDocument(ctx, idStr); err != nil {
+			return nil, err
+		}
+		if doc, err = c.prepareUpsert(ctx, hooks, idStr, snapshot, DeepCloneMap(input)); err != nil {
+			return nil, err
+		}
+		if err := c.runValidators(ctx, doc); err != nil {
+			return nil, err
+		}
 
-		// 读取现有文档（如果存在）
-		existingItem, err := txn.Get(key)
-		if err == nil {
-			// 文档存在
-			err = existingItem.Value(func(val []byte) error {
-				var existingDoc map[string]any
-				if err := json.Unmarshal(val, &existingDoc); err != nil {
+		err = c.updateWithChangelog(ctx, func(txn kvTxn, changes *changelogTxn) error {
+			key := bstore.BucketKey(c.name, idStr)
+
+			// 读取现有文档（如果存在）
+			oldDoc = nil
+			existing, err := txn.Get(key)
+			if err == nil {
+				if oldDoc, err = c.decodeStoredDocument(existing); err != nil {
 					return err
 				}
-				oldDoc = c.decompressDocument(existingDoc)
-				// 解密（如果需要）
-				if len(c.schema.EncryptedFields) > 0 && c.password != "" {
-					if err := decryptDocumentFields(oldDoc, c.schema.EncryptedFields, c.password); err != nil {
-						// 解密失败时继续
-					}
-				}
-				return nil
-			})
-			if err != nil {
+			} else if !errors.Is(err, ErrKeyNotFound) {
 				return err
 			}
+			if (oldDoc == nil) != (snapshot == nil) || (hooked && !c.sameRevision(oldDoc, snapshot)) {
+				return errHookStale
+			}
 
 			// 验证 final 字段
-			if err := ValidateFinalFields(c.schema, oldDoc, doc); err != nil {
-				return fmt.Errorf("final field validation failed: %w", err)
+			if oldDoc != nil {
+				if err := ValidateFinalFields(c.schema, oldDoc, doc); err != nil {
+					return fmt.Errorf("final field validation failed: %w", err)
+				}
 			}
-		} else if !errors.Is(err, badger.ErrKeyNotFound) {
-			return err
-		} else {
-			// 新文档应用默认值
-			ApplyDefaults(c.schema, doc)
-		}
 
-		// 调用 preSave 钩子
-		for _, hook := range c.preSave {
-			if err := hook(ctx, doc, oldDoc); err != nil {
-				return fmt.Errorf("preSave hook failed: %w", err)
+			// 调用 preSave 钩子
+			for _, hook := range c.preSave {
+				if err := hook(ctx, doc, oldDoc); err != nil {
+					return fmt.Errorf("preSave hook failed: %w", err)
+				}
 			}
-		}
 
-		// 获取当前 revision
-		var oldRev string
-		if oldDoc != nil {
-			if r, ok := oldDoc[c.schema.RevField]; ok {
-				oldRev = fmt.Sprintf("%v", r)
+			// 获取当前 revision
+			var oldRev string
+			if oldDoc != nil {
+				if r, ok := oldDoc[c.schema.RevField]; ok {
+					oldRev = fmt.Sprintf("%v", r)
+				}
 			}
-		}
-
-		// 计算新修订号
-		newRev, err := c.nextRevision(oldRev, doc)
-		if err != nil {
-			return fmt.Errorf("failed to generate revision: %w", err)
-		}
-		doc[c.schema.RevField] = newRev
-		rev = newRev
 
-		// 准备数据（加密、压缩、序列化）
-		docForStorage := DeepCloneMap(doc)
-		if len(c.schema.EncryptedFields) > 0 && c.password != "" {
-			if err := encryptDocumentFields(docForStorage, c.schema.EncryptedFields, c.password); err != nil {
-				return fmt.Errorf("failed to encrypt fields: %w", err)
+			// 计算新修订号
+			newRev, err := c.nextRevision(oldRev, doc)
+			if err != nil {
+				return fmt.Errorf("failed to generate revision: %w", err)
 			}
-		}
-		docForStorage = c.compressDocument(docForStorage)
-		data, err := json.Marshal(docForStorage)
-		if err != nil {
-			return fmt.Errorf("failed to marshal document: %w", err)
-		}
-
-		// 写入文档
-		if err := txn.Set(key, data); err != nil {
-			return err
-		}
+			doc[c.schema.RevField] = newRev
+			rev = newRev
 
-		// 更新索引（如果旧文档存在，先删除旧索引）
-		if oldDoc != nil {
-			if err := c.updateIndexesInTx(txn, oldDoc, idStr, true); err != nil {
+			// 写入文档并更新索引（如果旧文档存在，先删除旧索引）
+			if err := c.writeDocInTx(txn, idStr, doc, oldDoc); err != nil {
 				return err
 			}
+			op := OperationInsert
+			if oldDoc != nil {
+				op = OperationUpdate
+			}
+			return changes.add(idStr, op)
+		})
+		if !errors.Is(err, errHookStale) {
+			break
 		}
-		return c.updateIndexesInTx(txn, doc, idStr, false)
-	})
+		if attempt == maxHookAttempts {
+			return nil, hookConflictError()
+		}
+	}
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to upsert document and indexes: %w", err)
@@ -851,6 +1018,7 @@ func (c *collection) Upsert(ctx context.Context, doc map[string]any) (Document,
 			// 注意：postSave 钩子失败不会回滚，但会记录错误
 		}
 	}
+	c.runAfterWrite(ctx, hooks, idStr, doc, oldDoc)
 
 	// 准备变更事件
 	op := OperationInsert
@@ -866,7 +1034,7 @@ func (c *collection) Upsert(ctx context.Context, doc map[string]any) (Document,
 		Meta:       map[string]interface{}{"rev": rev},
 	}
 
-	c.emitChange(changeEvent)
+	c.emitChange(ctx, changeEvent)
 
 	return result, nil
 }
@@ -887,7 +1055,7 @@ func (c *collection) IncrementalUpsert(ctx context.Context, patch map[string]any
 	}
 
 	// 如果不存在则按 Upsert 新建
-	existing, err := c.FindByID(ctx, idStr)
+	existing, err := c.findByID(ctx, idStr)
 	if err != nil {
 		// 如果是未找到错误，则创建新文档
 		if IsNotFoundError(err) {
@@ -960,12 +1128,12 @@ func (c *collection) IncrementalUpsert(ctx context.Context, patch map[string]any
 	}); err != nil {
 		return nil, err
 	}
-	return existing, nil
+	return c.transformDocument(ctx, existing)
 }
 
 // IncrementalModify 对指定文档应用修改函数。
 func (c *collection) IncrementalModify(ctx context.Context, id string, modifier func(doc map[string]any) error) (Document, error) {
-	doc, err := c.FindByID(ctx, id)
+	doc, err := c.findByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -976,10 +1144,20 @@ func (c *collection) IncrementalModify(ctx context.Context, id string, modifier
 	if err := doc.AtomicUpdate(ctx, modifier); err != nil {
 		return nil, err
 	}
-	return doc, nil
+	return c.transformDocument(ctx, doc)
 }
 
+// FindByID 根据主键查找文档，不存在时返回 NotFound 错误。
 func (c *collection) FindByID(ctx context.Context, id string) (Document, error) {
+	doc, err := c.findByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return c.transformDocument(ctx, doc)
+}
+
+// findByID 根据主键读取存储中的原始文档，不应用读取转换器，供内部写路径使用。
+func (c *collection) findByID(ctx context.Context, id string) (Document, error) {
 	if err := c.beginOp(ctx); err != nil {
 		return nil, err
 	}
@@ -1022,6 +1200,62 @@ func (c *collection) FindByID(ctx context.Context, id string) (Document, error)
 	return acquireDocument(id, doc, c), nil
 }
 
+// FindByIDs 在同一个只读事务中批量按主键查找文档。
+// 返回结果与 ids 顺序一致，不存在的文档对应位置为 nil。
+func (c *collection) FindByIDs(ctx context.Context, ids []string) ([]Document, error) {
+	docs, err := c.findByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return c.transformDocuments(ctx, docs)
+}
+
+// findByIDs 批量读取原始文档，不应用读取转换器。
+func (c *collection) findByIDs(ctx context.Context, ids []string) ([]Document, error) {
+	if err := c.beginOp(ctx); err != nil {
+		return nil, err
+	}
+	defer c.endOp()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, errors.New("collection is closed")
+	}
+
+	docs := make([]Document, len(ids))
+	if len(ids) == 0 {
+		return docs, nil
+	}
+
+	err := c.store.WithView(ctx, func(txn kvTxn) error {
+		for i, id := range ids {
+			if !c.idBloomFilter.Test(id) {
+				continue
+			}
+			val, err := txn.Get(bstore.BucketKey(c.name, id))
+			if err != nil {
+				if errors.Is(err, ErrKeyNotFound) {
+					continue
+				}
+				return err
+			}
+			doc, err := c.decodeStoredDocument(val)
+			if err != nil {
+				return fmt.Errorf("failed to decode document %s: %w", id, err)
+			}
+			docs[i] = acquireDocument(id, doc, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
 func (c *collection) Remove(ctx context.Context, id string) error {
 	if err := c.beginOp(ctx); err != nil {
 		return err
@@ -1064,59 +1298,27 @@ func (c *collection) Remove(ctx context.Context, id string) error {
 		return NewError(ErrorTypeNotFound, fmt.Sprintf("document with id %s not found", id), nil)
 	}
 
-	// 调用 preRemove 钩子
+	// 调用 preRemove 与 BeforeDelete 钩子
 	for _, hook := range c.preRemove {
 		if err := hook(ctx, nil, oldDoc); err != nil {
 			c.mu.Unlock()
 			return fmt.Errorf("preRemove hook failed: %w", err)
 		}
 	}
-
-	// 获取附件列表，以便后续删除文件系统中的文件
-	var attachmentsToDelete []*Attachment
-	attachmentBucket := fmt.Sprintf("%s_attachments", c.name)
-	attachmentPrefix := fmt.Sprintf("%s_", id)
-	_ = c.store.Iterate(ctx, attachmentBucket, func(k, v []byte) error {
-		if strings.HasPrefix(string(k), attachmentPrefix) {
-			var att Attachment
-			if err := json.Unmarshal(v, &att); err == nil {
-				attachmentsToDelete = append(attachmentsToDelete, &att)
-			}
-		}
-		return nil
-	})
+	hooks := c.hooks
+	if err := runBeforeDelete(ctx, hooks, id); err != nil {
+		c.mu.Unlock()
+		return err
+	}
 
 	// 原子删除：在一个事务中删除文档、附件元数据和索引
-	err = c.store.WithUpdate(ctx, func(txn *badger.Txn) error {
-		// 1. 删除文档
-		docKey := bstore.BucketKey(c.name, id)
-		if err := txn.Delete(docKey); err != nil {
+	var attachmentsToDelete []*Attachment
+	err = c.updateWithChangelog(ctx, func(txn kvTxn, changes *changelogTxn) error {
+		var err error
+		if attachmentsToDelete, err = c.deleteDocumentInTx(txn, id, oldDoc); err != nil {
 			return err
 		}
-
-		// 2. 删除该文档的所有附件元数据
-		// 注意：Badger 不支持在迭代同一个事务时删除，所以先收集键
-		opts := badger.DefaultIteratorOptions
-		opts.Prefix = bstore.BucketPrefix(attachmentBucket)
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		var attachmentKeysToDelete [][]byte
-		prefix := bstore.BucketPrefix(attachmentBucket)
-		fullPrefix := append(prefix, []byte(attachmentPrefix)...)
-
-		for it.Seek(fullPrefix); it.ValidForPrefix(fullPrefix); it.Next() {
-			attachmentKeysToDelete = append(attachmentKeysToDelete, append([]byte{}, it.Item().Key()...))
-		}
-
-		for _, k := range attachmentKeysToDelete {
-			if err := txn.Delete(k); err != nil {
-				return err
-			}
-		}
-
-		// 3. 更新索引（删除索引条目）
-		return c.updateIndexesInTx(txn, oldDoc, id, true)
+		return changes.add(id, OperationDelete)
 	})
 
 	if err != nil {
@@ -1124,110 +1326,878 @@ func (c *collection) Remove(ctx context.Context, id string) error {
 		return fmt.Errorf("failed to atomically remove document, attachments and indexes: %w", err)
 	}
 
-	// 标记需要重建布隆过滤器
-	c.bloomNeedsRebuild = true
-
-	// 删除文件系统中的附件文件（在事务成功后进行，虽然无法完全保证原子性，但比之前好）
-	for _, att := range attachmentsToDelete {
-		filePath, err := c.getAttachmentFilePath(id, att.ID, att.Name)
-		if err == nil {
-			os.Remove(filePath)
-		}
-	}
-
-	// 调用 postRemove 钩子
-	for _, hook := range c.postRemove {
-		if err := hook(ctx, nil, oldDoc); err != nil {
-			// 注意：postRemove 钩子失败不会回滚，但会记录错误
-		}
-	}
-
-	// 在释放锁之前准备变更事件
-	changeEvent := ChangeEvent{
-		Collection: c.name,
-		ID:         id,
-		Op:         OperationDelete,
-		Doc:        nil,
-		Old:        oldDoc,
-		Meta:       nil,
-	}
+	changeEvent := c.afterRemove(ctx, id, oldDoc, attachmentsToDelete)
 
 	// 释放锁后再发送变更事件，避免死锁
 	c.mu.Unlock()
-	c.emitChange(changeEvent)
+	if hooks.AfterDelete != nil {
+		hooks.AfterDelete(ctx, id)
+	}
+	c.emitChange(ctx, changeEvent)
 
 	return nil
 }
 
-func (c *collection) All(ctx context.Context) ([]Document, error) {
+// FindOneAndDelete 在同一个事务中查找第一个匹配 selector 的文档（按主键顺序）并将其删除。
+// 返回被删除的文档；没有匹配的文档时返回 nil, nil。
+func (c *collection) FindOneAndDelete(ctx context.Context, selector map[string]any) (Document, error) {
+	q := c.Find(selector)
+
 	if err := c.beginOp(ctx); err != nil {
 		return nil, err
 	}
 	defer c.endOp()
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	hooks := c.schemaHooks()
+	hooked := hooks.BeforeDelete != nil
 
-	if c.closed {
-		return nil, errors.New("collection is closed")
-	}
+	var id string
+	var oldDoc map[string]any
+	var attachmentsToDelete []*Attachment
+	var err error
+	for attempt := 1; ; attempt++ {
+		// 设置了 BeforeDelete 钩子时，先在加锁和开启事务之前找到待删除的文档并调用钩子，
+		// 事务中确认命中的仍是同一版本的文档
+		var snapID string
+		var snapshot map[string]any
+		if hooked {
+			if snapID, snapshot, err = c.findFirstMatch(ctx, q); err != nil {
+				return nil, fmt.Errorf("failed to find and delete document: %w", err)
+			}
+			if snapshot != nil {
+				if err := runBeforeDelete(ctx, hooks, snapID); err != nil {
+					return nil, fmt.Errorf("failed to find and delete document: %w", err)
+				}
+			}
+		}
 
-	var docs []Document
-	err := c.store.Iterate(ctx, c.name, func(k, v []byte) error {
-		var doc map[string]any
-		if err := json.Unmarshal(v, &doc); err != nil {
-			return err
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return nil, errors.New("collection is closed")
 		}
-		// 解压缩
-		doc = c.decompressDocument(doc)
 
-		// 解密需要解密的字段
-		if len(c.schema.EncryptedFields) > 0 && c.password != "" {
-			if err := decryptDocumentFields(doc, c.schema.EncryptedFields, c.password); err != nil {
-				// 解密失败时，继续处理文档
+		err = c.updateWithChangelog(ctx, func(txn kvTxn, changes *changelogTxn) error {
+			// 查找第一个匹配的文档（迭代器在返回前关闭，之后才能在同一事务中删除）
+			var err error
+			if id, oldDoc, err = c.findFirstMatchInTx(txn, q); err != nil {
+				return err
+			}
+			if hooked && (id != snapID || !c.sameRevision(oldDoc, snapshot)) {
+				return errHookStale
+			}
+			if oldDoc == nil {
+				return nil
+			}
+
+			// 调用 preRemove 钩子
+			for _, hook := range c.preRemove {
+				if err := hook(ctx, nil, oldDoc); err != nil {
+					return fmt.Errorf("preRemove hook failed: %w", err)
+				}
+			}
+
+			if attachmentsToDelete, err = c.deleteDocumentInTx(txn, id, oldDoc); err != nil {
+				return err
+			}
+			return changes.add(id, OperationDelete)
+		})
+		if !errors.Is(err, errHookStale) || attempt == maxHookAttempts {
+			break
+		}
+		c.mu.Unlock()
+	}
+	if err != nil {
+		c.mu.Unlock()
+		if errors.Is(err, errHookStale) {
+			return nil, hookConflictError()
+		}
+		return nil, fmt.Errorf("failed to find and delete document: %w", err)
+	}
+	if oldDoc == nil {
+		c.mu.Unlock()
+		return nil, nil
+	}
+
+	changeEvent := c.afterRemove(ctx, id, oldDoc, attachmentsToDelete)
+
+	// 释放锁后再发送变更事件，避免死锁
+	c.mu.Unlock()
+	if hooks.AfterDelete != nil {
+		hooks.AfterDelete(ctx, id)
+	}
+	c.emitChange(ctx, changeEvent)
+
+	return c.transformDocument(ctx, acquireDocument(id, DeepCloneMap(oldDoc), c))
+}
+
+// FindAndModifyOptions FindAndModify 的选项。
+type FindAndModifyOptions struct {
+	ReturnNew bool // 返回更新后的文档，默认返回更新前的文档
+	Upsert    bool // 没有匹配的文档时，以 filter 中的等值条件为基础应用 update 并插入新文档
+}
+
+// FindAndModify 在同一个事务中查找第一个匹配 filter 的文档（按主键顺序）并应用 update。
+// update 可以是更新操作符（见 Document.Update）或普通字段（合并到文档，主键字段被忽略）。
+// 默认返回更新前的文档，opts.ReturnNew 为 true 时返回更新后的文档；没有匹配且未启用 Upsert 时返回 nil, nil。
+// Upsert 插入的文档与 Insert 一样获得第 1 代修订号，此时若未设置 ReturnNew 则返回 nil（插入前不存在文档）。
+func (c *collection) FindAndModify(ctx context.Context, filter, update map[string]any, opts FindAndModifyOptions) (Document, error) {
+	if len(update) == 0 {
+		return nil, NewError(ErrorTypeValidation, "update cannot be empty", nil)
+	}
+	useOperators := hasUpdateOperators(update)
+	q := c.Find(filter)
+
+	if err := c.waitWriteTokens(ctx, 1); err != nil {
+		return nil, err
+	}
+	if err := c.beginOp(ctx); err != nil {
+		return nil, err
+	}
+	defer c.endOp()
+
+	hooks := c.schemaHooks()
+	hooked := hooks.BeforeUpdate != nil || (opts.Upsert && hooks.BeforeInsert != nil)
+
+	applyUpdate := func(doc map[string]any) error {
+		if useOperators {
+			return applyUpdateOperators(doc, update, c.isPrimaryKeyField)
+		}
+		for k, v := range update {
+			if !c.isPrimaryKeyField(k) {
+				doc[k] = deepCloneValue(v)
+			}
+		}
+		return nil
+	}
+
+	// prepare 基于匹配到的文档（没有匹配时为 nil）计算待写入的文档并调用 Before* 钩子，无需写入时 newDoc 为 nil
+	prepare := func(id string, oldDoc map[string]any) (string, map[string]any, error) {
+		if oldDoc != nil {
+			newDoc := DeepCloneMap(oldDoc)
+			if err := applyUpdate(newDoc); err != nil {
+				return "", nil, err
+			}
+			newDoc, err := c.runBeforeUpdate(ctx, hooks, id, oldDoc, newDoc)
+			return id, newDoc, err
+		}
+		if !opts.Upsert {
+			return "", nil, nil
+		}
+		newDoc := selectorEqualities(filter)
+		if err := applyUpdate(newDoc); err != nil {
+			return "", nil, err
+		}
+		newDoc, err := runBeforeInsert(ctx, hooks, newDoc)
+		if err != nil {
+			return "", nil, err
+		}
+		c.stripVirtualFields(newDoc)
+		ApplyDefaults(c.schema, newDoc)
+		if err := c.validatePrimaryKey(newDoc); err != nil {
+			return "", nil, err
+		}
+		id, err = c.extractPrimaryKey(newDoc)
+		return id, newDoc, err
+	}
+
+	var id, rev string
+	var oldDoc, newDoc map[string]any
+	var err error
+	for attempt := 1; ; attempt++ {
+		// 设置了 Before* 钩子时，先在加锁和开启事务之前找到文档并调用钩子，事务中确认命中的仍是同一版本的文档
+		var snapID string
+		var snapshot, prepared map[string]any
+		if hooked {
+			if snapID, snapshot, err = c.findFirstMatch(ctx, q); err == nil {
+				snapID, prepared, err = prepare(snapID, snapshot)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to find and modify document: %w", err)
+			}
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return nil, errors.New("collection is closed")
+		}
+
+		err = c.updateWithChangelog(ctx, func(txn kvTxn, changes *changelogTxn) error {
+			// 查找第一个匹配的文档
+			matchID, match, err := c.findFirstMatchInTx(txn, q)
+			if err != nil {
+				return err
+			}
+			oldDoc = match
+			if hooked {
+				if !c.sameRevision(oldDoc, snapshot) || (oldDoc != nil && matchID != snapID) {
+					return errHookStale
+				}
+				id, newDoc = snapID, prepared
+			} else if id, newDoc, err = prepare(matchID, oldDoc); err != nil {
+				return err
+			}
+			if newDoc == nil {
+				return nil
+			}
+
+			var oldRev string
+			if oldDoc != nil {
+				if r, ok := oldDoc[c.schema.RevField]; ok {
+					oldRev = fmt.Sprintf("%v", r)
+				}
+			} else if _, err := txn.Get(bstore.BucketKey(c.name, id)); err == nil {
+				return NewError(ErrorTypeAlreadyExists, fmt.Sprintf("document with id %s already exists", id), nil).
+					WithContext("document_id", id)
+			} else if !errors.Is(err, ErrKeyNotFound) {
+				return err
+			}
+
+			if err := c.validateDocument(newDoc); err != nil {
+				return NewError(ErrorTypeValidation, "schema validation failed", err)
+			}
+			if oldDoc != nil {
+				if err := ValidateFinalFields(c.schema, oldDoc, newDoc); err != nil {
+					return fmt.Errorf("final field validation failed: %w", err)
+				}
+			}
+			if err := runValidatorList(ctx, c.validators, newDoc); err != nil {
+				return err
+			}
+
+			if oldDoc == nil {
+				for _, hook := range c.preInsert {
+					if err := hook(ctx, newDoc, nil); err != nil {
+						return fmt.Errorf("preInsert hook failed: %w", err)
+					}
+				}
+			}
+			for _, hook := range c.preSave {
+				if err := hook(ctx, newDoc, oldDoc); err != nil {
+					return fmt.Errorf("preSave hook failed: %w", err)
+				}
+			}
+
+			newRev, err := c.nextRevision(oldRev, newDoc)
+			if err != nil {
+				return fmt.Errorf("failed to generate revision: %w", err)
+			}
+			newDoc[c.schema.RevField] = newRev
+			rev = newRev
+
+			if err := c.writeDocInTx(txn, id, newDoc, oldDoc); err != nil {
+				return err
+			}
+			op := OperationInsert
+			if oldDoc != nil {
+				op = OperationUpdate
+			}
+			return changes.add(id, op)
+		})
+		if !errors.Is(err, errHookStale) || attempt == maxHookAttempts {
+			break
+		}
+		c.mu.Unlock()
+	}
+	if err != nil {
+		c.mu.Unlock()
+		if errors.Is(err, errHookStale) {
+			return nil, hookConflictError()
+		}
+		return nil, fmt.Errorf("failed to find and modify document: %w", err)
+	}
+	if newDoc == nil {
+		c.mu.Unlock()
+		return nil, nil
+	}
+
+	c.idBloomFilter.Add(id)
+	op := OperationUpdate
+	if oldDoc == nil {
+		op = OperationInsert
+	}
+	changeEvent := ChangeEvent{
+		Collection: c.name,
+		ID:         id,
+		Op:         op,
+		Doc:        newDoc,
+		Old:        oldDoc,
+		Meta:       map[string]interface{}{"rev": rev},
+	}
+
+	// 释放锁后再调用后置钩子和发送变更事件，避免死锁
+	c.mu.Unlock()
+	for _, hook := range c.postSave {
+		_ = hook(ctx, newDoc, oldDoc)
+	}
+	if oldDoc == nil {
+		for _, hook := range c.postInsert {
+			_ = hook(ctx, newDoc, nil)
+		}
+	}
+	c.runAfterWrite(ctx, hooks, id, newDoc, oldDoc)
+	c.emitChange(ctx, changeEvent)
+
+	if opts.ReturnNew {
+		return c.transformDocument(ctx, acquireDocument(id, DeepCloneMap(newDoc), c))
+	}
+	if oldDoc == nil {
+		return nil, nil
+	}
+	return c.transformDocument(ctx, acquireDocument(id, DeepCloneMap(oldDoc), c))
+}
+
+// FindOrCreate 查找第一个匹配 selector 的文档，不存在时以 defaults 合并 selector 中的等值条件插入新文档。
+// 返回的 bool 表示文档是否为本次新建。同一集合上的 FindOrCreate 调用串行执行，
+// 因此并发调用相同的 selector 只会创建一次；若其他写入抢先插入了相同主键的文档，则返回该文档。
+func (c *collection) FindOrCreate(ctx context.Context, selector, defaults map[string]any) (Document, bool, error) {
+	c.findOrCreateMu.Lock()
+	defer c.findOrCreateMu.Unlock()
+
+	existing, err := c.FindOne(ctx, selector)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+
+	doc := DeepCloneMap(defaults)
+	if doc == nil {
+		doc = make(map[string]any)
+	}
+	for field, value := range selectorEqualities(selector) {
+		doc[field] = value
+	}
+
+	created, err := c.Insert(ctx, doc)
+	if err != nil {
+		if IsAlreadyExistsError(err) {
+			existing, findErr := c.FindOne(ctx, selector)
+			if findErr == nil && existing != nil {
+				return existing, false, nil
+			}
+		}
+		return nil, false, err
+	}
+	return created, true, nil
+}
+
+// selectorEqualities 返回选择器中可以确定字段值的等值条件（字段: 值 或 字段: {"$eq": 值}），用于按条件新建文档。
+func selectorEqualities(selector map[string]any) map[string]any {
+	doc := make(map[string]any)
+	for field, cond := range selector {
+		if strings.HasPrefix(field, "$") {
+			continue
+		}
+		if ops, ok := cond.(map[string]any); ok {
+			// 只有 $eq 条件可以确定字段值
+			value, ok := ops["$eq"]
+			if !ok {
+				continue
+			}
+			cond = value
+		}
+		doc[field] = deepCloneValue(cond)
+	}
+	return doc
+}
+
+// deleteDocumentInTx 在事务中删除文档、附件元数据和索引条目，返回被删除的附件元数据。
+func (c *collection) deleteDocumentInTx(txn kvTxn, id string, oldDoc map[string]any) ([]*Attachment, error) {
+	// 1. 删除文档
+	docKey := bstore.BucketKey(c.name, id)
+	if err := txn.Delete(docKey); err != nil {
+		return nil, err
+	}
+
+	// 2. 删除该文档的所有附件元数据
+	// 注意：迭代期间不能在同一事务中删除，所以先收集键
+	attachmentBucket := fmt.Sprintf("%s_attachments", c.name)
+	fullPrefix := bstore.BucketKey(attachmentBucket, id+"_")
+	it := txn.Scan(fullPrefix)
+
+	var attachments []*Attachment
+	var attachmentKeysToDelete [][]byte
+	for it.Next() {
+		attachmentKeysToDelete = append(attachmentKeysToDelete, append([]byte(nil), it.Key()...))
+		var att Attachment
+		if err := json.Unmarshal(it.Value(), &att); err == nil {
+			attachments = append(attachments, &att)
+		}
+	}
+	err := it.Err()
+	it.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range attachmentKeysToDelete {
+		if err := txn.Delete(k); err != nil {
+			return nil, err
+		}
+	}
+
+	// 3. 更新索引（删除索引条目）
+	return attachments, c.updateIndexesInTx(txn, oldDoc, id, true)
+}
+
+// afterRemove 在删除事务提交后清理附件文件、调用 postRemove 钩子并返回变更事件。
+// 注意：调用者应已持有锁
+func (c *collection) afterRemove(ctx context.Context, id string, oldDoc map[string]any, attachments []*Attachment) ChangeEvent {
+	// 标记需要重建布隆过滤器
+	c.bloomNeedsRebuild = true
+
+	// 删除文件系统中的附件文件（在事务成功后进行，虽然无法完全保证原子性，但比之前好）
+	for _, att := range attachments {
+		filePath, err := c.getAttachmentFilePath(id, att.ID, att.Name)
+		if err == nil {
+			os.Remove(filePath)
+		}
+	}
+
+	// 调用 postRemove 钩子
+	for _, hook := range c.postRemove {
+		if err := hook(ctx, nil, oldDoc); err != nil {
+			// 注意：postRemove 钩子失败不会回滚，但会记录错误
+		}
+	}
+
+	return ChangeEvent{
+		Collection: c.name,
+		ID:         id,
+		Op:         OperationDelete,
+		Doc:        nil,
+		Old:        oldDoc,
+		Meta:       nil,
+	}
+}
+
+func (c *collection) All(ctx context.Context) ([]Document, error) {
+	docs, err := c.all(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.transformDocuments(ctx, docs)
+}
+
+// all 返回集合中的全部原始文档，不应用读取转换器。
+func (c *collection) all(ctx context.Context) ([]Document, error) {
+	if err := c.beginOp(ctx); err != nil {
+		return nil, err
+	}
+	defer c.endOp()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, errors.New("collection is closed")
+	}
+
+	var docs []Document
+	err := c.store.Iterate(ctx, c.name, func(k, v []byte) error {
+		doc, err := c.decodeStoredDocument(v)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, acquireDocument(string(k), doc, c))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// decodeStoredDocument 将存储中的原始数据解码为文档（解压缩并解密字段）。
+func (c *collection) decodeStoredDocument(v []byte) (map[string]any, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(v, &doc); err != nil {
+		return nil, err
+	}
+	// 解压缩
+	doc = c.decompressDocument(doc)
+
+	// 解密需要解密的字段
+	if len(c.schema.EncryptedFields) > 0 && c.password != "" {
+		if err := decryptDocumentFields(doc, c.schema.EncryptedFields, c.password); err != nil {
+			// 解密失败时，继续处理文档
+		}
+	}
+	return doc, nil
+}
+
+// loadStoredDocument 在事务外读取并解码文档，文档不存在时返回 nil。
+func (c *collection) loadStoredDocument(ctx context.Context, id string) (map[string]any, error) {
+	data, err := c.store.Get(ctx, c.name, id)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return c.decodeStoredDocument(data)
+}
+
+// findFirstMatch 在只读事务中按主键顺序查找第一个匹配 q 的文档，没有匹配时 doc 为 nil。
+func (c *collection) findFirstMatch(ctx context.Context, q *Query) (id string, doc map[string]any, err error) {
+	err = c.store.WithView(ctx, func(txn kvTxn) error {
+		id, doc, err = c.findFirstMatchInTx(txn, q)
+		return err
+	})
+	return id, doc, err
+}
+
+// findFirstMatchInTx 在事务中按主键顺序查找第一个匹配 q 的文档，没有匹配时 doc 为 nil。
+// 返回前迭代器已关闭，调用方可以在同一事务中继续写入。
+func (c *collection) findFirstMatchInTx(txn kvTxn, q *Query) (id string, doc map[string]any, err error) {
+	prefix := bstore.BucketPrefix(c.name)
+	it := txn.Scan(prefix)
+	defer it.Close()
+
+	for it.Next() {
+		d, err := c.decodeStoredDocument(it.Value())
+		if err != nil {
+			return "", nil, err
+		}
+		if q.match(d) {
+			return string(it.Key()[len(prefix):]), d, nil
+		}
+	}
+	return "", nil, it.Err()
+}
+
+// writeDocInTx 在事务中写入文档：加密、键压缩、序列化并检查大小后写入存储，再更新索引。
+// oldDoc 非 nil 时先删除旧文档的索引项。doc 本身不会被修改。
+func (c *collection) writeDocInTx(txn kvTxn, id string, doc, oldDoc map[string]any) error {
+	docForStorage := DeepCloneMap(doc)
+	if len(c.schema.EncryptedFields) > 0 && c.password != "" {
+		if err := encryptDocumentFields(docForStorage, c.schema.EncryptedFields, c.password); err != nil {
+			return fmt.Errorf("failed to encrypt fields: %w", err)
+		}
+	}
+	docForStorage = c.compressDocument(docForStorage)
+	data, err := json.Marshal(docForStorage)
+	if err != nil {
+		return fmt.Errorf("failed to marshal document: %w", err)
+	}
+	if err := c.checkDocumentSize(id, data); err != nil {
+		return err
+	}
+
+	if err := txn.Set(bstore.BucketKey(c.name, id), data); err != nil {
+		return err
+	}
+	if oldDoc != nil {
+		if err := c.updateIndexesInTx(txn, oldDoc, id, true); err != nil {
+			return err
+		}
+	}
+	return c.updateIndexesInTx(txn, doc, id, false)
+}
+
+// iterateBatches 按 batchSize 分批扫描集合中的所有文档。
+// 扫描基于开始时刻的快照，回调返回后该批文档即可被回收，避免全量加载大集合。
+func (c *collection) iterateBatches(ctx context.Context, batchSize int, fn func(docs []Document) error) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+	if err := c.beginOp(ctx); err != nil {
+		return err
+	}
+	defer c.endOp()
+
+	c.mu.RLock()
+	if c.closed {
+		c.mu.RUnlock()
+		return errors.New("collection is closed")
+	}
+	prefix := bstore.BucketPrefix(c.name)
+	it, err := c.store.Scan(ctx, prefix)
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for {
+		docs := make([]Document, 0, batchSize)
+		for len(docs) < batchSize && it.Next() {
+			doc, err := c.decodeStoredDocument(it.Value())
+			if err != nil {
+				return err
+			}
+			docs = append(docs, acquireDocument(string(it.Key()[len(prefix):]), doc, c))
+		}
+		if err := it.Err(); err != nil {
+			return err
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+		if err := fn(docs); err != nil {
+			return err
+		}
+		if len(docs) < batchSize {
+			return nil
+		}
+	}
+}
+
+// Count 返回集合中的文档总数。
+func (c *collection) Count(ctx context.Context) (int, error) {
+	if err := c.beginOp(ctx); err != nil {
+		return 0, err
+	}
+	defer c.endOp()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return 0, errors.New("collection is closed")
+	}
+
+	var count int
+	err := c.store.Iterate(ctx, c.name, func(k, v []byte) error {
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// MapReduce 对集合中的所有文档执行 map-reduce 聚合。
+// map 阶段按文档并行执行，同一个键的值按文档遍历顺序传给 reduce。
+func (c *collection) MapReduce(ctx context.Context, mapFn MapFunc, reduceFn ReduceFunc, opts MapReduceOptions) (map[string]any, error) {
+	if mapFn == nil || reduceFn == nil {
+		return nil, NewError(ErrorTypeValidation, "map and reduce functions are required", nil)
+	}
+
+	docs, err := c.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	numWorkers := opts.NumWorkers
+	if numWorkers <= 0 {
+		numWorkers = runtime.NumCPU()
+	}
+
+	// 1. map 阶段：每个文档的输出写入对应下标，保证分组顺序稳定
+	emitted := make([][]KeyValue, len(docs))
+	mapWorkers := numWorkers
+	if mapWorkers > len(docs) {
+		mapWorkers = len(docs)
+	}
+	var wg sync.WaitGroup
+	wg.Add(mapWorkers)
+	for i := 0; i < mapWorkers; i++ {
+		go func(workerID int) {
+			defer wg.Done()
+			for j := workerID; j < len(docs); j += mapWorkers {
+				if ctx.Err() != nil {
+					return
+				}
+				emitted[j] = mapFn(docs[j])
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 2. shuffle 阶段：按键分组
+	grouped := make(map[string][]any)
+	var keys []string
+	for _, kvs := range emitted {
+		for _, kv := range kvs {
+			if _, ok := grouped[kv.Key]; !ok {
+				keys = append(keys, kv.Key)
+			}
+			grouped[kv.Key] = append(grouped[kv.Key], kv.Value)
+		}
+	}
+
+	// 3. reduce 阶段：按键并行执行
+	reduced := make([]any, len(keys))
+	reduceWorkers := numWorkers
+	if reduceWorkers > len(keys) {
+		reduceWorkers = len(keys)
+	}
+	wg.Add(reduceWorkers)
+	for i := 0; i < reduceWorkers; i++ {
+		go func(workerID int) {
+			defer wg.Done()
+			for j := workerID; j < len(keys); j += reduceWorkers {
+				reduced[j] = reduceFn(keys[j], grouped[keys[j]])
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	result := make(map[string]any, len(keys))
+	for i, key := range keys {
+		result[key] = reduced[i]
+	}
+	return result, nil
+}
+
+// Max 返回匹配 selector 的文档中 field 字段的最大值，没有匹配文档时返回 nil, nil。
+// 数值按大小比较，字符串按字典序比较。值取自经过读取转换器处理后的文档；
+// 未设置读取转换器、selector 为空且 field 是某个索引的首字段时直接扫描索引键。
+func (c *collection) Max(ctx context.Context, field string, selector map[string]any) (any, error) {
+	return c.extremum(ctx, field, selector, 1)
+}
+
+// Min 返回匹配 selector 的文档中 field 字段的最小值，没有匹配文档时返回 nil, nil。
+func (c *collection) Min(ctx context.Context, field string, selector map[string]any) (any, error) {
+	return c.extremum(ctx, field, selector, -1)
+}
+
+// extremum 计算字段的最值，sign 为 1 时取最大值，为 -1 时取最小值。缺少该字段的文档会被忽略。
+func (c *collection) extremum(ctx context.Context, field string, selector map[string]any, sign int) (any, error) {
+	var best any
+	consider := func(v any) {
+		if v == nil {
+			return
+		}
+		if best == nil || compareValues(v, best)*sign > 0 {
+			best = v
+		}
+	}
+
+	c.mu.RLock()
+	transformed := c.readTransformer != nil
+	c.mu.RUnlock()
+
+	// 索引中是存储的原始值，设置了读取转换器时需要加载文档
+	if len(selector) == 0 && !transformed {
+		if bucketName, ok := c.indexBucketForField(field); ok {
+			// 索引键格式为 {values}\0{docID}，值直接从键中解析，无需加载文档
+			rawPrefix := bstore.BucketPrefix(bucketName)
+			err := c.store.IterateRawPrefix(ctx, rawPrefix, func(key, value []byte) error {
+				sep := bytes.LastIndexByte(key, 0x00)
+				if sep < 0 {
+					return nil
+				}
+				var values []any
+				if err := json.Unmarshal(key[:sep], &values); err != nil {
+					return err
+				}
+				if len(values) > 0 {
+					consider(values[0])
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
 			}
+			return best, nil
 		}
-		docs = append(docs, acquireDocument(string(k), doc, c))
-		return nil
-	})
+	}
+
+	docs, err := c.Find(selector).Exec(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	return docs, nil
-}
-
-// Count 返回集合中的文档总数。
-func (c *collection) Count(ctx context.Context) (int, error) {
-	if err := c.beginOp(ctx); err != nil {
-		return 0, err
+	for _, doc := range docs {
+		consider(getNestedValue(doc.Data(), field))
 	}
-	defer c.endOp()
+	return best, nil
+}
 
+// indexBucketForField 返回以 field 为首字段的索引所在的 bucket。
+// 加密字段的索引值不可比较，此时返回 false。
+func (c *collection) indexBucketForField(field string) (string, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	if c.closed {
-		return 0, errors.New("collection is closed")
+	for _, encrypted := range c.schema.EncryptedFields {
+		if encrypted == field {
+			return "", false
+		}
+	}
+	for _, idx := range c.schema.Indexes {
+		if len(idx.Fields) == 0 || idx.Fields[0] != field || idx.Type != "" {
+			continue
+		}
+		indexName := idx.Name
+		if indexName == "" {
+			indexName = strings.Join(idx.Fields, "_")
+		}
+		return fmt.Sprintf("%s_idx_%s", c.name, indexName), true
+	}
+	return "", false
+}
+
+// CountBy 按字段值统计文档数量，等价于 GROUP BY field COUNT(*)。
+// field 支持点号分隔的嵌套路径（如 "address.city"），字段值按 fmt.Sprint 转换为键，
+// 缺少该字段或值为 null 的文档不计入结果。
+func (c *collection) CountBy(ctx context.Context, field string) (map[string]int, error) {
+	reduced, err := c.MapReduce(ctx,
+		func(doc Document) []KeyValue {
+			value := getNestedValue(doc.Data(), field)
+			if value == nil {
+				return nil
+			}
+			return []KeyValue{{Key: fmt.Sprint(value), Value: 1}}
+		},
+		func(key string, values []any) any {
+			count := 0
+			for _, v := range values {
+				count += v.(int)
+			}
+			return count
+		},
+		MapReduceOptions{},
+	)
+	if err != nil {
+		return nil, err
 	}
 
-	var count int
-	err := c.store.Iterate(ctx, c.name, func(k, v []byte) error {
-		count++
-		return nil
-	})
-	return count, err
+	counts := make(map[string]int, len(reduced))
+	for key, value := range reduced {
+		counts[key] = value.(int)
+	}
+	return counts, nil
 }
 
 // BulkInsert 批量插入文档。
 func (c *collection) BulkInsert(ctx context.Context, docs []map[string]any) ([]Document, error) {
-	logrus.WithFields(logrus.Fields{
-		"collection": c.name,
-		"count":      len(docs),
-	}).Debug("Bulk inserting documents")
+	results, _, err := c.bulkInsert(ctx, docs, false)
+	if err != nil {
+		return nil, err
+	}
+	return c.transformDocuments(ctx, results)
+}
+
+// bulkInsert 执行批量插入，返回未经读取转换器处理的文档。
+// skipExisting 为 true 时在写入事务中跳过已存在的文档并返回它们在 docs 中的位置，否则整批回滚。
+func (c *collection) bulkInsert(ctx context.Context, docs []map[string]any, skipExisting bool) ([]Document, []int, error) {
+	c.logger.Debug("Bulk inserting documents", "collection", c.name, "count", len(docs))
 
 	if len(docs) == 0 {
-		return []Document{}, nil
+		return []Document{}, nil, nil
+	}
+	if err := c.waitWriteTokens(ctx, len(docs)); err != nil {
+		return nil, nil, err
+	}
+
+	// 0. 按顺序调用 BeforeInsert 钩子（不修改调用方的切片）
+	hooks := c.schemaHooks()
+	if hooks.BeforeInsert != nil {
+		hooked := make([]map[string]any, len(docs))
+		for i, doc := range docs {
+			if doc == nil {
+				return nil, nil, NewError(ErrorTypeValidation, "document cannot be nil", nil)
+			}
+			var err error
+			if hooked[i], err = runBeforeInsert(ctx, hooks, doc); err != nil {
+				return nil, nil, err
+			}
+		}
+		docs = hooked
 	}
 
 	// 1. 并发预处理阶段 (锁外进行)：应用默认值、验证、提取主键、生成修订号
@@ -1251,11 +2221,16 @@ func (c *collection) BulkInsert(ctx context.Context, docs []map[string]any) ([]D
 			defer wg.Done()
 			for j := workerID; j < len(docs); j += numWorkers {
 				doc := docs[j]
+				c.stripVirtualFields(doc)
 				ApplyDefaults(c.schema, doc)
-				if err := ValidateDocument(c.schema, doc); err != nil {
+				if err := c.validateDocument(doc); err != nil {
 					preppedResults[j].err = NewError(ErrorTypeValidation, "schema validation failed", err)
 					continue
 				}
+				if err := c.runValidators(ctx, doc); err != nil {
+					preppedResults[j].err = err
+					continue
+				}
 				if err := c.validatePrimaryKey(doc); err != nil {
 					preppedResults[j].err = NewError(ErrorTypeValidation, "primary key validation failed", err)
 					continue
@@ -1281,14 +2256,14 @@ func (c *collection) BulkInsert(ctx context.Context, docs []map[string]any) ([]D
 	// 检查预处理错误
 	for _, res := range preppedResults {
 		if res.err != nil {
-			return nil, res.err
+			return nil, nil, res.err
 		}
 	}
 
 	c.mu.Lock()
 	if c.closed {
 		c.mu.Unlock()
-		return nil, NewError(ErrorTypeClosed, "collection is closed", nil)
+		return nil, nil, NewError(ErrorTypeClosed, "collection is closed", nil)
 	}
 
 	// 2. 调用钩子（锁内进行，保证钩子执行的顺序性和安全性）
@@ -1296,7 +2271,7 @@ func (c *collection) BulkInsert(ctx context.Context, docs []map[string]any) ([]D
 		for _, hook := range c.preInsert {
 			if err := hook(ctx, res.doc, nil); err != nil {
 				c.mu.Unlock()
-				return nil, NewError(ErrorTypeValidation, "preInsert hook failed", err)
+				return nil, nil, NewError(ErrorTypeValidation, "preInsert hook failed", err)
 			}
 		}
 	}
@@ -1330,6 +2305,10 @@ func (c *collection) BulkInsert(ctx context.Context, docs []map[string]any) ([]D
 					writeResults[j].err = NewError(ErrorTypeIO, fmt.Sprintf("failed to marshal document %s", res.idStr), err)
 					continue
 				}
+				if err := c.checkDocumentSize(res.idStr, data); err != nil {
+					writeResults[j].err = err
+					continue
+				}
 				writeResults[j] = writeResult{idStr: res.idStr, data: data, doc: res.doc}
 			}
 		}(i)
@@ -1338,24 +2317,37 @@ func (c *collection) BulkInsert(ctx context.Context, docs []map[string]any) ([]D
 
 	for _, res := range writeResults {
 		if res.err != nil {
-			return nil, res.err
+			return nil, nil, res.err
 		}
 	}
 
-	// 4. 存储写入阶段 (使用 Badger 事务保证原子性)
-	err := c.store.WithUpdate(ctx, func(txn *badger.Txn) error {
-		// 批量检查和写入
-		for _, item := range writeResults {
+	// 4. 存储写入阶段：持有集合锁，在同一个事务中检查文档是否存在并写入
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, nil, NewError(ErrorTypeClosed, "collection is closed", nil)
+	}
+	var skipped []int
+	err := c.updateWithChangelog(ctx, func(txn kvTxn, changes *changelogTxn) error {
+		skipped = skipped[:0]
+		for i, item := range writeResults {
 			key := bstore.BucketKey(c.name, item.idStr)
-			if c.idBloomFilter.Test(item.idStr) {
-				if _, err := txn.Get(key); err == nil {
-					return NewError(ErrorTypeAlreadyExists, fmt.Sprintf("document with id %s already exists", item.idStr), nil).
-						WithContext("document_id", item.idStr)
+			if _, err := txn.Get(key); err == nil {
+				if skipExisting {
+					skipped = append(skipped, i)
+					continue
 				}
+				return NewError(ErrorTypeAlreadyExists, fmt.Sprintf("document with id %s already exists", item.idStr), nil).
+					WithContext("document_id", item.idStr)
+			} else if !errors.Is(err, ErrKeyNotFound) {
+				return NewError(ErrorTypeIO, fmt.Sprintf("failed to check document %s", item.idStr), err)
 			}
 			if err := txn.Set(key, item.data); err != nil {
 				return NewError(ErrorTypeIO, fmt.Sprintf("failed to write document %s", item.idStr), err)
 			}
+			if err := changes.add(item.idStr, OperationInsert); err != nil {
+				return err
+			}
 			// 批量更新索引
 			if err := c.updateIndexesInTx(txn, item.doc, item.idStr, false); err != nil {
 				return NewError(ErrorTypeIndex, fmt.Sprintf("failed to update indexes for document %s", item.idStr), err)
@@ -1364,13 +2356,27 @@ func (c *collection) BulkInsert(ctx context.Context, docs []map[string]any) ([]D
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		c.mu.Unlock()
+		return nil, nil, err
+	}
+	if len(skipped) > 0 {
+		written := make([]writeResult, 0, len(writeResults)-len(skipped))
+		next := 0
+		for i, item := range writeResults {
+			if next < len(skipped) && skipped[next] == i {
+				next++
+				continue
+			}
+			written = append(written, item)
+		}
+		writeResults = written
 	}
 
 	// 更新布隆过滤器
 	for _, item := range writeResults {
 		c.idBloomFilter.Add(item.idStr)
 	}
+	c.mu.Unlock()
 
 	// 5. 准备返回结果和变更事件
 	result := make([]Document, len(writeResults))
@@ -1390,21 +2396,114 @@ func (c *collection) BulkInsert(ctx context.Context, docs []map[string]any) ([]D
 
 	// 6. 后置处理 (锁外发送事件)
 	for _, event := range changeEvents {
-		c.emitChange(event)
+		c.emitChange(ctx, event)
+	}
+	for _, res := range writeResults {
+		c.runAfterWrite(ctx, hooks, res.idStr, res.doc, nil)
 	}
 
-	logrus.WithFields(logrus.Fields{
-		"collection": c.name,
-		"count":      len(result),
-	}).Info("Bulk insert completed")
-	return result, nil
+	c.logger.Info("Bulk insert completed", "collection", c.name, "count", len(result))
+	return result, skipped, nil
+}
+
+// BulkInsertWithOptions 按 opts.OnConflict 策略批量插入文档。
+// error 策略遇到已存在的 ID 时整批回滚，只返回描述冲突文档的 BulkInsertError 作为整体错误；
+// skip 策略在写入事务中跳过已存在的文档（以及批内重复的 ID），逐文档的冲突信息通过第二个返回值返回；
+// 其他失败只通过整体错误返回，此时不返回逐文档错误。
+// skip 策略下被跳过的文档同样会经过钩子与验证，验证失败时整批回滚。
+func (c *collection) BulkInsertWithOptions(ctx context.Context, docs []map[string]any, opts BulkInsertOptions) ([]Document, []BulkInsertError, error) {
+	switch opts.OnConflict {
+	case "", BulkInsertOnConflictError:
+		results, _, err := c.bulkInsert(ctx, docs, false)
+		if err != nil {
+			var rxErr *RxDBError
+			if errors.As(err, &rxErr) && rxErr.Type == ErrorTypeAlreadyExists {
+				id, _ := rxErr.Context["document_id"].(string)
+				return nil, nil, BulkInsertError{Index: c.docIndexByID(docs, id), ID: id, Err: err}
+			}
+			return nil, nil, err
+		}
+		results, err = c.transformDocuments(ctx, results)
+		if err != nil {
+			return nil, nil, err
+		}
+		return results, nil, nil
+
+	case BulkInsertOnConflictReplace:
+		results, err := c.BulkUpsert(ctx, docs)
+		if err != nil {
+			return nil, nil, err
+		}
+		return results, nil, nil
+
+	case BulkInsertOnConflictSkip:
+		var conflicts []BulkInsertError
+		toInsert := make([]map[string]any, 0, len(docs))
+		positions := make([]int, 0, len(docs)) // toInsert 中文档在 docs 中的位置
+		seen := make(map[string]struct{}, len(docs))
+		for i, doc := range docs {
+			if id, err := c.extractPrimaryKey(doc); err == nil {
+				if _, dup := seen[id]; dup {
+					conflicts = append(conflicts, BulkInsertError{Index: i, ID: id,
+						Err: NewError(ErrorTypeAlreadyExists, fmt.Sprintf("duplicate id %s in batch", id), nil).WithContext("document_id", id)})
+					continue
+				}
+				seen[id] = struct{}{}
+			}
+			// 主键缺失等问题交给 bulkInsert 统一报告
+			toInsert = append(toInsert, doc)
+			positions = append(positions, i)
+		}
+
+		results, skipped, err := c.bulkInsert(ctx, toInsert, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, j := range skipped {
+			id, _ := c.extractPrimaryKey(toInsert[j])
+			conflicts = append(conflicts, BulkInsertError{Index: positions[j], ID: id,
+				Err: NewError(ErrorTypeAlreadyExists, fmt.Sprintf("document with id %s already exists", id), nil).WithContext("document_id", id)})
+		}
+		sort.Slice(conflicts, func(a, b int) bool { return conflicts[a].Index < conflicts[b].Index })
+
+		results, err = c.transformDocuments(ctx, results)
+		if err != nil {
+			return nil, nil, err
+		}
+		return results, conflicts, nil
+
+	default:
+		return nil, nil, NewError(ErrorTypeValidation, fmt.Sprintf("unknown OnConflict strategy: %s", opts.OnConflict), nil)
+	}
+}
+
+// docIndexByID 返回主键为 id 的文档在 docs 中的位置，未找到时返回 -1。
+func (c *collection) docIndexByID(docs []map[string]any, id string) int {
+	for i, doc := range docs {
+		if docID, err := c.extractPrimaryKey(doc); err == nil && docID == id {
+			return i
+		}
+	}
+	return -1
 }
 
 // BulkUpsert 批量更新或插入文档。
 func (c *collection) BulkUpsert(ctx context.Context, docs []map[string]any) ([]Document, error) {
+	results, err := c.bulkUpsert(ctx, docs)
+	if err != nil {
+		return nil, err
+	}
+	return c.transformDocuments(ctx, results)
+}
+
+// bulkUpsert 执行批量写入，返回未经读取转换器处理的文档。
+func (c *collection) bulkUpsert(ctx context.Context, docs []map[string]any) ([]Document, error) {
 	if len(docs) == 0 {
 		return []Document{}, nil
 	}
+	if err := c.waitWriteTokens(ctx, len(docs)); err != nil {
+		return nil, err
+	}
 
 	// 1. 并发处理：验证、提取主键、获取旧文档
 	type upsertItem struct {
@@ -1427,6 +2526,12 @@ func (c *collection) BulkUpsert(ctx context.Context, docs []map[string]any) ([]D
 			defer wg.Done()
 			for j := workerID; j < len(docs); j += numWorkers {
 				doc := docs[j]
+				c.stripVirtualFields(doc)
+				// 自定义验证器在加锁前执行，允许验证器查询集合
+				if err := c.runValidators(ctx, doc); err != nil {
+					items[j].err = err
+					continue
+				}
 				// 验证并提取主键
 				if err := c.validatePrimaryKey(doc); err != nil {
 					items[j].err = err
@@ -1484,10 +2589,31 @@ func (c *collection) BulkUpsert(ctx context.Context, docs []map[string]any) ([]D
 		return nil, errors.New("collection is closed")
 	}
 
+	hooks := c.hooks
 	for i := range items {
 		item := &items[i]
-		// Schema 验证
-		if err := ValidateDocument(c.schema, item.doc); err != nil {
+		// 调用 BeforeInsert/BeforeUpdate 钩子
+		var err error
+		if item.oldDoc != nil {
+			item.doc, err = c.runBeforeUpdate(ctx, hooks, item.idStr, item.oldDoc, item.doc)
+		} else if hooks.BeforeInsert != nil {
+			if item.doc, err = runBeforeInsert(ctx, hooks, item.doc); err == nil {
+				c.stripVirtualFields(item.doc)
+				if newID, perr := c.extractPrimaryKey(item.doc); perr != nil || newID != item.idStr {
+					err = NewError(ErrorTypeValidation, fmt.Sprintf("hook must not change the primary key of document %s", item.idStr), perr)
+				}
+			}
+		}
+		if err != nil {
+			c.mu.Unlock()
+			return nil, err
+		}
+
+		// 新文档应用默认值后进行 Schema 验证（启用 CoerceTypes 时先转换类型）
+		if item.oldDoc == nil {
+			ApplyDefaults(c.schema, item.doc)
+		}
+		if err := c.validateDocument(item.doc); err != nil {
 			c.mu.Unlock()
 			return nil, fmt.Errorf("schema validation failed for doc %s: %w", item.idStr, err)
 		}
@@ -1502,9 +2628,6 @@ func (c *collection) BulkUpsert(ctx context.Context, docs []map[string]any) ([]D
 			if prev, ok := item.oldDoc[c.schema.RevField]; ok {
 				oldRev = fmt.Sprintf("%v", prev)
 			}
-		} else {
-			// 新文档应用默认值
-			ApplyDefaults(c.schema, item.doc)
 		}
 
 		// 调用 preSave 钩子
@@ -1555,6 +2678,10 @@ func (c *collection) BulkUpsert(ctx context.Context, docs []map[string]any) ([]D
 					toWrite[j].err = fmt.Errorf("failed to marshal document %s: %w", item.idStr, err)
 					continue
 				}
+				if err := c.checkDocumentSize(item.idStr, data); err != nil {
+					toWrite[j].err = err
+					continue
+				}
 				toWrite[j] = writeData{idStr: item.idStr, data: data, doc: item.doc, oldDoc: item.oldDoc}
 			}
 		}(i)
@@ -1568,13 +2695,20 @@ func (c *collection) BulkUpsert(ctx context.Context, docs []map[string]any) ([]D
 	}
 
 	// 4. 执行批量写入
-	err := c.store.WithUpdate(ctx, func(txn *badger.Txn) error {
+	err := c.updateWithChangelog(ctx, func(txn kvTxn, changes *changelogTxn) error {
 		for _, item := range toWrite {
 			key := bstore.BucketKey(c.name, item.idStr)
 
 			if err := txn.Set(key, item.data); err != nil {
 				return err
 			}
+			op := OperationInsert
+			if item.oldDoc != nil {
+				op = OperationUpdate
+			}
+			if err := changes.add(item.idStr, op); err != nil {
+				return err
+			}
 			// 更新索引
 			if item.oldDoc != nil {
 				if err := c.updateIndexesInTx(txn, item.oldDoc, item.idStr, true); err != nil {
@@ -1616,9 +2750,12 @@ func (c *collection) BulkUpsert(ctx context.Context, docs []map[string]any) ([]D
 		}
 	}
 
-	// 6. 发送变更事件
+	// 6. 调用 After* 钩子并发送变更事件
+	for _, item := range toWrite {
+		c.runAfterWrite(ctx, hooks, item.idStr, item.doc, item.oldDoc)
+	}
 	for _, event := range changeEvents {
-		c.emitChange(event)
+		c.emitChange(ctx, event)
 	}
 
 	return result, nil
@@ -1667,9 +2804,18 @@ func (c *collection) BulkRemove(ctx context.Context, ids []string) error {
 			}
 		}
 	}
+	hooks := c.hooks
+	for _, id := range ids {
+		if _, exists := oldDocs[id]; exists {
+			if err := runBeforeDelete(ctx, hooks, id); err != nil {
+				c.mu.Unlock()
+				return err
+			}
+		}
+	}
 
 	// 批量原子删除：在一个事务中删除文档和所有关联索引
-	err := c.store.WithUpdate(ctx, func(txn *badger.Txn) error {
+	err := c.updateWithChangelog(ctx, func(txn kvTxn, changes *changelogTxn) error {
 		for _, id := range ids {
 			key := bstore.BucketKey(c.name, id)
 			if err := txn.Delete(key); err != nil {
@@ -1680,6 +2826,9 @@ func (c *collection) BulkRemove(ctx context.Context, ids []string) error {
 				if err := c.updateIndexesInTx(txn, oldDoc, id, true); err != nil {
 					return err
 				}
+				if err := changes.add(id, OperationDelete); err != nil {
+					return err
+				}
 			}
 		}
 		return nil
@@ -1715,13 +2864,90 @@ func (c *collection) BulkRemove(ctx context.Context, ids []string) error {
 			for _, hook := range c.postRemove {
 				_ = hook(ctx, nil, oldDoc)
 			}
+			if hooks.AfterDelete != nil {
+				hooks.AfterDelete(ctx, id)
+			}
 		}
 	}
 
 	for _, event := range changeEvents {
-		c.emitChange(event)
+		c.emitChange(ctx, event)
+	}
+
+	return nil
+}
+
+// Truncate 删除集合中的所有文档、附件与二级索引条目，保留 schema 与索引定义。
+// 与逐条删除不同，Truncate 不调用 preRemove/postRemove 钩子与 SchemaHooks，也不为每个文档发送删除事件，
+// 而是发送一条 OperationTruncate 事件；全文与向量搜索实例收到后清空索引，配置保持不变，
+// 复制客户端收到后删除远程的全部文档。
+func (c *collection) Truncate(ctx context.Context) error {
+	if err := c.beginOp(ctx); err != nil {
+		return err
+	}
+	defer c.endOp()
+
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+		return errors.New("collection is closed")
+	}
+
+	// 附件文件存放在数据库共享目录中，需要先根据附件元数据收集待删除的文件
+	attachmentBucket := fmt.Sprintf("%s_attachments", c.name)
+	var attachmentFiles []string
+	err := c.store.Iterate(ctx, attachmentBucket, func(k, v []byte) error {
+		var att Attachment
+		if err := json.Unmarshal(v, &att); err != nil {
+			return nil
+		}
+		docID := strings.TrimSuffix(string(k), "_"+att.ID)
+		if filePath, err := c.getAttachmentFilePath(docID, att.ID, att.Name); err == nil {
+			attachmentFiles = append(attachmentFiles, filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		c.mu.Unlock()
+		return fmt.Errorf("failed to list attachments: %w", err)
+	}
+
+	buckets := []string{c.name, attachmentBucket}
+	for _, idx := range c.schema.Indexes {
+		indexName := idx.Name
+		if indexName == "" {
+			indexName = strings.Join(idx.Fields, "_")
+		}
+		buckets = append(buckets, fmt.Sprintf("%s_idx_%s", c.name, indexName))
+	}
+	if err := c.store.DropBuckets(ctx, buckets...); err != nil {
+		c.mu.Unlock()
+		return fmt.Errorf("failed to truncate collection: %w", err)
+	}
+
+	c.idBloomFilter.Clear()
+	c.bloomNeedsRebuild = false
+
+	// DropBuckets 不在读写事务中执行，清空完成后再单独记录变更日志
+	err = c.updateWithChangelog(ctx, func(txn kvTxn, changes *changelogTxn) error {
+		return changes.add("", OperationTruncate)
+	})
+	if err != nil {
+		c.logger.Warn("Failed to record truncate in changelog", "collection", c.name, "error", err)
+	}
+
+	// 释放锁后再清理文件并发送变更事件，避免死锁
+	c.mu.Unlock()
+
+	for _, filePath := range attachmentFiles {
+		os.Remove(filePath)
 	}
 
+	c.emitChange(ctx, ChangeEvent{
+		Collection: c.name,
+		Op:         OperationTruncate,
+	})
 	return nil
 }
 
@@ -1768,10 +2994,95 @@ func (c *collection) ImportJSON(ctx context.Context, docs []map[string]any) erro
 	}
 
 	// 使用 BulkUpsert 来导入
-	_, err := c.BulkUpsert(ctx, docs)
+	_, err := c.bulkUpsert(ctx, docs)
 	return err
 }
 
+// AddValidator 注册自定义验证器。
+// 验证器在 Insert、Upsert 及其批量版本中按注册顺序执行，第一个错误会中止写入。
+func (c *collection) AddValidator(v Validator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validators = append(c.validators, v)
+}
+
+// runValidators 依次执行已注册的验证器，返回第一个验证错误。
+// 调用时不能持有 c.mu，以便验证器可以查询集合。
+func (c *collection) runValidators(ctx context.Context, doc map[string]any) error {
+	c.mu.RLock()
+	validators := c.validators
+	c.mu.RUnlock()
+	return runValidatorList(ctx, validators, doc)
+}
+
+// runValidatorList 依次执行 validators。已持有集合锁的调用方直接传入 c.validators。
+func runValidatorList(ctx context.Context, validators []Validator, doc map[string]any) error {
+	for _, v := range validators {
+		if err := v.Validate(ctx, doc); err != nil {
+			return NewError(ErrorTypeValidation, "validator failed", err)
+		}
+	}
+	return nil
+}
+
+// stripVirtualFields 从写入数据中移除 Schema.Virtual 声明的计算字段。
+func (c *collection) stripVirtualFields(doc map[string]any) {
+	for field := range c.schema.Virtual {
+		delete(doc, field)
+	}
+}
+
+// withVirtualFields 返回附加了计算字段的文档浅拷贝；未声明计算字段时直接返回原文档。
+func (c *collection) withVirtualFields(doc map[string]any) map[string]any {
+	if len(c.schema.Virtual) == 0 || doc == nil {
+		return doc
+	}
+	out := make(map[string]any, len(doc)+len(c.schema.Virtual))
+	for k, v := range doc {
+		out[k] = v
+	}
+	for field, fn := range c.schema.Virtual {
+		out[field] = fn(doc)
+	}
+	return out
+}
+
+// waitWriteTokens 在写入 n 个文档前从限流器获取配额。
+// 超过桶容量的请求分批获取；上下文取消时立即返回 ctx.Err()。
+func (c *collection) waitWriteTokens(ctx context.Context, n int) error {
+	if c.writeLimit == nil || n <= 0 {
+		return nil
+	}
+	burst := c.writeLimit.Burst()
+	for n > 0 {
+		take := min(n, burst)
+		r := c.writeLimit.ReserveN(time.Now(), take)
+		if !r.OK() {
+			return NewError(ErrorTypeUnknown, "write rate limit reservation failed", nil)
+		}
+		if delay := r.Delay(); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				r.Cancel()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		n -= take
+	}
+	return nil
+}
+
+// checkDocumentSize 检查序列化后的文档是否超过 DatabaseOptions.MaxDocumentSize。
+func (c *collection) checkDocumentSize(docID string, data []byte) error {
+	if c.maxDocSize > 0 && len(data) > c.maxDocSize {
+		return &DocumentTooLargeError{DocumentID: docID, Size: len(data), MaxSize: c.maxDocSize}
+	}
+	return nil
+}
+
 // PreInsert 注册插入前钩子。
 func (c *collection) PreInsert(hook HookFunc) {
 	c.mu.Lock()
@@ -2429,8 +3740,8 @@ func (c *collection) CreateIndex(ctx context.Context, index Index) error {
 	}
 
 	// 验证索引
-	if len(index.Fields) == 0 {
-		return fmt.Errorf("index must have at least one field")
+	if err := validateIndex(index); err != nil {
+		return err
 	}
 
 	// 检查索引是否已存在
@@ -2481,19 +3792,11 @@ func (c *collection) CreateIndex(ctx context.Context, index Index) error {
 			}
 		}
 
-		// 构建索引键
-		indexKeyParts := make([]interface{}, 0, len(index.Fields))
-		for _, field := range index.Fields {
-			value := getNestedValue(doc, field)
-			indexKeyParts = append(indexKeyParts, value)
+		// 构建索引键并直接设置，无需读取旧列表
+		for _, indexKey := range indexEntryKeys(index, doc, string(k)) {
+			_ = c.store.Set(ctx, bucketName, string(indexKey), nil)
 		}
 
-		docID := string(k)
-		indexKey := encodeIndexKey(indexKeyParts, docID)
-
-		// 直接设置索引键，无需读取旧列表
-		_ = c.store.Set(ctx, bucketName, string(indexKey), nil)
-
 		return nil
 	})
 	if err != nil {
@@ -2503,7 +3806,7 @@ func (c *collection) CreateIndex(ctx context.Context, index Index) error {
 	// 将索引添加到 schema
 	c.schema.Indexes = append(c.schema.Indexes, index)
 
-	return nil
+	return c.saveIndexes(ctx)
 }
 
 // DropIndex 删除索引。
@@ -2555,7 +3858,7 @@ func (c *collection) DropIndex(ctx context.Context, indexName string) error {
 	// 从 schema 中移除索引
 	c.schema.Indexes = append(c.schema.Indexes[:indexIndex], c.schema.Indexes[indexIndex+1:]...)
 
-	return nil
+	return c.saveIndexes(ctx)
 }
 
 // ListIndexes 返回所有索引列表。
@@ -2623,8 +3926,8 @@ func (c *collection) updateIndexesOnSchemaChange(ctx context.Context, oldIndexes
 		if !exists {
 			// 全新的索引
 			needsBuild = true
-		} else if !indexFieldsEqualForCollection(oldIdx.Fields, newIdx.Fields) {
-			// 字段有变化，需要重建
+		} else if !indexFieldsEqualForCollection(oldIdx.Fields, newIdx.Fields) || oldIdx.Type != newIdx.Type {
+			// 字段或类型有变化，需要重建
 			needsBuild = true
 			// 先删除旧索引数据
 			indexName := oldIdx.Name
@@ -2667,19 +3970,11 @@ func (c *collection) updateIndexesOnSchemaChange(ctx context.Context, oldIndexes
 					}
 				}
 
-				// 构建索引键
-				indexKeyParts := make([]interface{}, 0, len(newIdx.Fields))
-				for _, field := range newIdx.Fields {
-					value := getNestedValue(doc, field)
-					indexKeyParts = append(indexKeyParts, value)
+				// 构建并设置索引键
+				for _, indexKey := range indexEntryKeys(newIdx, doc, string(k)) {
+					_ = c.store.Set(ctx, bucketName, string(indexKey), nil)
 				}
 
-				docID := string(k)
-				indexKey := encodeIndexKey(indexKeyParts, docID)
-
-				// 设置索引键
-				_ = c.store.Set(ctx, bucketName, string(indexKey), nil)
-
 				return nil
 			})
 			if err != nil {
@@ -2691,6 +3986,50 @@ func (c *collection) updateIndexesOnSchemaChange(ctx context.Context, oldIndexes
 	return nil
 }
 
+// mergeSchemaIndexes 合并已有索引与 schema 声明的索引。
+// 已有但未在 schema 中声明的索引默认保留，仅在 dropMissing 为 true 时移除。
+func mergeSchemaIndexes(existing, declared []Index, dropMissing bool) []Index {
+	merged := make([]Index, 0, len(existing)+len(declared))
+	merged = append(merged, declared...)
+	if dropMissing {
+		return merged
+	}
+
+	declaredKeys := make(map[string]bool, len(declared))
+	for _, idx := range declared {
+		declaredKeys[getIndexKeyForCollection(idx)] = true
+	}
+	for _, idx := range existing {
+		if !declaredKeys[getIndexKeyForCollection(idx)] {
+			merged = append(merged, idx)
+		}
+	}
+	return merged
+}
+
+// loadIndexes 从存储中加载集合已构建的索引定义，从未保存过时返回 nil。
+func (c *collection) loadIndexes(ctx context.Context) ([]Index, error) {
+	data, err := c.store.Get(ctx, "_meta", fmt.Sprintf("%s_indexes", c.name))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var indexes []Index
+	if err := json.Unmarshal(data, &indexes); err != nil {
+		return nil, err
+	}
+	return indexes, nil
+}
+
+// saveIndexes 将当前索引定义保存到存储中。
+// 注意：调用者应已持有锁
+func (c *collection) saveIndexes(ctx context.Context) error {
+	data, err := json.Marshal(c.schema.Indexes)
+	if err != nil {
+		return err
+	}
+	return c.store.Set(ctx, "_meta", fmt.Sprintf("%s_indexes", c.name), data)
+}
+
 // getIndexKeyForCollection 获取索引的唯一键（用于比较）
 func getIndexKeyForCollection(idx Index) string {
 	if idx.Name != "" {
@@ -2,34 +2,92 @@ package rxdb
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
-func TestCollection_Insert(t *testing.T) {
+// newTestDatabase 与 testutil.NewTestDatabase 相同，供包内测试使用
+// （包内测试导入 testutil 会形成循环依赖）。
+func newTestDatabase(t testing.TB) Database {
+	t.Helper()
+
 	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "testdb.db")
 	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: "../../data/test_insert.db",
+		Name:    "testdb",
+		Path:    path,
+		Backend: newTestBackend(t),
 	})
 	if err != nil {
 		t.Fatalf("Failed to create database: %v", err)
 	}
-	defer os.RemoveAll("../../data/test_insert.db")
-	defer db.Close(ctx)
+	t.Cleanup(func() {
+		db.Close(ctx)
+		os.RemoveAll(path)
+	})
+	return db
+}
+
+// newTestCollection 与 testutil.NewTestCollection 相同，供包内测试使用。
+func newTestCollection(t testing.TB, db Database, name string, schema Schema) Collection {
+	t.Helper()
+
+	coll, err := db.Collection(context.Background(), name, schema)
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	return coll
+}
+
+func TestCollection_NameAndSchema(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	for _, tc := range []struct {
+		name       string
+		primaryKey string
+	}{
+		{"users", "id"},
+		{"products", "sku"},
+	} {
+		var collection Collection = newTestCollection(t, db, tc.name, Schema{PrimaryKey: tc.primaryKey, RevField: "_rev"})
+		if got := collection.Name(); got != tc.name {
+			t.Errorf("Expected name %q, got %q", tc.name, got)
+		}
+		if got := collection.Schema().PrimaryKey; got != tc.primaryKey {
+			t.Errorf("Expected primary key %q for %s, got %v", tc.primaryKey, tc.name, got)
+		}
+
+		// 重新获取的集合保持创建时的 schema
+		again, err := db.Collection(ctx, tc.name, Schema{PrimaryKey: tc.primaryKey, RevField: "_rev"})
+		if err != nil {
+			t.Fatalf("Failed to get collection: %v", err)
+		}
+		if again.Name() != tc.name || again.Schema().PrimaryKey != tc.primaryKey {
+			t.Errorf("Unexpected name/primary key for %s: %s/%v", tc.name, again.Name(), again.Schema().PrimaryKey)
+		}
+	}
+}
+
+func TestCollection_Insert(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入文档
 	doc, err := collection.Insert(ctx, map[string]any{
@@ -65,24 +123,13 @@ func TestCollection_Insert(t *testing.T) {
 
 func TestCollection_Upsert(t *testing.T) {
 	ctx := context.Background()
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: "../../data/test_upsert.db",
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer os.RemoveAll("../../data/test_upsert.db")
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 首次插入
 	doc1, err := collection.Upsert(ctx, map[string]any{
@@ -117,28 +164,17 @@ func TestCollection_Upsert(t *testing.T) {
 
 func TestCollection_Remove(t *testing.T) {
 	ctx := context.Background()
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: "../../data/test_remove.db",
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer os.RemoveAll("../../data/test_remove.db")
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入文档
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Test",
 	})
@@ -164,29 +200,18 @@ func TestCollection_Remove(t *testing.T) {
 
 func TestCollection_All(t *testing.T) {
 	ctx := context.Background()
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: "../../data/test_all.db",
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer os.RemoveAll("../../data/test_all.db")
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入多个文档
 	for i := 1; i <= 5; i++ {
-		_, err = collection.Insert(ctx, map[string]any{
+		_, err := collection.Insert(ctx, map[string]any{
 			"id":   fmt.Sprintf("doc%d", i),
 			"name": fmt.Sprintf("Document %d", i),
 		})
@@ -208,30 +233,19 @@ func TestCollection_All(t *testing.T) {
 
 func TestCollection_Changes(t *testing.T) {
 	ctx := context.Background()
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: "../../data/test_changes.db",
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer os.RemoveAll("../../data/test_changes.db")
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	changes := collection.Changes()
 
 	// 插入文档
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Test",
 	})
@@ -324,30 +338,18 @@ func TestCollection_InsertDuplicate(t *testing.T) {
 
 func TestCollection_FindByID(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_findbyid.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入文档
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Test Document",
 	})
@@ -389,27 +391,15 @@ func TestCollection_FindByID(t *testing.T) {
 
 func TestCollection_Count(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_count.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 空集合应该返回 0
 	count, err := collection.Count(ctx)
@@ -460,27 +450,15 @@ func TestCollection_Count(t *testing.T) {
 
 func TestCollection_BulkInsert(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_bulk_insert.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 准备批量插入的文档
 	docs := []map[string]any{
@@ -524,27 +502,15 @@ func TestCollection_BulkInsert_Performance(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	dbPath := "../../data/test_bulk_insert_perf.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 准备大量文档
 	numDocs := 1000
@@ -573,10 +539,7 @@ func TestCollection_BulkInsert_Performance(t *testing.T) {
 	t.Logf("BulkInsert %d documents took %v (%.2f docs/sec)", numDocs, duration, float64(numDocs)/duration.Seconds())
 
 	// 对比单个插入性能
-	collection2, err := db.Collection(ctx, "test2", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection2 := newTestCollection(t, db, "test2", schema)
 
 	start = time.Now()
 	for i := 0; i < 100; i++ { // 只测试100个以节省时间
@@ -595,30 +558,18 @@ func TestCollection_BulkInsert_Performance(t *testing.T) {
 
 func TestCollection_BulkInsertDuplicate(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_bulk_insert_duplicate.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 先插入一个文档
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Original",
 	})
@@ -675,32 +626,223 @@ func TestCollection_BulkInsertDuplicate(t *testing.T) {
 	}
 }
 
-func TestCollection_BulkUpsert(t *testing.T) {
+func TestCollection_BulkInsertWithOptions(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_bulk_upsert.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
+	db := newTestDatabase(t)
+
+	newCollection := func(name string) Collection {
+		collection, err := db.Collection(ctx, name, Schema{PrimaryKey: "id", RevField: "_rev"})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		if _, err := collection.Insert(ctx, map[string]any{"id": "doc1", "name": "Original"}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+		return collection
+	}
+	batch := func() []map[string]any {
+		return []map[string]any{
+			{"id": "doc2", "name": "Document 2"},
+			{"id": "doc1", "name": "Duplicate"},
+			{"id": "doc3", "name": "Document 3"},
+		}
+	}
+
+	t.Run("error", func(t *testing.T) {
+		collection := newCollection("conflict_error")
+		docs, conflicts, err := collection.BulkInsertWithOptions(ctx, batch(), BulkInsertOptions{OnConflict: BulkInsertOnConflictError})
+		if !IsAlreadyExistsError(err) {
+			t.Fatalf("Expected AlreadyExists error, got %v", err)
+		}
+		if len(docs) != 0 {
+			t.Errorf("Expected no inserted documents, got %d", len(docs))
+		}
+		if conflicts != nil {
+			t.Errorf("Expected no per-document errors, got %+v", conflicts)
+		}
+		var bulkErr BulkInsertError
+		if !errors.As(err, &bulkErr) || bulkErr.ID != "doc1" || bulkErr.Index != 1 {
+			t.Errorf("Unexpected error: %#v", err)
+		}
+		if count, _ := collection.Count(ctx); count != 1 {
+			t.Errorf("Expected count 1, got %d", count)
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		collection := newCollection("conflict_skip")
+		input := append(batch(), map[string]any{"id": "doc2", "name": "Duplicate in batch"})
+		docs, conflicts, err := collection.BulkInsertWithOptions(ctx, input, BulkInsertOptions{OnConflict: BulkInsertOnConflictSkip})
+		if err != nil {
+			t.Fatalf("Failed to bulk insert: %v", err)
+		}
+		if len(docs) != 2 {
+			t.Errorf("Expected 2 inserted documents, got %d", len(docs))
+		}
+		if len(conflicts) != 2 {
+			t.Fatalf("Expected 2 conflicts, got %+v", conflicts)
+		}
+		if conflicts[0].ID != "doc1" || conflicts[0].Index != 1 || !IsAlreadyExistsError(conflicts[0]) {
+			t.Errorf("Unexpected first conflict: %+v", conflicts[0])
+		}
+		if conflicts[1].ID != "doc2" || conflicts[1].Index != 3 {
+			t.Errorf("Unexpected second conflict: %+v", conflicts[1])
+		}
+		if count, _ := collection.Count(ctx); count != 3 {
+			t.Errorf("Expected count 3, got %d", count)
+		}
+		doc1, _ := collection.FindByID(ctx, "doc1")
+		if doc1 == nil || doc1.GetString("name") != "Original" {
+			t.Error("doc1 should keep its original value")
+		}
+	})
+
+	t.Run("skip concurrent", func(t *testing.T) {
+		collection := newCollection("conflict_skip_concurrent")
+		const workers = 4
+		var inserted, skipped int64
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				input := make([]map[string]any, 10)
+				for i := range input {
+					input[i] = map[string]any{"id": fmt.Sprintf("c%d", i), "worker": w}
+				}
+				docs, conflicts, err := collection.BulkInsertWithOptions(ctx, input, BulkInsertOptions{OnConflict: BulkInsertOnConflictSkip})
+				if err != nil {
+					t.Errorf("Failed to bulk insert: %v", err)
+					return
+				}
+				atomic.AddInt64(&inserted, int64(len(docs)))
+				atomic.AddInt64(&skipped, int64(len(conflicts)))
+			}(w)
+		}
+		wg.Wait()
+		if inserted != 10 || skipped != 10*(workers-1) {
+			t.Errorf("Expected 10 inserted and %d skipped, got %d and %d", 10*(workers-1), inserted, skipped)
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		collection := newCollection("conflict_replace")
+		docs, conflicts, err := collection.BulkInsertWithOptions(ctx, batch(), BulkInsertOptions{OnConflict: BulkInsertOnConflictReplace})
+		if err != nil {
+			t.Fatalf("Failed to bulk insert: %v", err)
+		}
+		if len(docs) != 3 || len(conflicts) != 0 {
+			t.Errorf("Expected 3 documents and no conflicts, got %d and %+v", len(docs), conflicts)
+		}
+		doc1, _ := collection.FindByID(ctx, "doc1")
+		if doc1 == nil || doc1.GetString("name") != "Duplicate" {
+			t.Error("doc1 should be replaced")
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		collection := newCollection("conflict_unknown")
+		_, _, err := collection.BulkInsertWithOptions(ctx, batch(), BulkInsertOptions{OnConflict: "merge"})
+		if !IsValidationError(err) {
+			t.Errorf("Expected validation error, got %v", err)
+		}
+	})
+}
+
+func TestCollection_VirtualFields(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDatabase(t)
+
+	collection, err := db.Collection(ctx, "test", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		Virtual: map[string]func(doc map[string]any) any{
+			"fullName": func(doc map[string]any) any {
+				first, _ := doc["firstName"].(string)
+				last, _ := doc["lastName"].(string)
+				return first + " " + last
+			},
+		},
 	})
 	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
+		t.Fatalf("Failed to create collection: %v", err)
 	}
-	defer db.Close(ctx)
+
+	doc, err := collection.Insert(ctx, map[string]any{
+		"id":        "u1",
+		"firstName": "Ada",
+		"lastName":  "Lovelace",
+		"fullName":  "should not be stored",
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if got := doc.Data()["fullName"]; got != "Ada Lovelace" {
+		t.Errorf("Expected fullName 'Ada Lovelace', got %v", got)
+	}
+	if _, err := collection.Insert(ctx, map[string]any{"id": "u2", "firstName": "Alan", "lastName": "Turing"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	// 计算字段不应写入存储
+	raw, err := db.(*database).store.Get(ctx, "test", "u1")
+	if err != nil {
+		t.Fatalf("Failed to read raw document: %v", err)
+	}
+	if strings.Contains(string(raw), "fullName") {
+		t.Errorf("Virtual field should not be stored, got %s", raw)
+	}
+
+	found, err := collection.FindByID(ctx, "u2")
+	if err != nil || found == nil {
+		t.Fatalf("Failed to find document: %v", err)
+	}
+	if got := found.Data()["fullName"]; got != "Alan Turing" {
+		t.Errorf("Expected fullName 'Alan Turing', got %v", got)
+	}
+
+	// 查询计算字段
+	results, err := collection.Find(map[string]any{"fullName": "Ada Lovelace"}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 1 || results[0].ID() != "u1" {
+		t.Errorf("Expected only u1, got %d results", len(results))
+	}
+
+	results, err = collection.Find(map[string]any{"fullName": map[string]any{"$regex": "^Al"}}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 1 || results[0].ID() != "u2" {
+		t.Errorf("Expected only u2, got %d results", len(results))
+	}
+
+	count, err := collection.Find(map[string]any{"fullName": map[string]any{"$ne": "Ada Lovelace"}}).Count(ctx)
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected count 1, got %d", count)
+	}
+}
+
+func TestCollection_BulkUpsert(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 先插入一个文档
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Original",
 	})
@@ -749,27 +891,15 @@ func TestCollection_BulkUpsert_Performance(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	dbPath := "../../data/test_bulk_upsert_perf.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 准备混合插入和更新的文档
 	numDocs := 500
@@ -783,7 +913,7 @@ func TestCollection_BulkUpsert_Performance(t *testing.T) {
 	}
 
 	// 先插入一半
-	_, err = collection.BulkInsert(ctx, docs[:numDocs/2])
+	_, err := collection.BulkInsert(ctx, docs[:numDocs/2])
 	if err != nil {
 		t.Fatalf("Failed to bulk insert: %v", err)
 	}
@@ -816,31 +946,19 @@ func TestCollection_BulkUpsert_Performance(t *testing.T) {
 
 func TestCollection_BulkRemove(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_bulk_remove.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入多个文档
 	for i := 1; i <= 5; i++ {
-		_, err = collection.Insert(ctx, map[string]any{
+		_, err := collection.Insert(ctx, map[string]any{
 			"id":   fmt.Sprintf("doc%d", i),
 			"name": fmt.Sprintf("Document %d", i),
 		})
@@ -851,7 +969,7 @@ func TestCollection_BulkRemove(t *testing.T) {
 
 	// 批量删除
 	ids := []string{"doc1", "doc2", "doc3"}
-	err = collection.BulkRemove(ctx, ids)
+	err := collection.BulkRemove(ctx, ids)
 	if err != nil {
 		t.Fatalf("Failed to bulk remove: %v", err)
 	}
@@ -885,32 +1003,117 @@ func TestCollection_BulkRemove(t *testing.T) {
 	}
 }
 
-func TestCollection_IncrementalUpsert(t *testing.T) {
+func TestCollection_Truncate(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_incremental_upsert.db"
-	defer os.RemoveAll(dbPath)
+	db := newTestDatabase(t)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
+	collection := newTestCollection(t, db, "test", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		Indexes:    []Index{{Fields: []string{"category"}, Name: "category_idx"}},
+	})
+
+	for i := 1; i <= 20; i++ {
+		_, err := collection.Insert(ctx, map[string]any{
+			"id":       fmt.Sprintf("doc%d", i),
+			"category": "old",
+			"title":    fmt.Sprintf("old document %d", i),
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert doc%d: %v", i, err)
+		}
+	}
+
+	fts, err := AddFulltextSearch(collection, FulltextSearchConfig{
+		Identifier: "truncate-fts",
+		DocToString: func(doc map[string]any) string {
+			title, _ := doc["title"].(string)
+			return title
+		},
 	})
 	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
+		t.Fatalf("Failed to create fulltext search: %v", err)
 	}
-	defer db.Close(ctx)
+	defer fts.Close()
+	if count := fts.Count(); count != 20 {
+		t.Fatalf("Expected 20 documents in fulltext index, got %d", count)
+	}
+
+	changes := collection.Changes()
+	if err := collection.Truncate(ctx); err != nil {
+		t.Fatalf("Failed to truncate: %v", err)
+	}
+
+	select {
+	case event := <-changes:
+		if event.Op != OperationTruncate {
+			t.Errorf("Expected OperationTruncate, got %s", event.Op)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for truncate event")
+	}
+
+	count, err := collection.Count(ctx)
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected count 0 after truncate, got %d", count)
+	}
+
+	// 索引定义保留，索引数据清空
+	if indexes := collection.ListIndexes(); len(indexes) != 1 {
+		t.Errorf("Expected index definition to be kept, got %v", indexes)
+	}
+	if _, err := collection.Insert(ctx, map[string]any{"id": "new1", "category": "new", "title": "fresh document"}); err != nil {
+		t.Fatalf("Failed to insert after truncate: %v", err)
+	}
+	docs, err := collection.Find(map[string]any{"category": "old"}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to find: %v", err)
+	}
+	if len(docs) != 0 {
+		t.Errorf("Expected no documents with old category, got %d", len(docs))
+	}
+	docs, err = collection.Find(map[string]any{"category": "new"}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to find: %v", err)
+	}
+	if len(docs) != 1 {
+		t.Errorf("Expected 1 document with new category, got %d", len(docs))
+	}
+
+	// 全文索引通过变更事件异步重置，并继续索引新文档
+	deadline := time.Now().Add(2 * time.Second)
+	for fts.Count() != 1 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if count := fts.Count(); count != 1 {
+		t.Errorf("Expected 1 document in fulltext index after truncate, got %d", count)
+	}
+	results, err := fts.Find(ctx, "document")
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(results) != 1 || results[0].ID() != "new1" {
+		t.Errorf("Expected only new1 in fulltext results, got %d results", len(results))
+	}
+}
+
+func TestCollection_IncrementalUpsert(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入初始文档
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Original",
 		"age":  25,
@@ -943,30 +1146,18 @@ func TestCollection_IncrementalUpsert(t *testing.T) {
 
 func TestCollection_ExportJSON(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_export_json.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb_export_json",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入文档
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Test",
 	})
@@ -1075,27 +1266,15 @@ func TestCollection_ExportJSON_Encryption(t *testing.T) {
 
 func TestCollection_ImportJSON(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_import_json.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 导入文档
 	docs := []map[string]any{
@@ -1103,7 +1282,7 @@ func TestCollection_ImportJSON(t *testing.T) {
 		{"id": "doc2", "name": "Document 2"},
 	}
 
-	err = collection.ImportJSON(ctx, docs)
+	err := collection.ImportJSON(ctx, docs)
 	if err != nil {
 		t.Fatalf("Failed to import: %v", err)
 	}
@@ -1134,27 +1313,15 @@ func TestCollection_ImportJSON_Performance(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	dbPath := "../../data/test_import_perf.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 准备大量文档
 	numDocs := 1000
@@ -1169,7 +1336,7 @@ func TestCollection_ImportJSON_Performance(t *testing.T) {
 
 	// 测试批量导入性能
 	start := time.Now()
-	err = collection.ImportJSON(ctx, docs)
+	err := collection.ImportJSON(ctx, docs)
 	duration := time.Since(start)
 
 	if err != nil {
@@ -1205,30 +1372,18 @@ func TestCollection_ImportJSON_Performance(t *testing.T) {
 
 func TestCollection_IncrementalModify(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_incremental_modify.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入初始文档
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Original",
 		"age":  25,
@@ -1259,27 +1414,15 @@ func TestCollection_IncrementalModify(t *testing.T) {
 
 func TestCollection_ChangesMultipleListeners(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_changes_multiple.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 创建多个监听者 - 每个监听者都有独立的通道，都能收到所有事件
 	changes1 := collection.Changes()
@@ -1309,7 +1452,7 @@ func TestCollection_ChangesMultipleListeners(t *testing.T) {
 	}()
 
 	// 插入两个文档，两个监听者都应该收到所有事件
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Test 1",
 	})
@@ -1440,30 +1583,18 @@ func TestCollection_ExportImportRoundTrip(t *testing.T) {
 
 func TestCollection_Dump(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_dump.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入文档
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Test",
 	})
@@ -1494,27 +1625,15 @@ func TestCollection_Dump(t *testing.T) {
 
 func TestCollection_ImportDump(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_import_dump.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 准备 dump 数据
 	dump := map[string]any{
@@ -1528,7 +1647,7 @@ func TestCollection_ImportDump(t *testing.T) {
 	}
 
 	// 导入 dump
-	err = collection.ImportDump(ctx, dump)
+	err := collection.ImportDump(ctx, dump)
 	if err != nil {
 		t.Fatalf("Failed to import dump: %v", err)
 	}
@@ -1744,27 +1863,15 @@ func TestCollection_DumpImportRoundTrip(t *testing.T) {
 
 func TestCollection_UpsertWithConflict(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_upsert_conflict.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入初始文档
 	doc1, err := collection.Insert(ctx, map[string]any{
@@ -1816,32 +1923,20 @@ func TestCollection_UpsertWithConflict(t *testing.T) {
 
 func TestCollection_ChangesFilter(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_changes_filter.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	changes := collection.Changes()
 
 	// 插入文档
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Test",
 	})
@@ -1892,27 +1987,15 @@ func TestCollection_ChangesFilter(t *testing.T) {
 // TestCollection_ChangesEventOrder 测试事件顺序
 func TestCollection_ChangesEventOrder(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_changes_order.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	changes := collection.Changes()
 
@@ -1984,27 +2067,15 @@ func TestCollection_ChangesEventOrder(t *testing.T) {
 // TestCollection_ChangesConcurrency 测试并发安全性
 func TestCollection_ChangesConcurrency(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_changes_concurrency.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	changes := collection.Changes()
 
@@ -2129,26 +2200,14 @@ func TestCollection_ChangesChannelClose(t *testing.T) {
 
 func TestCollection_UpsertConflict(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_upsert_conflict_repro.db"
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb_conflict",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer os.RemoveAll(dbPath)
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	docID := "doc-conflict"
 	var wg sync.WaitGroup
@@ -2203,27 +2262,15 @@ func TestCollection_UpsertConflict(t *testing.T) {
 // 修复后应该能正确处理这种情况，而不是报 revision mismatch 错误
 func TestCollection_UpsertBloomFilterFalseNegative(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_upsert_bloom_false_negative.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb_bloom_false_negative",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	docID := "doc-bloom-false-negative"
 	var wg sync.WaitGroup
@@ -2317,27 +2364,15 @@ func TestCollection_UpsertBloomFilterFalseNegative(t *testing.T) {
 // 多个 Upsert 操作同时尝试创建同一个文档，应该都能成功或正确处理冲突
 func TestCollection_UpsertConcurrentCreation(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_upsert_concurrent_creation.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb_concurrent_creation",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	docID := "doc-concurrent-creation"
 	numGoroutines := 20
@@ -2415,17 +2450,8 @@ func TestCollection_UpsertConcurrentCreation(t *testing.T) {
 // 但在事务中能正确读取到 revision（actualRev 不为空），应该能正确处理而不是报错
 func TestCollection_UpsertAfterSchemaChange(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_upsert_after_schema_change.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	// 创建初始 schema
 	schemaV1 := Schema{
@@ -2441,10 +2467,7 @@ func TestCollection_UpsertAfterSchemaChange(t *testing.T) {
 		},
 	}
 
-	collection, err := db.Collection(ctx, "test", schemaV1)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schemaV1)
 
 	// 插入初始文档
 	docID := "doc-schema-change"
@@ -2481,10 +2504,7 @@ func TestCollection_UpsertAfterSchemaChange(t *testing.T) {
 	}
 
 	// 使用新 schema 获取集合（这会触发 schema 更新）
-	collection2, err := db.Collection(ctx, "test", schemaV2)
-	if err != nil {
-		t.Fatalf("Failed to update collection schema: %v", err)
-	}
+	collection2 := newTestCollection(t, db, "test", schemaV2)
 
 	// 验证 schema 已更新
 	updatedSchema := collection2.Schema()
@@ -2549,17 +2569,8 @@ func TestCollection_UpsertAfterSchemaChange(t *testing.T) {
 // 场景：schema 的 RevField 字段名改变后，旧文档可能使用旧的字段名
 func TestCollection_UpsertAfterSchemaChange_RevFieldChange(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_upsert_after_schema_change_revfield.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	// 创建初始 schema，使用默认 _rev 字段
 	schemaV1 := Schema{
@@ -2570,10 +2581,7 @@ func TestCollection_UpsertAfterSchemaChange_RevFieldChange(t *testing.T) {
 		},
 	}
 
-	collection, err := db.Collection(ctx, "test", schemaV1)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schemaV1)
 
 	// 插入初始文档
 	docID := "doc-revfield-change"
@@ -2608,10 +2616,7 @@ func TestCollection_UpsertAfterSchemaChange_RevFieldChange(t *testing.T) {
 	}
 
 	// 使用新 schema 获取集合
-	collection2, err := db.Collection(ctx, "test", schemaV2)
-	if err != nil {
-		t.Fatalf("Failed to update collection schema: %v", err)
-	}
+	collection2 := newTestCollection(t, db, "test", schemaV2)
 
 	// 验证迁移后的文档使用新字段
 	migratedDoc, err := collection2.FindByID(ctx, docID)
@@ -2667,17 +2672,8 @@ func TestCollection_UpsertAfterSchemaChange_RevFieldChange(t *testing.T) {
 // 场景：旧数据可能没有 revision 字段，Upsert 应该能正确处理
 func TestCollection_UpsertAfterSchemaChange_NoRevision(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_upsert_after_schema_change_no_rev.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	// 创建初始 schema
 	schemaV1 := Schema{
@@ -2688,10 +2684,7 @@ func TestCollection_UpsertAfterSchemaChange_NoRevision(t *testing.T) {
 		},
 	}
 
-	collection, err := db.Collection(ctx, "test", schemaV1)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schemaV1)
 
 	// 直接通过存储层插入一个没有 revision 字段的文档（模拟旧数据）
 	docID := "doc-no-rev"
@@ -2733,3 +2726,1495 @@ func TestCollection_UpsertAfterSchemaChange_NoRevision(t *testing.T) {
 	}
 	t.Logf("Updated revision: %s", rev2)
 }
+
+func TestCollection_MapReduce(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	collection, err := db.Collection(ctx, "texts", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	texts := []string{
+		"the quick brown fox",
+		"the lazy dog",
+		"quick quick fox jumps over the dog",
+		"",
+		"brown dog brown fox",
+	}
+	for i, text := range texts {
+		if _, err := collection.Insert(ctx, map[string]any{
+			"id":   fmt.Sprintf("doc%d", i),
+			"text": text,
+		}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	// 朴素的参考实现
+	expected := make(map[string]int)
+	for _, text := range texts {
+		for _, word := range strings.Fields(text) {
+			expected[word]++
+		}
+	}
+
+	mapFn := func(doc Document) []KeyValue {
+		var kvs []KeyValue
+		for _, word := range strings.Fields(doc.GetString("text")) {
+			kvs = append(kvs, KeyValue{Key: word, Value: 1})
+		}
+		return kvs
+	}
+	reduceFn := func(key string, values []any) any {
+		sum := 0
+		for _, v := range values {
+			sum += v.(int)
+		}
+		return sum
+	}
+
+	for _, workers := range []int{0, 1, 3} {
+		result, err := collection.MapReduce(ctx, mapFn, reduceFn, MapReduceOptions{NumWorkers: workers})
+		if err != nil {
+			t.Fatalf("MapReduce failed: %v", err)
+		}
+		if len(result) != len(expected) {
+			t.Errorf("workers=%d: expected %d keys, got %d", workers, len(expected), len(result))
+		}
+		for word, count := range expected {
+			if result[word] != count {
+				t.Errorf("workers=%d: expected %s=%d, got %v", workers, word, count, result[word])
+			}
+		}
+	}
+
+	if _, err := collection.MapReduce(ctx, nil, reduceFn, MapReduceOptions{}); err == nil {
+		t.Error("Expected error for nil map function")
+	}
+}
+
+func TestCollection_WatchFrom_Resume(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_collection_watch_from.db"
+	defer os.RemoveAll(dbPath)
+
+	openCollection := func() (Database, Collection) {
+		db, err := CreateDatabase(ctx, DatabaseOptions{
+			Name: "testdb",
+			Path: dbPath,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create database: %v", err)
+		}
+		collection, err := db.Collection(ctx, "test", Schema{
+			PrimaryKey: "id",
+			RevField:   "_rev",
+			Changelog:  &ChangelogOptions{},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		return db, collection
+	}
+
+	receive := func(ch <-chan CDCEvent, n int) []CDCEvent {
+		events := make([]CDCEvent, 0, n)
+		for len(events) < n {
+			select {
+			case event, ok := <-ch:
+				if !ok {
+					t.Fatalf("Watch channel closed after %d events, expected %d", len(events), n)
+				}
+				events = append(events, event)
+			case <-time.After(2 * time.Second):
+				t.Fatalf("Timed out after %d events, expected %d", len(events), n)
+			}
+		}
+		return events
+	}
+
+	db, collection := openCollection()
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	stream, err := collection.WatchFrom(watchCtx, nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	for i := 1; i <= 3; i++ {
+		if _, err := collection.Insert(ctx, map[string]any{"id": fmt.Sprintf("doc%d", i), "n": i}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	first := receive(stream, 3)
+	for i, event := range first {
+		if event.Sequence != int64(i+1) {
+			t.Errorf("Expected sequence %d, got %d", i+1, event.Sequence)
+		}
+	}
+	lastSeen := first[len(first)-1].Sequence
+
+	// 模拟断线：取消订阅后继续写入，并重启数据库
+	cancel()
+	if _, err := collection.Insert(ctx, map[string]any{"id": "doc4", "n": 4}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if _, err := collection.Upsert(ctx, map[string]any{"id": "doc1", "n": 10}); err != nil {
+		t.Fatalf("Failed to upsert: %v", err)
+	}
+	if err := collection.Remove(ctx, "doc2"); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	db.Close(ctx)
+
+	db, collection = openCollection()
+	defer db.Close(ctx)
+
+	watchCtx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	resumed, err := collection.WatchFrom(watchCtx, nil, lastSeen)
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	replayed := receive(resumed, 3)
+	if _, err := collection.Insert(ctx, map[string]any{"id": "doc5", "n": 5}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	live := receive(resumed, 1)
+
+	expected := []struct {
+		id string
+		op Operation
+	}{
+		{"doc4", OperationInsert},
+		{"doc1", OperationUpdate},
+		{"doc2", OperationDelete},
+		{"doc5", OperationInsert},
+	}
+	for i, event := range append(replayed, live...) {
+		if event.Sequence != lastSeen+int64(i+1) {
+			t.Errorf("Expected sequence %d, got %d", lastSeen+int64(i+1), event.Sequence)
+		}
+		if event.ID != expected[i].id || event.Op != expected[i].op {
+			t.Errorf("Event %d: expected %s %s, got %s %s", i, expected[i].op, expected[i].id, event.Op, event.ID)
+		}
+	}
+
+	select {
+	case event := <-resumed:
+		t.Errorf("Unexpected duplicate event: %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 带 selector 的重放按文档当前状态匹配，doc2 已删除，其事件无法判断而总会发送
+	filtered, err := collection.WatchFrom(watchCtx, map[string]any{"id": "doc2"}, 0)
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	events := receive(filtered, 2)
+	if events[0].Op != OperationInsert || events[1].Op != OperationDelete {
+		t.Errorf("Expected insert and delete for doc2, got %s and %s", events[0].Op, events[1].Op)
+	}
+}
+
+// TestCollection_Changelog 测试变更日志的保留策略、日志内容与 Truncate 事件
+func TestCollection_Changelog(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_collection_changelog.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name:     "testdb",
+		Path:     dbPath,
+		Password: "changelog-password",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	coll, err := db.Collection(ctx, "test", Schema{
+		PrimaryKey:      "id",
+		RevField:        "_rev",
+		EncryptedFields: []string{"secret"},
+		Changelog:       &ChangelogOptions{MaxEntries: 3},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		doc := map[string]any{"id": fmt.Sprintf("doc%d", i), "group": "a", "secret": fmt.Sprintf("top-secret-%d", i)}
+		if _, err := coll.Insert(ctx, doc); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	// 日志只保留最近 3 条，且不包含文档内容
+	col := coll.(*collection)
+	var seqs []int64
+	err = col.store.Iterate(ctx, col.changelogBucket(), func(k, v []byte) error {
+		if strings.Contains(string(v), "top-secret") {
+			t.Errorf("Changelog entry contains document data: %s", v)
+		}
+		seq, err := strconv.ParseInt(string(k), 10, 64)
+		seqs = append(seqs, seq)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to read changelog: %v", err)
+	}
+	if !reflect.DeepEqual(seqs, []int64{3, 4, 5}) {
+		t.Errorf("Expected retained sequences [3 4 5], got %v", seqs)
+	}
+
+	receive := func(ch <-chan CDCEvent) CDCEvent {
+		t.Helper()
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				t.Fatal("Watch channel closed")
+			}
+			return event
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for event")
+			return CDCEvent{}
+		}
+	}
+
+	// 从已清理的位置恢复时从最早保留的日志开始，Doc 为解密后的当前文档
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := coll.WatchFrom(watchCtx, map[string]any{"group": "a"}, 0)
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	for i := 3; i <= 5; i++ {
+		event := receive(stream)
+		if event.Sequence != int64(i) || event.ID != fmt.Sprintf("doc%d", i) {
+			t.Errorf("Expected event %d for doc%d, got %d for %s", i, i, event.Sequence, event.ID)
+		}
+		if event.Doc["secret"] != fmt.Sprintf("top-secret-%d", i) {
+			t.Errorf("Expected decrypted document in event, got %v", event.Doc)
+		}
+	}
+
+	// Truncate 事件没有文档内容，不受 selector 限制
+	if err := coll.Truncate(ctx); err != nil {
+		t.Fatalf("Failed to truncate: %v", err)
+	}
+	if event := receive(stream); event.Op != OperationTruncate || event.Sequence != 6 {
+		t.Errorf("Expected truncate event with sequence 6, got %s %d", event.Op, event.Sequence)
+	}
+
+	// 未启用变更日志的集合不写日志，Watch 只转发实时事件
+	plain, err := db.Collection(ctx, "plain", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	live := plain.Watch(watchCtx, map[string]any{"group": "a"})
+	if _, err := plain.Insert(ctx, map[string]any{"id": "p1", "group": "b"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if _, err := plain.Insert(ctx, map[string]any{"id": "p2", "group": "a"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if event := receive(live); event.ID != "p2" || event.Sequence != 0 {
+		t.Errorf("Expected live event for p2 without sequence, got %s %d", event.ID, event.Sequence)
+	}
+	if _, err := plain.WatchFrom(watchCtx, nil, 0); !IsValidationError(err) {
+		t.Errorf("Expected validation error from WatchFrom without changelog, got %v", err)
+	}
+	plainCol := plain.(*collection)
+	_ = plainCol.store.Iterate(ctx, plainCol.changelogBucket(), func(k, _ []byte) error {
+		t.Errorf("Unexpected changelog entry %s", k)
+		return nil
+	})
+
+	// 并发写入时按序列号顺序投递，不丢失也不重复
+	concurrent, err := db.Collection(ctx, "concurrent", Schema{PrimaryKey: "id", RevField: "_rev", Changelog: &ChangelogOptions{}})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	all, err := concurrent.WatchFrom(watchCtx, nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				if _, err := concurrent.Upsert(ctx, map[string]any{"id": fmt.Sprintf("w%d-%d", w, i%5), "n": i}); err != nil {
+					t.Errorf("Failed to upsert: %v", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	var last int64
+	for i := 0; i < 100; i++ {
+		event := receive(all)
+		if event.Sequence <= last {
+			t.Fatalf("Expected increasing sequence after %d, got %d", last, event.Sequence)
+		}
+		last = event.Sequence
+	}
+}
+
+// TestCollection_WatchByOperation 测试按操作类型过滤的变更流
+func TestCollection_WatchByOperation(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "test", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	inserts := collection.WatchInserts(watchCtx)
+	updates := collection.WatchUpdates(watchCtx)
+	deletes := collection.WatchDeletes(watchCtx)
+
+	if _, err := collection.Insert(ctx, map[string]any{"id": "doc1", "name": "Test"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if _, err := collection.Upsert(ctx, map[string]any{"id": "doc1", "name": "Updated"}); err != nil {
+		t.Fatalf("Failed to upsert: %v", err)
+	}
+	if err := collection.Remove(ctx, "doc1"); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+
+	for _, tc := range []struct {
+		ch <-chan ChangeEvent
+		op Operation
+	}{
+		{inserts, OperationInsert},
+		{updates, OperationUpdate},
+		{deletes, OperationDelete},
+	} {
+		select {
+		case event := <-tc.ch:
+			if event.Op != tc.op || event.ID != "doc1" {
+				t.Errorf("Expected %s event for doc1, got %s event for %s", tc.op, event.Op, event.ID)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for %s event", tc.op)
+		}
+		// 其他操作类型的事件不应被转发
+		select {
+		case event := <-tc.ch:
+			t.Errorf("Unexpected %s event on %s stream", event.Op, tc.op)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	// ctx 取消后通道关闭
+	cancel()
+	for _, ch := range []<-chan ChangeEvent{inserts, updates, deletes} {
+		select {
+		case _, ok := <-ch:
+			if ok {
+				t.Error("Expected no further events after cancel")
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for channel to close")
+		}
+	}
+}
+
+func TestCollection_FindByIDs(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDatabase(t)
+
+	collection, err := db.Collection(ctx, "test", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		if _, err := collection.Insert(ctx, map[string]any{"id": fmt.Sprintf("doc%d", i), "n": i}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	ids := []string{"doc3", "missing", "doc1", "doc2", "doc1"}
+	docs, err := collection.FindByIDs(ctx, ids)
+	if err != nil {
+		t.Fatalf("FindByIDs failed: %v", err)
+	}
+	if len(docs) != len(ids) {
+		t.Fatalf("Expected %d results, got %d", len(ids), len(docs))
+	}
+	for i, id := range ids {
+		if id == "missing" {
+			if docs[i] != nil {
+				t.Errorf("Expected nil for missing id at %d, got %v", i, docs[i].Data())
+			}
+			continue
+		}
+		if docs[i] == nil {
+			t.Errorf("Expected document %s at %d, got nil", id, i)
+			continue
+		}
+		if docs[i].ID() != id {
+			t.Errorf("Expected document %s at %d, got %s", id, i, docs[i].ID())
+		}
+	}
+	if docs[0].GetInt("n") != 3 {
+		t.Errorf("Expected n=3 for doc3, got %v", docs[0].Get("n"))
+	}
+
+	empty, err := collection.FindByIDs(ctx, []string{})
+	if err != nil {
+		t.Fatalf("FindByIDs with empty ids failed: %v", err)
+	}
+	if empty == nil || len(empty) != 0 {
+		t.Errorf("Expected empty non-nil slice, got %v", empty)
+	}
+}
+
+func TestCollection_FindOneAndDelete(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDatabase(t)
+
+	collection, err := db.Collection(ctx, "tasks", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		Indexes:    []Index{{Fields: []string{"status"}, Name: "status_idx"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	const total = 20
+	for i := 0; i < total; i++ {
+		if _, err := collection.Insert(ctx, map[string]any{"id": fmt.Sprintf("task%02d", i), "status": "pending"}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	if _, err := collection.Insert(ctx, map[string]any{"id": "done", "status": "done"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	var mu sync.Mutex
+	consumed := make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doc, err := collection.FindOneAndDelete(ctx, map[string]any{"status": "pending"})
+			if err != nil {
+				t.Errorf("FindOneAndDelete failed: %v", err)
+				return
+			}
+			if doc == nil {
+				t.Errorf("Expected a task, got nil")
+				return
+			}
+			mu.Lock()
+			consumed[doc.ID()]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(consumed) != total {
+		t.Errorf("Expected %d distinct tasks consumed, got %d", total, len(consumed))
+	}
+	for id, n := range consumed {
+		if n != 1 {
+			t.Errorf("Task %s consumed %d times", id, n)
+		}
+	}
+
+	// 队列为空时返回 nil, nil
+	doc, err := collection.FindOneAndDelete(ctx, map[string]any{"status": "pending"})
+	if err != nil || doc != nil {
+		t.Errorf("Expected nil, nil on empty queue, got %v, %v", doc, err)
+	}
+
+	// 未匹配的文档保持不变，索引中已删除的条目不再返回
+	count, err := collection.Count(ctx)
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 remaining document, got %d", count)
+	}
+	results, err := collection.Find(map[string]any{"status": "pending"}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no pending tasks, got %d", len(results))
+	}
+}
+
+func TestCollection_FindAndModify(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "jobs", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	for _, id := range []string{"b", "a", "c"} {
+		if _, err := collection.Insert(ctx, map[string]any{"id": id, "status": "pending", "attempts": 0}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	// 多个匹配时按主键顺序取第一个，默认返回更新前的文档
+	old, err := collection.FindAndModify(ctx, map[string]any{"status": "pending"},
+		map[string]any{"$set": map[string]any{"status": "running"}, "$inc": map[string]any{"attempts": 1}},
+		FindAndModifyOptions{})
+	if err != nil {
+		t.Fatalf("FindAndModify failed: %v", err)
+	}
+	if old == nil || old.ID() != "a" || old.Get("status") != "pending" {
+		t.Fatalf("Expected old state of a, got %v", old)
+	}
+	stored, _ := collection.FindByID(ctx, "a")
+	if stored.Get("status") != "running" || stored.Get("attempts") != 1.0 {
+		t.Errorf("Document a not updated: %v", stored.Data())
+	}
+	if rev, _ := stored.Get("_rev").(string); !strings.HasPrefix(rev, "2-") {
+		t.Errorf("Expected second revision, got %v", stored.Get("_rev"))
+	}
+
+	// ReturnNew 返回更新后的文档；普通字段按合并处理
+	updated, err := collection.FindAndModify(ctx, map[string]any{"status": "pending"},
+		map[string]any{"status": "done"}, FindAndModifyOptions{ReturnNew: true})
+	if err != nil {
+		t.Fatalf("FindAndModify failed: %v", err)
+	}
+	if updated == nil || updated.ID() != "b" || updated.Get("status") != "done" || updated.Get("attempts") != 0.0 {
+		t.Errorf("Expected new state of b, got %v", updated)
+	}
+
+	// 没有匹配且未启用 Upsert
+	none, err := collection.FindAndModify(ctx, map[string]any{"status": "missing"},
+		map[string]any{"$set": map[string]any{"x": 1}}, FindAndModifyOptions{ReturnNew: true})
+	if err != nil || none != nil {
+		t.Errorf("Expected nil, nil without match, got %v, %v", none, err)
+	}
+
+	// Upsert：以 filter 的等值条件为基础插入，修订号为第 1 代
+	inserted, err := collection.FindAndModify(ctx, map[string]any{"id": "d", "status": "new"},
+		map[string]any{"$inc": map[string]any{"attempts": 1}}, FindAndModifyOptions{Upsert: true, ReturnNew: true})
+	if err != nil {
+		t.Fatalf("FindAndModify upsert failed: %v", err)
+	}
+	if inserted == nil || inserted.ID() != "d" || inserted.Get("status") != "new" || inserted.Get("attempts") != 1.0 {
+		t.Fatalf("Unexpected upserted document: %v", inserted)
+	}
+	if rev, _ := inserted.Get("_rev").(string); !strings.HasPrefix(rev, "1-") {
+		t.Errorf("Expected first revision on upsert, got %v", inserted.Get("_rev"))
+	}
+	// 未设置 ReturnNew 时 upsert 返回 nil
+	before, err := collection.FindAndModify(ctx, map[string]any{"id": "e"},
+		map[string]any{"$set": map[string]any{"status": "new"}}, FindAndModifyOptions{Upsert: true})
+	if err != nil || before != nil {
+		t.Errorf("Expected nil old document on upsert, got %v, %v", before, err)
+	}
+	if _, err := collection.FindByID(ctx, "e"); err != nil {
+		t.Errorf("Upserted document e not found: %v", err)
+	}
+
+	// 无法确定主键时 upsert 失败；非法更新不修改文档
+	if _, err := collection.FindAndModify(ctx, map[string]any{"status": "other"},
+		map[string]any{"$set": map[string]any{"x": 1}}, FindAndModifyOptions{Upsert: true}); err == nil {
+		t.Error("Expected error when upserting without primary key")
+	}
+	if _, err := collection.FindAndModify(ctx, map[string]any{"id": "a"},
+		map[string]any{"$inc": map[string]any{"status": 1}}, FindAndModifyOptions{}); !IsValidationError(err) {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+
+	// 并发认领任务：每个文档只会被认领一次
+	for i := 0; i < 10; i++ {
+		if _, err := collection.Insert(ctx, map[string]any{"id": fmt.Sprintf("q%02d", i), "status": "queued"}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	var mu sync.Mutex
+	claimed := make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doc, err := collection.FindAndModify(ctx, map[string]any{"status": "queued"},
+				map[string]any{"$set": map[string]any{"status": "claimed"}}, FindAndModifyOptions{})
+			if err != nil {
+				t.Errorf("Concurrent FindAndModify failed: %v", err)
+				return
+			}
+			if doc != nil {
+				mu.Lock()
+				claimed[doc.ID()]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(claimed) != 10 {
+		t.Errorf("Expected 10 claimed documents, got %d", len(claimed))
+	}
+	for id, n := range claimed {
+		if n != 1 {
+			t.Errorf("Document %s claimed %d times", id, n)
+		}
+	}
+}
+
+func TestCollection_FindOrCreate(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDatabase(t)
+
+	collection, err := db.Collection(ctx, "users", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	// 每个 goroutine 的默认值使用不同的主键，只有串行化才能保证只创建一次
+	const workers = 20
+	selector := map[string]any{"email": "alice@example.com", "role": map[string]any{"$eq": "admin"}}
+	var createdCount int32
+	var mu sync.Mutex
+	ids := make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			doc, created, err := collection.FindOrCreate(ctx, selector, map[string]any{
+				"id":   fmt.Sprintf("user%02d", i),
+				"name": "Alice",
+			})
+			if err != nil {
+				t.Errorf("FindOrCreate failed: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if created {
+				createdCount++
+			}
+			ids[doc.ID()]++
+		}(i)
+	}
+	wg.Wait()
+
+	if createdCount != 1 {
+		t.Errorf("Expected exactly 1 creation, got %d", createdCount)
+	}
+	if len(ids) != 1 {
+		t.Errorf("Expected all callers to get the same document, got %v", ids)
+	}
+	count, err := collection.Count(ctx)
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 document, got %d", count)
+	}
+
+	// 新建文档包含 defaults 与 selector 中的等值字段
+	doc, created, err := collection.FindOrCreate(ctx, selector, nil)
+	if err != nil {
+		t.Fatalf("FindOrCreate failed: %v", err)
+	}
+	if created {
+		t.Error("Expected existing document to be found")
+	}
+	if doc.GetString("name") != "Alice" || doc.GetString("email") != "alice@example.com" || doc.GetString("role") != "admin" {
+		t.Errorf("Unexpected document data: %v", doc.Data())
+	}
+
+	// 非等值条件不会写入新文档
+	doc, created, err = collection.FindOrCreate(ctx, map[string]any{"email": "bob@example.com", "age": map[string]any{"$gt": 18}}, map[string]any{"id": "bob"})
+	if err != nil {
+		t.Fatalf("FindOrCreate failed: %v", err)
+	}
+	if !created {
+		t.Error("Expected document to be created")
+	}
+	if _, ok := doc.Data()["age"]; ok {
+		t.Errorf("Expected non-equality condition to be ignored, got %v", doc.Data())
+	}
+
+	// 缺少主键时返回插入错误
+	if _, _, err := collection.FindOrCreate(ctx, map[string]any{"email": "carol@example.com"}, nil); err == nil {
+		t.Error("Expected error when created document has no primary key")
+	}
+}
+
+func TestCollection_Iterator(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDatabase(t)
+
+	collection, err := db.Collection(ctx, "test", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := collection.Insert(ctx, map[string]any{"id": fmt.Sprintf("old%d", i), "n": i}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	it, err := collection.Iterator(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	defer it.Close()
+
+	seen := make(map[string]bool)
+	inserted := false
+	for it.Next() {
+		seen[it.Document().ID()] = true
+		if !inserted {
+			// 迭代过程中插入新文档，打开的迭代器不应看到它们
+			for i := 0; i < 5; i++ {
+				if _, err := collection.Insert(ctx, map[string]any{"id": fmt.Sprintf("new%d", i), "n": i}); err != nil {
+					t.Fatalf("Failed to insert during iteration: %v", err)
+				}
+			}
+			inserted = true
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Iterator error: %v", err)
+	}
+	if len(seen) != 5 {
+		t.Errorf("Expected 5 documents from snapshot, got %d: %v", len(seen), seen)
+	}
+	for id := range seen {
+		if strings.HasPrefix(id, "new") {
+			t.Errorf("Iterator should not see document inserted after creation: %s", id)
+		}
+	}
+	if err := it.Close(); err != nil {
+		t.Fatalf("Failed to close iterator: %v", err)
+	}
+	if it.Next() {
+		t.Error("Next should return false after Close")
+	}
+
+	it2, err := collection.Iterator(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	defer it2.Close()
+
+	count := 0
+	newCount := 0
+	for it2.Next() {
+		count++
+		if strings.HasPrefix(it2.Document().ID(), "new") {
+			newCount++
+		}
+	}
+	if err := it2.Err(); err != nil {
+		t.Fatalf("Iterator error: %v", err)
+	}
+	if count != 10 || newCount != 5 {
+		t.Errorf("Expected 10 documents (5 new) from new iterator, got %d (%d new)", count, newCount)
+	}
+}
+
+func TestCollection_ReadTransformer(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDatabase(t)
+
+	users, err := db.Collection(ctx, "users", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	users.SetReadTransformer(FieldMaskTransformer("password"))
+
+	assertMasked := func(where string, doc Document) {
+		t.Helper()
+		if doc == nil {
+			t.Fatalf("%s: document is nil", where)
+		}
+		if _, ok := doc.Data()["password"]; ok {
+			t.Errorf("%s: password should be stripped, got %v", where, doc.Data())
+		}
+		if doc.GetString("name") == "" {
+			t.Errorf("%s: name should be kept, got %v", where, doc.Data())
+		}
+	}
+
+	inserted, err := users.Insert(ctx, map[string]any{"id": "u1", "name": "Alice", "password": "secret1"})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	assertMasked("Insert", inserted)
+
+	bulk, err := users.BulkInsert(ctx, []map[string]any{
+		{"id": "u2", "name": "Bob", "password": "secret2"},
+		{"id": "u3", "name": "Carol", "password": "secret3"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to bulk insert: %v", err)
+	}
+	for _, doc := range bulk {
+		assertMasked("BulkInsert", doc)
+	}
+
+	doc, err := users.FindByID(ctx, "u1")
+	if err != nil {
+		t.Fatalf("Failed to find by id: %v", err)
+	}
+	assertMasked("FindByID", doc)
+
+	all, err := users.All(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get all: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("Expected 3 documents, got %d", len(all))
+	}
+	for _, doc := range all {
+		assertMasked("All", doc)
+	}
+
+	found, err := users.Find(map[string]any{"name": "Bob"}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to find: %v", err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("Expected 1 document, got %d", len(found))
+	}
+	assertMasked("Find", found[0])
+
+	byIDs, err := users.FindByIDs(ctx, []string{"u2", "missing", "u3"})
+	if err != nil {
+		t.Fatalf("Failed to find by ids: %v", err)
+	}
+	assertMasked("FindByIDs", byIDs[0])
+	if byIDs[1] != nil {
+		t.Errorf("Missing id should map to nil, got %v", byIDs[1])
+	}
+	assertMasked("FindByIDs", byIDs[2])
+
+	// 查询批量更新基于存储中的原始文档，不应丢失被掩码的字段
+	if _, err := users.Find(map[string]any{"id": "u1"}).Update(ctx, map[string]any{"name": "Alice2"}); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+
+	// 取消转换器后，存储中的密码仍然完整
+	users.SetReadTransformer(nil)
+	for id, want := range map[string]string{"u1": "secret1", "u2": "secret2", "u3": "secret3"} {
+		doc, err := users.FindByID(ctx, id)
+		if err != nil {
+			t.Fatalf("Failed to find %s: %v", id, err)
+		}
+		if got := doc.GetString("password"); got != want {
+			t.Errorf("Stored password for %s = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestCollection_CountBy(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDatabase(t)
+
+	collection, err := db.Collection(ctx, "products", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	categories := []string{"book", "book", "book", "book", "book", "toy", "toy", "toy", "food", "food"}
+	countries := []string{"cn", "us"}
+	for i, category := range categories {
+		doc := map[string]any{
+			"id":       fmt.Sprintf("p%d", i),
+			"category": category,
+		}
+		if i < 9 {
+			doc["supplier"] = map[string]any{"country": countries[i%2]}
+		}
+		if _, err := collection.Insert(ctx, doc); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	counts, err := collection.CountBy(ctx, "category")
+	if err != nil {
+		t.Fatalf("CountBy failed: %v", err)
+	}
+	expected := map[string]int{"book": 5, "toy": 3, "food": 2}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("Expected %v, got %v", expected, counts)
+	}
+
+	// 嵌套路径，缺少该字段的文档不计入
+	counts, err = collection.CountBy(ctx, "supplier.country")
+	if err != nil {
+		t.Fatalf("CountBy failed: %v", err)
+	}
+	expected = map[string]int{"cn": 5, "us": 4}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("Expected %v, got %v", expected, counts)
+	}
+}
+
+func TestCollection_MaxMin(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDatabase(t)
+
+	collection, err := db.Collection(ctx, "products", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		Indexes:    []Index{{Fields: []string{"price"}, Name: "price_idx"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	// 集合为空时返回 nil
+	if v, err := collection.Max(ctx, "price", nil); err != nil || v != nil {
+		t.Errorf("Max on empty collection = %v, %v; want nil, nil", v, err)
+	}
+
+	products := []map[string]any{
+		{"id": "p1", "name": "pear", "price": 9, "category": "fruit"},
+		{"id": "p2", "name": "apple", "price": 12.5, "category": "fruit"},
+		{"id": "p3", "name": "zucchini", "price": 3, "category": "vegetable"},
+		{"id": "p4", "name": "carrot", "price": 100, "category": "vegetable"},
+		{"id": "p5", "name": "mango", "category": "fruit"},
+	}
+	for _, p := range products {
+		if _, err := collection.Insert(ctx, p); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	t.Run("numeric", func(t *testing.T) {
+		// price 上有索引，走索引扫描；数值比较而非字典序（100 > 12.5 > 9）
+		max, err := collection.Max(ctx, "price", nil)
+		if err != nil {
+			t.Fatalf("Max failed: %v", err)
+		}
+		if max != float64(100) {
+			t.Errorf("Max(price) = %v, want 100", max)
+		}
+		min, err := collection.Min(ctx, "price", nil)
+		if err != nil {
+			t.Fatalf("Min failed: %v", err)
+		}
+		if min != float64(3) {
+			t.Errorf("Min(price) = %v, want 3", min)
+		}
+	})
+
+	t.Run("string", func(t *testing.T) {
+		max, err := collection.Max(ctx, "name", nil)
+		if err != nil {
+			t.Fatalf("Max failed: %v", err)
+		}
+		if max != "zucchini" {
+			t.Errorf("Max(name) = %v, want zucchini", max)
+		}
+		min, err := collection.Min(ctx, "name", nil)
+		if err != nil {
+			t.Fatalf("Min failed: %v", err)
+		}
+		if min != "apple" {
+			t.Errorf("Min(name) = %v, want apple", min)
+		}
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		selector := map[string]any{"category": "fruit"}
+		max, err := collection.Max(ctx, "price", selector)
+		if err != nil {
+			t.Fatalf("Max failed: %v", err)
+		}
+		if max != 12.5 {
+			t.Errorf("Max(price, fruit) = %v, want 12.5", max)
+		}
+		min, err := collection.Min(ctx, "price", selector)
+		if err != nil {
+			t.Fatalf("Min failed: %v", err)
+		}
+		if min != float64(9) {
+			t.Errorf("Min(price, fruit) = %v, want 9", min)
+		}
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		selector := map[string]any{"category": "meat"}
+		if v, err := collection.Max(ctx, "price", selector); err != nil || v != nil {
+			t.Errorf("Max with no match = %v, %v; want nil, nil", v, err)
+		}
+		if v, err := collection.Min(ctx, "name", selector); err != nil || v != nil {
+			t.Errorf("Min with no match = %v, %v; want nil, nil", v, err)
+		}
+	})
+
+	t.Run("read transformer", func(t *testing.T) {
+		// 索引中是原始值，设置读取转换器后应从转换后的文档取值
+		collection.SetReadTransformer(ReadTransformerFunc(func(ctx context.Context, doc Document) (Document, error) {
+			out := doc.Clone()
+			if price, ok := out.Data()["price"].(float64); ok {
+				out.Data()["price"] = price * 2
+			}
+			return out, nil
+		}))
+		defer collection.SetReadTransformer(nil)
+
+		if max, err := collection.Max(ctx, "price", nil); err != nil || max != float64(200) {
+			t.Errorf("Max(price) = %v, %v; want 200", max, err)
+		}
+		if min, err := collection.Min(ctx, "price", nil); err != nil || min != float64(6) {
+			t.Errorf("Min(price) = %v, %v; want 6", min, err)
+		}
+
+		collection.SetReadTransformer(FieldMaskTransformer("price"))
+		if max, err := collection.Max(ctx, "price", nil); err != nil || max != nil {
+			t.Errorf("Max of masked field = %v, %v; want nil, nil", max, err)
+		}
+	})
+}
+
+func TestCollection_Distinct(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "items", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		Indexes:    []Index{{Fields: []string{"category"}, Name: "category_idx"}},
+	})
+
+	items := []map[string]any{
+		{"id": "1", "category": "books", "tags": []any{"go", "db"}, "meta": map[string]any{"color": "red"}, "mixed": 2},
+		{"id": "2", "category": "music", "tags": []any{"go"}, "meta": map[string]any{"color": "blue"}, "mixed": "b"},
+		{"id": "3", "category": "books", "tags": []any{}, "mixed": true},
+		{"id": "4", "category": nil, "tags": "solo", "meta": map[string]any{"color": "red"}, "mixed": 1},
+		{"id": "5", "mixed": "a"},
+		{"id": "6", "category": "games", "mixed": 2},
+	}
+	for _, item := range items {
+		if _, err := collection.Insert(ctx, item); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		field    string
+		filter   map[string]any
+		expected []any
+	}{
+		// 使用索引，缺失与 null 被忽略
+		{"indexed", "category", nil, []any{"books", "games", "music"}},
+		{"filtered", "category", map[string]any{"mixed": map[string]any{"$type": "number"}}, []any{"books", "games"}},
+		{"array explode", "tags", nil, []any{"db", "go", "solo"}},
+		{"nested", "meta.color", nil, []any{"blue", "red"}},
+		{"mixed types", "mixed", nil, []any{1.0, 2.0, "a", "b", true}},
+		{"missing field", "unknown", nil, []any{}},
+	}
+	for _, tt := range tests {
+		values, err := collection.Distinct(ctx, tt.field, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: Distinct failed: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(values, tt.expected) {
+			t.Errorf("%s: Distinct(%s) = %v, want %v", tt.name, tt.field, values, tt.expected)
+		}
+	}
+
+	// 链式调用
+	values, err := collection.Find(map[string]any{"category": "books"}).Distinct(ctx, "tags")
+	if err != nil {
+		t.Fatalf("Query.Distinct failed: %v", err)
+	}
+	if !reflect.DeepEqual(values, []any{"db", "go"}) {
+		t.Errorf("Query.Distinct = %v", values)
+	}
+
+	// 大基数字段
+	docs := make([]map[string]any, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		docs = append(docs, map[string]any{"id": fmt.Sprintf("big%04d", i), "category": "bulk", "n": i % 500})
+	}
+	if _, err := collection.BulkInsert(ctx, docs); err != nil {
+		t.Fatalf("Failed to bulk insert: %v", err)
+	}
+	values, err = collection.Distinct(ctx, "n", nil)
+	if err != nil {
+		t.Fatalf("Distinct failed: %v", err)
+	}
+	if len(values) != 500 || values[0] != 0.0 || values[499] != 499.0 {
+		t.Errorf("Unexpected high-cardinality result: %d values", len(values))
+	}
+	values, _ = collection.Distinct(ctx, "category", nil)
+	if !reflect.DeepEqual(values, []any{"books", "bulk", "games", "music"}) {
+		t.Errorf("Indexed Distinct after bulk insert = %v", values)
+	}
+}
+
+func TestCollection_Aggregate(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "orders", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	// 空集合分组不产生任何结果
+	results, err := collection.Aggregate(ctx, []AggregateStage{GroupStage{Accumulators: map[string]Accumulator{"n": Count()}}})
+	if err != nil || len(results) != 0 {
+		t.Errorf("Group on empty collection = %v, %v; want no groups", results, err)
+	}
+
+	orders := []map[string]any{
+		{"id": "o1", "customer": "alice", "status": "paid", "amount": 10, "item": "pen", "meta": map[string]any{"region": "north"}},
+		{"id": "o2", "customer": "alice", "status": "paid", "amount": 5.5, "item": "ink", "meta": map[string]any{"region": "south"}},
+		{"id": "o3", "customer": "bob", "status": "paid", "amount": 20, "item": "pad"},
+		{"id": "o4", "customer": "bob", "status": "open", "amount": 7, "item": "pen"},
+		{"id": "o5", "status": "paid", "amount": 3, "item": "cap"},
+		{"id": "o6", "customer": nil, "status": "paid", "item": "box"},
+	}
+	for _, order := range orders {
+		if _, err := collection.Insert(ctx, order); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	results, err = collection.Pipeline().
+		Match(map[string]any{"status": "paid"}).
+		Group([]string{"customer"}, map[string]Accumulator{
+			"total": Sum("amount"),
+			"avg":   Avg("amount"),
+			"min":   Min("amount"),
+			"max":   Max("amount"),
+			"count": Count(),
+			"items": Push("item"),
+		}).
+		Sort(map[string]string{"total": "desc"}).
+		Exec(ctx)
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	expected := []map[string]any{
+		{"_id": "bob", "total": int64(20), "avg": 20.0, "min": 20.0, "max": 20.0, "count": 1, "items": []any{"pad"}},
+		{"_id": "alice", "total": 15.5, "avg": 7.75, "min": 5.5, "max": 10.0, "count": 2, "items": []any{"pen", "ink"}},
+		// 缺失与 null 的分组字段归为同一组；没有数值时 Avg/Min/Max 为 nil
+		{"_id": nil, "total": int64(3), "avg": 3.0, "min": 3.0, "max": 3.0, "count": 2, "items": []any{"cap", "box"}},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Unexpected aggregate results:\n got %v\nwant %v", results, expected)
+	}
+
+	// 多字段分组、Limit 与 Project
+	results, err = collection.Aggregate(ctx, []AggregateStage{
+		GroupStage{By: []string{"status", "item"}, Accumulators: map[string]Accumulator{"n": Count()}},
+		SortStage{Fields: map[string]string{"n": "desc", "_id.item": "asc"}},
+		LimitStage{N: 2},
+		ProjectStage{Exclude: []string{"_id.status"}},
+	})
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	expected = []map[string]any{
+		{"_id": map[string]any{"item": "box"}, "n": 1},
+		{"_id": map[string]any{"item": "cap"}, "n": 1},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Unexpected aggregate results:\n got %v\nwant %v", results, expected)
+	}
+
+	results, err = collection.Pipeline().
+		Match(map[string]any{"meta.region": map[string]any{"$exists": true}}).
+		Project([]string{"id", "meta.region"}, nil).
+		Sort(map[string]string{"id": "asc"}).
+		Exec(ctx)
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	expected = []map[string]any{
+		{"id": "o1", "meta": map[string]any{"region": "north"}},
+		{"id": "o2", "meta": map[string]any{"region": "south"}},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Unexpected projection results:\n got %v\nwant %v", results, expected)
+	}
+
+	// 非首个 Match 阶段在内存中过滤
+	results, err = collection.Pipeline().
+		Group([]string{"customer"}, map[string]Accumulator{"n": Count()}).
+		Match(map[string]any{"n": map[string]any{"$gte": 2}}).
+		Sort(map[string]string{"_id": "asc"}).
+		Exec(ctx)
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if len(results) != 3 || results[0]["_id"] != nil || results[1]["_id"] != "alice" || results[2]["_id"] != "bob" {
+		t.Errorf("Unexpected match-after-group results: %v", results)
+	}
+
+	// 非法阶段
+	invalid := [][]AggregateStage{
+		{LimitStage{N: 0}},
+		{ProjectStage{Include: []string{"a"}, Exclude: []string{"b"}}},
+		{SortStage{Fields: map[string]string{"amount": "up"}}},
+		{GroupStage{Accumulators: map[string]Accumulator{"_id": Count()}}},
+	}
+	for _, pipeline := range invalid {
+		if _, err := collection.Aggregate(ctx, pipeline); !IsValidationError(err) {
+			t.Errorf("Expected validation error for %#v, got %v", pipeline, err)
+		}
+	}
+}
+
+func TestAggregate_SumOverflow(t *testing.T) {
+	state := &accumulatorState{acc: Sum("n"), parts: []string{"n"}}
+	state.add(map[string]any{"n": int64(math.MaxInt64)})
+	state.add(map[string]any{"n": int64(1)})
+	sum, ok := state.result().(float64)
+	if !ok || sum != float64(math.MaxInt64)+1 {
+		t.Errorf("Expected overflow to switch to float64, got %#v", state.result())
+	}
+
+	state = &accumulatorState{acc: Sum("n"), parts: []string{"n"}}
+	state.add(map[string]any{"n": int64(math.MaxInt64)})
+	state.add(map[string]any{"n": int64(-1)})
+	state.add(map[string]any{"n": "not a number"})
+	if got := state.result(); got != int64(math.MaxInt64-1) {
+		t.Errorf("Expected exact int64 sum, got %#v", got)
+	}
+}
+
+func TestCollection_ObserveAggregate(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "orders", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	observeCtx, cancel := context.WithCancel(ctx)
+	results := collection.ObserveAggregate(observeCtx, []AggregateStage{
+		MatchStage{Filter: map[string]any{"status": "paid"}},
+		GroupStage{Accumulators: map[string]Accumulator{"total": Sum("amount"), "count": Count(), "avg": Avg("amount")}},
+	})
+
+	next := func() map[string]any {
+		t.Helper()
+		select {
+		case result, ok := <-results:
+			if !ok {
+				t.Fatal("result channel closed unexpectedly")
+			}
+			return result
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for aggregate result")
+		}
+		return nil
+	}
+
+	// 订阅时立即发送初始结果
+	if initial := next(); initial["count"] != 0 || initial["avg"] != nil {
+		t.Fatalf("Unexpected initial result: %v", initial)
+	}
+
+	// 快速插入 10 个文档，防抖后只触发少量重新聚合
+	for i := 1; i <= 10; i++ {
+		if _, err := collection.Insert(ctx, map[string]any{"id": fmt.Sprintf("o%02d", i), "status": "paid", "amount": i, "note": ""}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	emissions := 0
+	var last map[string]any
+	for last == nil || last["count"] != 10 {
+		last = next()
+		emissions++
+	}
+	if emissions >= 10 {
+		t.Errorf("Expected debounced emissions to be fewer than 10, got %d", emissions)
+	}
+	if numberToFloat64(last["total"]) != 55 || last["avg"] != 5.5 {
+		t.Errorf("Unexpected final aggregate: %v", last)
+	}
+
+	// 未被管道引用的字段变化、以及不匹配 MatchStage 的文档不会触发重新聚合
+	if _, err := collection.Upsert(ctx, map[string]any{"id": "o01", "status": "paid", "amount": 1, "note": "gift"}); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if _, err := collection.Insert(ctx, map[string]any{"id": "p01", "status": "pending", "amount": 100}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	select {
+	case result := <-results:
+		t.Errorf("Expected no emission for unrelated changes, got %v", result)
+	case <-time.After(AggregateDebounceDuration + 150*time.Millisecond):
+	}
+
+	// 文档离开结果集时重新聚合
+	if err := collection.Remove(ctx, "o10"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if result := next(); result["count"] != 9 || numberToFloat64(result["total"]) != 45 {
+		t.Errorf("Unexpected aggregate after remove: %v", result)
+	}
+
+	// ctx 取消后 channel 关闭
+	cancel()
+	select {
+	case _, ok := <-results:
+		if ok {
+			t.Error("Expected channel to be closed after cancellation")
+		}
+	case <-time.After(time.Second):
+		t.Error("Channel was not closed after cancellation")
+	}
+
+	groupCtx, groupCancel := context.WithCancel(ctx)
+	defer groupCancel()
+	grouped := collection.ObserveAggregate(groupCtx, []AggregateStage{GroupStage{By: []string{"status"}, Accumulators: map[string]Accumulator{"count": Count()}}})
+	result := <-grouped
+	if paid, _ := result["paid"].(map[string]any); paid["count"] != 9 {
+		t.Errorf("Unexpected grouped result: %v", result)
+	}
+
+	// 不支持的管道直接关闭 channel
+	if _, ok := <-collection.ObserveAggregate(ctx, []AggregateStage{LimitStage{N: 1}}); ok {
+		t.Error("Expected channel to be closed for unsupported pipeline")
+	}
+}
+
+func TestCollection_ForEach(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "items", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+
+	for i := 0; i < 20; i++ {
+		if _, err := collection.Insert(ctx, map[string]any{"id": fmt.Sprintf("item%02d", i), "n": i, "even": i%2 == 0}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	// 按过滤条件遍历
+	var ids []string
+	err := collection.ForEach(ctx, map[string]any{"even": true}, func(doc Document) error {
+		ids = append(ids, doc.ID())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEach failed: %v", err)
+	}
+	if len(ids) != 10 || ids[0] != "item00" || ids[9] != "item18" {
+		t.Errorf("Unexpected ForEach ids: %v", ids)
+	}
+
+	// 回调返回错误时停止遍历
+	stop := errors.New("stop")
+	visited := 0
+	err = collection.ForEach(ctx, nil, func(doc Document) error {
+		visited++
+		if visited == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || visited != 3 {
+		t.Errorf("Expected stop after 3 docs, got err=%v visited=%d", err, visited)
+	}
+
+	// 回调中写入集合不会死锁
+	err = collection.ForEach(ctx, map[string]any{"even": false}, func(doc Document) error {
+		return collection.Remove(ctx, doc.ID())
+	})
+	if err != nil {
+		t.Fatalf("ForEach with writes failed: %v", err)
+	}
+	if count, _ := collection.Count(ctx); count != 10 {
+		t.Errorf("Expected 10 docs after removal, got %d", count)
+	}
+
+	// Query.ForEach 遵循 Skip/Limit 与排序
+	ids = nil
+	err = collection.Find(nil).Skip(2).Limit(3).ForEach(ctx, func(doc Document) error {
+		ids = append(ids, doc.ID())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Query.ForEach failed: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"item04", "item06", "item08"}) {
+		t.Errorf("Unexpected Skip/Limit ids: %v", ids)
+	}
+	ids = nil
+	err = collection.Find(nil).Sort(map[string]string{"n": "desc"}).Limit(2).ForEach(ctx, func(doc Document) error {
+		ids = append(ids, doc.ID())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Sorted ForEach failed: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"item18", "item16"}) {
+		t.Errorf("Unexpected sorted ids: %v", ids)
+	}
+}
+
+func TestCollection_Stream(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "items", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+
+	for i := 0; i < 10; i++ {
+		if _, err := collection.Insert(ctx, map[string]any{"id": fmt.Sprintf("item%02d", i), "n": i}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	docs, errs := collection.Stream(ctx, map[string]any{"n": map[string]any{"$gte": 5}})
+	count := 0
+	for range docs {
+		count++
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if count != 5 {
+		t.Errorf("Expected 5 streamed docs, got %d", count)
+	}
+
+	// 取消后生产者退出并报告错误
+	cancelCtx, cancel := context.WithCancel(ctx)
+	docs, errs = collection.Stream(cancelCtx, nil)
+	<-docs
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
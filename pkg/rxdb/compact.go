@@ -0,0 +1,81 @@
+package rxdb
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// defaultAutoCompactInterval 自动压缩的默认间隔
+const defaultAutoCompactInterval = time.Hour
+
+// compactDiscardRatio Value Log GC 的丢弃比例：可丢弃数据超过 50% 的日志文件会被重写
+const compactDiscardRatio = 0.5
+
+// Compact 压缩底层存储，回收已删除或已覆盖数据占用的 Value Log 空间。
+// 与自动压缩互斥执行。
+func (d *database) Compact(ctx context.Context) error {
+	if err := d.beginOp(ctx); err != nil {
+		return err
+	}
+	defer d.endOp()
+	return d.compact(ctx)
+}
+
+// compact 执行一次压缩，同一时刻只有一次压缩在运行。
+func (d *database) compact(ctx context.Context) error {
+	d.compactMu.Lock()
+	defer d.compactMu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	store := d.store.badgerStore()
+	if store == nil {
+		// 其他存储后端自行管理空间回收
+		atomic.AddInt64(&d.compactRuns, 1)
+		return nil
+	}
+	if err := store.RunValueLogGC(compactDiscardRatio); err != nil {
+		return NewError(ErrorTypeIO, "compaction failed", err)
+	}
+	atomic.AddInt64(&d.compactRuns, 1)
+	return nil
+}
+
+// startAutoCompact 启动后台压缩 goroutine，按 interval 定期执行 compact，直到 stopAutoCompact。
+func (d *database) startAutoCompact(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultAutoCompactInterval
+	}
+	d.compactStop = make(chan struct{})
+	d.compactDone = make(chan struct{})
+
+	go func() {
+		defer close(d.compactDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-d.compactStop:
+				return
+			case <-ticker.C:
+				if err := d.compact(context.Background()); err != nil {
+					d.logger.Warn("Auto compaction failed", "name", d.name, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// stopAutoCompact 停止后台压缩并等待正在进行的压缩结束，可重复调用。
+func (d *database) stopAutoCompact() {
+	if d.compactStop == nil {
+		return
+	}
+	d.compactStopOnce.Do(func() {
+		close(d.compactStop)
+	})
+	<-d.compactDone
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -15,7 +19,7 @@ import (
 	"time"
 
 	"github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
-	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 )
 
 var (
@@ -119,8 +123,11 @@ type DatabaseOptions struct {
 	Name string
 	// Path 存储路径
 	Path string
-	// BadgerOptions Badger 存储选项
+	// BadgerOptions Badger 存储选项，仅在未设置 Backend 时生效
 	BadgerOptions badger.Options
+	// Backend 自定义存储后端（可选），为空时在 Path 下打开 BadgerDB。
+	// Path 仍用于附件、全文索引等旁路文件；数据库关闭时会调用 Backend.Close
+	Backend StorageBackend
 	// Password 数据库级密码（预留用于字段加密）
 	Password string
 	// MultiInstance 是否允许多实例（同名数据库多开）
@@ -135,12 +142,28 @@ type DatabaseOptions struct {
 	HashFunction func(data []byte) string
 	// GraphOptions 图数据库配置（可选）
 	GraphOptions *GraphOptions
+	// Logger 内部日志器（可选），为空时按 LogLevel 创建
+	Logger Logger
+	// LogLevel 日志级别（debug/info/warn/error），仅在未设置 Logger 时生效；均为空时沿用 logrus 标准日志器
+	LogLevel string
+	// MaxDocumentSize 单个文档序列化后的最大字节数，0 表示不限制
+	MaxDocumentSize int
+	// WriteRateLimit 每秒允许写入的文档数（所有集合共享），0 表示不限制
+	WriteRateLimit float64
+	// AutoCompact 是否在后台定期压缩存储（默认关闭）
+	AutoCompact bool
+	// AutoCompactInterval 自动压缩间隔，默认 1 小时
+	AutoCompactInterval time.Duration
+	// TTLPollInterval 设置了 Schema.TTL 的集合检查过期文档的间隔，默认 60 秒
+	TTLPollInterval time.Duration
+	// SchemaHooks 新建集合默认使用的生命周期钩子，可通过 Collection.SetHooks 按集合替换
+	SchemaHooks SchemaHooks
 }
 
 // database 是 Database 接口的默认实现。
 type database struct {
 	name        string
-	store       *badger.Store
+	store       *kvStore
 	collections map[string]*collection
 	mu          sync.RWMutex
 	activeOps   int32 // 使用 atomic 操作，避免为了计数而加锁
@@ -151,6 +174,17 @@ type database struct {
 	broadcaster *eventBroadcaster // 多实例事件广播器
 	lockFile    *os.File          // 文件锁（用于多实例选举）
 	isLeader    bool              // 是否为领导实例
+	maxDocSize  int               // 单文档大小上限（字节），0 表示不限制
+	writeLimit  *rate.Limiter     // 写入限流器，nil 表示不限制
+	ttlInterval time.Duration     // TTL 过期检查间隔
+	schemaHooks SchemaHooks       // 新建集合的默认生命周期钩子
+
+	// 存储压缩
+	compactMu       sync.Mutex    // 保证压缩不会并发执行
+	compactRuns     int64         // 已成功执行的压缩次数（atomic）
+	compactStop     chan struct{} // 通知自动压缩 goroutine 退出，未启用时为 nil
+	compactStopOnce sync.Once
+	compactDone     chan struct{} // 自动压缩 goroutine 退出后关闭
 
 	// 数据库级别订阅者管理
 	dbSubscribersMu   sync.RWMutex
@@ -161,14 +195,14 @@ type database struct {
 	// 图数据库相关
 	graphClient GraphDatabase
 	graphBridge GraphBridge
+
+	logger Logger // 内部日志器
 }
 
 // CreateDatabase 创建新的数据库实例。
 func CreateDatabase(ctx context.Context, opts DatabaseOptions) (Database, error) {
-	logrus.WithFields(logrus.Fields{
-		"name": opts.Name,
-		"path": opts.Path,
-	}).Debug("Creating database")
+	logger := resolveLogger(opts)
+	logger.Debug("Creating database", "name", opts.Name, "path", opts.Path)
 
 	if opts.Name == "" {
 		return nil, errors.New("database name required")
@@ -185,7 +219,7 @@ func CreateDatabase(ctx context.Context, opts DatabaseOptions) (Database, error)
 			shouldCloseExisting = true
 		} else if opts.IgnoreDuplicate {
 			dbRegistryMu.Unlock()
-			logrus.WithField("name", opts.Name).Debug("Returning existing database")
+			logger.Debug("Returning existing database", "name", opts.Name)
 			return existing, nil
 		} else if !opts.MultiInstance {
 			dbRegistryMu.Unlock()
@@ -197,7 +231,7 @@ func CreateDatabase(ctx context.Context, opts DatabaseOptions) (Database, error)
 	// 在释放锁后关闭已存在的数据库，避免死锁
 	// Close 方法内部需要获取 dbRegistryMu 锁
 	if shouldCloseExisting {
-		logrus.WithField("name", opts.Name).Info("Closing duplicate database")
+		logger.Info("Closing duplicate database", "name", opts.Name)
 		_ = existing.Close(ctx)
 	}
 
@@ -207,12 +241,17 @@ func CreateDatabase(ctx context.Context, opts DatabaseOptions) (Database, error)
 		opts.BadgerOptions.EncryptionKey = hash[:]
 	}
 
-	store, err := badger.Open(opts.Path, opts.BadgerOptions)
-	if err != nil {
-		logrus.WithError(err).WithField("path", opts.Path).Error("Failed to open badger store")
-		return nil, fmt.Errorf("failed to open badger store: %w", err)
+	backend := opts.Backend
+	if backend == nil {
+		badgerBackend, err := NewBadgerBackend(opts.Path, opts.BadgerOptions)
+		if err != nil {
+			logger.Error("Failed to open badger store", "path", opts.Path, "error", err)
+			return nil, fmt.Errorf("failed to open badger store: %w", err)
+		}
+		backend = badgerBackend
+		logger.Debug("Badger store opened successfully", "path", opts.Path)
 	}
-	logrus.WithField("path", opts.Path).Debug("Badger store opened successfully")
+	store := newKVStore(backend, opts.Path)
 
 	hashFn := opts.HashFunction
 	if hashFn == nil {
@@ -225,9 +264,22 @@ func CreateDatabase(ctx context.Context, opts DatabaseOptions) (Database, error)
 		collections:   make(map[string]*collection),
 		password:      opts.Password,
 		multiInst:     opts.MultiInstance,
+		maxDocSize:    opts.MaxDocumentSize,
+		ttlInterval:   opts.TTLPollInterval,
+		schemaHooks:   opts.SchemaHooks,
 		hashFn:        hashFn,
 		dbSubscribers: make(map[uint64]chan ChangeEvent),
 		closeChan:     make(chan struct{}),
+		logger:        logger,
+	}
+
+	if opts.WriteRateLimit > 0 {
+		// 桶容量为一秒的配额，允许短时突发
+		db.writeLimit = rate.NewLimiter(rate.Limit(opts.WriteRateLimit), int(math.Ceil(opts.WriteRateLimit)))
+	}
+
+	if opts.AutoCompact {
+		db.startAutoCompact(opts.AutoCompactInterval)
 	}
 
 	// 如果启用多实例，创建或获取事件广播器
@@ -244,7 +296,7 @@ func CreateDatabase(ctx context.Context, opts DatabaseOptions) (Database, error)
 	// 初始化图数据库（如果启用）
 	if opts.GraphOptions != nil && opts.GraphOptions.Enabled {
 		if err := db.initGraph(ctx, opts.GraphOptions); err != nil {
-			logrus.WithError(err).WithField("name", opts.Name).Error("Failed to initialize graph database")
+			logger.Error("Failed to initialize graph database", "name", opts.Name, "error", err)
 			// 图数据库初始化失败不影响主数据库，只记录错误
 		}
 	}
@@ -253,7 +305,7 @@ func CreateDatabase(ctx context.Context, opts DatabaseOptions) (Database, error)
 	dbRegistry[opts.Name] = db
 	dbRegistryMu.Unlock()
 
-	logrus.WithField("name", opts.Name).Info("Database created successfully")
+	logger.Info("Database created successfully", "name", opts.Name)
 	return db, nil
 }
 
@@ -262,7 +314,7 @@ func (d *database) Name() string {
 }
 
 func (d *database) Close(ctx context.Context) error {
-	logrus.WithField("name", d.name).Debug("Closing database")
+	d.logger.Debug("Closing database", "name", d.name)
 
 	d.mu.Lock()
 	if d.closed {
@@ -285,11 +337,20 @@ func (d *database) Close(ctx context.Context) error {
 	d.dbSubscribersMu.Unlock()
 
 	// 在同一个锁内关闭所有集合的变更通道，避免双重加锁
+	cols := make([]*collection, 0, len(d.collections))
 	for _, col := range d.collections {
 		col.close()
+		cols = append(cols, col)
 	}
 	d.mu.Unlock()
 
+	// 释放锁后等待 TTL 清理退出（清理中的写操作需要获取数据库读锁）
+	for _, col := range cols {
+		col.stopTTL()
+		col.closeDeclaredFulltexts()
+		col.closeDeclaredVectors()
+	}
+
 	// 如果这是最后一个实例，关闭广播器
 	dbRegistryMu.Lock()
 	instanceCount := 0
@@ -321,6 +382,9 @@ func (d *database) Close(ctx context.Context) error {
 		_ = d.graphClient.Close()
 	}
 
+	// 等待后台压缩退出后再关闭存储
+	d.stopAutoCompact()
+
 	return d.store.Close()
 }
 
@@ -331,15 +395,31 @@ func (d *database) Destroy(ctx context.Context) error {
 		return nil
 	}
 	d.closed = true
+	cols := make([]*collection, 0, len(d.collections))
+	for _, col := range d.collections {
+		cols = append(cols, col)
+	}
 	d.mu.Unlock()
 
+	for _, col := range cols {
+		col.stopTTL()
+		col.closeDeclaredFulltexts()
+		col.closeDeclaredVectors()
+	}
+
 	// 获取存储路径
 	path := d.store.Path()
 	if path == "" {
 		return errors.New("database path not available")
 	}
 
-	// 关闭数据库
+	// 自定义后端的数据不在 path 目录下，需要逐键删除
+	d.stopAutoCompact()
+	if d.store.badgerStore() == nil {
+		if err := d.store.dropPrefixes(ctx, nil); err != nil {
+			return fmt.Errorf("failed to clear storage backend: %w", err)
+		}
+	}
 	if err := d.store.Close(); err != nil {
 		return fmt.Errorf("failed to close database: %w", err)
 	}
@@ -361,6 +441,32 @@ func (d *database) Destroy(ctx context.Context) error {
 }
 
 func (d *database) Collection(ctx context.Context, name string, schema Schema) (Collection, error) {
+	if err := validateFulltextDeclarations(schema.FulltextIndexes); err != nil {
+		return nil, err
+	}
+	if err := validateVectorDeclarations(schema.VectorIndexes); err != nil {
+		return nil, err
+	}
+	col, err := d.openCollection(ctx, name, schema)
+	if err != nil {
+		return nil, err
+	}
+	// 构建全文与向量索引会查询集合（需要获取数据库读锁），因此在 openCollection 释放 d.mu 之后进行
+	col.mu.RLock()
+	decls := col.schema.FulltextIndexes
+	vectorDecls := col.schema.VectorIndexes
+	col.mu.RUnlock()
+	if err := col.attachFulltextIndexes(decls); err != nil {
+		return nil, err
+	}
+	if err := col.attachVectorIndexes(ctx, vectorDecls); err != nil {
+		return nil, err
+	}
+	return col, nil
+}
+
+// openCollection 创建或返回已打开的集合，必要时更新 schema 与索引。
+func (d *database) openCollection(ctx context.Context, name string, schema Schema) (*collection, error) {
 	if err := d.beginOp(ctx); err != nil {
 		return nil, err
 	}
@@ -384,6 +490,16 @@ func (d *database) Collection(ctx context.Context, name string, schema Schema) (
 		enabled := true
 		schema.KeyCompression = &enabled
 	}
+	switch schema.Options.Validation {
+	case "", SchemaValidationStrict, SchemaValidationWarn, SchemaValidationOff:
+	default:
+		return nil, NewError(ErrorTypeValidation, fmt.Sprintf("unsupported schema validation mode: %s", schema.Options.Validation), nil)
+	}
+	if schema.JSON != nil && schema.Options.Validation != SchemaValidationOff {
+		if err := compileDocumentSchema(schema); err != nil {
+			return nil, NewError(ErrorTypeValidation, "invalid JSON schema", err).WithContext("collection", name)
+		}
+	}
 
 	// 如果集合已存在，检查是否需要迁移或更新 schema
 	if col, ok := d.collections[name]; ok {
@@ -391,9 +507,15 @@ func (d *database) Collection(ctx context.Context, name string, schema Schema) (
 		oldVersion := getSchemaVersion(col.schema)
 		newVersion := getSchemaVersion(schema)
 
-		// 检测索引变化
+		// 检测索引变化（未声明的已有索引默认保留）
 		oldIndexes := col.schema.Indexes
+		schema.Indexes = mergeSchemaIndexes(oldIndexes, schema.Indexes, schema.DropMissingIndexes)
 		newIndexes := schema.Indexes
+		// 已创建的全文索引保持打开，新声明的全文索引在下面补建
+		schema.FulltextIndexes = mergeFulltextDeclarations(col.schema.FulltextIndexes, schema.FulltextIndexes)
+		schema.VectorIndexes = mergeVectorDeclarations(col.schema.VectorIndexes, schema.VectorIndexes)
+		// 变更日志在写路径中无锁读取，配置以集合首次打开时为准
+		schema.Changelog = col.schema.Changelog
 
 		// 需要更新schema的情况：
 		// 1. 版本号增加
@@ -436,26 +558,40 @@ func (d *database) Collection(ctx context.Context, name string, schema Schema) (
 				col.schema = oldSchema
 				return nil, fmt.Errorf("failed to update indexes: %w", err)
 			}
+			if err := col.saveIndexes(ctx); err != nil {
+				return nil, fmt.Errorf("failed to save indexes: %w", err)
+			}
 
 			// 更新压缩表（如果schema字段有变化）
 			col.generateCompressionTable()
 		}
 
+		col.mu.Lock()
+		col.schema.FulltextIndexes = schema.FulltextIndexes
+		col.schema.VectorIndexes = schema.VectorIndexes
+		col.mu.Unlock()
+
 		return col, nil
 	}
 
-	col, err := newCollection(ctx, d, d.store, name, schema, d.hashFn, d.broadcaster, d.password, d.emitDatabaseChange, d.beginOp, d.endOp)
+	col, err := newCollection(ctx, d, d.store, name, schema, d.hashFn, d.broadcaster, d.password, d.emitDatabaseChange, d.beginOp, d.endOp, d.logger)
 	if err != nil {
 		return nil, err
 	}
+	col.maxDocSize = d.maxDocSize
+	col.writeLimit = d.writeLimit
+	col.hooks = d.schemaHooks
+	if schema.TTL.enabled() {
+		col.startTTL(d.ttlInterval)
+	}
 
 	d.collections[name] = col
 	return col, nil
 }
 
-// GetStore 返回底层存储（供内部使用）。
+// GetStore 返回底层 Badger 存储（供内部使用），使用其他存储后端时返回 nil。
 func (d *database) GetStore() *badger.Store {
-	return d.store
+	return d.store.badgerStore()
 }
 
 // Changes 返回数据库级别的变更事件通道（所有集合的变更）。
@@ -605,6 +741,105 @@ func (d *database) RequestIdle(ctx context.Context) error {
 	}
 }
 
+// Ping 对底层存储执行一次最小读取（不存在键的查找），用于存活/就绪探针。
+// 数据库已关闭时返回 ErrorTypeClosed 错误，上下文已取消或超时时返回 ctx.Err()。
+func (d *database) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	d.mu.RLock()
+	closed := d.closed
+	d.mu.RUnlock()
+	if closed {
+		return NewError(ErrorTypeClosed, "database is closed", nil)
+	}
+
+	if _, err := d.store.Get(ctx, "_meta", "ping"); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return NewError(ErrorTypeIO, "ping failed", err)
+	}
+	return nil
+}
+
+// 数据库级迁移元数据（独立于集合的 _meta 键，避免与 "<集合名>_version" 冲突）。
+const (
+	dbMetaBucket         = "_db_meta"
+	dbVersionKey         = "db_version"
+	dbMigrationFailedKey = "db_migration_failed"
+)
+
+// Migrate 依次执行 migrations，将数据库版本从 from 升级到 to。
+// migrations[i] 负责从 from+i 迁移到 from+i+1，每步成功后写入 db_version；
+// 已应用的步骤（目标版本不高于已存储版本）会被跳过，因此重复执行是幂等的。
+// 某一步失败时停止后续步骤，db_version 保持在最后一次成功的版本，并记录失败的目标版本。
+func (d *database) Migrate(ctx context.Context, from, to int, migrations []MigrationFn) error {
+	if to < from {
+		return NewError(ErrorTypeValidation, fmt.Sprintf("invalid migration range: %d -> %d", from, to), nil)
+	}
+	if len(migrations) != to-from {
+		return NewError(ErrorTypeValidation, fmt.Sprintf("expected %d migrations for %d -> %d, got %d", to-from, from, to, len(migrations)), nil)
+	}
+
+	d.mu.RLock()
+	closed := d.closed
+	d.mu.RUnlock()
+	if closed {
+		return NewError(ErrorTypeClosed, "database is closed", nil)
+	}
+
+	current, err := d.dbVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i, migration := range migrations {
+		version := from + i + 1
+		if version <= current {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		d.logger.Info("Running database migration", "name", d.name, "from", version-1, "to", version)
+		if err := migration(ctx, d, version-1); err != nil {
+			failed, _ := json.Marshal(version)
+			_ = d.store.Set(ctx, dbMetaBucket, dbMigrationFailedKey, failed)
+			return NewError(ErrorTypeSchema, fmt.Sprintf("migration to version %d failed", version), err).
+				WithContext("version", version)
+		}
+
+		data, _ := json.Marshal(version)
+		if err := d.store.Set(ctx, dbMetaBucket, dbVersionKey, data); err != nil {
+			return NewError(ErrorTypeIO, "failed to store database version", err)
+		}
+		current = version
+	}
+
+	if err := d.store.Delete(ctx, dbMetaBucket, dbMigrationFailedKey); err != nil {
+		return NewError(ErrorTypeIO, "failed to clear migration failure", err)
+	}
+	return nil
+}
+
+// dbVersion 返回已存储的数据库版本，未迁移过时返回 0。
+func (d *database) dbVersion(ctx context.Context) (int, error) {
+	data, err := d.store.Get(ctx, dbMetaBucket, dbVersionKey)
+	if err != nil {
+		return 0, NewError(ErrorTypeIO, "failed to read database version", err)
+	}
+	version := 0
+	if data != nil {
+		if err := json.Unmarshal(data, &version); err != nil {
+			return 0, NewError(ErrorTypeIO, "failed to decode database version", err)
+		}
+	}
+	return version, nil
+}
+
 // Password 返回数据库级密码（当前仅存储，不用于加密）。
 func (d *database) Password() string {
 	return d.password
@@ -741,6 +976,114 @@ func (d *database) ImportJSON(ctx context.Context, data map[string]any) error {
 	return nil
 }
 
+// Collections 返回当前已打开集合的名称（按名称排序）。
+func (d *database) Collections() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	names := make([]string, 0, len(d.collections))
+	for name := range d.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExportSchema 导出所有已打开集合的 schema 与索引定义（按集合名排序）。
+func (d *database) ExportSchema(ctx context.Context) ([]SchemaExport, error) {
+	if err := d.beginOp(ctx); err != nil {
+		return nil, err
+	}
+	defer d.endOp()
+
+	d.mu.RLock()
+	names := make([]string, 0, len(d.collections))
+	cols := make(map[string]*collection, len(d.collections))
+	for name, col := range d.collections {
+		names = append(names, name)
+		cols[name] = col
+	}
+	d.mu.RUnlock()
+	sort.Strings(names)
+
+	exports := make([]SchemaExport, 0, len(names))
+	for _, name := range names {
+		col := cols[name]
+		col.mu.RLock()
+		schema := col.schema
+		col.mu.RUnlock()
+
+		// 函数字段无法迁移到其他进程
+		schema.Virtual = nil
+		schema.MigrationStrategies = nil
+		schema.DefaultGenerators = nil
+		schema.FulltextIndexes = exportFulltextDeclarations(schema.FulltextIndexes)
+		schema.VectorIndexes = exportVectorDeclarations(schema.VectorIndexes)
+		if schema.JSON != nil {
+			schema.JSON = DeepCloneMap(schema.JSON)
+		}
+		schema.Indexes = append([]Index(nil), schema.Indexes...)
+		schema.EncryptedFields = append([]string(nil), schema.EncryptedFields...)
+
+		exports = append(exports, SchemaExport{
+			CollectionName:   name,
+			Schema:           schema,
+			IndexDefinitions: col.ListIndexes(),
+		})
+	}
+	return exports, nil
+}
+
+// ImportSchema 按导出结果创建集合与索引。
+// force 为 false 时已存在的集合保持原有 schema，只补建缺失的索引；
+// force 为 true 时以导出的 schema 重新打开已存在的集合，索引与 IndexDefinitions 保持一致（多余的索引被删除，变化的索引被重建）。
+func (d *database) ImportSchema(ctx context.Context, schemas []SchemaExport, force bool) error {
+	for _, export := range schemas {
+		if export.CollectionName == "" {
+			return NewError(ErrorTypeValidation, "collection name is required", nil)
+		}
+
+		d.mu.RLock()
+		existing, exists := d.collections[export.CollectionName]
+		d.mu.RUnlock()
+
+		schema := export.Schema
+		schema.Indexes = append([]Index(nil), export.IndexDefinitions...)
+
+		if !exists || force {
+			schema.DropMissingIndexes = force
+			if _, err := d.Collection(ctx, export.CollectionName, schema); err != nil {
+				return fmt.Errorf("failed to import collection %s: %w", export.CollectionName, err)
+			}
+			continue
+		}
+
+		current := existing.ListIndexes()
+		for _, idx := range export.IndexDefinitions {
+			if containsIndex(current, idx) {
+				continue
+			}
+			if err := existing.CreateIndex(ctx, idx); err != nil {
+				return fmt.Errorf("failed to import index for collection %s: %w", export.CollectionName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// containsIndex 判断 indexes 中是否已有同名索引（未命名时比较字段列表）。
+func containsIndex(indexes []Index, idx Index) bool {
+	for _, existing := range indexes {
+		if idx.Name != "" && existing.Name == idx.Name {
+			return true
+		}
+		if idx.Name == "" && reflect.DeepEqual(existing.Fields, idx.Fields) {
+			return true
+		}
+	}
+	return false
+}
+
 // Backup 备份数据库到指定文件路径。
 func (d *database) Backup(ctx context.Context, backupPath string) error {
 	if err := d.beginOp(ctx); err != nil {
@@ -762,7 +1105,11 @@ func (d *database) Backup(ctx context.Context, backupPath string) error {
 	}
 
 	// 使用 Badger 的内置备份功能
-	return d.store.Backup(ctx, backupPath)
+	store := d.store.badgerStore()
+	if store == nil {
+		return NewError(ErrorTypeValidation, "backup is only supported by the badger storage backend", nil)
+	}
+	return store.Backup(ctx, backupPath)
 }
 
 // IsRxDatabase 检查对象是否为 RxDatabase 实例。
@@ -840,8 +1187,8 @@ func indexesEqual(oldIndexes, newIndexes []Index) bool {
 		if !exists {
 			return false
 		}
-		// 比较字段列表
-		if !indexFieldsEqual(oldIdx.Fields, newIdx.Fields) {
+		// 比较字段列表与索引类型
+		if !indexFieldsEqual(oldIdx.Fields, newIdx.Fields) || oldIdx.Type != newIdx.Type {
 			return false
 		}
 	}
@@ -1,10 +1,20 @@
 package rxdb
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 func TestDatabase_CreateDatabase(t *testing.T) {
@@ -134,6 +144,179 @@ func TestDatabase_Close(t *testing.T) {
 	}
 }
 
+func TestDatabase_AutoCompact(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_auto_compact.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name:                "testdb",
+		Path:                dbPath,
+		AutoCompact:         true,
+		AutoCompactInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	d := db.(*database)
+
+	// 1ms 间隔下约 10ms 内即应完成至少两次压缩；负载较高时放宽等待时间
+	deadline := time.Now().Add(2 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	for atomic.LoadInt64(&d.compactRuns) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if runs := atomic.LoadInt64(&d.compactRuns); runs < 2 {
+		t.Errorf("Expected at least 2 compactions, got %d", runs)
+	}
+
+	// 手动压缩与自动压缩互斥，不应报错
+	if err := db.Compact(ctx); err != nil {
+		t.Errorf("Compact failed: %v", err)
+	}
+
+	if err := db.Close(ctx); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+
+	// Close 返回后后台压缩 goroutine 必须已退出
+	select {
+	case <-d.compactDone:
+	default:
+		t.Error("Auto compaction goroutine still running after Close")
+	}
+	runs := atomic.LoadInt64(&d.compactRuns)
+	time.Sleep(5 * time.Millisecond)
+	if after := atomic.LoadInt64(&d.compactRuns); after != runs {
+		t.Errorf("Compaction ran after Close: %d -> %d", runs, after)
+	}
+
+	if err := db.Compact(ctx); err == nil {
+		t.Error("Compact should fail after database is closed")
+	}
+}
+
+func TestDatabase_TTL(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_ttl.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name:            "testdb",
+		Path:            dbPath,
+		TTLPollInterval: 5 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	coll, err := db.Collection(ctx, "sessions", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		TTL:        &TTLOptions{TTLField: "createdAt", TTLSeconds: 3600},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	changes := coll.Changes()
+
+	now := time.Now()
+	docs := []map[string]any{
+		{"id": "expired", "createdAt": now.Add(-2 * time.Hour).Format(time.RFC3339)},
+		{"id": "fresh", "createdAt": now.Format(time.RFC3339)},
+		{"id": "no-ttl"},
+		{"id": "invalid", "createdAt": "yesterday"},
+	}
+	for _, doc := range docs {
+		if _, err := coll.Insert(ctx, doc); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+
+	// 等待过期文档的删除事件
+	deadline := time.After(2 * time.Second)
+	for removed := false; !removed; {
+		select {
+		case event := <-changes:
+			if event.Op != OperationDelete {
+				continue
+			}
+			if event.ID != "expired" {
+				t.Fatalf("Unexpected TTL removal of %s", event.ID)
+			}
+			if event.Source != ChangeSourceTTL {
+				t.Errorf("Expected source %q, got %q", ChangeSourceTTL, event.Source)
+			}
+			removed = true
+		case <-deadline:
+			t.Fatal("Timed out waiting for expired document to be removed")
+		}
+	}
+
+	doc, err := coll.FindByID(ctx, "expired")
+	if err == nil && doc != nil {
+		t.Error("Expired document should be removed")
+	}
+	for _, id := range []string{"fresh", "no-ttl", "invalid"} {
+		if _, err := coll.FindByID(ctx, id); err != nil {
+			t.Errorf("Document %s should not expire: %v", id, err)
+		}
+	}
+
+	if err := db.Close(ctx); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+
+	// Close 返回后 TTL goroutine 必须已退出
+	c := coll.(*collection)
+	select {
+	case <-c.ttlDone:
+	default:
+		t.Error("TTL goroutine still running after Close")
+	}
+}
+
+func TestCollection_RemoveIfExpired(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	coll, err := db.Collection(ctx, "sessions", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		TTL:        &TTLOptions{TTLField: "createdAt", TTLSeconds: 3600},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	c := coll.(*collection)
+
+	now := time.Now()
+	stale := now.Add(-2 * time.Hour).Format(time.RFC3339)
+	for _, id := range []string{"refreshed", "expired"} {
+		if _, err := coll.Insert(ctx, map[string]any{"id": id, "createdAt": stale}); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+
+	// 查询到过期文档之后、删除之前，文档被并发刷新
+	if _, err := coll.Upsert(ctx, map[string]any{"id": "refreshed", "createdAt": now.Format(time.RFC3339)}); err != nil {
+		t.Fatalf("Failed to refresh document: %v", err)
+	}
+	if ok, err := c.removeIfExpired(ctx, "refreshed", now); err != nil || ok {
+		t.Errorf("Refreshed document should not be removed: ok=%v err=%v", ok, err)
+	}
+	if _, err := coll.FindByID(ctx, "refreshed"); err != nil {
+		t.Errorf("Refreshed document should still exist: %v", err)
+	}
+
+	if ok, err := c.removeIfExpired(ctx, "expired", now); err != nil || !ok {
+		t.Errorf("Expired document should be removed: ok=%v err=%v", ok, err)
+	}
+	if ok, err := c.removeIfExpired(ctx, "expired", now); err != nil || ok {
+		t.Errorf("Removed document should be skipped: ok=%v err=%v", ok, err)
+	}
+}
+
 func TestDatabase_Destroy(t *testing.T) {
 	ctx := context.Background()
 	dbPath := "../../data/test_destroy.db"
@@ -349,6 +532,120 @@ func TestDatabase_MultipleCollections(t *testing.T) {
 	}
 }
 
+func TestDatabase_ExportImportSchema(t *testing.T) {
+	ctx := context.Background()
+	srcPath := "../../data/test_export_schema_src.db"
+	dstPath := "../../data/test_export_schema_dst.db"
+	defer os.RemoveAll(srcPath)
+	defer os.RemoveAll(dstPath)
+
+	src, err := CreateDatabase(ctx, DatabaseOptions{Name: "schema-src", Path: srcPath})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer src.Close(ctx)
+
+	schemas := map[string]Schema{
+		"users": {
+			PrimaryKey: "id",
+			RevField:   "_rev",
+			Indexes:    []Index{{Name: "idx_email", Fields: []string{"email"}}},
+		},
+		"orders": {
+			PrimaryKey: "orderId",
+			RevField:   "_rev",
+			JSON: map[string]any{
+				"version": 2,
+				"properties": map[string]any{
+					"orderId": map[string]any{"type": "string"},
+					"total":   map[string]any{"type": "number"},
+				},
+			},
+		},
+		"logs": {PrimaryKey: "id", RevField: "_rev"},
+	}
+	for name, schema := range schemas {
+		if _, err := src.Collection(ctx, name, schema); err != nil {
+			t.Fatalf("Failed to create collection %s: %v", name, err)
+		}
+	}
+	orders, _ := src.Collection(ctx, "orders", schemas["orders"])
+	if err := orders.CreateIndex(ctx, Index{Name: "idx_total", Fields: []string{"total"}}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	exports, err := src.ExportSchema(ctx)
+	if err != nil {
+		t.Fatalf("Failed to export schema: %v", err)
+	}
+	if len(exports) != 3 {
+		t.Fatalf("Expected 3 schema exports, got %d", len(exports))
+	}
+
+	// 导出结果可以序列化后在其他进程中导入
+	data, err := json.Marshal(exports)
+	if err != nil {
+		t.Fatalf("Failed to marshal schema export: %v", err)
+	}
+	var decoded []SchemaExport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal schema export: %v", err)
+	}
+
+	dst, err := CreateDatabase(ctx, DatabaseOptions{Name: "schema-dst", Path: dstPath})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer dst.Close(ctx)
+
+	if err := dst.ImportSchema(ctx, decoded, false); err != nil {
+		t.Fatalf("Failed to import schema: %v", err)
+	}
+	if got, want := dst.Collections(), []string{"logs", "orders", "users"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected collections %v, got %v", want, got)
+	}
+
+	// 再次导入是幂等的
+	if err := dst.ImportSchema(ctx, decoded, false); err != nil {
+		t.Fatalf("Failed to re-import schema: %v", err)
+	}
+
+	exported := make(map[string]SchemaExport)
+	for _, e := range decoded {
+		exported[e.CollectionName] = e
+	}
+	dstOrders, err := dst.Collection(ctx, "orders", exported["orders"].Schema)
+	if err != nil {
+		t.Fatalf("Failed to get collection: %v", err)
+	}
+	if dstOrders.Schema().PrimaryKey != "orderId" {
+		t.Errorf("Expected primary key orderId, got %v", dstOrders.Schema().PrimaryKey)
+	}
+	if !containsIndex(dstOrders.ListIndexes(), Index{Name: "idx_total"}) {
+		t.Errorf("Expected idx_total to be imported, got %v", dstOrders.ListIndexes())
+	}
+	if _, err := dstOrders.Insert(ctx, map[string]any{"orderId": "o1", "total": 10.0}); err != nil {
+		t.Fatalf("Failed to insert into imported collection: %v", err)
+	}
+
+	// 不带 force 时保留已有索引，带 force 时与导出定义保持一致
+	usersExport := exported["users"]
+	usersExport.IndexDefinitions = nil
+	if err := dst.ImportSchema(ctx, []SchemaExport{usersExport}, false); err != nil {
+		t.Fatalf("Failed to import schema: %v", err)
+	}
+	dstUsers, _ := dst.Collection(ctx, "users", exported["users"].Schema)
+	if len(dstUsers.ListIndexes()) != 1 {
+		t.Errorf("Expected existing index to be kept without force, got %v", dstUsers.ListIndexes())
+	}
+	if err := dst.ImportSchema(ctx, []SchemaExport{usersExport}, true); err != nil {
+		t.Fatalf("Failed to force import schema: %v", err)
+	}
+	if len(dstUsers.ListIndexes()) != 0 {
+		t.Errorf("Expected index to be dropped with force, got %v", dstUsers.ListIndexes())
+	}
+}
+
 func TestDatabase_ExportJSON(t *testing.T) {
 	ctx := context.Background()
 	dbPath := "../../data/test_export.db"
@@ -691,8 +988,7 @@ func TestDatabase_Changes(t *testing.T) {
 
 func TestDatabase_WaitForLeadership(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_leadership.db"
-	defer os.RemoveAll(dbPath)
+	dbPath := filepath.Join(t.TempDir(), "test_leadership.db")
 
 	db, err := CreateDatabase(ctx, DatabaseOptions{
 		Name: "testdb",
@@ -713,10 +1009,10 @@ func TestDatabase_WaitForLeadership(t *testing.T) {
 // TestDatabase_WaitForLeadership_MultiInstance 测试多实例选举
 func TestDatabase_WaitForLeadership_MultiInstance(t *testing.T) {
 	ctx := context.Background()
-	dbPath1 := "../../data/test_leadership1.db"
-	dbPath2 := "../../data/test_leadership2.db"
-	defer os.RemoveAll(dbPath1)
-	defer os.RemoveAll(dbPath2)
+	// 多实例模式会在数据库目录旁创建 .lock 文件，使用临时目录避免遗留
+	dir := t.TempDir()
+	dbPath1 := filepath.Join(dir, "test_leadership1.db")
+	dbPath2 := filepath.Join(dir, "test_leadership2.db")
 
 	// 创建第一个多实例数据库
 	db1, err := CreateDatabase(ctx, DatabaseOptions{
@@ -792,6 +1088,94 @@ func TestDatabase_RequestIdle(t *testing.T) {
 	}
 }
 
+func TestDatabase_Ping(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_ping.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "testdb",
+		Path: dbPath,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	// 健康的数据库
+	if err := db.Ping(ctx); err != nil {
+		t.Errorf("Ping should succeed: %v", err)
+	}
+
+	// 已过期的上下文
+	expiredCtx, cancel := context.WithTimeout(ctx, -time.Second)
+	defer cancel()
+	if err := db.Ping(expiredCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// 关闭后
+	if err := db.Close(ctx); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+	if err := db.Ping(ctx); !IsClosedError(err) {
+		t.Errorf("Expected closed error after Close, got %v", err)
+	}
+}
+
+func TestDatabase_WriteRateLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping rate limit test in short mode")
+	}
+
+	ctx := context.Background()
+	dbPath := "../../data/test_write_rate_limit.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name:           "testdb",
+		Path:           dbPath,
+		WriteRateLimit: 20,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	collection, err := db.Collection(ctx, "test", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	// 20 docs/sec 写入 30 个文档：突发 20 个，其余 10 个至少需要 0.5 秒
+	start := time.Now()
+	for i := 0; i < 30; i++ {
+		if _, err := collection.Insert(ctx, map[string]any{"id": fmt.Sprintf("doc%d", i)}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 450*time.Millisecond {
+		t.Errorf("Expected rate-limited inserts to take at least 500ms, took %v", elapsed)
+	}
+
+	// 配额耗尽时，等待中的写入在上下文取消后立即返回（下一个配额在 50ms 后才产生）
+	if _, err := collection.BulkInsert(ctx, []map[string]any{{"id": "bulk1"}, {"id": "bulk2"}}); err != nil {
+		t.Fatalf("Failed to bulk insert: %v", err)
+	}
+	cancelCtx, cancel := context.WithCancel(ctx)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start = time.Now()
+	_, err = collection.Upsert(cancelCtx, map[string]any{"id": "late"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("Expected cancellation to return promptly, took %v", elapsed)
+	}
+	if collection.Exists("late") {
+		t.Error("Cancelled write should not be stored")
+	}
+}
+
 func TestIsRxDatabase(t *testing.T) {
 	ctx := context.Background()
 	dbPath := "../../data/test_isrxdb.db"
@@ -1057,10 +1441,9 @@ func TestDatabase_RestoreFromBackup(t *testing.T) {
 
 func TestDatabase_MultiInstance(t *testing.T) {
 	ctx := context.Background()
-	dbPath1 := "../../data/test_multi1.db"
-	dbPath2 := "../../data/test_multi2.db"
-	defer os.RemoveAll(dbPath1)
-	defer os.RemoveAll(dbPath2)
+	dir := t.TempDir()
+	dbPath1 := filepath.Join(dir, "test_multi1.db")
+	dbPath2 := filepath.Join(dir, "test_multi2.db")
 
 	// 创建第一个实例
 	db1, err := CreateDatabase(ctx, DatabaseOptions{
@@ -1130,3 +1513,338 @@ func TestDatabase_MultiInstance(t *testing.T) {
 		// 如果实现了同步，文档应该存在
 	}
 }
+
+// recordingLogger 记录所有日志消息，用于验证日志路由。
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) record(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, msg)
+}
+
+func (l *recordingLogger) Debug(msg string, _ ...any) { l.record(msg) }
+func (l *recordingLogger) Info(msg string, _ ...any)  { l.record(msg) }
+func (l *recordingLogger) Warn(msg string, _ ...any)  { l.record(msg) }
+func (l *recordingLogger) Error(msg string, _ ...any) { l.record(msg) }
+
+func TestDatabase_Logger(t *testing.T) {
+	ctx := context.Background()
+
+	// 捕获 logrus 的全部输出，确保内部日志不会绕过配置的 Logger
+	var buf bytes.Buffer
+	std := logrus.StandardLogger()
+	oldOut, oldLevel := std.Out, std.GetLevel()
+	std.SetOutput(&buf)
+	std.SetLevel(logrus.DebugLevel)
+	defer func() {
+		std.SetOutput(oldOut)
+		std.SetLevel(oldLevel)
+	}()
+
+	run := func(dbPath string, logger Logger) {
+		defer os.RemoveAll(dbPath)
+		db, err := CreateDatabase(ctx, DatabaseOptions{
+			Name:   "testdb",
+			Path:   dbPath,
+			Logger: logger,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create database: %v", err)
+		}
+		defer db.Close(ctx)
+
+		collection, err := db.Collection(ctx, "test", Schema{PrimaryKey: "id", RevField: "_rev"})
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		if _, err := collection.BulkInsert(ctx, []map[string]any{
+			{"id": "1", "text": "hello world"},
+			{"id": "2", "text": "hello rxdb"},
+		}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+
+		fts, err := AddFulltextSearch(collection, FulltextSearchConfig{
+			Identifier: "logger-test",
+			DocToString: func(doc map[string]any) string {
+				text, _ := doc["text"].(string)
+				return text
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create fulltext search: %v", err)
+		}
+		defer fts.Close()
+		if err := fts.Reindex(ctx); err != nil {
+			t.Fatalf("Failed to reindex: %v", err)
+		}
+		if _, err := collection.Find(map[string]any{"id": "1"}).Exec(ctx); err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+	}
+
+	run("../../data/test_logger_noop.db", NoopLogger())
+	if buf.Len() != 0 {
+		t.Errorf("Expected no log output with NoopLogger, got:\n%s", buf.String())
+	}
+
+	recorder := &recordingLogger{}
+	run("../../data/test_logger_recording.db", recorder)
+	if buf.Len() != 0 {
+		t.Errorf("Expected no logrus output with custom Logger, got:\n%s", buf.String())
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.messages) == 0 {
+		t.Error("Expected custom Logger to receive internal log messages")
+	}
+}
+
+func TestDatabase_SnapshotRestore(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_snapshot.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "testdb",
+		Path: dbPath,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	schema := Schema{PrimaryKey: "id", RevField: "_rev"}
+	users, err := db.Collection(ctx, "users", schema)
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	if _, err := users.BulkInsert(ctx, []map[string]any{
+		{"id": "u1", "name": "Alice"},
+		{"id": "u2", "name": "Bob"},
+	}); err != nil {
+		t.Fatalf("Failed to insert documents: %v", err)
+	}
+
+	snap, err := Snapshot(ctx, db)
+	if err != nil {
+		t.Fatalf("Failed to create snapshot: %v", err)
+	}
+	if len(snap.Collections["users"]) != 2 {
+		t.Fatalf("Expected 2 documents in snapshot, got %d", len(snap.Collections["users"]))
+	}
+
+	// 快照可以经过 JSON 序列化往返
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatalf("Failed to marshal snapshot: %v", err)
+	}
+	var restored DatabaseSnapshot
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("Failed to unmarshal snapshot: %v", err)
+	}
+
+	// 修改数据库：更新、删除、新增文档以及新增集合
+	if _, err := users.Upsert(ctx, map[string]any{"id": "u1", "name": "Alice Changed"}); err != nil {
+		t.Fatalf("Failed to upsert: %v", err)
+	}
+	if err := users.Remove(ctx, "u2"); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	if _, err := users.Insert(ctx, map[string]any{"id": "u3", "name": "Carol"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	posts, err := db.Collection(ctx, "posts", schema)
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	if _, err := posts.Insert(ctx, map[string]any{"id": "p1", "title": "Hello"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	if err := RestoreSnapshot(ctx, db, &restored); err != nil {
+		t.Fatalf("Failed to restore snapshot: %v", err)
+	}
+
+	docs, err := users.All(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get all: %v", err)
+	}
+	names := make(map[string]any)
+	for _, doc := range docs {
+		names[doc.ID()] = doc.Get("name")
+	}
+	expected := map[string]any{"u1": "Alice", "u2": "Bob"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected %d users after restore, got %v", len(expected), names)
+	}
+	for id, name := range expected {
+		if names[id] != name {
+			t.Errorf("Expected user %s name %v, got %v", id, name, names[id])
+		}
+	}
+
+	count, err := posts.Count(ctx)
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected posts collection to be empty after restore, got %d", count)
+	}
+
+	if err := RestoreSnapshot(ctx, db, nil); !IsValidationError(err) {
+		t.Errorf("Expected validation error for nil snapshot, got %v", err)
+	}
+}
+
+func TestDatabase_Transaction(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	accounts := newTestCollection(t, db, "accounts", Schema{PrimaryKey: "id", RevField: "_rev"})
+	ledger := newTestCollection(t, db, "ledger", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	for _, id := range []string{"alice", "bob"} {
+		if _, err := accounts.Insert(ctx, map[string]any{"id": id, "balance": 100}); err != nil {
+			t.Fatalf("Failed to insert account: %v", err)
+		}
+	}
+	if _, err := ledger.Insert(ctx, map[string]any{"id": "stale"}); err != nil {
+		t.Fatalf("Failed to insert ledger entry: %v", err)
+	}
+	changes := accounts.Changes()
+
+	transfer := func(tx Tx, n int) {
+		t.Helper()
+		if err := tx.Collection("accounts").Update(ctx, "alice", map[string]any{"$inc": map[string]any{"balance": -10}}); err != nil {
+			t.Fatalf("Failed to buffer update: %v", err)
+		}
+		if err := tx.Collection("accounts").Update(ctx, "bob", map[string]any{"$inc": map[string]any{"balance": 10}}); err != nil {
+			t.Fatalf("Failed to buffer update: %v", err)
+		}
+		if err := tx.Collection("ledger").Insert(ctx, map[string]any{"id": fmt.Sprintf("t%d", n), "amount": 10}); err != nil {
+			t.Fatalf("Failed to buffer insert: %v", err)
+		}
+	}
+
+	// 回滚后数据库保持不变，也不发送变更事件
+	tx, err := db.BeginTx(ctx)
+	if err != nil {
+		t.Fatalf("BeginTx failed: %v", err)
+	}
+	transfer(tx, 0)
+	if err := tx.Collection("ledger").Remove(ctx, "stale"); err != nil {
+		t.Fatalf("Failed to buffer remove: %v", err)
+	}
+	select {
+	case event := <-changes:
+		t.Fatalf("Unexpected change event before commit: %+v", event)
+	default:
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if err := tx.Commit(ctx); !errors.Is(err, ErrTxDone) {
+		t.Errorf("Expected ErrTxDone after rollback, got %v", err)
+	}
+	for _, id := range []string{"alice", "bob"} {
+		doc, _ := accounts.FindByID(ctx, id)
+		if doc.Get("balance") != 100.0 {
+			t.Errorf("Rollback changed %s balance to %v", id, doc.Get("balance"))
+		}
+	}
+	if n, _ := ledger.Count(ctx); n != 1 {
+		t.Errorf("Rollback changed ledger: %d entries", n)
+	}
+
+	// 提交后所有写入生效并发送事件
+	tx, _ = db.BeginTx(ctx)
+	transfer(tx, 1)
+	if err := tx.Collection("ledger").Remove(ctx, "stale"); err != nil {
+		t.Fatalf("Failed to buffer remove: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	alice, _ := accounts.FindByID(ctx, "alice")
+	bob, _ := accounts.FindByID(ctx, "bob")
+	if alice.Get("balance") != 90.0 || bob.Get("balance") != 110.0 {
+		t.Errorf("Unexpected balances after commit: %v, %v", alice.Get("balance"), bob.Get("balance"))
+	}
+	if _, err := ledger.FindByID(ctx, "t1"); err != nil {
+		t.Errorf("Ledger entry not committed: %v", err)
+	}
+	if _, err := ledger.FindByID(ctx, "stale"); err == nil {
+		t.Error("Stale ledger entry should be removed")
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-changes:
+			if event.Op != OperationUpdate {
+				t.Errorf("Expected update event, got %s", event.Op)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for change events after commit")
+		}
+	}
+
+	// 任一操作失败时整个事务不生效
+	tx, _ = db.BeginTx(ctx)
+	transfer(tx, 2)
+	if err := tx.Collection("ledger").Insert(ctx, map[string]any{"id": "t1"}); err != nil {
+		t.Fatalf("Failed to buffer insert: %v", err)
+	}
+	if err := tx.Commit(ctx); !IsAlreadyExistsError(err) {
+		t.Errorf("Expected already exists error, got %v", err)
+	}
+	alice, _ = accounts.FindByID(ctx, "alice")
+	if alice.Get("balance") != 90.0 {
+		t.Errorf("Failed commit changed balance to %v", alice.Get("balance"))
+	}
+	if _, err := ledger.FindByID(ctx, "t2"); err == nil {
+		t.Error("Failed commit should not insert ledger entry")
+	}
+	if err := tx.Collection("missing").Insert(ctx, map[string]any{"id": "x"}); err == nil {
+		t.Error("Expected error for unopened collection")
+	}
+
+	// 并发读取只能看到提交前或提交后的完整状态
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			docs, err := accounts.Find(map[string]any{}).Exec(ctx)
+			if err != nil {
+				t.Errorf("Find failed: %v", err)
+				return
+			}
+			total := 0.0
+			for _, doc := range docs {
+				total += doc.GetFloat("balance")
+			}
+			if total != 200 {
+				t.Errorf("Reader observed partial transaction: total balance %v", total)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		tx, _ := db.BeginTx(ctx)
+		transfer(tx, 10+i)
+		if err := tx.Commit(ctx); err != nil {
+			t.Fatalf("Commit failed: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,138 @@
+package rxdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	bstore "github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
+)
+
+// Distinct 返回匹配 filter 的文档中 field 字段的去重值，按类型（数值、字符串、布尔、其他）再按值升序排列。
+// 支持点号路径；数组字段的每个元素作为单独的值；字段缺失或为 null 的文档不贡献值。
+// filter 为空且 field 是某个索引的首字段时直接扫描索引键。
+func (c *collection) Distinct(ctx context.Context, field string, filter map[string]any) ([]any, error) {
+	if len(filter) == 0 {
+		if bucketName, ok := c.indexBucketForField(field); ok {
+			set := newDistinctSet()
+			rawPrefix := bstore.BucketPrefix(bucketName)
+			err := c.store.IterateRawPrefix(ctx, rawPrefix, func(key, value []byte) error {
+				sep := bytes.LastIndexByte(key, 0x00)
+				if sep < 0 {
+					return nil
+				}
+				var values []any
+				if err := json.Unmarshal(key[:sep], &values); err != nil {
+					return err
+				}
+				if len(values) > 0 {
+					set.add(values[0])
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			return set.sorted(), nil
+		}
+	}
+	return c.Find(filter).Distinct(ctx, field)
+}
+
+// Distinct 返回查询结果中 field 字段的去重值，规则同 Collection.Distinct；Skip/Limit 作用于文档而非值。
+func (q *Query) Distinct(ctx context.Context, field string) ([]any, error) {
+	docs, err := q.exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parts := splitQueryPath(field)
+	set := newDistinctSet()
+	for _, doc := range docs {
+		data := doc.Data()
+		value := getNestedValueByParts(data, parts)
+		if value == nil && len(parts) > 1 {
+			// 路径经过数组时展开每个元素
+			values, _ := getNestedValuesByParts(data, parts)
+			for _, v := range values {
+				set.add(v)
+			}
+			continue
+		}
+		set.add(value)
+	}
+	return set.sorted(), nil
+}
+
+// distinctSet 按 JSON 编码去重的值集合。
+type distinctSet struct {
+	seen   map[string]struct{}
+	values []any
+}
+
+func newDistinctSet() *distinctSet {
+	return &distinctSet{seen: make(map[string]struct{})}
+}
+
+// add 加入一个值：nil 被忽略，数组展开为各个元素。
+func (s *distinctSet) add(v any) {
+	switch val := v.(type) {
+	case nil:
+		return
+	case []any:
+		for _, item := range val {
+			s.add(item)
+		}
+		return
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	if _, ok := s.seen[string(encoded)]; ok {
+		return
+	}
+	s.seen[string(encoded)] = struct{}{}
+	s.values = append(s.values, v)
+}
+
+// sorted 返回排序后的值：数值 < 字符串 < 布尔 < 其他（按 JSON 编码比较）。
+func (s *distinctSet) sorted() []any {
+	out := s.values
+	if out == nil {
+		out = []any{}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		ri, rj := distinctRank(out[i]), distinctRank(out[j])
+		if ri != rj {
+			return ri < rj
+		}
+		switch ri {
+		case 0:
+			return numberToFloat64(out[i]) < numberToFloat64(out[j])
+		case 1:
+			return out[i].(string) < out[j].(string)
+		case 2:
+			return !out[i].(bool) && out[j].(bool)
+		}
+		a, _ := json.Marshal(out[i])
+		b, _ := json.Marshal(out[j])
+		return strings.Compare(string(a), string(b)) < 0
+	})
+	return out
+}
+
+// distinctRank 返回值类型的排序优先级。
+func distinctRank(v any) int {
+	switch v.(type) {
+	case string:
+		return 1
+	case bool:
+		return 2
+	}
+	if isNumeric(v) {
+		return 0
+	}
+	return 3
+}
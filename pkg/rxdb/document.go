@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 
-	"github.com/dgraph-io/badger/v4"
 	bstore "github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
 )
 
@@ -16,6 +18,8 @@ type document struct {
 	collection *collection
 	revField   string
 	changes    chan ChangeEvent
+	detached   bool           // 由 Clone 创建的副本，Save 时通过集合的 Upsert 写入
+	pending    map[string]any // 通过 Set 记录、尚未保存的字段修改
 }
 
 func (d *document) ID() string {
@@ -23,6 +27,9 @@ func (d *document) ID() string {
 }
 
 func (d *document) Data() map[string]any {
+	if d.collection != nil {
+		return d.collection.withVirtualFields(d.data)
+	}
 	return d.data
 }
 
@@ -89,21 +96,203 @@ func (d *document) GetObject(field string) map[string]any {
 	return nil
 }
 
-// Set 设置字段值（不保存到数据库）。
-func (d *document) Set(ctx context.Context, field string, value any) error {
+// lookupPath 按点号路径查找字段值，数字路径段可用于访问数组元素（如 "items.0.name"）。
+// 路径不存在或中间节点为 nil 时返回 false。
+func (d *document) lookupPath(path string) (any, bool) {
+	var current any = d.data
+	for _, part := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]any:
+			v, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			current = v
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			current = node[i]
+		default:
+			return nil, false
+		}
+	}
+	if current == nil {
+		return nil, false
+	}
+	return current, true
+}
+
+// GetNested 按点号路径获取字段值，数字路径段用于访问数组元素（如 "items.0.price"）。
+// 路径不存在或值为 nil 时返回 false。
+func (d *document) GetNested(path string) (any, bool) {
+	return d.lookupPath(path)
+}
+
+// SetNested 按点号路径设置字段值并保存文档，缺失的中间对象会自动创建。
+// 路径经过非对象值（包括数组）、或指向主键字段时返回错误，保存失败时文档数据保持不变。
+func (d *document) SetNested(ctx context.Context, path string, value any) error {
 	if d.collection == nil {
 		return fmt.Errorf("document is not associated with a collection")
 	}
+	parts := strings.Split(path, ".")
+	for _, part := range parts {
+		if part == "" {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("invalid field path %q", path), nil)
+		}
+	}
+	if d.collection.isPrimaryKeyField(parts[0]) {
+		return NewError(ErrorTypeValidation, fmt.Sprintf("cannot modify primary key field %s", parts[0]), nil)
+	}
+
+	newData := DeepCloneMap(d.data)
+	if newData == nil {
+		newData = make(map[string]any)
+	}
+	current := newData
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part]
+		if !ok || next == nil {
+			child := make(map[string]any)
+			current[part] = child
+			current = child
+			continue
+		}
+		child, ok := next.(map[string]any)
+		if !ok {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("cannot set %s: field %s is not an object", path, part), nil)
+		}
+		current = child
+	}
+	current[parts[len(parts)-1]] = value
+
+	oldData := d.data
+	d.data = newData
+	if err := d.Save(ctx); err != nil {
+		d.data = oldData
+		return err
+	}
+	return nil
+}
+
+// GetNestedString 按点号路径获取字符串字段，路径不存在或类型不符时返回 false。
+func (d *document) GetNestedString(path string) (string, bool) {
+	v, ok := d.lookupPath(path)
+	if !ok {
+		return "", false
+	}
+	s, ok := v.(string)
+	return s, ok
+}
+
+// GetNestedFloat 按点号路径获取浮点数字段，整数值会转换为 float64。
+func (d *document) GetNestedFloat(path string) (float64, bool) {
+	v, ok := d.lookupPath(path)
+	if !ok {
+		return 0, false
+	}
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// GetNestedInt 按点号路径获取整数字段。
+// JSON 解码后的数字为 float64，只有整数值的 float64 才会转换成功。
+func (d *document) GetNestedInt(path string) (int, bool) {
+	v, ok := d.lookupPath(path)
+	if !ok {
+		return 0, false
+	}
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		if n != math.Trunc(n) {
+			return 0, false
+		}
+		return int(n), true
+	}
+	return 0, false
+}
+
+// GetNestedBool 按点号路径获取布尔字段。
+func (d *document) GetNestedBool(path string) (bool, bool) {
+	v, ok := d.lookupPath(path)
+	if !ok {
+		return false, false
+	}
+	b, ok := v.(bool)
+	return b, ok
+}
+
+// GetNestedSlice 按点号路径获取数组字段。
+func (d *document) GetNestedSlice(path string) ([]any, bool) {
+	v, ok := d.lookupPath(path)
+	if !ok {
+		return nil, false
+	}
+	arr, ok := v.([]any)
+	return arr, ok
+}
+
+// Set 设置字段值（不保存到数据库）并返回文档本身，便于链式调用：
+//
+//	doc.Set("name", "Alice").Set("age", 30).Apply(ctx)
+//
+// 修改会立即反映在 Data() 中，同时记录为待应用修改，由 Apply 或 Save 持久化。
+func (d *document) Set(field string, value any) Document {
+	if d.data == nil {
+		d.data = make(map[string]any)
+	}
+	if d.pending == nil {
+		d.pending = make(map[string]any)
+	}
 	d.data[field] = value
+	d.pending[field] = value
+	return d
+}
+
+// Apply 将 Set 记录的待应用修改通过 Update 保存到数据库。
+// 没有待应用修改时不执行写入，因此连续调用是幂等的。
+func (d *document) Apply(ctx context.Context) error {
+	if d.collection == nil {
+		return fmt.Errorf("document is not associated with a collection")
+	}
+	if len(d.pending) == 0 {
+		return nil
+	}
+	if err := d.Update(ctx, d.pending); err != nil {
+		return err
+	}
+	d.pending = nil
 	return nil
 }
 
 // Update 更新文档的多个字段并保存到数据库。
+// updates 也可以只包含更新操作符（$set、$unset、$inc、$push、$pull、$addToSet、$rename），此时原子地应用到存储中的最新文档。
 func (d *document) Update(ctx context.Context, updates map[string]any) error {
 	if d.collection == nil {
 		return fmt.Errorf("document is not associated with a collection")
 	}
 
+	// 使用更新操作符时在集合锁内基于存储中的最新文档读改写，避免并发更新丢失
+	if hasUpdateOperators(updates) {
+		return d.AtomicUpdate(ctx, func(doc map[string]any) error {
+			return applyUpdateOperators(doc, updates, d.collection.isPrimaryKeyField)
+		})
+	}
+
 	// 复制当前数据，避免在保存失败时污染内存状态
 	newData := DeepCloneMap(d.data)
 
@@ -136,12 +325,36 @@ func (d *document) Remove(ctx context.Context) error {
 	return d.collection.Remove(ctx, d.id)
 }
 
+// Clone 返回文档的深拷贝副本，修改副本不会影响原文档。
+// 副本与集合中的文档解耦，调用其 Save 等同于对集合执行 Upsert。
+func (d *document) Clone() Document {
+	return &document{
+		id:         d.id,
+		data:       DeepCloneMap(d.data),
+		collection: d.collection,
+		revField:   d.revField,
+		detached:   true,
+		pending:    DeepCloneMap(d.pending),
+	}
+}
+
 // Save 保存文档到数据库。
 func (d *document) Save(ctx context.Context) error {
 	if d.collection == nil {
 		return fmt.Errorf("document is not associated with a collection")
 	}
 
+	if d.detached {
+		saved, err := d.collection.upsert(ctx, DeepCloneMap(d.data))
+		if err != nil {
+			return err
+		}
+		d.id = saved.ID()
+		d.data = DeepCloneMap(saved.Data())
+		d.pending = nil
+		return nil
+	}
+
 	d.collection.mu.Lock()
 
 	if d.collection.closed {
@@ -176,6 +389,26 @@ func (d *document) Save(ctx context.Context) error {
 		}
 	}
 
+	// 调用 BeforeUpdate 钩子（文档已被删除时视为插入，调用 BeforeInsert）
+	hooks := d.collection.hooks
+	if oldDoc != nil {
+		if d.data, err = d.collection.runBeforeUpdate(ctx, hooks, d.id, oldDocForIndex, d.data); err != nil {
+			d.collection.mu.Unlock()
+			return err
+		}
+	} else if hooks.BeforeInsert != nil {
+		if d.data, err = runBeforeInsert(ctx, hooks, d.data); err == nil {
+			err = d.collection.checkHookResult(d.id, d.data)
+		}
+		if err != nil {
+			d.collection.mu.Unlock()
+			return err
+		}
+	}
+
+	// 启用 CoerceTypes 时按 Schema 声明的类型转换字段值
+	CoerceDocumentTypes(d.collection.schema, d.data)
+
 	// 验证 final 字段（如果文档已存在）
 	if oldDoc != nil {
 		if err := ValidateFinalFields(d.collection.schema, oldDoc, d.data); err != nil {
@@ -233,12 +466,19 @@ func (d *document) Save(ctx context.Context) error {
 	}
 
 	// 原子写入：使用单个事务同时更新文档和所有索引
-	err = d.collection.store.WithUpdate(ctx, func(txn *badger.Txn) error {
+	err = d.collection.updateWithChangelog(ctx, func(txn kvTxn, changes *changelogTxn) error {
 		// 1. 写入文档
 		docKey := bstore.BucketKey(d.collection.name, d.id)
 		if err := txn.Set(docKey, data); err != nil {
 			return err
 		}
+		op := OperationInsert
+		if oldDoc != nil {
+			op = OperationUpdate
+		}
+		if err := changes.add(d.id, op); err != nil {
+			return err
+		}
 
 		// 2. 更新索引
 		if oldDoc != nil {
@@ -280,9 +520,12 @@ func (d *document) Save(ctx context.Context) error {
 		Meta:       map[string]interface{}{"rev": rev},
 	}
 
-	// 释放锁后再发送变更事件，避免死锁
+	d.pending = nil
+
+	// 释放锁后再调用 After* 钩子和发送变更事件，避免死锁
 	d.collection.mu.Unlock()
-	d.collection.emitChange(changeEvent)
+	d.collection.runAfterWrite(ctx, hooks, d.id, d.data, oldDoc)
+	d.collection.emitChange(ctx, changeEvent)
 
 	return nil
 }
@@ -308,6 +551,30 @@ func (d *document) ToMutableJSON() (map[string]any, error) {
 	return DeepCloneMap(d.data), nil
 }
 
+// DocumentFromJSON 将 ToJSON 生成的 JSON 反序列化为文档，主键按 schema 从数据中提取。
+// 返回的文档不关联任何集合，Save/Update 等持久化操作会返回错误；
+// 需要写入时调用 collection.Insert(ctx, doc.Data())。
+func DocumentFromJSON(data []byte, schema Schema) (Document, error) {
+	var docData map[string]any
+	if err := json.Unmarshal(data, &docData); err != nil {
+		return nil, NewError(ErrorTypeValidation, "failed to unmarshal document JSON", err)
+	}
+	if docData == nil {
+		return nil, NewError(ErrorTypeValidation, "document JSON must be an object", nil)
+	}
+
+	id, err := (&collection{schema: schema}).extractPrimaryKey(docData)
+	if err != nil {
+		return nil, NewError(ErrorTypeValidation, "primary key validation failed", err)
+	}
+
+	return &document{
+		id:       id,
+		data:     docData,
+		revField: schema.RevField,
+	}, nil
+}
+
 // Deleted 检查文档是否已删除。
 func (d *document) Deleted(ctx context.Context) (bool, error) {
 	if d.collection == nil {
@@ -366,6 +633,12 @@ func (d *document) AtomicUpdate(ctx context.Context, updateFn func(doc map[strin
 		}
 	}
 
+	hooks := d.collection.hooks
+	var beforeUpdate map[string]any
+	if hooks.BeforeUpdate != nil {
+		beforeUpdate = DeepCloneMap(currentDoc)
+	}
+
 	// 应用更新函数
 	if err := updateFn(currentDoc); err != nil {
 		d.collection.mu.Unlock()
@@ -381,6 +654,15 @@ func (d *document) AtomicUpdate(ctx context.Context, updateFn func(doc map[strin
 		}
 	}
 
+	// 调用 BeforeUpdate 钩子
+	if beforeUpdate != nil {
+		if currentDoc, err = d.collection.runBeforeUpdate(ctx, hooks, d.id, beforeUpdate, currentDoc); err != nil {
+			d.collection.mu.Unlock()
+			return err
+		}
+	}
+	CoerceDocumentTypes(d.collection.schema, currentDoc)
+
 	// 更新修订号
 	var oldRev string
 	if rev, ok := currentDoc[d.revField]; ok {
@@ -457,11 +739,14 @@ func (d *document) AtomicUpdate(ctx context.Context, updateFn func(doc map[strin
 		return err
 	}
 	// 原子写入：在单个事务中更新文档和索引
-	err = d.collection.store.WithUpdate(ctx, func(txn *badger.Txn) error {
+	err = d.collection.updateWithChangelog(ctx, func(txn kvTxn, changes *changelogTxn) error {
 		docKey := bstore.BucketKey(d.collection.name, d.id)
 		if err := txn.Set(docKey, newData); err != nil {
 			return err
 		}
+		if err := changes.add(d.id, OperationUpdate); err != nil {
+			return err
+		}
 
 		// 更新索引（先删除旧索引，再添加新索引）
 		if err := d.collection.updateIndexesInTx(txn, oldDocForIndex, d.id, true); err != nil {
@@ -488,9 +773,10 @@ func (d *document) AtomicUpdate(ctx context.Context, updateFn func(doc map[strin
 		Meta:       map[string]interface{}{"rev": rev},
 	}
 
-	// 释放锁后再发送变更事件，避免死锁
+	// 释放锁后再调用 AfterUpdate 钩子和发送变更事件，避免死锁
 	d.collection.mu.Unlock()
-	d.collection.emitChange(changeEvent)
+	d.collection.runAfterWrite(ctx, hooks, d.id, currentDoc, oldDoc)
+	d.collection.emitChange(ctx, changeEvent)
 
 	return nil
 }
@@ -676,3 +962,79 @@ func (d *document) GetAllAttachments(ctx context.Context) ([]*Attachment, error)
 	}
 	return d.collection.GetAllAttachments(ctx, d.id)
 }
+
+// Diff 比较当前文档与 other 的 Data()，返回字段级差异。
+// 嵌套对象递归比较，键为点号路径（如 "address.city"）；数组逐元素比较，
+// 有差异时整体记为 changed。other 为 nil 时所有字段均视为 removed。
+func (d *document) Diff(other Document) map[string]DiffEntry {
+	var newData map[string]any
+	if other != nil {
+		newData = other.Data()
+	}
+	diff := make(map[string]DiffEntry)
+	diffMaps("", d.Data(), newData, diff)
+	return diff
+}
+
+func diffMaps(prefix string, oldData, newData map[string]any, diff map[string]DiffEntry) {
+	for key, oldVal := range oldData {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		newVal, ok := newData[key]
+		if !ok {
+			diff[path] = DiffEntry{Old: oldVal, Type: DiffRemoved}
+			continue
+		}
+		oldMap, oldIsMap := oldVal.(map[string]any)
+		newMap, newIsMap := newVal.(map[string]any)
+		if oldIsMap && newIsMap {
+			diffMaps(path, oldMap, newMap, diff)
+			continue
+		}
+		if !diffValuesEqual(oldVal, newVal) {
+			diff[path] = DiffEntry{Old: oldVal, New: newVal, Type: DiffChanged}
+		}
+	}
+	for key, newVal := range newData {
+		if _, ok := oldData[key]; ok {
+			continue
+		}
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		diff[path] = DiffEntry{New: newVal, Type: DiffAdded}
+	}
+}
+
+// diffValuesEqual 深度比较两个值，数值按大小比较（int 与 float64 视为相等）。
+func diffValuesEqual(a, b any) bool {
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			w, ok := bv[k]
+			if !ok || !diffValuesEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !diffValuesEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return compareEqual(a, b)
+}
@@ -3,7 +3,10 @@ package rxdb
 import (
 	"context"
 	"fmt"
+	"math"
 	"os"
+	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -449,10 +452,7 @@ func TestDocument_Set(t *testing.T) {
 	}
 
 	// 设置字段值（不保存）
-	err = doc.Set(ctx, "name", "Modified")
-	if err != nil {
-		t.Fatalf("Failed to set field: %v", err)
-	}
+	doc.Set("name", "Modified")
 
 	if doc.GetString("name") != "Modified" {
 		t.Errorf("Expected 'Modified', got '%s'", doc.GetString("name"))
@@ -539,6 +539,115 @@ func TestDocument_Update(t *testing.T) {
 	}
 }
 
+func TestDocument_UpdateOperators(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "test", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	doc, err := collection.Insert(ctx, map[string]any{
+		"id":      "doc1",
+		"name":    "Original",
+		"counter": 1,
+		"tags":    []any{"go", "db"},
+		"roles":   []any{"user"},
+		"temp":    "x",
+		"old":     "value",
+		"profile": map[string]any{"visits": 2},
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+
+	// 一次调用组合多个操作符
+	err = doc.Update(ctx, map[string]any{
+		"$set":      map[string]any{"name": "Updated", "profile.city": "Berlin"},
+		"$inc":      map[string]any{"counter": 2, "profile.visits": -1, "fresh": 5},
+		"$unset":    map[string]any{"temp": "", "missing": ""},
+		"$push":     map[string]any{"tags": "rxdb"},
+		"$pull":     map[string]any{"roles": "user"},
+		"$addToSet": map[string]any{"extra": map[string]any{"$each": []any{"a", "b", "a"}}},
+		"$rename":   map[string]any{"old": "renamed"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to update with operators: %v", err)
+	}
+	if err := doc.Update(ctx, map[string]any{"$addToSet": map[string]any{"tags": "go", "roles": "admin"}}); err != nil {
+		t.Fatalf("Failed to apply $addToSet: %v", err)
+	}
+
+	stored, err := collection.FindByID(ctx, "doc1")
+	if err != nil {
+		t.Fatalf("Failed to find document: %v", err)
+	}
+	for _, d := range []Document{doc, stored} {
+		data := d.Data()
+		expected := map[string]any{
+			"name":    "Updated",
+			"counter": 3.0,
+			"fresh":   5.0,
+			"tags":    []any{"go", "db", "rxdb"},
+			"roles":   []any{"admin"},
+			"extra":   []any{"a", "b"},
+			"renamed": "value",
+			"profile": map[string]any{"visits": 1.0, "city": "Berlin"},
+		}
+		for field, want := range expected {
+			if !reflect.DeepEqual(data[field], want) {
+				t.Errorf("Field %s = %#v, want %#v", field, data[field], want)
+			}
+		}
+		for _, field := range []string{"temp", "old"} {
+			if _, ok := data[field]; ok {
+				t.Errorf("Field %s should be removed", field)
+			}
+		}
+	}
+
+	// 非法操作返回验证错误且不修改文档
+	invalid := []map[string]any{
+		{"$push": map[string]any{"name": "x"}},
+		{"$addToSet": map[string]any{"counter": 1}},
+		{"$pull": map[string]any{"name": "x"}},
+		{"$inc": map[string]any{"name": 1}},
+		{"$inc": map[string]any{"counter": "1"}},
+		{"$set": map[string]any{"id": "other"}},
+		{"$set": map[string]any{"name": "x"}, "counter": 1},
+		{"$set": map[string]any{"profile": map[string]any{}}, "$inc": map[string]any{"profile.visits": 1}},
+		{"$max": map[string]any{"counter": 10}},
+	}
+	for _, updates := range invalid {
+		if err := doc.Update(ctx, updates); !IsValidationError(err) {
+			t.Errorf("Expected validation error for %v, got %v", updates, err)
+		}
+	}
+	stored, _ = collection.FindByID(ctx, "doc1")
+	if stored.Get("name") != "Updated" || stored.Get("counter") != 3.0 {
+		t.Errorf("Invalid updates should not modify the document: %v", stored.Data())
+	}
+
+	// 并发 $inc 不丢失更新
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, err := collection.FindByID(ctx, "doc1")
+			if err != nil {
+				t.Errorf("Failed to find document: %v", err)
+				return
+			}
+			if err := d.Update(ctx, map[string]any{"$inc": map[string]any{"counter": 1}}); err != nil {
+				t.Errorf("Concurrent $inc failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	stored, _ = collection.FindByID(ctx, "doc1")
+	if stored.Get("counter") != 23.0 {
+		t.Errorf("Expected counter 23 after concurrent increments, got %v", stored.Get("counter"))
+	}
+}
+
 func TestDocument_Save(t *testing.T) {
 	ctx := context.Background()
 	dbPath := "../../data/test_document_save.db"
@@ -572,7 +681,7 @@ func TestDocument_Save(t *testing.T) {
 	}
 
 	// 修改字段
-	doc.Set(ctx, "name", "Modified")
+	doc.Set("name", "Modified")
 
 	// 保存
 	err = doc.Save(ctx)
@@ -774,6 +883,114 @@ func TestDocument_ToJSON_Encryption(t *testing.T) {
 	}
 }
 
+// TestDocumentFromJSON 测试 ToJSON 与 DocumentFromJSON 的往返转换
+func TestDocumentFromJSON(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_document_fromjson.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "testdb",
+		Path: dbPath,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	schema := Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	}
+	source, err := db.Collection(ctx, "source", schema)
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	original, err := source.Insert(ctx, map[string]any{
+		"id":   "doc1",
+		"name": "Test",
+		"age":  30,
+		"tags": []any{"a", "b"},
+		"meta": map[string]any{"active": true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+
+	jsonData, err := original.ToJSON()
+	if err != nil {
+		t.Fatalf("Failed to convert to JSON: %v", err)
+	}
+
+	doc, err := DocumentFromJSON(jsonData, schema)
+	if err != nil {
+		t.Fatalf("Failed to create document from JSON: %v", err)
+	}
+	if doc.ID() != "doc1" {
+		t.Errorf("Expected ID 'doc1', got '%s'", doc.ID())
+	}
+	if doc.GetString("_rev") != original.GetString("_rev") {
+		t.Errorf("Expected _rev %q, got %q", original.GetString("_rev"), doc.GetString("_rev"))
+	}
+	if doc.GetInt("age") != 30 {
+		t.Errorf("Expected age 30, got %d", doc.GetInt("age"))
+	}
+	if !reflect.DeepEqual(doc.GetArray("tags"), []any{"a", "b"}) {
+		t.Errorf("Expected tags [a b], got %v", doc.GetArray("tags"))
+	}
+	if active, ok := doc.GetNestedBool("meta.active"); !ok || !active {
+		t.Errorf("Expected meta.active true, got %v", active)
+	}
+
+	// 再次序列化结果一致
+	again, err := doc.ToJSON()
+	if err != nil {
+		t.Fatalf("Failed to convert to JSON: %v", err)
+	}
+	if string(again) != string(jsonData) {
+		t.Errorf("Expected roundtrip JSON %s, got %s", jsonData, again)
+	}
+
+	// 未关联集合的文档不能直接保存
+	if err := doc.Save(ctx); err == nil {
+		t.Error("Expected error when saving detached document")
+	}
+
+	// 插入后才关联到集合
+	target, err := db.Collection(ctx, "target", schema)
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	inserted, err := target.Insert(ctx, doc.Data())
+	if err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+	if inserted.GetString("name") != "Test" {
+		t.Errorf("Expected name 'Test', got '%s'", inserted.GetString("name"))
+	}
+	if err := inserted.Update(ctx, map[string]any{"name": "Updated"}); err != nil {
+		t.Fatalf("Failed to update inserted document: %v", err)
+	}
+
+	// 复合主键
+	compositeSchema := Schema{PrimaryKey: []string{"tenant", "key"}}
+	composite, err := DocumentFromJSON([]byte(`{"tenant":"t1","key":"k1","value":1}`), compositeSchema)
+	if err != nil {
+		t.Fatalf("Failed to create document from JSON: %v", err)
+	}
+	if composite.ID() != `["t1","k1"]` {
+		t.Errorf("Expected composite ID [\"t1\",\"k1\"], got %s", composite.ID())
+	}
+
+	// 非法输入
+	for _, input := range []string{`not json`, `null`, `[1,2]`, `{"name":"no id"}`} {
+		if _, err := DocumentFromJSON([]byte(input), schema); !IsValidationError(err) {
+			t.Errorf("Expected validation error for %s, got %v", input, err)
+		}
+	}
+}
+
 func TestDocument_ToMutableJSON(t *testing.T) {
 	ctx := context.Background()
 	dbPath := "../../data/test_document_mutablejson.db"
@@ -936,6 +1153,43 @@ func TestDocument_Changes(t *testing.T) {
 	}
 }
 
+func TestUpdateOperators_IncIntegers(t *testing.T) {
+	doc := map[string]any{
+		"count":  1,
+		"stored": 2.0, // 从 JSON 读出的整数值
+		"ratio":  1.5,
+		"big":    int64(1<<60 + 1),
+		"max":    int64(math.MaxInt64),
+	}
+	err := applyUpdateOperators(doc, map[string]any{"$inc": map[string]any{
+		"count":  2,
+		"stored": int32(3),
+		"ratio":  1,
+		"big":    1,
+		"max":    1,
+		"fresh":  4,
+		"float":  0.5,
+	}}, func(string) bool { return false })
+	if err != nil {
+		t.Fatalf("Failed to apply $inc: %v", err)
+	}
+
+	expected := map[string]any{
+		"count":  int64(3),
+		"stored": int64(5),
+		"ratio":  2.5,
+		"big":    int64(1<<60 + 2),
+		"max":    float64(math.MaxInt64) + 1, // 溢出时改用浮点
+		"fresh":  int64(4),
+		"float":  0.5,
+	}
+	for field, want := range expected {
+		if !reflect.DeepEqual(doc[field], want) {
+			t.Errorf("Field %s = %#v, want %#v", field, doc[field], want)
+		}
+	}
+}
+
 func TestDocument_AtomicUpdate(t *testing.T) {
 	ctx := context.Background()
 	dbPath := "../../data/test_document_atomic_update.db"
@@ -1442,3 +1696,452 @@ func TestDocument_ChangesChannelClose(t *testing.T) {
 		t.Error("Channel should be closed after database close")
 	}
 }
+
+func TestDocument_Clone(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_document_clone.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "testdb",
+		Path: dbPath,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	collection, err := db.Collection(ctx, "test", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	doc, err := collection.Insert(ctx, map[string]any{
+		"id":      "doc1",
+		"name":    "Alice",
+		"profile": map[string]any{"city": "Beijing"},
+		"tags":    []any{"a", "b"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+
+	clone := doc.Clone()
+	if clone.ID() != doc.ID() {
+		t.Errorf("Expected clone ID %s, got %s", doc.ID(), clone.ID())
+	}
+
+	// 修改副本（包括嵌套对象与数组）不应影响原文档
+	clone.Data()["name"] = "Bob"
+	clone.GetObject("profile")["city"] = "Shanghai"
+	clone.GetArray("tags")[0] = "z"
+
+	if doc.GetString("name") != "Alice" {
+		t.Errorf("Expected original name Alice, got %s", doc.GetString("name"))
+	}
+	if doc.GetObject("profile")["city"] != "Beijing" {
+		t.Errorf("Expected original city Beijing, got %v", doc.GetObject("profile")["city"])
+	}
+	if doc.GetArray("tags")[0] != "a" {
+		t.Errorf("Expected original tag a, got %v", doc.GetArray("tags")[0])
+	}
+
+	stored, err := collection.FindByID(ctx, "doc1")
+	if err != nil {
+		t.Fatalf("Failed to find document: %v", err)
+	}
+	if stored.GetString("name") != "Alice" {
+		t.Errorf("Expected stored name Alice, got %s", stored.GetString("name"))
+	}
+
+	// 副本的 Save 等同于 Upsert
+	if err := clone.Save(ctx); err != nil {
+		t.Fatalf("Failed to save clone: %v", err)
+	}
+	stored, err = collection.FindByID(ctx, "doc1")
+	if err != nil {
+		t.Fatalf("Failed to find document: %v", err)
+	}
+	if stored.GetString("name") != "Bob" {
+		t.Errorf("Expected stored name Bob after saving clone, got %s", stored.GetString("name"))
+	}
+	if doc.GetString("name") != "Alice" {
+		t.Errorf("Expected original document to stay Alice, got %s", doc.GetString("name"))
+	}
+
+	// 修改主键后保存副本会插入新文档
+	copyDoc := doc.Clone()
+	copyDoc.Data()["id"] = "doc2"
+	if err := copyDoc.Save(ctx); err != nil {
+		t.Fatalf("Failed to save clone with new id: %v", err)
+	}
+	if copyDoc.ID() != "doc2" {
+		t.Errorf("Expected clone ID doc2 after save, got %s", copyDoc.ID())
+	}
+	count, err := collection.Count(ctx)
+	if err != nil {
+		t.Fatalf("Failed to count documents: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 documents, got %d", count)
+	}
+}
+
+func TestDocument_NestedGetters(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_document_nested_getters.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "testdb",
+		Path: dbPath,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	collection, err := db.Collection(ctx, "test", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	if _, err := collection.Insert(ctx, map[string]any{
+		"id":      "doc1",
+		"address": map[string]any{"city": "Beijing", "lat": 39.9, "geo": nil},
+		"scores":  map[string]any{"math": 95.5},
+		"flags":   map[string]any{"active": true},
+		"stats":   map[string]any{"views": 42, "ratio": 0.5},
+		"tags":    []any{"go", "db"},
+		"items":   []any{map[string]any{"name": "first"}, map[string]any{"name": "second"}},
+	}); err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+
+	// 重新读取，数字经过 JSON 解码后为 float64
+	doc, err := collection.FindByID(ctx, "doc1")
+	if err != nil {
+		t.Fatalf("Failed to find document: %v", err)
+	}
+
+	t.Run("nested path exists", func(t *testing.T) {
+		if v, ok := doc.GetNestedString("address.city"); !ok || v != "Beijing" {
+			t.Errorf("GetNestedString(address.city) = %q, %v", v, ok)
+		}
+		if v, ok := doc.GetNestedFloat("scores.math"); !ok || v != 95.5 {
+			t.Errorf("GetNestedFloat(scores.math) = %v, %v", v, ok)
+		}
+		if v, ok := doc.GetNestedBool("flags.active"); !ok || !v {
+			t.Errorf("GetNestedBool(flags.active) = %v, %v", v, ok)
+		}
+	})
+
+	t.Run("intermediate path is nil", func(t *testing.T) {
+		if _, ok := doc.GetNestedFloat("address.geo.lat"); ok {
+			t.Error("GetNestedFloat through nil should return false")
+		}
+		if _, ok := doc.GetNestedString("missing.city"); ok {
+			t.Error("GetNestedString through missing field should return false")
+		}
+		if _, ok := doc.GetNestedString("address.geo"); ok {
+			t.Error("GetNestedString on nil value should return false")
+		}
+	})
+
+	t.Run("type mismatch", func(t *testing.T) {
+		if _, ok := doc.GetNestedString("address.lat"); ok {
+			t.Error("GetNestedString on number should return false")
+		}
+		if _, ok := doc.GetNestedBool("address.city"); ok {
+			t.Error("GetNestedBool on string should return false")
+		}
+		if _, ok := doc.GetNestedSlice("address"); ok {
+			t.Error("GetNestedSlice on object should return false")
+		}
+		if _, ok := doc.GetNestedFloat("address.city.name"); ok {
+			t.Error("GetNestedFloat through string should return false")
+		}
+	})
+
+	t.Run("numeric coercion", func(t *testing.T) {
+		if v, ok := doc.GetNestedInt("stats.views"); !ok || v != 42 {
+			t.Errorf("GetNestedInt(stats.views) = %v, %v", v, ok)
+		}
+		if _, ok := doc.GetNestedInt("stats.ratio"); ok {
+			t.Error("GetNestedInt on non-integral float should return false")
+		}
+		if v, ok := doc.GetNestedFloat("stats.views"); !ok || v != 42 {
+			t.Errorf("GetNestedFloat(stats.views) = %v, %v", v, ok)
+		}
+	})
+
+	t.Run("array access", func(t *testing.T) {
+		tags, ok := doc.GetNestedSlice("tags")
+		if !ok || len(tags) != 2 || tags[0] != "go" {
+			t.Errorf("GetNestedSlice(tags) = %v, %v", tags, ok)
+		}
+		if v, ok := doc.GetNestedString("tags.1"); !ok || v != "db" {
+			t.Errorf("GetNestedString(tags.1) = %q, %v", v, ok)
+		}
+		if v, ok := doc.GetNestedString("items.1.name"); !ok || v != "second" {
+			t.Errorf("GetNestedString(items.1.name) = %q, %v", v, ok)
+		}
+		if _, ok := doc.GetNestedString("tags.5"); ok {
+			t.Error("Out of range index should return false")
+		}
+		if _, ok := doc.GetNestedString("tags.x"); ok {
+			t.Error("Non-numeric index should return false")
+		}
+	})
+}
+
+func TestDocument_GetSetNested(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "nested", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	doc, err := coll.Insert(ctx, map[string]any{
+		"id":    "doc1",
+		"items": []any{map[string]any{"price": 9.5}},
+		"tags":  "plain",
+	})
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	if v, ok := doc.GetNested("items.0.price"); !ok || v != 9.5 {
+		t.Errorf("GetNested(items.0.price) = %v, %v", v, ok)
+	}
+	if _, ok := doc.GetNested("items.1.price"); ok {
+		t.Error("GetNested on missing element should return false")
+	}
+
+	// 中间对象自动创建，并通过点号查询读回
+	if err := doc.SetNested(ctx, "profile.address.city", "Berlin"); err != nil {
+		t.Fatalf("SetNested failed: %v", err)
+	}
+	if v, ok := doc.GetNestedString("profile.address.city"); !ok || v != "Berlin" {
+		t.Errorf("GetNestedString after SetNested = %q, %v", v, ok)
+	}
+	results, err := coll.Find(map[string]any{"profile.address.city": "Berlin"}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID() != "doc1" {
+		t.Fatalf("Expected doc1 to match nested query, got %d results", len(results))
+	}
+	if v, ok := results[0].GetNested("profile.address.city"); !ok || v != "Berlin" {
+		t.Errorf("Stored nested value = %v, %v", v, ok)
+	}
+	if v, ok := results[0].GetNestedFloat("items.0.price"); !ok || v != 9.5 {
+		t.Errorf("Existing fields should be kept, got %v, %v", v, ok)
+	}
+
+	// 路径经过非对象值或指向主键时报错，且不修改文档
+	if err := doc.SetNested(ctx, "tags.first", "x"); err == nil {
+		t.Error("Expected error when path traverses a non-object value")
+	}
+	if err := doc.SetNested(ctx, "id", "doc2"); err == nil {
+		t.Error("Expected error when setting the primary key")
+	}
+	if v, _ := doc.GetNestedString("tags"); v != "plain" {
+		t.Errorf("Document should be unchanged after failed SetNested, got %v", doc.Data())
+	}
+}
+
+func TestDocument_SetApply(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_document_set_apply.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "testdb",
+		Path: dbPath,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	collection, err := db.Collection(ctx, "test", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	fluent, err := collection.Insert(ctx, map[string]any{"id": "doc1", "name": "Original", "age": 20, "city": "Beijing"})
+	if err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+	classic, err := collection.Insert(ctx, map[string]any{"id": "doc2", "name": "Original", "age": 20, "city": "Beijing"})
+	if err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+
+	// 链式 Set + Apply
+	if err := fluent.Set("name", "Alice").Set("age", 30).Apply(ctx); err != nil {
+		t.Fatalf("Failed to apply: %v", err)
+	}
+	// 等价的 Update + Save
+	if err := classic.Update(ctx, map[string]any{"name": "Alice", "age": 30}); err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if err := classic.Save(ctx); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	stored1, err := collection.FindByID(ctx, "doc1")
+	if err != nil {
+		t.Fatalf("Failed to find doc1: %v", err)
+	}
+	stored2, err := collection.FindByID(ctx, "doc2")
+	if err != nil {
+		t.Fatalf("Failed to find doc2: %v", err)
+	}
+	for _, field := range []string{"name", "age", "city"} {
+		if !reflect.DeepEqual(stored1.Get(field), stored2.Get(field)) {
+			t.Errorf("Field %s differs: Set+Apply=%v, Update+Save=%v", field, stored1.Get(field), stored2.Get(field))
+		}
+	}
+	if stored1.GetString("name") != "Alice" || stored1.GetInt("age") != 30 {
+		t.Errorf("Unexpected stored document: %v", stored1.Data())
+	}
+
+	// 没有新的 Set 时再次 Apply 不会产生写入
+	rev := stored1.GetString("_rev")
+	if err := fluent.Apply(ctx); err != nil {
+		t.Fatalf("Failed to apply again: %v", err)
+	}
+	again, err := collection.FindByID(ctx, "doc1")
+	if err != nil {
+		t.Fatalf("Failed to find doc1: %v", err)
+	}
+	if again.GetString("_rev") != rev {
+		t.Errorf("Second Apply should be a no-op, rev changed from %s to %s", rev, again.GetString("_rev"))
+	}
+	if !reflect.DeepEqual(again.Data(), stored1.Data()) {
+		t.Errorf("Second Apply changed document: %v -> %v", stored1.Data(), again.Data())
+	}
+}
+
+func TestDocument_Diff(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_document_diff.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "testdb",
+		Path: dbPath,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	collection, err := db.Collection(ctx, "test", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	doc, err := collection.Insert(ctx, map[string]any{
+		"id":   "doc1",
+		"name": "Alice",
+		"age":  30,
+		"tags": []any{"a", "b"},
+		"address": map[string]any{
+			"city": "Beijing",
+			"zip":  "100000",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+
+	t.Run("identical documents", func(t *testing.T) {
+		diff := doc.Diff(doc.Clone())
+		if diff == nil || len(diff) != 0 {
+			t.Errorf("Expected empty diff, got %v", diff)
+		}
+	})
+
+	t.Run("single field update", func(t *testing.T) {
+		if err := doc.Clone().Set("name", "Bob").Save(ctx); err != nil {
+			t.Fatalf("Failed to save document: %v", err)
+		}
+		updated, err := collection.FindByID(ctx, "doc1")
+		if err != nil {
+			t.Fatalf("Failed to find document: %v", err)
+		}
+		diff := doc.Diff(updated)
+		want := DiffEntry{Old: "Alice", New: "Bob", Type: DiffChanged}
+		if diff["name"] != want {
+			t.Errorf("Expected %v for name, got %v", want, diff["name"])
+		}
+		if diff["_rev"].Type != DiffChanged {
+			t.Errorf("Expected _rev to be changed, got %v", diff["_rev"])
+		}
+		if len(diff) != 2 {
+			t.Errorf("Expected 2 diff entries, got %v", diff)
+		}
+	})
+
+	t.Run("field added", func(t *testing.T) {
+		diff := doc.Diff(doc.Clone().Set("email", "alice@example.com"))
+		want := DiffEntry{New: "alice@example.com", Type: DiffAdded}
+		if len(diff) != 1 || diff["email"] != want {
+			t.Errorf("Expected only %v for email, got %v", want, diff)
+		}
+	})
+
+	t.Run("field removed", func(t *testing.T) {
+		other := doc.Clone()
+		delete(other.Data(), "age")
+		diff := doc.Diff(other)
+		if len(diff) != 1 || diff["age"].Type != DiffRemoved || diff["age"].New != nil {
+			t.Errorf("Expected age to be removed, got %v", diff)
+		}
+		if !compareEqual(diff["age"].Old, 30) {
+			t.Errorf("Expected old age 30, got %v", diff["age"].Old)
+		}
+	})
+
+	t.Run("nested map", func(t *testing.T) {
+		other := doc.Clone().Set("address", map[string]any{
+			"city":    "Shanghai",
+			"zip":     "100000",
+			"country": "CN",
+		})
+		diff := doc.Diff(other)
+		if len(diff) != 2 {
+			t.Errorf("Expected 2 diff entries, got %v", diff)
+		}
+		if want := (DiffEntry{Old: "Beijing", New: "Shanghai", Type: DiffChanged}); diff["address.city"] != want {
+			t.Errorf("Expected %v for address.city, got %v", want, diff["address.city"])
+		}
+		if diff["address.country"].Type != DiffAdded {
+			t.Errorf("Expected address.country to be added, got %v", diff["address.country"])
+		}
+	})
+
+	t.Run("array changed", func(t *testing.T) {
+		diff := doc.Diff(doc.Clone().Set("tags", []any{"a", "c"}))
+		entry, ok := diff["tags"]
+		if !ok || entry.Type != DiffChanged {
+			t.Fatalf("Expected tags to be changed, got %v", diff)
+		}
+		if !reflect.DeepEqual(entry.New, []any{"a", "c"}) {
+			t.Errorf("Expected full new array, got %v", entry.New)
+		}
+	})
+}
@@ -103,3 +103,81 @@ func IsConflictError(err error) bool {
 	}
 	return false
 }
+
+// DocumentTooLargeError 表示文档序列化后的大小超过了 DatabaseOptions.MaxDocumentSize。
+type DocumentTooLargeError struct {
+	DocumentID string
+	Size       int // 实际大小（字节）
+	MaxSize    int // 允许的最大大小（字节）
+}
+
+// Error 实现 error 接口
+func (e *DocumentTooLargeError) Error() string {
+	return fmt.Sprintf("document %s is too large: %d bytes exceeds limit of %d bytes", e.DocumentID, e.Size, e.MaxSize)
+}
+
+// IsDocumentTooLargeError 检查是否是文档过大错误
+func IsDocumentTooLargeError(err error) bool {
+	var e *DocumentTooLargeError
+	return errors.As(err, &e)
+}
+
+// DimensionMismatchError 表示导入的向量索引维度与当前 VectorSearchConfig 不一致。
+type DimensionMismatchError struct {
+	Expected int // 当前配置的维度
+	Actual   int // 导入数据的维度
+}
+
+// Error 实现 error 接口
+func (e *DimensionMismatchError) Error() string {
+	return fmt.Sprintf("vector dimension mismatch: expected %d, got %d", e.Expected, e.Actual)
+}
+
+// IsDimensionMismatchError 检查是否是向量维度不匹配错误
+func IsDimensionMismatchError(err error) bool {
+	var e *DimensionMismatchError
+	return errors.As(err, &e)
+}
+
+// MetricMismatchError 表示导入的向量索引距离度量与当前 VectorSearchConfig 不一致。
+type MetricMismatchError struct {
+	Expected string // 当前配置的距离度量
+	Actual   string // 导入数据的距离度量
+}
+
+// Error 实现 error 接口
+func (e *MetricMismatchError) Error() string {
+	return fmt.Sprintf("vector distance metric mismatch: expected %s, got %s", e.Expected, e.Actual)
+}
+
+// IsMetricMismatchError 检查是否是距离度量不匹配错误
+func IsMetricMismatchError(err error) bool {
+	var e *MetricMismatchError
+	return errors.As(err, &e)
+}
+
+// InvalidRegexError 表示 $regex 的模式或 $options 标志无效。
+type InvalidRegexError struct {
+	Pattern string // 正则模式
+	Options string // $options 标志
+	Err     error  // 编译错误（标志无效时为 nil）
+}
+
+// Error 实现 error 接口
+func (e *InvalidRegexError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid regex %q with options %q: %v", e.Pattern, e.Options, e.Err)
+	}
+	return fmt.Sprintf("invalid regex options %q for pattern %q: supported flags are i, m, s", e.Options, e.Pattern)
+}
+
+// Unwrap 返回底层编译错误
+func (e *InvalidRegexError) Unwrap() error {
+	return e.Err
+}
+
+// IsInvalidRegexError 检查是否是无效正则错误
+func IsInvalidRegexError(err error) bool {
+	var e *InvalidRegexError
+	return errors.As(err, &e)
+}
@@ -2,7 +2,9 @@ package rxdb
 
 import (
 	"context"
+	"errors"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -31,8 +33,7 @@ func TestErrors_ValidationError(t *testing.T) {
 					"type": "string",
 				},
 				"name": map[string]any{
-					"type":     "string",
-					"required": true,
+					"type": "string",
 				},
 			},
 			"required": []any{"id", "name"},
@@ -267,8 +268,7 @@ func TestErrors_Recovery(t *testing.T) {
 					"type": "string",
 				},
 				"name": map[string]any{
-					"type":     "string",
-					"required": true,
+					"type": "string",
 				},
 			},
 			"required": []any{"id", "name"},
@@ -340,3 +340,76 @@ func TestErrors_Recovery(t *testing.T) {
 		t.Errorf("Expected 2 documents, got %d", count)
 	}
 }
+
+// TestErrors_DocumentTooLargeError 测试文档大小限制
+func TestErrors_DocumentTooLargeError(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_errors_doc_too_large.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name:            "testdb",
+		Path:            dbPath,
+		MaxDocumentSize: 256,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	collection, err := db.Collection(ctx, "test", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	// 未超过限制的文档可以正常写入
+	if _, err := collection.Insert(ctx, map[string]any{"id": "small", "name": "ok"}); err != nil {
+		t.Fatalf("Failed to insert document under the limit: %v", err)
+	}
+
+	large := strings.Repeat("x", 512)
+
+	// Insert 超过限制
+	_, err = collection.Insert(ctx, map[string]any{"id": "large", "content": large})
+	if !IsDocumentTooLargeError(err) {
+		t.Fatalf("Expected DocumentTooLargeError on Insert, got: %v", err)
+	}
+	var tooLarge *DocumentTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("Expected *DocumentTooLargeError, got %T", err)
+	}
+	if tooLarge.MaxSize != 256 || tooLarge.Size <= 256 || tooLarge.DocumentID != "large" {
+		t.Errorf("Unexpected error details: %+v", tooLarge)
+	}
+
+	// Upsert 超过限制
+	_, err = collection.Upsert(ctx, map[string]any{"id": "small", "content": large})
+	if !IsDocumentTooLargeError(err) {
+		t.Errorf("Expected DocumentTooLargeError on Upsert, got: %v", err)
+	}
+
+	// BulkInsert 超过限制
+	_, err = collection.BulkInsert(ctx, []map[string]any{
+		{"id": "bulk1", "name": "ok"},
+		{"id": "bulk2", "content": large},
+	})
+	if !IsDocumentTooLargeError(err) {
+		t.Errorf("Expected DocumentTooLargeError on BulkInsert, got: %v", err)
+	}
+
+	// 失败的写入不应修改已有数据
+	count, err := collection.Count(ctx)
+	if err != nil {
+		t.Fatalf("Failed to count documents: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 document, got %d", count)
+	}
+
+	if IsDocumentTooLargeError(errors.New("other")) {
+		t.Error("IsDocumentTooLargeError should be false for unrelated errors")
+	}
+}
@@ -1,20 +1,45 @@
 package rxdb
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
 	_ "embed"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/blevesearch/bleve/v2"
 	"github.com/blevesearch/bleve/v2/analysis"
 	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/lang/da"
+	"github.com/blevesearch/bleve/v2/analysis/lang/de"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/lang/es"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fi"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fr"
+	"github.com/blevesearch/bleve/v2/analysis/lang/hu"
+	"github.com/blevesearch/bleve/v2/analysis/lang/it"
+	"github.com/blevesearch/bleve/v2/analysis/lang/nl"
+	"github.com/blevesearch/bleve/v2/analysis/lang/no"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ro"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ru"
+	"github.com/blevesearch/bleve/v2/analysis/lang/sv"
+	"github.com/blevesearch/bleve/v2/analysis/lang/tr"
 	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/token/ngram"
+	"github.com/blevesearch/bleve/v2/analysis/token/stop"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
 	"github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/v2/analysis/tokenmap"
 	"github.com/blevesearch/bleve/v2/registry"
+	"github.com/blevesearch/bleve/v2/search"
 	"github.com/blevesearch/bleve/v2/search/query"
 	huichensego "github.com/huichen/sego"
 	"github.com/mozhou-tech/rxdb-go/pkg/sego"
@@ -28,31 +53,77 @@ type FulltextSearchConfig struct {
 	// DocToString 将文档转换为可搜索字符串的函数。
 	// 可以返回单个字段值或连接多个字段。
 	DocToString func(doc map[string]any) string
-	// BatchSize 每次索引的文档数量（可选）。
+	// BatchSize 构建或重建索引时每批读取并提交的文档数量（可选，默认 100）。
 	BatchSize int
 	// Initialization 初始化模式："instant"（立即）或 "lazy"（懒加载）。
 	// 默认为 "instant"。
 	Initialization string
 	// IndexOptions 索引选项（可选）。
 	IndexOptions *FulltextIndexOptions
+	// AutoReindexOnConfigChange 运行时修改索引配置（如 UpdateStopWords）后是否自动重建索引。
+	// 为 false 时需手动调用 Reindex，修改才会作用于已索引的文档。
+	AutoReindexOnConfigChange bool
+	// Ranking 相关性评分方式："tf"（默认，bleve 的 TF-IDF）或 "bm25"。
+	// 启用 BM25 时，文档频率与文档长度等统计信息在构建索引时由全部文档计算并保存在索引中，
+	// 之后随集合变更、AddDocument 与 RemoveDocument 增量更新。
+	Ranking string
+	// AutoSync 是否监听集合的 Changes() 自动更新索引，nil 时默认为 true。
+	// 为 false 时索引只在构建、Reindex 以及调用 IndexDocument / UnindexDocument 时更新。
+	AutoSync *bool
 }
 
 // FulltextIndexOptions 全文索引选项。
 type FulltextIndexOptions struct {
-	// Tokenize 分词模式："strict"（严格）、"forward"（前向）、"reverse"（反向）、"full"（完整）。
+	// Tokenize 分词模式："strict"（严格）、"forward"（前向）、"reverse"（反向）、"full"（完整）、
+	// "sego"（中文分词）、"ngram"（字符级 n-gram，适用于无词边界的语言和容错搜索）。
 	Tokenize string
+	// NGramSize ngram 分词模式下每个 n-gram 的字符数，默认为 3。
+	NGramSize int
 	// MinLength 最小搜索词长度。
 	MinLength int
 	// CaseSensitive 是否区分大小写。
 	CaseSensitive bool
 	// StopWords 停用词列表。
 	StopWords []string
+	// StemmerLanguage 词干提取语言（Snowball 算法），如 "english"、"german"、"french"。
+	// 索引与查询都会进行词干提取；sego 分词模式下忽略该选项。
+	StemmerLanguage string
+	// BM25K1 BM25 的词频饱和参数 k1，为 0 时默认 1.2（仅 Ranking 为 "bm25" 时生效）。
+	BM25K1 float64
+	// BM25B BM25 的文档长度归一化参数 b（0-1），为 0 时默认 0.75（仅 Ranking 为 "bm25" 时生效）。
+	BM25B float64
+	// Synonyms 同义词表，键为规范词，值为其同义词，如 {"car": ["automobile", "vehicle"]}。
+	// 同义词与查询词一样经过分析器处理（大小写、词干），分析后不是单个词的条目被忽略。
+	Synonyms map[string][]string
+	// SynonymMode 同义词处理方式："index"（默认，见 SynonymModeIndex）或 "query"（见 SynonymModeQuery）。
+	SynonymMode string
 }
 
 // FulltextSearchResult 全文搜索结果。
 type FulltextSearchResult struct {
-	Document Document
-	Score    float64 // 相关性分数
+	Document   Document
+	ExternalID string             // 通过 AddDocument 索引的外部文档 ID（此时 Document 为 nil）
+	Score      float64            // 相关性分数
+	Debug      *FulltextDebugInfo // 评分调试信息（仅在 Debug 模式下返回）
+	// Highlights 各高亮字段的 HTML 片段，命中的词以 <mark> 标记（仅设置 HighlightFields 时返回，未命中的字段不出现）
+	Highlights map[string]string
+}
+
+// FulltextDebugInfo 全文搜索评分调试信息。
+// 用于排查相关性问题，说明每个文档的得分构成。
+type FulltextDebugInfo struct {
+	// MatchedTerms 文档中命中的查询词（分词后的形式）。
+	MatchedTerms []string
+	// TermFrequencies 每个命中词在文档中出现的次数。
+	TermFrequencies map[string]int
+	// InverseDocFrequencies 每个命中词的逆文档频率。
+	InverseDocFrequencies map[string]float64
+	// FieldLengthNormalization 字段长度归一化因子。
+	FieldLengthNormalization float64
+	// FinalScore 最终分数（与结果 Score 一致）。
+	FinalScore float64
+	// Explanation bleve 返回的原始评分解释树（仅在使用 bleve 自身评分时存在）。
+	Explanation *search.Explanation
 }
 
 // FulltextSearchOptions 全文搜索选项。
@@ -64,6 +135,18 @@ type FulltextSearchOptions struct {
 	// Selector 元数据过滤选择器（Mango 语法）。
 	// 如果提供，将在全文搜索时进行前置过滤。
 	Selector map[string]any
+	// Debug 是否返回评分调试信息（开销较大，仅用于排查问题）。
+	Debug bool
+	// MinTermFreq MoreLikeThis 选取查询词时，词在参考文档中的最小出现次数（默认 1）。
+	MinTermFreq int
+	// MinDocFreq MoreLikeThis 选取查询词时，包含该词的最小文档数（含参考文档本身，默认 2）。
+	MinDocFreq int
+	// MaxQueryTerms MoreLikeThis 合成查询的最大词数（默认 25）。
+	MaxQueryTerms int
+	// HighlightFields 需要生成高亮片段的文档字段（支持点号路径），仅对字符串字段生效。
+	HighlightFields []string
+	// HighlightMaxLength 高亮片段的最大字符数（不含 <mark> 标签，默认 200）。
+	HighlightMaxLength int
 }
 
 // FulltextSearch 全文搜索实例。
@@ -80,13 +163,46 @@ type FulltextSearch struct {
 	initMode    string
 	batchSize   int
 	closeChan   chan struct{}
+	autoReindex bool
+	autoSync    bool
+	ranking     string
+	bm25K1      float64
+	bm25B       float64
+	bm25        bm25Meta
 }
 
 const (
+	// externalDocIDPrefix 外部文档在 bleve 索引中的 ID 前缀，避免与集合文档 ID 冲突
+	externalDocIDPrefix = "_ext:"
+
 	segoAnalyzerName  = "rxdb_sego"
 	segoTokenizerName = "rxdb_sego_tokenizer"
+
+	// defaultNGramSize ngram 分词模式的默认 n-gram 长度
+	defaultNGramSize = 3
+
+	stopWordsTokenMap = "rxdb_stop_words"
+	stopWordsFilter   = "rxdb_stop_words_filter"
 )
 
+// snowballStemmers 支持的词干提取语言与 bleve Snowball 词干过滤器的映射。
+var snowballStemmers = map[string]string{
+	"danish":    da.SnowballStemmerName,
+	"dutch":     nl.SnowballStemmerName,
+	"english":   en.SnowballStemmerName,
+	"finnish":   fi.SnowballStemmerName,
+	"french":    fr.SnowballStemmerName,
+	"german":    de.SnowballStemmerName,
+	"hungarian": hu.SnowballStemmerName,
+	"italian":   it.SnowballStemmerName,
+	"norwegian": no.SnowballStemmerName,
+	"romanian":  ro.SnowballStemmerName,
+	"russian":   ru.SnowballStemmerName,
+	"spanish":   es.SnowballStemmerName,
+	"swedish":   sv.SnowballStemmerName,
+	"turkish":   tr.SnowballStemmerName,
+}
+
 var (
 	registerSegoOnce sync.Once
 )
@@ -161,6 +277,35 @@ func AddFulltextSearch(coll Collection, config FulltextSearchConfig) (*FulltextS
 	if config.DocToString == nil {
 		return nil, fmt.Errorf("docToString function is required")
 	}
+	if config.IndexOptions != nil {
+		switch strings.ToLower(config.IndexOptions.SynonymMode) {
+		case "", SynonymModeIndex, SynonymModeQuery:
+		default:
+			return nil, fmt.Errorf("unsupported synonym mode: %s", config.IndexOptions.SynonymMode)
+		}
+	}
+	if config.IndexOptions != nil && config.IndexOptions.StemmerLanguage != "" {
+		if _, ok := snowballStemmers[strings.ToLower(config.IndexOptions.StemmerLanguage)]; !ok {
+			return nil, fmt.Errorf("unsupported stemmer language: %s", config.IndexOptions.StemmerLanguage)
+		}
+	}
+
+	ranking, err := parseFulltextRanking(config.Ranking)
+	if err != nil {
+		return nil, err
+	}
+	bm25K1, bm25B := defaultBM25K1, defaultBM25B
+	if config.IndexOptions != nil {
+		if config.IndexOptions.BM25K1 < 0 || config.IndexOptions.BM25B < 0 || config.IndexOptions.BM25B > 1 {
+			return nil, fmt.Errorf("invalid bm25 parameters: k1=%v, b=%v", config.IndexOptions.BM25K1, config.IndexOptions.BM25B)
+		}
+		if config.IndexOptions.BM25K1 > 0 {
+			bm25K1 = config.IndexOptions.BM25K1
+		}
+		if config.IndexOptions.BM25B > 0 {
+			bm25B = config.IndexOptions.BM25B
+		}
+	}
 
 	initMode := config.Initialization
 	if initMode == "" {
@@ -192,6 +337,11 @@ func AddFulltextSearch(coll Collection, config FulltextSearchConfig) (*FulltextS
 		initMode:    initMode,
 		batchSize:   batchSize,
 		closeChan:   make(chan struct{}),
+		autoReindex: config.AutoReindexOnConfigChange,
+		autoSync:    config.AutoSync == nil || *config.AutoSync,
+		ranking:     ranking,
+		bm25K1:      bm25K1,
+		bm25B:       bm25B,
 	}
 
 	// 创建或打开 bleve 索引
@@ -208,17 +358,23 @@ func AddFulltextSearch(coll Collection, config FulltextSearchConfig) (*FulltextS
 	}
 
 	// 启动监听变更的 goroutine
-	go fts.watchChanges()
+	// 在返回前订阅，避免遗漏创建后立即发生的变更
+	if fts.autoSync {
+		go fts.watchChanges(fts.collection.Changes())
+	}
 
+	col.registerFulltext(fts)
 	return fts, nil
 }
 
 // openOrCreateIndex 打开或创建 bleve 索引。
 func (fts *FulltextSearch) openOrCreateIndex() error {
+	registerSynonymAnalyzer()
+
 	// 尝试打开现有索引
 	if index, err := bleve.Open(fts.indexPath); err == nil {
 		fts.index = index
-		return nil
+		return fts.loadBM25Meta()
 	}
 
 	// 创建新的索引映射
@@ -231,13 +387,49 @@ func (fts *FulltextSearch) openOrCreateIndex() error {
 
 	// 创建自定义分析器（如果需要）
 	if fts.options != nil {
+		// 停用词在索引阶段通过 stop_tokens 过滤器移除，修改后需 Reindex 才能作用于已索引文档
+		var stopFilters []string
+		if len(fts.options.StopWords) > 0 {
+			tokens := make([]interface{}, len(fts.options.StopWords))
+			for i, w := range fts.options.StopWords {
+				if !fts.options.CaseSensitive {
+					w = strings.ToLower(w)
+				}
+				tokens[i] = w
+			}
+			if err := mapping.AddCustomTokenMap(stopWordsTokenMap, map[string]interface{}{
+				"type":   tokenmap.Name,
+				"tokens": tokens,
+			}); err != nil {
+				return fmt.Errorf("failed to create stop words token map: %w", err)
+			}
+			if err := mapping.AddCustomTokenFilter(stopWordsFilter, map[string]interface{}{
+				"type":           stop.Name,
+				"stop_token_map": stopWordsTokenMap,
+			}); err != nil {
+				return fmt.Errorf("failed to create stop words filter: %w", err)
+			}
+			stopFilters = []string{stopWordsFilter}
+		}
+
 		// 中文分词：使用 sego + lowercase
 		if strings.EqualFold(fts.options.Tokenize, "sego") {
 			registerSego()
-			if !fts.options.CaseSensitive {
+			if !fts.options.CaseSensitive && len(stopFilters) == 0 {
 				// 如果需要不区分大小写，我们需要创建一个组合了 sego tokenizer 和 lowercase filter 的分析器
 				// 已经注册的 segoAnalyzerName 已经包含了 lowercase filter，所以直接使用它即可
 				textFieldMapping.Analyzer = segoAnalyzerName
+			} else if !fts.options.CaseSensitive {
+				const segoStopAnalyzerName = "rxdb_sego_stop"
+				err := mapping.AddCustomAnalyzer(segoStopAnalyzerName, map[string]interface{}{
+					"type":          custom.Name,
+					"tokenizer":     segoTokenizerName,
+					"token_filters": append([]string{lowercase.Name}, stopFilters...),
+				})
+				if err != nil {
+					return fmt.Errorf("failed to create sego analyzer: %w", err)
+				}
+				textFieldMapping.Analyzer = segoStopAnalyzerName
 			} else {
 				// 如果需要区分大小写，我们需要一个新的没有 lowercase filter 的分析器
 				const segoCaseSensitiveAnalyzerName = "rxdb_sego_case_sensitive"
@@ -245,6 +437,7 @@ func (fts *FulltextSearch) openOrCreateIndex() error {
 					"type":      custom.Name,
 					"tokenizer": segoTokenizerName,
 					// 不包含 lowercase filter
+					"token_filters": stopFilters,
 				})
 				if err == nil {
 					textFieldMapping.Analyzer = segoCaseSensitiveAnalyzerName
@@ -253,24 +446,89 @@ func (fts *FulltextSearch) openOrCreateIndex() error {
 					textFieldMapping.Analyzer = segoAnalyzerName
 				}
 			}
+		} else if strings.EqualFold(fts.options.Tokenize, "ngram") {
+			// 字符级 n-gram：整个字段作为一个词元，再切分为重叠的 n-gram，
+			// 查询字符串经过同一分析器切分后按 n-gram 命中数评分
+			size := fts.options.NGramSize
+			if size <= 0 {
+				size = defaultNGramSize
+			}
+			filterName := fmt.Sprintf("rxdb_ngram_%d", size)
+			err := mapping.AddCustomTokenFilter(filterName, map[string]interface{}{
+				"type": ngram.Name,
+				"min":  float64(size),
+				"max":  float64(size),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create ngram token filter: %w", err)
+			}
+			tokenFilters := []string{filterName}
+			if !fts.options.CaseSensitive {
+				tokenFilters = []string{lowercase.Name, filterName}
+			}
+			analyzerName := filterName + "_analyzer"
+			err = mapping.AddCustomAnalyzer(analyzerName, map[string]interface{}{
+				"type":          custom.Name,
+				"tokenizer":     single.Name,
+				"token_filters": tokenFilters,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create ngram analyzer: %w", err)
+			}
+			textFieldMapping.Analyzer = analyzerName
+		} else if stemmer, ok := snowballStemmers[strings.ToLower(fts.options.StemmerLanguage)]; ok {
+			// 词干提取：unicode 分词 + (可选)小写转换 + (可选)停用词 + Snowball 词干过滤器
+			var tokenFilters []string
+			if !fts.options.CaseSensitive {
+				tokenFilters = append(tokenFilters, lowercase.Name)
+			}
+			tokenFilters = append(append(tokenFilters, stopFilters...), stemmer)
+			analyzerName := "rxdb_stem_" + strings.ToLower(fts.options.StemmerLanguage)
+			err := mapping.AddCustomAnalyzer(analyzerName, map[string]interface{}{
+				"type":          custom.Name,
+				"tokenizer":     unicode.Name,
+				"token_filters": tokenFilters,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create stemmer analyzer: %w", err)
+			}
+			textFieldMapping.Analyzer = analyzerName
 		} else if !fts.options.CaseSensitive {
 			// 使用自定义分析器，包含小写转换
 			err := mapping.AddCustomAnalyzer("rxdb_lowercase", map[string]interface{}{
-				"type":      custom.Name,
-				"tokenizer": unicode.Name,
-				"token_filters": []string{
-					lowercase.Name,
-				},
+				"type":          custom.Name,
+				"tokenizer":     unicode.Name,
+				"token_filters": append([]string{lowercase.Name}, stopFilters...),
 			})
 			if err == nil {
 				textFieldMapping.Analyzer = "rxdb_lowercase"
 			}
+		} else if len(stopFilters) > 0 {
+			// 等价于默认的 standard 分析器，额外过滤自定义停用词
+			err := mapping.AddCustomAnalyzer("rxdb_standard_stop", map[string]interface{}{
+				"type":          custom.Name,
+				"tokenizer":     unicode.Name,
+				"token_filters": append([]string{lowercase.Name, en.StopName}, stopFilters...),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create stop words analyzer: %w", err)
+			}
+			textFieldMapping.Analyzer = "rxdb_standard_stop"
 		}
 
-		// 设置最小长度和停用词（通过自定义分析器）
-		if fts.options.MinLength > 0 || len(fts.options.StopWords) > 0 {
-			// 注意：bleve 的停用词和最小长度过滤需要自定义实现
-			// 这里我们使用默认分析器，在搜索时进行过滤
+		// 最小长度在搜索时过滤（见 queryTerms）
+
+		// index 模式的同义词在上述分析器的输出上处理
+		if fts.synonymMode() == SynonymModeIndex {
+			base := textFieldMapping.Analyzer
+			if base == "" {
+				base = mapping.DefaultAnalyzer
+			}
+			name, err := fts.addSynonymAnalyzers(mapping, base)
+			if err != nil {
+				return err
+			}
+			textFieldMapping.Analyzer = name
 		}
 	}
 
@@ -286,66 +544,207 @@ func (fts *FulltextSearch) openOrCreateIndex() error {
 	}
 
 	fts.index = index
+	fts.bm25 = bm25Meta{}
+	return nil
+}
+
+// recreateIndex 删除索引目录并创建空索引。调用方需持有 fts.mu。
+func (fts *FulltextSearch) recreateIndex() error {
+	if fts.index != nil {
+		_ = fts.index.Close()
+	}
+	if err := os.RemoveAll(fts.indexPath); err != nil {
+		return fmt.Errorf("failed to remove index directory: %w", err)
+	}
+	if err := fts.openOrCreateIndex(); err != nil {
+		return fmt.Errorf("failed to recreate index: %w", err)
+	}
 	return nil
 }
 
 // buildIndex 构建全文索引。
+// 按 batchSize 分批扫描集合，每批在独立的读事务中读取并提交一次 bleve 批处理，
+// 使内存占用与批大小而非集合大小成正比。通过 AddDocument 添加的外部文档随后一并重建。
+// 启用 BM25 时先清空索引，再在同一遍扫描中重新计算全部统计信息。
 func (fts *FulltextSearch) buildIndex(ctx context.Context) error {
 	fts.mu.Lock()
 	defer fts.mu.Unlock()
 
-	// 获取所有文档
-	docs, err := fts.collection.All(ctx)
+	builder := fts.newBM25Builder()
+	if builder != nil {
+		if err := fts.recreateIndex(); err != nil {
+			return err
+		}
+	}
+
+	err := fts.collection.iterateBatches(ctx, fts.batchSize, func(docs []Document) error {
+		batch := fts.index.NewBatch()
+		for _, doc := range docs {
+			// 将文档转换为可搜索字符串
+			text := fts.docToString(doc.Data())
+			if text == "" {
+				continue
+			}
+
+			// 添加到批处理
+			if err := batch.Index(doc.ID(), contentDocument(doc.Data(), text)); err != nil {
+				return fmt.Errorf("failed to index document %s: %w", doc.ID(), err)
+			}
+			if builder != nil {
+				if err := builder.add(batch, doc.ID(), fts.analyzeText(text)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if batch.Size() == 0 {
+			return nil
+		}
+		if err := fts.index.Batch(batch); err != nil {
+			return fmt.Errorf("failed to batch index: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
+	if err := fts.buildExternalIndex(ctx, builder); err != nil {
+		return err
+	}
+	return fts.flushBM25(builder)
+}
 
-	// 批量索引文档
+// externalBucket 返回保存外部文档原文的存储桶名称。
+func (fts *FulltextSearch) externalBucket() string {
+	return fmt.Sprintf("_fulltext_external_%s_%s", fts.collection.name, fts.identifier)
+}
+
+// buildExternalIndex 将持久化的外部文档重新写入索引，builder 非 nil 时同时累计 BM25 统计。
+// 调用方需持有 fts.mu。
+func (fts *FulltextSearch) buildExternalIndex(ctx context.Context, builder *bm25Builder) error {
 	batch := fts.index.NewBatch()
-	count := 0
-	for _, doc := range docs {
-		// 将文档转换为可搜索字符串
-		text := fts.docToString(doc.Data())
-		if text == "" {
-			continue
+	err := fts.collection.store.Iterate(ctx, fts.externalBucket(), func(key, value []byte) error {
+		id := externalDocIDPrefix + string(key)
+		if err := batch.Index(id, map[string]interface{}{"_content": string(value)}); err != nil {
+			return err
 		}
+		return builder.add(batch, id, fts.analyzeText(string(value)))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load external documents: %w", err)
+	}
+	if batch.Size() == 0 {
+		return nil
+	}
+	if err := fts.index.Batch(batch); err != nil {
+		return fmt.Errorf("failed to batch index external documents: %w", err)
+	}
+	return nil
+}
 
-		// 创建 bleve 文档
-		bleveDoc := make(map[string]interface{})
-		for k, v := range doc.Data() {
-			bleveDoc[k] = v
-		}
-		bleveDoc["_content"] = text
+// AddDocument 将不属于集合的外部文本以 docID 加入索引（已存在时覆盖）。
+// 外部文档会持久化到数据库中，Reindex 时会一并重建；
+// 在 FindWithScores 结果中以 ExternalID 返回，Find 不返回外部文档。
+func (fts *FulltextSearch) AddDocument(ctx context.Context, docID string, text string) error {
+	if docID == "" {
+		return fmt.Errorf("document id is required")
+	}
+	if err := fts.ensureInitialized(ctx); err != nil {
+		return err
+	}
 
-		// 添加到批处理
-		if err := batch.Index(doc.ID(), bleveDoc); err != nil {
-			return fmt.Errorf("failed to index document %s: %w", doc.ID(), err)
-		}
+	fts.mu.Lock()
+	defer fts.mu.Unlock()
 
-		count++
-		if count >= fts.batchSize {
-			// 提交批处理
-			if err := fts.index.Batch(batch); err != nil {
-				return fmt.Errorf("failed to batch index: %w", err)
-			}
-			batch = fts.index.NewBatch()
-			count = 0
-		}
+	if err := fts.collection.store.Set(ctx, fts.externalBucket(), docID, []byte(text)); err != nil {
+		return fmt.Errorf("failed to store external document %s: %w", docID, err)
+	}
+	if err := fts.indexText(externalDocIDPrefix+docID, map[string]interface{}{"_content": text}, text); err != nil {
+		return fmt.Errorf("failed to index external document %s: %w", docID, err)
 	}
+	return nil
+}
 
-	// 提交剩余的文档
-	if count > 0 {
-		if err := fts.index.Batch(batch); err != nil {
-			return fmt.Errorf("failed to batch index: %w", err)
+// RemoveDocument 从索引中移除通过 AddDocument 添加的外部文档。
+func (fts *FulltextSearch) RemoveDocument(ctx context.Context, docID string) error {
+	if docID == "" {
+		return fmt.Errorf("document id is required")
+	}
+	if err := fts.ensureInitialized(ctx); err != nil {
+		return err
+	}
+
+	fts.mu.Lock()
+	defer fts.mu.Unlock()
+
+	if err := fts.collection.store.Delete(ctx, fts.externalBucket(), docID); err != nil {
+		return fmt.Errorf("failed to delete external document %s: %w", docID, err)
+	}
+	if err := fts.deleteText(externalDocIDPrefix + docID); err != nil {
+		return fmt.Errorf("failed to remove external document %s: %w", docID, err)
+	}
+	return nil
+}
+
+// IndexDocument 将集合文档写入索引（已存在时覆盖），只更新该文档的倒排表项，不重建索引。
+// 主要用于 AutoSync 为 false 时手动同步；DocToString 返回空字符串时从索引中移除该文档。
+// bleve 在查询时按词典中的文档频率计算 IDF，BM25 统计也只按该文档的词元增量调整，
+// 因此单次调用的开销与索引大小无关。
+func (fts *FulltextSearch) IndexDocument(ctx context.Context, doc Document) error {
+	if doc == nil || doc.ID() == "" {
+		return fmt.Errorf("document id is required")
+	}
+	if err := fts.ensureInitialized(ctx); err != nil {
+		return err
+	}
+
+	fts.mu.Lock()
+	defer fts.mu.Unlock()
+
+	data := doc.Data()
+	text := fts.docToString(data)
+	if text == "" {
+		if err := fts.deleteText(doc.ID()); err != nil {
+			return fmt.Errorf("failed to remove document %s: %w", doc.ID(), err)
 		}
+		return nil
+	}
+	if err := fts.indexText(doc.ID(), contentDocument(data, text), text); err != nil {
+		return fmt.Errorf("failed to index document %s: %w", doc.ID(), err)
 	}
+	return nil
+}
 
+// UnindexDocument 从索引中移除集合文档 id（不影响集合中的文档），文档不在索引中时不做任何操作。
+func (fts *FulltextSearch) UnindexDocument(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("document id is required")
+	}
+	if err := fts.ensureInitialized(ctx); err != nil {
+		return err
+	}
+
+	fts.mu.Lock()
+	defer fts.mu.Unlock()
+
+	if err := fts.deleteText(id); err != nil {
+		return fmt.Errorf("failed to remove document %s: %w", id, err)
+	}
 	return nil
 }
 
+// contentDocument 构造写入 bleve 的文档：文档字段（供 Selector 过滤）加上 _content 字段中的索引文本。
+func contentDocument(data map[string]any, text string) map[string]interface{} {
+	bleveDoc := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		bleveDoc[k] = v
+	}
+	bleveDoc["_content"] = text
+	return bleveDoc
+}
+
 // watchChanges 监听集合变更并更新索引。
-func (fts *FulltextSearch) watchChanges() {
-	changes := fts.collection.Changes()
+func (fts *FulltextSearch) watchChanges(changes <-chan ChangeEvent) {
 	for {
 		select {
 		case <-fts.closeChan:
@@ -369,19 +768,34 @@ func (fts *FulltextSearch) handleChange(event ChangeEvent) {
 		if event.Doc != nil {
 			text := fts.docToString(event.Doc)
 			if text != "" {
-				bleveDoc := make(map[string]interface{})
-				for k, v := range event.Doc {
-					bleveDoc[k] = v
-				}
-				bleveDoc["_content"] = text
-				_ = fts.index.Index(event.ID, bleveDoc)
+				_ = fts.indexText(event.ID, contentDocument(event.Doc, text), text)
 			}
 		}
 	case OperationDelete:
-		_ = fts.index.Delete(event.ID)
+		_ = fts.deleteText(event.ID)
+	case OperationTruncate:
+		_ = fts.resetIndex(context.Background())
 	}
 }
 
+// resetIndex 将索引重置为空（保留配置），随后重新写入外部文档。调用方需持有 fts.mu。
+func (fts *FulltextSearch) resetIndex(ctx context.Context) error {
+	// 实例已关闭时索引也已关闭，不再重建
+	select {
+	case <-fts.closeChan:
+		return nil
+	default:
+	}
+	if err := fts.recreateIndex(); err != nil {
+		return err
+	}
+	builder := fts.newBM25Builder()
+	if err := fts.buildExternalIndex(ctx, builder); err != nil {
+		return err
+	}
+	return fts.flushBM25(builder)
+}
+
 // ensureInitialized 确保索引已初始化（用于懒加载模式）。
 func (fts *FulltextSearch) ensureInitialized(ctx context.Context) error {
 	if fts.initialized {
@@ -410,9 +824,12 @@ func (fts *FulltextSearch) Find(ctx context.Context, queryStr string, options ..
 		return nil, err
 	}
 
-	docs := make([]Document, len(results))
-	for i, r := range results {
-		docs[i] = r.Document
+	docs := make([]Document, 0, len(results))
+	for _, r := range results {
+		// 外部文档没有对应的集合文档，仅在 FindWithScores 中返回
+		if r.Document != nil {
+			docs = append(docs, r.Document)
+		}
 	}
 	return docs, nil
 }
@@ -438,6 +855,165 @@ func (fts *FulltextSearch) FindWithScores(ctx context.Context, queryStr string,
 		return []FulltextSearchResult{}, nil
 	}
 
+	queryTerms := fts.queryTerms(queryStr)
+	if len(queryTerms) == 0 {
+		return []FulltextSearchResult{}, nil
+	}
+
+	// 创建 bleve 查询
+	// 使用 MatchQuery，它会自动使用字段的分析器来分析查询字符串
+	// 但我们需要确保查询字符串已经被正确分词，所以使用分词后的词重新组合
+	// 这样 MatchQuery 会对每个词进行分析，然后匹配索引中的词
+	// 如果索引中的词是"生态系统"，而查询词是"系统"，它们不会匹配（因为"生态系统"是一个完整的词）
+	queryString := strings.Join(queryTerms, " ")
+	groups := fts.queryGroups(queryString)
+	bleveQuery := fts.textQuery(queryString, groups)
+
+	// 如果有选择器，合并查询
+	if len(opts.Selector) > 0 {
+		filterQuery := selectorToBleveQuery(opts.Selector)
+		bleveQuery = bleve.NewConjunctionQuery(bleveQuery, filterQuery)
+	}
+
+	// 创建搜索请求
+	searchRequest := bleve.NewSearchRequest(bleveQuery)
+	if opts.Limit <= 0 {
+		opts.Limit = 10 // 默认限制
+	}
+	size, err := fts.rerankSearchSize(opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+	searchRequest.Size = size
+	if opts.Debug && fts.ranking == FulltextRankingTF && !fts.expandsQuery() {
+		searchRequest.Explain = true
+	}
+
+	// 执行搜索
+	searchResult, err := fts.index.Search(searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("bleve search failed: %w", err)
+	}
+
+	debugInfos, err := fts.rankHits(ctx, searchResult, groups, opts.Limit, opts.Debug)
+	if err != nil {
+		return nil, err
+	}
+	results := fts.hitsToResults(ctx, searchResult, opts, debugInfos)
+	fts.highlightResults(results, flattenGroups(groups), opts)
+	return results, nil
+}
+
+// scoreAll 返回匹配查询字符串的全部集合文档 ID 及其归一化分数（0-1），供 $text 查询使用。
+// 通过 AddDocument 索引的外部文档不包含在结果中。
+func (fts *FulltextSearch) scoreAll(ctx context.Context, queryStr string) (map[string]float64, error) {
+	if err := fts.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	fts.mu.RLock()
+	defer fts.mu.RUnlock()
+
+	scores := make(map[string]float64)
+	queryTerms := fts.queryTerms(queryStr)
+	if len(queryTerms) == 0 {
+		return scores, nil
+	}
+
+	docCount, err := fts.index.DocCount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count indexed documents: %w", err)
+	}
+	if docCount == 0 {
+		return scores, nil
+	}
+
+	queryString := strings.Join(queryTerms, " ")
+	groups := fts.queryGroups(queryString)
+	searchRequest := bleve.NewSearchRequest(fts.textQuery(queryString, groups))
+	searchRequest.Size = int(docCount)
+
+	searchResult, err := fts.index.Search(searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("bleve search failed: %w", err)
+	}
+	if _, err := fts.rankHits(ctx, searchResult, groups, 0, false); err != nil {
+		return nil, err
+	}
+	for _, hit := range searchResult.Hits {
+		if strings.HasPrefix(hit.ID, externalDocIDPrefix) {
+			continue
+		}
+		score := hit.Score
+		if searchResult.MaxScore > 0 {
+			score = hit.Score / searchResult.MaxScore
+		}
+		scores[hit.ID] = score
+	}
+	return scores, nil
+}
+
+// FindNear 查找与指定文档文本最相似的文档（"more like this"）。
+// 使用 DocToString 提取该文档的索引文本，分词后作为查询词检索索引，结果中不包含该文档本身。
+// limit <= 0 时默认返回 10 条。
+func (fts *FulltextSearch) FindNear(ctx context.Context, docID string, limit int) ([]FulltextSearchResult, error) {
+	if err := fts.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	doc, err := fts.collection.FindByID(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, NewError(ErrorTypeNotFound, fmt.Sprintf("document with id %s not found", docID), nil)
+	}
+
+	fts.mu.RLock()
+	defer fts.mu.RUnlock()
+
+	// 去重后的查询词，避免长文档生成过多重复子查询
+	seen := make(map[string]struct{})
+	var terms []string
+	for _, term := range fts.queryTerms(fts.docToString(doc.Data())) {
+		if _, ok := seen[term]; ok {
+			continue
+		}
+		seen[term] = struct{}{}
+		terms = append(terms, term)
+	}
+	if len(terms) == 0 {
+		return []FulltextSearchResult{}, nil
+	}
+
+	queryString := strings.Join(terms, " ")
+	groups := fts.queryGroups(queryString)
+	bq := bleve.NewBooleanQuery()
+	bq.AddMust(fts.textQuery(queryString, groups))
+	bq.AddMustNot(bleve.NewDocIDQuery([]string{docID}))
+
+	opts := FulltextSearchOptions{Limit: limit}
+	if opts.Limit <= 0 {
+		opts.Limit = 10
+	}
+	searchRequest := bleve.NewSearchRequest(bq)
+	if searchRequest.Size, err = fts.rerankSearchSize(opts.Limit); err != nil {
+		return nil, err
+	}
+
+	searchResult, err := fts.index.Search(searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("bleve search failed: %w", err)
+	}
+	if _, err := fts.rankHits(ctx, searchResult, groups, opts.Limit, false); err != nil {
+		return nil, err
+	}
+
+	return fts.hitsToResults(ctx, searchResult, opts, nil), nil
+}
+
+// queryTerms 按索引的分词配置将查询字符串切分为查询词，并过滤过短的词和停用词。
+func (fts *FulltextSearch) queryTerms(queryStr string) []string {
 	// 如果使用 sego 分词，需要手动分词查询字符串，然后使用 TermQuery 精确匹配
 	// 这样可以确保查询词与索引中的词完全一致，避免模糊匹配
 	var queryTerms []string
@@ -508,41 +1084,12 @@ func (fts *FulltextSearch) FindWithScores(ctx context.Context, queryStr string,
 		}
 	}
 
-	if len(queryTerms) == 0 {
-		return []FulltextSearchResult{}, nil
-	}
-
-	// 创建 bleve 查询
-	// 使用 MatchQuery，它会自动使用字段的分析器来分析查询字符串
-	// 但我们需要确保查询字符串已经被正确分词，所以使用分词后的词重新组合
-	// 这样 MatchQuery 会对每个词进行分析，然后匹配索引中的词
-	// 如果索引中的词是"生态系统"，而查询词是"系统"，它们不会匹配（因为"生态系统"是一个完整的词）
-	queryString := strings.Join(queryTerms, " ")
-	mq := bleve.NewMatchQuery(queryString)
-	mq.SetField("_content")
-	var bleveQuery query.Query = mq
-
-	// 如果有选择器，合并查询
-	if len(opts.Selector) > 0 {
-		filterQuery := selectorToBleveQuery(opts.Selector)
-		bleveQuery = bleve.NewConjunctionQuery(bleveQuery, filterQuery)
-	}
-
-	// 创建搜索请求
-	searchRequest := bleve.NewSearchRequest(bleveQuery)
-	if opts.Limit > 0 {
-		searchRequest.Size = opts.Limit
-	} else {
-		searchRequest.Size = 10 // 默认限制
-	}
-
-	// 执行搜索
-	searchResult, err := fts.index.Search(searchRequest)
-	if err != nil {
-		return nil, fmt.Errorf("bleve search failed: %w", err)
-	}
+	return queryTerms
+}
 
-	// 转换结果
+// hitsToResults 将 bleve 命中结果转换为 FulltextSearchResult，并应用阈值过滤与分数归一化。
+// debugInfos 为 BM25 评分时计算的调试信息，为 nil 时从 bleve 的评分解释中提取。
+func (fts *FulltextSearch) hitsToResults(ctx context.Context, searchResult *bleve.SearchResult, opts FulltextSearchOptions, debugInfos map[string]*FulltextDebugInfo) []FulltextSearchResult {
 	var results []FulltextSearchResult
 	for _, hit := range searchResult.Hits {
 		// 应用阈值过滤
@@ -554,25 +1101,109 @@ func (fts *FulltextSearch) FindWithScores(ctx context.Context, queryStr string,
 			}
 		}
 
-		// 获取文档
-		doc, err := fts.collection.FindByID(ctx, hit.ID)
-		if err != nil {
-			continue
-		}
-
 		// 归一化分数到 0-1 范围（如果 MaxScore > 0）
 		score := hit.Score
 		if searchResult.MaxScore > 0 {
 			score = hit.Score / searchResult.MaxScore
 		}
 
-		results = append(results, FulltextSearchResult{
-			Document: doc,
-			Score:    score,
-		})
+		var result FulltextSearchResult
+		if externalID, ok := strings.CutPrefix(hit.ID, externalDocIDPrefix); ok {
+			result = FulltextSearchResult{ExternalID: externalID, Score: score}
+		} else {
+			// 获取文档
+			doc, err := fts.collection.FindByID(ctx, hit.ID)
+			if err != nil {
+				continue
+			}
+			result = FulltextSearchResult{Document: doc, Score: score}
+		}
+		if opts.Debug {
+			if info, ok := debugInfos[hit.ID]; ok {
+				info.FinalScore = score
+				result.Debug = info
+			} else {
+				result.Debug = buildFulltextDebugInfo(hit.Expl, score)
+			}
+		}
+		results = append(results, result)
 	}
 
-	return results, nil
+	return results
+}
+
+// buildFulltextDebugInfo 从 bleve 的评分解释树中提取调试信息。
+//
+// 按 bleve 词项评分节点的结构取值：每个词的得分节点恰有三个子节点，
+// 依次为 tf、字段长度归一化（BM25 下为 saturation，其子节点才是 fieldNorm）和 idf。
+// 词本身和词频只取自 tf 子节点，其余数值按位置读取，不依赖各节点的说明文字。
+// 原始解释树同时保存在 Explanation 中，供需要完整信息的调用方使用。
+func buildFulltextDebugInfo(expl *search.Explanation, score float64) *FulltextDebugInfo {
+	info := &FulltextDebugInfo{
+		MatchedTerms:          []string{},
+		TermFrequencies:       make(map[string]int),
+		InverseDocFrequencies: make(map[string]float64),
+		FinalScore:            score,
+		Explanation:           expl,
+	}
+
+	var normSum float64
+	var normCount int
+	var walk func(e *search.Explanation)
+	walk = func(e *search.Explanation) {
+		if e == nil {
+			return
+		}
+		if len(e.Children) == 3 && e.Children[0] != nil && e.Children[1] != nil && e.Children[2] != nil {
+			if field, term, freq, ok := parseExplanationTermFreq(e.Children[0].Message); ok {
+				if field == "_content" {
+					if _, seen := info.TermFrequencies[term]; !seen {
+						info.MatchedTerms = append(info.MatchedTerms, term)
+					}
+					info.TermFrequencies[term] = freq
+					info.InverseDocFrequencies[term] = e.Children[2].Value
+					norm := e.Children[1]
+					if len(norm.Children) > 0 && norm.Children[0] != nil {
+						norm = norm.Children[0]
+					}
+					normSum += norm.Value
+					normCount++
+				}
+				return
+			}
+		}
+		for _, child := range e.Children {
+			walk(child)
+		}
+	}
+	walk(expl)
+
+	if normCount > 0 {
+		info.FieldLengthNormalization = normSum / float64(normCount)
+	}
+	sort.Strings(info.MatchedTerms)
+	return info
+}
+
+// parseExplanationTermFreq 解析 bleve tf 节点的说明 "tf(termFreq(field:term)=freq"。
+func parseExplanationTermFreq(message string) (field, term string, freq int, ok bool) {
+	rest, ok := strings.CutPrefix(message, "tf(termFreq(")
+	if !ok {
+		return "", "", 0, false
+	}
+	i := strings.LastIndex(rest, ")=")
+	if i < 0 {
+		return "", "", 0, false
+	}
+	freq, err := strconv.Atoi(rest[i+2:])
+	if err != nil {
+		return "", "", 0, false
+	}
+	field, term, ok = strings.Cut(rest[:i], ":")
+	if !ok {
+		return "", "", 0, false
+	}
+	return field, term, freq, true
 }
 
 // Reindex 重建全文索引。
@@ -611,8 +1242,50 @@ func (fts *FulltextSearch) Reindex(ctx context.Context) error {
 	return nil
 }
 
+// GetStopWords 返回当前的停用词列表。
+func (fts *FulltextSearch) GetStopWords(ctx context.Context) ([]string, error) {
+	select {
+	case <-fts.closeChan:
+		return nil, fmt.Errorf("fulltext search is closed")
+	default:
+	}
+
+	fts.mu.RLock()
+	defer fts.mu.RUnlock()
+	if fts.options == nil {
+		return []string{}, nil
+	}
+	return append([]string{}, fts.options.StopWords...), nil
+}
+
+// UpdateStopWords 替换停用词列表。新列表立即作用于查询；
+// 已索引的文档需调用 Reindex 后才会移除停用词，启用 AutoReindexOnConfigChange 时自动重建。
+func (fts *FulltextSearch) UpdateStopWords(ctx context.Context, stopWords []string) error {
+	select {
+	case <-fts.closeChan:
+		return fmt.Errorf("fulltext search is closed")
+	default:
+	}
+
+	fts.mu.Lock()
+	// 复制选项，避免修改调用方传入的 FulltextIndexOptions
+	var opts FulltextIndexOptions
+	if fts.options != nil {
+		opts = *fts.options
+	}
+	opts.StopWords = append([]string(nil), stopWords...)
+	fts.options = &opts
+	fts.mu.Unlock()
+
+	if fts.autoReindex {
+		return fts.Reindex(ctx)
+	}
+	return nil
+}
+
 // Close 关闭全文搜索实例。
 func (fts *FulltextSearch) Close() {
+	fts.collection.unregisterFulltext(fts)
 	close(fts.closeChan)
 	fts.mu.Lock()
 	defer fts.mu.Unlock()
@@ -647,6 +1320,165 @@ func (fts *FulltextSearch) Load(ctx context.Context) error {
 	return nil
 }
 
+// Export 将整个索引序列化为自包含的 zip 数据（bleve 索引文件及其映射），
+// 可通过 Import 加载到其他实例（例如只读副本）。导出期间仅持有读锁，不阻塞查询。
+func (fts *FulltextSearch) Export(ctx context.Context) ([]byte, error) {
+	if err := fts.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	fts.mu.RLock()
+	defer fts.mu.RUnlock()
+
+	copyable, ok := fts.index.(bleve.IndexCopyable)
+	if !ok {
+		return nil, fmt.Errorf("fulltext index does not support export")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "rxdb-fulltext-export-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// CopyTo 基于索引快照复制，不受并发写入影响
+	if err := copyable.CopyTo(bleve.FileSystemDirectory(tmpDir)); err != nil {
+		return nil, fmt.Errorf("failed to copy index: %w", err)
+	}
+	return zipDirectory(ctx, tmpDir)
+}
+
+// Import 用 Export 导出的数据替换当前索引。
+// 导入的外部文档仅存在于索引中，Reindex 会按当前集合与外部文档重新构建索引。
+func (fts *FulltextSearch) Import(ctx context.Context, data []byte) error {
+	// 先解压到索引目录旁的临时目录并校验，避免损坏当前索引
+	tmpDir, err := os.MkdirTemp(filepath.Dir(fts.indexPath), ".import-")
+	if err != nil {
+		return fmt.Errorf("failed to create import directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := unzipDirectory(ctx, data, tmpDir); err != nil {
+		return err
+	}
+	index, err := bleve.Open(tmpDir)
+	if err != nil {
+		return fmt.Errorf("invalid fulltext index data: %w", err)
+	}
+	if err := index.Close(); err != nil {
+		return fmt.Errorf("failed to close imported index: %w", err)
+	}
+
+	fts.mu.Lock()
+	defer fts.mu.Unlock()
+
+	select {
+	case <-fts.closeChan:
+		return fmt.Errorf("fulltext search is closed")
+	default:
+	}
+
+	if fts.index != nil {
+		_ = fts.index.Close()
+	}
+	if err := os.RemoveAll(fts.indexPath); err != nil {
+		return fmt.Errorf("failed to remove index directory: %w", err)
+	}
+	if err := os.Rename(tmpDir, fts.indexPath); err != nil {
+		return fmt.Errorf("failed to move imported index: %w", err)
+	}
+	index, err = bleve.Open(fts.indexPath)
+	if err != nil {
+		return fmt.Errorf("failed to open imported index: %w", err)
+	}
+	fts.index = index
+	fts.initialized = true
+	return fts.loadBM25Meta()
+}
+
+// zipDirectory 将目录下的所有文件按相对路径写入 zip 数据。
+func zipDirectory(ctx context.Context, dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive index: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to archive index: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// unzipDirectory 将 zip 数据解压到目录，拒绝指向目录之外的路径。
+func unzipDirectory(ctx context.Context, data []byte, dir string) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("invalid index data: %w", err)
+	}
+	for _, file := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := filepath.FromSlash(file.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid path in index data: %s", file.Name)
+		}
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if err := extractZipFile(file, filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("failed to extract %s: %w", file.Name, err)
+		}
+	}
+	return nil
+}
+
+func extractZipFile(file *zip.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	r, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
 // selectorToBleveQuery 将 Mango 选择器转换为 Bleve 查询。
 func selectorToBleveQuery(selector map[string]any) query.Query {
 	if len(selector) == 0 {
@@ -0,0 +1,364 @@
+package rxdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis"
+)
+
+const (
+	// FulltextRankingTF 使用 bleve 默认的 TF-IDF 评分（词频开方 × 逆文档频率 × 1/sqrt(字段长度)）。
+	FulltextRankingTF = "tf"
+	// FulltextRankingBM25 使用 Okapi BM25 评分，词频饱和并按平均文档长度归一化。
+	FulltextRankingBM25 = "bm25"
+
+	defaultBM25K1 = 1.2
+	defaultBM25B  = 0.75
+
+	// BM25 统计信息以 internal 键值的形式与 bleve 索引存放在一起，随索引一起导出、重建和删除
+	bm25MetaKey       = "rxdb_bm25_meta"
+	bm25DocFreqPrefix = "rxdb_bm25_df:"
+	bm25DocPrefix     = "rxdb_bm25_doc:"
+)
+
+// bm25Meta 全部已索引文档的汇总统计。
+type bm25Meta struct {
+	DocCount    int `json:"docCount"`
+	TotalLength int `json:"totalLength"`
+}
+
+// bm25DocStats 单个文档的词元总数与各词的词频。
+type bm25DocStats struct {
+	Length int            `json:"length"`
+	Terms  map[string]int `json:"terms"`
+}
+
+// bm25Builder 在全量构建索引时累计文档频率，构建结束后由 flushBM25 一次写入。
+type bm25Builder struct {
+	meta    bm25Meta
+	docFreq map[string]int
+}
+
+// newBM25Builder 返回全量构建使用的统计累加器，未启用 BM25 时返回 nil。
+func (fts *FulltextSearch) newBM25Builder() *bm25Builder {
+	if fts.ranking != FulltextRankingBM25 {
+		return nil
+	}
+	return &bm25Builder{docFreq: make(map[string]int)}
+}
+
+// add 将文档统计写入批处理并累计到汇总中。
+func (b *bm25Builder) add(batch *bleve.Batch, id string, stats bm25DocStats) error {
+	if b == nil {
+		return nil
+	}
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed to encode bm25 stats for %s: %w", id, err)
+	}
+	batch.SetInternal([]byte(bm25DocPrefix+id), data)
+	b.meta.DocCount++
+	b.meta.TotalLength += stats.Length
+	for term := range stats.Terms {
+		b.docFreq[term]++
+	}
+	return nil
+}
+
+// flushBM25 写入全量构建得到的文档频率与汇总统计。调用方需持有 fts.mu。
+func (fts *FulltextSearch) flushBM25(b *bm25Builder) error {
+	if b == nil {
+		return nil
+	}
+	meta, err := json.Marshal(b.meta)
+	if err != nil {
+		return fmt.Errorf("failed to encode bm25 stats: %w", err)
+	}
+	batch := fts.index.NewBatch()
+	for term, n := range b.docFreq {
+		batch.SetInternal([]byte(bm25DocFreqPrefix+term), strconv.AppendInt(nil, int64(n), 10))
+	}
+	batch.SetInternal([]byte(bm25MetaKey), meta)
+	if err := fts.index.Batch(batch); err != nil {
+		return fmt.Errorf("failed to store bm25 stats: %w", err)
+	}
+	fts.bm25 = b.meta
+	return nil
+}
+
+// loadBM25Meta 从索引中读取汇总统计（打开已有索引或导入后调用）。调用方需持有 fts.mu。
+func (fts *FulltextSearch) loadBM25Meta() error {
+	fts.bm25 = bm25Meta{}
+	if fts.ranking != FulltextRankingBM25 {
+		return nil
+	}
+	data, err := fts.index.GetInternal([]byte(bm25MetaKey))
+	if err != nil {
+		return fmt.Errorf("failed to load bm25 stats: %w", err)
+	}
+	if data == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &fts.bm25); err != nil {
+		return fmt.Errorf("failed to decode bm25 stats: %w", err)
+	}
+	return nil
+}
+
+// analyzeText 使用 _content 字段的分析器分词，与索引中的词元保持一致（含停用词、词干、n-gram 与同义词）。
+func (fts *FulltextSearch) analyzeText(text string) bm25DocStats {
+	return analyzeWith(fts.fieldAnalyzer(), text)
+}
+
+// analyzeWith 使用 analyzer 分词并统计词频。
+func analyzeWith(analyzer analysis.Analyzer, text string) bm25DocStats {
+	stats := bm25DocStats{Terms: make(map[string]int)}
+	if analyzer == nil {
+		return stats
+	}
+	for _, token := range analyzer.Analyze([]byte(text)) {
+		stats.Terms[string(token.Term)]++
+		stats.Length++
+	}
+	return stats
+}
+
+// docStatsBM25 读取文档的统计信息，不存在时返回 nil。
+func (fts *FulltextSearch) docStatsBM25(id string) (*bm25DocStats, error) {
+	data, err := fts.index.GetInternal([]byte(bm25DocPrefix + id))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var stats bm25DocStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, fmt.Errorf("failed to decode bm25 stats for %s: %w", id, err)
+	}
+	return &stats, nil
+}
+
+// docFreqBM25 读取包含 term 的文档数量。
+func (fts *FulltextSearch) docFreqBM25(term string) (int, error) {
+	data, err := fts.index.GetInternal([]byte(bm25DocFreqPrefix + term))
+	if err != nil || data == nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(data))
+}
+
+// updateBM25 将文档 id 的统计增量更新写入批处理：先扣除旧文本的统计，再累加新文本（text 为 nil 表示删除）。
+// 返回批处理提交后应生效的汇总统计。调用方需持有 fts.mu。
+func (fts *FulltextSearch) updateBM25(batch *bleve.Batch, id string, text *string) (bm25Meta, error) {
+	meta := fts.bm25
+	old, err := fts.docStatsBM25(id)
+	if err != nil {
+		return meta, err
+	}
+
+	delta := make(map[string]int)
+	if old != nil {
+		meta.DocCount--
+		meta.TotalLength -= old.Length
+		for term := range old.Terms {
+			delta[term]--
+		}
+	}
+	docKey := []byte(bm25DocPrefix + id)
+	if text != nil {
+		stats := fts.analyzeText(*text)
+		data, err := json.Marshal(stats)
+		if err != nil {
+			return meta, fmt.Errorf("failed to encode bm25 stats for %s: %w", id, err)
+		}
+		batch.SetInternal(docKey, data)
+		meta.DocCount++
+		meta.TotalLength += stats.Length
+		for term := range stats.Terms {
+			delta[term]++
+		}
+	} else if old != nil {
+		batch.DeleteInternal(docKey)
+	}
+
+	for term, d := range delta {
+		if d == 0 {
+			continue
+		}
+		df, err := fts.docFreqBM25(term)
+		if err != nil {
+			return meta, fmt.Errorf("failed to read bm25 document frequency: %w", err)
+		}
+		if df += d; df > 0 {
+			batch.SetInternal([]byte(bm25DocFreqPrefix+term), strconv.AppendInt(nil, int64(df), 10))
+		} else {
+			batch.DeleteInternal([]byte(bm25DocFreqPrefix + term))
+		}
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return meta, fmt.Errorf("failed to encode bm25 stats: %w", err)
+	}
+	batch.SetInternal([]byte(bm25MetaKey), data)
+	return meta, nil
+}
+
+// indexText 将文档写入索引，启用 BM25 时在同一批处理中增量更新统计信息。调用方需持有 fts.mu。
+func (fts *FulltextSearch) indexText(id string, bleveDoc map[string]interface{}, text string) error {
+	batch := fts.index.NewBatch()
+	if err := batch.Index(id, bleveDoc); err != nil {
+		return err
+	}
+	return fts.commitWithBM25(batch, id, &text)
+}
+
+// deleteText 从索引中删除文档，启用 BM25 时在同一批处理中扣除其统计信息。调用方需持有 fts.mu。
+func (fts *FulltextSearch) deleteText(id string) error {
+	batch := fts.index.NewBatch()
+	batch.Delete(id)
+	return fts.commitWithBM25(batch, id, nil)
+}
+
+func (fts *FulltextSearch) commitWithBM25(batch *bleve.Batch, id string, text *string) error {
+	if fts.ranking != FulltextRankingBM25 {
+		return fts.index.Batch(batch)
+	}
+	meta, err := fts.updateBM25(batch, id, text)
+	if err != nil {
+		return err
+	}
+	if err := fts.index.Batch(batch); err != nil {
+		return err
+	}
+	fts.bm25 = meta
+	return nil
+}
+
+// rankBM25 按查询词组 groups 计算 BM25 分数，替换命中结果的分数并重新排序，limit > 0 时截断结果。
+// 每组为同一查询词及其同义词（见 queryGroups），组内词频相加、文档频率取最大值，视为同一个词计分。
+// debug 为 true 时返回每个命中文档的评分调试信息。调用方需持有 fts.mu 读锁。
+func (fts *FulltextSearch) rankBM25(searchResult *bleve.SearchResult, groups [][]string, limit int, debug bool) (map[string]*FulltextDebugInfo, error) {
+	if fts.bm25.DocCount == 0 {
+		// 索引中没有统计信息（如导入了未启用 BM25 的索引），保留原始分数
+		truncateHits(searchResult, limit)
+		return nil, nil
+	}
+
+	n := float64(fts.bm25.DocCount)
+	idf := make([]float64, len(groups))
+	for i, group := range groups {
+		df := 0
+		for _, term := range group {
+			termDF, err := fts.docFreqBM25(term)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read bm25 document frequency: %w", err)
+			}
+			df = max(df, termDF)
+		}
+		idf[i] = math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+	}
+	avgLength := float64(fts.bm25.TotalLength) / n
+
+	var infos map[string]*FulltextDebugInfo
+	if debug {
+		infos = make(map[string]*FulltextDebugInfo, len(searchResult.Hits))
+	}
+	searchResult.MaxScore = 0
+	for _, hit := range searchResult.Hits {
+		stats, err := fts.docStatsBM25(hit.ID)
+		if err != nil {
+			return nil, err
+		}
+		if stats == nil {
+			hit.Score = 0
+			continue
+		}
+		norm := 1 - fts.bm25B
+		if avgLength > 0 {
+			norm += fts.bm25B * float64(stats.Length) / avgLength
+		}
+		info := newGroupDebugInfo(debug, norm)
+		score := 0.0
+		for i, group := range groups {
+			tf := float64(groupTermFreq(group, stats.Terms, idf[i], info))
+			if tf > 0 {
+				score += idf[i] * tf * (fts.bm25K1 + 1) / (tf + fts.bm25K1*norm)
+			}
+		}
+		if info != nil {
+			infos[hit.ID] = info
+		}
+		hit.Score = score
+		searchResult.MaxScore = math.Max(searchResult.MaxScore, score)
+	}
+
+	sort.SliceStable(searchResult.Hits, func(i, j int) bool {
+		return searchResult.Hits[i].Score > searchResult.Hits[j].Score
+	})
+	truncateHits(searchResult, limit)
+	return infos, nil
+}
+
+// newGroupDebugInfo debug 为 true 时返回待填充的调试信息，否则返回 nil。
+func newGroupDebugInfo(debug bool, norm float64) *FulltextDebugInfo {
+	if !debug {
+		return nil
+	}
+	return &FulltextDebugInfo{
+		MatchedTerms:             []string{},
+		TermFrequencies:          make(map[string]int),
+		InverseDocFrequencies:    make(map[string]float64),
+		FieldLengthNormalization: norm,
+	}
+}
+
+// groupTermFreq 返回词组在文档中的总词频，info 非 nil 时记录命中的词。
+func groupTermFreq(group []string, terms map[string]int, idf float64, info *FulltextDebugInfo) int {
+	tf := 0
+	for _, term := range group {
+		freq := terms[term]
+		if freq == 0 {
+			continue
+		}
+		tf += freq
+		if info != nil {
+			info.MatchedTerms = append(info.MatchedTerms, term)
+			info.TermFrequencies[term] = freq
+			info.InverseDocFrequencies[term] = idf
+		}
+	}
+	return tf
+}
+
+func truncateHits(searchResult *bleve.SearchResult, limit int) {
+	if limit > 0 && len(searchResult.Hits) > limit {
+		searchResult.Hits = searchResult.Hits[:limit]
+	}
+}
+
+// rerankSearchSize 启用 BM25 或查询时同义词扩展时需要取回全部命中文档后重新排序，返回索引中的文档总数。
+func (fts *FulltextSearch) rerankSearchSize(size int) (int, error) {
+	if fts.ranking != FulltextRankingBM25 && !fts.expandsQuery() {
+		return size, nil
+	}
+	docCount, err := fts.index.DocCount()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count indexed documents: %w", err)
+	}
+	return int(docCount), nil
+}
+
+// parseFulltextRanking 校验并规范化排序方式配置。
+func parseFulltextRanking(ranking string) (string, error) {
+	switch strings.ToLower(ranking) {
+	case "", FulltextRankingTF:
+		return FulltextRankingTF, nil
+	case FulltextRankingBM25:
+		return FulltextRankingBM25, nil
+	}
+	return "", fmt.Errorf("unsupported fulltext ranking: %s", ranking)
+}
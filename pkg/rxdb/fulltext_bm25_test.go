@@ -0,0 +1,154 @@
+package rxdb
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFulltextSearch_BM25(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "bm25_docs", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	// long 中 database 出现 3 次（原始词频最高）但篇幅很长；stuffed 堆砌关键词
+	docs := []map[string]any{
+		{"id": "short", "text": "embedded database engine"},
+		{"id": "long", "text": "database " + strings.Repeat("replication sync offline mobile ", 8) + "database storage database"},
+		{"id": "stuffed", "text": strings.Repeat("database ", 8) + "sales pitch"},
+		{"id": "f1", "text": "offline first mobile apps"},
+		{"id": "f2", "text": "reactive queries and streams"},
+		{"id": "f3", "text": "vector search with hnsw"},
+		{"id": "f4", "text": "graph traversal algorithms"},
+		{"id": "f5", "text": "schema validation hooks"},
+	}
+	if _, err := coll.BulkInsert(ctx, docs); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	config := func(id, ranking string) FulltextSearchConfig {
+		return FulltextSearchConfig{
+			Identifier:  id,
+			Ranking:     ranking,
+			DocToString: func(doc map[string]any) string { return doc["text"].(string) },
+		}
+	}
+	tf, err := AddFulltextSearch(coll, config("tf", ""))
+	if err != nil {
+		t.Fatalf("Failed to create fulltext search: %v", err)
+	}
+	defer tf.Close()
+	bm25, err := AddFulltextSearch(coll, config("bm25", FulltextRankingBM25))
+	if err != nil {
+		t.Fatalf("Failed to create fulltext search: %v", err)
+	}
+	defer bm25.Close()
+
+	scores := func(fts *FulltextSearch, query string) (map[string]float64, []string) {
+		t.Helper()
+		results, err := fts.FindWithScores(ctx, query, FulltextSearchOptions{Limit: 100, Debug: true})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		out := make(map[string]float64, len(results))
+		var order []string
+		for _, r := range results {
+			id := r.ExternalID
+			if r.Document != nil {
+				id = r.Document.ID()
+			}
+			out[id] = r.Score
+			order = append(order, id)
+		}
+		return out, order
+	}
+
+	tfScores, _ := scores(tf, "database")
+	bm25Scores, bm25Order := scores(bm25, "database")
+	if strings.Join(bm25Order, ",") != "stuffed,short,long" {
+		t.Errorf("Unexpected bm25 ranking: %v", bm25Order)
+	}
+	// 长度归一化：原始词频更高的长文档排在短文档之后
+	if bm25Scores["long"] >= bm25Scores["short"] {
+		t.Errorf("Expected short document to outrank long one: %v", bm25Scores)
+	}
+	// 词频饱和：关键词堆砌带来的优势在 BM25 下小于 TF
+	tfRatio := tfScores["stuffed"] / tfScores["short"]
+	bm25Ratio := bm25Scores["stuffed"] / bm25Scores["short"]
+	if bm25Ratio >= tfRatio {
+		t.Errorf("Expected bm25 to dampen term stuffing: tf ratio %.3f, bm25 ratio %.3f", tfRatio, bm25Ratio)
+	}
+
+	// 调试信息与公式一致
+	results, err := bm25.FindWithScores(ctx, "database", FulltextSearchOptions{Limit: 1, Debug: true})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Search failed: %v, %d results", err, len(results))
+	}
+	debug := results[0].Debug
+	if debug == nil || debug.TermFrequencies["database"] != 8 {
+		t.Fatalf("Unexpected debug info: %+v", debug)
+	}
+	// 8 个文档共 65 个词元（标准分析器移除 and、with 等停用词），stuffed 有 10 个
+	wantIDF := math.Log(1 + (8-3+0.5)/(3+0.5))
+	wantNorm := 1 - defaultBM25B + defaultBM25B*10/(65.0/8)
+	if math.Abs(debug.InverseDocFrequencies["database"]-wantIDF) > 1e-9 || math.Abs(debug.FieldLengthNormalization-wantNorm) > 1e-9 {
+		t.Errorf("Expected idf %.4f and norm %.4f, got %+v", wantIDF, wantNorm, debug)
+	}
+
+	// 增量更新后的统计应与全量重建一致
+	if err := bm25.AddDocument(ctx, "ext", "database notes"); err != nil {
+		t.Fatalf("Failed to add document: %v", err)
+	}
+	if _, err := coll.Insert(ctx, map[string]any{"id": "new", "text": "a tiny database"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := coll.Remove(ctx, "long"); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		// 已删除的集合文档在结果中会被过滤，还需等待索引本身处理完删除（8 个集合文档与 1 个外部文档）
+		got, _ := scores(bm25, "database")
+		_, hasNew := got["new"]
+		_, hasLong := got["long"]
+		bm25.mu.RLock()
+		indexed, _ := bm25.index.DocCount()
+		bm25.mu.RUnlock()
+		if hasNew && !hasLong && indexed == 9 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Index did not catch up with changes: %v", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	incremental, _ := scores(bm25, "database")
+	incrementalMeta := bm25.bm25
+
+	if err := bm25.Reindex(ctx); err != nil {
+		t.Fatalf("Failed to reindex: %v", err)
+	}
+	rebuilt, _ := scores(bm25, "database")
+	if bm25.bm25 != incrementalMeta {
+		t.Errorf("Expected stats %+v after reindex, got %+v", incrementalMeta, bm25.bm25)
+	}
+	if len(rebuilt) != len(incremental) {
+		t.Fatalf("Expected %v, got %v", incremental, rebuilt)
+	}
+	for id, score := range incremental {
+		if math.Abs(rebuilt[id]-score) > 1e-9 {
+			t.Errorf("%s: incremental score %.6f, rebuilt %.6f", id, score, rebuilt[id])
+		}
+	}
+
+	if _, err := AddFulltextSearch(coll, config("bad", "bm42")); err == nil {
+		t.Error("Expected error for unsupported ranking")
+	}
+	bad := config("bad", FulltextRankingBM25)
+	bad.IndexOptions = &FulltextIndexOptions{BM25B: 1.5}
+	if _, err := AddFulltextSearch(coll, bad); err == nil {
+		t.Error("Expected error for invalid b")
+	}
+}
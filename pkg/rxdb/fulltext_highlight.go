@@ -0,0 +1,187 @@
+package rxdb
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultHighlightMaxLength 高亮片段的默认最大字符数。
+const defaultHighlightMaxLength = 200
+
+// textSpan 文本中的字节区间 [start, end)，term 为命中的词元。
+type textSpan struct {
+	start, end int
+	term       string
+}
+
+// highlightResults 为结果中的集合文档生成 opts.HighlightFields 的高亮片段。调用方需持有 fts.mu 读锁。
+func (fts *FulltextSearch) highlightResults(results []FulltextSearchResult, terms []string, opts FulltextSearchOptions) {
+	if len(opts.HighlightFields) == 0 || len(terms) == 0 {
+		return
+	}
+	maxLength := opts.HighlightMaxLength
+	if maxLength <= 0 {
+		maxLength = defaultHighlightMaxLength
+	}
+	termSet := make(map[string]struct{}, len(terms))
+	for _, term := range terms {
+		termSet[term] = struct{}{}
+	}
+
+	for i := range results {
+		if results[i].Document == nil {
+			continue
+		}
+		data := results[i].Document.Data()
+		for _, field := range opts.HighlightFields {
+			text, ok := getNestedValue(data, field).(string)
+			if !ok {
+				continue
+			}
+			if snippet, ok := fts.highlight(text, termSet, maxLength); ok {
+				if results[i].Highlights == nil {
+					results[i].Highlights = make(map[string]string)
+				}
+				results[i].Highlights[field] = snippet
+			}
+		}
+	}
+}
+
+// highlight 从 text 中选取命中词最密集的片段（不超过 maxLength 个字符），并用 <mark> 标记命中的词。
+// 优先按句子边界选取连续的若干句；单句过长时退化为按词边界截取的字符窗口。
+// text 中没有命中词时返回 false。
+func (fts *FulltextSearch) highlight(text string, terms map[string]struct{}, maxLength int) (string, bool) {
+	analyzer := fts.fieldAnalyzer()
+	if analyzer == nil {
+		return "", false
+	}
+	var matches []textSpan
+	for _, token := range analyzer.Analyze([]byte(text)) {
+		if _, ok := terms[string(token.Term)]; ok && token.End <= len(text) {
+			matches = append(matches, textSpan{start: token.Start, end: token.End, term: string(token.Term)})
+		}
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	var best snippetWindow
+	sentences := splitSentences(text)
+	for i := range sentences {
+		for j := i; j < len(sentences); j++ {
+			w := newSnippetWindow(text, sentences[i].start, sentences[j].end, matches)
+			if w.length > maxLength {
+				break
+			}
+			if w.better(best) {
+				best = w
+			}
+		}
+	}
+	if best.distinct == 0 {
+		// 没有能容纳命中词的完整句子，从每个命中词开始截取字符窗口
+		for _, match := range matches {
+			end := truncateAtWord(text, match.start, maxLength)
+			if w := newSnippetWindow(text, match.start, end, matches); w.better(best) {
+				best = w
+			}
+		}
+	}
+	if best.distinct == 0 {
+		return "", false
+	}
+
+	var sb strings.Builder
+	pos := best.start
+	for _, match := range matches {
+		// 跳过窗口外以及与前一个命中重叠（如 n-gram）的词元
+		if match.start < pos || match.end > best.end {
+			continue
+		}
+		sb.WriteString(html.EscapeString(text[pos:match.start]))
+		sb.WriteString("<mark>")
+		sb.WriteString(html.EscapeString(text[match.start:match.end]))
+		sb.WriteString("</mark>")
+		pos = match.end
+	}
+	sb.WriteString(html.EscapeString(text[pos:best.end]))
+	return sb.String(), true
+}
+
+// snippetWindow 候选片段及其命中情况。
+type snippetWindow struct {
+	start, end int
+	length     int // 字符数
+	distinct   int // 命中的不同词数
+	count      int // 命中次数
+}
+
+func newSnippetWindow(text string, start, end int, matches []textSpan) snippetWindow {
+	w := snippetWindow{start: start, end: end, length: utf8.RuneCountInString(text[start:end])}
+	seen := make(map[string]struct{})
+	for _, match := range matches {
+		if match.start >= start && match.end <= end {
+			seen[match.term] = struct{}{}
+			w.count++
+		}
+	}
+	w.distinct = len(seen)
+	return w
+}
+
+// better 依次比较命中的不同词数、命中密度与长度（越短越好）。
+func (w snippetWindow) better(other snippetWindow) bool {
+	if w.distinct != other.distinct {
+		return w.distinct > other.distinct
+	}
+	// 比较 w.count/w.length 与 other.count/other.length
+	if d := w.count*other.length - other.count*w.length; d != 0 {
+		return d > 0
+	}
+	return w.length < other.length
+}
+
+// splitSentences 按句末标点与换行将文本切分为句子，返回去除首尾空白后的字节区间。
+func splitSentences(text string) []textSpan {
+	var sentences []textSpan
+	start := 0
+	add := func(end int) {
+		s := strings.TrimSpace(text[start:end])
+		if s != "" {
+			offset := start + strings.Index(text[start:end], s)
+			sentences = append(sentences, textSpan{start: offset, end: offset + len(s)})
+		}
+		start = end
+	}
+	for i, r := range text {
+		switch r {
+		case '.', '!', '?', '\n', '。', '！', '？', '；':
+			add(i + utf8.RuneLen(r))
+		}
+	}
+	add(len(text))
+	return sentences
+}
+
+// truncateAtWord 返回从 start 起不超过 maxLength 个字符的结束位置，尽量在空白处截断以免切断单词。
+func truncateAtWord(text string, start, maxLength int) int {
+	end, n := start, 0
+	lastSpace := -1
+	for i, r := range text[start:] {
+		if n == maxLength {
+			if lastSpace > start && !unicode.IsSpace(r) {
+				return lastSpace
+			}
+			return end
+		}
+		if unicode.IsSpace(r) {
+			lastSpace = start + i
+		}
+		end = start + i + utf8.RuneLen(r)
+		n++
+	}
+	return end
+}
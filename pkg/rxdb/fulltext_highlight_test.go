@@ -0,0 +1,108 @@
+package rxdb
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFulltextSearch_Highlight(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "highlight_docs", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	body := "RxDB is a local-first database. It runs in browsers & servers. " +
+		"Replication keeps every client in sync. " +
+		"The replication protocol resolves conflicts with revisions, and conflicts are rare. " +
+		"Queries are reactive and indexes make them fast."
+	longSentence := strings.Repeat("filler words without meaning ", 20) + "then replication conflicts appear " + strings.Repeat("and more filler text ", 20)
+	docs := []map[string]any{
+		{"id": "1", "title": "Replication & conflicts", "meta": map[string]any{"summary": "How conflicts are handled"}, "body": body},
+		{"id": "2", "title": "Long", "body": longSentence},
+	}
+	if _, err := coll.BulkInsert(ctx, docs); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier: "highlight",
+		DocToString: func(doc map[string]any) string {
+			return doc["title"].(string) + " " + doc["body"].(string)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+
+	const maxLength = 100
+	results, err := fts.FindWithScores(ctx, "replication conflicts", FulltextSearchOptions{
+		HighlightFields:    []string{"title", "body", "meta.summary", "missing"},
+		HighlightMaxLength: maxLength,
+	})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	markRe := regexp.MustCompile(`<mark>(.*?)</mark>`)
+	plain := func(snippet string) string {
+		return strings.NewReplacer("<mark>", "", "</mark>", "", "&amp;", "&").Replace(snippet)
+	}
+	for _, r := range results {
+		for field, snippet := range r.Highlights {
+			if n := utf8.RuneCountInString(plain(snippet)); n > maxLength {
+				t.Errorf("%s/%s: snippet has %d characters: %q", r.Document.ID(), field, n, snippet)
+			}
+			// 片段包含全部命中的查询词
+			marked := make(map[string]bool)
+			for _, m := range markRe.FindAllStringSubmatch(snippet, -1) {
+				marked[strings.ToLower(m[1])] = true
+			}
+			if field != "meta.summary" && (!marked["replication"] || !marked["conflicts"]) {
+				t.Errorf("%s/%s: expected both terms to be marked: %q", r.Document.ID(), field, snippet)
+			}
+		}
+	}
+
+	byID := make(map[string]FulltextSearchResult)
+	for _, r := range results {
+		byID[r.Document.ID()] = r
+	}
+	doc1 := byID["1"].Highlights
+	if len(doc1) != 3 {
+		t.Fatalf("Expected 3 highlighted fields, got %v", doc1)
+	}
+	if doc1["title"] != "<mark>Replication</mark> &amp; <mark>conflicts</mark>" {
+		t.Errorf("Unexpected title highlight: %q", doc1["title"])
+	}
+	if doc1["meta.summary"] != "How <mark>conflicts</mark> are handled" {
+		t.Errorf("Unexpected nested highlight: %q", doc1["meta.summary"])
+	}
+	// 选取命中最密集的完整句子，而不是从句子中间截断
+	want := "The <mark>replication</mark> protocol resolves <mark>conflicts</mark> with revisions, and <mark>conflicts</mark> are rare."
+	if doc1["body"] != want {
+		t.Errorf("Unexpected body highlight:\n got %q\nwant %q", doc1["body"], want)
+	}
+
+	// 单句超过最大长度时按词边界截取
+	long := byID["2"].Highlights["body"]
+	if !strings.HasPrefix(long, "<mark>replication</mark> <mark>conflicts</mark> appear") || strings.HasSuffix(plain(long), " ") {
+		t.Errorf("Unexpected long sentence highlight: %q", long)
+	}
+
+	// 未设置 HighlightFields 时不生成高亮
+	results, err = fts.FindWithScores(ctx, "replication", FulltextSearchOptions{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	for _, r := range results {
+		if r.Highlights != nil {
+			t.Errorf("Expected no highlights, got %v", r.Highlights)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package rxdb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+const (
+	defaultMLTMinTermFreq   = 1
+	defaultMLTMinDocFreq    = 2
+	defaultMLTMaxQueryTerms = 25
+)
+
+// MoreLikeThis 查找与 docID 对应文档内容相似的文档，用于"相关文章"等场景。
+// 先按索引分析器提取参考文档的词袋，按当前评分方式（TF-IDF 或 BM25）计算每个词的权重，
+// 取权重最高的 opts.MaxQueryTerms 个词（按权重加权）构造查询，结果中不包含参考文档本身。
+// opts.MinTermFreq 与 opts.MinDocFreq 过滤在参考文档中出现过少或在索引中过于罕见的词；
+// Limit、Threshold、Selector 与 Debug 的含义与 FindWithScores 相同，Limit <= 0 时默认返回 10 条。
+func (fts *FulltextSearch) MoreLikeThis(ctx context.Context, docID string, opts FulltextSearchOptions) ([]FulltextSearchResult, error) {
+	if err := fts.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	doc, err := fts.collection.FindByID(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, NewError(ErrorTypeNotFound, fmt.Sprintf("document with id %s not found", docID), nil)
+	}
+
+	if opts.Limit <= 0 {
+		opts.Limit = 10
+	}
+	if opts.MinTermFreq <= 0 {
+		opts.MinTermFreq = defaultMLTMinTermFreq
+	}
+	if opts.MinDocFreq <= 0 {
+		opts.MinDocFreq = defaultMLTMinDocFreq
+	}
+	if opts.MaxQueryTerms <= 0 {
+		opts.MaxQueryTerms = defaultMLTMaxQueryTerms
+	}
+
+	fts.mu.RLock()
+	defer fts.mu.RUnlock()
+
+	terms, err := fts.distinctiveTerms(fts.docToString(doc.Data()), opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 0 {
+		return []FulltextSearchResult{}, nil
+	}
+
+	// 各词按相对权重加权，最显著的词权重为 1
+	disjunction := bleve.NewDisjunctionQuery()
+	groups := make([][]string, len(terms))
+	for i, t := range terms {
+		tq := bleve.NewTermQuery(t.term)
+		tq.SetField("_content")
+		tq.SetBoost(t.weight / terms[0].weight)
+		disjunction.AddQuery(tq)
+		groups[i] = []string{t.term}
+	}
+	bq := bleve.NewBooleanQuery()
+	bq.AddMust(disjunction)
+	bq.AddMustNot(bleve.NewDocIDQuery([]string{docID}))
+	var bleveQuery query.Query = bq
+	if len(opts.Selector) > 0 {
+		bleveQuery = bleve.NewConjunctionQuery(bq, selectorToBleveQuery(opts.Selector))
+	}
+
+	searchRequest := bleve.NewSearchRequest(bleveQuery)
+	if searchRequest.Size, err = fts.rerankSearchSize(opts.Limit); err != nil {
+		return nil, err
+	}
+	if opts.Debug && fts.ranking == FulltextRankingTF {
+		searchRequest.Explain = true
+	}
+
+	searchResult, err := fts.index.Search(searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("bleve search failed: %w", err)
+	}
+	var debugInfos map[string]*FulltextDebugInfo
+	if fts.ranking == FulltextRankingBM25 {
+		if debugInfos, err = fts.rankBM25(searchResult, groups, opts.Limit, opts.Debug); err != nil {
+			return nil, err
+		}
+	} else {
+		truncateHits(searchResult, opts.Limit)
+	}
+	return fts.hitsToResults(ctx, searchResult, opts, debugInfos), nil
+}
+
+type weightedTerm struct {
+	term   string
+	weight float64
+}
+
+// distinctiveTerms 计算文本中各词元的权重，返回满足频率条件、按权重降序排列的前 MaxQueryTerms 个词。
+// 调用方需持有 fts.mu 读锁。
+func (fts *FulltextSearch) distinctiveTerms(text string, opts FulltextSearchOptions) ([]weightedTerm, error) {
+	docCount, err := fts.index.DocCount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count indexed documents: %w", err)
+	}
+	n := float64(docCount)
+	stats := fts.analyzeText(text)
+
+	// BM25 的长度归一化因子只与参考文档相关，对所有词相同
+	norm := 1.0
+	if fts.ranking == FulltextRankingBM25 && fts.bm25.TotalLength > 0 {
+		norm = 1 - fts.bm25B + fts.bm25B*float64(stats.Length)*float64(fts.bm25.DocCount)/float64(fts.bm25.TotalLength)
+	}
+
+	var terms []weightedTerm
+	for term, freq := range stats.Terms {
+		if freq < opts.MinTermFreq {
+			continue
+		}
+		df, err := fts.termDocFreq(term)
+		if err != nil {
+			return nil, err
+		}
+		if df < opts.MinDocFreq {
+			continue
+		}
+		tf := float64(freq)
+		var weight float64
+		if fts.ranking == FulltextRankingBM25 {
+			idf := math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+			weight = idf * tf * (fts.bm25K1 + 1) / (tf + fts.bm25K1*norm)
+		} else {
+			// 与 bleve 的 TF-IDF 一致：sqrt(tf) × (1 + ln(N/(df+1)))
+			weight = math.Sqrt(tf) * (1 + math.Log(n/float64(df+1)))
+		}
+		if weight > 0 {
+			terms = append(terms, weightedTerm{term: term, weight: weight})
+		}
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].weight != terms[j].weight {
+			return terms[i].weight > terms[j].weight
+		}
+		return terms[i].term < terms[j].term
+	})
+	if len(terms) > opts.MaxQueryTerms {
+		terms = terms[:opts.MaxQueryTerms]
+	}
+	return terms, nil
+}
+
+// termDocFreq 从 _content 字段词典中读取包含 term 的文档数量。调用方需持有 fts.mu 读锁。
+func (fts *FulltextSearch) termDocFreq(term string) (int, error) {
+	dict, err := fts.index.FieldDictRange("_content", []byte(term), []byte(term))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open field dictionary: %w", err)
+	}
+	defer dict.Close()
+	entry, err := dict.Next()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read field dictionary: %w", err)
+	}
+	if entry == nil || entry.Term != term {
+		return 0, nil
+	}
+	return int(entry.Count), nil
+}
@@ -0,0 +1,93 @@
+package rxdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFulltextSearch_MoreLikeThis(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "articles", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	articles := []map[string]any{
+		{"id": "article-001", "category": "databases", "text": "Embedded database engines store each document with indexes, transactions and replication"},
+		{"id": "article-002", "category": "databases", "text": "Choosing indexes for a document database improves query performance"},
+		{"id": "article-003", "category": "databases", "text": "Replication and transactions in distributed database engines"},
+		{"id": "article-004", "category": "databases", "text": "Document database query planners pick indexes automatically"},
+		{"id": "article-005", "category": "cooking", "text": "Bread recipes need flour, water, salt and patience with the dough"},
+		{"id": "article-006", "category": "cooking", "text": "Slow cooking stews with seasonal vegetables and a good stock"},
+		{"id": "article-007", "category": "cooking", "text": "A document of grandma's cake recipes with butter and sugar"},
+		{"id": "article-008", "category": "travel", "text": "Train journeys across the alps and the best mountain villages"},
+	}
+	if _, err := coll.BulkInsert(ctx, articles); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	category := make(map[string]string)
+	for _, a := range articles {
+		category[a["id"].(string)] = a["category"].(string)
+	}
+
+	for _, ranking := range []string{FulltextRankingTF, FulltextRankingBM25} {
+		fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+			Identifier:  "mlt-" + ranking,
+			Ranking:     ranking,
+			DocToString: func(doc map[string]any) string { return doc["text"].(string) },
+		})
+		if err != nil {
+			t.Fatalf("Failed to create fulltext search: %v", err)
+		}
+		defer fts.Close()
+
+		results, err := fts.MoreLikeThis(ctx, "article-001", FulltextSearchOptions{})
+		if err != nil {
+			t.Fatalf("%s: MoreLikeThis failed: %v", ranking, err)
+		}
+		if len(results) < 4 {
+			t.Fatalf("%s: expected at least 4 results, got %d", ranking, len(results))
+		}
+		// 同类文章全部排在其他类别之前，且不包含参考文档本身
+		for i, r := range results {
+			id := r.Document.ID()
+			if id == "article-001" {
+				t.Errorf("%s: reference document returned", ranking)
+			}
+			if i < 3 && category[id] != "databases" {
+				t.Errorf("%s: result %d is %s from %s", ranking, i, id, category[id])
+			}
+			if i >= 3 && category[id] == "databases" {
+				t.Errorf("%s: databases article %s ranked at %d", ranking, id, i)
+			}
+		}
+
+		// 只保留最显著的一个词
+		results, err = fts.MoreLikeThis(ctx, "article-001", FulltextSearchOptions{MaxQueryTerms: 1, Limit: 10})
+		if err != nil {
+			t.Fatalf("%s: MoreLikeThis failed: %v", ranking, err)
+		}
+		for _, r := range results {
+			if category[r.Document.ID()] != "databases" {
+				t.Errorf("%s: unexpected result %s with a single query term", ranking, r.Document.ID())
+			}
+		}
+
+		// 没有满足 MinDocFreq 的词时返回空结果
+		results, err = fts.MoreLikeThis(ctx, "article-001", FulltextSearchOptions{MinDocFreq: 100})
+		if err != nil || len(results) != 0 {
+			t.Errorf("%s: expected no results, got %d (%v)", ranking, len(results), err)
+		}
+	}
+
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier:  "mlt-missing",
+		DocToString: func(doc map[string]any) string { return doc["text"].(string) },
+	})
+	if err != nil {
+		t.Fatalf("Failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+	if _, err := fts.MoreLikeThis(ctx, "missing", FulltextSearchOptions{}); !IsNotFoundError(err) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package rxdb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FulltextIndexDeclaration Schema 中声明的全文索引。db.Collection 打开集合时自动创建，
+// 并随集合变更自动同步，数据库重新打开后无需再调用 AddFulltextSearch。
+type FulltextIndexDeclaration struct {
+	// Fields 拼接为可搜索文本的字段（支持点号路径），非空值按声明顺序以空格连接，
+	// 字符串数组的元素逐个拼接。
+	Fields []string
+	// Config 全文搜索配置，Identifier 必填。Config.DocToString 为空时由 Fields 生成，
+	// 非空时优先于 Fields；AutoSync 为 nil 时默认监听集合变更。
+	Config FulltextSearchConfig
+}
+
+// docToString 返回声明使用的文档文本提取函数。
+func (d FulltextIndexDeclaration) docToString() func(doc map[string]any) string {
+	if d.Config.DocToString != nil {
+		return d.Config.DocToString
+	}
+	fields := append([]string(nil), d.Fields...)
+	return func(doc map[string]any) string {
+		parts := make([]string, 0, len(fields))
+		for _, field := range fields {
+			parts = appendFulltextValue(parts, getNestedValue(doc, field))
+		}
+		return strings.Join(parts, " ")
+	}
+}
+
+// appendFulltextValue 将字段值转换为可搜索文本追加到 parts，忽略空值与对象。
+func appendFulltextValue(parts []string, value any) []string {
+	switch v := value.(type) {
+	case nil, map[string]any:
+		return parts
+	case string:
+		if v != "" {
+			parts = append(parts, v)
+		}
+	case []any:
+		for _, item := range v {
+			parts = appendFulltextValue(parts, item)
+		}
+	case []string:
+		for _, item := range v {
+			parts = appendFulltextValue(parts, item)
+		}
+	default:
+		parts = append(parts, fmt.Sprint(v))
+	}
+	return parts
+}
+
+// validateFulltextDeclarations 检查声明的 Identifier 唯一且提供了文本来源。
+func validateFulltextDeclarations(decls []FulltextIndexDeclaration) error {
+	seen := make(map[string]struct{}, len(decls))
+	for i, decl := range decls {
+		id := decl.Config.Identifier
+		if id == "" {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("fulltext index %d: identifier is required", i), nil)
+		}
+		if _, ok := seen[id]; ok {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("duplicate fulltext index identifier: %s", id), nil)
+		}
+		seen[id] = struct{}{}
+		if len(decl.Fields) == 0 && decl.Config.DocToString == nil {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("fulltext index %s: fields or DocToString is required", id), nil)
+		}
+	}
+	return nil
+}
+
+// attachFulltextIndexes 为 Schema 中声明、尚未添加到集合的全文索引创建 FulltextSearch 实例。
+// 任一索引创建失败时关闭本次已创建的实例并返回错误。
+func (c *collection) attachFulltextIndexes(decls []FulltextIndexDeclaration) error {
+	if err := validateFulltextDeclarations(decls); err != nil {
+		return err
+	}
+	var created []*FulltextSearch
+	for _, decl := range decls {
+		if _, err := c.GetFulltextIndex(decl.Config.Identifier); err == nil {
+			continue
+		}
+		config := decl.Config
+		config.DocToString = decl.docToString()
+		fts, err := AddFulltextSearch(c, config)
+		if err != nil {
+			for _, f := range created {
+				f.Close()
+			}
+			return fmt.Errorf("failed to create fulltext index %s: %w", config.Identifier, err)
+		}
+		created = append(created, fts)
+	}
+
+	c.fulltextMu.Lock()
+	c.declaredFulltexts = append(c.declaredFulltexts, created...)
+	c.fulltextMu.Unlock()
+	return nil
+}
+
+// closeDeclaredFulltexts 关闭由 Schema 声明创建的全文索引，在数据库关闭时调用。
+func (c *collection) closeDeclaredFulltexts() {
+	c.fulltextMu.Lock()
+	declared := c.declaredFulltexts
+	c.declaredFulltexts = nil
+	c.fulltextMu.Unlock()
+	for _, fts := range declared {
+		fts.Close()
+	}
+}
+
+// GetFulltextIndex 返回集合上指定 Identifier 的全文搜索实例，
+// 包括 Schema.FulltextIndexes 声明的索引与通过 AddFulltextSearch 添加的实例。
+func (c *collection) GetFulltextIndex(identifier string) (*FulltextSearch, error) {
+	c.fulltextMu.RLock()
+	defer c.fulltextMu.RUnlock()
+	for _, fts := range c.fulltexts {
+		if fts.identifier == identifier {
+			return fts, nil
+		}
+	}
+	return nil, NewError(ErrorTypeNotFound, fmt.Sprintf("fulltext index %q not found in collection %s", identifier, c.name), nil)
+}
+
+// mergeFulltextDeclarations 合并已有与新声明的全文索引，Identifier 相同时保留已有声明。
+func mergeFulltextDeclarations(existing, declared []FulltextIndexDeclaration) []FulltextIndexDeclaration {
+	merged := append([]FulltextIndexDeclaration(nil), existing...)
+	for _, decl := range declared {
+		found := false
+		for _, e := range existing {
+			if e.Config.Identifier == decl.Config.Identifier {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, decl)
+		}
+	}
+	return merged
+}
+
+// exportFulltextDeclarations 复制声明并去掉无法导出的 DocToString，导入后按 Fields 重建索引。
+func exportFulltextDeclarations(decls []FulltextIndexDeclaration) []FulltextIndexDeclaration {
+	if decls == nil {
+		return nil
+	}
+	exported := make([]FulltextIndexDeclaration, len(decls))
+	for i, decl := range decls {
+		decl.Fields = append([]string(nil), decl.Fields...)
+		decl.Config.DocToString = nil
+		exported[i] = decl
+	}
+	return exported
+}
@@ -0,0 +1,149 @@
+package rxdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// defaultSuggestLimit Suggest 与 SuggestDocuments 在 limit <= 0 时的默认返回数量。
+const defaultSuggestLimit = 10
+
+// Suggest 返回索引中以 prefix 开头、出现在最多文档中的 limit 个词元，用于输入联想（search-as-you-type）。
+// 直接遍历 bleve 的有序词典（FST）中该前缀的区间，不扫描文档。
+// 未设置 CaseSensitive 时前缀按小写匹配；prefix 为空或仅由停用词组成时返回空列表。
+// limit <= 0 时默认返回 10 个。
+func (fts *FulltextSearch) Suggest(ctx context.Context, prefix string, limit int) ([]string, error) {
+	if err := fts.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	fts.mu.RLock()
+	defer fts.mu.RUnlock()
+
+	prefix, ok := fts.suggestPrefix(prefix)
+	if !ok {
+		return []string{}, nil
+	}
+	if limit <= 0 {
+		limit = defaultSuggestLimit
+	}
+
+	entries, err := fts.prefixTerms(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return entries[i].term < entries[j].term
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	suggestions := make([]string, len(entries))
+	for i, e := range entries {
+		suggestions[i] = e.term
+	}
+	return suggestions, nil
+}
+
+// SuggestDocuments 返回索引文本中包含以 prefix 开头的词元的文档及其分数（与 FindWithScores 相同，
+// 外部文档以 ExternalID 返回）。前缀的处理方式与 Suggest 一致，limit <= 0 时默认返回 10 条。
+func (fts *FulltextSearch) SuggestDocuments(ctx context.Context, prefix string, limit int) ([]FulltextSearchResult, error) {
+	if err := fts.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	fts.mu.RLock()
+	defer fts.mu.RUnlock()
+
+	prefix, ok := fts.suggestPrefix(prefix)
+	if !ok {
+		return []FulltextSearchResult{}, nil
+	}
+	opts := FulltextSearchOptions{Limit: limit}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultSuggestLimit
+	}
+
+	pq := bleve.NewPrefixQuery(prefix)
+	pq.SetField("_content")
+	searchRequest := bleve.NewSearchRequest(pq)
+	size, err := fts.rerankSearchSize(opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+	searchRequest.Size = size
+
+	searchResult, err := fts.index.Search(searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("bleve search failed: %w", err)
+	}
+	if fts.ranking == FulltextRankingBM25 {
+		// 以前缀展开得到的全部词元计算 BM25 分数
+		entries, err := fts.prefixTerms(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		terms := make([]string, len(entries))
+		for i, e := range entries {
+			terms[i] = e.term
+		}
+		// 前缀展开得到的词元视为同一个词计分
+		if _, err := fts.rankBM25(searchResult, [][]string{terms}, opts.Limit, false); err != nil {
+			return nil, err
+		}
+	} else {
+		truncateHits(searchResult, opts.Limit)
+	}
+	return fts.hitsToResults(ctx, searchResult, opts, nil), nil
+}
+
+// suggestPrefix 规范化联想前缀。前缀经分析器处理后不产生任何词元（如停用词）时返回 false。
+func (fts *FulltextSearch) suggestPrefix(prefix string) (string, bool) {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return "", false
+	}
+	if fts.options == nil || !fts.options.CaseSensitive {
+		prefix = strings.ToLower(prefix)
+	}
+	if fts.analyzeText(prefix).Length == 0 {
+		return "", false
+	}
+	return prefix, true
+}
+
+type termCount struct {
+	term  string
+	count uint64
+}
+
+// prefixTerms 返回 _content 字段词典中以 prefix 开头的全部词元及其文档频率。调用方需持有 fts.mu 读锁。
+func (fts *FulltextSearch) prefixTerms(ctx context.Context, prefix string) ([]termCount, error) {
+	dict, err := fts.index.FieldDictPrefix("_content", []byte(prefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open field dictionary: %w", err)
+	}
+	defer dict.Close()
+
+	var entries []termCount
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		entry, err := dict.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read field dictionary: %w", err)
+		}
+		if entry == nil {
+			return entries, nil
+		}
+		entries = append(entries, termCount{term: entry.Term, count: entry.Count})
+	}
+}
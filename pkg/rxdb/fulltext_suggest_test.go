@@ -0,0 +1,105 @@
+package rxdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestFulltextSearch_Suggest(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "suggest_docs", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	docs := []map[string]any{
+		{"id": "1", "text": "database design for the data team"},
+		{"id": "2", "text": "data pipelines and database replication"},
+		{"id": "3", "text": "data science date parsing"},
+		{"id": "4", "text": "Éclair recipes with élan"},
+		{"id": "5", "text": "écoles et élèves"},
+		{"id": "6", "text": "dart and deno runtimes"},
+	}
+	if _, err := coll.BulkInsert(ctx, docs); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier:  "suggest",
+		DocToString: func(doc map[string]any) string { return doc["text"].(string) },
+	})
+	if err != nil {
+		t.Fatalf("Failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+
+	tests := []struct {
+		prefix string
+		limit  int
+		want   string
+	}{
+		// 按出现的文档数排序，相同时按字典序
+		{"dat", 0, "[data database date]"},
+		{"DAT", 2, "[data database]"},
+		// 单字符前缀候选很多，按 limit 截断
+		{"d", 3, "[data database dart]"},
+		{"é", 10, "[éclair écoles élan élèves]"},
+		{"Éc", 10, "[éclair écoles]"},
+		{"zzz", 10, "[]"},
+		// 仅由停用词组成的前缀不返回联想
+		{"the", 10, "[]"},
+		{"  ", 10, "[]"},
+	}
+	for _, tt := range tests {
+		got, err := fts.Suggest(ctx, tt.prefix, tt.limit)
+		if err != nil {
+			t.Fatalf("Suggest(%q) failed: %v", tt.prefix, err)
+		}
+		if fmt.Sprint(got) != tt.want {
+			t.Errorf("Suggest(%q, %d) = %v, want %s", tt.prefix, tt.limit, got, tt.want)
+		}
+	}
+
+	results, err := fts.SuggestDocuments(ctx, "datab", 0)
+	if err != nil {
+		t.Fatalf("SuggestDocuments failed: %v", err)
+	}
+	ids := map[string]bool{}
+	for _, r := range results {
+		if r.Score <= 0 {
+			t.Errorf("Expected positive score, got %v", r.Score)
+		}
+		ids[r.Document.ID()] = true
+	}
+	if len(ids) != 2 || !ids["1"] || !ids["2"] {
+		t.Errorf("Expected documents 1 and 2, got %v", ids)
+	}
+
+	results, err = fts.SuggestDocuments(ctx, "d", 2)
+	if err != nil {
+		t.Fatalf("SuggestDocuments failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results, got %d", len(results))
+	}
+	if results, err := fts.SuggestDocuments(ctx, "the", 10); err != nil || len(results) != 0 {
+		t.Errorf("Expected no results for stop word prefix, got %d (%v)", len(results), err)
+	}
+
+	// BM25 评分下同样可用
+	bm25, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier:  "suggest-bm25",
+		Ranking:     FulltextRankingBM25,
+		DocToString: func(doc map[string]any) string { return doc["text"].(string) },
+	})
+	if err != nil {
+		t.Fatalf("Failed to create fulltext search: %v", err)
+	}
+	defer bm25.Close()
+	results, err = bm25.SuggestDocuments(ctx, "élè", 0)
+	if err != nil {
+		t.Fatalf("SuggestDocuments failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Document.ID() != "5" {
+		t.Errorf("Expected document 5, got %d results", len(results))
+	}
+}
@@ -0,0 +1,423 @@
+package rxdb
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/registry"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+const (
+	// SynonymModeIndex 索引时为同义词额外写入规范词（键），查询时将查询词归一为规范词。
+	// 查询开销最低，修改同义词后需要 Reindex。
+	SynonymModeIndex = "index"
+	// SynonymModeQuery 索引保持原文，查询时将查询词扩展为整组同义词。修改同义词后无需重建索引。
+	SynonymModeQuery = "query"
+
+	synonymAnalyzerType  = "rxdb_synonym"
+	synonymIndexAnalyzer = "rxdb_synonym_index"
+	synonymQueryAnalyzer = "rxdb_synonym_query"
+)
+
+var registerSynonymOnce sync.Once
+
+// registerSynonymAnalyzer 注册同义词分析器类型。映射中保存的是分析器配置，打开已有索引前也必须注册。
+func registerSynonymAnalyzer() {
+	registerSynonymOnce.Do(func() {
+		registry.RegisterAnalyzer(synonymAnalyzerType, func(config map[string]interface{}, cache *registry.Cache) (analysis.Analyzer, error) {
+			base, _ := config["base"].(string)
+			if base == "" {
+				return nil, fmt.Errorf("synonym analyzer requires a base analyzer")
+			}
+			inject, _ := config["inject"].(bool)
+			return &synonymAnalyzer{
+				cache:    cache,
+				baseName: base,
+				synonyms: parseSynonymConfig(config["synonyms"]),
+				inject:   inject,
+			}, nil
+		})
+	})
+}
+
+// synonymAnalyzer 在基础分析器的输出上做同义词处理：inject 为 true 时在同一位置追加规范词（索引用），
+// 否则将同义词替换为规范词（查询用）。
+type synonymAnalyzer struct {
+	cache    *registry.Cache
+	baseName string
+	synonyms map[string][]string
+	inject   bool
+
+	// 基础分析器可能是同一映射中的自定义分析器，重新打开索引时定义顺序不确定，首次使用时再解析
+	once  sync.Once
+	base  analysis.Analyzer
+	table *synonymTable
+}
+
+func (a *synonymAnalyzer) Analyze(input []byte) analysis.TokenStream {
+	a.once.Do(func() {
+		if base, err := a.cache.AnalyzerNamed(a.baseName); err == nil {
+			a.base = base
+			a.table = newSynonymTable(base, a.synonyms)
+		}
+	})
+	if a.base == nil {
+		return nil
+	}
+
+	tokens := a.base.Analyze(input)
+	out := make(analysis.TokenStream, 0, len(tokens))
+	for _, token := range tokens {
+		canonical, ok := a.table.canonical[string(token.Term)]
+		if !ok || canonical == string(token.Term) {
+			out = append(out, token)
+			continue
+		}
+		if a.inject {
+			out = append(out, token)
+		}
+		out = append(out, &analysis.Token{
+			Term:     []byte(canonical),
+			Start:    token.Start,
+			End:      token.End,
+			Position: token.Position,
+			Type:     token.Type,
+		})
+	}
+	return out
+}
+
+// synonymTable 经分析器归一化后的同义词表。
+type synonymTable struct {
+	canonical map[string]string   // 词 -> 规范词
+	members   map[string][]string // 规范词 -> 整组词（已排序）
+}
+
+// newSynonymTable 用 analyzer 归一化同义词（大小写、词干等），分析后不是单个词元的条目被忽略。
+// 同一个词出现在多组中时以规范词字典序靠前的组为准。
+func newSynonymTable(analyzer analysis.Analyzer, synonyms map[string][]string) *synonymTable {
+	table := &synonymTable{canonical: make(map[string]string), members: make(map[string][]string)}
+	normalize := func(word string) (string, bool) {
+		tokens := analyzer.Analyze([]byte(word))
+		if len(tokens) != 1 {
+			return "", false
+		}
+		return string(tokens[0].Term), true
+	}
+
+	keys := make([]string, 0, len(synonyms))
+	for key := range synonyms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		canonical, ok := normalize(key)
+		if !ok {
+			continue
+		}
+		if _, taken := table.canonical[canonical]; !taken {
+			table.canonical[canonical] = canonical
+			table.members[canonical] = append(table.members[canonical], canonical)
+		}
+		for _, word := range synonyms[key] {
+			term, ok := normalize(word)
+			if !ok {
+				continue
+			}
+			if _, taken := table.canonical[term]; taken {
+				continue
+			}
+			table.canonical[term] = table.canonical[canonical]
+			group := table.canonical[canonical]
+			table.members[group] = append(table.members[group], term)
+		}
+	}
+	for _, group := range table.members {
+		sort.Strings(group)
+	}
+	return table
+}
+
+// parseSynonymConfig 解析分析器配置中的同义词表（新建时为原始类型，从映射 JSON 加载后为 interface{}）。
+func parseSynonymConfig(v interface{}) map[string][]string {
+	synonyms := make(map[string][]string)
+	switch m := v.(type) {
+	case map[string][]string:
+		for k, words := range m {
+			synonyms[k] = append([]string(nil), words...)
+		}
+	case map[string]interface{}:
+		for k, raw := range m {
+			switch words := raw.(type) {
+			case []string:
+				synonyms[k] = append([]string(nil), words...)
+			case []interface{}:
+				for _, w := range words {
+					if s, ok := w.(string); ok {
+						synonyms[k] = append(synonyms[k], s)
+					}
+				}
+			}
+		}
+	}
+	return synonyms
+}
+
+// synonymMode 返回生效的同义词模式，未配置同义词时返回空字符串。
+func (fts *FulltextSearch) synonymMode() string {
+	if fts.options == nil || len(fts.options.Synonyms) == 0 {
+		return ""
+	}
+	if strings.EqualFold(fts.options.SynonymMode, SynonymModeQuery) {
+		return SynonymModeQuery
+	}
+	return SynonymModeIndex
+}
+
+// expandsQuery 判断查询时是否需要扩展同义词。
+func (fts *FulltextSearch) expandsQuery() bool {
+	return fts.synonymMode() == SynonymModeQuery
+}
+
+// addSynonymAnalyzers 在 index 模式下以 base 为基础分析器定义索引与查询两个同义词分析器，返回索引分析器名称。
+func (fts *FulltextSearch) addSynonymAnalyzers(m *mapping.IndexMappingImpl, base string) (string, error) {
+	synonyms := make(map[string]interface{}, len(fts.options.Synonyms))
+	for k, words := range fts.options.Synonyms {
+		list := make([]interface{}, len(words))
+		for i, w := range words {
+			list[i] = w
+		}
+		synonyms[k] = list
+	}
+	for name, inject := range map[string]bool{synonymIndexAnalyzer: true, synonymQueryAnalyzer: false} {
+		err := m.AddCustomAnalyzer(name, map[string]interface{}{
+			"type":     synonymAnalyzerType,
+			"base":     base,
+			"synonyms": synonyms,
+			"inject":   inject,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to create synonym analyzer: %w", err)
+		}
+	}
+	return synonymIndexAnalyzer, nil
+}
+
+// fieldAnalyzer 返回 _content 字段的分析器（index 模式下包含同义词注入）。
+func (fts *FulltextSearch) fieldAnalyzer() analysis.Analyzer {
+	m := fts.index.Mapping()
+	return m.AnalyzerNamed(m.AnalyzerNameForPath("_content"))
+}
+
+// queryAnalyzerName 返回查询使用的分析器名称，为空表示使用字段分析器。
+func (fts *FulltextSearch) queryAnalyzerName() string {
+	if fts.synonymMode() == SynonymModeIndex && fts.fieldAnalyzerName() == synonymIndexAnalyzer {
+		return synonymQueryAnalyzer
+	}
+	return ""
+}
+
+func (fts *FulltextSearch) fieldAnalyzerName() string {
+	return fts.index.Mapping().AnalyzerNameForPath("_content")
+}
+
+// queryGroups 将查询字符串分析为查询词组：每组为一个查询词，query 模式下包含其整组同义词。
+// index 模式下查询词已归一为规范词，每组只有一个词。返回结果去重并排序。
+func (fts *FulltextSearch) queryGroups(queryStr string) [][]string {
+	analyzer := fts.fieldAnalyzer()
+	if name := fts.queryAnalyzerName(); name != "" {
+		analyzer = fts.index.Mapping().AnalyzerNamed(name)
+	}
+
+	var table *synonymTable
+	if fts.expandsQuery() {
+		table = newSynonymTable(fts.fieldAnalyzer(), fts.options.Synonyms)
+	}
+	seen := make(map[string]bool)
+	var groups [][]string
+	for term := range analyzeWith(analyzer, queryStr).Terms {
+		group := []string{term}
+		if table != nil {
+			if canonical, ok := table.canonical[term]; ok {
+				group = table.members[canonical]
+			}
+		}
+		key := strings.Join(group, "\x00")
+		if !seen[key] {
+			seen[key] = true
+			groups = append(groups, group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+	return groups
+}
+
+// textQuery 构造匹配查询词的 bleve 查询。query 模式下按词组展开为词项析取，否则使用 MatchQuery。
+func (fts *FulltextSearch) textQuery(queryString string, groups [][]string) query.Query {
+	if !fts.expandsQuery() {
+		mq := bleve.NewMatchQuery(queryString)
+		mq.SetField("_content")
+		mq.Analyzer = fts.queryAnalyzerName()
+		return mq
+	}
+	disjunction := bleve.NewDisjunctionQuery()
+	for _, group := range groups {
+		for _, term := range group {
+			tq := bleve.NewTermQuery(term)
+			tq.SetField("_content")
+			disjunction.AddQuery(tq)
+		}
+	}
+	return disjunction
+}
+
+// flattenGroups 返回全部词组中的词。
+func flattenGroups(groups [][]string) []string {
+	var terms []string
+	for _, group := range groups {
+		terms = append(terms, group...)
+	}
+	return terms
+}
+
+// rankGroupsTF 在 query 模式下以词组为单位重新计算 TF-IDF 分数：组内词频相加、文档频率取最大值，
+// 使同时出现多个同义词的文档不会因 bleve 对每个扩展词分别计分而得到过高的分数。
+// 计算方式与 bleve 一致：coord × Σ sqrt(tf) × (1 + ln(N/(df+1))) / sqrt(字段长度)。调用方需持有 fts.mu 读锁。
+func (fts *FulltextSearch) rankGroupsTF(ctx context.Context, searchResult *bleve.SearchResult, groups [][]string, limit int, debug bool) (map[string]*FulltextDebugInfo, error) {
+	docCount, err := fts.index.DocCount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count indexed documents: %w", err)
+	}
+	n := float64(docCount)
+	idf := make([]float64, len(groups))
+	for i, group := range groups {
+		df := 0
+		for _, term := range group {
+			termDF, err := fts.termDocFreq(term)
+			if err != nil {
+				return nil, err
+			}
+			df = max(df, termDF)
+		}
+		idf[i] = 1 + math.Log(n/float64(df+1))
+	}
+
+	var infos map[string]*FulltextDebugInfo
+	if debug {
+		infos = make(map[string]*FulltextDebugInfo, len(searchResult.Hits))
+	}
+	searchResult.MaxScore = 0
+	for _, hit := range searchResult.Hits {
+		text, err := fts.indexedText(ctx, hit.ID)
+		if err != nil {
+			return nil, err
+		}
+		stats := fts.analyzeText(text)
+		if stats.Length == 0 {
+			hit.Score = 0
+			continue
+		}
+		norm := 1 / math.Sqrt(float64(stats.Length))
+		info := newGroupDebugInfo(debug, norm)
+		score, matched := 0.0, 0
+		for i, group := range groups {
+			if tf := groupTermFreq(group, stats.Terms, idf[i], info); tf > 0 {
+				score += math.Sqrt(float64(tf)) * idf[i] * norm
+				matched++
+			}
+		}
+		if info != nil {
+			infos[hit.ID] = info
+		}
+		hit.Score = score * float64(matched) / float64(len(groups))
+		searchResult.MaxScore = math.Max(searchResult.MaxScore, hit.Score)
+	}
+
+	sort.SliceStable(searchResult.Hits, func(i, j int) bool {
+		return searchResult.Hits[i].Score > searchResult.Hits[j].Score
+	})
+	truncateHits(searchResult, limit)
+	return infos, nil
+}
+
+// indexedText 返回索引中文档 id 对应的原始文本（集合文档经 DocToString 转换）。
+func (fts *FulltextSearch) indexedText(ctx context.Context, id string) (string, error) {
+	if externalID, ok := strings.CutPrefix(id, externalDocIDPrefix); ok {
+		data, err := fts.collection.store.Get(ctx, fts.externalBucket(), externalID)
+		if err != nil {
+			return "", fmt.Errorf("failed to load external document %s: %w", externalID, err)
+		}
+		return string(data), nil
+	}
+	doc, err := fts.collection.FindByID(ctx, id)
+	if err != nil || doc == nil {
+		return "", err
+	}
+	return fts.docToString(doc.Data()), nil
+}
+
+// rankHits 按当前评分方式对命中结果重新计分：BM25 或 query 模式同义词扩展时重新排序并截断到 limit，
+// 否则保留 bleve 的分数。返回 BM25 或词组评分的调试信息。调用方需持有 fts.mu 读锁。
+func (fts *FulltextSearch) rankHits(ctx context.Context, searchResult *bleve.SearchResult, groups [][]string, limit int, debug bool) (map[string]*FulltextDebugInfo, error) {
+	switch {
+	case fts.ranking == FulltextRankingBM25:
+		return fts.rankBM25(searchResult, groups, limit, debug)
+	case fts.expandsQuery():
+		return fts.rankGroupsTF(ctx, searchResult, groups, limit, debug)
+	}
+	truncateHits(searchResult, limit)
+	return nil, nil
+}
+
+// GetSynonyms 返回当前的同义词表。
+func (fts *FulltextSearch) GetSynonyms(ctx context.Context) (map[string][]string, error) {
+	select {
+	case <-fts.closeChan:
+		return nil, fmt.Errorf("fulltext search is closed")
+	default:
+	}
+
+	fts.mu.RLock()
+	defer fts.mu.RUnlock()
+	synonyms := make(map[string][]string)
+	if fts.options != nil {
+		for k, words := range fts.options.Synonyms {
+			synonyms[k] = append([]string(nil), words...)
+		}
+	}
+	return synonyms, nil
+}
+
+// UpdateSynonyms 替换同义词表。query 模式下立即生效；index 模式下需调用 Reindex 后才会作用于索引，
+// 启用 AutoReindexOnConfigChange 时自动重建。
+func (fts *FulltextSearch) UpdateSynonyms(ctx context.Context, synonyms map[string][]string) error {
+	select {
+	case <-fts.closeChan:
+		return fmt.Errorf("fulltext search is closed")
+	default:
+	}
+
+	fts.mu.Lock()
+	// 复制选项，避免修改调用方传入的 FulltextIndexOptions
+	var opts FulltextIndexOptions
+	if fts.options != nil {
+		opts = *fts.options
+	}
+	opts.Synonyms = parseSynonymConfig(synonyms)
+	fts.options = &opts
+	expands := fts.expandsQuery()
+	fts.mu.Unlock()
+
+	if fts.autoReindex && !expands {
+		return fts.Reindex(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package rxdb
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestFulltextSearch_Synonyms(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "synonym_docs", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	docs := []map[string]any{
+		{"id": "car", "text": "red car for sale"},
+		{"id": "automobile", "text": "red automobile for sale"},
+		{"id": "vehicle", "text": "red Vehicle for sale"},
+		{"id": "bike", "text": "red bicycle for sale"},
+		{"id": "stuffed", "text": "car automobile vehicle"},
+		{"id": "repeated", "text": "car car car"},
+	}
+	if _, err := coll.BulkInsert(ctx, docs); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	synonyms := map[string][]string{"car": {"Automobile", "vehicle", "two words"}}
+	config := func(mode, ranking string) FulltextSearchConfig {
+		return FulltextSearchConfig{
+			Identifier:  "synonyms-" + mode + "-" + ranking,
+			Ranking:     ranking,
+			DocToString: func(doc map[string]any) string { return doc["text"].(string) },
+			IndexOptions: &FulltextIndexOptions{
+				Synonyms:    synonyms,
+				SynonymMode: mode,
+			},
+		}
+	}
+	search := func(fts *FulltextSearch, query string) map[string]float64 {
+		t.Helper()
+		results, err := fts.FindWithScores(ctx, query, FulltextSearchOptions{Limit: 100})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		scores := make(map[string]float64, len(results))
+		for _, r := range results {
+			scores[r.Document.ID()] = r.Score
+		}
+		return scores
+	}
+
+	for _, mode := range []string{SynonymModeIndex, SynonymModeQuery} {
+		for _, ranking := range []string{FulltextRankingTF, FulltextRankingBM25} {
+			name := mode + "/" + ranking
+			fts, err := AddFulltextSearch(coll, config(mode, ranking))
+			if err != nil {
+				t.Fatalf("%s: failed to create fulltext search: %v", name, err)
+			}
+			defer fts.Close()
+
+			// 任一同义词都能召回整组文档
+			for _, query := range []string{"car", "automobile", "VEHICLE"} {
+				scores := search(fts, query)
+				if len(scores) != 5 {
+					t.Errorf("%s: %q expected 5 results, got %v", name, query, scores)
+				}
+				if _, ok := scores["bike"]; ok {
+					t.Errorf("%s: %q unexpectedly matched bike", name, query)
+				}
+			}
+
+			// 同时包含多个同义词的文档不应高于重复同一个词的文档
+			scores := search(fts, "automobile")
+			if scores["stuffed"] > scores["repeated"]+1e-9 {
+				t.Errorf("%s: synonyms inflated the score: stuffed %.4f > repeated %.4f", name, scores["stuffed"], scores["repeated"])
+			}
+			// query 模式下索引保持原文，等长文档无论包含哪个同义词分数都相同
+			if mode == SynonymModeQuery {
+				for _, id := range []string{"car", "vehicle"} {
+					if math.Abs(scores[id]-scores["automobile"]) > 1e-9 {
+						t.Errorf("%s: expected %s to score like the exact match: %v", name, id, scores)
+					}
+				}
+			}
+
+			// 同义词扩展对 $text 查询同样生效
+			found, err := coll.Find(map[string]any{"$text": map[string]any{"$search": "automobile", "$index": config(mode, ranking).Identifier}}).Exec(ctx)
+			if err != nil {
+				t.Fatalf("%s: $text query failed: %v", name, err)
+			}
+			if len(found) != 5 {
+				t.Errorf("%s: $text expected 5 documents, got %d", name, len(found))
+			}
+		}
+	}
+
+	// query 模式下更新同义词无需重建索引
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier:   "synonyms-update",
+		DocToString:  func(doc map[string]any) string { return doc["text"].(string) },
+		IndexOptions: &FulltextIndexOptions{SynonymMode: SynonymModeQuery},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+	if scores := search(fts, "bike"); len(scores) != 0 {
+		t.Errorf("Expected no results before adding synonyms, got %v", scores)
+	}
+	if err := fts.UpdateSynonyms(ctx, map[string][]string{"bike": {"bicycle"}}); err != nil {
+		t.Fatalf("Failed to update synonyms: %v", err)
+	}
+	if scores := search(fts, "bike"); len(scores) != 1 || scores["bike"] == 0 {
+		t.Errorf("Expected bicycle document after updating synonyms, got %v", scores)
+	}
+	got, err := fts.GetSynonyms(ctx)
+	if err != nil || len(got["bike"]) != 1 || got["bike"][0] != "bicycle" {
+		t.Errorf("Unexpected synonyms: %v (%v)", got, err)
+	}
+
+	// index 模式的同义词分析器保存在索引映射中，重新打开已有索引后仍然生效
+	reopenConfig := config(SynonymModeIndex, FulltextRankingTF)
+	reopenConfig.Identifier = "synonyms-reopen"
+	first, err := AddFulltextSearch(coll, reopenConfig)
+	if err != nil {
+		t.Fatalf("Failed to create fulltext search: %v", err)
+	}
+	first.Close()
+	reopened, err := AddFulltextSearch(coll, reopenConfig)
+	if err != nil {
+		t.Fatalf("Failed to reopen fulltext search: %v", err)
+	}
+	defer reopened.Close()
+	if scores := search(reopened, "automobile"); len(scores) != 5 {
+		t.Errorf("Expected 5 results after reopening, got %v", scores)
+	}
+
+	if _, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier:   "synonyms-invalid",
+		DocToString:  func(doc map[string]any) string { return doc["text"].(string) },
+		IndexOptions: &FulltextIndexOptions{SynonymMode: "both"},
+	}); err == nil {
+		t.Error("Expected error for unsupported synonym mode")
+	}
+}
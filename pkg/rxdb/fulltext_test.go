@@ -2,8 +2,19 @@ package rxdb
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
 )
 
 func TestFulltextSearch_Basic(t *testing.T) {
@@ -174,6 +185,186 @@ func TestFulltextSearch_WithScores(t *testing.T) {
 	}
 }
 
+func TestFulltextSearch_Debug(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rxdb-fulltext-debug-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	db, err := CreateDatabase(context.Background(), DatabaseOptions{
+		Name: "test-fulltext-debug",
+		Path: tmpDir,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close(context.Background())
+
+	coll, err := db.Collection(context.Background(), "docs", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+
+	testDocs := []map[string]any{
+		{"id": "1", "content": "Go is a language and the Go language is simple"},
+		{"id": "2", "content": "Python language"},
+		{"id": "3", "content": "banana orange"},
+	}
+	for _, doc := range testDocs {
+		if _, err := coll.Insert(context.Background(), doc); err != nil {
+			t.Fatalf("failed to insert document: %v", err)
+		}
+	}
+
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier: "debug-search",
+		DocToString: func(doc map[string]any) string {
+			content, _ := doc["content"].(string)
+			return content
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+
+	// 未开启调试时不返回调试信息
+	results, err := fts.FindWithScores(context.Background(), "Go language")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	for _, r := range results {
+		if r.Debug != nil {
+			t.Errorf("expected no debug info when Debug is false")
+		}
+	}
+
+	results, err = fts.FindWithScores(context.Background(), "Go language", FulltextSearchOptions{Debug: true})
+	if err != nil {
+		t.Fatalf("failed to search with debug: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+
+	top := results[0]
+	if top.Document.ID() != "1" {
+		t.Fatalf("expected doc 1 first, got %s", top.Document.ID())
+	}
+	if top.Debug == nil {
+		t.Fatal("expected debug info")
+	}
+
+	matched := make(map[string]bool)
+	for _, term := range top.Debug.MatchedTerms {
+		matched[term] = true
+	}
+	if !matched["go"] || !matched["language"] {
+		t.Errorf("expected matched terms to contain go and language, got %v", top.Debug.MatchedTerms)
+	}
+	if top.Debug.TermFrequencies["go"] != 2 || top.Debug.TermFrequencies["language"] != 2 {
+		t.Errorf("unexpected term frequencies: %v", top.Debug.TermFrequencies)
+	}
+	if top.Debug.InverseDocFrequencies["go"] <= top.Debug.InverseDocFrequencies["language"] {
+		t.Errorf("expected rarer term go to have higher idf: %v", top.Debug.InverseDocFrequencies)
+	}
+	if top.Debug.FieldLengthNormalization <= 0 {
+		t.Errorf("expected positive field length normalization, got %f", top.Debug.FieldLengthNormalization)
+	}
+	if top.Debug.FinalScore != top.Score {
+		t.Errorf("expected FinalScore %f to equal Score %f", top.Debug.FinalScore, top.Score)
+	}
+
+	// 第二个结果只命中 language
+	if second := results[1].Debug; second == nil || len(second.MatchedTerms) != 1 || second.MatchedTerms[0] != "language" {
+		t.Errorf("expected second result to match only language, got %+v", second)
+	}
+}
+
+// TestBuildFulltextDebugInfo_BleveExplanation 用当前 bleve 版本实际生成的解释树校验解析结果，
+// bleve 调整解释树结构时该测试会失败。
+func TestBuildFulltextDebugInfo_BleveExplanation(t *testing.T) {
+	// doc 1 经 standard 分析器去掉停用词后为 go language go language simple（长度 5），
+	// 三个文档平均长度为 2；go 出现在 1 个文档中，language 出现在 2 个文档中。
+	tests := []struct {
+		model    string
+		idf      map[string]float64
+		normFull float64
+	}{
+		{
+			model:    "tf-idf",
+			idf:      map[string]float64{"go": 1 + math.Log(3.0/2), "language": 1 + math.Log(3.0/3)},
+			normFull: 1 / math.Sqrt(5),
+		},
+		{
+			model:    "bm25",
+			idf:      map[string]float64{"go": math.Log(1 + 2.5/1.5), "language": math.Log(1 + 1.5/2.5)},
+			normFull: 1 - 0.75 + 0.75*5/2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			indexMapping := bleve.NewIndexMapping()
+			indexMapping.DefaultAnalyzer = "standard"
+			if tt.model == "bm25" {
+				indexMapping.ScoringModel = tt.model
+			}
+			index, err := bleve.New(filepath.Join(t.TempDir(), "index"), indexMapping)
+			if err != nil {
+				t.Fatalf("failed to create index: %v", err)
+			}
+			defer index.Close()
+
+			docs := map[string]string{
+				"1": "Go is a language and the Go language is simple",
+				"2": "Python language",
+				"3": "banana orange",
+			}
+			for id, content := range docs {
+				if err := index.Index(id, map[string]any{"_content": content}); err != nil {
+					t.Fatalf("failed to index document: %v", err)
+				}
+			}
+
+			query := bleve.NewMatchQuery("Go language")
+			query.SetField("_content")
+			req := bleve.NewSearchRequest(query)
+			req.Explain = true
+			res, err := index.Search(req)
+			if err != nil {
+				t.Fatalf("failed to search: %v", err)
+			}
+			if len(res.Hits) != 2 || res.Hits[0].ID != "1" {
+				t.Fatalf("unexpected hits: %v", res.Hits)
+			}
+			hit := res.Hits[0]
+
+			info := buildFulltextDebugInfo(hit.Expl, hit.Score)
+			if !reflect.DeepEqual(info.MatchedTerms, []string{"go", "language"}) {
+				t.Errorf("unexpected matched terms: %v", info.MatchedTerms)
+			}
+			if !reflect.DeepEqual(info.TermFrequencies, map[string]int{"go": 2, "language": 2}) {
+				t.Errorf("unexpected term frequencies: %v", info.TermFrequencies)
+			}
+			for term, want := range tt.idf {
+				if got := info.InverseDocFrequencies[term]; math.Abs(got-want) > 1e-6 {
+					t.Errorf("idf(%s) = %f, want %f", term, got, want)
+				}
+			}
+			if math.Abs(info.FieldLengthNormalization-tt.normFull) > 1e-6 {
+				t.Errorf("field length normalization = %f, want %f", info.FieldLengthNormalization, tt.normFull)
+			}
+			if info.FinalScore != hit.Score || info.Explanation != hit.Expl {
+				t.Errorf("expected final score and raw explanation from the hit")
+			}
+		})
+	}
+}
+
 func TestFulltextSearch_RealTimeIndex(t *testing.T) {
 	// 创建临时目录
 	tmpDir, err := os.MkdirTemp("", "rxdb-fulltext-realtime-test-*")
@@ -413,3 +604,1206 @@ func TestFulltextSearch_Persist(t *testing.T) {
 
 	db.Close(context.Background())
 }
+
+func TestFulltextSearch_Stemmer(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rxdb-fulltext-stemmer-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	db, err := CreateDatabase(context.Background(), DatabaseOptions{
+		Name: "test-fulltext-stemmer",
+		Path: tmpDir,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close(context.Background())
+
+	coll, err := db.Collection(context.Background(), "docs", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+
+	testDocs := []map[string]any{
+		{"id": "1", "content": "running quickly"},
+		{"id": "2", "content": "walking slowly"},
+	}
+	for _, doc := range testDocs {
+		if _, err := coll.Insert(context.Background(), doc); err != nil {
+			t.Fatalf("failed to insert document: %v", err)
+		}
+	}
+
+	docToString := func(doc map[string]any) string {
+		content, _ := doc["content"].(string)
+		return content
+	}
+
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier:  "stem-search",
+		DocToString: docToString,
+		IndexOptions: &FulltextIndexOptions{
+			StemmerLanguage: "english",
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+
+	for _, q := range []string{"run", "runs", "Running"} {
+		results, err := fts.Find(context.Background(), q)
+		if err != nil {
+			t.Fatalf("failed to search %q: %v", q, err)
+		}
+		if len(results) != 1 || results[0].ID() != "1" {
+			t.Errorf("expected search %q to return document 1, got %d results", q, len(results))
+		}
+	}
+
+	// sego 分词模式忽略词干提取
+	segoFts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier:  "stem-sego-search",
+		DocToString: docToString,
+		IndexOptions: &FulltextIndexOptions{
+			Tokenize:        "sego",
+			StemmerLanguage: "english",
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create sego fulltext search: %v", err)
+	}
+	defer segoFts.Close()
+
+	results, err := segoFts.Find(context.Background(), "running")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) != 1 || results[0].ID() != "1" {
+		t.Errorf("expected sego search 'running' to return document 1, got %d results", len(results))
+	}
+	results, err = segoFts.Find(context.Background(), "run")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected sego search 'run' to ignore stemmer, got %d results", len(results))
+	}
+
+	// 不支持的语言
+	if _, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier:   "stem-invalid",
+		DocToString:  docToString,
+		IndexOptions: &FulltextIndexOptions{StemmerLanguage: "klingon"},
+	}); err == nil {
+		t.Error("expected error for unsupported stemmer language")
+	}
+}
+
+func TestFulltextSearch_ReindexBatchSize(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rxdb-fulltext-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "test-fulltext",
+		Path: tmpDir,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	coll, err := db.Collection(ctx, "articles", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+
+	const total = 25
+	for i := 0; i < total; i++ {
+		if _, err := coll.Insert(ctx, map[string]any{"id": fmt.Sprintf("doc%02d", i), "title": fmt.Sprintf("article number %d", i)}); err != nil {
+			t.Fatalf("failed to insert document: %v", err)
+		}
+	}
+
+	// 分批扫描应覆盖所有文档，且每批不超过 batchSize
+	seen := make(map[string]bool)
+	var sizes []int
+	err = coll.(*collection).iterateBatches(ctx, 7, func(docs []Document) error {
+		sizes = append(sizes, len(docs))
+		for _, doc := range docs {
+			if seen[doc.ID()] {
+				t.Errorf("document %s scanned twice", doc.ID())
+			}
+			seen[doc.ID()] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("iterateBatches failed: %v", err)
+	}
+	if len(seen) != total {
+		t.Errorf("expected %d documents scanned, got %d", total, len(seen))
+	}
+	if fmt.Sprint(sizes) != "[7 7 7 4]" {
+		t.Errorf("expected batch sizes [7 7 7 4], got %v", sizes)
+	}
+
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier: "batched",
+		DocToString: func(doc map[string]any) string {
+			title, _ := doc["title"].(string)
+			return title
+		},
+		BatchSize: 7,
+	})
+	if err != nil {
+		t.Fatalf("failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+
+	if err := fts.Reindex(ctx); err != nil {
+		t.Fatalf("reindex failed: %v", err)
+	}
+	if count := fts.Count(); count != total {
+		t.Errorf("expected %d indexed documents, got %d", total, count)
+	}
+	results, err := fts.Find(ctx, "article", FulltextSearchOptions{Limit: total})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) != total {
+		t.Errorf("expected %d results, got %d", total, len(results))
+	}
+}
+
+// TestFulltextSearch_ReindexPeakMemory 断言重建索引期间驻留的文档内存不超过 2×单文档占用×BatchSize。
+// DocToString 返回空字符串使文档不进入 bleve，测得的增量只来自分批加载的文档。
+func TestFulltextSearch_ReindexPeakMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping memory measurement in short mode")
+	}
+	// 内存与 SQLite 后端的 Scan 按快照或分页预读，峰值由后端决定，只在流式读取的 Badger 上校验
+	if name := os.Getenv(testBackendEnv); name != "" && name != "badger" {
+		t.Skipf("peak memory bound only holds for the badger backend, got %s", name)
+	}
+	const (
+		total     = 1000
+		batchSize = 50
+	)
+
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll, err := db.Collection(ctx, "articles", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	// 文档足够大，使分批持有的文档占用远超存储迭代器的固定开销和后台 goroutine 造成的堆波动
+	docs := make([]map[string]any, 0, batchSize)
+	for i := 0; i < total; i++ {
+		docs = append(docs, map[string]any{
+			"id":   fmt.Sprintf("doc%06d", i),
+			"body": strings.Repeat(fmt.Sprintf("document %d about databases, search engines and batched indexing ", i), 1000),
+		})
+		if len(docs) == cap(docs) {
+			if _, err := coll.BulkInsert(ctx, docs); err != nil {
+				t.Fatalf("failed to insert documents: %v", err)
+			}
+			docs = docs[:0]
+		}
+	}
+	docs = nil
+
+	var base, peak uint64
+	seen := 0
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier: "peak",
+		DocToString: func(doc map[string]any) string {
+			// 每批最后一个文档处，整批文档均处于驻留状态
+			if seen++; seen%batchSize == 0 && base > 0 {
+				var m runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&m)
+				if m.HeapAlloc > base && m.HeapAlloc-base > peak {
+					peak = m.HeapAlloc - base
+				}
+			}
+			return ""
+		},
+		BatchSize:      batchSize,
+		Initialization: "lazy",
+	})
+	if err != nil {
+		t.Fatalf("failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+
+	// 预热一次，使重新打开索引的固定开销计入基线
+	if err := fts.Reindex(ctx); err != nil {
+		t.Fatalf("reindex failed: %v", err)
+	}
+
+	// 估算单个文档解码后驻留的内存
+	c := coll.(*collection)
+	raw := make([][]byte, batchSize)
+	for i := range raw {
+		if raw[i], err = c.store.Get(ctx, c.name, fmt.Sprintf("doc%06d", i)); err != nil {
+			t.Fatalf("failed to load sample: %v", err)
+		}
+	}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.GC() // 第二次回收带终结器的对象
+	runtime.ReadMemStats(&before)
+	sample := make([]map[string]any, len(raw))
+	for i, data := range raw {
+		if sample[i], err = c.decodeStoredDocument(data); err != nil {
+			t.Fatalf("failed to decode sample: %v", err)
+		}
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	perDoc := float64(after.HeapAlloc-before.HeapAlloc) / float64(len(sample))
+	runtime.KeepAlive(raw)
+	runtime.KeepAlive(sample)
+	raw, sample = nil, nil
+
+	runtime.GC()
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	base = m.HeapAlloc
+	if err := fts.Reindex(ctx); err != nil {
+		t.Fatalf("reindex failed: %v", err)
+	}
+
+	limit := 2 * perDoc * batchSize
+	t.Logf("peak %d bytes, per document %.0f bytes, limit %.0f bytes", peak, perDoc, limit)
+	if float64(peak) > limit {
+		t.Errorf("peak heap growth %d bytes exceeds 2×%.0f×%d bytes", peak, perDoc, batchSize)
+	}
+}
+
+// BenchmarkFulltextSearch_Reindex 在 10 万文档的集合上以 BatchSize=50 重建索引，
+// 并报告重建期间堆内存的峰值增量，用于观察内存占用是否随批大小而非集合大小增长。
+// 峰值包含 bleve 自身的索引开销；文档驻留内存的上界由 TestFulltextSearch_ReindexPeakMemory 断言。
+func BenchmarkFulltextSearch_Reindex(b *testing.B) {
+	const (
+		total     = 100000
+		batchSize = 50
+	)
+
+	tmpDir, err := os.MkdirTemp("", "rxdb-fulltext-bench-*")
+	if err != nil {
+		b.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "bench-fulltext",
+		Path: tmpDir,
+	})
+	if err != nil {
+		b.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	coll, err := db.Collection(ctx, "articles", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		b.Fatalf("failed to create collection: %v", err)
+	}
+
+	docs := make([]map[string]any, 0, 1000)
+	for i := 0; i < total; i++ {
+		docs = append(docs, map[string]any{
+			"id":   fmt.Sprintf("doc%06d", i),
+			"body": fmt.Sprintf("document %d about databases, search engines and batched indexing", i),
+		})
+		if len(docs) == cap(docs) {
+			if _, err := coll.BulkInsert(ctx, docs); err != nil {
+				b.Fatalf("failed to insert documents: %v", err)
+			}
+			docs = docs[:0]
+		}
+	}
+
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier: "bench",
+		DocToString: func(doc map[string]any) string {
+			body, _ := doc["body"].(string)
+			return body
+		},
+		BatchSize:      batchSize,
+		Initialization: "lazy",
+	})
+	if err != nil {
+		b.Fatalf("failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+
+	// 估算单个文档解码后的内存占用
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	sample, err := coll.Find(nil).Limit(1000).Exec(ctx)
+	if err != nil {
+		b.Fatalf("failed to load sample: %v", err)
+	}
+	runtime.ReadMemStats(&after)
+	perDoc := float64(after.TotalAlloc-before.TotalAlloc) / float64(len(sample))
+	sample = nil
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+		var base runtime.MemStats
+		runtime.ReadMemStats(&base)
+
+		var peak uint64
+		var wg sync.WaitGroup
+		done := make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(10 * time.Millisecond)
+			defer ticker.Stop()
+			var m runtime.MemStats
+			for {
+				runtime.ReadMemStats(&m)
+				if m.HeapInuse > peak {
+					peak = m.HeapInuse
+				}
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+
+		if err := fts.Reindex(ctx); err != nil {
+			b.Fatalf("reindex failed: %v", err)
+		}
+		close(done)
+		wg.Wait()
+
+		var growth float64
+		if peak > base.HeapInuse {
+			growth = float64(peak - base.HeapInuse)
+		}
+		b.ReportMetric(growth/(1<<20), "peak-heap-MB")
+		b.ReportMetric(growth/(perDoc*total), "peak/full-load")
+	}
+	b.ReportMetric(perDoc, "bytes/doc")
+}
+
+// countIndexTerms 统计全文索引 _content 字段中的词项数量。
+func countIndexTerms(t *testing.T, fts *FulltextSearch) int {
+	t.Helper()
+	dict, err := fts.index.FieldDict("_content")
+	if err != nil {
+		t.Fatalf("failed to open field dictionary: %v", err)
+	}
+	defer dict.Close()
+
+	count := 0
+	for {
+		entry, err := dict.Next()
+		if err != nil {
+			t.Fatalf("failed to read field dictionary: %v", err)
+		}
+		if entry == nil {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+func TestFulltextSearch_NGram(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rxdb-fulltext-ngram-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "test-fulltext-ngram",
+		Path: tmpDir,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	coll, err := db.Collection(ctx, "docs", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+
+	testDocs := []map[string]any{
+		{"id": "1", "content": "hello world"},
+		{"id": "2", "content": "goodbye moon"},
+		{"id": "3", "content": "the quick brown fox"},
+	}
+	for _, doc := range testDocs {
+		if _, err := coll.Insert(ctx, doc); err != nil {
+			t.Fatalf("failed to insert document: %v", err)
+		}
+	}
+
+	docToString := func(doc map[string]any) string {
+		content, _ := doc["content"].(string)
+		return content
+	}
+
+	ngramFTS, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier:   "ngram-search",
+		DocToString:  docToString,
+		IndexOptions: &FulltextIndexOptions{Tokenize: "ngram"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create ngram fulltext search: %v", err)
+	}
+	defer ngramFTS.Close()
+
+	forwardFTS, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier:   "forward-search",
+		DocToString:  docToString,
+		IndexOptions: &FulltextIndexOptions{Tokenize: "forward"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create forward fulltext search: %v", err)
+	}
+	defer forwardFTS.Close()
+
+	// 子串查询通过 n-gram 命中
+	results, err := ngramFTS.Find(ctx, "ello")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) == 0 || results[0].ID() != "1" {
+		ids := make([]string, 0, len(results))
+		for _, doc := range results {
+			ids = append(ids, doc.ID())
+		}
+		t.Errorf("expected doc 1 as top result for \"ello\", got %v", ids)
+	}
+
+	// 按词分词时子串无法命中
+	results, err = forwardFTS.Find(ctx, "ello")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no results from forward tokenizer for \"ello\", got %d", len(results))
+	}
+
+	ngramTerms := countIndexTerms(t, ngramFTS)
+	forwardTerms := countIndexTerms(t, forwardFTS)
+	if ngramTerms <= forwardTerms {
+		t.Errorf("expected ngram index to have more terms than forward index, got %d <= %d", ngramTerms, forwardTerms)
+	}
+}
+
+func TestFulltextSearch_ExternalDocuments(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rxdb-fulltext-external-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "test-fulltext-external",
+		Path: tmpDir,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	coll, err := db.Collection(ctx, "docs", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	if _, err := coll.Insert(ctx, map[string]any{"id": "1", "content": "golang database tutorial"}); err != nil {
+		t.Fatalf("failed to insert document: %v", err)
+	}
+
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier: "external-search",
+		DocToString: func(doc map[string]any) string {
+			content, _ := doc["content"].(string)
+			return content
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+
+	if err := fts.AddDocument(ctx, "https://example.com/page", "an external page about golang concurrency"); err != nil {
+		t.Fatalf("failed to add external document: %v", err)
+	}
+	if err := fts.AddDocument(ctx, "", "missing id"); err == nil {
+		t.Error("expected error for empty document id")
+	}
+
+	results, err := fts.FindWithScores(ctx, "golang")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	var sawCollection, sawExternal bool
+	for _, r := range results {
+		switch {
+		case r.Document != nil && r.Document.ID() == "1":
+			sawCollection = true
+			if r.ExternalID != "" {
+				t.Errorf("collection result should not have ExternalID, got %q", r.ExternalID)
+			}
+		case r.Document == nil && r.ExternalID == "https://example.com/page":
+			sawExternal = true
+		default:
+			t.Errorf("unexpected result: %+v", r)
+		}
+	}
+	if !sawCollection || !sawExternal {
+		t.Errorf("expected both collection and external results, got %+v", results)
+	}
+
+	// Find 只返回集合文档
+	docs, err := fts.Find(ctx, "concurrency")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(docs) != 0 {
+		t.Errorf("expected no collection documents for external-only match, got %d", len(docs))
+	}
+
+	// 外部文档在 Reindex 后仍然可搜索
+	if err := fts.Reindex(ctx); err != nil {
+		t.Fatalf("failed to reindex: %v", err)
+	}
+	results, err = fts.FindWithScores(ctx, "concurrency")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) != 1 || results[0].ExternalID != "https://example.com/page" {
+		t.Errorf("expected external document after reindex, got %+v", results)
+	}
+
+	if err := fts.RemoveDocument(ctx, "https://example.com/page"); err != nil {
+		t.Fatalf("failed to remove external document: %v", err)
+	}
+	results, err = fts.FindWithScores(ctx, "golang")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) != 1 || results[0].Document == nil || results[0].Document.ID() != "1" {
+		t.Errorf("expected only the collection document after removal, got %+v", results)
+	}
+	if fts.Count() != 1 {
+		t.Errorf("expected 1 indexed document, got %d", fts.Count())
+	}
+}
+
+func TestFulltextSearch_ManualSync(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "manual_sync", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	if _, err := coll.Insert(ctx, map[string]any{"id": "1", "content": "golang database tutorial"}); err != nil {
+		t.Fatalf("failed to insert document: %v", err)
+	}
+
+	autoSync := false
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier: "manual",
+		DocToString: func(doc map[string]any) string {
+			content, _ := doc["content"].(string)
+			return content
+		},
+		AutoSync: &autoSync,
+		Ranking:  FulltextRankingBM25,
+	})
+	if err != nil {
+		t.Fatalf("failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+
+	ids := func(query string) []string {
+		t.Helper()
+		docs, err := fts.Find(ctx, query)
+		if err != nil {
+			t.Fatalf("failed to search: %v", err)
+		}
+		var out []string
+		for _, doc := range docs {
+			out = append(out, doc.ID())
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	// 关闭 AutoSync 后集合变更不会自动写入索引
+	doc2, err := coll.Insert(ctx, map[string]any{"id": "2", "content": "golang concurrency patterns"})
+	if err != nil {
+		t.Fatalf("failed to insert document: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := ids("golang"); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Fatalf("expected only the initially indexed document, got %v", got)
+	}
+
+	if err := fts.IndexDocument(ctx, doc2); err != nil {
+		t.Fatalf("failed to index document: %v", err)
+	}
+	if got := ids("golang"); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("expected both documents after IndexDocument, got %v", got)
+	}
+	if fts.bm25.DocCount != 2 {
+		t.Errorf("expected bm25 statistics for 2 documents, got %+v", fts.bm25)
+	}
+
+	// 再次调用时覆盖旧的索引内容
+	updated, err := coll.Upsert(ctx, map[string]any{"id": "2", "content": "rust ownership"})
+	if err != nil {
+		t.Fatalf("failed to update document: %v", err)
+	}
+	if err := fts.IndexDocument(ctx, updated); err != nil {
+		t.Fatalf("failed to index document: %v", err)
+	}
+	if got := ids("concurrency"); len(got) != 0 {
+		t.Errorf("expected stale terms to be removed, got %v", got)
+	}
+	if got := ids("rust"); !reflect.DeepEqual(got, []string{"2"}) {
+		t.Errorf("expected updated document, got %v", got)
+	}
+
+	if err := fts.UnindexDocument(ctx, "1"); err != nil {
+		t.Fatalf("failed to unindex document: %v", err)
+	}
+	if got := ids("golang"); len(got) != 0 {
+		t.Errorf("expected no results after UnindexDocument, got %v", got)
+	}
+	if fts.Count() != 1 || fts.bm25.DocCount != 1 {
+		t.Errorf("expected 1 indexed document, got %d (%+v)", fts.Count(), fts.bm25)
+	}
+	if err := fts.UnindexDocument(ctx, "missing"); err != nil {
+		t.Errorf("expected no error for a document that is not indexed, got %v", err)
+	}
+	if err := fts.IndexDocument(ctx, nil); err == nil {
+		t.Error("expected error for nil document")
+	}
+
+	// Reindex 仍按集合的当前内容重建
+	if err := fts.Reindex(ctx); err != nil {
+		t.Fatalf("failed to reindex: %v", err)
+	}
+	if got := ids("golang rust"); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("expected both documents after reindex, got %v", got)
+	}
+}
+
+func TestFulltextSearch_FindNear(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "articles", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	articles := []map[string]any{
+		{"id": "go-concurrency", "topic": "go", "content": "Go concurrency with goroutines and channels: the select statement coordinates goroutines without locks"},
+		{"id": "go-channels", "topic": "go", "content": "Buffered channels in Go let goroutines communicate and synchronize concurrency safely"},
+		{"id": "go-scheduler", "topic": "go", "content": "The Go scheduler multiplexes goroutines onto OS threads for cheap concurrency"},
+		{"id": "rust-ownership", "topic": "rust", "content": "Rust ownership and borrowing rules prevent memory errors at compile time"},
+		{"id": "css-grid", "topic": "css", "content": "CSS grid layout arranges page elements into rows and columns"},
+		{"id": "sql-joins", "topic": "sql", "content": "SQL joins combine rows from multiple tables using matching keys"},
+	}
+	for _, doc := range articles {
+		if _, err := coll.Insert(ctx, doc); err != nil {
+			t.Fatalf("failed to insert document: %v", err)
+		}
+	}
+
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier: "articles-near",
+		DocToString: func(doc map[string]any) string {
+			content, _ := doc["content"].(string)
+			return content
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+
+	results, err := fts.FindNear(ctx, "go-concurrency", 2)
+	if err != nil {
+		t.Fatalf("failed to find near: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Document == nil {
+			t.Fatalf("expected collection document in result")
+		}
+		if r.Document.ID() == "go-concurrency" {
+			t.Errorf("expected source document to be excluded")
+		}
+		if topic := r.Document.GetString("topic"); topic != "go" {
+			t.Errorf("expected Go-related article, got %s (%s)", r.Document.ID(), topic)
+		}
+	}
+
+	// 不存在的文档返回 not found 错误
+	if _, err := fts.FindNear(ctx, "missing", 2); !IsNotFoundError(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+// indexedTermCount 直接查询 bleve 索引，返回包含 term 的文档数。
+func indexedTermCount(t *testing.T, fts *FulltextSearch, term string) int {
+	t.Helper()
+	q := bleve.NewTermQuery(term)
+	q.SetField("_content")
+	fts.mu.RLock()
+	defer fts.mu.RUnlock()
+	res, err := fts.index.Search(bleve.NewSearchRequest(q))
+	if err != nil {
+		t.Fatalf("failed to search index: %v", err)
+	}
+	return int(res.Total)
+}
+
+func TestFulltextSearch_UpdateStopWords(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "articles", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	for _, doc := range []map[string]any{
+		{"id": "1", "content": "The quick brown fox"},
+		{"id": "2", "content": "the lazy dog"},
+		{"id": "3", "content": "a quick cat"},
+	} {
+		if _, err := coll.Insert(ctx, doc); err != nil {
+			t.Fatalf("failed to insert document: %v", err)
+		}
+	}
+
+	docToString := func(doc map[string]any) string {
+		content, _ := doc["content"].(string)
+		return content
+	}
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier:   "stopwords",
+		DocToString:  docToString,
+		IndexOptions: &FulltextIndexOptions{},
+	})
+	if err != nil {
+		t.Fatalf("failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+
+	stopWords, err := fts.GetStopWords(ctx)
+	if err != nil {
+		t.Fatalf("failed to get stop words: %v", err)
+	}
+	if len(stopWords) != 0 {
+		t.Errorf("expected no stop words, got %v", stopWords)
+	}
+
+	results, err := fts.Find(ctx, "the")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("expected 2 results for \"the\", got %d", len(results))
+	}
+
+	if err := fts.UpdateStopWords(ctx, []string{"the"}); err != nil {
+		t.Fatalf("failed to update stop words: %v", err)
+	}
+	stopWords, err = fts.GetStopWords(ctx)
+	if err != nil {
+		t.Fatalf("failed to get stop words: %v", err)
+	}
+	if len(stopWords) != 1 || stopWords[0] != "the" {
+		t.Errorf("expected [the], got %v", stopWords)
+	}
+
+	// 未重建前停用词仍在索引中
+	if n := indexedTermCount(t, fts, "the"); n != 2 {
+		t.Errorf("expected \"the\" to remain indexed before reindex, got %d docs", n)
+	}
+
+	if err := fts.Reindex(ctx); err != nil {
+		t.Fatalf("failed to reindex: %v", err)
+	}
+	if n := indexedTermCount(t, fts, "the"); n != 0 {
+		t.Errorf("expected \"the\" not to be indexed after reindex, got %d docs", n)
+	}
+	results, err = fts.Find(ctx, "the")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no results for stop word, got %d", len(results))
+	}
+	results, err = fts.Find(ctx, "quick")
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("expected 2 results for \"quick\", got %d", len(results))
+	}
+
+	// 启用 AutoReindexOnConfigChange 时更新停用词立即重建索引
+	auto, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier:                "stopwords-auto",
+		DocToString:               docToString,
+		IndexOptions:              &FulltextIndexOptions{},
+		AutoReindexOnConfigChange: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create fulltext search: %v", err)
+	}
+	defer auto.Close()
+	if err := auto.UpdateStopWords(ctx, []string{"quick"}); err != nil {
+		t.Fatalf("failed to update stop words: %v", err)
+	}
+	if n := indexedTermCount(t, auto, "quick"); n != 0 {
+		t.Errorf("expected \"quick\" not to be indexed after automatic reindex, got %d docs", n)
+	}
+	if n := indexedTermCount(t, auto, "the"); n != 2 {
+		t.Errorf("expected \"the\" to stay indexed, got %d docs", n)
+	}
+}
+
+func TestFulltextSearch_ExportImport(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rxdb-fulltext-export-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "test-fulltext-export",
+		Path: tmpDir,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	docs := []map[string]any{
+		{"id": "1", "content": "golang database tutorial"},
+		{"id": "2", "content": "golang concurrency patterns"},
+		{"id": "3", "content": "rust ownership and borrowing"},
+		{"id": "4", "content": "database indexing with golang"},
+	}
+	source, err := db.Collection(ctx, "source", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	replica, err := db.Collection(ctx, "replica", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	for _, doc := range docs {
+		if _, err := source.Insert(ctx, doc); err != nil {
+			t.Fatalf("failed to insert document: %v", err)
+		}
+		if _, err := replica.Insert(ctx, DeepCloneMap(doc)); err != nil {
+			t.Fatalf("failed to insert document: %v", err)
+		}
+	}
+
+	sourceFTS, err := AddFulltextSearch(source, FulltextSearchConfig{
+		Identifier: "content",
+		DocToString: func(doc map[string]any) string {
+			content, _ := doc["content"].(string)
+			return content
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create fulltext search: %v", err)
+	}
+	defer sourceFTS.Close()
+	if err := sourceFTS.AddDocument(ctx, "ext", "external golang notes"); err != nil {
+		t.Fatalf("failed to add external document: %v", err)
+	}
+
+	// 副本自身不索引任何内容，查询结果只能来自导入的索引
+	replicaFTS, err := AddFulltextSearch(replica, FulltextSearchConfig{
+		Identifier:  "content",
+		DocToString: func(doc map[string]any) string { return "" },
+	})
+	if err != nil {
+		t.Fatalf("failed to create fulltext search: %v", err)
+	}
+	defer replicaFTS.Close()
+	if replicaFTS.Count() != 0 {
+		t.Fatalf("expected empty replica index, got %d documents", replicaFTS.Count())
+	}
+
+	data, err := sourceFTS.Export(ctx)
+	if err != nil {
+		t.Fatalf("failed to export index: %v", err)
+	}
+	if err := replicaFTS.Import(ctx, data); err != nil {
+		t.Fatalf("failed to import index: %v", err)
+	}
+	if replicaFTS.Count() != sourceFTS.Count() {
+		t.Errorf("expected %d documents after import, got %d", sourceFTS.Count(), replicaFTS.Count())
+	}
+
+	for _, q := range []string{"golang", "database", "rust ownership", "concurrency"} {
+		want, err := sourceFTS.FindWithScores(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to search source: %v", err)
+		}
+		got, err := replicaFTS.FindWithScores(ctx, q)
+		if err != nil {
+			t.Fatalf("failed to search replica: %v", err)
+		}
+		if len(got) != len(want) || len(want) == 0 {
+			t.Fatalf("query %q: expected %d results, got %d", q, len(want), len(got))
+		}
+		for i := range want {
+			wantID, gotID := want[i].ExternalID, got[i].ExternalID
+			if want[i].Document != nil {
+				wantID = want[i].Document.ID()
+			}
+			if got[i].Document != nil {
+				gotID = got[i].Document.ID()
+			}
+			if wantID != gotID || want[i].Score != got[i].Score {
+				t.Errorf("query %q result %d: expected %s (%f), got %s (%f)", q, i, wantID, want[i].Score, gotID, got[i].Score)
+			}
+		}
+	}
+
+	if err := replicaFTS.Import(ctx, []byte("not a zip archive")); err == nil {
+		t.Error("expected error for invalid index data")
+	}
+	if replicaFTS.Count() != sourceFTS.Count() {
+		t.Errorf("failed import should keep the current index, got %d documents", replicaFTS.Count())
+	}
+}
+
+func TestQuery_TextOperator(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "posts", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	// $text 在未添加全文搜索时返回错误，而不是退化为扫描
+	textSelector := map[string]any{
+		"$text":  map[string]any{"$search": "golang concurrency"},
+		"status": "published",
+	}
+	if _, err := coll.Find(textSelector).Exec(ctx); !IsValidationError(err) {
+		t.Fatalf("expected validation error without fulltext search, got %v", err)
+	}
+
+	posts := []map[string]any{
+		{"id": "p1", "status": "published", "content": "golang concurrency patterns with golang channels and concurrency primitives"},
+		{"id": "p2", "status": "published", "content": "an introduction to golang"},
+		{"id": "p3", "status": "draft", "content": "golang concurrency deep dive"},
+		{"id": "p4", "status": "published", "content": "baking sourdough bread at home"},
+		{"id": "p5", "status": "published", "content": "concurrency in databases"},
+	}
+	for _, doc := range posts {
+		if _, err := coll.Insert(ctx, doc); err != nil {
+			t.Fatalf("failed to insert document: %v", err)
+		}
+	}
+
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier: "posts-text",
+		DocToString: func(doc map[string]any) string {
+			content, _ := doc["content"].(string)
+			return content
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create fulltext search: %v", err)
+	}
+
+	// 全文结果与结构化条件取交集，未指定排序时按相关性降序
+	results, err := coll.Find(textSelector).ExecWithScores(ctx)
+	if err != nil {
+		t.Fatalf("failed to execute $text query: %v", err)
+	}
+	var ids []string
+	for i, r := range results {
+		ids = append(ids, r.Document.ID())
+		if r.Score <= 0 || r.Score > 1 {
+			t.Errorf("expected score in (0, 1] for %s, got %f", r.Document.ID(), r.Score)
+		}
+		if i > 0 && r.Score > results[i-1].Score {
+			t.Errorf("expected results ordered by score, got %v", ids)
+		}
+		if _, ok := r.Document.Data()[TextScoreField]; ok {
+			t.Errorf("expected %s not to be written into the document", TextScoreField)
+		}
+	}
+	if len(ids) != 3 || ids[0] != "p1" {
+		t.Fatalf("expected p1 first among [p1 p2 p5], got %v", ids)
+	}
+	for _, id := range ids {
+		if id == "p3" || id == "p4" {
+			t.Errorf("unexpected result %s", id)
+		}
+	}
+
+	// $score 伪字段可用于排序
+	docs, err := coll.Find(textSelector).OrderBy(TextScoreField, false).Exec(ctx)
+	if err != nil {
+		t.Fatalf("failed to execute $text query: %v", err)
+	}
+	if len(docs) != 3 || docs[2].ID() != "p1" {
+		t.Errorf("expected ascending score order ending with p1, got %d results", len(docs))
+	}
+
+	count, err := coll.Find(textSelector).Count(ctx)
+	if err != nil {
+		t.Fatalf("failed to count $text query: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("expected count 3, got %d", count)
+	}
+
+	// 无匹配时返回空结果
+	docs, err = coll.Find(map[string]any{"$text": map[string]any{"$search": "kubernetes"}}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("failed to execute $text query: %v", err)
+	}
+	if len(docs) != 0 {
+		t.Errorf("expected no results, got %d", len(docs))
+	}
+
+	// 嵌套的 $text 与格式错误的 $text 返回错误
+	nested := map[string]any{"$or": []any{map[string]any{"$text": map[string]any{"$search": "golang"}}}}
+	if _, err := coll.Find(nested).Exec(ctx); !IsValidationError(err) {
+		t.Errorf("expected validation error for nested $text, got %v", err)
+	}
+	if _, err := coll.Find(map[string]any{"$text": "golang"}).Exec(ctx); !IsValidationError(err) {
+		t.Errorf("expected validation error for malformed $text, got %v", err)
+	}
+	if _, err := coll.Find(map[string]any{"status": "published"}).ExecWithScores(ctx); !IsValidationError(err) {
+		t.Errorf("expected validation error for ExecWithScores without $text, got %v", err)
+	}
+
+	// 关闭全文搜索后 $text 不再可用
+	fts.Close()
+	if _, err := coll.Find(textSelector).Exec(ctx); !IsValidationError(err) {
+		t.Errorf("expected validation error after closing fulltext search, got %v", err)
+	}
+}
+
+func TestSchema_FulltextIndexes(t *testing.T) {
+	ctx := context.Background()
+	dbPath := t.TempDir()
+	schema := Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		FulltextIndexes: []FulltextIndexDeclaration{
+			{Fields: []string{"title", "meta.tags"}, Config: FulltextSearchConfig{Identifier: "articles"}},
+		},
+	}
+	openDB := func() (Database, Collection) {
+		t.Helper()
+		db, err := CreateDatabase(ctx, DatabaseOptions{Name: "fts-schema", Path: dbPath})
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		coll, err := db.Collection(ctx, "articles", schema)
+		if err != nil {
+			db.Close(ctx)
+			t.Fatalf("Failed to open collection: %v", err)
+		}
+		return db, coll
+	}
+	searchIDs := func(coll Collection, q string) []string {
+		t.Helper()
+		fts, err := coll.GetFulltextIndex("articles")
+		if err != nil {
+			t.Fatalf("GetFulltextIndex failed: %v", err)
+		}
+		docs, err := fts.Find(ctx, q)
+		if err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		ids := make([]string, len(docs))
+		for i, doc := range docs {
+			ids[i] = doc.ID()
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	// 自动同步在后台 goroutine 中处理变更事件，等待索引追上写入
+	waitForIDs := func(coll Collection, q string, want []string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			ids := searchIDs(coll, q)
+			if reflect.DeepEqual(ids, want) {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Errorf("Search %q: expected %v, got %v", q, want, ids)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	db, coll := openDB()
+	if _, err := coll.Insert(ctx, map[string]any{"id": "a1", "title": "Go concurrency patterns", "meta": map[string]any{"tags": []any{"golang"}}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	// 索引自动同步集合变更
+	waitForIDs(coll, "concurrency", []string{"a1"})
+	waitForIDs(coll, "golang", []string{"a1"})
+	if _, err := coll.GetFulltextIndex("missing"); !IsNotFoundError(err) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+	if err := db.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// 重新打开数据库后索引自动恢复，无需再调用 AddFulltextSearch
+	db, coll = openDB()
+	defer db.Close(ctx)
+	if ids := searchIDs(coll, "concurrency"); !reflect.DeepEqual(ids, []string{"a1"}) {
+		t.Errorf("Expected restored index to find a1, got %v", ids)
+	}
+	if _, err := coll.Insert(ctx, map[string]any{"id": "a2", "title": "Rust concurrency"}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if err := coll.Remove(ctx, "a1"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	waitForIDs(coll, "concurrency", []string{"a2"})
+	results, err := coll.Find(map[string]any{"$text": map[string]any{"$search": "rust", "$index": "articles"}}).Exec(ctx)
+	if err != nil || len(results) != 1 || results[0].ID() != "a2" {
+		t.Errorf("Expected $text query to use the declared index, got %d results (%v)", len(results), err)
+	}
+
+	// 声明不合法时打开集合失败
+	if _, err := db.Collection(ctx, "invalid", Schema{FulltextIndexes: []FulltextIndexDeclaration{{Config: FulltextSearchConfig{Identifier: "x"}}}}); err == nil {
+		t.Error("Expected error for declaration without fields")
+	}
+}
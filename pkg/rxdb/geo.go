@@ -0,0 +1,510 @@
+package rxdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	bstore "github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
+)
+
+// IndexTypeGeo 地理空间索引类型，索引字段需为 GeoJSON Point：
+//
+//	{"type": "Point", "coordinates": [lon, lat]}
+//
+// 索引按 geohash 单元的层级前缀组织，$near 与 $geoWithin 查询通过扫描覆盖查询范围的单元获取候选文档。
+const IndexTypeGeo = "geo"
+
+const (
+	// geohashPrecision 索引键中 geohash 的长度（约 3.7cm × 1.9cm）
+	geohashPrecision = 12
+	// geoMaxCoverCells 查询时覆盖范围最多使用的 geohash 单元数
+	geoMaxCoverCells = 32
+	// earthRadiusMeters 地球平均半径（米）
+	earthRadiusMeters = 6371008.8
+)
+
+const geohashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// geoPoint 经纬度坐标（度）。
+type geoPoint struct {
+	Lon, Lat float64
+}
+
+// parseGeoPoint 解析 GeoJSON Point，也接受 [lon, lat] 形式的坐标数组。
+func parseGeoPoint(v any) (geoPoint, bool) {
+	if m, ok := v.(map[string]any); ok {
+		if t, _ := m["type"].(string); t != "Point" {
+			return geoPoint{}, false
+		}
+		v = m["coordinates"]
+	}
+	coords, ok := parseGeoCoordinates(v)
+	if !ok {
+		return geoPoint{}, false
+	}
+	if coords.Lon < -180 || coords.Lon > 180 || coords.Lat < -90 || coords.Lat > 90 {
+		return geoPoint{}, false
+	}
+	return coords, true
+}
+
+func parseGeoCoordinates(v any) (geoPoint, bool) {
+	switch arr := v.(type) {
+	case []any:
+		if len(arr) < 2 || !isNumeric(arr[0]) || !isNumeric(arr[1]) {
+			return geoPoint{}, false
+		}
+		return geoPoint{Lon: toFloat64(arr[0]), Lat: toFloat64(arr[1])}, true
+	case []float64:
+		if len(arr) < 2 {
+			return geoPoint{}, false
+		}
+		return geoPoint{Lon: arr[0], Lat: arr[1]}, true
+	}
+	return geoPoint{}, false
+}
+
+// parseGeoPolygon 解析 GeoJSON Polygon 或 MultiPolygon，返回多边形列表，
+// 每个多边形的第一个环为外环，其余为内环（洞）。
+func parseGeoPolygon(v any) ([][][]geoPoint, bool) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	t, _ := m["type"].(string)
+	switch t {
+	case "Polygon":
+		polygon, ok := parseGeoRings(m["coordinates"])
+		if !ok {
+			return nil, false
+		}
+		return [][][]geoPoint{polygon}, true
+	case "MultiPolygon":
+		arr, ok := m["coordinates"].([]any)
+		if !ok || len(arr) == 0 {
+			return nil, false
+		}
+		polygons := make([][][]geoPoint, 0, len(arr))
+		for _, item := range arr {
+			polygon, ok := parseGeoRings(item)
+			if !ok {
+				return nil, false
+			}
+			polygons = append(polygons, polygon)
+		}
+		return polygons, true
+	}
+	return nil, false
+}
+
+func parseGeoRings(v any) ([][]geoPoint, bool) {
+	arr, ok := v.([]any)
+	if !ok || len(arr) == 0 {
+		return nil, false
+	}
+	rings := make([][]geoPoint, 0, len(arr))
+	for _, rawRing := range arr {
+		ringArr, ok := rawRing.([]any)
+		if !ok || len(ringArr) < 3 {
+			return nil, false
+		}
+		ring := make([]geoPoint, 0, len(ringArr))
+		for _, rawPoint := range ringArr {
+			p, ok := parseGeoCoordinates(rawPoint)
+			if !ok {
+				return nil, false
+			}
+			ring = append(ring, p)
+		}
+		rings = append(rings, ring)
+	}
+	return rings, true
+}
+
+// haversineDistance 计算两点间的大圆距离（米）。
+func haversineDistance(a, b geoPoint) float64 {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// pointInRing 使用射线法判断点是否在环内（平面近似，环可闭合也可不闭合）。
+func pointInRing(p geoPoint, ring []geoPoint) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// pointInPolygons 判断点是否落在任一多边形内（位于外环内且不在任何洞内）。
+func pointInPolygons(p geoPoint, polygons [][][]geoPoint) bool {
+	for _, polygon := range polygons {
+		if !pointInRing(p, polygon[0]) {
+			continue
+		}
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if pointInRing(p, hole) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// nearSpec 解析后的 $near 查询参数。
+type nearSpec struct {
+	center      geoPoint
+	maxDistance float64 // <= 0 表示不限制
+	minDistance float64
+}
+
+// parseNearSpec 解析 {"$geometry": Point, "$maxDistance": 米, "$minDistance": 米}。
+func parseNearSpec(v any) (nearSpec, bool) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nearSpec{}, false
+	}
+	center, ok := parseGeoPoint(m["$geometry"])
+	if !ok {
+		return nearSpec{}, false
+	}
+	spec := nearSpec{center: center}
+	if v, ok := m["$maxDistance"]; ok && isNumeric(v) {
+		spec.maxDistance = toFloat64(v)
+	}
+	if v, ok := m["$minDistance"]; ok && isNumeric(v) {
+		spec.minDistance = toFloat64(v)
+	}
+	return spec, true
+}
+
+// matchNear 检查文档中的点是否满足 $near 的距离范围。
+func matchNear(docValue any, opValue any) bool {
+	spec, ok := parseNearSpec(opValue)
+	if !ok {
+		return false
+	}
+	p, ok := parseGeoPoint(docValue)
+	if !ok {
+		return false
+	}
+	d := haversineDistance(spec.center, p)
+	if spec.maxDistance > 0 && d > spec.maxDistance {
+		return false
+	}
+	return d >= spec.minDistance
+}
+
+// matchGeoWithin 检查文档中的点是否落在 {"$geometry": Polygon|MultiPolygon} 内。
+func matchGeoWithin(docValue any, opValue any) bool {
+	m, ok := opValue.(map[string]any)
+	if !ok {
+		return false
+	}
+	polygons, ok := parseGeoPolygon(m["$geometry"])
+	if !ok {
+		return false
+	}
+	p, ok := parseGeoPoint(docValue)
+	if !ok {
+		return false
+	}
+	return pointInPolygons(p, polygons)
+}
+
+// geohashBits 返回给定精度下经度与纬度的编码位数。
+func geohashBits(precision int) (lonBits, latBits int) {
+	bits := precision * 5
+	return (bits + 1) / 2, bits / 2
+}
+
+// encodeGeohash 将坐标编码为指定长度的 geohash。
+func encodeGeohash(p geoPoint, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+	var sb strings.Builder
+	sb.Grow(precision)
+	bit, ch, even := 0, 0, true
+	for sb.Len() < precision {
+		if even {
+			mid := (minLon + maxLon) / 2
+			if p.Lon >= mid {
+				ch |= 1 << (4 - bit)
+				minLon = mid
+			} else {
+				maxLon = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if p.Lat >= mid {
+				ch |= 1 << (4 - bit)
+				minLat = mid
+			} else {
+				maxLat = mid
+			}
+		}
+		even = !even
+		if bit < 4 {
+			bit++
+		} else {
+			sb.WriteByte(geohashBase32[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return sb.String()
+}
+
+// geohashCover 返回覆盖经纬度矩形的 geohash 单元列表，单元数不超过 geoMaxCoverCells。
+// 矩形跨越反子午线时返回 false，调用方应回退到全表扫描。
+func geohashCover(minLon, minLat, maxLon, maxLat float64) ([]string, bool) {
+	if minLon < -180 || maxLon > 180 || minLon > maxLon {
+		return nil, false
+	}
+	minLat = math.Max(minLat, -90)
+	maxLat = math.Min(maxLat, 90)
+
+	for precision := geohashPrecision; precision >= 1; precision-- {
+		lonBits, latBits := geohashBits(precision)
+		cellW := 360 / math.Pow(2, float64(lonBits))
+		cellH := 180 / math.Pow(2, float64(latBits))
+		x0 := int(math.Floor((minLon + 180) / cellW))
+		x1 := int(math.Floor((maxLon + 180) / cellW))
+		y0 := int(math.Floor((minLat + 90) / cellH))
+		y1 := int(math.Floor((maxLat + 90) / cellH))
+		if (x1-x0+1)*(y1-y0+1) > geoMaxCoverCells {
+			continue
+		}
+
+		cells := make([]string, 0, (x1-x0+1)*(y1-y0+1))
+		seen := make(map[string]bool, cap(cells))
+		for x := x0; x <= x1; x++ {
+			for y := y0; y <= y1; y++ {
+				// 以单元中心点编码，得到该单元的 geohash
+				center := geoPoint{
+					Lon: math.Min(-180+(float64(x)+0.5)*cellW, 180),
+					Lat: math.Min(-90+(float64(y)+0.5)*cellH, 90),
+				}
+				// 边界恰为 180/90 时会落回最后一个单元，需要去重
+				if cell := encodeGeohash(center, precision); !seen[cell] {
+					seen[cell] = true
+					cells = append(cells, cell)
+				}
+			}
+		}
+		return cells, true
+	}
+	return nil, false
+}
+
+// nearBoundingBox 返回以 center 为圆心、radius 米为半径的圆的外接经纬度矩形。
+func nearBoundingBox(center geoPoint, radius float64) (minLon, minLat, maxLon, maxLat float64) {
+	dLat := radius / earthRadiusMeters * 180 / math.Pi
+	minLat, maxLat = center.Lat-dLat, center.Lat+dLat
+	if minLat <= -90 || maxLat >= 90 {
+		// 覆盖极点时经度范围为全部
+		return -180, math.Max(minLat, -90), 180, math.Min(maxLat, 90)
+	}
+	dLon := dLat / math.Cos(center.Lat*math.Pi/180)
+	return center.Lon - dLon, minLat, center.Lon + dLon, maxLat
+}
+
+// polygonBoundingBox 返回多边形外环的外接经纬度矩形。
+func polygonBoundingBox(polygons [][][]geoPoint) (minLon, minLat, maxLon, maxLat float64) {
+	minLon, minLat = math.Inf(1), math.Inf(1)
+	maxLon, maxLat = math.Inf(-1), math.Inf(-1)
+	for _, polygon := range polygons {
+		for _, p := range polygon[0] {
+			minLon, maxLon = math.Min(minLon, p.Lon), math.Max(maxLon, p.Lon)
+			minLat, maxLat = math.Min(minLat, p.Lat), math.Max(maxLat, p.Lat)
+		}
+	}
+	return minLon, minLat, maxLon, maxLat
+}
+
+// validateIndex 校验索引定义。
+func validateIndex(index Index) error {
+	if len(index.Fields) == 0 {
+		return fmt.Errorf("index must have at least one field")
+	}
+	switch index.Type {
+	case "":
+	case IndexTypeGeo:
+		if len(index.Fields) != 1 {
+			return fmt.Errorf("geo index must have exactly one field")
+		}
+	default:
+		return fmt.Errorf("unsupported index type: %s", index.Type)
+	}
+	return nil
+}
+
+// indexEntryKeys 计算文档在索引中的键（不含存储桶前缀）。
+// 字段路径经过数组时（如 "orders[*].sku"）为每个元素值各生成一个键（多键索引），
+// 多个字段均展开时取各字段值的组合。
+// 地理索引字段不是合法的 GeoJSON Point 时返回 nil，该文档不写入索引。
+func indexEntryKeys(idx Index, doc map[string]any, docID string) [][]byte {
+	if idx.Type == IndexTypeGeo {
+		p, ok := parseGeoPoint(getNestedValue(doc, idx.Fields[0]))
+		if !ok {
+			return nil
+		}
+		key := make([]byte, 0, geohashPrecision+1+len(docID))
+		key = append(key, encodeGeohash(p, geohashPrecision)...)
+		key = append(key, 0x00)
+		key = append(key, docID...)
+		return [][]byte{key}
+	}
+
+	combos := [][]any{make([]any, 0, len(idx.Fields))}
+	for _, field := range idx.Fields {
+		values := indexFieldValues(doc, field)
+		next := make([][]any, 0, len(combos)*len(values))
+		for _, combo := range combos {
+			for _, v := range values {
+				parts := make([]any, len(combo), len(combo)+1)
+				copy(parts, combo)
+				next = append(next, append(parts, v))
+			}
+		}
+		combos = next
+	}
+
+	keys := make([][]byte, len(combos))
+	for i, combo := range combos {
+		keys[i] = encodeIndexKey(combo, docID)
+	}
+	return keys
+}
+
+// indexFieldValues 返回文档在索引字段上的取值；路径经过数组时返回去重后的全部元素值。
+func indexFieldValues(doc map[string]any, field string) []any {
+	parts := splitQueryPath(field)
+	value := getNestedValueByParts(doc, parts)
+	if value != nil || len(parts) < 2 {
+		return []any{value}
+	}
+	values, fanned := getNestedValuesByParts(doc, parts)
+	if !fanned || len(values) == 0 {
+		return []any{nil}
+	}
+	seen := make(map[string]struct{}, len(values))
+	unique := make([]any, 0, len(values))
+	for _, v := range values {
+		encoded, _ := json.Marshal(v)
+		if _, ok := seen[string(encoded)]; ok {
+			continue
+		}
+		seen[string(encoded)] = struct{}{}
+		unique = append(unique, v)
+	}
+	return unique
+}
+
+// findNearSpec 返回选择器顶层字段上的 $near 条件。
+func (q *Query) findNearSpec() (string, nearSpec, bool) {
+	for field, value := range q.selector {
+		if strings.HasPrefix(field, "$") {
+			continue
+		}
+		ops, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+		if near, ok := ops["$near"]; ok {
+			if spec, ok := parseNearSpec(near); ok {
+				return field, spec, true
+			}
+		}
+	}
+	return "", nearSpec{}, false
+}
+
+// tryUseGeoIndex 对带有 $near（需 $maxDistance）或 $geoWithin 条件且建有地理索引的字段，
+// 扫描覆盖查询范围的 geohash 单元获取候选文档 ID。
+func (q *Query) tryUseGeoIndex(ctx context.Context) ([]string, bool) {
+	for _, idx := range q.collection.schema.Indexes {
+		if idx.Type != IndexTypeGeo {
+			continue
+		}
+		ops, ok := q.selector[idx.Fields[0]].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		var cells []string
+		var covered bool
+		if near, ok := ops["$near"]; ok {
+			spec, ok := parseNearSpec(near)
+			if !ok || spec.maxDistance <= 0 {
+				continue
+			}
+			cells, covered = geohashCover(nearBoundingBox(spec.center, spec.maxDistance))
+		} else if within, ok := ops["$geoWithin"].(map[string]any); ok {
+			polygons, ok := parseGeoPolygon(within["$geometry"])
+			if !ok {
+				continue
+			}
+			cells, covered = geohashCover(polygonBoundingBox(polygons))
+		}
+		if !covered {
+			continue
+		}
+
+		indexName := idx.Name
+		if indexName == "" {
+			indexName = strings.Join(idx.Fields, "_")
+		}
+		bucketName := fmt.Sprintf("%s_idx_%s", q.collection.name, indexName)
+
+		var docIDs []string
+		for _, cell := range cells {
+			err := q.collection.store.IterateRawPrefix(ctx, bstore.BucketKey(bucketName, cell), func(key, value []byte) error {
+				if id := decodeIndexKey(key); id != "" {
+					docIDs = append(docIDs, strings.Clone(id))
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, false
+			}
+		}
+		return docIDs, true
+	}
+	return nil, false
+}
+
+// sortByNear 在未指定排序时，按与 $near 中心点的距离升序排列结果。
+func (q *Query) sortByNear(results []map[string]any) {
+	field, spec, ok := q.findNearSpec()
+	if !ok {
+		return
+	}
+	parts := splitQueryPath(field)
+	distance := func(doc map[string]any) float64 {
+		p, ok := parseGeoPoint(getNestedValueByParts(doc, parts))
+		if !ok {
+			return math.Inf(1)
+		}
+		return haversineDistance(spec.center, p)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return distance(results[i]) < distance(results[j])
+	})
+}
@@ -4,23 +4,19 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"github.com/mozhou-tech/rxdb-go/pkg/graph/cayley"
-	"github.com/sirupsen/logrus"
 )
 
 // initGraph 初始化图数据库
 func (d *database) initGraph(ctx context.Context, opts *GraphOptions) error {
 	if opts == nil || !opts.Enabled {
-		logrus.Debug("[Graph] initGraph: graph database disabled")
+		d.logger.Debug("[Graph] initGraph: graph database disabled")
 		return nil
 	}
 
-	logrus.WithFields(logrus.Fields{
-		"backend":  opts.Backend,
-		"path":     opts.Path,
-		"autoSync": opts.AutoSync,
-	}).Info("[Graph] initGraph: initializing graph database")
+	d.logger.Info("[Graph] initGraph: initializing graph database", "backend", opts.Backend, "path", opts.Path, "autoSync", opts.AutoSync)
 
 	// 设置默认后端
 	backend := opts.Backend
@@ -40,11 +36,7 @@ func (d *database) initGraph(ctx context.Context, opts *GraphOptions) error {
 		Path:    path,
 	})
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"backend": opts.Backend,
-			"path":    opts.Path,
-			"error":   err,
-		}).Error("[Graph] initGraph: failed to create graph client")
+		d.logger.Error("[Graph] initGraph: failed to create graph client", "backend", opts.Backend, "path", opts.Path, "error", err)
 		return fmt.Errorf("failed to create graph client: %w", err)
 	}
 
@@ -54,11 +46,11 @@ func (d *database) initGraph(ctx context.Context, opts *GraphOptions) error {
 	}
 
 	d.graphClient = graphDB
-	logrus.Info("[Graph] initGraph: graph database client created successfully")
+	d.logger.Info("[Graph] initGraph: graph database client created successfully")
 
 	// 如果启用自动同步，创建桥接
 	if opts.AutoSync {
-		logrus.Info("[Graph] initGraph: creating bridge for auto-sync")
+		d.logger.Info("[Graph] initGraph: creating bridge for auto-sync")
 		// 创建适配器以匹配 cayley.Database 接口
 		dbAdapter := &databaseAdapter{db: d}
 		bridge := cayley.NewBridge(dbAdapter, client)
@@ -68,13 +60,13 @@ func (d *database) initGraph(ctx context.Context, opts *GraphOptions) error {
 
 		// 启动自动同步
 		if err := bridge.StartAutoSync(ctx); err != nil {
-			logrus.WithError(err).Error("[Graph] initGraph: failed to start auto sync")
+			d.logger.Error("[Graph] initGraph: failed to start auto sync", "error", err)
 			return fmt.Errorf("failed to start graph auto sync: %w", err)
 		}
-		logrus.Info("[Graph] initGraph: auto-sync started successfully")
+		d.logger.Info("[Graph] initGraph: auto-sync started successfully")
 	}
 
-	logrus.Info("[Graph] initGraph: graph database initialized successfully")
+	d.logger.Info("[Graph] initGraph: graph database initialized successfully")
 	return nil
 }
 
@@ -117,10 +109,131 @@ func (g *graphDatabase) Query() GraphQuery {
 	return &graphQueryImpl{query: cayley.NewQuery(g.client)}
 }
 
+func (g *graphDatabase) Recommend(ctx context.Context, nodeID string, hops int, limit int) ([]string, error) {
+	return g.client.Recommend(ctx, nodeID, hops, limit)
+}
+
+func (g *graphDatabase) Stats(ctx context.Context) (*GraphStats, error) {
+	nodes, edges, err := g.client.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &GraphStats{NodeCount: nodes, EdgeCount: edges}, nil
+}
+
+func (g *graphDatabase) MergeNode(ctx context.Context, id string, attrs map[string]any) error {
+	return g.client.MergeNode(ctx, id, attrs)
+}
+
+func (g *graphDatabase) GetNode(ctx context.Context, id string) (map[string]any, error) {
+	return g.client.GetNode(ctx, id)
+}
+
+func (g *graphDatabase) GetEdges(ctx context.Context, nodeID string) ([]GraphEdge, error) {
+	quads, err := g.client.OutEdges(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphEdges(quads), nil
+}
+
+func (g *graphDatabase) GetInEdges(ctx context.Context, nodeID string) ([]GraphEdge, error) {
+	quads, err := g.client.InEdges(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	return toGraphEdges(quads), nil
+}
+
+func (g *graphDatabase) DeleteEdge(ctx context.Context, subject, predicate, object string) error {
+	return g.client.Unlink(ctx, subject, predicate, object)
+}
+
+func (g *graphDatabase) DeleteNode(ctx context.Context, nodeID string, cascade bool) error {
+	return g.client.DeleteNode(ctx, nodeID, cascade)
+}
+
+func (g *graphDatabase) GetAllNodes(ctx context.Context) ([]string, error) {
+	return g.client.AllNodes(ctx)
+}
+
+func (g *graphDatabase) PageRank(ctx context.Context, opts PageRankOptions) (map[string]float64, error) {
+	return g.client.PageRank(ctx, cayley.PageRankOptions{
+		DampingFactor: opts.DampingFactor,
+		MaxIterations: opts.MaxIterations,
+		Tolerance:     opts.Tolerance,
+		Relation:      opts.Relation,
+		Undirected:    opts.Undirected,
+		Parallel:      opts.Parallel,
+	})
+}
+
+func (g *graphDatabase) Subgraph(ctx context.Context, seedIDs []string, opts SubgraphOptions) (*GraphSubgraph, error) {
+	nodes, quads, err := g.client.Subgraph(ctx, seedIDs, cayley.SubgraphOptions{
+		MaxDepth:  opts.MaxDepth,
+		Relation:  opts.Relation,
+		MaxNodes:  opts.MaxNodes,
+		Direction: opts.Direction,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &GraphSubgraph{Nodes: nodes, Edges: toGraphEdges(quads)}, nil
+}
+
 func (g *graphDatabase) Close() error {
 	return g.client.Close()
 }
 
+// toGraphEdges 将四元组转换为 GraphEdge
+func toGraphEdges(quads []cayley.QueryResult) []GraphEdge {
+	edges := make([]GraphEdge, len(quads))
+	for i, q := range quads {
+		edges[i] = GraphEdge{Subject: q.Subject, Predicate: q.Predicate, Object: q.Object}
+	}
+	return edges
+}
+
+// ExportDOT 将子图导出为 Graphviz DOT 格式的有向图，边的标签为谓词
+func (s *GraphSubgraph) ExportDOT() string {
+	var b strings.Builder
+	b.WriteString("digraph G {\n")
+	for _, node := range s.Nodes {
+		fmt.Fprintf(&b, "  %s;\n", dotQuote(node))
+	}
+	for _, e := range s.Edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", dotQuote(e.Subject), dotQuote(e.Object), dotQuote(e.Predicate))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// ExportJSON 将子图导出为 {"nodes": [...], "edges": [...]} 结构，可直接 JSON 序列化
+func (s *GraphSubgraph) ExportJSON() map[string]any {
+	nodes := make([]any, len(s.Nodes))
+	for i, node := range s.Nodes {
+		nodes[i] = node
+	}
+	edges := make([]any, len(s.Edges))
+	for i, e := range s.Edges {
+		edge := map[string]any{
+			"subject":   e.Subject,
+			"predicate": e.Predicate,
+			"object":    e.Object,
+		}
+		if e.Properties != nil {
+			edge["properties"] = e.Properties
+		}
+		edges[i] = edge
+	}
+	return map[string]any{"nodes": nodes, "edges": edges}
+}
+
+// dotQuote 返回 DOT 语言的双引号字符串
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
 // graphQueryImpl 实现 GraphQuery 接口
 type graphQueryImpl struct {
 	query *cayley.Query
@@ -414,6 +527,7 @@ func (gb *graphBridgeImpl) AddRelationMapping(mapping *GraphRelationMapping) {
 			Relation:    mapping.Relation,
 			TargetField: mapping.TargetField,
 			AutoLink:    mapping.AutoLink,
+			Weight:      mapping.Weight,
 		})
 	}
 }
@@ -3,6 +3,8 @@ package rxdb
 import (
 	"context"
 	"fmt"
+	"math"
+	"math/rand"
 	"os"
 	"reflect"
 	"sort"
@@ -277,6 +279,94 @@ func TestGraphDatabase_FindPath(t *testing.T) {
 	}
 }
 
+// TestGraphDatabase_Recommend 测试基于图的推荐
+func TestGraphDatabase_Recommend(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_graph_recommend.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "test_recommend",
+		Path: dbPath,
+		GraphOptions: &GraphOptions{
+			Enabled:  true,
+			Backend:  "memory",
+			AutoSync: true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	graphDB := db.Graph()
+
+	// 用户 × 物品 二部图
+	edges := [][3]string{
+		{"alice", "likes", "item1"},
+		{"alice", "likes", "item2"},
+		{"bob", "likes", "item1"},
+		{"bob", "likes", "item2"},
+		{"bob", "likes", "item3"},
+		{"carol", "likes", "item2"},
+		{"carol", "purchased", "item4"},
+	}
+	for _, e := range edges {
+		if err := graphDB.Link(ctx, e[0], e[1], e[2]); err != nil {
+			t.Fatalf("Failed to link: %v", err)
+		}
+	}
+
+	liked := map[string]bool{"item1": true, "item2": true}
+	newItems := func(nodes []string) []string {
+		var items []string
+		for _, n := range nodes {
+			if len(n) > 4 && n[:4] == "item" && !liked[n] {
+				items = append(items, n)
+			}
+		}
+		return items
+	}
+
+	recs, err := graphDB.Recommend(ctx, "alice", 3, 0)
+	if err != nil {
+		t.Fatalf("Failed to recommend: %v", err)
+	}
+	for _, n := range recs {
+		if n == "alice" {
+			t.Error("Recommendations should not contain the starting node")
+		}
+	}
+	// bob 与 alice 共同喜欢两个物品，因此 item3 应排在 item4 前面
+	if got := newItems(recs); !reflect.DeepEqual(got, []string{"item3", "item4"}) {
+		t.Errorf("Expected new items [item3 item4], got %v (all: %v)", got, recs)
+	}
+
+	// 提高 purchased 关系的权重后，item4 应排在 item3 前面
+	db.GraphBridge().AddRelationMapping(&GraphRelationMapping{
+		Collection: "orders",
+		Field:      "items",
+		Relation:   "purchased",
+		Weight:     10,
+	})
+	recs, err = graphDB.Recommend(ctx, "alice", 3, 0)
+	if err != nil {
+		t.Fatalf("Failed to recommend: %v", err)
+	}
+	if got := newItems(recs); !reflect.DeepEqual(got, []string{"item4", "item3"}) {
+		t.Errorf("Expected weighted new items [item4 item3], got %v (all: %v)", got, recs)
+	}
+
+	// limit 限制返回数量
+	recs, err = graphDB.Recommend(ctx, "alice", 3, 2)
+	if err != nil {
+		t.Fatalf("Failed to recommend: %v", err)
+	}
+	if len(recs) != 2 {
+		t.Errorf("Expected 2 recommendations, got %d", len(recs))
+	}
+}
+
 // TestGraphDatabase_Query_V 测试查询 API - V
 func TestGraphDatabase_Query_V(t *testing.T) {
 	ctx := context.Background()
@@ -1086,6 +1176,494 @@ func TestGraphDatabase_DefaultPath(t *testing.T) {
 	}
 }
 
+// TestGraphDatabase_LevelDBPersistence 测试 LevelDB 后端在重启后保留图数据
+func TestGraphDatabase_LevelDBPersistence(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_graph_leveldb.db"
+	defer os.RemoveAll(dbPath)
+
+	opts := DatabaseOptions{
+		Name: "test_graph_leveldb",
+		Path: dbPath,
+		GraphOptions: &GraphOptions{
+			Enabled: true,
+			Backend: "leveldb",
+			Path:    dbPath + "/graph",
+		},
+	}
+
+	db, err := CreateDatabase(ctx, opts)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	graphDB := db.Graph()
+	if graphDB == nil {
+		t.Fatal("Graph database should not be nil")
+	}
+	graphDB.Link(ctx, "user1", "follows", "user2")
+	graphDB.Link(ctx, "user2", "follows", "user3")
+	graphDB.Link(ctx, "user1", "likes", "post1")
+	graphDB.Unlink(ctx, "user1", "likes", "post1")
+
+	if err := db.Close(ctx); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+
+	// 重新打开数据库
+	db, err = CreateDatabase(ctx, opts)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	graphDB = db.Graph()
+	neighbors, err := graphDB.GetNeighbors(ctx, "user1", "follows")
+	if err != nil {
+		t.Fatalf("Failed to get neighbors: %v", err)
+	}
+	if len(neighbors) != 1 || neighbors[0] != "user2" {
+		t.Errorf("Expected neighbors [user2] after restart, got %v", neighbors)
+	}
+
+	liked, err := graphDB.GetNeighbors(ctx, "user1", "likes")
+	if err != nil {
+		t.Fatalf("Failed to get neighbors: %v", err)
+	}
+	if len(liked) != 0 {
+		t.Errorf("Expected removed edge to stay removed, got %v", liked)
+	}
+
+	paths, err := graphDB.FindPath(ctx, "user1", "user3", 5, "follows")
+	if err != nil {
+		t.Fatalf("Failed to find path: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Error("Expected path from user1 to user3 after restart")
+	}
+}
+
+// TestGraphDatabase_MergeNode 测试节点属性的创建与合并
+func TestGraphDatabase_MergeNode(t *testing.T) {
+	ctx := context.Background()
+
+	for _, backend := range []string{"memory", "leveldb"} {
+		t.Run(backend, func(t *testing.T) {
+			dbPath := "../../data/test_graph_merge_node_" + backend + ".db"
+			defer os.RemoveAll(dbPath)
+
+			db, err := CreateDatabase(ctx, DatabaseOptions{
+				Name: "test_merge_node",
+				Path: dbPath,
+				GraphOptions: &GraphOptions{
+					Enabled: true,
+					Backend: backend,
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create database: %v", err)
+			}
+			defer db.Close(ctx)
+
+			graphDB := db.Graph()
+
+			// 不存在的节点
+			attrs, err := graphDB.GetNode(ctx, "alice")
+			if err != nil {
+				t.Fatalf("Failed to get node: %v", err)
+			}
+			if attrs != nil {
+				t.Errorf("Expected nil attributes for missing node, got %v", attrs)
+			}
+
+			// 创建节点
+			err = graphDB.MergeNode(ctx, "alice", map[string]any{
+				"name":        "Alice",
+				"type":        "person",
+				"description": "engineer",
+				"meta":        map[string]any{"source": "doc1", "confidence": 0.8},
+			})
+			if err != nil {
+				t.Fatalf("Failed to merge node: %v", err)
+			}
+
+			// 更新部分属性，nil 删除属性，嵌套对象递归合并
+			err = graphDB.MergeNode(ctx, "alice", map[string]any{
+				"description": "senior engineer",
+				"type":        nil,
+				"meta":        map[string]any{"confidence": 0.9},
+			})
+			if err != nil {
+				t.Fatalf("Failed to merge node: %v", err)
+			}
+
+			attrs, err = graphDB.GetNode(ctx, "alice")
+			if err != nil {
+				t.Fatalf("Failed to get node: %v", err)
+			}
+			expected := map[string]any{
+				"name":        "Alice",
+				"description": "senior engineer",
+				"meta":        map[string]any{"source": "doc1", "confidence": 0.9},
+			}
+			if !reflect.DeepEqual(attrs, expected) {
+				t.Errorf("Expected merged attributes %v, got %v", expected, attrs)
+			}
+
+			// 修改返回值不应影响存储
+			attrs["name"] = "changed"
+			attrs, _ = graphDB.GetNode(ctx, "alice")
+			if attrs["name"] != "Alice" {
+				t.Errorf("Expected stored name to be unchanged, got %v", attrs["name"])
+			}
+		})
+	}
+}
+
+// TestGraphDatabase_EdgesAndDeleteNode 测试边的枚举与删除，以及级联删除节点
+func TestGraphDatabase_EdgesAndDeleteNode(t *testing.T) {
+	ctx := context.Background()
+
+	for _, backend := range []string{"memory", "leveldb"} {
+		t.Run(backend, func(t *testing.T) {
+			dbPath := "../../data/test_graph_edges_" + backend + ".db"
+			defer os.RemoveAll(dbPath)
+
+			db, err := CreateDatabase(ctx, DatabaseOptions{
+				Name: "test_graph_edges",
+				Path: dbPath,
+				GraphOptions: &GraphOptions{
+					Enabled: true,
+					Backend: backend,
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create database: %v", err)
+			}
+			defer db.Close(ctx)
+
+			graphDB := db.Graph()
+			links := [][3]string{
+				{"alice", "follows", "bob"},
+				{"alice", "likes", "carol"},
+				{"bob", "follows", "alice"},
+				{"carol", "follows", "bob"},
+			}
+			for _, l := range links {
+				if err := graphDB.Link(ctx, l[0], l[1], l[2]); err != nil {
+					t.Fatalf("Failed to link: %v", err)
+				}
+			}
+			if err := graphDB.MergeNode(ctx, "dave", map[string]any{"name": "Dave"}); err != nil {
+				t.Fatalf("Failed to merge node: %v", err)
+			}
+			if err := graphDB.MergeNode(ctx, "alice", map[string]any{"name": "Alice"}); err != nil {
+				t.Fatalf("Failed to merge node: %v", err)
+			}
+
+			out, err := graphDB.GetEdges(ctx, "alice")
+			if err != nil {
+				t.Fatalf("Failed to get edges: %v", err)
+			}
+			expectedOut := []GraphEdge{
+				{Subject: "alice", Predicate: "follows", Object: "bob"},
+				{Subject: "alice", Predicate: "likes", Object: "carol"},
+			}
+			if !reflect.DeepEqual(out, expectedOut) {
+				t.Errorf("Expected out edges %v, got %v", expectedOut, out)
+			}
+			in, err := graphDB.GetInEdges(ctx, "bob")
+			if err != nil {
+				t.Fatalf("Failed to get in edges: %v", err)
+			}
+			expectedIn := []GraphEdge{
+				{Subject: "alice", Predicate: "follows", Object: "bob"},
+				{Subject: "carol", Predicate: "follows", Object: "bob"},
+			}
+			if !reflect.DeepEqual(in, expectedIn) {
+				t.Errorf("Expected in edges %v, got %v", expectedIn, in)
+			}
+
+			nodes, err := graphDB.GetAllNodes(ctx)
+			if err != nil {
+				t.Fatalf("Failed to get all nodes: %v", err)
+			}
+			if !reflect.DeepEqual(nodes, []string{"alice", "bob", "carol", "dave"}) {
+				t.Errorf("Unexpected nodes: %v", nodes)
+			}
+
+			// 删除单条边
+			if err := graphDB.DeleteEdge(ctx, "carol", "follows", "bob"); err != nil {
+				t.Fatalf("Failed to delete edge: %v", err)
+			}
+			if in, _ := graphDB.GetInEdges(ctx, "bob"); len(in) != 1 || in[0].Subject != "alice" {
+				t.Errorf("Expected only alice -> bob after delete, got %v", in)
+			}
+
+			// 非级联删除仍有边的节点失败，且不修改图
+			if err := graphDB.DeleteNode(ctx, "alice", false); err == nil {
+				t.Error("Expected error deleting node with edges without cascade")
+			}
+			if attrs, _ := graphDB.GetNode(ctx, "alice"); attrs == nil {
+				t.Error("Expected alice to be kept after failed delete")
+			}
+
+			// 级联删除移除所有出边与入边，不留下孤立的边
+			if err := graphDB.DeleteNode(ctx, "alice", true); err != nil {
+				t.Fatalf("Failed to delete node: %v", err)
+			}
+			results, err := graphDB.Query().V("alice", "bob", "carol").Both().All(ctx)
+			if err != nil {
+				t.Fatalf("Failed to query: %v", err)
+			}
+			for _, r := range results {
+				if r.Subject == "alice" || r.Object == "alice" {
+					t.Errorf("Found orphaned edge %v", r)
+				}
+			}
+			for _, node := range []string{"alice", "bob", "carol"} {
+				out, _ := graphDB.GetEdges(ctx, node)
+				in, _ := graphDB.GetInEdges(ctx, node)
+				for _, e := range append(out, in...) {
+					if e.Subject == "alice" || e.Object == "alice" {
+						t.Errorf("Found orphaned edge %v", e)
+					}
+				}
+			}
+			if attrs, _ := graphDB.GetNode(ctx, "alice"); attrs != nil {
+				t.Errorf("Expected alice attributes to be deleted, got %v", attrs)
+			}
+			stats, err := graphDB.Stats(ctx)
+			if err != nil {
+				t.Fatalf("Failed to get stats: %v", err)
+			}
+			if stats.EdgeCount != 0 {
+				t.Errorf("Expected no edges left, got %d", stats.EdgeCount)
+			}
+			nodes, _ = graphDB.GetAllNodes(ctx)
+			if !reflect.DeepEqual(nodes, []string{"dave"}) {
+				t.Errorf("Expected only dave to remain, got %v", nodes)
+			}
+
+			// 没有边的节点可以非级联删除
+			if err := graphDB.DeleteNode(ctx, "dave", false); err != nil {
+				t.Fatalf("Failed to delete node: %v", err)
+			}
+			if nodes, _ := graphDB.GetAllNodes(ctx); len(nodes) != 0 {
+				t.Errorf("Expected empty graph, got %v", nodes)
+			}
+		})
+	}
+}
+
+// TestGraphDatabase_PageRank 测试 PageRank 计算
+func TestGraphDatabase_PageRank(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_graph_pagerank.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "test_graph_pagerank",
+		Path: dbPath,
+		GraphOptions: &GraphOptions{
+			Enabled: true,
+			Backend: "memory",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	graphDB := db.Graph()
+	link := func(from, relation, to string) {
+		t.Helper()
+		if err := graphDB.Link(ctx, from, relation, to); err != nil {
+			t.Fatalf("Failed to link: %v", err)
+		}
+	}
+	assertSum := func(ranks map[string]float64) {
+		t.Helper()
+		sum := 0.0
+		for _, r := range ranks {
+			sum += r
+		}
+		if math.Abs(sum-1) > 1e-6 {
+			t.Errorf("Expected ranks to sum to 1, got %v", sum)
+		}
+	}
+
+	// 链 A -> B -> C
+	link("A", "chain", "B")
+	link("B", "chain", "C")
+
+	// 无向时 B 连接两个节点，排名最高
+	ranks, err := graphDB.PageRank(ctx, PageRankOptions{Relation: "chain", Undirected: true})
+	if err != nil {
+		t.Fatalf("Failed to compute pagerank: %v", err)
+	}
+	if len(ranks) != 3 || ranks["B"] <= ranks["A"] || ranks["B"] <= ranks["C"] {
+		t.Errorf("Expected B to rank highest, got %v", ranks)
+	}
+	assertSum(ranks)
+
+	// 有向时分数沿边传递：B 高于源头 A，终点 C 累积最多
+	ranks, err = graphDB.PageRank(ctx, PageRankOptions{Relation: "chain"})
+	if err != nil {
+		t.Fatalf("Failed to compute pagerank: %v", err)
+	}
+	if ranks["B"] <= ranks["A"] || ranks["C"] <= ranks["B"] {
+		t.Errorf("Expected A < B < C for directed chain, got %v", ranks)
+	}
+	assertSum(ranks)
+
+	// 环 X -> Y -> Z -> X 收敛到均匀分布
+	link("X", "cycle", "Y")
+	link("Y", "cycle", "Z")
+	link("Z", "cycle", "X")
+	ranks, err = graphDB.PageRank(ctx, PageRankOptions{Relation: "cycle", Tolerance: 1e-9})
+	if err != nil {
+		t.Fatalf("Failed to compute pagerank: %v", err)
+	}
+	if len(ranks) != 3 {
+		t.Fatalf("Expected 3 nodes, got %v", ranks)
+	}
+	for node, r := range ranks {
+		if math.Abs(r-1.0/3) > 1e-6 {
+			t.Errorf("Expected %s to converge to 1/3, got %v", node, r)
+		}
+	}
+
+	// 并发计算与串行结果一致
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		link(fmt.Sprintf("n%d", rng.Intn(300)), "random", fmt.Sprintf("n%d", rng.Intn(300)))
+	}
+	serial, err := graphDB.PageRank(ctx, PageRankOptions{})
+	if err != nil {
+		t.Fatalf("Failed to compute pagerank: %v", err)
+	}
+	parallel, err := graphDB.PageRank(ctx, PageRankOptions{Parallel: true})
+	if err != nil {
+		t.Fatalf("Failed to compute parallel pagerank: %v", err)
+	}
+	if len(serial) != len(parallel) {
+		t.Fatalf("Expected %d nodes in parallel result, got %d", len(serial), len(parallel))
+	}
+	for node, r := range serial {
+		if math.Abs(parallel[node]-r) > 1e-12 {
+			t.Errorf("Node %s: serial %v, parallel %v", node, r, parallel[node])
+		}
+	}
+	assertSum(serial)
+
+	if _, err := graphDB.PageRank(ctx, PageRankOptions{DampingFactor: 1.5}); err == nil {
+		t.Error("Expected error for invalid damping factor")
+	}
+}
+
+// TestGraphDatabase_Subgraph 测试子图提取的深度限制、方向与关系过滤以及导出
+func TestGraphDatabase_Subgraph(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_graph_subgraph.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "test_graph_subgraph",
+		Path: dbPath,
+		GraphOptions: &GraphOptions{
+			Enabled: true,
+			Backend: "memory",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	graphDB := db.Graph()
+	// a -> b -> c -> d，e -> a，a -likes-> f
+	for _, l := range [][3]string{
+		{"a", "follows", "b"},
+		{"b", "follows", "c"},
+		{"c", "follows", "d"},
+		{"e", "follows", "a"},
+		{"a", "likes", "f"},
+	} {
+		if err := graphDB.Link(ctx, l[0], l[1], l[2]); err != nil {
+			t.Fatalf("Failed to link: %v", err)
+		}
+	}
+
+	sortedNodes := func(sg *GraphSubgraph) []string {
+		nodes := append([]string(nil), sg.Nodes...)
+		sort.Strings(nodes)
+		return nodes
+	}
+	cases := []struct {
+		name  string
+		opts  SubgraphOptions
+		nodes []string
+		edges int
+	}{
+		{"out depth 1", SubgraphOptions{Direction: "out", MaxDepth: 1}, []string{"a", "b", "f"}, 2},
+		{"out depth 2", SubgraphOptions{Direction: "out", MaxDepth: 2}, []string{"a", "b", "c", "f"}, 3},
+		{"out depth 3 relation", SubgraphOptions{Direction: "out", MaxDepth: 3, Relation: "follows"}, []string{"a", "b", "c", "d"}, 3},
+		{"in", SubgraphOptions{Direction: "in", MaxDepth: 3}, []string{"a", "e"}, 1},
+		{"both depth 1", SubgraphOptions{MaxDepth: 1}, []string{"a", "b", "e", "f"}, 3},
+		{"max nodes", SubgraphOptions{Direction: "out", MaxDepth: 3, MaxNodes: 2}, []string{"a", "b"}, 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sg, err := graphDB.Subgraph(ctx, []string{"a"}, tc.opts)
+			if err != nil {
+				t.Fatalf("Failed to extract subgraph: %v", err)
+			}
+			if sg.Nodes[0] != "a" {
+				t.Errorf("Expected seed first, got %v", sg.Nodes)
+			}
+			if got := sortedNodes(sg); !reflect.DeepEqual(got, tc.nodes) {
+				t.Errorf("Expected nodes %v, got %v", tc.nodes, got)
+			}
+			if len(sg.Edges) != tc.edges {
+				t.Errorf("Expected %d edges, got %v", tc.edges, sg.Edges)
+			}
+			inSubgraph := make(map[string]bool)
+			for _, n := range sg.Nodes {
+				inSubgraph[n] = true
+			}
+			for _, e := range sg.Edges {
+				if !inSubgraph[e.Subject] || !inSubgraph[e.Object] {
+					t.Errorf("Edge %v leaves the subgraph", e)
+				}
+				if tc.opts.Relation != "" && e.Predicate != tc.opts.Relation {
+					t.Errorf("Edge %v does not match relation %s", e, tc.opts.Relation)
+				}
+			}
+		})
+	}
+
+	sg, err := graphDB.Subgraph(ctx, []string{"c"}, SubgraphOptions{Direction: "out"})
+	if err != nil {
+		t.Fatalf("Failed to extract subgraph: %v", err)
+	}
+	expectedDOT := "digraph G {\n  \"c\";\n  \"d\";\n  \"c\" -> \"d\" [label=\"follows\"];\n}\n"
+	if dot := sg.ExportDOT(); dot != expectedDOT {
+		t.Errorf("Unexpected DOT output:\n%s", dot)
+	}
+	expectedJSON := map[string]any{
+		"nodes": []any{"c", "d"},
+		"edges": []any{map[string]any{"subject": "c", "predicate": "follows", "object": "d"}},
+	}
+	if got := sg.ExportJSON(); !reflect.DeepEqual(got, expectedJSON) {
+		t.Errorf("Expected JSON %v, got %v", expectedJSON, got)
+	}
+
+	if _, err := graphDB.Subgraph(ctx, []string{"a"}, SubgraphOptions{Direction: "sideways"}); err == nil {
+		t.Error("Expected error for unsupported direction")
+	}
+}
+
 // TestGraphDatabase_AutoSync 测试自动同步功能
 func TestGraphDatabase_AutoSync(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -19,6 +19,28 @@ type HybridSearchResult struct {
 	// HybridScore 混合搜索综合分数。
 	// 计算公式：FulltextScore * fulltextWeight + VectorScore * vectorWeight
 	HybridScore float64
+	// Explain 各检索方式对该结果的贡献，仅在 HybridSearchOptions.Debug 为 true 时返回。
+	Explain *HybridExplain
+}
+
+// HybridFusionWeightedSum 按权重线性加权两种检索分数的融合策略。
+const HybridFusionWeightedSum = "weighted_sum"
+
+// HybridExplain 混合搜索结果的来源明细。
+// 指针字段为 nil 表示该文档未被对应的检索方式返回。
+type HybridExplain struct {
+	// FulltextRank 在全文搜索结果中的名次（从 1 开始）。
+	FulltextRank *int
+	// FulltextScore 全文搜索分数。
+	FulltextScore *float64
+	// VectorRank 在向量搜索结果中的名次（从 1 开始）。
+	VectorRank *int
+	// VectorScore 向量搜索相似度分数。
+	VectorScore *float64
+	// VectorDistance 向量搜索距离。
+	VectorDistance *float64
+	// FusionStrategy 计算 HybridScore 使用的融合策略。
+	FusionStrategy string
 }
 
 // HybridSearchOptions 混合搜索选项。
@@ -32,6 +54,8 @@ type HybridSearchOptions struct {
 	// 与 FulltextWeight 一起决定混合分数的计算方式。
 	// 建议 FulltextWeight + VectorWeight = 1.0，但不强制要求。
 	VectorWeight float64
+	// Debug 是否在结果中返回 Explain 明细（会带来少量额外开销）。
+	Debug bool
 }
 
 // PerformHybridSearch 执行混合搜索。
@@ -64,10 +88,22 @@ func PerformHybridSearch(
 	// 合并结果
 	resultMap := make(map[string]*HybridSearchResult)
 
+	// explainFor 在 Debug 模式下返回结果的 Explain，按需创建
+	explainFor := func(result *HybridSearchResult) *HybridExplain {
+		if !options.Debug {
+			return nil
+		}
+		if result.Explain == nil {
+			result.Explain = &HybridExplain{FusionStrategy: HybridFusionWeightedSum}
+		}
+		return result.Explain
+	}
+
 	// 添加全文搜索结果
-	for _, r := range fulltextResults {
+	for i, r := range fulltextResults {
 		docID := r.Document.ID()
-		if existing, ok := resultMap[docID]; ok {
+		existing, ok := resultMap[docID]
+		if ok {
 			// 如果已存在，更新全文搜索分数（取较高值）
 			if r.Score > existing.FulltextScore {
 				existing.FulltextScore = r.Score
@@ -75,32 +111,46 @@ func PerformHybridSearch(
 				existing.HybridScore = existing.FulltextScore*options.FulltextWeight + existing.VectorScore*options.VectorWeight
 			}
 		} else {
-			resultMap[docID] = &HybridSearchResult{
+			existing = &HybridSearchResult{
 				Document:      r.Document,
 				FulltextScore: r.Score,
 				VectorScore:   0,
 				HybridScore:   r.Score * options.FulltextWeight,
 			}
+			resultMap[docID] = existing
+		}
+		if explain := explainFor(existing); explain != nil && explain.FulltextRank == nil {
+			rank, score := i+1, r.Score
+			explain.FulltextRank = &rank
+			explain.FulltextScore = &score
 		}
 	}
 
 	// 添加向量搜索结果
-	for _, r := range vectorResults {
+	for i, r := range vectorResults {
 		docID := r.Document.ID()
-		if existing, ok := resultMap[docID]; ok {
+		existing, ok := resultMap[docID]
+		if ok {
 			// 如果已存在，更新向量搜索分数
 			existing.VectorScore = r.Score
 			existing.VectorDistance = r.Distance
 			// 重新计算混合分数
 			existing.HybridScore = existing.FulltextScore*options.FulltextWeight + r.Score*options.VectorWeight
 		} else {
-			resultMap[docID] = &HybridSearchResult{
+			existing = &HybridSearchResult{
 				Document:       r.Document,
 				FulltextScore:  0,
 				VectorScore:    r.Score,
 				VectorDistance: r.Distance,
 				HybridScore:    r.Score * options.VectorWeight,
 			}
+			resultMap[docID] = existing
+		}
+		if explain := explainFor(existing); explain != nil && explain.VectorRank == nil {
+			rank, score, distance := i+1, r.Score, r.Distance
+			explain.VectorRank = &rank
+			explain.VectorScore = &score
+			explain.VectorDistance = &distance
 		}
 	}
 
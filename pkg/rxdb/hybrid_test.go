@@ -0,0 +1,172 @@
+package rxdb
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestHybridSearch_Explain(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rxdb-hybrid-explain-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "test-hybrid-explain",
+		Path: tmpDir,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	coll, err := db.Collection(ctx, "docs", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+
+	docs := []map[string]any{
+		{"id": "a1", "content": "apple apple apple pie", "x": 0.0, "y": 1.0},
+		{"id": "a2", "content": "apple juice", "x": 0.1, "y": 0.9},
+		{"id": "b1", "content": "banana bread", "x": 1.0, "y": 0.0},
+		{"id": "b2", "content": "banana split", "x": 0.9, "y": 0.1},
+		{"id": "c1", "content": "cherry tart", "x": -1.0, "y": 0.0},
+		{"id": "c2", "content": "cherry jam", "x": -0.9, "y": -0.1},
+	}
+	for _, doc := range docs {
+		if _, err := coll.Insert(ctx, doc); err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+	}
+
+	fts, err := AddFulltextSearch(coll, FulltextSearchConfig{
+		Identifier: "hybrid-fulltext",
+		DocToString: func(doc map[string]any) string {
+			content, _ := doc["content"].(string)
+			return content
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create fulltext search: %v", err)
+	}
+	defer fts.Close()
+
+	vs, err := AddVectorSearch(coll, VectorSearchConfig{
+		Identifier: "hybrid-vector",
+		Dimensions: 2,
+		DocToEmbedding: func(doc map[string]any) (Vector, error) {
+			x, _ := doc["x"].(float64)
+			y, _ := doc["y"].(float64)
+			return Vector{x, y}, nil
+		},
+		DistanceMetric: "cosine",
+	})
+	if err != nil {
+		t.Fatalf("failed to create vector search: %v", err)
+	}
+	defer vs.Close()
+
+	query := "apple"
+	queryVector := Vector{1, 0}
+	options := HybridSearchOptions{
+		Limit:          2,
+		FulltextWeight: 0.1,
+		VectorWeight:   0.9,
+		Debug:          true,
+	}
+
+	// 分别执行两种检索，得到预期的名次
+	fulltextResults, err := fts.FindWithScores(ctx, query, FulltextSearchOptions{Limit: options.Limit * 2})
+	if err != nil {
+		t.Fatalf("failed to run fulltext search: %v", err)
+	}
+	vectorResults, err := vs.Search(ctx, queryVector, VectorSearchOptions{Limit: options.Limit * 2})
+	if err != nil {
+		t.Fatalf("failed to run vector search: %v", err)
+	}
+	fulltextRank := make(map[string]int)
+	for i, r := range fulltextResults {
+		fulltextRank[r.Document.ID()] = i + 1
+	}
+	vectorRank := make(map[string]int)
+	for i, r := range vectorResults {
+		vectorRank[r.Document.ID()] = i + 1
+	}
+	if fulltextRank["a1"] != 1 || fulltextRank["a2"] != 2 || len(fulltextRank) != 2 {
+		t.Fatalf("unexpected fulltext ranking: %v", fulltextRank)
+	}
+	if vectorRank["b1"] != 1 || vectorRank["b2"] != 2 || len(vectorRank) != 4 {
+		t.Fatalf("unexpected vector ranking: %v", vectorRank)
+	}
+
+	results, err := PerformHybridSearch(ctx, fts, vs, query, queryVector, options)
+	if err != nil {
+		t.Fatalf("failed to perform hybrid search: %v", err)
+	}
+	if len(results) != 2 || results[0].Document.ID() != "b1" || results[1].Document.ID() != "b2" {
+		t.Fatalf("expected [b1 b2], got %v", results)
+	}
+
+	for _, r := range results {
+		id := r.Document.ID()
+		explain := r.Explain
+		if explain == nil {
+			t.Fatalf("%s: expected explain in debug mode", id)
+		}
+		if explain.FusionStrategy != HybridFusionWeightedSum {
+			t.Errorf("%s: fusion strategy = %q", id, explain.FusionStrategy)
+		}
+		if explain.FulltextRank != nil || explain.FulltextScore != nil {
+			t.Errorf("%s: was not returned by fulltext search, got rank %v", id, explain.FulltextRank)
+		}
+		if explain.VectorRank == nil || *explain.VectorRank != vectorRank[id] {
+			t.Errorf("%s: vector rank = %v, want %d", id, explain.VectorRank, vectorRank[id])
+		}
+		if explain.VectorScore == nil || *explain.VectorScore != r.VectorScore {
+			t.Errorf("%s: vector score = %v, want %v", id, explain.VectorScore, r.VectorScore)
+		}
+		if explain.VectorDistance == nil || *explain.VectorDistance != r.VectorDistance {
+			t.Errorf("%s: vector distance = %v, want %v", id, explain.VectorDistance, r.VectorDistance)
+		}
+	}
+
+	// 放宽数量限制后，a1 同时被全文与向量搜索返回
+	options.Limit = 10
+	results, err = PerformHybridSearch(ctx, fts, vs, query, queryVector, options)
+	if err != nil {
+		t.Fatalf("failed to perform hybrid search: %v", err)
+	}
+	var a1 *HybridSearchResult
+	for i := range results {
+		if results[i].Document.ID() == "a1" {
+			a1 = &results[i]
+		}
+	}
+	if a1 == nil || a1.Explain == nil {
+		t.Fatalf("expected a1 with explain in results")
+	}
+	if a1.Explain.FulltextRank == nil || *a1.Explain.FulltextRank != 1 {
+		t.Errorf("a1: fulltext rank = %v, want 1", a1.Explain.FulltextRank)
+	}
+	if a1.Explain.FulltextScore == nil || *a1.Explain.FulltextScore != a1.FulltextScore {
+		t.Errorf("a1: fulltext score = %v, want %v", a1.Explain.FulltextScore, a1.FulltextScore)
+	}
+	if a1.Explain.VectorRank == nil {
+		t.Error("a1: expected vector rank when returned by vector search")
+	}
+
+	// 关闭 Debug 时不返回 Explain
+	options.Debug = false
+	results, err = PerformHybridSearch(ctx, fts, vs, query, queryVector, options)
+	if err != nil {
+		t.Fatalf("failed to perform hybrid search: %v", err)
+	}
+	for _, r := range results {
+		if r.Explain != nil {
+			t.Errorf("%s: explain should be nil when Debug is false", r.Document.ID())
+		}
+	}
+}
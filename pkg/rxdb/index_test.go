@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -721,3 +722,310 @@ func TestIndex_Performance(t *testing.T) {
 		t.Errorf("Expected 10 documents, got %d", len(results))
 	}
 }
+
+func TestIndex_SchemaIndexesOnReopen(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_index_schema_reopen.db"
+	defer os.RemoveAll(dbPath)
+
+	openCollection := func(schema Schema) (Database, Collection) {
+		db, err := CreateDatabase(ctx, DatabaseOptions{
+			Name: "testdb",
+			Path: dbPath,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create database: %v", err)
+		}
+		collection, err := db.Collection(ctx, "test", schema)
+		if err != nil {
+			t.Fatalf("Failed to create collection: %v", err)
+		}
+		return db, collection
+	}
+	indexEntries := func(col Collection, indexName string) int {
+		count := 0
+		bucket := fmt.Sprintf("test_idx_%s", indexName)
+		err := col.(*collection).store.Iterate(ctx, bucket, func(k, v []byte) error {
+			count++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to iterate index bucket: %v", err)
+		}
+		return count
+	}
+
+	db, collection := openCollection(Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		Indexes:    []Index{{Fields: []string{"name"}, Name: "name_idx"}},
+	})
+	for i := 0; i < 5; i++ {
+		if _, err := collection.Insert(ctx, map[string]any{"id": fmt.Sprintf("doc%d", i), "name": fmt.Sprintf("user%d", i), "age": 20 + i%2}); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+	db.Close(ctx)
+
+	// 重新打开并在 schema 中新增索引，db.Collection 返回时索引应已构建完成
+	db, collection = openCollection(Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		Indexes: []Index{
+			{Fields: []string{"name"}, Name: "name_idx"},
+			{Fields: []string{"age"}, Name: "age_idx"},
+		},
+	})
+	if len(collection.ListIndexes()) != 2 {
+		t.Errorf("Expected 2 indexes, got %d", len(collection.ListIndexes()))
+	}
+	if n := indexEntries(collection, "age_idx"); n != 5 {
+		t.Errorf("Expected 5 entries in age_idx, got %d", n)
+	}
+	results, err := collection.Find(map[string]any{"age": 21}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected 2 documents with age 21, got %d", len(results))
+	}
+	db.Close(ctx)
+
+	// 从 schema 中移除索引时默认保留
+	db, collection = openCollection(Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		Indexes:    []Index{{Fields: []string{"name"}, Name: "name_idx"}},
+	})
+	if len(collection.ListIndexes()) != 2 {
+		t.Errorf("Expected removed index to be kept, got %v", collection.ListIndexes())
+	}
+	if _, err := collection.Insert(ctx, map[string]any{"id": "doc5", "name": "user5", "age": 21}); err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+	if n := indexEntries(collection, "age_idx"); n != 6 {
+		t.Errorf("Expected kept age_idx to stay maintained with 6 entries, got %d", n)
+	}
+	db.Close(ctx)
+
+	// DropMissingIndexes 时删除未声明的索引
+	db, collection = openCollection(Schema{
+		PrimaryKey:         "id",
+		RevField:           "_rev",
+		Indexes:            []Index{{Fields: []string{"name"}, Name: "name_idx"}},
+		DropMissingIndexes: true,
+	})
+	defer db.Close(ctx)
+	if len(collection.ListIndexes()) != 1 {
+		t.Errorf("Expected 1 index after DropMissingIndexes, got %v", collection.ListIndexes())
+	}
+	if n := indexEntries(collection, "age_idx"); n != 0 {
+		t.Errorf("Expected age_idx to be dropped, got %d entries", n)
+	}
+}
+
+func TestIndex_Geo(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "places", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		Indexes: []Index{
+			{Fields: []string{"location"}, Type: IndexTypeGeo},
+		},
+	})
+
+	point := func(lon, lat float64) map[string]any {
+		return map[string]any{"type": "Point", "coordinates": []any{lon, lat}}
+	}
+	places := []map[string]any{
+		{"id": "tiananmen", "location": point(116.3975, 39.9087)},
+		{"id": "forbidden_city", "location": point(116.3972, 39.9163)},
+		{"id": "wangfujing", "location": point(116.4109, 39.9149)},
+		{"id": "sanlitun", "location": point(116.4551, 39.9370)},
+		{"id": "summer_palace", "location": point(116.2755, 39.9999)},
+		{"id": "shanghai", "location": point(121.4737, 31.2304)},
+		{"id": "no_location", "name": "unknown"},
+	}
+	for _, place := range places {
+		if _, err := collection.Insert(ctx, place); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+
+	ids := func(docs []Document) []string {
+		result := make([]string, len(docs))
+		for i, doc := range docs {
+			result[i] = doc.ID()
+		}
+		return result
+	}
+	center := point(116.3975, 39.9087)
+
+	t.Run("nearest points", func(t *testing.T) {
+		docs, err := collection.Find(map[string]any{
+			"location": map[string]any{"$near": map[string]any{"$geometry": center}},
+		}).Limit(3).Exec(ctx)
+		if err != nil {
+			t.Fatalf("Failed to execute query: %v", err)
+		}
+		want := []string{"tiananmen", "forbidden_city", "wangfujing"}
+		if got := ids(docs); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("max distance", func(t *testing.T) {
+		q := collection.Find(map[string]any{
+			"location": map[string]any{"$near": map[string]any{
+				"$geometry":    center,
+				"$maxDistance": 10000,
+			}},
+		})
+		docs, err := q.Exec(ctx)
+		if err != nil {
+			t.Fatalf("Failed to execute query: %v", err)
+		}
+		want := []string{"tiananmen", "forbidden_city", "wangfujing", "sanlitun"}
+		if got := ids(docs); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+
+		// 地理索引只返回覆盖查询范围的单元中的候选文档
+		candidates, ok := q.tryUseGeoIndex(ctx)
+		if !ok {
+			t.Fatal("Expected $near query to use the geo index")
+		}
+		for _, id := range candidates {
+			if id == "shanghai" || id == "no_location" {
+				t.Errorf("Unexpected candidate %s from geo index", id)
+			}
+		}
+	})
+
+	t.Run("polygon", func(t *testing.T) {
+		polygon := map[string]any{
+			"type": "Polygon",
+			"coordinates": []any{[]any{
+				[]any{116.35, 39.89},
+				[]any{116.42, 39.89},
+				[]any{116.42, 39.93},
+				[]any{116.35, 39.93},
+				[]any{116.35, 39.89},
+			}},
+		}
+		docs, err := collection.Find(map[string]any{
+			"location": map[string]any{"$geoWithin": map[string]any{"$geometry": polygon}},
+		}).Sort(map[string]string{"id": "asc"}).Exec(ctx)
+		if err != nil {
+			t.Fatalf("Failed to execute query: %v", err)
+		}
+		want := []string{"forbidden_city", "tiananmen", "wangfujing"}
+		if got := ids(docs); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("index maintained on update", func(t *testing.T) {
+		doc, err := collection.FindByID(ctx, "shanghai")
+		if err != nil {
+			t.Fatalf("Failed to find document: %v", err)
+		}
+		if err := doc.Update(ctx, map[string]any{"location": point(116.3980, 39.9090)}); err != nil {
+			t.Fatalf("Failed to update document: %v", err)
+		}
+		count, err := collection.Find(map[string]any{
+			"location": map[string]any{"$near": map[string]any{
+				"$geometry":    center,
+				"$maxDistance": 100,
+			}},
+		}).Count(ctx)
+		if err != nil {
+			t.Fatalf("Failed to count documents: %v", err)
+		}
+		if count != 2 {
+			t.Errorf("Expected 2 documents within 100m, got %d", count)
+		}
+	})
+
+	if err := collection.CreateIndex(ctx, Index{Fields: []string{"a", "b"}, Type: IndexTypeGeo}); err == nil {
+		t.Error("Expected error for multi-field geo index")
+	}
+}
+
+func TestIndex_NestedAndArrayPaths(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	collection, err := db.Collection(ctx, "customers", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		Indexes: []Index{
+			{Fields: []string{"orders[*].sku"}, Name: "sku_idx"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	docs := []map[string]any{
+		{"id": "c1", "address": map[string]any{"city": "Beijing"}, "orders": []any{map[string]any{"sku": "A"}, map[string]any{"sku": "B"}, map[string]any{"sku": "B"}}},
+		{"id": "c2", "address": map[string]any{"city": "Shanghai"}, "orders": []any{map[string]any{"sku": "B"}}},
+		{"id": "c3", "address": map[string]any{"city": "Beijing"}, "orders": []any{map[string]any{"sku": "C"}}},
+		{"id": "c4", "address": map[string]any{"city": "Shenzhen"}},
+	}
+	for _, doc := range docs {
+		if _, err := collection.Insert(ctx, doc); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+
+	// 在已有数据上创建嵌套字段索引
+	if err := collection.CreateIndex(ctx, Index{Fields: []string{"address.city"}, Name: "city_idx"}); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+
+	indexedIDs := func(selector map[string]any) []string {
+		t.Helper()
+		ids, ok := collection.Find(selector).tryUseIndex(ctx)
+		if !ok {
+			t.Fatalf("Expected query %v to use an index", selector)
+		}
+		return ids
+	}
+
+	if ids := indexedIDs(map[string]any{"address.city": "Beijing"}); !reflect.DeepEqual(ids, []string{"c1", "c3"}) {
+		t.Errorf("Expected city index to return [c1 c3], got %v", ids)
+	}
+	// 多键索引：每个元素值一个条目，同一文档只返回一次
+	if ids := indexedIDs(map[string]any{"orders[*].sku": "B"}); !reflect.DeepEqual(ids, []string{"c1", "c2"}) {
+		t.Errorf("Expected sku index to return [c1 c2], got %v", ids)
+	}
+
+	results, err := collection.Find(map[string]any{"orders[*].sku": "B", "address.city": "Beijing"}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 1 || results[0].ID() != "c1" {
+		t.Errorf("Expected only c1, got %d results", len(results))
+	}
+
+	// 更新后旧的数组元素条目被移除
+	if _, err := collection.Upsert(ctx, map[string]any{"id": "c1", "address": map[string]any{"city": "Beijing"}, "orders": []any{map[string]any{"sku": "C"}}}); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+	if ids := indexedIDs(map[string]any{"orders[*].sku": "B"}); !reflect.DeepEqual(ids, []string{"c2"}) {
+		t.Errorf("Expected sku index to return [c2] after update, got %v", ids)
+	}
+	if ids := indexedIDs(map[string]any{"orders[*].sku": "C"}); !reflect.DeepEqual(ids, []string{"c1", "c3"}) {
+		t.Errorf("Expected sku index to return [c1 c3] after update, got %v", ids)
+	}
+
+	// 删除后条目被移除
+	if err := collection.Remove(ctx, "c3"); err != nil {
+		t.Fatalf("Failed to remove document: %v", err)
+	}
+	if ids := indexedIDs(map[string]any{"orders[*].sku": "C"}); !reflect.DeepEqual(ids, []string{"c1"}) {
+		t.Errorf("Expected sku index to return [c1] after delete, got %v", ids)
+	}
+}
@@ -311,8 +311,7 @@ func TestIntegration_Transaction(t *testing.T) {
 					"type": "string",
 				},
 				"name": map[string]any{
-					"type":     "string",
-					"required": true,
+					"type": "string",
 				},
 			},
 			"required": []any{"id", "name"},
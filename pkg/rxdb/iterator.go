@@ -0,0 +1,97 @@
+package rxdb
+
+import (
+	"context"
+	"errors"
+
+	bstore "github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
+)
+
+// DocumentIterator 按主键顺序遍历集合文档的迭代器。
+// 迭代器在创建时开启存储后端的扫描；Badger 与内存后端下遍历期间看到的是创建时刻的一致性快照，
+// 之后插入、更新或删除的文档对该迭代器不可见。使用完毕后必须调用 Close 释放底层资源。
+// DocumentIterator 不是并发安全的。
+type DocumentIterator struct {
+	ctx     context.Context
+	col     *collection
+	it      Iterator
+	prefix  []byte
+	current Document
+	err     error
+	closed  bool
+}
+
+// Iterator 创建基于快照的文档迭代器。
+func (c *collection) Iterator(ctx context.Context) (*DocumentIterator, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return nil, errors.New("collection is closed")
+	}
+
+	prefix := bstore.BucketPrefix(c.name)
+	it, err := c.store.Scan(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DocumentIterator{
+		ctx:    ctx,
+		col:    c,
+		it:     it,
+		prefix: prefix,
+	}, nil
+}
+
+// Next 前进到下一个文档，没有更多文档或出错时返回 false（错误通过 Err 获取）。
+func (di *DocumentIterator) Next() bool {
+	if di.closed || di.err != nil {
+		return false
+	}
+	if err := di.ctx.Err(); err != nil {
+		di.err = err
+		return false
+	}
+
+	if !di.it.Next() {
+		di.err = di.it.Err()
+		di.current = nil
+		return false
+	}
+
+	doc, err := di.col.decodeStoredDocument(di.it.Value())
+	if err != nil {
+		di.err = err
+		di.current = nil
+		return false
+	}
+	current, err := di.col.transformDocument(di.ctx, acquireDocument(string(di.it.Key()[len(di.prefix):]), doc, di.col))
+	if err != nil {
+		di.err = err
+		di.current = nil
+		return false
+	}
+	di.current = current
+	return true
+}
+
+// Document 返回当前文档，需在 Next 返回 true 之后调用。
+func (di *DocumentIterator) Document() Document {
+	return di.current
+}
+
+// Err 返回遍历过程中遇到的错误。
+func (di *DocumentIterator) Err() error {
+	return di.err
+}
+
+// Close 关闭迭代器并释放底层扫描，可重复调用。
+func (di *DocumentIterator) Close() error {
+	if di.closed {
+		return nil
+	}
+	di.closed = true
+	di.current = nil
+	return di.it.Close()
+}
@@ -0,0 +1,351 @@
+package rxdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RFC 6902 JSON Patch 操作类型。
+const (
+	JSONPatchAdd     = "add"
+	JSONPatchRemove  = "remove"
+	JSONPatchReplace = "replace"
+	JSONPatchMove    = "move"
+	JSONPatchCopy    = "copy"
+	JSONPatchTest    = "test"
+)
+
+// JSONPatchOp 一个 RFC 6902 JSON Patch 操作。Path 与 From 为 JSON Pointer（RFC 6901），
+// 如 "/tags/0"、"/address/city"；数组末尾可使用 "-"（仅 add、move、copy 的目标路径）。
+type JSONPatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+	From  string `json:"from,omitempty"`
+}
+
+// Patch 在同一次原子更新中按顺序应用 RFC 6902 JSON Patch，返回修订号递增后的文档。
+// 任一操作失败（包括 test 断言不成立）时整个 patch 都不会生效：test 失败返回 Conflict 错误，
+// 可用于乐观并发控制；路径不存在等其他错误返回 Validation 错误。
+// patch 不能修改主键与修订号字段，结果文档会按 Schema 重新验证。
+func (c *collection) Patch(ctx context.Context, id string, patch []JSONPatchOp) (Document, error) {
+	if len(patch) == 0 {
+		return nil, NewError(ErrorTypeValidation, "patch cannot be empty", nil)
+	}
+	for i, op := range patch {
+		for _, path := range []string{op.Path, op.From} {
+			tokens, err := parseJSONPointer(path)
+			if err != nil {
+				return nil, NewError(ErrorTypeValidation, fmt.Sprintf("patch operation %d: %v", i, err), nil)
+			}
+			if len(tokens) > 0 && (c.isPrimaryKeyField(tokens[0]) || tokens[0] == c.schema.RevField) && op.Op != JSONPatchTest {
+				return nil, NewError(ErrorTypeValidation, fmt.Sprintf("patch operation %d cannot modify field %s", i, tokens[0]), nil)
+			}
+		}
+	}
+
+	doc, err := c.findByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, NewError(ErrorTypeNotFound, fmt.Sprintf("document with id %s not found", id), nil)
+	}
+	err = doc.AtomicUpdate(ctx, func(data map[string]any) error {
+		patched, err := applyJSONPatch(data, patch)
+		if err != nil {
+			return err
+		}
+		for _, field := range c.getPrimaryKeyFields() {
+			if !jsonValuesEqual(patched[field], data[field]) {
+				return NewError(ErrorTypeValidation, fmt.Sprintf("patch cannot modify primary key field %s", field), nil)
+			}
+		}
+		if err := c.validateDocument(patched); err != nil {
+			return NewError(ErrorTypeValidation, "schema validation failed", err)
+		}
+		if err := ValidateFinalFields(c.schema, data, patched); err != nil {
+			return NewError(ErrorTypeValidation, "final field validation failed", err)
+		}
+		for k := range data {
+			delete(data, k)
+		}
+		for k, v := range patched {
+			data[k] = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.transformDocument(ctx, doc)
+}
+
+// applyJSONPatch 在 doc 的深拷贝上按顺序应用 patch，返回新文档，doc 本身不会被修改。
+func applyJSONPatch(doc map[string]any, patch []JSONPatchOp) (map[string]any, error) {
+	var root any = DeepCloneMap(doc)
+	for i, op := range patch {
+		var err error
+		root, err = applyJSONPatchOp(root, op)
+		if err != nil {
+			var rxErr *RxDBError
+			if errors.As(err, &rxErr) {
+				return nil, err
+			}
+			return nil, NewError(ErrorTypeValidation, fmt.Sprintf("patch operation %d (%s %s) failed", i, op.Op, op.Path), err)
+		}
+	}
+	result, ok := root.(map[string]any)
+	if !ok {
+		return nil, NewError(ErrorTypeValidation, "patch result must be an object", nil)
+	}
+	return result, nil
+}
+
+func applyJSONPatchOp(root any, op JSONPatchOp) (any, error) {
+	path, err := parseJSONPointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case JSONPatchAdd:
+		value, err := toJSONValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return jsonPatchAdd(root, path, value)
+	case JSONPatchRemove:
+		root, _, err := jsonPatchRemove(root, path)
+		return root, err
+	case JSONPatchReplace:
+		value, err := toJSONValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		return jsonPatchAt(root, path, func(parent any, key string) (any, error) {
+			switch p := parent.(type) {
+			case map[string]any:
+				if _, ok := p[key]; !ok {
+					return nil, fmt.Errorf("path %s does not exist", op.Path)
+				}
+				p[key] = value
+				return p, nil
+			case []any:
+				i, err := jsonArrayIndex(key, len(p), false)
+				if err != nil {
+					return nil, err
+				}
+				p[i] = value
+				return p, nil
+			}
+			return nil, fmt.Errorf("path %s does not exist", op.Path)
+		})
+	case JSONPatchMove, JSONPatchCopy:
+		from, err := parseJSONPointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == JSONPatchMove {
+			if op.From == op.Path {
+				_, err := jsonPointerGet(root, from)
+				return root, err
+			}
+			if strings.HasPrefix(op.Path, op.From+"/") {
+				return nil, fmt.Errorf("cannot move %s into its own child %s", op.From, op.Path)
+			}
+			root, value, err := jsonPatchRemove(root, from)
+			if err != nil {
+				return nil, err
+			}
+			return jsonPatchAdd(root, path, value)
+		}
+		value, err := jsonPointerGet(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return jsonPatchAdd(root, path, deepCloneValue(value))
+	case JSONPatchTest:
+		value, err := toJSONValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		actual, err := jsonPointerGet(root, path)
+		if err != nil || !jsonValuesEqual(actual, value) {
+			return nil, NewError(ErrorTypeConflict, fmt.Sprintf("json patch test failed at %s", op.Path), err).
+				WithContext("path", op.Path)
+		}
+		return root, nil
+	}
+	return nil, fmt.Errorf("unsupported patch operation %q", op.Op)
+}
+
+// jsonPatchAdd 在 path 处添加值：对象成员被设置或替换，数组在索引处插入（"-" 表示追加到末尾）。
+func jsonPatchAdd(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return jsonPatchAt(root, path, func(parent any, key string) (any, error) {
+		switch p := parent.(type) {
+		case map[string]any:
+			p[key] = value
+			return p, nil
+		case []any:
+			i, err := jsonArrayIndex(key, len(p), true)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = value
+			return p, nil
+		}
+		return nil, fmt.Errorf("cannot add member %s to a %s", key, jsonTypeOf(parent))
+	})
+}
+
+// jsonPatchRemove 删除 path 处的值，返回新的根节点与被删除的值。
+func jsonPatchRemove(root any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the document root")
+	}
+	var removed any
+	root, err := jsonPatchAt(root, path, func(parent any, key string) (any, error) {
+		switch p := parent.(type) {
+		case map[string]any:
+			value, ok := p[key]
+			if !ok {
+				return nil, fmt.Errorf("member %s does not exist", key)
+			}
+			removed = value
+			delete(p, key)
+			return p, nil
+		case []any:
+			i, err := jsonArrayIndex(key, len(p), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = p[i]
+			return append(p[:i], p[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("member %s does not exist", key)
+	})
+	return root, removed, err
+}
+
+// jsonPatchAt 定位 path 的父容器并调用 fn(parent, 最后一个 token)，fn 返回的容器写回上一级。
+// 数组在插入或删除元素后会重新分配，因此每一级都需要写回。
+func jsonPatchAt(node any, path []string, fn func(parent any, key string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+	switch n := node.(type) {
+	case map[string]any:
+		child, ok := n[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("member %s does not exist", path[0])
+		}
+		updated, err := jsonPatchAt(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = updated
+		return n, nil
+	case []any:
+		i, err := jsonArrayIndex(path[0], len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := jsonPatchAt(n[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = updated
+		return n, nil
+	}
+	return nil, fmt.Errorf("cannot traverse into a %s at %s", jsonTypeOf(node), path[0])
+}
+
+// jsonPointerGet 返回 path 指向的值。
+func jsonPointerGet(node any, path []string) (any, error) {
+	for _, token := range path {
+		switch n := node.(type) {
+		case map[string]any:
+			child, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("member %s does not exist", token)
+			}
+			node = child
+		case []any:
+			i, err := jsonArrayIndex(token, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("cannot traverse into a %s at %s", jsonTypeOf(node), token)
+		}
+	}
+	return node, nil
+}
+
+// parseJSONPointer 解析 RFC 6901 JSON Pointer，空字符串表示整个文档。
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// jsonArrayIndex 解析数组索引。allowEnd 为 true 时允许 "-" 与等于长度的索引（插入到末尾）。
+func jsonArrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" {
+		if allowEnd {
+			return length, nil
+		}
+		return 0, fmt.Errorf("index - refers to a nonexistent array element")
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > length || (i == length && !allowEnd) {
+		return 0, fmt.Errorf("array index %d out of range (length %d)", i, length)
+	}
+	return i, nil
+}
+
+// toJSONValue 将 patch 中的值转换为 JSON 数据模型（map[string]any、[]any、float64 等），
+// 与存储后读取到的文档保持一致，也避免与调用方共享可变数据。
+func toJSONValue(value any) (any, error) {
+	switch value.(type) {
+	case nil, string, bool, float64:
+		return value, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid patch value: %w", err)
+	}
+	var result any
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid patch value: %w", err)
+	}
+	return result, nil
+}
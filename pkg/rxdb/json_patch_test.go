@@ -0,0 +1,121 @@
+package rxdb
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCollection_Patch(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "patch", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	if _, err := coll.Insert(ctx, map[string]any{
+		"id":      "doc1",
+		"name":    "Alice",
+		"tags":    []any{"a", "b"},
+		"address": map[string]any{"city": "Berlin"},
+	}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	doc, err := coll.Patch(ctx, "doc1", []JSONPatchOp{
+		{Op: JSONPatchTest, Path: "/name", Value: "Alice"},
+		{Op: JSONPatchReplace, Path: "/address/city", Value: "Paris"},
+		{Op: JSONPatchAdd, Path: "/address/zip", Value: "75001"},
+		{Op: JSONPatchAdd, Path: "/tags/-", Value: "c"},
+		{Op: JSONPatchAdd, Path: "/tags/0", Value: "first"},
+		{Op: JSONPatchRemove, Path: "/tags/2"},
+		{Op: JSONPatchCopy, From: "/name", Path: "/nickname"},
+		{Op: JSONPatchMove, From: "/name", Path: "/fullName"},
+	})
+	if err != nil {
+		t.Fatalf("Patch failed: %v", err)
+	}
+
+	data := doc.Data()
+	want := map[string]any{
+		"id":       "doc1",
+		"fullName": "Alice",
+		"nickname": "Alice",
+		"tags":     []any{"first", "a", "c"},
+		"address":  map[string]any{"city": "Paris", "zip": "75001"},
+	}
+	for k, v := range want {
+		if !reflect.DeepEqual(data[k], v) {
+			t.Errorf("%s = %v, want %v", k, data[k], v)
+		}
+	}
+	if _, ok := data["name"]; ok {
+		t.Errorf("Expected name to be moved, got %v", data)
+	}
+	if rev := doc.GetString("_rev"); !strings.HasPrefix(rev, "2-") {
+		t.Errorf("Expected revision 2-*, got %s", rev)
+	}
+
+	stored, err := coll.FindByID(ctx, "doc1")
+	if err != nil {
+		t.Fatalf("FindByID failed: %v", err)
+	}
+	if !reflect.DeepEqual(stored.Data(), data) {
+		t.Errorf("Stored document %v differs from returned %v", stored.Data(), data)
+	}
+}
+
+func TestCollection_PatchTestFailureRollsBack(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "patch_rollback", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	inserted, err := coll.Insert(ctx, map[string]any{"id": "doc1", "version": 1, "tags": []any{"a"}})
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	rev := inserted.GetString("_rev")
+
+	// 前面的操作已应用到副本上，test 失败后整个 patch 都不生效
+	_, err = coll.Patch(ctx, "doc1", []JSONPatchOp{
+		{Op: JSONPatchAdd, Path: "/tags/-", Value: "b"},
+		{Op: JSONPatchReplace, Path: "/version", Value: 3},
+		{Op: JSONPatchTest, Path: "/version", Value: 2},
+	})
+	if !IsConflictError(err) {
+		t.Fatalf("Expected conflict error, got %v", err)
+	}
+
+	// 路径不存在返回验证错误
+	_, err = coll.Patch(ctx, "doc1", []JSONPatchOp{
+		{Op: JSONPatchAdd, Path: "/tags/-", Value: "b"},
+		{Op: JSONPatchRemove, Path: "/missing"},
+	})
+	if !IsValidationError(err) {
+		t.Fatalf("Expected validation error, got %v", err)
+	}
+
+	stored, err := coll.FindByID(ctx, "doc1")
+	if err != nil {
+		t.Fatalf("FindByID failed: %v", err)
+	}
+	if stored.GetInt("version") != 1 || !reflect.DeepEqual(stored.GetArray("tags"), []any{"a"}) {
+		t.Errorf("Expected document to be unchanged, got %v", stored.Data())
+	}
+	if stored.GetString("_rev") != rev {
+		t.Errorf("Expected revision %s to be unchanged, got %s", rev, stored.GetString("_rev"))
+	}
+
+	// 不能修改主键与修订号
+	for _, op := range []JSONPatchOp{
+		{Op: JSONPatchReplace, Path: "/id", Value: "doc2"},
+		{Op: JSONPatchRemove, Path: "/_rev"},
+		{Op: JSONPatchMove, From: "/id", Path: "/oldID"},
+	} {
+		if _, err := coll.Patch(ctx, "doc1", []JSONPatchOp{op}); !IsValidationError(err) {
+			t.Errorf("%s %s: expected validation error, got %v", op.Op, op.Path, err)
+		}
+	}
+	if _, err := coll.Patch(ctx, "missing", []JSONPatchOp{{Op: JSONPatchAdd, Path: "/a", Value: 1}}); err == nil {
+		t.Error("Expected error for missing document")
+	}
+}
@@ -0,0 +1,373 @@
+package rxdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Schema 写入验证模式（SchemaOptions.Validation）。
+const (
+	SchemaValidationStrict = "strict" // 违反约束时返回错误（默认）
+	SchemaValidationWarn   = "warn"   // 记录警告日志后继续写入
+	SchemaValidationOff    = "off"    // 不进行 JSON Schema 验证
+)
+
+// SchemaOptions Schema 的写入期选项。
+type SchemaOptions struct {
+	// Validation 写入时按 Schema.JSON（JSON Schema Draft 7）验证文档的模式，
+	// 可选 SchemaValidationStrict（默认）、SchemaValidationWarn、SchemaValidationOff。
+	// 文档缺少主键字段时无论何种模式都会返回错误。
+	Validation string
+}
+
+// ValidationErrors 文档违反的全部 Schema 约束。
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, ve := range e {
+		msgs[i] = ve.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap 返回每个约束对应的 *ValidationError，使 errors.As(err, &*ValidationError) 能取到第一个违反的约束。
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i := range e {
+		errs[i] = &e[i]
+	}
+	return errs
+}
+
+// schemaResourceURL 编译 Schema.JSON 时使用的资源地址，"#/definitions/..." 形式的 $ref 相对它解析。
+const schemaResourceURL = "rxdb:///schema.json"
+
+// schemaMessages 生成验证错误信息使用的 printer。
+var schemaMessages = message.NewPrinter(language.English)
+
+// compiledSchemas 缓存已编译的 schema，键为排除的字段与 schema 的 JSON 编码。
+var compiledSchemas sync.Map
+
+type compiledSchema struct {
+	schema *jsonschema.Schema
+	err    error
+}
+
+// compileDocumentSchema 检查 schema.JSON 是否为合法的 JSON Schema，创建集合时调用，避免在每次写入时才报错。
+func compileDocumentSchema(schema Schema) error {
+	_, err := compileJSONSchema(schema.JSON, excludedSchemaFields(schema))
+	return err
+}
+
+// excludedSchemaFields 返回不参与 JSON Schema 验证的字段：主键与修订号。
+func excludedSchemaFields(schema Schema) []string {
+	excluded := getPrimaryKeyFields(schema)
+	if schema.RevField != "" {
+		excluded = append(excluded, schema.RevField)
+	}
+	return excluded
+}
+
+// validateJSONSchema 按 schema.JSON（JSON Schema Draft 7，pattern 使用 Go 的 RE2 语法）验证文档，返回全部违反的约束。
+// 主键与修订号字段分别由主键检查和数据库负责，验证前从文档与根对象的 properties、required 中排除，
+// 因此 additionalProperties、propertyNames 等约束也不会作用于它们。
+func validateJSONSchema(schema Schema, doc map[string]any) []ValidationError {
+	excluded := excludedSchemaFields(schema)
+	compiled, err := compileJSONSchema(schema.JSON, excluded)
+	if err != nil {
+		return []ValidationError{{Keyword: "$schema", Message: err.Error()}}
+	}
+
+	trimmed := make(map[string]any, len(doc))
+	for k, v := range doc {
+		trimmed[k] = v
+	}
+	for _, field := range excluded {
+		delete(trimmed, field)
+	}
+	// 按 JSON 编码后的形式验证，与文档的存储形式一致（[]string、time.Time 等转换为对应的 JSON 值）
+	raw, err := json.Marshal(trimmed)
+	if err != nil {
+		return []ValidationError{{Keyword: "type", Message: fmt.Sprintf("document is not valid JSON: %v", err)}}
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return []ValidationError{{Keyword: "type", Message: fmt.Sprintf("document is not valid JSON: %v", err)}}
+	}
+
+	err = compiled.Validate(instance)
+	if err == nil {
+		return nil
+	}
+	verr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []ValidationError{{Message: err.Error()}}
+	}
+	return collectSchemaErrors(instance, verr, nil)
+}
+
+// compileJSONSchema 编译（并缓存）排除了 excluded 字段的 schema。
+func compileJSONSchema(schemaJSON map[string]any, excluded []string) (*jsonschema.Schema, error) {
+	raw, err := json.Marshal(schemaJSON)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	key := strings.Join(excluded, "\x00") + "\x00" + string(raw)
+	if cached, ok := compiledSchemas.Load(key); ok {
+		c := cached.(*compiledSchema)
+		return c.schema, c.err
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if root, ok := doc.(map[string]any); ok {
+		excludeSchemaFields(root, excluded)
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft7)
+	var compiled *jsonschema.Schema
+	if err = compiler.AddResource(schemaResourceURL, doc); err == nil {
+		compiled, err = compiler.Compile(schemaResourceURL)
+	}
+	compiledSchemas.Store(key, &compiledSchema{schema: compiled, err: err})
+	return compiled, err
+}
+
+// excludeSchemaFields 从根对象 schema 的 properties 与 required 中移除指定字段。
+func excludeSchemaFields(root map[string]any, fields []string) {
+	properties, _ := root["properties"].(map[string]any)
+	required, _ := root["required"].([]any)
+	for _, field := range fields {
+		delete(properties, field)
+		for i := 0; i < len(required); i++ {
+			if required[i] == field {
+				required = append(required[:i], required[i+1:]...)
+				i--
+			}
+		}
+	}
+	if _, ok := root["required"]; ok {
+		root["required"] = required
+	}
+}
+
+// collectSchemaErrors 将验证错误树展开为逐条约束的 ValidationError。
+// $ref、allOf 等只是汇总子错误的节点会被展开；anyOf、oneOf、not 作为整体报告。
+func collectSchemaErrors(instance any, verr *jsonschema.ValidationError, errs []ValidationError) []ValidationError {
+	path := instancePath(instance, verr.InstanceLocation)
+	switch k := verr.ErrorKind.(type) {
+	case *kind.Schema, *kind.Group, *kind.Reference, *kind.AllOf:
+		if len(verr.Causes) > 0 {
+			for _, cause := range verr.Causes {
+				errs = collectSchemaErrors(instance, cause, errs)
+			}
+			return errs
+		}
+	case *kind.Required:
+		for _, field := range k.Missing {
+			errs = append(errs, ValidationError{
+				Path:    joinSchemaPath(path, field),
+				Keyword: "required",
+				Message: fmt.Sprintf("missing required field: %s", field),
+			})
+		}
+		return errs
+	case *kind.Dependency:
+		for _, field := range k.Missing {
+			errs = append(errs, ValidationError{
+				Path:    joinSchemaPath(path, field),
+				Keyword: "dependencies",
+				Message: fmt.Sprintf("field is required when %s is present", k.Prop),
+			})
+		}
+		return errs
+	case *kind.AdditionalProperties:
+		for _, field := range k.Properties {
+			errs = append(errs, ValidationError{
+				Path:    joinSchemaPath(path, field),
+				Keyword: "additionalProperties",
+				Message: "additional property is not allowed",
+			})
+		}
+		return errs
+	}
+	return append(errs, ValidationError{
+		Path:    path,
+		Keyword: schemaErrorKeyword(verr.ErrorKind),
+		Message: verr.ErrorKind.LocalizedString(schemaMessages),
+	})
+}
+
+// schemaErrorKeyword 返回错误对应的 JSON Schema 关键字。
+func schemaErrorKeyword(k jsonschema.ErrorKind) string {
+	switch k.(type) {
+	case *kind.Not:
+		return "not"
+	case *kind.FalseSchema:
+		return "false"
+	case *kind.Reference:
+		return "$ref"
+	}
+	if path := k.KeywordPath(); len(path) > 0 {
+		return path[0]
+	}
+	return ""
+}
+
+// instancePath 将 JSON Pointer 形式的位置转换为字段路径（如 "address.city"、"tags[0]"）。
+func instancePath(instance any, location []string) string {
+	path := ""
+	current := instance
+	for _, token := range location {
+		switch v := current.(type) {
+		case []any:
+			path += "[" + token + "]"
+			current = nil
+			if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(v) {
+				current = v[i]
+			}
+		case map[string]any:
+			path = joinSchemaPath(path, token)
+			current = v[token]
+		default:
+			path = joinSchemaPath(path, token)
+			current = nil
+		}
+	}
+	return path
+}
+
+func joinSchemaPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// normalizeJSONValue 将任意切片/数组转换为 []any，将键为字符串的 map 转换为 map[string]any。
+func normalizeJSONValue(value any) any {
+	switch value.(type) {
+	case nil, string, bool, []any, map[string]any:
+		return value
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return value // []byte 视为非数组
+		}
+		arr := make([]any, rv.Len())
+		for i := range arr {
+			arr[i] = rv.Index(i).Interface()
+		}
+		return arr
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return value
+		}
+		obj := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			obj[iter.Key().String()] = iter.Value().Interface()
+		}
+		return obj
+	}
+	return value
+}
+
+// jsonNumber 将 Go 数值类型（及 json.Number）转换为 float64。
+func jsonNumber(value any) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case nil, bool, string:
+		return 0, false
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// jsonTypeOf 返回值对应的 JSON 类型名。
+func jsonTypeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	if n, ok := jsonNumber(value); ok {
+		if n == math.Trunc(n) && !math.IsInf(n, 0) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// jsonValuesEqual 按 JSON 语义比较两个值：数值按大小比较，数组与对象逐元素比较。
+func jsonValuesEqual(a, b any) bool {
+	a, b = normalizeJSONValue(a), normalizeJSONValue(b)
+	if an, ok := jsonNumber(a); ok {
+		bn, ok := jsonNumber(b)
+		return ok && an == bn
+	}
+	switch av := a.(type) {
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonValuesEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			other, ok := bv[k]
+			if !ok || !jsonValuesEqual(v, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
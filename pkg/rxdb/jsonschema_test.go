@@ -0,0 +1,244 @@
+package rxdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func userSchemaJSON() map[string]any {
+	return map[string]any{
+		"type":                 "object",
+		"required":             []any{"id", "name", "email"},
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"id":    map[string]any{"type": "string", "maxLength": 32},
+			"name":  map[string]any{"type": "string", "minLength": 1},
+			"email": map[string]any{"type": "string", "pattern": `^[^@\s]+@[^@\s]+$`},
+			"age":   map[string]any{"type": "integer", "minimum": 0},
+			"tags": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"uniqueItems": true,
+			},
+			"address": map[string]any{"$ref": "#/definitions/address"},
+		},
+		"definitions": map[string]any{
+			"address": map[string]any{
+				"type":                 "object",
+				"required":             []any{"city"},
+				"additionalProperties": false,
+				"properties": map[string]any{
+					"city": map[string]any{"type": "string"},
+					"zip":  map[string]any{"type": "string", "pattern": `^\d{5}$`},
+				},
+			},
+		},
+	}
+}
+
+// validationKeywords 返回 path -> keyword 的映射，便于断言。
+func validationKeywords(t *testing.T, err error) map[string]string {
+	t.Helper()
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("Expected ValidationErrors, got %T: %v", err, err)
+	}
+	result := make(map[string]string, len(verrs))
+	for _, ve := range verrs {
+		result[ve.Path] = ve.Keyword
+	}
+	return result
+}
+
+func TestValidateDocument_JSONSchemaDraft7(t *testing.T) {
+	schema := Schema{PrimaryKey: "id", RevField: "_rev", JSON: userSchemaJSON()}
+
+	valid := map[string]any{
+		"id":      "u1",
+		"_rev":    "1-abc", // 修订号不受 additionalProperties 限制
+		"name":    "Alice",
+		"email":   "alice@example.com",
+		"age":     30,
+		"tags":    []string{"a", "b"},
+		"address": map[string]any{"city": "Berlin", "zip": "10115"},
+	}
+	if err := ValidateDocument(schema, valid); err != nil {
+		t.Fatalf("Expected valid document, got %v", err)
+	}
+
+	cases := []struct {
+		name string
+		doc  map[string]any
+		want map[string]string
+	}{
+		{
+			name: "missing required field",
+			doc:  map[string]any{"id": "u1", "email": "a@b.c"},
+			want: map[string]string{"name": "required"},
+		},
+		{
+			name: "wrong type",
+			doc:  map[string]any{"id": "u1", "name": "A", "email": "a@b.c", "age": "thirty"},
+			want: map[string]string{"age": "type"},
+		},
+		{
+			name: "pattern mismatch",
+			doc:  map[string]any{"id": "u1", "name": "A", "email": "not-an-email"},
+			want: map[string]string{"email": "pattern"},
+		},
+		{
+			name: "additional properties",
+			doc:  map[string]any{"id": "u1", "name": "A", "email": "a@b.c", "nickname": "al"},
+			want: map[string]string{"nickname": "additionalProperties"},
+		},
+		{
+			name: "nested $ref",
+			doc:  map[string]any{"id": "u1", "name": "A", "email": "a@b.c", "address": map[string]any{"zip": "1", "extra": true}},
+			want: map[string]string{"address.city": "required", "address.zip": "pattern", "address.extra": "additionalProperties"},
+		},
+		{
+			name: "array items",
+			doc:  map[string]any{"id": "u1", "name": "A", "email": "a@b.c", "tags": []any{"x", 1, "x"}},
+			want: map[string]string{"tags[1]": "type", "tags": "uniqueItems"},
+		},
+		{
+			// 所有违反的约束都会被列出，而不仅是第一个
+			name: "multiple violations",
+			doc:  map[string]any{"id": "u1", "email": "bad", "age": -1, "nickname": "al"},
+			want: map[string]string{"name": "required", "email": "pattern", "age": "minimum", "nickname": "additionalProperties"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := validationKeywords(t, ValidateDocument(schema, tc.doc))
+			if len(got) != len(tc.want) {
+				t.Errorf("Expected %d errors %v, got %v", len(tc.want), tc.want, got)
+			}
+			for path, keyword := range tc.want {
+				if got[path] != keyword {
+					t.Errorf("Expected %s error at %q, got %v", keyword, path, got)
+				}
+			}
+		})
+	}
+
+	// 缺少主键时单独报错
+	got := validationKeywords(t, ValidateDocument(schema, map[string]any{"name": "A", "email": "a@b.c"}))
+	if len(got) != 1 || got["id"] != "required" {
+		t.Errorf("Expected only a missing primary key error, got %v", got)
+	}
+}
+
+func TestValidateDocument_PrimaryKeyExcluded(t *testing.T) {
+	schema := Schema{PrimaryKey: []string{"tenant", "id"}, RevField: "_rev", JSON: map[string]any{
+		"type":                 "object",
+		"required":             []any{"tenant", "id", "name"},
+		"additionalProperties": false,
+		"propertyNames":        map[string]any{"pattern": "^[a-z]+$"},
+		"properties": map[string]any{
+			"tenant": map[string]any{"type": "integer"},
+			"id":     map[string]any{"type": "string", "maxLength": 2},
+			"_rev":   map[string]any{"type": "integer"},
+			"name":   map[string]any{"type": "string"},
+		},
+	}}
+
+	// 主键与修订号字段由数据库负责，properties 中为它们声明的约束不生效
+	doc := map[string]any{"tenant": "acme", "id": "too-long", "_rev": "1-abc", "name": "A"}
+	if err := ValidateDocument(schema, doc); err != nil {
+		t.Fatalf("Expected primary key constraints to be ignored, got %v", err)
+	}
+
+	err := ValidateDocument(schema, map[string]any{"tenant": 1, "name": 2})
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("Expected errors.As to find *ValidationError, got %T: %v", err, err)
+	}
+	if ve.Path != "id" || ve.Keyword != "required" {
+		t.Errorf("Expected first error to be the missing primary key, got %+v", ve)
+	}
+	got := validationKeywords(t, err)
+	if len(got) != 2 || got["name"] != "type" {
+		t.Errorf("Expected missing id and name type errors, got %v", got)
+	}
+}
+
+func TestValidateDocument_CombinatorsAndConditionals(t *testing.T) {
+	schema := Schema{PrimaryKey: "id", RevField: "_rev", JSON: map[string]any{
+		"properties": map[string]any{
+			"kind":  map[string]any{"enum": []any{"card", "bank"}},
+			"value": map[string]any{"oneOf": []any{map[string]any{"type": "integer"}, map[string]any{"type": "number", "multipleOf": 0.5}}},
+			"code":  map[string]any{"not": map[string]any{"const": "forbidden"}},
+		},
+		"if":   map[string]any{"properties": map[string]any{"kind": map[string]any{"const": "card"}}},
+		"then": map[string]any{"required": []any{"cardNumber"}},
+		"else": map[string]any{"required": []any{"iban"}},
+	}}
+
+	if err := ValidateDocument(schema, map[string]any{"id": "1", "kind": "card", "cardNumber": "4242", "value": 1.5}); err != nil {
+		t.Errorf("Expected valid document, got %v", err)
+	}
+	got := validationKeywords(t, ValidateDocument(schema, map[string]any{"id": "1", "kind": "cash", "value": 2, "code": "forbidden"}))
+	want := map[string]string{"kind": "enum", "value": "oneOf", "code": "not", "iban": "required"}
+	for path, keyword := range want {
+		if got[path] != keyword {
+			t.Errorf("Expected %s error at %q, got %v", keyword, path, got)
+		}
+	}
+}
+
+func TestCollection_SchemaValidationModes(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	invalid := map[string]any{"id": "u1", "name": "A", "email": "invalid", "nickname": "al"}
+	for _, mode := range []string{"", SchemaValidationStrict, SchemaValidationWarn, SchemaValidationOff} {
+		coll := newTestCollection(t, db, "validation_"+mode, Schema{
+			PrimaryKey: "id",
+			RevField:   "_rev",
+			JSON:       userSchemaJSON(),
+			Options:    SchemaOptions{Validation: mode},
+		})
+
+		_, insertErr := coll.Insert(ctx, DeepCloneMap(invalid))
+		_, upsertErr := coll.Upsert(ctx, map[string]any{"id": "u2", "age": "old"})
+		if mode == "" || mode == SchemaValidationStrict {
+			for _, err := range []error{insertErr, upsertErr} {
+				if !errors.As(err, new(ValidationErrors)) {
+					t.Errorf("mode %q: expected validation error, got %v", mode, err)
+				}
+			}
+			var verrs ValidationErrors
+			if !errors.As(insertErr, &verrs) || len(verrs) != 2 {
+				t.Errorf("mode %q: expected 2 violations, got %v", mode, insertErr)
+			}
+			var ve *ValidationError
+			if !errors.As(insertErr, &ve) || ve.Keyword == "" {
+				t.Errorf("mode %q: expected *ValidationError, got %v", mode, insertErr)
+			}
+			continue
+		}
+		if insertErr != nil || upsertErr != nil {
+			t.Errorf("mode %q: expected invalid documents to be written, got %v / %v", mode, insertErr, upsertErr)
+		}
+		// 缺少主键始终报错
+		if _, err := coll.Insert(ctx, map[string]any{"name": "A"}); err == nil {
+			t.Errorf("mode %q: expected error for missing primary key", mode)
+		}
+	}
+
+	if _, err := db.Collection(ctx, "validation_invalid", Schema{Options: SchemaOptions{Validation: "lenient"}}); err == nil {
+		t.Error("Expected error for unsupported validation mode")
+	}
+
+	// 非法的 JSON Schema 在创建集合时报错
+	badSchema := Schema{PrimaryKey: "id", JSON: map[string]any{"properties": map[string]any{"name": map[string]any{"required": true}}}}
+	if _, err := db.Collection(ctx, "validation_bad_schema", badSchema); !IsValidationError(err) {
+		t.Errorf("Expected validation error for invalid JSON schema, got %v", err)
+	}
+	badSchema.Options.Validation = SchemaValidationOff
+	if _, err := db.Collection(ctx, "validation_bad_schema", badSchema); err != nil {
+		t.Errorf("Expected invalid JSON schema to be accepted when validation is off, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package rxdb
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"sync"
@@ -8,6 +9,94 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// Logger rxdb-go 内部日志接口。
+// keysAndValues 为交替出现的键值对，例如 Warn("msg", "collection", name, "error", err)。
+type Logger interface {
+	Debug(msg string, keysAndValues ...any)
+	Info(msg string, keysAndValues ...any)
+	Warn(msg string, keysAndValues ...any)
+	Error(msg string, keysAndValues ...any)
+}
+
+// logrusLogger 基于 logrus 的 Logger 实现。
+type logrusLogger struct {
+	logger *logrus.Logger
+}
+
+// NewStdLogger 创建输出到标准错误的默认日志器。
+// level 支持 debug、info、warn、error 等 logrus 级别名称，无法识别时使用 info。
+func NewStdLogger(level string) Logger {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
+	logger.SetOutput(os.Stderr)
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		lvl = logrus.InfoLevel
+	}
+	logger.SetLevel(lvl)
+	return &logrusLogger{logger: logger}
+}
+
+// NoopLogger 返回丢弃所有日志的日志器（适用于测试）。
+func NoopLogger() Logger {
+	return noopLogger{}
+}
+
+// defaultLogger 返回未配置日志器时使用的实现（logrus 标准日志器）。
+func defaultLogger() Logger {
+	return &logrusLogger{logger: logrus.StandardLogger()}
+}
+
+// resolveLogger 根据数据库选项确定日志器：优先使用 Logger，其次按 LogLevel 创建。
+func resolveLogger(opts DatabaseOptions) Logger {
+	if opts.Logger != nil {
+		return opts.Logger
+	}
+	if opts.LogLevel != "" {
+		return NewStdLogger(opts.LogLevel)
+	}
+	return defaultLogger()
+}
+
+func (l *logrusLogger) entry(keysAndValues []any) *logrus.Entry {
+	fields := make(logrus.Fields, len(keysAndValues)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		if i+1 < len(keysAndValues) {
+			fields[key] = keysAndValues[i+1]
+		} else {
+			fields[key] = nil
+		}
+	}
+	return l.logger.WithFields(fields)
+}
+
+func (l *logrusLogger) Debug(msg string, keysAndValues ...any) {
+	l.entry(keysAndValues).Debug(msg)
+}
+
+func (l *logrusLogger) Info(msg string, keysAndValues ...any) {
+	l.entry(keysAndValues).Info(msg)
+}
+
+func (l *logrusLogger) Warn(msg string, keysAndValues ...any) {
+	l.entry(keysAndValues).Warn(msg)
+}
+
+func (l *logrusLogger) Error(msg string, keysAndValues ...any) {
+	l.entry(keysAndValues).Error(msg)
+}
+
+// noopLogger 丢弃所有日志。
+type noopLogger struct{}
+
+func (noopLogger) Debug(string, ...any) {}
+func (noopLogger) Info(string, ...any)  {}
+func (noopLogger) Warn(string, ...any)  {}
+func (noopLogger) Error(string, ...any) {}
+
 var (
 	globalLogger *logrus.Logger
 	loggerOnce   sync.Once
@@ -1041,6 +1041,7 @@ func TestSchemaModify_RemoveIndexes(t *testing.T) {
 		Indexes: []Index{
 			{Fields: []string{"name"}, Name: "name_idx"},
 		},
+		DropMissingIndexes: true,
 		MigrationStrategies: map[int]MigrationStrategy{
 			2: func(oldDoc map[string]any) (map[string]any, error) {
 				return oldDoc, nil
@@ -1222,9 +1223,10 @@ func TestSchemaModify_SameVersion_RemoveIndexes(t *testing.T) {
 		Indexes: []Index{
 			{Fields: []string{"name"}, Name: "name_idx"},
 		},
+		DropMissingIndexes: true,
 	}
 
-	// 使用新 schema 获取集合（应该自动删除旧索引）
+	// 使用新 schema 获取集合（DropMissingIndexes 时删除未声明的旧索引）
 	collection2, err := db.Collection(ctx, "test", schemaV1Modified)
 	if err != nil {
 		t.Fatalf("Failed to get collection with modified schema: %v", err)
@@ -1519,6 +1521,7 @@ func TestSchemaModify_LowerVersion(t *testing.T) {
 		Indexes: []Index{
 			{Fields: []string{"age"}, Name: "age_idx"},
 		},
+		DropMissingIndexes: true,
 	}
 
 	// 使用新 schema 获取集合（应该允许更新，可能是回滚场景）
@@ -1667,7 +1670,7 @@ func TestSchemaModify_ComplexChanges(t *testing.T) {
 		PrimaryKey:      "id",
 		RevField:        "_rev",
 		EncryptedFields: []string{"email", "phone"}, // 添加 phone
-		KeyCompression:  &compressionEnabled,         // 启用压缩
+		KeyCompression:  &compressionEnabled,        // 启用压缩
 		JSON: map[string]any{
 			"version": 1, // 相同版本
 		},
@@ -1713,3 +1716,140 @@ func TestSchemaModify_ComplexChanges(t *testing.T) {
 		t.Errorf("Expected 1 document, got %d", len(docs))
 	}
 }
+
+func TestMigration_DatabaseMigrate(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_migration_database.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "testdb",
+		Path: dbPath,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	schema := Schema{PrimaryKey: "id", RevField: "_rev"}
+	var calls []int
+	migrations := []MigrationFn{
+		// 1: 创建集合并写入初始数据
+		func(ctx context.Context, db Database, fromVersion int) error {
+			calls = append(calls, fromVersion)
+			users, err := db.Collection(ctx, "users", schema)
+			if err != nil {
+				return err
+			}
+			_, err = users.Insert(ctx, map[string]any{"id": "u1", "name": "Alice"})
+			return err
+		},
+		// 2: 为已有文档补充字段
+		func(ctx context.Context, db Database, fromVersion int) error {
+			calls = append(calls, fromVersion)
+			users, err := db.Collection(ctx, "users", schema)
+			if err != nil {
+				return err
+			}
+			_, err = users.IncrementalModify(ctx, "u1", func(doc map[string]any) error {
+				doc["role"] = "admin"
+				return nil
+			})
+			return err
+		},
+	}
+
+	stored := func() int {
+		version, err := db.(*database).dbVersion(ctx)
+		if err != nil {
+			t.Fatalf("Failed to read database version: %v", err)
+		}
+		return version
+	}
+
+	// 多步迁移
+	if err := db.Migrate(ctx, 0, 2, migrations); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if stored() != 2 {
+		t.Errorf("Expected database version 2, got %d", stored())
+	}
+	if fmt.Sprint(calls) != "[0 1]" {
+		t.Errorf("Expected migrations called with [0 1], got %v", calls)
+	}
+	users, err := db.Collection(ctx, "users", schema)
+	if err != nil {
+		t.Fatalf("Failed to get collection: %v", err)
+	}
+	doc, err := users.FindByID(ctx, "u1")
+	if err != nil || doc == nil {
+		t.Fatalf("Failed to find migrated document: %v", err)
+	}
+	if doc.GetString("role") != "admin" {
+		t.Errorf("Expected role 'admin', got %q", doc.GetString("role"))
+	}
+
+	// 幂等：已应用的步骤不会重复执行
+	calls = nil
+	if err := db.Migrate(ctx, 0, 2, migrations); err != nil {
+		t.Fatalf("Failed to re-run migrations: %v", err)
+	}
+	if len(calls) != 0 {
+		t.Errorf("Expected no migrations to run again, got %v", calls)
+	}
+
+	// 部分失败：第 3 步成功，第 4 步失败，第 5 步被跳过
+	calls = nil
+	more := []MigrationFn{
+		func(ctx context.Context, db Database, fromVersion int) error {
+			calls = append(calls, fromVersion)
+			return nil
+		},
+		func(ctx context.Context, db Database, fromVersion int) error {
+			calls = append(calls, fromVersion)
+			return fmt.Errorf("boom")
+		},
+		func(ctx context.Context, db Database, fromVersion int) error {
+			calls = append(calls, fromVersion)
+			return nil
+		},
+	}
+	err = db.Migrate(ctx, 2, 5, more)
+	if err == nil {
+		t.Fatal("Expected migration failure")
+	}
+	if fmt.Sprint(calls) != "[2 3]" {
+		t.Errorf("Expected migrations called with [2 3], got %v", calls)
+	}
+	if stored() != 3 {
+		t.Errorf("Expected database version 3 after failure, got %d", stored())
+	}
+	failed, err := db.(*database).store.Get(ctx, dbMetaBucket, dbMigrationFailedKey)
+	if err != nil {
+		t.Fatalf("Failed to read failed version: %v", err)
+	}
+	if string(failed) != "4" {
+		t.Errorf("Expected failed version 4, got %q", failed)
+	}
+
+	// 修复后重新执行只运行剩余步骤
+	calls = nil
+	more[1] = func(ctx context.Context, db Database, fromVersion int) error {
+		calls = append(calls, fromVersion)
+		return nil
+	}
+	if err := db.Migrate(ctx, 2, 5, more); err != nil {
+		t.Fatalf("Failed to resume migrations: %v", err)
+	}
+	if fmt.Sprint(calls) != "[3 4]" {
+		t.Errorf("Expected migrations called with [3 4], got %v", calls)
+	}
+	if stored() != 5 {
+		t.Errorf("Expected database version 5, got %d", stored())
+	}
+
+	// 参数校验
+	if err := db.Migrate(ctx, 0, 2, migrations[:1]); !IsValidationError(err) {
+		t.Errorf("Expected validation error for mismatched migrations, got %v", err)
+	}
+}
@@ -4,26 +4,42 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	bstore "github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
-	"github.com/sirupsen/logrus"
 )
 
 // Query 提供与 RxDB 兼容的查询 API。
 // 支持 Mango Query 语法的子集。
 type Query struct {
-	collection   *collection
-	selector     map[string]any
-	splitPaths   map[string][]string // 预拆分的路径，压榨查询性能
-	sortFields   []SortField
-	skip         int
-	limit        int
-	bloomFilters map[string]*BloomFilter // 为 $in 和 $nin 操作预构建的布隆过滤器
+	collection    *collection
+	selector      map[string]any
+	splitPaths    map[string][]string // 预拆分的路径，压榨查询性能
+	sortFields    []SortField
+	skip          int
+	limit         int
+	bloomFilters  map[string]*BloomFilter // 为 $in 和 $nin 操作预构建的布隆过滤器
+	cache         *queryCache             // 结果缓存（通过 Cache 启用）
+	withCursor    bool                    // 游标分页模式：按排序字段与主键确定顺序
+	after         *cursorPosition         // After 设置的游标位置
+	cursorErr     error                   // After 解析游标失败的错误，执行时返回
+	textScores    map[string]float64      // $text 匹配的文档 ID 及分数，执行时填充
+	selectFields  []string                // Select 设置的投影字段
+	excludeFields []string                // ExcludeFields 设置的排除字段
+	timeout       time.Duration           // WithOptions 设置的执行超时
+}
+
+// QueryOptions 查询执行选项。
+type QueryOptions struct {
+	// Timeout 查询执行超时，> 0 时在调用方的 ctx 上附加截止时间。
+	// 扫描在每个文档之间检查 ctx，超时或取消时丢弃已收集的部分结果并返回 ctx.Err()。
+	Timeout time.Duration
 }
 
 // SortField 排序字段定义。
@@ -35,6 +51,11 @@ type SortField struct {
 
 // NewQuery 创建新的查询实例。
 func (c *collection) Find(selector map[string]any) *Query {
+	if c.schema.CoerceTypes && c.schema.JSON != nil {
+		if properties, ok := c.schema.JSON["properties"].(map[string]any); ok {
+			selector = coerceSelector(properties, selector)
+		}
+	}
 	q := &Query{
 		collection:   c,
 		selector:     selector,
@@ -101,7 +122,7 @@ func (q *Query) preSplitPaths(selector map[string]any) {
 			continue
 		}
 		if _, ok := q.splitPaths[k]; !ok {
-			q.splitPaths[k] = strings.Split(k, ".")
+			q.splitPaths[k] = splitQueryPath(k)
 		}
 	}
 }
@@ -120,7 +141,7 @@ func (q *Query) Sort(sortDef map[string]string) *Query {
 	for field, order := range sortDef {
 		q.sortFields = append(q.sortFields, SortField{
 			Field:      field,
-			SplitField: strings.Split(field, "."),
+			SplitField: splitQueryPath(field),
 			Desc:       strings.ToLower(order) == "desc",
 		})
 	}
@@ -131,12 +152,26 @@ func (q *Query) Sort(sortDef map[string]string) *Query {
 func (q *Query) OrderBy(field string, desc bool) *Query {
 	q.sortFields = append(q.sortFields, SortField{
 		Field:      field,
-		SplitField: strings.Split(field, "."),
+		SplitField: splitQueryPath(field),
 		Desc:       desc,
 	})
 	return q
 }
 
+// WithOptions 设置查询执行选项，作用于 Exec、Count、ForEach 等执行方法。
+func (q *Query) WithOptions(opts QueryOptions) *Query {
+	q.timeout = opts.Timeout
+	return q
+}
+
+// withTimeout 按 QueryOptions.Timeout 为 ctx 附加截止时间，未设置时原样返回。
+func (q *Query) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if q.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, q.timeout)
+}
+
 // Skip 设置跳过的文档数。
 func (q *Query) Skip(n int) *Query {
 	q.skip = n
@@ -166,7 +201,7 @@ func (q *Query) Equals(field string, value any) *Query {
 	}
 	q.selector[field] = value
 	if _, ok := q.splitPaths[field]; !ok {
-		q.splitPaths[field] = strings.Split(field, ".")
+		q.splitPaths[field] = splitQueryPath(field)
 	}
 	return q
 }
@@ -181,7 +216,7 @@ func (q *Query) Gt(field string, value any) *Query {
 	}
 	q.selector[field].(map[string]any)["$gt"] = value
 	if _, ok := q.splitPaths[field]; !ok {
-		q.splitPaths[field] = strings.Split(field, ".")
+		q.splitPaths[field] = splitQueryPath(field)
 	}
 	return q
 }
@@ -196,7 +231,7 @@ func (q *Query) Gte(field string, value any) *Query {
 	}
 	q.selector[field].(map[string]any)["$gte"] = value
 	if _, ok := q.splitPaths[field]; !ok {
-		q.splitPaths[field] = strings.Split(field, ".")
+		q.splitPaths[field] = splitQueryPath(field)
 	}
 	return q
 }
@@ -211,7 +246,7 @@ func (q *Query) Lt(field string, value any) *Query {
 	}
 	q.selector[field].(map[string]any)["$lt"] = value
 	if _, ok := q.splitPaths[field]; !ok {
-		q.splitPaths[field] = strings.Split(field, ".")
+		q.splitPaths[field] = splitQueryPath(field)
 	}
 	return q
 }
@@ -226,7 +261,7 @@ func (q *Query) Lte(field string, value any) *Query {
 	}
 	q.selector[field].(map[string]any)["$lte"] = value
 	if _, ok := q.splitPaths[field]; !ok {
-		q.splitPaths[field] = strings.Split(field, ".")
+		q.splitPaths[field] = splitQueryPath(field)
 	}
 	return q
 }
@@ -241,7 +276,7 @@ func (q *Query) In(field string, values []any) *Query {
 	}
 	q.selector[field].(map[string]any)["$in"] = values
 	if _, ok := q.splitPaths[field]; !ok {
-		q.splitPaths[field] = strings.Split(field, ".")
+		q.splitPaths[field] = splitQueryPath(field)
 	}
 	return q
 }
@@ -256,7 +291,7 @@ func (q *Query) Nin(field string, values []any) *Query {
 	}
 	q.selector[field].(map[string]any)["$nin"] = values
 	if _, ok := q.splitPaths[field]; !ok {
-		q.splitPaths[field] = strings.Split(field, ".")
+		q.splitPaths[field] = splitQueryPath(field)
 	}
 	return q
 }
@@ -271,7 +306,7 @@ func (q *Query) Exists(field string, exists bool) *Query {
 	}
 	q.selector[field].(map[string]any)["$exists"] = exists
 	if _, ok := q.splitPaths[field]; !ok {
-		q.splitPaths[field] = strings.Split(field, ".")
+		q.splitPaths[field] = splitQueryPath(field)
 	}
 	return q
 }
@@ -286,7 +321,7 @@ func (q *Query) Type(field string, typeStr string) *Query {
 	}
 	q.selector[field].(map[string]any)["$type"] = typeStr
 	if _, ok := q.splitPaths[field]; !ok {
-		q.splitPaths[field] = strings.Split(field, ".")
+		q.splitPaths[field] = splitQueryPath(field)
 	}
 	return q
 }
@@ -301,7 +336,7 @@ func (q *Query) Regex(field string, pattern string) *Query {
 	}
 	q.selector[field].(map[string]any)["$regex"] = pattern
 	if _, ok := q.splitPaths[field]; !ok {
-		q.splitPaths[field] = strings.Split(field, ".")
+		q.splitPaths[field] = splitQueryPath(field)
 	}
 	return q
 }
@@ -349,12 +384,25 @@ func convertToAnySlice(slice []map[string]any) []any {
 	return result
 }
 
+// candidateIDs 返回需要加载的候选文档 ID：$text 查询使用全文搜索的匹配结果，否则尝试使用索引。
+func (q *Query) candidateIDs(ctx context.Context) ([]string, bool) {
+	if q.textScores != nil {
+		return q.textIDs(), true
+	}
+	return q.tryUseIndex(ctx)
+}
+
 // tryUseIndex 尝试使用索引优化查询，返回匹配的文档ID列表和是否使用了索引。
 func (q *Query) tryUseIndex(ctx context.Context) ([]string, bool) {
 	if len(q.selector) == 0 {
 		return nil, false
 	}
 
+	// 地理查询优先使用地理索引
+	if docIDs, ok := q.tryUseGeoIndex(ctx); ok {
+		return docIDs, true
+	}
+
 	// 查找最佳索引
 	bestIndex := q.findBestIndex()
 	if bestIndex == nil {
@@ -394,15 +442,27 @@ func (q *Query) tryUseIndex(ctx context.Context) ([]string, bool) {
 
 	rawPrefix := bstore.BucketKey(bucketName, unsafeB2S(prefix))
 	var docIDs []string
+	seen := make(map[string]struct{})
 
 	// 使用 IterateRawPrefix 直接从 Key 中压榨出所有 ID，无需 Unmarshal 列表
 	err := q.collection.store.IterateRawPrefix(ctx, rawPrefix, func(key, value []byte) error {
-		// key 格式是 bucket:values\0docID，这里 key 已经去掉了 bucket: 前缀
-		id := decodeIndexKey(key)
-		if id != "" {
-			// decodeIndexKey 使用了 unsafeB2S，由于 id 需要在回调外使用，必须克隆
-			docIDs = append(docIDs, strings.Clone(id))
+		// key 格式是 bucket:values\0docID，这里 key 已经去掉了扫描前缀；
+		// 完全匹配时前缀包含分隔符，剩余部分即为文档 ID
+		id := unsafeB2S(key)
+		if !fullMatch {
+			id = decodeIndexKey(key)
+		}
+		if id == "" {
+			return nil
+		}
+		// 多键索引中同一文档可能出现多次
+		if _, ok := seen[id]; ok {
+			return nil
 		}
+		// decodeIndexKey 使用了 unsafeB2S，由于 id 需要在回调外使用，必须克隆
+		id = strings.Clone(id)
+		seen[id] = struct{}{}
+		docIDs = append(docIDs, id)
 		return nil
 	})
 
@@ -433,6 +493,10 @@ func (q *Query) findBestIndex() *Index {
 	maxMatchCount := 0
 
 	for _, idx := range q.collection.schema.Indexes {
+		// 地理索引只用于 $near / $geoWithin，见 tryUseGeoIndex
+		if idx.Type == IndexTypeGeo {
+			continue
+		}
 		matchCount := q.countIndexMatches(idx, queryFields)
 		if matchCount > 0 && matchCount > maxMatchCount {
 			// 检查是否所有索引字段都在查询中（完全匹配）
@@ -524,12 +588,33 @@ func (q *Query) getSelectorValue(field string) interface{} {
 
 // Exec 执行查询并返回结果。
 func (q *Query) Exec(ctx context.Context) ([]Document, error) {
+	docs, err := q.exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	docs, err = q.collection.transformDocuments(ctx, docs)
+	if err != nil {
+		return nil, err
+	}
+	return q.project(docs), nil
+}
+
+// exec 执行查询并返回未经读取转换器处理的原始文档。
+// ctx 在扫描的每个文档之间检查，取消或超时时丢弃部分结果并返回 ctx.Err()。
+func (q *Query) exec(ctx context.Context) ([]Document, error) {
+	ctx, cancel := q.withTimeout(ctx)
+	defer cancel()
+
 	if err := q.collection.beginOp(ctx); err != nil {
 		return nil, err
 	}
 	defer q.collection.endOp()
 
-	logrus.WithField("collection", q.collection.name).Debug("Executing query")
+	q.collection.logger.Debug("Executing query", "collection", q.collection.name)
+
+	if err := q.resolveText(ctx); err != nil {
+		return nil, err
+	}
 
 	q.collection.mu.RLock()
 	defer q.collection.mu.RUnlock()
@@ -538,22 +623,39 @@ func (q *Query) Exec(ctx context.Context) ([]Document, error) {
 		return nil, NewError(ErrorTypeClosed, "collection is closed", nil)
 	}
 
+	if err := validateRegexes(q.selector); err != nil {
+		return nil, err
+	}
+	if err := q.validateCursor(); err != nil {
+		return nil, err
+	}
+
+	var cacheGen uint64
+	if q.cache != nil {
+		if docs, ok := q.cache.load(); ok {
+			return docs, nil
+		}
+		cacheGen = q.cache.generation()
+	}
+
 	var results []map[string]any
 
 	// 尝试使用索引优化查询
-	indexedDocIDs, useIndex := q.tryUseIndex(ctx)
+	indexedDocIDs, useIndex := q.candidateIDs(ctx)
 	if useIndex {
-		logrus.WithFields(logrus.Fields{
-			"collection":  q.collection.name,
-			"indexedDocs": len(indexedDocIDs),
-		}).Debug("Query using index")
+		q.collection.logger.Debug("Query using index", "collection", q.collection.name, "indexedDocs", len(indexedDocIDs))
 	} else {
-		logrus.WithField("collection", q.collection.name).Debug("Query using full scan")
+		q.collection.logger.Debug("Query using full scan", "collection", q.collection.name)
 	}
 
-	if useIndex && len(indexedDocIDs) > 0 {
+	if q.textScores != nil && len(indexedDocIDs) == 0 {
+		// $text 没有匹配，无需扫描
+	} else if useIndex && len(indexedDocIDs) > 0 {
 		// 使用索引：只加载匹配的文档
 		for _, docID := range indexedDocIDs {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			var doc map[string]any
 			err := q.collection.store.GetValue(ctx, q.collection.name, docID, func(data []byte) error {
 				if data != nil {
@@ -607,9 +709,18 @@ func (q *Query) Exec(ctx context.Context) ([]Document, error) {
 		}
 	}
 
-	// 排序
-	if len(q.sortFields) > 0 {
+	// 排序；未指定排序时 $near 查询按距离由近到远返回
+	if q.withCursor {
+		var err error
+		if results, err = q.applyCursor(results); err != nil {
+			return nil, err
+		}
+	} else if len(q.sortFields) > 0 {
 		q.sortResults(results)
+	} else if q.textScores != nil {
+		q.sortByTextScore(results)
+	} else {
+		q.sortByNear(results)
 	}
 
 	// Skip
@@ -624,6 +735,10 @@ func (q *Query) Exec(ctx context.Context) ([]Document, error) {
 		results = results[:q.limit]
 	}
 
+	if q.cache != nil {
+		q.cache.store(results, cacheGen)
+	}
+
 	// 转换为 Document
 	docs := make([]Document, len(results))
 	for i, r := range results {
@@ -652,11 +767,18 @@ func (q *Query) FindOne(ctx context.Context) (Document, error) {
 
 // Count 返回匹配的文档数量。
 func (q *Query) Count(ctx context.Context) (int, error) {
+	ctx, cancel := q.withTimeout(ctx)
+	defer cancel()
+
 	if err := q.collection.beginOp(ctx); err != nil {
 		return 0, err
 	}
 	defer q.collection.endOp()
 
+	if err := q.resolveText(ctx); err != nil {
+		return 0, err
+	}
+
 	q.collection.mu.RLock()
 	defer q.collection.mu.RUnlock()
 
@@ -664,14 +786,23 @@ func (q *Query) Count(ctx context.Context) (int, error) {
 		return 0, fmt.Errorf("collection is closed")
 	}
 
+	if err := validateRegexes(q.selector); err != nil {
+		return 0, err
+	}
+
 	var count int
 
 	// 尝试使用索引优化查询
-	indexedDocIDs, useIndex := q.tryUseIndex(ctx)
+	indexedDocIDs, useIndex := q.candidateIDs(ctx)
 
-	if useIndex && len(indexedDocIDs) > 0 {
+	if q.textScores != nil && len(indexedDocIDs) == 0 {
+		// $text 没有匹配，无需扫描
+	} else if useIndex && len(indexedDocIDs) > 0 {
 		// 使用索引：只检查匹配的文档
 		for _, docID := range indexedDocIDs {
+			if err := ctx.Err(); err != nil {
+				return 0, err
+			}
 			var doc map[string]any
 			err := q.collection.store.GetValue(ctx, q.collection.name, docID, func(data []byte) error {
 				if data != nil {
@@ -733,7 +864,8 @@ func (q *Query) match(doc map[string]any) bool {
 	if len(q.selector) == 0 {
 		return true
 	}
-	return q.matchSelector(doc, q.selector)
+	// 计算字段在过滤阶段按需求值，使其可以出现在选择器中
+	return q.matchSelector(q.collection.withVirtualFields(doc), q.selector)
 }
 
 func (q *Query) matchSelector(doc map[string]any, selector map[string]any) bool {
@@ -780,13 +912,26 @@ func (q *Query) matchSelector(doc map[string]any, selector map[string]any) bool
 					}
 				}
 			}
+		case "$text":
+			if !q.matchText(doc) {
+				return false
+			}
 		default:
 			// 字段匹配：使用预拆分的路径压榨性能
 			parts, ok := q.splitPaths[key]
 			if !ok {
-				parts = strings.Split(key, ".")
+				parts = splitQueryPath(key)
 			}
 			docValue := getNestedValueByParts(doc, parts)
+			if docValue == nil && len(parts) > 1 {
+				// 路径经过数组时，任一元素满足条件即匹配
+				if values, fanned := getNestedValuesByParts(doc, parts); fanned {
+					if !q.matchAnyValue(key, values, value) {
+						return false
+					}
+					continue
+				}
+			}
 			fieldExists := fieldExistsInDocByParts(doc, parts)
 			if !q.matchFieldWithExistence(key, docValue, value, fieldExists) {
 				return false
@@ -797,33 +942,54 @@ func (q *Query) matchSelector(doc map[string]any, selector map[string]any) bool
 }
 
 // fieldExistsInDocByParts 使用预拆分路径检查字段是否存在。
-func fieldExistsInDocByParts(doc map[string]any, parts []string) bool {
-	current := doc
-	for i, part := range parts {
-		if current == nil {
-			return false
-		}
-		if i == len(parts)-1 {
-			_, exists := current[part]
-			return exists
+// matchAnyValue 判断数组展开得到的任一值是否满足条件；没有任何值时按字段不存在处理。
+func (q *Query) matchAnyValue(fieldKey string, values []any, selectorValue any) bool {
+	if len(values) == 0 {
+		return q.matchFieldWithExistence(fieldKey, nil, selectorValue, false)
+	}
+	for _, v := range values {
+		if q.matchFieldWithExistence(fieldKey, v, selectorValue, true) {
+			return true
 		}
-		if next, ok := current[part]; ok {
-			if nextMap, ok := next.(map[string]any); ok {
-				current = nextMap
-			} else {
+	}
+	return false
+}
+
+func fieldExistsInDocByParts(doc map[string]any, parts []string) bool {
+	var current any = doc
+	for _, part := range parts {
+		switch node := current.(type) {
+		case map[string]any:
+			next, ok := node[part]
+			if !ok {
 				return false
 			}
-		} else {
+			current = next
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return false
+			}
+			current = node[i]
+		default:
 			return false
 		}
 	}
-	return false
+	return len(parts) > 0
 }
 
 func (q *Query) matchFieldWithExistence(fieldKey string, docValue, selectorValue any, fieldExists bool) bool {
 	// 如果选择器值是 map，则包含操作符
 	if ops, ok := selectorValue.(map[string]any); ok {
 		for op, opValue := range ops {
+			if op == "$regex" {
+				// $options 与 $regex 同级出现
+				options, _ := ops["$options"].(string)
+				if !matchRegex(docValue, opValue, options) {
+					return false
+				}
+				continue
+			}
 			if !q.matchOperatorWithExistence(fieldKey, docValue, op, opValue, fieldExists) {
 				return false
 			}
@@ -835,6 +1001,75 @@ func (q *Query) matchFieldWithExistence(fieldKey string, docValue, selectorValue
 	return compareEqual(docValue, selectorValue)
 }
 
+// matchRegex 使用 $regex 模式及 $options 标志匹配字符串字段，非字符串或模式无效时不匹配。
+func matchRegex(docValue, pattern any, options string) bool {
+	p, ok := pattern.(string)
+	if !ok {
+		return false
+	}
+	s, ok := docValue.(string)
+	if !ok {
+		return false
+	}
+	re, err := compileRegex(p, options)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(s)
+}
+
+// compileRegex 将 $options 标志转换为 Go 正则内联标志后编译模式。
+// 支持 i（忽略大小写）、m（多行，^ $ 匹配行首行尾）、s（. 匹配换行），每个标志至多出现一次。
+func compileRegex(pattern, options string) (*regexp.Regexp, error) {
+	if options == "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, &InvalidRegexError{Pattern: pattern, Err: err}
+		}
+		return re, nil
+	}
+	for i, flag := range options {
+		if !strings.ContainsRune("ims", flag) || strings.ContainsRune(options[:i], flag) {
+			return nil, &InvalidRegexError{Pattern: pattern, Options: options}
+		}
+	}
+	re, err := regexp.Compile("(?" + options + ")" + pattern)
+	if err != nil {
+		return nil, &InvalidRegexError{Pattern: pattern, Options: options, Err: err}
+	}
+	return re, nil
+}
+
+// validateRegexes 检查选择器中所有 $regex 模式及其 $options 是否有效。
+func validateRegexes(selector map[string]any) error {
+	for key, value := range selector {
+		switch v := value.(type) {
+		case map[string]any:
+			if pattern, ok := v["$regex"].(string); ok {
+				options, _ := v["$options"].(string)
+				if _, err := compileRegex(pattern, options); err != nil {
+					return err
+				}
+			}
+			if err := validateRegexes(v); err != nil {
+				return err
+			}
+		case []any:
+			if key != "$and" && key != "$or" && key != "$nor" {
+				continue
+			}
+			for _, cond := range v {
+				if condMap, ok := cond.(map[string]any); ok {
+					if err := validateRegexes(condMap); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func (q *Query) matchOperatorWithExistence(fieldKey string, docValue any, op string, opValue any, fieldExists bool) bool {
 	switch op {
 	case "$eq":
@@ -888,16 +1123,10 @@ func (q *Query) matchOperatorWithExistence(fieldKey string, docValue any, op str
 	case "$type":
 		return matchType(docValue, opValue)
 	case "$regex":
-		if pattern, ok := opValue.(string); ok {
-			if s, ok := docValue.(string); ok {
-				re, err := regexp.Compile(pattern)
-				if err != nil {
-					return false
-				}
-				return re.MatchString(s)
-			}
-		}
-		return false
+		return matchRegex(docValue, opValue, "")
+	case "$options":
+		// 仅作为 $regex 的标志，由 matchFieldWithExistence 处理
+		return true
 	case "$elemMatch":
 		if arr, ok := docValue.([]any); ok {
 			if criteria, ok := opValue.(map[string]any); ok {
@@ -941,6 +1170,15 @@ func (q *Query) matchOperatorWithExistence(fieldKey string, docValue any, op str
 			}
 		}
 		return false
+	case "$near":
+		return matchNear(docValue, opValue)
+	case "$geoWithin":
+		return matchGeoWithin(docValue, opValue)
+	case "$nearDate":
+		if spec, ok := opValue.(map[string]any); ok {
+			return matchNearDate(docValue, spec, time.Now())
+		}
+		return false
 	case "$mod":
 		if modArr, ok := opValue.([]any); ok && len(modArr) == 2 {
 			divisor := toFloat64(modArr[0])
@@ -955,6 +1193,77 @@ func (q *Query) matchOperatorWithExistence(fieldKey string, docValue any, op str
 	return false
 }
 
+// matchNearDate 检查时间字段是否落在相对 now 的时间窗口内。
+// spec 形如 {"$within": "7d", "$direction": "past"}，$direction 默认为 "past"。
+// 字段值支持 RFC3339 时间字符串或 Unix 时间戳（秒）。
+func matchNearDate(docValue any, spec map[string]any, now time.Time) bool {
+	withinStr, ok := spec["$within"].(string)
+	if !ok {
+		return false
+	}
+	within, err := parseRelativeDuration(withinStr)
+	if err != nil || within < 0 {
+		return false
+	}
+
+	t, ok := toTime(docValue)
+	if !ok {
+		return false
+	}
+
+	direction, _ := spec["$direction"].(string)
+	switch direction {
+	case "", "past":
+		return !t.After(now) && !t.Before(now.Add(-within))
+	case "future":
+		return !t.Before(now) && !t.After(now.Add(within))
+	}
+	return false
+}
+
+// parseRelativeDuration 解析时长字符串，在 time.ParseDuration 的基础上支持 "d"（天）与 "w"（周）。
+func parseRelativeDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n := len(s); n > 1 {
+		var unit time.Duration
+		switch s[n-1] {
+		case 'd':
+			unit = 24 * time.Hour
+		case 'w':
+			unit = 7 * 24 * time.Hour
+		}
+		if unit > 0 {
+			v, err := strconv.ParseFloat(s[:n-1], 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+			}
+			return time.Duration(v * float64(unit)), nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+// toTime 将 RFC3339 字符串或 Unix 时间戳（秒）转换为 time.Time。
+func toTime(v any) (time.Time, bool) {
+	switch val := v.(type) {
+	case time.Time:
+		return val, true
+	case string:
+		t, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	default:
+		if isNumeric(val) {
+			sec := toFloat64(val)
+			whole := math.Floor(sec)
+			return time.Unix(int64(whole), int64((sec-whole)*1e9)), true
+		}
+	}
+	return time.Time{}, false
+}
+
 func matchType(value any, typeValue any) bool {
 	typeStr, ok := typeValue.(string)
 	if !ok {
@@ -1054,8 +1363,8 @@ func toFloat64(v any) float64 {
 func (q *Query) sortResults(results []map[string]any) {
 	sort.Slice(results, func(i, j int) bool {
 		for _, sf := range q.sortFields {
-			vi := getNestedValueByParts(results[i], sf.SplitField)
-			vj := getNestedValueByParts(results[j], sf.SplitField)
+			vi := q.sortValue(results[i], sf)
+			vj := q.sortValue(results[j], sf)
 
 			cmp := compareValues(vi, vj)
 			if cmp == 0 {
@@ -1115,14 +1424,14 @@ func AsQueryCollection(c Collection) QueryCollection {
 	return nil
 }
 
-// GetStore 返回底层存储（供内部使用）。
+// GetStore 返回底层 Badger 存储（供内部使用），使用其他存储后端时返回 nil。
 func (c *collection) GetStore() *bstore.Store {
-	return c.store
+	return c.store.badgerStore()
 }
 
 // Remove 删除匹配查询的所有文档。
 func (q *Query) Remove(ctx context.Context) (int, error) {
-	docs, err := q.Exec(ctx)
+	docs, err := q.exec(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -1144,7 +1453,7 @@ func (q *Query) Remove(ctx context.Context) (int, error) {
 
 // Update 更新匹配查询的所有文档。
 func (q *Query) Update(ctx context.Context, updates map[string]any) (int, error) {
-	docs, err := q.Exec(ctx)
+	docs, err := q.exec(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -1164,7 +1473,7 @@ func (q *Query) Update(ctx context.Context, updates map[string]any) (int, error)
 		updateMaps[i] = data
 	}
 
-	results, err := q.collection.BulkUpsert(ctx, updateMaps)
+	results, err := q.collection.bulkUpsert(ctx, updateMaps)
 	if err != nil {
 		return 0, err
 	}
@@ -1239,6 +1548,76 @@ func (q *Query) Observe(ctx context.Context) <-chan []Document {
 	return resultChan
 }
 
+// ObserveWithDebounce 与 Observe 类似，但会对变更事件进行防抖。
+// 首个相关变更到达后开启一个 debounce 窗口，窗口内的所有变更只会触发一次重新查询，
+// 窗口内没有变更时不会发送结果。适用于批量写入时避免结果集泛滥。
+func (q *Query) ObserveWithDebounce(ctx context.Context, debounce time.Duration) <-chan []Document {
+	if debounce <= 0 {
+		return q.Observe(ctx)
+	}
+
+	resultChan := make(chan []Document, 1)
+	// 先订阅再执行初始查询，避免遗漏两者之间的变更
+	changes := q.collection.Changes()
+
+	go func() {
+		defer close(resultChan)
+
+		initial, err := q.Exec(ctx)
+		if err != nil {
+			initial = []Document{}
+		}
+
+		select {
+		case resultChan <- initial:
+		case <-ctx.Done():
+			return
+		}
+
+		lastResult := initial
+		var timer *time.Timer
+		var timerC <-chan time.Time
+
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case event, ok := <-changes:
+				if !ok {
+					return
+				}
+				// 窗口已开启时只需累积事件，不重置计时器
+				if timerC == nil && q.mightAffectQuery(event) {
+					timer = time.NewTimer(debounce)
+					timerC = timer.C
+				}
+			case <-timerC:
+				timer, timerC = nil, nil
+
+				queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				newResult, err := q.Exec(queryCtx)
+				cancel()
+				if err != nil {
+					continue
+				}
+				if !resultsEqual(lastResult, newResult) {
+					lastResult = newResult
+					select {
+					case resultChan <- newResult:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return resultChan
+}
+
 // mightAffectQuery 检查变更事件是否可能影响查询结果。
 func (q *Query) mightAffectQuery(event ChangeEvent) bool {
 	// 如果查询选择器为空，所有变更都可能影响结果
@@ -0,0 +1,130 @@
+package rxdb
+
+import (
+	"sync"
+	"time"
+)
+
+// queryCache 查询结果缓存。
+// 缓存在集合发出相关变更事件时同步失效：事件文档属于上次结果，或变更后的文档匹配选择器。
+type queryCache struct {
+	query *Query
+	ttl   time.Duration // 缓存有效期，<= 0 表示仅在相关变更时失效
+
+	mu      sync.Mutex
+	results []map[string]any
+	ids     map[string]struct{}
+	expires time.Time
+	valid   bool
+	gen     uint64 // 每次失效递增，避免并发执行的查询写入已过期的结果
+}
+
+// Cache 启用查询结果缓存。
+// 结果在 ttl 到期或收到可能影响结果的变更事件（文档此前在结果中，或变更后匹配选择器）前保持有效，
+// 期间 Exec 直接返回缓存结果而不访问存储。ttl <= 0 表示不按时间失效。
+// 应在排序、分页等条件设置完成后调用。
+func (q *Query) Cache(ttl time.Duration) *Query {
+	q.cache = &queryCache{query: q, ttl: ttl}
+	return q
+}
+
+// load 返回仍然有效的缓存结果。
+func (qc *queryCache) load() ([]Document, bool) {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+
+	if !qc.valid {
+		return nil, false
+	}
+	if qc.ttl > 0 && time.Now().After(qc.expires) {
+		qc.invalidateLocked()
+		return nil, false
+	}
+
+	c := qc.query.collection
+	docs := make([]Document, len(qc.results))
+	for i, r := range qc.results {
+		id, err := c.extractPrimaryKey(r)
+		if err != nil {
+			return nil, false
+		}
+		docs[i] = acquireDocument(id, DeepCloneMap(r), c)
+	}
+	return docs, true
+}
+
+// generation 返回当前失效代数，执行查询前记录，用于 store 判断结果是否仍然可用。
+func (qc *queryCache) generation() uint64 {
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+	return qc.gen
+}
+
+// store 保存查询结果。执行期间缓存已失效（gen 变化）时丢弃结果。
+func (qc *queryCache) store(results []map[string]any, gen uint64) {
+	c := qc.query.collection
+	c.queryCachesMu.Lock()
+	defer c.queryCachesMu.Unlock()
+	qc.mu.Lock()
+	defer qc.mu.Unlock()
+
+	if qc.gen != gen {
+		return
+	}
+
+	qc.results = make([]map[string]any, len(results))
+	qc.ids = make(map[string]struct{}, len(results))
+	for i, r := range results {
+		qc.results[i] = DeepCloneMap(r)
+		if id, err := c.extractPrimaryKey(r); err == nil {
+			qc.ids[id] = struct{}{}
+		}
+	}
+	qc.expires = time.Now().Add(qc.ttl)
+	qc.valid = true
+
+	if c.queryCaches == nil {
+		c.queryCaches = make(map[*queryCache]struct{})
+	}
+	c.queryCaches[qc] = struct{}{}
+}
+
+// affectedBy 判断变更事件是否可能改变缓存结果。调用者需持有 qc.mu。
+func (qc *queryCache) affectedBy(event ChangeEvent) bool {
+	if _, ok := qc.ids[event.ID]; ok {
+		return true
+	}
+	if qc.query.hasText() {
+		// 全文匹配结果只能通过重新搜索确定
+		return true
+	}
+	if event.Doc != nil {
+		return qc.query.match(event.Doc)
+	}
+	return false
+}
+
+// invalidateLocked 使缓存失效。调用者需持有 qc.mu。
+func (qc *queryCache) invalidateLocked() {
+	qc.valid = false
+	qc.results = nil
+	qc.ids = nil
+	qc.gen++
+}
+
+// invalidateQueryCaches 使受变更事件影响的查询缓存失效，并移除已过期的缓存。
+func (c *collection) invalidateQueryCaches(event ChangeEvent) {
+	c.queryCachesMu.Lock()
+	defer c.queryCachesMu.Unlock()
+
+	now := time.Now()
+	for qc := range c.queryCaches {
+		qc.mu.Lock()
+		expired := qc.ttl > 0 && now.After(qc.expires)
+		if !qc.valid || expired || qc.affectedBy(event) {
+			qc.invalidateLocked()
+			delete(c.queryCaches, qc)
+		}
+		qc.mu.Unlock()
+	}
+}
@@ -0,0 +1,175 @@
+package rxdb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Cursor 游标分页令牌。
+// 内容为 base64 编码的 JSON，记录上一页最后一条结果的排序字段值与主键，本身是字符串，可直接序列化为 JSON。
+// 调用方应将其视为不透明值，原样传给 Query.After。
+type Cursor string
+
+// cursorPosition 游标解码后的位置信息。
+type cursorPosition struct {
+	Order  []string `json:"o"`  // 排序签名（字段:asc|desc），用于校验游标与查询是否匹配
+	Values []any    `json:"v"`  // 排序字段值
+	ID     string   `json:"id"` // 主键，排序字段值相同时用于确定先后
+}
+
+// ExecWithCursor 执行查询并返回结果及指向最后一条结果的游标。
+// 结果按排序字段排序，字段值相同时按主键升序，保证分页顺序确定；没有结果时返回空游标。
+// 下一页使用相同的选择器与排序调用 After(cursor)，无需 Skip 即可从该位置继续。
+func (q *Query) ExecWithCursor(ctx context.Context) ([]Document, Cursor, error) {
+	q.withCursor = true
+	docs, err := q.exec(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next Cursor
+	if len(docs) > 0 {
+		last := docs[len(docs)-1]
+		if next, err = q.encodeCursor(last.ID(), last.Data()); err != nil {
+			return nil, "", err
+		}
+	}
+
+	docs, err = q.collection.transformDocuments(ctx, docs)
+	if err != nil {
+		return nil, "", err
+	}
+	return q.project(docs), next, nil
+}
+
+// After 从游标位置之后继续查询（不包含游标指向的文档）。
+// 游标只记录排序值与主键，因此游标指向的文档被删除或修改后仍可继续分页；
+// 游标的排序签名与当前查询不一致时，Exec 返回验证错误。空游标表示从头开始。
+func (q *Query) After(cursor Cursor) *Query {
+	q.withCursor = true
+	q.after = nil
+	q.cursorErr = nil
+	if q.cache != nil {
+		// 游标改变了结果范围，已缓存的结果不再适用
+		q.cache.mu.Lock()
+		q.cache.invalidateLocked()
+		q.cache.mu.Unlock()
+	}
+	if cursor == "" {
+		return q
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(string(cursor))
+	if err != nil {
+		q.cursorErr = NewError(ErrorTypeValidation, "invalid cursor", err)
+		return q
+	}
+	var pos cursorPosition
+	if err := json.Unmarshal(raw, &pos); err != nil {
+		q.cursorErr = NewError(ErrorTypeValidation, "invalid cursor", err)
+		return q
+	}
+	q.after = &pos
+	return q
+}
+
+// sortSignature 返回排序签名，用于校验游标。
+func (q *Query) sortSignature() []string {
+	order := make([]string, len(q.sortFields))
+	for i, sf := range q.sortFields {
+		dir := "asc"
+		if sf.Desc {
+			dir = "desc"
+		}
+		order[i] = sf.Field + ":" + dir
+	}
+	return order
+}
+
+// encodeCursor 生成指向指定文档的游标。
+func (q *Query) encodeCursor(id string, doc map[string]any) (Cursor, error) {
+	pos := cursorPosition{
+		Order:  q.sortSignature(),
+		Values: make([]any, len(q.sortFields)),
+		ID:     id,
+	}
+	for i, sf := range q.sortFields {
+		pos.Values[i] = q.sortValue(doc, sf)
+	}
+	raw, err := json.Marshal(pos)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return Cursor(base64.RawURLEncoding.EncodeToString(raw)), nil
+}
+
+// validateCursor 检查游标是否可用于当前查询。
+func (q *Query) validateCursor() error {
+	if q.cursorErr != nil {
+		return q.cursorErr
+	}
+	if q.after == nil {
+		return nil
+	}
+	order := q.sortSignature()
+	if len(q.after.Values) != len(order) || strings.Join(q.after.Order, ",") != strings.Join(order, ",") {
+		return NewError(ErrorTypeValidation, fmt.Sprintf("cursor sort order [%s] does not match query sort order [%s]",
+			strings.Join(q.after.Order, ", "), strings.Join(order, ", ")), nil)
+	}
+	return nil
+}
+
+// cursorEntry 游标分页排序时使用的文档及其排序键。
+type cursorEntry struct {
+	doc    map[string]any
+	id     string
+	values []any
+}
+
+// compareCursor 按排序字段与主键比较两个位置。
+func (q *Query) compareCursor(values []any, id string, otherValues []any, otherID string) int {
+	for i, sf := range q.sortFields {
+		cmp := compareValues(values[i], otherValues[i])
+		if cmp == 0 {
+			continue
+		}
+		if sf.Desc {
+			return -cmp
+		}
+		return cmp
+	}
+	return strings.Compare(id, otherID)
+}
+
+// applyCursor 按排序字段与主键排序结果，并丢弃游标位置及之前的文档。
+func (q *Query) applyCursor(results []map[string]any) ([]map[string]any, error) {
+	entries := make([]cursorEntry, 0, len(results))
+	for _, doc := range results {
+		id, err := q.collection.extractPrimaryKey(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract primary key: %w", err)
+		}
+		values := make([]any, len(q.sortFields))
+		for i, sf := range q.sortFields {
+			values[i] = q.sortValue(doc, sf)
+		}
+		if q.after != nil && q.compareCursor(values, id, q.after.Values, q.after.ID) <= 0 {
+			continue
+		}
+		entries = append(entries, cursorEntry{doc: doc, id: id, values: values})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return q.compareCursor(entries[i].values, entries[i].id, entries[j].values, entries[j].id) < 0
+	})
+
+	sorted := make([]map[string]any, len(entries))
+	for i, e := range entries {
+		sorted[i] = e.doc
+	}
+	return sorted, nil
+}
@@ -0,0 +1,51 @@
+package rxdb
+
+// Select 设置结果只包含指定字段（支持点号路径），主键字段总是保留；文档中不存在的字段值为 nil。
+// 投影在排序之后进行，因此排序字段即使未被选择也能正常参与排序。
+// 投影结果是不关联集合的只读视图，调用 Save、Update 等写操作会返回错误。
+func (q *Query) Select(fields ...string) *Query {
+	q.selectFields = append(q.selectFields, fields...)
+	return q
+}
+
+// ExcludeFields 设置从结果中移除的字段（支持点号路径），主键字段不会被移除，可与 Select 组合使用。
+func (q *Query) ExcludeFields(fields ...string) *Query {
+	q.excludeFields = append(q.excludeFields, fields...)
+	return q
+}
+
+// project 按 Select/ExcludeFields 投影查询结果，未设置投影时原样返回。
+func (q *Query) project(docs []Document) []Document {
+	if len(q.selectFields) == 0 && len(q.excludeFields) == 0 {
+		return docs
+	}
+
+	pkFields := q.collection.getPrimaryKeyFields()
+	projected := make([]Document, len(docs))
+	for i, doc := range docs {
+		data := doc.Data()
+		if len(q.selectFields) > 0 {
+			selected := make(map[string]any, len(q.selectFields)+len(pkFields))
+			for _, field := range pkFields {
+				if v, ok := data[field]; ok {
+					selected[field] = v
+				}
+			}
+			for _, field := range q.selectFields {
+				parts := splitQueryPath(field)
+				setNestedValue(selected, parts, deepCloneValue(getNestedValueByParts(data, parts)))
+			}
+			data = selected
+		} else {
+			data = DeepCloneMap(data)
+		}
+		for _, field := range q.excludeFields {
+			if q.collection.isPrimaryKeyField(field) {
+				continue
+			}
+			data, _ = withoutNestedValue(data, splitQueryPath(field))
+		}
+		projected[i] = acquireDocument(doc.ID(), data, nil)
+	}
+	return projected
+}
@@ -2,33 +2,30 @@ package rxdb
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"testing"
 	"time"
+
+	bstore "github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
 )
 
 func TestQuery_Find(t *testing.T) {
 	ctx := context.Background()
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: "../../data/test_query.db",
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer os.RemoveAll("../../data/test_query.db")
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
 	collection.Insert(ctx, map[string]any{
@@ -112,25 +109,14 @@ func TestQuery_Find(t *testing.T) {
 
 func TestCollection_FindAndFindOne(t *testing.T) {
 	ctx := context.Background()
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: "../../data/test_collection_query.db",
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer os.RemoveAll("../../data/test_collection_query.db")
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "heroes", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "heroes", schema)
 
 	_, _ = collection.Insert(ctx, map[string]any{
 		"id":    "1",
@@ -166,25 +152,14 @@ func TestCollection_FindAndFindOne(t *testing.T) {
 
 func TestQuery_Sort(t *testing.T) {
 	ctx := context.Background()
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: "../../data/test_sort.db",
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer os.RemoveAll("../../data/test_sort.db")
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
 	collection.Insert(ctx, map[string]any{
@@ -231,25 +206,14 @@ func TestQuery_Sort(t *testing.T) {
 
 func TestQuery_LimitSkip(t *testing.T) {
 	ctx := context.Background()
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: "../../data/test_limit.db",
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer os.RemoveAll("../../data/test_limit.db")
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入多个文档
 	for i := 1; i <= 10; i++ {
@@ -292,25 +256,14 @@ func TestQuery_LimitSkip(t *testing.T) {
 
 func TestQuery_Count(t *testing.T) {
 	ctx := context.Background()
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: "../../data/test_count.db",
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer os.RemoveAll("../../data/test_count.db")
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
 	for i := 1; i <= 5; i++ {
@@ -353,25 +306,14 @@ func TestQuery_Count(t *testing.T) {
 
 func TestQuery_FindOne(t *testing.T) {
 	ctx := context.Background()
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: "../../data/test_findone.db",
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer os.RemoveAll("../../data/test_findone.db")
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
 	collection.Insert(ctx, map[string]any{
@@ -415,27 +357,15 @@ func TestQuery_FindOne(t *testing.T) {
 
 func TestQuery_Operator_Eq(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_eq.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
 	testDocs := []map[string]any{
@@ -446,7 +376,7 @@ func TestQuery_Operator_Eq(t *testing.T) {
 	}
 
 	for _, doc := range testDocs {
-		_, err = collection.Insert(ctx, doc)
+		_, err := collection.Insert(ctx, doc)
 		if err != nil {
 			t.Fatalf("Failed to insert: %v", err)
 		}
@@ -535,27 +465,15 @@ func TestQuery_Operator_Eq(t *testing.T) {
 
 func TestQuery_Operator_Ne(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_ne.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30})
 	collection.Insert(ctx, map[string]any{"id": "2", "name": "Bob", "age": 25})
@@ -581,27 +499,15 @@ func TestQuery_Operator_Ne(t *testing.T) {
 
 func TestQuery_Operator_Gt(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_gt.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "age": 20})
 	collection.Insert(ctx, map[string]any{"id": "2", "age": 30})
@@ -627,27 +533,15 @@ func TestQuery_Operator_Gt(t *testing.T) {
 
 func TestQuery_Operator_Lt(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_lt.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "age": 20})
 	collection.Insert(ctx, map[string]any{"id": "2", "age": 30})
@@ -673,27 +567,15 @@ func TestQuery_Operator_Lt(t *testing.T) {
 
 func TestQuery_Operator_Nin(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_nin.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice"})
 	collection.Insert(ctx, map[string]any{"id": "2", "name": "Bob"})
@@ -719,27 +601,15 @@ func TestQuery_Operator_Nin(t *testing.T) {
 
 func TestQuery_Operator_Exists(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_exists.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "email": "alice@example.com"})
 	collection.Insert(ctx, map[string]any{"id": "2", "name": "Bob"}) // 没有 email
@@ -764,27 +634,15 @@ func TestQuery_Operator_Exists(t *testing.T) {
 
 func TestQuery_Operator_And(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_and.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30, "color": "blue"})
 	collection.Insert(ctx, map[string]any{"id": "2", "name": "Bob", "age": 30, "color": "red"})
@@ -813,27 +671,15 @@ func TestQuery_Operator_And(t *testing.T) {
 
 func TestQuery_Operator_Or(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_or.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30})
 	collection.Insert(ctx, map[string]any{"id": "2", "name": "Bob", "age": 25})
@@ -858,27 +704,15 @@ func TestQuery_Operator_Or(t *testing.T) {
 
 func TestQuery_Operator_All(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_all.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "tags": []any{"tag1", "tag2", "tag3"}})
 	collection.Insert(ctx, map[string]any{"id": "2", "tags": []any{"tag1", "tag2"}})
@@ -900,27 +734,15 @@ func TestQuery_Operator_All(t *testing.T) {
 
 func TestQuery_Operator_Size(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_size.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "tags": []any{"tag1"}})
 	collection.Insert(ctx, map[string]any{"id": "2", "tags": []any{"tag1", "tag2"}})
@@ -946,27 +768,15 @@ func TestQuery_Operator_Size(t *testing.T) {
 
 func TestQuery_Operator_Not(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_not.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30})
 	collection.Insert(ctx, map[string]any{"id": "2", "name": "Bob", "age": 25})
@@ -994,27 +804,15 @@ func TestQuery_Operator_Not(t *testing.T) {
 
 func TestQuery_Operator_Nor(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_nor.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30})
 	collection.Insert(ctx, map[string]any{"id": "2", "name": "Bob", "age": 25})
@@ -1043,27 +841,15 @@ func TestQuery_Operator_Nor(t *testing.T) {
 
 func TestQuery_Operator_ElemMatch(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_elemmatch.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{
 		"id": "1",
@@ -1103,27 +889,15 @@ func TestQuery_Operator_ElemMatch(t *testing.T) {
 
 func TestQuery_Operator_Type(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_type.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "value": "string"})
 	collection.Insert(ctx, map[string]any{"id": "2", "value": 123})
@@ -1149,27 +923,15 @@ func TestQuery_Operator_Type(t *testing.T) {
 
 func TestQuery_Operator_Mod(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_mod.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "age": 20})
 	collection.Insert(ctx, map[string]any{"id": "2", "age": 25})
@@ -1193,27 +955,15 @@ func TestQuery_Operator_Mod(t *testing.T) {
 // TestQuery_Operator_Mod_BoundaryCases 测试 Mod 操作符的边界情况
 func TestQuery_Operator_Mod_BoundaryCases(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_mod_boundary.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据，包括边界值
 	collection.Insert(ctx, map[string]any{"id": "1", "age": 0})   // 0 % 5 == 0
@@ -1271,27 +1021,15 @@ func TestQuery_Operator_Mod_BoundaryCases(t *testing.T) {
 // TestQuery_Operator_Not_Nested 测试嵌套 NOT
 func TestQuery_Operator_Not_Nested(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_not_nested.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30, "active": true})
 	collection.Insert(ctx, map[string]any{"id": "2", "name": "Bob", "age": 25, "active": false})
@@ -1334,27 +1072,15 @@ func TestQuery_Operator_Not_Nested(t *testing.T) {
 
 func TestQuery_Chain(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_chain.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30})
 	collection.Insert(ctx, map[string]any{"id": "2", "name": "Bob", "age": 25})
@@ -1383,27 +1109,15 @@ func TestQuery_Chain(t *testing.T) {
 
 func TestQuery_SortMultipleFields(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_sort_multiple.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30, "score": 80})
 	collection.Insert(ctx, map[string]any{"id": "2", "name": "Bob", "age": 30, "score": 90})
@@ -1439,27 +1153,15 @@ func TestQuery_SortMultipleFields(t *testing.T) {
 
 func TestQuery_Operator_Gte(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_gte.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "age": 20})
 	collection.Insert(ctx, map[string]any{"id": "2", "age": 30})
@@ -1490,27 +1192,15 @@ func TestQuery_Operator_Gte(t *testing.T) {
 
 func TestQuery_Operator_Lte(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_lte.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "age": 20})
 	collection.Insert(ctx, map[string]any{"id": "2", "age": 30})
@@ -1541,27 +1231,15 @@ func TestQuery_Operator_Lte(t *testing.T) {
 
 func TestQuery_Observe(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_observe.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入初始数据
 	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30})
@@ -1609,27 +1287,15 @@ func TestQuery_Observe(t *testing.T) {
 
 func TestQuery_ObserveMultiple(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_observe_multiple.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30})
 
@@ -1664,43 +1330,176 @@ func TestQuery_ObserveMultiple(t *testing.T) {
 	}
 }
 
-func TestQuery_Update(t *testing.T) {
+func TestQuery_ObserveWithDebounce(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_update.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
-	schema := Schema{
+	collection, err := db.Collection(ctx, "test", Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
-	}
-
-	collection, err := db.Collection(ctx, "test", schema)
+	})
 	if err != nil {
 		t.Fatalf("Failed to create collection: %v", err)
 	}
 
-	// 插入测试数据
-	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30, "status": "active"})
-	collection.Insert(ctx, map[string]any{"id": "2", "name": "Bob", "age": 25, "status": "active"})
-	collection.Insert(ctx, map[string]any{"id": "3", "name": "Charlie", "age": 35, "status": "inactive"})
-
 	qc := AsQueryCollection(collection)
-	query := qc.Find(map[string]any{"status": "active"})
+	query := qc.Find(map[string]any{})
 
-	// 更新匹配的文档
-	updatedCount, err := query.Update(ctx, map[string]any{"status": "updated"})
-	if err != nil {
-		t.Fatalf("Failed to update documents: %v", err)
-	}
+	observeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resultChan := query.ObserveWithDebounce(observeCtx, 100*time.Millisecond)
+
+	// 接收初始结果
+	initialResults := <-resultChan
+	if len(initialResults) != 0 {
+		t.Fatalf("Expected 0 initial results, got %d", len(initialResults))
+	}
+
+	// 快速连续插入 100 个文档
+	for i := 0; i < 100; i++ {
+		if _, err := collection.Insert(ctx, map[string]any{"id": fmt.Sprintf("doc%03d", i), "n": i}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	// 收集结果直到获得全部文档
+	emissions := 0
+	var last []Document
+	timeout := time.After(3 * time.Second)
+	for len(last) < 100 {
+		select {
+		case results, ok := <-resultChan:
+			if !ok {
+				t.Fatal("result channel closed unexpectedly")
+			}
+			emissions++
+			last = results
+		case <-timeout:
+			t.Fatalf("timed out waiting for debounced results, got %d docs after %d emissions", len(last), emissions)
+		}
+	}
+
+	if emissions > 5 {
+		t.Errorf("Expected debounced emissions to be far fewer than 100 inserts, got %d", emissions)
+	}
+
+	// 窗口内没有变更时不应再发送
+	select {
+	case results := <-resultChan:
+		t.Errorf("Expected no emission without changes, got %d results", len(results))
+	case <-time.After(250 * time.Millisecond):
+	}
+}
+
+func TestQuery_Cancellation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 1M-document scan in short mode")
+	}
+	ctx := context.Background()
+	backend := NewMemoryBackend()
+	db, err := CreateDatabase(ctx, DatabaseOptions{Name: "testdb", Path: filepath.Join(t.TempDir(), "testdb.db"), Backend: backend})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+	collection := newTestCollection(t, db, "large", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	// 直接写入存储，避免逐个插入 1M 文档
+	const total = 1000000
+	ops := make([]BatchOp, 0, total)
+	for i := 0; i < total; i++ {
+		id := fmt.Sprintf("doc%07d", i)
+		ops = append(ops, BatchOp{Key: bstore.BucketKey("large", id), Value: []byte(`{"id":"` + id + `","n":` + strconv.Itoa(i) + `}`)})
+	}
+	if err := backend.BatchWrite(ctx, ops); err != nil {
+		t.Fatalf("BatchWrite failed: %v", err)
+	}
+	ops = nil
+
+	// 不匹配任何文档的条件迫使全表扫描（完整扫描需要数秒）；
+	// 测量 cancel 到返回的延迟，取多次中的最小值以排除调度与 GC 抖动
+	selector := map[string]any{"n": map[string]any{"$lt": -1}}
+	run := func(name string, exec func(ctx context.Context) error) {
+		t.Helper()
+		best := time.Hour
+		for attempt := 0; attempt < 2; attempt++ {
+			scanCtx, cancel := context.WithCancel(ctx)
+			done := make(chan time.Time, 1)
+			var execErr error
+			go func() {
+				execErr = exec(scanCtx)
+				done <- time.Now()
+			}()
+			// 内存后端的快照复制约需数百毫秒，等待扫描进入逐文档匹配阶段后再取消
+			time.Sleep(1500 * time.Millisecond)
+			cancelled := time.Now()
+			cancel()
+			select {
+			case returned := <-done:
+				if !errors.Is(execErr, context.Canceled) {
+					t.Fatalf("%s: expected context.Canceled, got %v", name, execErr)
+				}
+				if latency := returned.Sub(cancelled); latency < best {
+					best = latency
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%s: scan did not stop after cancellation", name)
+			}
+		}
+		if best >= time.Millisecond {
+			t.Errorf("%s: expected sub-millisecond cancellation latency, got %v", name, best)
+		}
+	}
+
+	run("Exec", func(ctx context.Context) error {
+		docs, err := collection.Find(selector).Exec(ctx)
+		if docs != nil {
+			t.Errorf("Expected partial results to be discarded, got %d", len(docs))
+		}
+		return err
+	})
+	run("ForEach", func(ctx context.Context) error {
+		return collection.ForEach(ctx, selector, func(Document) error { return nil })
+	})
+
+	// QueryOptions.Timeout 在内部附加截止时间
+	start := time.Now()
+	docs, err := collection.Find(selector).WithOptions(QueryOptions{Timeout: 20 * time.Millisecond}).Exec(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || docs != nil {
+		t.Errorf("Expected deadline exceeded with no results, got %d docs, %v", len(docs), err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Timeout was not enforced, query ran for %v", elapsed)
+	}
+}
+
+func TestQuery_Update(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDatabase(t)
+
+	schema := Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	}
+
+	collection := newTestCollection(t, db, "test", schema)
+
+	// 插入测试数据
+	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30, "status": "active"})
+	collection.Insert(ctx, map[string]any{"id": "2", "name": "Bob", "age": 25, "status": "active"})
+	collection.Insert(ctx, map[string]any{"id": "3", "name": "Charlie", "age": 35, "status": "inactive"})
+
+	qc := AsQueryCollection(collection)
+	query := qc.Find(map[string]any{"status": "active"})
+
+	// 更新匹配的文档
+	updatedCount, err := query.Update(ctx, map[string]any{"status": "updated"})
+	if err != nil {
+		t.Fatalf("Failed to update documents: %v", err)
+	}
 
 	if updatedCount != 2 {
 		t.Errorf("Expected 2 documents to be updated, got %d", updatedCount)
@@ -1736,27 +1535,15 @@ func TestQuery_Update(t *testing.T) {
 
 func TestQuery_Remove(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_remove.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
 	collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30})
@@ -1803,30 +1590,18 @@ func TestQuery_Remove(t *testing.T) {
 
 func TestQuery_IndexUsage(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_index.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
-	_, err = collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30})
+	_, err := collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30})
 	if err != nil {
 		t.Fatalf("Failed to insert: %v", err)
 	}
@@ -1893,27 +1668,15 @@ func TestQuery_IndexUsage_Performance(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	dbPath := "../../data/test_query_index_perf.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入大量数据
 	numDocs := 1000
@@ -1966,30 +1729,18 @@ func TestQuery_IndexUsage_Performance(t *testing.T) {
 
 func TestQuery_CompositeIndex(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_composite_index.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
-	_, err = collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30, "city": "NYC"})
+	_, err := collection.Insert(ctx, map[string]any{"id": "1", "name": "Alice", "age": 30, "city": "NYC"})
 	if err != nil {
 		t.Fatalf("Failed to insert: %v", err)
 	}
@@ -2046,31 +1797,19 @@ func TestQuery_CompositeIndex(t *testing.T) {
 // TestQuery_SortStability 测试排序稳定性
 func TestQuery_SortStability(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_sort_stability.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入多个具有相同排序键值的文档
 	for i := 0; i < 5; i++ {
-		_, err = collection.Insert(ctx, map[string]any{
+		_, err := collection.Insert(ctx, map[string]any{
 			"id":   fmt.Sprintf("doc%d", i),
 			"name": "Same Name",
 			"age":  30,
@@ -2116,30 +1855,18 @@ func TestQuery_SortStability(t *testing.T) {
 // TestQuery_Operator_Ne_NullValue 测试不等于操作符的空值处理
 func TestQuery_Operator_Ne_NullValue(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_ne_null.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Alice",
 		"age":  30,
@@ -2199,27 +1926,15 @@ func TestQuery_Operator_Ne_NullValue(t *testing.T) {
 // 注意：当前实现不支持日期类型，日期值会被转换为字符串进行比较
 func TestQuery_Operator_Gt_Date(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_gt_date.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据，使用 RFC3339 格式的日期字符串
 	testDocs := []map[string]any{
@@ -2230,7 +1945,7 @@ func TestQuery_Operator_Gt_Date(t *testing.T) {
 	}
 
 	for _, doc := range testDocs {
-		_, err = collection.Insert(ctx, doc)
+		_, err := collection.Insert(ctx, doc)
 		if err != nil {
 			t.Fatalf("Failed to insert: %v", err)
 		}
@@ -2276,27 +1991,15 @@ func TestQuery_Operator_Gt_Date(t *testing.T) {
 // TestQuery_Operator_Gt_String 测试字符串大于比较
 func TestQuery_Operator_Gt_String(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_gt_string.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
 	testDocs := []map[string]any{
@@ -2307,7 +2010,7 @@ func TestQuery_Operator_Gt_String(t *testing.T) {
 	}
 
 	for _, doc := range testDocs {
-		_, err = collection.Insert(ctx, doc)
+		_, err := collection.Insert(ctx, doc)
 		if err != nil {
 			t.Fatalf("Failed to insert: %v", err)
 		}
@@ -2340,27 +2043,15 @@ func TestQuery_Operator_Gt_String(t *testing.T) {
 // TestQuery_Operator_In 测试基本数组包含查询
 func TestQuery_Operator_In(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_in.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
 	testDocs := []map[string]any{
@@ -2371,7 +2062,7 @@ func TestQuery_Operator_In(t *testing.T) {
 	}
 
 	for _, doc := range testDocs {
-		_, err = collection.Insert(ctx, doc)
+		_, err := collection.Insert(ctx, doc)
 		if err != nil {
 			t.Fatalf("Failed to insert: %v", err)
 		}
@@ -2446,30 +2137,18 @@ func TestQuery_Operator_In(t *testing.T) {
 // TestQuery_Operator_In_EmptyArray 测试空数组处理
 func TestQuery_Operator_In_EmptyArray(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_in_empty.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Alice",
 	})
@@ -2497,27 +2176,15 @@ func TestQuery_Operator_In_EmptyArray(t *testing.T) {
 // TestQuery_Operator_Regex 测试基本正则匹配
 func TestQuery_Operator_Regex(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_regex.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
 	testDocs := []map[string]any{
@@ -2528,7 +2195,7 @@ func TestQuery_Operator_Regex(t *testing.T) {
 	}
 
 	for _, doc := range testDocs {
-		_, err = collection.Insert(ctx, doc)
+		_, err := collection.Insert(ctx, doc)
 		if err != nil {
 			t.Fatalf("Failed to insert: %v", err)
 		}
@@ -2617,27 +2284,15 @@ func TestQuery_Operator_Regex(t *testing.T) {
 // TestQuery_Operator_Regex_Complex 测试复杂正则表达式
 func TestQuery_Operator_Regex_Complex(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_regex_complex.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
 	testDocs := []map[string]any{
@@ -2648,7 +2303,7 @@ func TestQuery_Operator_Regex_Complex(t *testing.T) {
 	}
 
 	for _, doc := range testDocs {
-		_, err = collection.Insert(ctx, doc)
+		_, err := collection.Insert(ctx, doc)
 		if err != nil {
 			t.Fatalf("Failed to insert: %v", err)
 		}
@@ -2683,33 +2338,124 @@ func TestQuery_Operator_Regex_Complex(t *testing.T) {
 	}
 }
 
-// TestQuery_Operator_And_Nested 测试嵌套 AND 操作符
-func TestQuery_Operator_And_Nested(t *testing.T) {
+// TestQuery_Operator_RegexOptions 测试 $regex 的 $options 标志
+func TestQuery_Operator_RegexOptions(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_and_nested.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
+	collection := newTestCollection(t, db, "test", schema)
+
+	testDocs := []map[string]any{
+		{"id": "doc1", "name": "Alice", "bio": "engineer\nlikes Go"},
+		{"id": "doc2", "name": "alice cooper", "bio": "singer"},
+		{"id": "doc3", "name": "Bob", "bio": "first line\nsecond line"},
+	}
+
+	for _, doc := range testDocs {
+		_, err := collection.Insert(ctx, doc)
+		if err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	qc := AsQueryCollection(collection)
+
+	// 不带标志时区分大小写
+	results, err := qc.Find(map[string]any{
+		"name": map[string]any{"$regex": "^alice"},
+	}).Exec(ctx)
 	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 case-sensitive result, got %d", len(results))
 	}
 
+	// i：忽略大小写
+	results, err = qc.Find(map[string]any{
+		"name": map[string]any{"$regex": "^alice", "$options": "i"},
+	}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected 2 case-insensitive results, got %d", len(results))
+	}
+
+	// m：^ 匹配每一行的开头
+	results, err = qc.Find(map[string]any{
+		"bio": map[string]any{"$regex": "^likes"},
+	}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no match without multiline flag, got %d", len(results))
+	}
+	results, err = qc.Find(map[string]any{
+		"bio": map[string]any{"$regex": "^likes", "$options": "m"},
+	}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	if len(results) != 1 || results[0].ID() != "doc1" {
+		t.Errorf("Expected doc1 with multiline flag, got %d results", len(results))
+	}
+
+	// s：. 匹配换行
+	count, err := qc.Find(map[string]any{
+		"bio": map[string]any{"$regex": "line.second", "$options": "s"},
+	}).Count(ctx)
+	if err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 result with dotall flag, got %d", count)
+	}
+
+	// 无效标志及重复标志返回 InvalidRegexError
+	for _, options := range []string{"x", "ii", "im g"} {
+		_, err = qc.Find(map[string]any{
+			"name": map[string]any{"$regex": "alice", "$options": options},
+		}).Exec(ctx)
+		if !IsInvalidRegexError(err) {
+			t.Errorf("Expected InvalidRegexError for options %q, got %v", options, err)
+		}
+	}
+
+	// 嵌套在 $or 中的无效模式同样返回错误
+	_, err = qc.Find(map[string]any{
+		"$or": []any{
+			map[string]any{"name": map[string]any{"$regex": "(unclosed"}},
+		},
+	}).Exec(ctx)
+	var regexErr *InvalidRegexError
+	if !errors.As(err, &regexErr) || regexErr.Pattern != "(unclosed" {
+		t.Errorf("Expected InvalidRegexError for invalid pattern, got %v", err)
+	}
+}
+
+// TestQuery_Operator_And_Nested 测试嵌套 AND 操作符
+func TestQuery_Operator_And_Nested(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDatabase(t)
+
+	schema := Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	}
+
+	collection := newTestCollection(t, db, "test", schema)
+
 	// 插入测试数据
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Alice",
 		"age":  30,
@@ -2759,31 +2505,19 @@ func TestQuery_Operator_And_Nested(t *testing.T) {
 // TestQuery_Operator_Or_Nested 测试嵌套 OR 操作符
 func TestQuery_Operator_Or_Nested(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_or_nested.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
 	for i := 1; i <= 5; i++ {
-		_, err = collection.Insert(ctx, map[string]any{
+		_, err := collection.Insert(ctx, map[string]any{
 			"id":   fmt.Sprintf("doc%d", i),
 			"name": fmt.Sprintf("User%d", i),
 			"age":  20 + i*5,
@@ -2823,30 +2557,18 @@ func TestQuery_Operator_Or_Nested(t *testing.T) {
 // TestQuery_Operator_AndOr_Combined 测试 AND 和 OR 组合
 func TestQuery_Operator_AndOr_Combined(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_andor_combined.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Alice",
 		"age":  30,
@@ -2891,30 +2613,18 @@ func TestQuery_Operator_AndOr_Combined(t *testing.T) {
 // TestQuery_Operator_Exists_NotExists 测试字段不存在检查
 func TestQuery_Operator_Exists_NotExists(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_exists_not.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"name": "Alice",
 		"age":  30,
@@ -2964,30 +2674,18 @@ func TestQuery_Operator_Exists_NotExists(t *testing.T) {
 // TestQuery_Operator_Type_ArrayObject 测试数组和对象类型
 func TestQuery_Operator_Type_ArrayObject(t *testing.T) {
 	ctx := context.Background()
-	dbPath := "../../data/test_query_type_array_object.db"
-	defer os.RemoveAll(dbPath)
 
-	db, err := CreateDatabase(ctx, DatabaseOptions{
-		Name: "testdb",
-		Path: dbPath,
-	})
-	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
-	}
-	defer db.Close(ctx)
+	db := newTestDatabase(t)
 
 	schema := Schema{
 		PrimaryKey: "id",
 		RevField:   "_rev",
 	}
 
-	collection, err := db.Collection(ctx, "test", schema)
-	if err != nil {
-		t.Fatalf("Failed to create collection: %v", err)
-	}
+	collection := newTestCollection(t, db, "test", schema)
 
 	// 插入测试数据
-	_, err = collection.Insert(ctx, map[string]any{
+	_, err := collection.Insert(ctx, map[string]any{
 		"id":   "doc1",
 		"tags": []any{"tag1", "tag2"},
 		"meta": map[string]any{"key": "value"},
@@ -3036,3 +2734,575 @@ func TestQuery_Operator_Type_ArrayObject(t *testing.T) {
 		t.Errorf("Expected 1 result, got %d", len(results))
 	}
 }
+
+func TestQuery_CoerceTypes(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDatabase(t)
+
+	schemaJSON := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id":     map[string]any{"type": "string"},
+			"age":    map[string]any{"type": "integer"},
+			"score":  map[string]any{"type": "number"},
+			"active": map[string]any{"type": "boolean"},
+		},
+	}
+
+	coerced, err := db.Collection(ctx, "coerced", Schema{
+		JSON:        schemaJSON,
+		PrimaryKey:  "id",
+		RevField:    "_rev",
+		CoerceTypes: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	plain, err := db.Collection(ctx, "plain", Schema{
+		JSON:       schemaJSON,
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	for _, col := range []Collection{coerced, plain} {
+		if _, err := col.Insert(ctx, map[string]any{"id": "1", "age": 30, "score": 1}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	// 整数字段使用 float64 查询
+	results, err := coerced.Find(map[string]any{"age": float64(30)}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 result for float64 query, got %d", len(results))
+	}
+
+	// 字符串形式的数值仅在开启转换时匹配
+	results, err = coerced.Find(map[string]any{"age": map[string]any{"$in": []any{"30"}}}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 result for string query with coercion, got %d", len(results))
+	}
+	results, err = plain.Find(map[string]any{"age": "30"}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected 0 results for string query without coercion, got %d", len(results))
+	}
+
+	// 插入时按声明类型转换
+	doc, err := coerced.Insert(ctx, map[string]any{"id": "2", "age": "31", "score": "2.5", "active": "true"})
+	if err != nil {
+		t.Fatalf("Failed to insert with coercion: %v", err)
+	}
+	if !isNumeric(doc.Get("age")) || doc.GetInt("age") != 31 {
+		t.Errorf("Expected age coerced to 31, got %T %v", doc.Get("age"), doc.Get("age"))
+	}
+	if doc.GetFloat("score") != 2.5 {
+		t.Errorf("Expected score 2.5, got %v", doc.Get("score"))
+	}
+	if !doc.GetBool("active") {
+		t.Errorf("Expected active true, got %v", doc.Get("active"))
+	}
+
+	if _, err := plain.Insert(ctx, map[string]any{"id": "2", "age": "31"}); err == nil {
+		t.Error("Expected validation error without coercion")
+	}
+
+	// 数值比较不区分 int 与 float64，未开启转换时同样匹配
+	for _, selector := range []map[string]any{
+		{"age": float64(30)},
+		{"score": map[string]any{"$gte": 1, "$lt": 1.5}},
+		{"age": map[string]any{"$in": []any{int64(30)}}},
+	} {
+		results, err := plain.Find(selector).Exec(ctx)
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		if len(results) != 1 {
+			t.Errorf("Expected 1 result for %v, got %d", selector, len(results))
+		}
+	}
+}
+
+func TestCollection_CoerceTypesOnWrite(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+
+	collection, err := db.Collection(ctx, "coerced", Schema{
+		JSON: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id":     map[string]any{"type": "string"},
+				"age":    map[string]any{"type": "integer"},
+				"active": map[string]any{"type": "boolean"},
+			},
+			"required": []any{"age"},
+		},
+		PrimaryKey:  "id",
+		RevField:    "_rev",
+		CoerceTypes: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	expectAge := func(id string, age int) {
+		t.Helper()
+		doc, err := collection.FindByID(ctx, id)
+		if err != nil {
+			t.Fatalf("Failed to find %s: %v", id, err)
+		}
+		if !isNumeric(doc.Get("age")) || doc.GetInt("age") != age {
+			t.Errorf("Expected %s age %d, got %T %v", id, age, doc.Get("age"), doc.Get("age"))
+		}
+	}
+
+	// Upsert 新文档与已有文档都在验证前转换
+	if _, err := collection.Upsert(ctx, map[string]any{"id": "u1", "age": "20"}); err != nil {
+		t.Fatalf("Failed to upsert new document: %v", err)
+	}
+	expectAge("u1", 20)
+	if _, err := collection.Upsert(ctx, map[string]any{"id": "u1", "age": "21", "active": "true"}); err != nil {
+		t.Fatalf("Failed to upsert existing document: %v", err)
+	}
+	expectAge("u1", 21)
+
+	// BulkUpsert 同时包含新文档与已有文档
+	_, err = collection.BulkUpsert(ctx, []map[string]any{
+		{"id": "u1", "age": "22"},
+		{"id": "b1", "age": "30.0"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to bulk upsert: %v", err)
+	}
+	expectAge("u1", 22)
+	expectAge("b1", 30)
+
+	// 更新已有文档时同样转换
+	doc, err := collection.FindByID(ctx, "b1")
+	if err != nil {
+		t.Fatalf("Failed to find b1: %v", err)
+	}
+	if err := doc.Update(ctx, map[string]any{"age": "31"}); err != nil {
+		t.Fatalf("Failed to update document: %v", err)
+	}
+	expectAge("b1", 31)
+
+	// 无法转换的值仍然返回验证错误
+	if _, err := collection.Upsert(ctx, map[string]any{"id": "u2", "age": "abc"}); err == nil {
+		t.Error("Expected validation error for non-numeric age")
+	}
+	if _, err := collection.BulkUpsert(ctx, []map[string]any{{"id": "u1", "age": "abc"}}); err == nil {
+		t.Error("Expected validation error for non-numeric age in bulk upsert")
+	}
+}
+
+func TestQuery_NearDate(t *testing.T) {
+	ctx := context.Background()
+
+	db := newTestDatabase(t)
+
+	collection, err := db.Collection(ctx, "events", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	now := time.Now()
+	docs := []map[string]any{
+		{"id": "past-in", "updatedAt": now.Add(-(6*24*time.Hour + 23*time.Hour)).Format(time.RFC3339)},
+		{"id": "past-out", "updatedAt": now.Add(-(7*24*time.Hour + time.Hour)).Format(time.RFC3339)},
+		{"id": "past-epoch", "updatedAt": now.Add(-24 * time.Hour).Unix()},
+		{"id": "future-in", "updatedAt": now.Add(30 * time.Minute).Format(time.RFC3339)},
+		{"id": "future-out", "updatedAt": now.Add(2 * time.Hour).Format(time.RFC3339)},
+		{"id": "invalid", "updatedAt": "not a date"},
+	}
+	for _, doc := range docs {
+		if _, err := collection.Insert(ctx, doc); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	ids := func(selector map[string]any) map[string]bool {
+		results, err := collection.Find(selector).Exec(ctx)
+		if err != nil {
+			t.Fatalf("Failed to query: %v", err)
+		}
+		found := make(map[string]bool)
+		for _, doc := range results {
+			found[doc.ID()] = true
+		}
+		return found
+	}
+
+	past := ids(map[string]any{"updatedAt": map[string]any{"$nearDate": map[string]any{"$within": "7d", "$direction": "past"}}})
+	if len(past) != 2 || !past["past-in"] || !past["past-epoch"] {
+		t.Errorf("Expected past-in and past-epoch, got %v", past)
+	}
+
+	// 未指定方向时默认为 past
+	if def := ids(map[string]any{"updatedAt": map[string]any{"$nearDate": map[string]any{"$within": "7d"}}}); len(def) != 2 {
+		t.Errorf("Expected default direction to be past, got %v", def)
+	}
+
+	future := ids(map[string]any{"updatedAt": map[string]any{"$nearDate": map[string]any{"$within": "1h", "$direction": "future"}}})
+	if len(future) != 1 || !future["future-in"] {
+		t.Errorf("Expected future-in only, got %v", future)
+	}
+
+	// 非法时长不匹配任何文档
+	if bad := ids(map[string]any{"updatedAt": map[string]any{"$nearDate": map[string]any{"$within": "abc"}}}); len(bad) != 0 {
+		t.Errorf("Expected no results for invalid duration, got %v", bad)
+	}
+}
+
+// storageReads 返回 Badger 累计的读取次数（点查询与迭代器创建）。
+func storageReads() int64 {
+	var n int64
+	for _, name := range []string{"badger_get_num_user", "badger_iterator_num_user"} {
+		if v, ok := expvar.Get(name).(*expvar.Int); ok {
+			n += v.Value()
+		}
+	}
+	return n
+}
+
+// TestQuery_Cache 测试查询结果缓存
+func TestQuery_Cache(t *testing.T) {
+	if backend := os.Getenv(testBackendEnv); backend != "" && backend != "badger" {
+		t.Skip("storage read counters are only exported by Badger")
+	}
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "test", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	for i := 0; i < 5; i++ {
+		if _, err := collection.Insert(ctx, map[string]any{"id": fmt.Sprintf("a%d", i), "type": "a"}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	qc := AsQueryCollection(collection)
+
+	// 在 100 次无关变更后执行查询，统计查询本身的存储读取次数
+	measure := func(q *Query, prefix string) int64 {
+		if _, err := q.Exec(ctx); err != nil {
+			t.Fatalf("Failed to execute query: %v", err)
+		}
+		var reads int64
+		for i := 0; i < 100; i++ {
+			if _, err := collection.Insert(ctx, map[string]any{"id": fmt.Sprintf("%s%d", prefix, i), "type": "b"}); err != nil {
+				t.Fatalf("Failed to insert: %v", err)
+			}
+			before := storageReads()
+			results, err := q.Exec(ctx)
+			reads += storageReads() - before
+			if err != nil {
+				t.Fatalf("Failed to execute query: %v", err)
+			}
+			if len(results) != 5 {
+				t.Fatalf("Expected 5 results, got %d", len(results))
+			}
+		}
+		return reads
+	}
+
+	uncached := measure(qc.Find(map[string]any{"type": "a"}), "u")
+	cachedQuery := qc.Find(map[string]any{"type": "a"}).Cache(time.Minute)
+	cached := measure(cachedQuery, "c")
+	if uncached == 0 {
+		t.Fatal("Expected uncached queries to read storage")
+	}
+	if cached*10 > uncached {
+		t.Errorf("Expected caching to reduce reads by at least 90%%: %d cached vs %d uncached", cached, uncached)
+	}
+
+	// 匹配的新文档使缓存失效
+	if _, err := collection.Insert(ctx, map[string]any{"id": "a5", "type": "a"}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	results, err := cachedQuery.Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	if len(results) != 6 {
+		t.Errorf("Expected 6 results after matching insert, got %d", len(results))
+	}
+
+	// 此前匹配、更新后不再匹配的文档同样使缓存失效
+	if _, err := collection.Upsert(ctx, map[string]any{"id": "a0", "type": "b"}); err != nil {
+		t.Fatalf("Failed to upsert: %v", err)
+	}
+	results, err = cachedQuery.Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	if len(results) != 5 {
+		t.Errorf("Expected 5 results after update, got %d", len(results))
+	}
+
+	// 修改返回的文档数据不影响缓存
+	results[0].Data()["type"] = "mutated"
+	results, err = cachedQuery.Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	for _, doc := range results {
+		if doc.GetString("type") != "a" {
+			t.Errorf("Expected cached document type 'a', got %q", doc.GetString("type"))
+		}
+	}
+
+	// ttl 到期后重新执行查询
+	ttlQuery := qc.Find(map[string]any{"type": "a"}).Cache(10 * time.Millisecond)
+	if _, err := ttlQuery.Exec(ctx); err != nil {
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	before := storageReads()
+	if _, err := ttlQuery.Exec(ctx); err != nil {
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	if reads := storageReads() - before; reads != 0 {
+		t.Errorf("Expected cached query not to read storage, got %d reads", reads)
+	}
+	time.Sleep(20 * time.Millisecond)
+	before = storageReads()
+	if _, err := ttlQuery.Exec(ctx); err != nil {
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	if storageReads() == before {
+		t.Error("Expected expired cache to re-read storage")
+	}
+}
+
+func TestQuery_Cursor(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "cursor", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	// 分数有重复，验证按主键确定同分文档的顺序
+	const total = 25
+	for i := 0; i < total; i++ {
+		_, err := collection.Insert(ctx, map[string]any{
+			"id":    fmt.Sprintf("doc%02d", i),
+			"score": i % 5,
+			"group": "a",
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+	if _, err := collection.Insert(ctx, map[string]any{"id": "other", "score": 10, "group": "b"}); err != nil {
+		t.Fatalf("Failed to insert document: %v", err)
+	}
+
+	var expected []string
+	for score := 4; score >= 0; score-- {
+		for i := 0; i < total; i++ {
+			if i%5 == score {
+				expected = append(expected, fmt.Sprintf("doc%02d", i))
+			}
+		}
+	}
+
+	newQuery := func() *Query {
+		return collection.Find(map[string]any{"group": "a"}).OrderBy("score", true).Limit(10)
+	}
+
+	var got []string
+	var cursor Cursor
+	for page := 0; ; page++ {
+		if page > total {
+			t.Fatal("Pagination did not terminate")
+		}
+		// 游标经过 JSON 传输后仍可使用
+		data, err := json.Marshal(map[string]any{"cursor": cursor})
+		if err != nil {
+			t.Fatalf("Failed to marshal cursor: %v", err)
+		}
+		var payload struct {
+			Cursor Cursor `json:"cursor"`
+		}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			t.Fatalf("Failed to unmarshal cursor: %v", err)
+		}
+
+		docs, next, err := newQuery().After(payload.Cursor).ExecWithCursor(ctx)
+		if err != nil {
+			t.Fatalf("Failed to execute page %d: %v", page, err)
+		}
+		if len(docs) == 0 {
+			if next != "" {
+				t.Errorf("Expected empty cursor for empty page, got %q", next)
+			}
+			break
+		}
+		for _, doc := range docs {
+			got = append(got, doc.ID())
+		}
+		cursor = next
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected pages %v, got %v", expected, got)
+	}
+
+	// 游标指向的文档被删除后仍可继续分页
+	docs, cursor, err := newQuery().ExecWithCursor(ctx)
+	if err != nil {
+		t.Fatalf("Failed to execute first page: %v", err)
+	}
+	if err := collection.Remove(ctx, docs[len(docs)-1].ID()); err != nil {
+		t.Fatalf("Failed to remove document: %v", err)
+	}
+	docs, err = newQuery().After(cursor).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to execute page after deletion: %v", err)
+	}
+	if len(docs) == 0 || docs[0].ID() != expected[10] {
+		t.Errorf("Expected next page to start at %s after deletion, got %v", expected[10], docs)
+	}
+
+	// 排序不一致或格式错误的游标返回验证错误
+	if _, err := collection.Find(map[string]any{"group": "a"}).OrderBy("score", false).After(cursor).Exec(ctx); !IsValidationError(err) {
+		t.Errorf("Expected validation error for mismatched sort order, got %v", err)
+	}
+	if _, err := newQuery().After("not-a-cursor!").Exec(ctx); !IsValidationError(err) {
+		t.Errorf("Expected validation error for malformed cursor, got %v", err)
+	}
+}
+
+func TestQuery_NestedFieldPaths(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "nested", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	docs := []map[string]any{
+		{"id": "u1", "address": map[string]any{"city": "Beijing", "zip": 100000}, "orders": []any{map[string]any{"sku": "A1", "qty": 1}, map[string]any{"sku": "B2", "qty": 5}}},
+		{"id": "u2", "address": map[string]any{"city": "Shanghai", "zip": 200000}, "orders": []any{map[string]any{"sku": "C3", "qty": 2}}},
+		{"id": "u3", "address": map[string]any{"city": "Beijing", "zip": 100100}, "orders": []any{}},
+		{"id": "u4", "name": "no address"},
+	}
+	for _, doc := range docs {
+		if _, err := collection.Insert(ctx, doc); err != nil {
+			t.Fatalf("Failed to insert document: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		selector map[string]any
+		want     []string
+	}{
+		{"equality", map[string]any{"address.city": "Beijing"}, []string{"u1", "u3"}},
+		{"range", map[string]any{"address.zip": map[string]any{"$gt": 100000, "$lt": 200000}}, []string{"u3"}},
+		{"exists", map[string]any{"address.city": map[string]any{"$exists": true}}, []string{"u1", "u2", "u3"}},
+		{"not exists", map[string]any{"address.city": map[string]any{"$exists": false}}, []string{"u4"}},
+		{"regex", map[string]any{"address.city": map[string]any{"$regex": "^Shang"}}, []string{"u2"}},
+		{"in", map[string]any{"address.city": map[string]any{"$in": []any{"Shanghai", "Shenzhen"}}}, []string{"u2"}},
+		{"array wildcard", map[string]any{"orders[*].sku": "B2"}, []string{"u1"}},
+		{"array implicit", map[string]any{"orders.sku": "C3"}, []string{"u2"}},
+		{"array range", map[string]any{"orders[*].qty": map[string]any{"$gte": 2}}, []string{"u1", "u2"}},
+		{"array in", map[string]any{"orders[*].sku": map[string]any{"$in": []any{"A1", "C3"}}}, []string{"u1", "u2"}},
+		{"array regex", map[string]any{"orders[*].sku": map[string]any{"$regex": "^[AC]"}}, []string{"u1", "u2"}},
+		{"array exists", map[string]any{"orders[*].sku": map[string]any{"$exists": true}}, []string{"u1", "u2"}},
+		{"array index", map[string]any{"orders.0.sku": "A1"}, []string{"u1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := collection.Find(tt.selector).OrderBy("id", false).Exec(ctx)
+			if err != nil {
+				t.Fatalf("Failed to execute query: %v", err)
+			}
+			var got []string
+			for _, doc := range results {
+				got = append(got, doc.ID())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// 按嵌套字段排序
+	results, err := collection.Find(map[string]any{"address.zip": map[string]any{"$exists": true}}).OrderBy("address.zip", true).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	var got []string
+	for _, doc := range results {
+		got = append(got, doc.ID())
+	}
+	if want := []string{"u2", "u3", "u1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected sort order %v, got %v", want, got)
+	}
+}
+
+func TestQuery_SelectFields(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "users", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	users := []map[string]any{
+		{"id": "u1", "name": "Alice", "score": 30, "secret": "a", "address": map[string]any{"city": "Berlin", "zip": "10115"}},
+		{"id": "u2", "name": "Bob", "score": 10, "secret": "b"},
+		{"id": "u3", "name": "Carol", "score": 20, "secret": "c", "address": map[string]any{"city": "Paris"}},
+	}
+	for _, user := range users {
+		if _, err := collection.Insert(ctx, user); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	// 排序字段 score 未被选择，但仍参与排序；主键总是保留；缺失字段为 nil
+	docs, err := collection.Find(nil).Select("name", "address.city").Sort(map[string]string{"score": "desc"}).Exec(ctx)
+	if err != nil {
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	expected := []map[string]any{
+		{"id": "u1", "name": "Alice", "address": map[string]any{"city": "Berlin"}},
+		{"id": "u3", "name": "Carol", "address": map[string]any{"city": "Paris"}},
+		{"id": "u2", "name": "Bob", "address": map[string]any{"city": nil}},
+	}
+	if len(docs) != len(expected) {
+		t.Fatalf("Expected %d documents, got %d", len(expected), len(docs))
+	}
+	for i, doc := range docs {
+		if !reflect.DeepEqual(doc.Data(), expected[i]) {
+			t.Errorf("Document %d = %v, want %v", i, doc.Data(), expected[i])
+		}
+	}
+
+	// ExcludeFields 移除指定字段，但不移除主键
+	doc, err := collection.Find(map[string]any{"id": "u1"}).ExcludeFields("secret", "address.zip", "_rev", "id").FindOne(ctx)
+	if err != nil {
+		t.Fatalf("Failed to execute query: %v", err)
+	}
+	expectedDoc := map[string]any{"id": "u1", "name": "Alice", "score": 30.0, "address": map[string]any{"city": "Berlin"}}
+	if !reflect.DeepEqual(doc.Data(), expectedDoc) {
+		t.Errorf("Excluded document = %v, want %v", doc.Data(), expectedDoc)
+	}
+
+	// 投影不影响存储中的文档，投影结果不能写回
+	if err := doc.Save(ctx); err == nil {
+		t.Error("Expected error saving projected document")
+	}
+	stored, err := collection.FindByID(ctx, "u1")
+	if err != nil {
+		t.Fatalf("Failed to find document: %v", err)
+	}
+	if stored.Get("secret") != "a" || stored.Data()["address"].(map[string]any)["zip"] != "10115" {
+		t.Errorf("Projection modified stored document: %v", stored.Data())
+	}
+}
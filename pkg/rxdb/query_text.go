@@ -0,0 +1,183 @@
+package rxdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// TextScoreField $text 查询的相关性分数伪字段，可用于 Sort/OrderBy，不会写入文档。
+const TextScoreField = "$score"
+
+// registerFulltext 记录集合上的全文搜索实例，供 $text 查询使用。
+func (c *collection) registerFulltext(fts *FulltextSearch) {
+	c.fulltextMu.Lock()
+	defer c.fulltextMu.Unlock()
+	c.fulltexts = append(c.fulltexts, fts)
+}
+
+// unregisterFulltext 移除已关闭的全文搜索实例。
+func (c *collection) unregisterFulltext(fts *FulltextSearch) {
+	c.fulltextMu.Lock()
+	defer c.fulltextMu.Unlock()
+	for i, f := range c.fulltexts {
+		if f == fts {
+			c.fulltexts = append(c.fulltexts[:i], c.fulltexts[i+1:]...)
+			return
+		}
+	}
+}
+
+// fulltextFor 返回 $text 使用的全文搜索实例：identifier 为空时使用最先添加的实例。
+func (c *collection) fulltextFor(identifier string) (*FulltextSearch, error) {
+	c.fulltextMu.RLock()
+	defer c.fulltextMu.RUnlock()
+	for _, fts := range c.fulltexts {
+		if identifier == "" || fts.identifier == identifier {
+			return fts, nil
+		}
+	}
+	if identifier != "" {
+		return nil, NewError(ErrorTypeValidation, fmt.Sprintf("$text: fulltext search %q is not attached to collection %s", identifier, c.name), nil)
+	}
+	return nil, NewError(ErrorTypeValidation, fmt.Sprintf("$text: no fulltext search attached to collection %s", c.name), nil)
+}
+
+// resolveText 解析选择器中的 $text 条件并执行全文搜索，记录匹配文档的分数。
+// 格式：{"$text": {"$search": "查询词", "$index": "可选的全文搜索 Identifier"}}，仅支持顶层使用。
+// 必须在获取集合锁之前调用：全文索引的初始化与搜索会读取集合。
+func (q *Query) resolveText(ctx context.Context) error {
+	q.textScores = nil
+
+	spec, ok := q.selector["$text"]
+	if !ok {
+		if selectorHasOperator(q.selector, "$text") {
+			return NewError(ErrorTypeValidation, "$text must be a top-level operator", nil)
+		}
+		return nil
+	}
+
+	specMap, ok := spec.(map[string]any)
+	if !ok {
+		return NewError(ErrorTypeValidation, "$text requires an object with $search", nil)
+	}
+	search, ok := specMap["$search"].(string)
+	if !ok {
+		return NewError(ErrorTypeValidation, "$text.$search must be a string", nil)
+	}
+	identifier, _ := specMap["$index"].(string)
+
+	fts, err := q.collection.fulltextFor(identifier)
+	if err != nil {
+		return err
+	}
+	scores, err := fts.scoreAll(ctx, search)
+	if err != nil {
+		return err
+	}
+	q.textScores = scores
+	return nil
+}
+
+// selectorHasOperator 检查选择器（含 $and/$or/$not/$nor 嵌套）中是否出现指定的顶层操作符。
+func selectorHasOperator(selector map[string]any, op string) bool {
+	for key, value := range selector {
+		if key == op {
+			return true
+		}
+		switch v := value.(type) {
+		case []any:
+			for _, item := range v {
+				if m, ok := item.(map[string]any); ok && selectorHasOperator(m, op) {
+					return true
+				}
+			}
+		case map[string]any:
+			if key == "$not" && selectorHasOperator(v, op) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasText 报告查询是否包含 $text 条件。
+func (q *Query) hasText() bool {
+	_, ok := q.selector["$text"]
+	return ok
+}
+
+// textIDs 返回 $text 匹配的文档 ID（按 ID 排序），作为候选集替代索引或全表扫描。
+func (q *Query) textIDs() []string {
+	ids := make([]string, 0, len(q.textScores))
+	for id := range q.textScores {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// matchText 判断文档是否在 $text 的匹配结果中。
+func (q *Query) matchText(doc map[string]any) bool {
+	if q.textScores == nil {
+		return false
+	}
+	id, err := q.collection.extractPrimaryKey(doc)
+	if err != nil {
+		return false
+	}
+	_, ok := q.textScores[id]
+	return ok
+}
+
+// textScore 返回文档的 $text 相关性分数，没有 $text 条件或未匹配时为 0。
+func (q *Query) textScore(doc map[string]any) float64 {
+	if q.textScores == nil {
+		return 0
+	}
+	id, err := q.collection.extractPrimaryKey(doc)
+	if err != nil {
+		return 0
+	}
+	return q.textScores[id]
+}
+
+// sortValue 返回文档的排序字段值，支持 $score 伪字段。
+func (q *Query) sortValue(doc map[string]any, sf SortField) any {
+	if sf.Field == TextScoreField {
+		return q.textScore(doc)
+	}
+	return getNestedValueByParts(doc, sf.SplitField)
+}
+
+// sortByTextScore 在未指定排序时，按 $text 相关性分数降序排列结果。
+func (q *Query) sortByTextScore(results []map[string]any) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return q.textScore(results[i]) > q.textScore(results[j])
+	})
+}
+
+// ExecWithScores 执行包含 $text 条件的查询，返回文档及其相关性分数（归一化到 0-1）。
+func (q *Query) ExecWithScores(ctx context.Context) ([]FulltextSearchResult, error) {
+	if !q.hasText() {
+		return nil, NewError(ErrorTypeValidation, "ExecWithScores requires a $text query", nil)
+	}
+	raw, err := q.exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scores := make([]float64, len(raw))
+	for i, doc := range raw {
+		scores[i] = q.textScores[doc.ID()]
+	}
+	docs, err := q.collection.transformDocuments(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	docs = q.project(docs)
+	results := make([]FulltextSearchResult, len(docs))
+	for i, doc := range docs {
+		results[i] = FulltextSearchResult{Document: doc, Score: scores[i]}
+	}
+	return results, nil
+}
@@ -0,0 +1,145 @@
+package rxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// maxHookAttempts 在写事务外调用 Before* 钩子的写入路径，因文档被并发修改而重试的最大次数。
+const maxHookAttempts = 10
+
+// errHookStale 表示调用 Before* 钩子之后、写事务读取之前文档已被并发修改，需要重新调用钩子。
+var errHookStale = errors.New("document changed while running hooks")
+
+// SchemaHooks 集合级别的生命周期钩子，未设置的钩子会被跳过。
+//
+// Before* 钩子返回错误时操作中止并返回该错误（以 %w 包装）。BeforeInsert/BeforeUpdate 返回的文档
+// 替换即将写入的数据，返回 nil 表示沿用传入的数据；返回的文档会重新经过 Schema 验证，且不能修改主键。
+// After* 钩子在写入提交后调用，接收的是文档副本，修改它不会影响存储。
+//
+// 钩子覆盖 Insert、BulkInsert、Upsert、BulkUpsert、Document.Save/Update/AtomicUpdate、FindAndModify、
+// Remove、BulkRemove、FindOneAndDelete 与事务（Transaction）中的写入；Truncate 不调用钩子。
+// Upsert、FindAndModify、FindOneAndDelete 与事务提交在加锁和开启写事务之前调用 Before* 钩子，
+// 若文档在此期间被并发修改则重新读取并再次调用；其余部分写入路径在持有集合锁期间调用，钩子中不应写入同一集合。
+type SchemaHooks struct {
+	// BeforeInsert 在插入新文档之前调用，可用于设置计算字段（如 createdAt）。
+	BeforeInsert func(ctx context.Context, data map[string]any) (map[string]any, error)
+	// AfterInsert 在新文档写入之后调用。
+	AfterInsert func(ctx context.Context, doc Document)
+	// BeforeUpdate 在更新已有文档之前调用。old 为更新前的文档，patch 为即将写入的完整新文档数据
+	// （整体替换的数据，或更新函数、操作符应用之后的结果）。
+	BeforeUpdate func(ctx context.Context, old Document, patch map[string]any) (map[string]any, error)
+	// AfterUpdate 在文档更新之后调用。
+	AfterUpdate func(ctx context.Context, doc Document)
+	// BeforeDelete 在删除文档之前调用，返回错误时文档不会被删除。
+	BeforeDelete func(ctx context.Context, id string) error
+	// AfterDelete 在文档删除之后调用。
+	AfterDelete func(ctx context.Context, id string)
+}
+
+// SetHooks 设置集合的生命周期钩子，替换之前设置的钩子（包括 DatabaseOptions.SchemaHooks 提供的默认钩子）。
+// 传入零值 SchemaHooks 取消全部钩子。
+func (c *collection) SetHooks(hooks SchemaHooks) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hooks = hooks
+}
+
+// schemaHooks 返回当前的生命周期钩子，调用时不能持有 c.mu。
+func (c *collection) schemaHooks() SchemaHooks {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hooks
+}
+
+// runBeforeInsert 调用 BeforeInsert 钩子，返回钩子处理后的文档。钩子未设置或返回 nil 时返回 doc。
+func runBeforeInsert(ctx context.Context, hooks SchemaHooks, doc map[string]any) (map[string]any, error) {
+	if hooks.BeforeInsert == nil {
+		return doc, nil
+	}
+	result, err := hooks.BeforeInsert(ctx, doc)
+	if err != nil {
+		return nil, fmt.Errorf("beforeInsert hook failed: %w", err)
+	}
+	if result == nil {
+		return doc, nil
+	}
+	return result, nil
+}
+
+// runBeforeUpdate 调用 BeforeUpdate 钩子，返回钩子处理后的文档并重新进行 Schema 验证。
+// 钩子未设置时直接返回 doc；钩子修改主键时返回验证错误。
+func (c *collection) runBeforeUpdate(ctx context.Context, hooks SchemaHooks, id string, oldDoc, doc map[string]any) (map[string]any, error) {
+	if hooks.BeforeUpdate == nil {
+		return doc, nil
+	}
+	old := acquireDocument(id, DeepCloneMap(oldDoc), c)
+	result, err := hooks.BeforeUpdate(ctx, old, doc)
+	if err != nil {
+		return nil, fmt.Errorf("beforeUpdate hook failed: %w", err)
+	}
+	if result == nil {
+		result = doc
+	}
+	if err := c.checkHookResult(id, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkHookResult 验证 Before* 钩子返回的文档：主键必须与 id 一致，并满足 Schema。
+func (c *collection) checkHookResult(id string, doc map[string]any) error {
+	if err := c.checkHookPrimaryKey(id, doc); err != nil {
+		return err
+	}
+	if err := c.validateDocument(doc); err != nil {
+		return NewError(ErrorTypeValidation, "schema validation failed", err)
+	}
+	return nil
+}
+
+// checkHookPrimaryKey 验证 Before* 钩子没有修改文档的主键。
+func (c *collection) checkHookPrimaryKey(id string, doc map[string]any) error {
+	newID, err := c.extractPrimaryKey(doc)
+	if err != nil || newID != id {
+		return NewError(ErrorTypeValidation, fmt.Sprintf("hook must not change the primary key of document %s", id), err).
+			WithContext("document_id", id)
+	}
+	return nil
+}
+
+// sameRevision 判断写事务中读取的文档与调用钩子时读取的文档是否为同一版本，两者都不存在也视为相同。
+func (c *collection) sameRevision(a, b map[string]any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return fmt.Sprint(a[c.schema.RevField]) == fmt.Sprint(b[c.schema.RevField])
+}
+
+// hookConflictError 在多次重试后文档仍被并发修改时返回。
+func hookConflictError() error {
+	return NewError(ErrorTypeConflict, "document was modified concurrently while running hooks", errHookStale)
+}
+
+// runAfterWrite 在写入提交后调用 AfterInsert（oldDoc 为 nil 时）或 AfterUpdate 钩子。
+func (c *collection) runAfterWrite(ctx context.Context, hooks SchemaHooks, id string, doc, oldDoc map[string]any) {
+	hook := hooks.AfterUpdate
+	if oldDoc == nil {
+		hook = hooks.AfterInsert
+	}
+	if hook != nil {
+		hook(ctx, acquireDocument(id, DeepCloneMap(doc), c))
+	}
+}
+
+// runBeforeDelete 调用 BeforeDelete 钩子。
+func runBeforeDelete(ctx context.Context, hooks SchemaHooks, id string) error {
+	if hooks.BeforeDelete == nil {
+		return nil
+	}
+	if err := hooks.BeforeDelete(ctx, id); err != nil {
+		return fmt.Errorf("beforeDelete hook failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,370 @@
+package rxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchemaHooks_BeforeInsertComputedFields(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "hook_insert", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		JSON: map[string]any{
+			"properties": map[string]any{
+				"id":        map[string]any{"type": "string"},
+				"createdAt": map[string]any{"type": "string"},
+			},
+			"required": []any{"createdAt"},
+		},
+	})
+
+	var inserted []string
+	coll.SetHooks(SchemaHooks{
+		BeforeInsert: func(ctx context.Context, data map[string]any) (map[string]any, error) {
+			data["createdAt"] = "2026-01-01T00:00:00Z"
+			return data, nil
+		},
+		AfterInsert: func(ctx context.Context, doc Document) {
+			inserted = append(inserted, doc.ID())
+		},
+	})
+
+	// 计算字段在 Schema 验证之前设置，required 约束不会失败
+	doc, err := coll.Insert(ctx, map[string]any{"id": "a"})
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if doc.GetString("createdAt") != "2026-01-01T00:00:00Z" {
+		t.Errorf("Expected createdAt to be set, got %v", doc.Data())
+	}
+	if _, err := coll.BulkInsert(ctx, []map[string]any{{"id": "b"}, {"id": "c"}}); err != nil {
+		t.Fatalf("BulkInsert failed: %v", err)
+	}
+	if _, err := coll.Upsert(ctx, map[string]any{"id": "d"}); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if _, err := coll.FindAndModify(ctx, map[string]any{"id": "e"}, map[string]any{"$set": map[string]any{"n": 1}}, FindAndModifyOptions{Upsert: true}); err != nil {
+		t.Fatalf("FindAndModify failed: %v", err)
+	}
+
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		stored, err := coll.FindByID(ctx, id)
+		if err != nil || stored == nil {
+			t.Fatalf("FindByID(%s) failed: %v", id, err)
+		}
+		if stored.GetString("createdAt") == "" {
+			t.Errorf("%s: expected createdAt to be stored, got %v", id, stored.Data())
+		}
+	}
+	if want := []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(inserted, want) {
+		t.Errorf("AfterInsert calls = %v, want %v", inserted, want)
+	}
+
+	// BeforeInsert 返回错误时中止插入
+	errRejected := errors.New("rejected")
+	coll.SetHooks(SchemaHooks{
+		BeforeInsert: func(ctx context.Context, data map[string]any) (map[string]any, error) {
+			return nil, errRejected
+		},
+	})
+	if _, err := coll.Insert(ctx, map[string]any{"id": "f", "createdAt": "x"}); !errors.Is(err, errRejected) {
+		t.Errorf("Expected hook error, got %v", err)
+	}
+	if doc, _ := coll.FindByID(ctx, "f"); doc != nil {
+		t.Error("Document should not be inserted when BeforeInsert fails")
+	}
+}
+
+func TestSchemaHooks_Update(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "hook_update", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	if _, err := coll.Insert(ctx, map[string]any{"id": "a", "n": 1}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	var oldValues []any
+	var updated int
+	coll.SetHooks(SchemaHooks{
+		BeforeUpdate: func(ctx context.Context, old Document, patch map[string]any) (map[string]any, error) {
+			oldValues = append(oldValues, old.Get("n"))
+			patch["updates"] = len(oldValues)
+			return patch, nil
+		},
+		AfterUpdate: func(ctx context.Context, doc Document) {
+			updated++
+		},
+	})
+
+	if _, err := coll.Upsert(ctx, map[string]any{"id": "a", "n": 2}); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	doc, err := coll.FindByID(ctx, "a")
+	if err != nil {
+		t.Fatalf("FindByID failed: %v", err)
+	}
+	if err := doc.Update(ctx, map[string]any{"n": 3}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, err := coll.FindAndModify(ctx, map[string]any{"id": "a"}, map[string]any{"$inc": map[string]any{"n": 1}}, FindAndModifyOptions{}); err != nil {
+		t.Fatalf("FindAndModify failed: %v", err)
+	}
+
+	stored, err := coll.FindByID(ctx, "a")
+	if err != nil {
+		t.Fatalf("FindByID failed: %v", err)
+	}
+	if stored.GetInt("n") != 4 || stored.GetInt("updates") != 3 {
+		t.Errorf("Unexpected stored document: %v", stored.Data())
+	}
+	if len(oldValues) != 3 || updated != 3 {
+		t.Errorf("Expected 3 BeforeUpdate/AfterUpdate calls, got %v / %d", oldValues, updated)
+	}
+
+	// 钩子不能修改主键
+	coll.SetHooks(SchemaHooks{
+		BeforeUpdate: func(ctx context.Context, old Document, patch map[string]any) (map[string]any, error) {
+			return map[string]any{"id": "other"}, nil
+		},
+	})
+	if _, err := coll.Upsert(ctx, map[string]any{"id": "a", "n": 5}); err == nil {
+		t.Error("Expected error when hook changes the primary key")
+	}
+}
+
+func TestSchemaHooks_BeforeDeletePreventsRemoval(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "hook_delete", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	for _, id := range []string{"keep", "a", "b"} {
+		if _, err := coll.Insert(ctx, map[string]any{"id": id}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	errProtected := errors.New("protected")
+	var deleted []string
+	coll.SetHooks(SchemaHooks{
+		BeforeDelete: func(ctx context.Context, id string) error {
+			if id == "keep" {
+				return errProtected
+			}
+			return nil
+		},
+		AfterDelete: func(ctx context.Context, id string) {
+			deleted = append(deleted, id)
+		},
+	})
+
+	if err := coll.Remove(ctx, "keep"); !errors.Is(err, errProtected) {
+		t.Errorf("Remove: expected hook error, got %v", err)
+	}
+	if err := coll.BulkRemove(ctx, []string{"a", "keep"}); !errors.Is(err, errProtected) {
+		t.Errorf("BulkRemove: expected hook error, got %v", err)
+	}
+	if _, err := coll.FindOneAndDelete(ctx, map[string]any{"id": "keep"}); !errors.Is(err, errProtected) {
+		t.Errorf("FindOneAndDelete: expected hook error, got %v", err)
+	}
+	for _, id := range []string{"keep", "a"} {
+		if doc, err := coll.FindByID(ctx, id); err != nil || doc == nil {
+			t.Errorf("Document %s should not be removed (err: %v)", id, err)
+		}
+	}
+
+	if err := coll.Remove(ctx, "a"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := coll.FindOneAndDelete(ctx, map[string]any{"id": "b"}); err != nil {
+		t.Fatalf("FindOneAndDelete failed: %v", err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("AfterDelete calls = %v, want %v", deleted, want)
+	}
+}
+
+func TestSchemaHooks_DatabaseDefault(t *testing.T) {
+	ctx := context.Background()
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name:    "testdb",
+		Path:    filepath.Join(t.TempDir(), "testdb.db"),
+		Backend: newTestBackend(t),
+		SchemaHooks: SchemaHooks{
+			BeforeInsert: func(ctx context.Context, data map[string]any) (map[string]any, error) {
+				data["source"] = "database"
+				return data, nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	coll := newTestCollection(t, db, "hook_default", Schema{PrimaryKey: "id", RevField: "_rev"})
+	doc, err := coll.Insert(ctx, map[string]any{"id": "a"})
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if doc.GetString("source") != "database" {
+		t.Errorf("Expected database default hook to run, got %v", doc.Data())
+	}
+
+	// SetHooks 替换默认钩子
+	coll.SetHooks(SchemaHooks{})
+	doc, err = coll.Insert(ctx, map[string]any{"id": "b"})
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if _, ok := doc.Data()["source"]; ok {
+		t.Errorf("Expected hooks to be replaced, got %v", doc.Data())
+	}
+}
+
+func TestSchemaHooks_Transaction(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "hook_tx", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if _, err := coll.Insert(ctx, map[string]any{"id": "keep"}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	errProtected := errors.New("protected")
+	var deleted []string
+	coll.SetHooks(SchemaHooks{
+		BeforeInsert: func(ctx context.Context, data map[string]any) (map[string]any, error) {
+			data["createdAt"] = "now"
+			return data, nil
+		},
+		BeforeUpdate: func(ctx context.Context, old Document, patch map[string]any) (map[string]any, error) {
+			patch["updatedBy"] = "hook"
+			return patch, nil
+		},
+		BeforeDelete: func(ctx context.Context, id string) error {
+			if id == "keep" {
+				return errProtected
+			}
+			return nil
+		},
+		AfterDelete: func(ctx context.Context, id string) {
+			deleted = append(deleted, id)
+		},
+	})
+
+	commit := func(fn func(tc TxCollection) error) error {
+		t.Helper()
+		tx, err := db.BeginTx(ctx)
+		if err != nil {
+			t.Fatalf("BeginTx failed: %v", err)
+		}
+		if err := fn(tx.Collection("hook_tx")); err != nil {
+			t.Fatalf("Failed to add operation: %v", err)
+		}
+		return tx.Commit(ctx)
+	}
+
+	if err := commit(func(tc TxCollection) error {
+		if err := tc.Insert(ctx, map[string]any{"id": "a"}); err != nil {
+			return err
+		}
+		return tc.Update(ctx, "keep", map[string]any{"n": 1})
+	}); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if doc, err := coll.FindByID(ctx, "a"); err != nil || doc.GetString("createdAt") != "now" {
+		t.Errorf("Expected BeforeInsert to run in transaction, got %v (err: %v)", doc, err)
+	}
+	if doc, err := coll.FindByID(ctx, "keep"); err != nil || doc.GetString("updatedBy") != "hook" {
+		t.Errorf("Expected BeforeUpdate to run in transaction, got %v (err: %v)", doc, err)
+	}
+
+	// BeforeDelete 的否决对事务同样生效，整个事务不写入
+	err := commit(func(tc TxCollection) error {
+		if err := tc.Remove(ctx, "a"); err != nil {
+			return err
+		}
+		return tc.Remove(ctx, "keep")
+	})
+	if !errors.Is(err, errProtected) {
+		t.Fatalf("Expected hook error from Commit, got %v", err)
+	}
+	for _, id := range []string{"a", "keep"} {
+		if doc, err := coll.FindByID(ctx, id); err != nil || doc == nil {
+			t.Errorf("Document %s should not be removed (err: %v)", id, err)
+		}
+	}
+
+	if err := commit(func(tc TxCollection) error { return tc.Remove(ctx, "a") }); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if want := []string{"a"}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("AfterDelete calls = %v, want %v", deleted, want)
+	}
+}
+
+func TestSchemaHooks_RunOutsideWriteLock(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "hook_reentrant", Schema{PrimaryKey: "id", RevField: "_rev"})
+	for _, doc := range []map[string]any{{"id": "counter", "n": 0}, {"id": "victim"}} {
+		if _, err := coll.Insert(ctx, doc); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	calls := 0
+	coll.SetHooks(SchemaHooks{
+		BeforeUpdate: func(ctx context.Context, old Document, patch map[string]any) (map[string]any, error) {
+			if old.ID() != "counter" {
+				return patch, nil
+			}
+			// 第一次调用时在钩子中写入同一文档，模拟钩子运行期间的并发修改
+			if calls++; calls == 1 {
+				if _, err := coll.Upsert(ctx, map[string]any{"id": "counter", "n": 10}); err != nil {
+					return nil, err
+				}
+			}
+			return patch, nil
+		},
+		BeforeDelete: func(ctx context.Context, id string) error {
+			_, err := coll.Insert(ctx, map[string]any{"id": "audit-" + id})
+			return err
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		// 钩子期间文档被修改，重新读取后基于最新文档再次调用钩子
+		doc, err := coll.FindAndModify(ctx, map[string]any{"id": "counter"}, map[string]any{"$inc": map[string]any{"n": 1}}, FindAndModifyOptions{ReturnNew: true})
+		if err != nil {
+			t.Errorf("FindAndModify failed: %v", err)
+		} else if fmt.Sprint(doc.Get("n")) != "11" {
+			t.Errorf("Expected n 11 after retry, got %v", doc.Get("n"))
+		}
+		if calls != 3 {
+			t.Errorf("Expected BeforeUpdate to be called 3 times, got %d", calls)
+		}
+
+		if _, err := coll.FindOneAndDelete(ctx, map[string]any{"id": "victim"}); err != nil {
+			t.Errorf("FindOneAndDelete failed: %v", err)
+		}
+		if doc, err := coll.FindByID(ctx, "audit-victim"); err != nil || doc == nil {
+			t.Errorf("Expected BeforeDelete to write audit document (err: %v)", err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("hooks writing to the same collection deadlocked")
+	}
+}
@@ -0,0 +1,147 @@
+package rxdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DatabaseSnapshot 数据库的内存快照，可直接 JSON 序列化后写入磁盘。
+// Collections 以集合名为键，值为该集合全部文档的 JSON。
+// （类型名不能与 Snapshot 函数同名，因此使用 DatabaseSnapshot。）
+type DatabaseSnapshot struct {
+	Name        string                       `json:"name"`
+	CreatedAt   time.Time                    `json:"created_at"`
+	Collections map[string][]json.RawMessage `json:"collections"`
+}
+
+// Snapshot 将数据库中所有已打开集合的文档序列化为内存快照。
+// 加密字段以明文保存，恢复时会按集合 schema 重新加密。
+func Snapshot(ctx context.Context, db Database) (*DatabaseSnapshot, error) {
+	d, ok := db.(*database)
+	if !ok {
+		return nil, fmt.Errorf("invalid database type")
+	}
+	if err := d.beginOp(ctx); err != nil {
+		return nil, err
+	}
+	defer d.endOp()
+
+	collections, err := d.openCollections()
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &DatabaseSnapshot{
+		Name:        d.name,
+		CreatedAt:   time.Now(),
+		Collections: make(map[string][]json.RawMessage, len(collections)),
+	}
+	for name, col := range collections {
+		docs, err := col.ExportJSON(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export collection %s: %w", name, err)
+		}
+		raw := make([]json.RawMessage, 0, len(docs))
+		for _, doc := range docs {
+			data, err := json.Marshal(doc)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal document in collection %s: %w", name, err)
+			}
+			raw = append(raw, data)
+		}
+		snap.Collections[name] = raw
+	}
+	return snap, nil
+}
+
+// RestoreSnapshot 清空数据库中所有已打开集合，然后重新导入快照中的文档。
+// 快照中存在但尚未打开的集合会使用默认 schema（主键 id）创建。
+func RestoreSnapshot(ctx context.Context, db Database, s *DatabaseSnapshot) error {
+	d, ok := db.(*database)
+	if !ok {
+		return fmt.Errorf("invalid database type")
+	}
+	if s == nil {
+		return NewError(ErrorTypeValidation, "snapshot is nil", nil)
+	}
+	if err := d.beginOp(ctx); err != nil {
+		return err
+	}
+	defer d.endOp()
+
+	collections, err := d.openCollections()
+	if err != nil {
+		return err
+	}
+
+	// 先清空所有已打开的集合，确保不在快照中的文档被移除
+	for name, col := range collections {
+		if err := col.clearDocuments(ctx); err != nil {
+			return fmt.Errorf("failed to clear collection %s: %w", name, err)
+		}
+	}
+
+	// 按集合名排序，保证恢复顺序稳定
+	names := make([]string, 0, len(s.Collections))
+	for name := range s.Collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		docs := make([]map[string]any, 0, len(s.Collections[name]))
+		for _, raw := range s.Collections[name] {
+			var doc map[string]any
+			if err := json.Unmarshal(raw, &doc); err != nil {
+				return fmt.Errorf("failed to unmarshal document in collection %s: %w", name, err)
+			}
+			docs = append(docs, doc)
+		}
+
+		col, ok := collections[name]
+		if !ok {
+			created, err := d.Collection(ctx, name, Schema{PrimaryKey: "id", RevField: "_rev"})
+			if err != nil {
+				return fmt.Errorf("failed to get collection %s: %w", name, err)
+			}
+			col = created.(*collection)
+		}
+		if err := col.ImportJSON(ctx, docs); err != nil {
+			return fmt.Errorf("failed to import collection %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// openCollections 返回当前已打开集合的副本，避免在持有锁时执行集合操作。
+func (d *database) openCollections() (map[string]*collection, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return nil, NewError(ErrorTypeClosed, "database is closed", nil)
+	}
+	collections := make(map[string]*collection, len(d.collections))
+	for name, col := range d.collections {
+		collections[name] = col
+	}
+	return collections, nil
+}
+
+// clearDocuments 删除集合中的所有文档（会触发删除事件与索引清理）。
+func (c *collection) clearDocuments(ctx context.Context) error {
+	var ids []string
+	err := c.store.Iterate(ctx, c.name, func(k, v []byte) error {
+		ids = append(ids, string(k))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return c.BulkRemove(ctx, ids)
+}
@@ -0,0 +1,452 @@
+package rxdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	bstore "github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
+)
+
+// ErrKeyNotFound 由 StorageBackend.Get 在键不存在时返回。
+var ErrKeyNotFound = errors.New("key not found")
+
+// ErrConflict 由不支持原生事务的后端在读写事务提交时返回，表示事务读取的键已被并发修改。
+var ErrConflict = errors.New("transaction conflict")
+
+// StorageBackend 可插拔的有序键值存储后端，通过 DatabaseOptions.Backend 替换默认的 BadgerDB。
+// 键按字节序排序；实现必须是并发安全的。
+type StorageBackend interface {
+	// Get 读取键对应的值，键不存在时返回 ErrKeyNotFound
+	Get(ctx context.Context, key []byte) ([]byte, error)
+	// Set 写入键值对
+	Set(ctx context.Context, key, value []byte) error
+	// Delete 删除键，键不存在时不报错
+	Delete(ctx context.Context, key []byte) error
+	// BatchWrite 原子地执行一组写入或删除
+	BatchWrite(ctx context.Context, ops []BatchOp) error
+	// Scan 按键升序遍历具有 prefix 前缀的键值对，limit <= 0 表示不限制
+	Scan(ctx context.Context, prefix []byte, limit int) (Iterator, error)
+	// Close 关闭后端并释放资源
+	Close() error
+}
+
+// BatchOp 描述 BatchWrite 中的单个操作。
+type BatchOp struct {
+	Key    []byte
+	Value  []byte
+	Delete bool // 为 true 时删除 Key，忽略 Value
+}
+
+// Iterator 遍历 Scan 的结果。首次调用 Next 前迭代器不指向任何键值对。
+type Iterator interface {
+	// Next 前进到下一个键值对，没有更多数据或出错时返回 false
+	Next() bool
+	// Key 返回当前键（完整键，包含前缀）
+	Key() []byte
+	// Value 返回当前值
+	Value() []byte
+	// Err 返回遍历过程中遇到的错误
+	Err() error
+	// Close 释放迭代器资源，可重复调用
+	Close() error
+}
+
+// kvTxn 是集合代码使用的读写事务，屏蔽不同后端的事务实现。
+type kvTxn interface {
+	Get(key []byte) ([]byte, error)
+	Set(key, value []byte) error
+	Delete(key []byte) error
+	// Scan 按键升序遍历前缀下的键值对，能看到本事务中尚未提交的写入
+	Scan(prefix []byte) Iterator
+}
+
+// txnBackend 由原生支持读写事务的后端实现（如 BadgerBackend），kvStore 优先使用原生事务。
+type txnBackend interface {
+	update(ctx context.Context, fn func(txn kvTxn) error) error
+	view(ctx context.Context, fn func(txn kvTxn) error) error
+}
+
+// rangeScanner 由能直接定位到起始键的后端实现，kvStore.ScanFrom 优先使用，避免从前缀起点逐个跳过。
+type rangeScanner interface {
+	scanFrom(ctx context.Context, prefix, start []byte) (Iterator, error)
+}
+
+// kvStore 在 StorageBackend 之上提供按 bucket 分组的读写接口与事务，是集合访问存储的唯一入口。
+// 不支持原生事务的后端通过写缓冲实现乐观事务：提交时串行校验读过的键未被修改，再以 BatchWrite 原子写入。
+type kvStore struct {
+	backend StorageBackend
+	path    string
+	writeMu sync.Mutex
+}
+
+func newKVStore(backend StorageBackend, path string) *kvStore {
+	return &kvStore{backend: backend, path: path}
+}
+
+// Path 返回数据库目录，用于附件、全文索引等旁路文件。
+func (s *kvStore) Path() string {
+	return s.path
+}
+
+// Close 关闭底层后端。
+func (s *kvStore) Close() error {
+	return s.backend.Close()
+}
+
+// badgerStore 返回 BadgerDB 存储句柄，其他后端返回 nil。
+func (s *kvStore) badgerStore() *bstore.Store {
+	if b, ok := s.backend.(*BadgerBackend); ok {
+		return b.store
+	}
+	return nil
+}
+
+// WithUpdate 在读写事务中执行 fn，fn 返回错误时丢弃全部写入。
+func (s *kvStore) WithUpdate(ctx context.Context, fn func(txn kvTxn) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if tb, ok := s.backend.(txnBackend); ok {
+		return tb.update(ctx, fn)
+	}
+
+	txn := newBufferedTxn(ctx, s.backend)
+	if err := fn(txn); err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return txn.commit()
+}
+
+// WithView 在只读事务中执行 fn。
+func (s *kvStore) WithView(ctx context.Context, fn func(txn kvTxn) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if tb, ok := s.backend.(txnBackend); ok {
+		return tb.view(ctx, fn)
+	}
+	return fn(newBufferedTxn(ctx, s.backend))
+}
+
+// Scan 返回前缀下键值对的迭代器，调用方负责 Close。
+func (s *kvStore) Scan(ctx context.Context, prefix []byte) (Iterator, error) {
+	return s.backend.Scan(ctx, prefix, 0)
+}
+
+// ScanFrom 返回前缀下键不小于 start 的键值对迭代器，调用方负责 Close。
+func (s *kvStore) ScanFrom(ctx context.Context, prefix, start []byte) (Iterator, error) {
+	if rs, ok := s.backend.(rangeScanner); ok {
+		return rs.scanFrom(ctx, prefix, start)
+	}
+	it, err := s.backend.Scan(ctx, prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &seekIterator{Iterator: it, start: start}, nil
+}
+
+// Get 从指定 bucket 获取值，键不存在时返回 nil。
+func (s *kvStore) Get(ctx context.Context, bucket, key string) ([]byte, error) {
+	value, err := s.backend.Get(ctx, bstore.BucketKey(bucket, key))
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, nil
+	}
+	return value, err
+}
+
+// GetValue 从指定 bucket 获取值并交给 fn 处理，键不存在时以 nil 调用 fn。
+func (s *kvStore) GetValue(ctx context.Context, bucket, key string, fn func(val []byte) error) error {
+	value, err := s.Get(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	return fn(value)
+}
+
+// Set 在指定 bucket 设置值。
+func (s *kvStore) Set(ctx context.Context, bucket, key string, value []byte) error {
+	return s.WithUpdate(ctx, func(txn kvTxn) error {
+		return txn.Set(bstore.BucketKey(bucket, key), value)
+	})
+}
+
+// Delete 从指定 bucket 删除值。
+func (s *kvStore) Delete(ctx context.Context, bucket, key string) error {
+	return s.WithUpdate(ctx, func(txn kvTxn) error {
+		return txn.Delete(bstore.BucketKey(bucket, key))
+	})
+}
+
+// Iterate 遍历指定 bucket 中的所有键值对，回调中的 key 不含 bucket 前缀。
+func (s *kvStore) Iterate(ctx context.Context, bucket string, fn func(key, value []byte) error) error {
+	return s.IterateRawPrefix(ctx, bstore.BucketPrefix(bucket), fn)
+}
+
+// IterateRawPrefix 遍历具有原始前缀的所有键值对，回调中的 key 已去掉该前缀。
+func (s *kvStore) IterateRawPrefix(ctx context.Context, rawPrefix []byte, fn func(key, value []byte) error) error {
+	it, err := s.backend.Scan(ctx, rawPrefix, 0)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(it.Key()[len(rawPrefix):], it.Value()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// DropBuckets 删除指定 bucket 中的所有键。
+func (s *kvStore) DropBuckets(ctx context.Context, buckets ...string) error {
+	if store := s.badgerStore(); store != nil {
+		return store.DropBuckets(ctx, buckets...)
+	}
+	prefixes := make([][]byte, len(buckets))
+	for i, bucket := range buckets {
+		prefixes[i] = bstore.BucketPrefix(bucket)
+	}
+	return s.dropPrefixes(ctx, prefixes...)
+}
+
+// dropPrefixes 在一次 BatchWrite 中删除具有任一前缀的键，空前缀表示全部键。
+func (s *kvStore) dropPrefixes(ctx context.Context, prefixes ...[]byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var ops []BatchOp
+	for _, prefix := range prefixes {
+		it, err := s.backend.Scan(ctx, prefix, 0)
+		if err != nil {
+			return err
+		}
+		for it.Next() {
+			ops = append(ops, BatchOp{Key: append([]byte(nil), it.Key()...), Delete: true})
+		}
+		err = it.Err()
+		it.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	return s.backend.BatchWrite(ctx, ops)
+}
+
+// bufferedTxn 基于写缓冲的通用事务：读取优先命中缓冲，提交时一次性 BatchWrite。
+type bufferedTxn struct {
+	ctx     context.Context
+	backend StorageBackend
+	writes  map[string]BatchOp
+	reads   map[string][]byte // 从后端读取过的键及其值，nil 表示读取时不存在
+}
+
+func newBufferedTxn(ctx context.Context, backend StorageBackend) *bufferedTxn {
+	return &bufferedTxn{
+		ctx:     ctx,
+		backend: backend,
+		writes:  make(map[string]BatchOp),
+		reads:   make(map[string][]byte),
+	}
+}
+
+func (t *bufferedTxn) Get(key []byte) ([]byte, error) {
+	if op, ok := t.writes[string(key)]; ok {
+		if op.Delete {
+			return nil, ErrKeyNotFound
+		}
+		return op.Value, nil
+	}
+	value, err := t.backend.Get(t.ctx, key)
+	if err == nil || errors.Is(err, ErrKeyNotFound) {
+		if _, ok := t.reads[string(key)]; !ok {
+			t.reads[string(key)] = value
+		}
+	}
+	return value, err
+}
+
+func (t *bufferedTxn) Set(key, value []byte) error {
+	k := string(key)
+	t.writes[k] = BatchOp{Key: []byte(k), Value: append([]byte{}, value...)}
+	return nil
+}
+
+func (t *bufferedTxn) Delete(key []byte) error {
+	k := string(key)
+	t.writes[k] = BatchOp{Key: []byte(k), Delete: true}
+	return nil
+}
+
+func (t *bufferedTxn) Scan(prefix []byte) Iterator {
+	base, err := t.backend.Scan(t.ctx, prefix, 0)
+	if err != nil {
+		return &sliceIterator{err: err}
+	}
+	var pending []BatchOp
+	for k, op := range t.writes {
+		if bytes.HasPrefix([]byte(k), prefix) {
+			pending = append(pending, op)
+		}
+	}
+	if len(pending) == 0 {
+		return base
+	}
+	sort.Slice(pending, func(i, j int) bool { return bytes.Compare(pending[i].Key, pending[j].Key) < 0 })
+	return &mergedIterator{base: base, pending: pending, baseOK: base.Next()}
+}
+
+// commit 按键顺序提交缓冲的写入。调用方需保证提交串行执行；
+// 事务内 Get 过的键若已被其他事务修改，返回 ErrConflict 且不写入。
+func (t *bufferedTxn) commit() error {
+	if len(t.writes) == 0 {
+		return nil
+	}
+	for k, read := range t.reads {
+		current, err := t.backend.Get(t.ctx, []byte(k))
+		if err != nil && !errors.Is(err, ErrKeyNotFound) {
+			return err
+		}
+		if (read == nil) != (current == nil) || !bytes.Equal(read, current) {
+			return ErrConflict
+		}
+	}
+	ops := make([]BatchOp, 0, len(t.writes))
+	for _, op := range t.writes {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return bytes.Compare(ops[i].Key, ops[j].Key) < 0 })
+	return t.backend.BatchWrite(t.ctx, ops)
+}
+
+// mergedIterator 将后端迭代结果与事务内未提交的写入按键序合并。
+type mergedIterator struct {
+	base    Iterator
+	baseOK  bool
+	pending []BatchOp
+	key     []byte
+	value   []byte
+}
+
+func (m *mergedIterator) Next() bool {
+	for {
+		hasPending := len(m.pending) > 0
+		if !m.baseOK && !hasPending {
+			m.key, m.value = nil, nil
+			return false
+		}
+
+		var op *BatchOp
+		switch {
+		case !hasPending:
+		case !m.baseOK:
+			op = &m.pending[0]
+		default:
+			cmp := bytes.Compare(m.pending[0].Key, m.base.Key())
+			if cmp == 0 {
+				// 缓冲写入覆盖后端中的同名键
+				m.baseOK = m.base.Next()
+			}
+			if cmp <= 0 {
+				op = &m.pending[0]
+			}
+		}
+
+		if op == nil {
+			// 后端迭代器在 Next 之后可能复用缓冲区，先复制
+			m.key = append([]byte(nil), m.base.Key()...)
+			m.value = append([]byte(nil), m.base.Value()...)
+			m.baseOK = m.base.Next()
+			return true
+		}
+		m.pending = m.pending[1:]
+		if op.Delete {
+			continue
+		}
+		m.key, m.value = op.Key, op.Value
+		return true
+	}
+}
+
+func (m *mergedIterator) Key() []byte   { return m.key }
+func (m *mergedIterator) Value() []byte { return m.value }
+func (m *mergedIterator) Err() error    { return m.base.Err() }
+func (m *mergedIterator) Close() error  { return m.base.Close() }
+
+// seekIterator 跳过小于 start 的键，用于不支持直接定位的后端。
+type seekIterator struct {
+	Iterator
+	start   []byte
+	started bool
+}
+
+func (s *seekIterator) Next() bool {
+	if s.started {
+		return s.Iterator.Next()
+	}
+	s.started = true
+	for s.Iterator.Next() {
+		if bytes.Compare(s.Iterator.Key(), s.start) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sliceIterator 遍历预先收集好的键值对，用作快照迭代器或携带错误的空迭代器。
+type sliceIterator struct {
+	keys   [][]byte
+	values [][]byte
+	pos    int
+	err    error
+}
+
+func (s *sliceIterator) Next() bool {
+	if s.err != nil || s.pos >= len(s.keys) {
+		s.pos = len(s.keys) + 1
+		return false
+	}
+	s.pos++
+	return true
+}
+
+func (s *sliceIterator) Key() []byte {
+	if s.pos < 1 || s.pos > len(s.keys) {
+		return nil
+	}
+	return s.keys[s.pos-1]
+}
+
+func (s *sliceIterator) Value() []byte {
+	if s.pos < 1 || s.pos > len(s.values) {
+		return nil
+	}
+	return s.values[s.pos-1]
+}
+
+func (s *sliceIterator) Err() error   { return s.err }
+func (s *sliceIterator) Close() error { return nil }
+
+// prefixUpperBound 返回大于所有以 prefix 开头的键的最小键，prefix 全为 0xFF 时返回 nil。
+func prefixUpperBound(prefix []byte) []byte {
+	upper := append([]byte(nil), prefix...)
+	for i := len(upper) - 1; i >= 0; i-- {
+		if upper[i] < 0xFF {
+			upper[i]++
+			return upper[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package rxdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+	bstore "github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
+)
+
+// BadgerBackend 基于 BadgerDB 的 StorageBackend，是未设置 DatabaseOptions.Backend 时的默认后端。
+// 相同路径的多个 BadgerBackend 共享同一个 BadgerDB 实例。
+type BadgerBackend struct {
+	store *bstore.Store
+}
+
+// NewBadgerBackend 打开 path 处的 BadgerDB。
+func NewBadgerBackend(path string, opts bstore.Options) (*BadgerBackend, error) {
+	store, err := bstore.Open(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &BadgerBackend{store: store}, nil
+}
+
+// Store 返回底层 Badger 存储句柄（供备份、压缩等高级用法）。
+func (b *BadgerBackend) Store() *bstore.Store {
+	return b.store
+}
+
+// Get 实现 StorageBackend。
+func (b *BadgerBackend) Get(ctx context.Context, key []byte) ([]byte, error) {
+	var value []byte
+	err := b.store.WithView(ctx, func(txn *badger.Txn) error {
+		var err error
+		value, err = (&badgerTxn{txn: txn}).Get(key)
+		return err
+	})
+	return value, err
+}
+
+// Set 实现 StorageBackend。
+func (b *BadgerBackend) Set(ctx context.Context, key, value []byte) error {
+	return b.store.WithUpdate(ctx, func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	})
+}
+
+// Delete 实现 StorageBackend。
+func (b *BadgerBackend) Delete(ctx context.Context, key []byte) error {
+	return b.store.WithUpdate(ctx, func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+}
+
+// BatchWrite 实现 StorageBackend，整批在同一个 Badger 事务中提交。
+func (b *BadgerBackend) BatchWrite(ctx context.Context, ops []BatchOp) error {
+	return b.store.WithUpdate(ctx, func(txn *badger.Txn) error {
+		for _, op := range ops {
+			var err error
+			if op.Delete {
+				err = txn.Delete(op.Key)
+			} else {
+				err = txn.Set(op.Key, op.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Scan 实现 StorageBackend。迭代器持有只读事务，看到的是创建时刻的一致性快照。
+func (b *BadgerBackend) Scan(ctx context.Context, prefix []byte, limit int) (Iterator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	txn, err := b.store.NewReadTxn()
+	if err != nil {
+		return nil, err
+	}
+	it := newBadgerIterator(txn, prefix, limit)
+	it.ownTxn = true
+	return it, nil
+}
+
+// scanFrom 实现 rangeScanner，迭代器直接 Seek 到 start。
+func (b *BadgerBackend) scanFrom(ctx context.Context, prefix, start []byte) (Iterator, error) {
+	it, err := b.Scan(ctx, prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	it.(*badgerIterator).start = append([]byte(nil), start...)
+	return it, nil
+}
+
+// Close 实现 StorageBackend，释放对共享 BadgerDB 实例的引用。
+func (b *BadgerBackend) Close() error {
+	return b.store.Close()
+}
+
+// update 使用 Badger 原生读写事务，冲突时返回 badger.ErrConflict。
+func (b *BadgerBackend) update(ctx context.Context, fn func(txn kvTxn) error) error {
+	return b.store.WithUpdate(ctx, func(txn *badger.Txn) error {
+		return fn(&badgerTxn{txn: txn})
+	})
+}
+
+func (b *BadgerBackend) view(ctx context.Context, fn func(txn kvTxn) error) error {
+	return b.store.WithView(ctx, func(txn *badger.Txn) error {
+		return fn(&badgerTxn{txn: txn})
+	})
+}
+
+// badgerTxn 将 *badger.Txn 适配为 kvTxn。
+type badgerTxn struct {
+	txn *badger.Txn
+}
+
+func (t *badgerTxn) Get(key []byte) ([]byte, error) {
+	item, err := t.txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (t *badgerTxn) Set(key, value []byte) error {
+	return t.txn.Set(key, value)
+}
+
+func (t *badgerTxn) Delete(key []byte) error {
+	return t.txn.Delete(key)
+}
+
+func (t *badgerTxn) Scan(prefix []byte) Iterator {
+	return newBadgerIterator(t.txn, prefix, 0)
+}
+
+// badgerIterator 将 *badger.Iterator 适配为 Iterator。
+type badgerIterator struct {
+	txn     *badger.Txn
+	it      *badger.Iterator
+	prefix  []byte
+	start   []byte // 首次 Next 时 Seek 的位置，为空时从 prefix 开始
+	limit   int
+	count   int
+	started bool
+	ownTxn  bool // Close 时是否丢弃事务
+	closed  bool
+	value   []byte
+	err     error
+}
+
+func newBadgerIterator(txn *badger.Txn, prefix []byte, limit int) *badgerIterator {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	// Next 会复制每个值，预取只会额外持有最多 PrefetchSize 个值，分批扫描大文档时显著抬高内存峰值
+	opts.PrefetchValues = false
+	return &badgerIterator{txn: txn, it: txn.NewIterator(opts), prefix: prefix, limit: limit}
+}
+
+func (bi *badgerIterator) Next() bool {
+	if bi.closed || bi.err != nil {
+		return false
+	}
+	if !bi.started {
+		if bytes.Compare(bi.start, bi.prefix) > 0 {
+			bi.it.Seek(bi.start)
+		} else {
+			bi.it.Seek(bi.prefix)
+		}
+		bi.started = true
+	} else {
+		bi.it.Next()
+	}
+	if !bi.it.ValidForPrefix(bi.prefix) || (bi.limit > 0 && bi.count >= bi.limit) {
+		bi.value = nil
+		return false
+	}
+	bi.value, bi.err = bi.it.Item().ValueCopy(bi.value[:0])
+	if bi.err != nil {
+		return false
+	}
+	bi.count++
+	return true
+}
+
+func (bi *badgerIterator) Key() []byte {
+	if bi.closed || !bi.it.Valid() {
+		return nil
+	}
+	return bi.it.Item().Key()
+}
+
+func (bi *badgerIterator) Value() []byte {
+	return bi.value
+}
+
+func (bi *badgerIterator) Err() error {
+	return bi.err
+}
+
+func (bi *badgerIterator) Close() error {
+	if bi.closed {
+		return nil
+	}
+	bi.closed = true
+	bi.it.Close()
+	if bi.ownTxn {
+		bi.txn.Discard()
+	}
+	return nil
+}
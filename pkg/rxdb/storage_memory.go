@@ -0,0 +1,141 @@
+package rxdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// MemoryBackend 纯内存的 StorageBackend，不产生磁盘 I/O，适合单元测试。
+// 数据在 Close 之后仍保留，同一实例可被重新打开的数据库继续使用。
+type MemoryBackend struct {
+	mu     sync.RWMutex
+	keys   []string // 有序键列表
+	values map[string][]byte
+}
+
+// NewMemoryBackend 创建空的内存存储后端。
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{values: make(map[string][]byte)}
+}
+
+// Get 实现 StorageBackend。
+func (m *MemoryBackend) Get(ctx context.Context, key []byte) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	value, ok := m.values[string(key)]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return append([]byte{}, value...), nil
+}
+
+// Set 实现 StorageBackend。
+func (m *MemoryBackend) Set(ctx context.Context, key, value []byte) error {
+	if len(key) == 0 {
+		return errors.New("key cannot be empty")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(string(key), value)
+	return nil
+}
+
+// Delete 实现 StorageBackend。
+func (m *MemoryBackend) Delete(ctx context.Context, key []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.delete(string(key))
+	return nil
+}
+
+// BatchWrite 实现 StorageBackend，整批在一次加锁内完成。
+func (m *MemoryBackend) BatchWrite(ctx context.Context, ops []BatchOp) error {
+	for _, op := range ops {
+		if len(op.Key) == 0 {
+			return errors.New("key cannot be empty")
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, op := range ops {
+		if op.Delete {
+			m.delete(string(op.Key))
+		} else {
+			m.set(string(op.Key), op.Value)
+		}
+	}
+	return nil
+}
+
+// Scan 实现 StorageBackend，返回调用时刻的快照。
+func (m *MemoryBackend) Scan(ctx context.Context, prefix []byte, limit int) (Iterator, error) {
+	return m.scan(ctx, prefix, prefix, limit)
+}
+
+// scanFrom 实现 rangeScanner。
+func (m *MemoryBackend) scanFrom(ctx context.Context, prefix, start []byte) (Iterator, error) {
+	if bytes.Compare(start, prefix) < 0 {
+		start = prefix
+	}
+	return m.scan(ctx, prefix, start, 0)
+}
+
+// scan 返回前缀下从 start 开始的键值对快照。
+func (m *MemoryBackend) scan(ctx context.Context, prefix, start []byte, limit int) (Iterator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	it := &sliceIterator{}
+	for i := sort.SearchStrings(m.keys, string(start)); i < len(m.keys); i++ {
+		key := m.keys[i]
+		if !bytes.HasPrefix([]byte(key), prefix) {
+			break
+		}
+		if limit > 0 && len(it.keys) >= limit {
+			break
+		}
+		// 大前缀的快照复制耗时较长，定期检查 ctx 以便及时响应取消
+		if len(it.keys)%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		it.keys = append(it.keys, []byte(key))
+		it.values = append(it.values, append([]byte{}, m.values[key]...))
+	}
+	return it, nil
+}
+
+// Close 实现 StorageBackend，内存数据不会被清空。
+func (m *MemoryBackend) Close() error {
+	return nil
+}
+
+func (m *MemoryBackend) set(key string, value []byte) {
+	if _, ok := m.values[key]; !ok {
+		i := sort.SearchStrings(m.keys, key)
+		m.keys = append(m.keys, "")
+		copy(m.keys[i+1:], m.keys[i:])
+		m.keys[i] = key
+	}
+	m.values[key] = append([]byte{}, value...)
+}
+
+func (m *MemoryBackend) delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	i := sort.SearchStrings(m.keys, key)
+	m.keys = append(m.keys[:i], m.keys[i+1:]...)
+}
@@ -0,0 +1,210 @@
+package rxdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SQLiteDriverName 是 OpenSQLiteBackend 使用的 database/sql 驱动名，对应 modernc.org/sqlite。
+// 使用前需在程序中匿名导入驱动：import _ "modernc.org/sqlite"。
+const SQLiteDriverName = "sqlite"
+
+// sqliteScanPageSize Scan 每次从 SQLite 读取的行数，避免一次性加载整个前缀范围。
+const sqliteScanPageSize = 256
+
+// SQLiteBackend 基于 SQLite 的 StorageBackend，所有键值对保存在单表 rxdb_kv 中。
+type SQLiteBackend struct {
+	db *sql.DB
+}
+
+// OpenSQLiteBackend 通过 SQLiteDriverName 驱动打开 path 处的 SQLite 数据库。
+func OpenSQLiteBackend(path string) (*SQLiteBackend, error) {
+	db, err := sql.Open(SQLiteDriverName, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	backend, err := NewSQLiteBackend(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return backend, nil
+}
+
+// NewSQLiteBackend 使用已打开的 SQLite 连接创建后端，必要时创建数据表。
+// Close 会关闭 db。
+func NewSQLiteBackend(db *sql.DB) (*SQLiteBackend, error) {
+	// SQLite 同一时刻只允许一个写者，单连接可避免 "database is locked"
+	db.SetMaxOpenConns(1)
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS rxdb_kv (k BLOB PRIMARY KEY, v BLOB NOT NULL) WITHOUT ROWID`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sqlite table: %w", err)
+	}
+	return &SQLiteBackend{db: db}, nil
+}
+
+// Get 实现 StorageBackend。
+func (s *SQLiteBackend) Get(ctx context.Context, key []byte) ([]byte, error) {
+	var value []byte
+	err := s.db.QueryRowContext(ctx, `SELECT v FROM rxdb_kv WHERE k = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return value, nil
+}
+
+// Set 实现 StorageBackend。
+func (s *SQLiteBackend) Set(ctx context.Context, key, value []byte) error {
+	return s.BatchWrite(ctx, []BatchOp{{Key: key, Value: value}})
+}
+
+// Delete 实现 StorageBackend。
+func (s *SQLiteBackend) Delete(ctx context.Context, key []byte) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM rxdb_kv WHERE k = ?`, key)
+	return err
+}
+
+// BatchWrite 实现 StorageBackend，整批在一个 SQLite 事务中提交。
+func (s *SQLiteBackend) BatchWrite(ctx context.Context, ops []BatchOp) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, op := range ops {
+		if op.Delete {
+			_, err = tx.ExecContext(ctx, `DELETE FROM rxdb_kv WHERE k = ?`, op.Key)
+		} else {
+			value := op.Value
+			if value == nil {
+				value = []byte{}
+			}
+			_, err = tx.ExecContext(ctx, `INSERT INTO rxdb_kv (k, v) VALUES (?, ?) ON CONFLICT(k) DO UPDATE SET v = excluded.v`, op.Key, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Scan 实现 StorageBackend。结果按页读取，不持有事务，遍历期间可以看到其他写入。
+func (s *SQLiteBackend) Scan(ctx context.Context, prefix []byte, limit int) (Iterator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &sqliteIterator{
+		ctx:       ctx,
+		db:        s.db,
+		lower:     append([]byte{}, prefix...), // 非 nil，空前缀不能绑定为 NULL
+		upper:     prefixUpperBound(prefix),
+		limit:     limit,
+		inclusive: true,
+	}, nil
+}
+
+// scanFrom 实现 rangeScanner，第一页直接从 start 开始读取。
+func (s *SQLiteBackend) scanFrom(ctx context.Context, prefix, start []byte) (Iterator, error) {
+	it, err := s.Scan(ctx, prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	if si := it.(*sqliteIterator); bytes.Compare(start, si.lower) > 0 {
+		si.lower = append([]byte(nil), start...)
+	}
+	return it, nil
+}
+
+// Close 实现 StorageBackend。
+func (s *SQLiteBackend) Close() error {
+	return s.db.Close()
+}
+
+// sqliteIterator 以键为游标分页读取前缀范围。
+type sqliteIterator struct {
+	ctx       context.Context
+	db        *sql.DB
+	lower     []byte // 下一页的起始键
+	upper     []byte // 前缀上界（不含），nil 表示无上界
+	inclusive bool   // lower 是否包含在下一页中
+	limit     int
+	count     int
+	page      sliceIterator
+	done      bool
+	err       error
+}
+
+func (si *sqliteIterator) Next() bool {
+	if si.err != nil || (si.limit > 0 && si.count >= si.limit) {
+		return false
+	}
+	if !si.page.Next() {
+		if si.done || !si.fetch() || !si.page.Next() {
+			return false
+		}
+	}
+	si.count++
+	return true
+}
+
+// fetch 读取下一页，没有更多数据时返回 false。
+func (si *sqliteIterator) fetch() bool {
+	op := ">"
+	if si.inclusive {
+		op = ">="
+	}
+	query := `SELECT k, v FROM rxdb_kv WHERE k ` + op + ` ?`
+	args := []any{si.lower}
+	if si.upper != nil {
+		query += ` AND k < ?`
+		args = append(args, si.upper)
+	}
+	query += ` ORDER BY k LIMIT ?`
+	args = append(args, sqliteScanPageSize)
+
+	rows, err := si.db.QueryContext(si.ctx, query, args...)
+	if err != nil {
+		si.err = err
+		return false
+	}
+	defer rows.Close()
+
+	si.page = sliceIterator{}
+	for rows.Next() {
+		var k, v []byte
+		if err := rows.Scan(&k, &v); err != nil {
+			si.err = err
+			return false
+		}
+		si.page.keys = append(si.page.keys, k)
+		si.page.values = append(si.page.values, v)
+	}
+	if err := rows.Err(); err != nil {
+		si.err = err
+		return false
+	}
+	if len(si.page.keys) < sqliteScanPageSize {
+		si.done = true
+	}
+	if len(si.page.keys) == 0 {
+		return false
+	}
+	si.lower = si.page.keys[len(si.page.keys)-1]
+	si.inclusive = false
+	return true
+}
+
+func (si *sqliteIterator) Key() []byte   { return si.page.Key() }
+func (si *sqliteIterator) Value() []byte { return si.page.Value() }
+func (si *sqliteIterator) Err() error    { return si.err }
+func (si *sqliteIterator) Close() error  { return nil }
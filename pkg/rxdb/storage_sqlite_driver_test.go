@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package rxdb
+
+// 以 -tags sqlite 运行测试时注册 SQLite 驱动，供 RXDB_TEST_BACKEND=sqlite 与后端一致性测试使用。
+import _ "modernc.org/sqlite"
@@ -0,0 +1,311 @@
+package rxdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	bstore "github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
+)
+
+// testBackendEnv 选择包内测试使用的存储后端：badger（默认）、memory 或 sqlite。
+// sqlite 需要以 -tags sqlite 构建测试以注册 modernc.org/sqlite 驱动。
+const testBackendEnv = "RXDB_TEST_BACKEND"
+
+// newTestBackend 按 RXDB_TEST_BACKEND 创建存储后端，badger 返回 nil 以使用默认后端。
+func newTestBackend(t testing.TB) StorageBackend {
+	t.Helper()
+
+	switch name := os.Getenv(testBackendEnv); name {
+	case "", "badger":
+		return nil
+	default:
+		return openTestBackend(t, name)
+	}
+}
+
+// openTestBackend 创建指定类型的存储后端，badger 后端在测试结束时关闭。
+func openTestBackend(t testing.TB, name string) StorageBackend {
+	t.Helper()
+
+	dir := t.TempDir()
+	var backend StorageBackend
+	var err error
+	switch name {
+	case "badger":
+		backend, err = NewBadgerBackend(filepath.Join(dir, "badger"), bstore.Options{})
+	case "memory":
+		backend = NewMemoryBackend()
+	case "sqlite":
+		if !slices.Contains(sql.Drivers(), SQLiteDriverName) {
+			t.Skipf("sqlite driver %q not registered, run tests with -tags sqlite", SQLiteDriverName)
+		}
+		backend, err = OpenSQLiteBackend(filepath.Join(dir, "rxdb.sqlite"))
+	default:
+		t.Fatalf("unknown %s %q", testBackendEnv, name)
+	}
+	if err != nil {
+		t.Fatalf("Failed to open %s backend: %v", name, err)
+	}
+	return backend
+}
+
+func TestStorageBackend_Conformance(t *testing.T) {
+	for _, name := range []string{"badger", "memory", "sqlite"} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := openTestBackend(t, name)
+			defer backend.Close()
+
+			if _, err := backend.Get(ctx, []byte("missing")); !errors.Is(err, ErrKeyNotFound) {
+				t.Fatalf("Expected ErrKeyNotFound, got %v", err)
+			}
+			if err := backend.Set(ctx, []byte("a:1"), []byte("one")); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			if v, err := backend.Get(ctx, []byte("a:1")); err != nil || string(v) != "one" {
+				t.Fatalf("Get = %q, %v", v, err)
+			}
+
+			ops := []BatchOp{
+				{Key: []byte("a:3"), Value: []byte("three")},
+				{Key: []byte("a:2"), Value: []byte("two")},
+				{Key: []byte("b:1"), Value: nil},
+				{Key: []byte("a:1"), Delete: true},
+			}
+			if err := backend.BatchWrite(ctx, ops); err != nil {
+				t.Fatalf("BatchWrite failed: %v", err)
+			}
+			if v, err := backend.Get(ctx, []byte("b:1")); err != nil || len(v) != 0 {
+				t.Errorf("Expected empty value for b:1, got %q, %v", v, err)
+			}
+
+			keys := scanKeys(t, backend, []byte("a:"), 0)
+			if fmt.Sprint(keys) != "[a:2 a:3]" {
+				t.Errorf("Scan(a:) = %v", keys)
+			}
+			if keys := scanKeys(t, backend, []byte("a:"), 1); fmt.Sprint(keys) != "[a:2]" {
+				t.Errorf("Scan(a:, 1) = %v", keys)
+			}
+
+			if err := backend.Delete(ctx, []byte("a:2")); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if err := backend.Delete(ctx, []byte("a:2")); err != nil {
+				t.Fatalf("Delete of missing key failed: %v", err)
+			}
+			if keys := scanKeys(t, backend, nil, 0); fmt.Sprint(keys) != "[a:3 b:1]" {
+				t.Errorf("Scan(all) = %v", keys)
+			}
+
+			// 超过一页的扫描（SQLite 分页读取）
+			many := make([]BatchOp, 0, 600)
+			for i := 0; i < 600; i++ {
+				many = append(many, BatchOp{Key: []byte(fmt.Sprintf("m:%04d", i)), Value: []byte{byte(i)}})
+			}
+			if err := backend.BatchWrite(ctx, many); err != nil {
+				t.Fatalf("BatchWrite failed: %v", err)
+			}
+			keys = scanKeys(t, backend, []byte("m:"), 0)
+			if len(keys) != 600 || keys[0] != "m:0000" || keys[599] != "m:0599" {
+				t.Errorf("Unexpected paged scan: %d keys", len(keys))
+			}
+
+			// ScanFrom 直接定位到起始键；包装后的后端隐藏了 rangeScanner，走逐个跳过的通用路径
+			for _, store := range []*kvStore{newKVStore(backend, ""), newKVStore(struct{ StorageBackend }{backend}, "")} {
+				keys = scanFromKeys(t, store, []byte("m:"), []byte("m:0300"))
+				if len(keys) != 300 || keys[0] != "m:0300" || keys[299] != "m:0599" {
+					t.Errorf("Unexpected ScanFrom(m:0300): %d keys", len(keys))
+				}
+				if keys := scanFromKeys(t, store, []byte("m:"), []byte("a:")); len(keys) != 600 {
+					t.Errorf("Expected ScanFrom before prefix to return all 600 keys, got %d", len(keys))
+				}
+				if keys := scanFromKeys(t, store, []byte("a:"), []byte("a:4")); len(keys) != 0 {
+					t.Errorf("Expected ScanFrom past prefix to be empty, got %v", keys)
+				}
+			}
+		})
+	}
+}
+
+func TestStorageBackend_Txn(t *testing.T) {
+	ctx := context.Background()
+	store := newKVStore(NewMemoryBackend(), "")
+
+	if err := store.Set(ctx, "docs", "1", []byte("a")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := store.Set(ctx, "docs", "3", []byte("c")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	// 事务内的 Get 与 Scan 能看到未提交的写入
+	err := store.WithUpdate(ctx, func(txn kvTxn) error {
+		_ = txn.Set([]byte("docs:2"), []byte("b"))
+		_ = txn.Delete([]byte("docs:3"))
+		if v, err := txn.Get([]byte("docs:2")); err != nil || string(v) != "b" {
+			t.Errorf("txn.Get = %q, %v", v, err)
+		}
+		if _, err := txn.Get([]byte("docs:3")); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("Expected deleted key to be missing, got %v", err)
+		}
+		it := txn.Scan([]byte("docs:"))
+		defer it.Close()
+		var got [][]byte
+		for it.Next() {
+			got = append(got, it.Key())
+		}
+		if !slices.EqualFunc(got, [][]byte{[]byte("docs:1"), []byte("docs:2")}, bytes.Equal) {
+			t.Errorf("txn.Scan = %q", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithUpdate failed: %v", err)
+	}
+
+	// 返回错误时写入被丢弃
+	rollback := errors.New("rollback")
+	err = store.WithUpdate(ctx, func(txn kvTxn) error {
+		_ = txn.Set([]byte("docs:9"), []byte("z"))
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("Expected rollback error, got %v", err)
+	}
+	if v, _ := store.Get(ctx, "docs", "9"); v != nil {
+		t.Errorf("Expected rolled back write to be discarded, got %q", v)
+	}
+
+	var keys []string
+	_ = store.Iterate(ctx, "docs", func(key, value []byte) error {
+		keys = append(keys, string(key)+"="+string(value))
+		return nil
+	})
+	if fmt.Sprint(keys) != "[1=a 2=b]" {
+		t.Errorf("Iterate = %v", keys)
+	}
+}
+
+func TestDatabase_MemoryBackend(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryBackend()
+	path := filepath.Join(t.TempDir(), "memdb")
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{Name: "memdb", Path: path, Backend: backend})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	col, err := db.Collection(ctx, "users", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		Indexes:    []Index{{Fields: []string{"age"}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := col.Insert(ctx, map[string]any{"id": fmt.Sprintf("u%d", i), "age": 20 + i}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+	docs, err := col.Find(map[string]any{"age": map[string]any{"$gte": 22}}).Exec(ctx)
+	if err != nil || len(docs) != 3 {
+		t.Fatalf("Expected 3 docs, got %d (%v)", len(docs), err)
+	}
+	if err := db.Backup(ctx, filepath.Join(t.TempDir(), "backup")); err == nil {
+		t.Error("Expected Backup to fail for memory backend")
+	}
+	if err := db.Close(ctx); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected memory backend to leave no data directory, stat err = %v", err)
+	}
+
+	// 数据保留在后端中，重新打开后可读
+	db, err = CreateDatabase(ctx, DatabaseOptions{Name: "memdb", Path: path, Backend: backend})
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	col, err = db.Collection(ctx, "users", Schema{PrimaryKey: "id", RevField: "_rev", Indexes: []Index{{Fields: []string{"age"}}}})
+	if err != nil {
+		t.Fatalf("Failed to reopen collection: %v", err)
+	}
+	if count, _ := col.Count(ctx); count != 5 {
+		t.Errorf("Expected 5 docs after reopen, got %d", count)
+	}
+	if err := db.Destroy(ctx); err != nil {
+		t.Fatalf("Destroy failed: %v", err)
+	}
+	if keys := scanKeys(t, backend, nil, 0); len(keys) != 0 {
+		t.Errorf("Expected Destroy to clear backend, %d keys left", len(keys))
+	}
+}
+
+func scanKeys(t *testing.T, backend StorageBackend, prefix []byte, limit int) []string {
+	t.Helper()
+
+	it, err := backend.Scan(context.Background(), prefix, limit)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	defer it.Close()
+	var keys []string
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Scan iteration failed: %v", err)
+	}
+	return keys
+}
+
+func scanFromKeys(t *testing.T, store *kvStore, prefix, start []byte) []string {
+	t.Helper()
+
+	it, err := store.ScanFrom(context.Background(), prefix, start)
+	if err != nil {
+		t.Fatalf("ScanFrom failed: %v", err)
+	}
+	defer it.Close()
+	var keys []string
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("ScanFrom iteration failed: %v", err)
+	}
+	return keys
+}
+
+func TestStorageBackend_TxnConflict(t *testing.T) {
+	ctx := context.Background()
+	store := newKVStore(NewMemoryBackend(), "")
+	if err := store.Set(ctx, "docs", "1", []byte("a")); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	err := store.WithUpdate(ctx, func(txn kvTxn) error {
+		if _, err := txn.Get([]byte("docs:1")); err != nil {
+			return err
+		}
+		// 嵌套的独立事务先提交，修改了外层事务读取的键
+		if err := store.Set(ctx, "docs", "1", []byte("b")); err != nil {
+			return err
+		}
+		return txn.Set([]byte("docs:1"), []byte("c"))
+	})
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+	if v, _ := store.Get(ctx, "docs", "1"); string(v) != "b" {
+		t.Errorf("Expected conflicting write to be discarded, got %q", v)
+	}
+}
@@ -0,0 +1,126 @@
+package rxdb
+
+import (
+	"context"
+
+	bstore "github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
+)
+
+// ForEach 逐个将匹配 filter 的文档传给 fn，不会把完整结果集加载到内存。
+// fn 返回错误时停止遍历并返回该错误。遍历基于开始时刻的只读快照，fn 中可以安全地写入集合。
+func (c *collection) ForEach(ctx context.Context, filter map[string]any, fn func(Document) error) error {
+	return c.Find(filter).ForEach(ctx, fn)
+}
+
+// Stream 在后台 goroutine 中遍历匹配 filter 的文档并逐个发送到返回的文档通道。
+// 遍历结束后两个通道都会关闭；出错或 ctx 取消时错误通道会先收到一个错误。
+func (c *collection) Stream(ctx context.Context, filter map[string]any) (<-chan Document, <-chan error) {
+	docCh := make(chan Document)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(errCh)
+		defer close(docCh)
+		err := c.ForEach(ctx, filter, func(doc Document) error {
+			select {
+			case docCh <- doc:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errCh <- err
+		}
+	}()
+	return docCh, errCh
+}
+
+// ForEach 逐个将查询结果传给 fn，Sort/Skip/Limit 与投影照常生效。
+// 未设置排序（含 $near、$text 与游标分页的隐式排序）时直接遍历存储游标，每次只解码一个文档；
+// 需要排序时必须先收集全部匹配文档，此时退化为 Exec 后逐个回调。
+// ctx 取消或 QueryOptions.Timeout 到期时停止遍历并返回 ctx.Err()。
+func (q *Query) ForEach(ctx context.Context, fn func(Document) error) error {
+	ctx, cancel := q.withTimeout(ctx)
+	defer cancel()
+
+	if !q.streamable() {
+		docs, err := q.Exec(ctx)
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(doc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := validateRegexes(q.selector); err != nil {
+		return err
+	}
+	if q.limit == 0 {
+		return nil
+	}
+
+	c := q.collection
+	if err := c.beginOp(ctx); err != nil {
+		return err
+	}
+	defer c.endOp()
+
+	c.mu.RLock()
+	if c.closed {
+		c.mu.RUnlock()
+		return NewError(ErrorTypeClosed, "collection is closed", nil)
+	}
+	prefix := bstore.BucketPrefix(c.name)
+	it, err := c.store.Scan(ctx, prefix)
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	skipped, emitted := 0, 0
+	for it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, err := c.decodeStoredDocument(it.Value())
+		if err != nil {
+			return err
+		}
+		if !q.match(data) {
+			continue
+		}
+		if skipped < q.skip {
+			skipped++
+			continue
+		}
+
+		doc, err := c.transformDocument(ctx, acquireDocument(string(it.Key()[len(prefix):]), data, c))
+		if err != nil {
+			return err
+		}
+		if err := fn(q.project([]Document{doc})[0]); err != nil {
+			return err
+		}
+		emitted++
+		if q.limit > 0 && emitted >= q.limit {
+			return nil
+		}
+	}
+	return it.Err()
+}
+
+// streamable 判断查询结果是否可以按存储顺序直接流式输出，无需先收集全部文档再排序。
+func (q *Query) streamable() bool {
+	if len(q.sortFields) > 0 || q.withCursor || q.cache != nil || selectorHasOperator(q.selector, "$text") {
+		return false
+	}
+	_, _, hasNear := q.findNearSpec()
+	return !hasNear
+}
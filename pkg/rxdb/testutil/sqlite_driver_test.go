@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package testutil
+
+// 以 -tags sqlite 运行测试时注册 SQLite 驱动，供 RXDB_TEST_BACKEND=sqlite 使用。
+import _ "modernc.org/sqlite"
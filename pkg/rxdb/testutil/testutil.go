@@ -0,0 +1,76 @@
+// Package testutil 提供编写 rxdb 相关测试时使用的辅助函数。
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/mozhou-tech/rxdb-go/pkg/rxdb"
+)
+
+// NewTestDatabase 在 t.TempDir() 下创建临时数据库，测试结束时自动关闭并删除数据目录。
+func NewTestDatabase(t testing.TB) rxdb.Database {
+	t.Helper()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "testdb.db")
+	db, err := rxdb.CreateDatabase(ctx, rxdb.DatabaseOptions{
+		Name:    "testdb",
+		Path:    path,
+		Backend: newBackend(t, dir),
+	})
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close(ctx)
+		os.RemoveAll(path)
+	})
+	return db
+}
+
+// NewTestCollection 在 db 中创建集合，创建失败时立即终止测试。
+// 集合随数据库一起在测试结束时关闭。
+func NewTestCollection(t testing.TB, db rxdb.Database, name string, schema rxdb.Schema) rxdb.Collection {
+	t.Helper()
+
+	coll, err := db.Collection(context.Background(), name, schema)
+	if err != nil {
+		t.Fatalf("failed to create test collection %s: %v", name, err)
+	}
+	return coll
+}
+
+// BackendEnv 选择测试数据库使用的存储后端：badger（默认）、memory 或 sqlite。
+// sqlite 需要测试程序注册 modernc.org/sqlite 驱动（import _ "modernc.org/sqlite"），
+// 本包的测试以 -tags sqlite 构建时会自动注册。
+const BackendEnv = "RXDB_TEST_BACKEND"
+
+// newBackend 按 BackendEnv 创建存储后端，badger 返回 nil 以使用默认后端。
+func newBackend(t testing.TB, dir string) rxdb.StorageBackend {
+	t.Helper()
+
+	switch name := os.Getenv(BackendEnv); name {
+	case "", "badger":
+		return nil
+	case "memory":
+		return rxdb.NewMemoryBackend()
+	case "sqlite":
+		if !slices.Contains(sql.Drivers(), rxdb.SQLiteDriverName) {
+			t.Fatalf("sqlite driver %q not registered, import modernc.org/sqlite in tests", rxdb.SQLiteDriverName)
+		}
+		backend, err := rxdb.OpenSQLiteBackend(filepath.Join(dir, "rxdb.sqlite"))
+		if err != nil {
+			t.Fatalf("failed to open sqlite backend: %v", err)
+		}
+		return backend
+	default:
+		t.Fatalf("unsupported %s %q", BackendEnv, name)
+		return nil
+	}
+}
@@ -0,0 +1,25 @@
+package testutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mozhou-tech/rxdb-go/pkg/rxdb"
+)
+
+func TestNewTestCollection(t *testing.T) {
+	ctx := context.Background()
+	db := NewTestDatabase(t)
+	coll := NewTestCollection(t, db, "users", rxdb.Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	if _, err := coll.Insert(ctx, map[string]any{"id": "u1", "name": "Alice"}); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+	doc, err := coll.FindByID(ctx, "u1")
+	if err != nil {
+		t.Fatalf("failed to find by id: %v", err)
+	}
+	if doc.Get("name") != "Alice" {
+		t.Errorf("expected name Alice, got %v", doc.Get("name"))
+	}
+}
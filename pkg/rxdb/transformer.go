@@ -0,0 +1,81 @@
+package rxdb
+
+import "context"
+
+// ReadTransformer 在文档返回给调用方之前对其进行转换，例如添加计算字段或移除敏感字段。
+// 转换只影响返回值，不会修改存储中的文档。
+type ReadTransformer interface {
+	Transform(ctx context.Context, doc Document) (Document, error)
+}
+
+// ReadTransformerFunc 允许将普通函数用作 ReadTransformer。
+type ReadTransformerFunc func(ctx context.Context, doc Document) (Document, error)
+
+// Transform 实现 ReadTransformer 接口。
+func (f ReadTransformerFunc) Transform(ctx context.Context, doc Document) (Document, error) {
+	return f(ctx, doc)
+}
+
+// fieldMaskTransformer 从返回的文档中移除指定字段。
+type fieldMaskTransformer struct {
+	fields []string
+}
+
+// FieldMaskTransformer 返回移除指定字段的读取转换器。
+// 返回的是文档副本，被移除的字段不会出现在 Data() 中；
+// 对副本调用 Save/Update 会以副本内容覆盖存储，因此掩码后的文档应仅用于读取。
+func FieldMaskTransformer(fields ...string) ReadTransformer {
+	return &fieldMaskTransformer{fields: fields}
+}
+
+// Transform 实现 ReadTransformer 接口。
+func (t *fieldMaskTransformer) Transform(ctx context.Context, doc Document) (Document, error) {
+	masked := doc.Clone()
+	data := masked.Data()
+	for _, field := range t.fields {
+		delete(data, field)
+	}
+	return masked, nil
+}
+
+// SetReadTransformer 设置集合的读取转换器，传入 nil 取消转换。
+// 转换器作用于 FindByID、FindByIDs、All、Find、Iterator 以及写入操作返回的文档。
+func (c *collection) SetReadTransformer(t ReadTransformer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readTransformer = t
+}
+
+// transformDocument 对单个文档应用读取转换器，调用时不能持有 c.mu。
+func (c *collection) transformDocument(ctx context.Context, doc Document) (Document, error) {
+	c.mu.RLock()
+	t := c.readTransformer
+	c.mu.RUnlock()
+
+	if t == nil || doc == nil {
+		return doc, nil
+	}
+	return t.Transform(ctx, doc)
+}
+
+// transformDocuments 对文档列表应用读取转换器，nil 元素保持不变。
+func (c *collection) transformDocuments(ctx context.Context, docs []Document) ([]Document, error) {
+	c.mu.RLock()
+	t := c.readTransformer
+	c.mu.RUnlock()
+
+	if t == nil {
+		return docs, nil
+	}
+	for i, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		transformed, err := t.Transform(ctx, doc)
+		if err != nil {
+			return nil, err
+		}
+		docs[i] = transformed
+	}
+	return docs, nil
+}
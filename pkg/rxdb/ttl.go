@@ -0,0 +1,194 @@
+package rxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	bstore "github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
+)
+
+// defaultTTLPollInterval TTL 过期检查的默认间隔
+const defaultTTLPollInterval = time.Minute
+
+// ChangeSourceTTL 表示由 TTL 过期清理删除文档所产生的变更。
+const ChangeSourceTTL = "ttl"
+
+// TTLOptions 文档过期配置：文档在 TTLField 记录的时间之后 TTLSeconds 秒过期，由后台 goroutine 删除。
+type TTLOptions struct {
+	TTLField   string // RFC3339 时间戳字段名，缺失或无法解析的文档不会过期
+	TTLSeconds int    // 存活秒数，0 表示 TTLField 本身即为过期时间
+}
+
+// enabled 报告 TTL 配置是否有效。
+func (o *TTLOptions) enabled() bool {
+	return o != nil && o.TTLField != ""
+}
+
+// expired 报告 doc 在 now 时刻是否已过期。
+func (o *TTLOptions) expired(doc map[string]any, now time.Time) bool {
+	raw, ok := getNestedValue(doc, o.TTLField).(string)
+	if !ok {
+		return false
+	}
+	ts, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return false
+	}
+	return now.After(ts.Add(time.Duration(o.TTLSeconds) * time.Second))
+}
+
+// startTTL 启动后台过期清理 goroutine，按 interval 定期删除过期文档，直到 stopTTL。
+func (c *collection) startTTL(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultTTLPollInterval
+	}
+	c.ttlStop = make(chan struct{})
+	c.ttlDone = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer close(c.ttlDone)
+		defer cancel()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.ttlStop:
+				return
+			case <-c.closeChan:
+				return
+			case <-ticker.C:
+				if _, err := c.removeExpired(ctx); err != nil && ctx.Err() == nil {
+					c.logger.Warn("TTL expiry failed", "collection", c.name, "error", err)
+				}
+			}
+		}
+	}()
+
+	// 停止时取消正在进行的清理
+	go func() {
+		select {
+		case <-c.ttlStop:
+			cancel()
+		case <-c.closeChan:
+			cancel()
+		case <-c.ttlDone:
+		}
+	}()
+}
+
+// stopTTL 停止后台过期清理并等待其退出，可重复调用。
+func (c *collection) stopTTL() {
+	if c.ttlStop == nil {
+		return
+	}
+	c.ttlStopOnce.Do(func() {
+		close(c.ttlStop)
+	})
+	<-c.ttlDone
+}
+
+// removeExpired 删除所有已过期的文档，返回删除数量。
+// 删除产生的 ChangeEvent 的 Source 为 ChangeSourceTTL。
+func (c *collection) removeExpired(ctx context.Context) (int, error) {
+	opts := c.schema.TTL
+	if !opts.enabled() {
+		return 0, nil
+	}
+
+	docs, err := c.Find(map[string]any{opts.TTLField: map[string]any{"$exists": true}}).Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	ctx = WithChangeSource(ctx, ChangeSourceTTL)
+	removed := 0
+	for _, doc := range docs {
+		if !opts.expired(doc.Data(), now) {
+			continue
+		}
+		ok, err := c.removeIfExpired(ctx, doc.ID(), now)
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// removeIfExpired 在删除事务中重新读取文档并确认其仍然过期后删除，
+// 避免删除在查询之后被并发刷新了过期时间的文档。文档不存在或未过期时返回 false。
+func (c *collection) removeIfExpired(ctx context.Context, id string, now time.Time) (bool, error) {
+	if err := c.beginOp(ctx); err != nil {
+		return false, err
+	}
+	defer c.endOp()
+
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+		return false, NewError(ErrorTypeClosed, "collection is closed", nil)
+	}
+
+	var oldDoc map[string]any
+	var attachmentsToDelete []*Attachment
+	err := c.updateWithChangelog(ctx, func(txn kvTxn, changes *changelogTxn) error {
+		oldDoc = nil
+		data, err := txn.Get(bstore.BucketKey(c.name, id))
+		if errors.Is(err, ErrKeyNotFound) {
+			// 已被并发删除
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		doc, err := c.decodeStoredDocument(data)
+		if err != nil {
+			return err
+		}
+		if !c.schema.TTL.expired(doc, now) {
+			return nil
+		}
+
+		for _, hook := range c.preRemove {
+			if err := hook(ctx, nil, doc); err != nil {
+				return fmt.Errorf("preRemove hook failed: %w", err)
+			}
+		}
+		if err := runBeforeDelete(ctx, c.hooks, id); err != nil {
+			return err
+		}
+
+		if attachmentsToDelete, err = c.deleteDocumentInTx(txn, id, doc); err != nil {
+			return err
+		}
+		oldDoc = doc
+		return changes.add(id, OperationDelete)
+	})
+	if err != nil {
+		c.mu.Unlock()
+		return false, fmt.Errorf("failed to remove expired document: %w", err)
+	}
+	if oldDoc == nil {
+		c.mu.Unlock()
+		return false, nil
+	}
+
+	changeEvent := c.afterRemove(ctx, id, oldDoc, attachmentsToDelete)
+	afterDelete := c.hooks.AfterDelete
+
+	// 释放锁后再发送变更事件，避免死锁
+	c.mu.Unlock()
+	if afterDelete != nil {
+		afterDelete(ctx, id)
+	}
+	c.emitChange(ctx, changeEvent)
+	return true, nil
+}
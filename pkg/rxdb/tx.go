@@ -0,0 +1,583 @@
+package rxdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	bstore "github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
+)
+
+// ErrTxDone 事务已提交或已回滚后继续使用时返回。
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+// Tx 跨集合的多文档事务。写操作先缓存在事务中，Commit 时在同一个存储事务中全部写入，
+// Rollback 丢弃所有缓存的写操作。变更事件只在提交成功后发送。
+// 提交时按每个文档的最终状态调用集合的 SchemaHooks：Before* 钩子在加锁前调用，任一钩子返回错误时整个事务不写入；
+// After* 钩子在提交成功后调用。
+type Tx interface {
+	// Collection 返回事务内的集合视图，集合必须已通过 Database.Collection 打开
+	Collection(name string) TxCollection
+	// Commit 原子地写入所有缓存的写操作，任一操作失败时不写入任何数据
+	Commit(ctx context.Context) error
+	// Rollback 丢弃所有缓存的写操作
+	Rollback() error
+}
+
+// TxCollection 事务内的集合视图，写操作在 Commit 前不可见。
+type TxCollection interface {
+	Insert(ctx context.Context, doc map[string]any) error
+	Upsert(ctx context.Context, doc map[string]any) error
+	// Update 更新文档字段，updates 语义与 Document.Update 相同（支持更新操作符），在提交时基于最新文档应用
+	Update(ctx context.Context, id string, updates map[string]any) error
+	Remove(ctx context.Context, id string) error
+}
+
+// 事务写操作类型
+const (
+	txOpInsert = "insert"
+	txOpUpsert = "upsert"
+	txOpUpdate = "update"
+	txOpRemove = "remove"
+)
+
+// txOp 缓存的写操作。
+type txOp struct {
+	collection *collection
+	kind       string
+	id         string
+	doc        map[string]any // insert/upsert 的文档，update 的更新内容
+}
+
+// tx 是 Tx 接口的默认实现。
+type tx struct {
+	db   *database
+	mu   sync.Mutex
+	ops  []txOp
+	done bool
+}
+
+// txCollection 是 TxCollection 接口的默认实现。
+type txCollection struct {
+	tx         *tx
+	name       string
+	collection *collection
+}
+
+// BeginTx 开始一个事务。
+func (d *database) BeginTx(ctx context.Context) (Tx, error) {
+	d.mu.RLock()
+	closed := d.closed
+	d.mu.RUnlock()
+	if closed {
+		return nil, errors.New("database is closed")
+	}
+	return &tx{db: d}, nil
+}
+
+func (t *tx) Collection(name string) TxCollection {
+	t.db.mu.RLock()
+	col := t.db.collections[name]
+	t.db.mu.RUnlock()
+	return &txCollection{tx: t, name: name, collection: col}
+}
+
+// add 缓存一个写操作。
+func (t *tx) add(op txOp) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return ErrTxDone
+	}
+	t.ops = append(t.ops, op)
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return ErrTxDone
+	}
+	t.done = true
+	t.ops = nil
+	return nil
+}
+
+func (tc *txCollection) check() error {
+	if tc.collection == nil {
+		return NewError(ErrorTypeNotFound, fmt.Sprintf("collection %s is not open", tc.name), nil)
+	}
+	return nil
+}
+
+// prepare 复制并校验待写入的文档，返回文档与主键。
+func (tc *txCollection) prepare(doc map[string]any) (map[string]any, string, error) {
+	if doc == nil {
+		return nil, "", errors.New("document cannot be nil")
+	}
+	c := tc.collection
+	doc = DeepCloneMap(doc)
+	c.stripVirtualFields(doc)
+	if err := c.validatePrimaryKey(doc); err != nil {
+		return nil, "", err
+	}
+	id, err := c.extractPrimaryKey(doc)
+	if err != nil {
+		return nil, "", err
+	}
+	return doc, id, nil
+}
+
+func (tc *txCollection) Insert(ctx context.Context, doc map[string]any) error {
+	if err := tc.check(); err != nil {
+		return err
+	}
+	doc, id, err := tc.prepare(doc)
+	if err != nil {
+		return err
+	}
+	return tc.tx.add(txOp{collection: tc.collection, kind: txOpInsert, id: id, doc: doc})
+}
+
+func (tc *txCollection) Upsert(ctx context.Context, doc map[string]any) error {
+	if err := tc.check(); err != nil {
+		return err
+	}
+	doc, id, err := tc.prepare(doc)
+	if err != nil {
+		return err
+	}
+	return tc.tx.add(txOp{collection: tc.collection, kind: txOpUpsert, id: id, doc: doc})
+}
+
+func (tc *txCollection) Update(ctx context.Context, id string, updates map[string]any) error {
+	if err := tc.check(); err != nil {
+		return err
+	}
+	if len(updates) == 0 {
+		return NewError(ErrorTypeValidation, "updates cannot be empty", nil)
+	}
+	return tc.tx.add(txOp{collection: tc.collection, kind: txOpUpdate, id: id, doc: DeepCloneMap(updates)})
+}
+
+func (tc *txCollection) Remove(ctx context.Context, id string) error {
+	if err := tc.check(); err != nil {
+		return err
+	}
+	return tc.tx.add(txOp{collection: tc.collection, kind: txOpRemove, id: id})
+}
+
+// txDocState 事务中单个文档的状态。
+type txDocState struct {
+	collection *collection
+	id         string
+	loaded     bool
+	orig       map[string]any // 事务开始写入前存储中的文档，不存在时为 nil
+	cur        map[string]any // 应用缓存操作后的文档，删除后为 nil
+}
+
+// txResult 提交后用于发送事件的单个文档结果。
+type txResult struct {
+	state       *txDocState
+	rev         string
+	attachments []*Attachment
+}
+
+func (t *tx) Commit(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done {
+		return ErrTxDone
+	}
+	t.done = true
+	ops := t.ops
+	t.ops = nil
+	if len(ops) == 0 {
+		return nil
+	}
+
+	if err := t.db.beginOp(ctx); err != nil {
+		return err
+	}
+	defer t.db.endOp()
+
+	writes := make(map[*collection]int)
+	for _, op := range ops {
+		writes[op.collection]++
+	}
+	cols := make([]*collection, 0, len(writes))
+	for c, n := range writes {
+		if err := c.waitWriteTokens(ctx, n); err != nil {
+			return err
+		}
+		cols = append(cols, c)
+	}
+	sort.Slice(cols, func(i, j int) bool { return cols[i].name < cols[j].name })
+
+	hooks := make(map[*collection]SchemaHooks, len(cols))
+	hooked := false
+	for _, c := range cols {
+		h := c.schemaHooks()
+		hooks[c] = h
+		hooked = hooked || h.BeforeInsert != nil || h.BeforeUpdate != nil || h.BeforeDelete != nil
+	}
+	unlock := func() {
+		for i := len(cols) - 1; i >= 0; i-- {
+			cols[i].mu.Unlock()
+		}
+	}
+
+	var results []txResult
+	var err error
+	for attempt := 1; ; attempt++ {
+		// 设置了 Before* 钩子时，先在加锁和开启存储事务之前基于当前数据计算各文档的最终状态并调用钩子，
+		// 提交时确认这些文档未被并发修改
+		var prepared []*txDocState
+		if hooked {
+			if prepared, err = t.prepareHooks(ctx, ops, hooks); err != nil {
+				return err
+			}
+		}
+
+		// 按集合名加锁，保证与其他事务的加锁顺序一致
+		for _, c := range cols {
+			c.mu.Lock()
+		}
+		for _, c := range cols {
+			if c.closed {
+				unlock()
+				return errors.New("collection is closed")
+			}
+		}
+
+		results, err = t.commitOps(ctx, ops, prepared)
+		if !errors.Is(err, errHookStale) || attempt == maxHookAttempts {
+			break
+		}
+		unlock()
+	}
+	if err != nil {
+		unlock()
+		if errors.Is(err, errHookStale) {
+			return hookConflictError()
+		}
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// 提交成功后准备变更事件
+	type pending struct {
+		collection *collection
+		event      ChangeEvent
+		post       func()
+	}
+	events := make([]pending, 0, len(results))
+	for _, r := range results {
+		s, c := r.state, r.state.collection
+		h := hooks[c]
+		if s.cur == nil {
+			id := s.id
+			events = append(events, pending{
+				collection: c,
+				event:      c.afterRemove(ctx, id, s.orig, r.attachments),
+				post: func() {
+					if h.AfterDelete != nil {
+						h.AfterDelete(ctx, id)
+					}
+				},
+			})
+			continue
+		}
+		c.idBloomFilter.Add(s.id)
+		op := OperationUpdate
+		if s.orig == nil {
+			op = OperationInsert
+		}
+		doc, old := s.cur, s.orig
+		events = append(events, pending{
+			collection: c,
+			event: ChangeEvent{
+				Collection: c.name,
+				ID:         s.id,
+				Op:         op,
+				Doc:        doc,
+				Old:        old,
+				Meta:       map[string]interface{}{"rev": r.rev},
+			},
+			post: func() {
+				for _, hook := range c.postSave {
+					_ = hook(ctx, doc, old)
+				}
+				if old == nil {
+					for _, hook := range c.postInsert {
+						_ = hook(ctx, doc, nil)
+					}
+				}
+				c.runAfterWrite(ctx, h, s.id, doc, old)
+			},
+		})
+	}
+
+	// 释放锁后再调用后置钩子和发送变更事件，避免死锁
+	unlock()
+	for _, p := range events {
+		if p.post != nil {
+			p.post()
+		}
+		p.collection.emitChange(ctx, p.event)
+	}
+	return nil
+}
+
+// applyTxOps 在存储事务中按顺序应用缓存操作，返回按首次访问顺序排列的文档状态。
+func applyTxOps(ctx context.Context, txn kvTxn, ops []txOp) ([]*txDocState, error) {
+	states := make(map[string]*txDocState)
+	var order []*txDocState
+	for _, op := range ops {
+		key := op.collection.name + "\x00" + op.id
+		state, ok := states[key]
+		if !ok {
+			state = &txDocState{collection: op.collection, id: op.id}
+			states[key] = state
+			order = append(order, state)
+		}
+		if err := state.apply(ctx, txn, op); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// prepareHooks 在只读事务中应用缓存操作，并对各文档的最终状态调用 Before* 钩子。
+func (t *tx) prepareHooks(ctx context.Context, ops []txOp, hooks map[*collection]SchemaHooks) ([]*txDocState, error) {
+	var order []*txDocState
+	err := t.db.store.WithView(ctx, func(txn kvTxn) error {
+		var err error
+		order, err = applyTxOps(ctx, txn, ops)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, state := range order {
+		if err := state.runBeforeHooks(ctx, hooks[state.collection]); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// commitOps 在一个存储事务中应用并写入所有缓存操作，调用方需持有相关集合的锁。
+// prepared 非 nil 时为 prepareHooks 的结果：文档已被并发修改时返回 errHookStale，否则写入钩子处理后的文档。
+func (t *tx) commitOps(ctx context.Context, ops []txOp, prepared []*txDocState) ([]txResult, error) {
+	var results []txResult
+	changelogs := make(map[*collection]*changelogTxn)
+	err := t.db.store.WithUpdate(ctx, func(txn kvTxn) error {
+		order, err := applyTxOps(ctx, txn, ops)
+		if err != nil {
+			return err
+		}
+		if prepared != nil {
+			for i, state := range order {
+				if !state.collection.sameRevision(state.orig, prepared[i].orig) {
+					return errHookStale
+				}
+				state.cur = prepared[i].cur
+			}
+		}
+		for _, state := range order {
+			result, skip, err := state.write(ctx, txn)
+			if err != nil {
+				return err
+			}
+			if skip {
+				continue
+			}
+			results = append(results, result)
+
+			// 变更日志与文档写入在同一事务中提交
+			c := state.collection
+			changes, ok := changelogs[c]
+			if !ok {
+				changes = c.changelog(ctx, txn)
+				changelogs[c] = changes
+			}
+			op := OperationUpdate
+			if state.cur == nil {
+				op = OperationDelete
+			} else if state.orig == nil {
+				op = OperationInsert
+			}
+			if err := changes.add(state.id, op); err != nil {
+				return err
+			}
+		}
+		for _, changes := range changelogs {
+			if err := changes.trim(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	for _, changes := range changelogs {
+		changes.finish(err == nil)
+	}
+	return results, err
+}
+
+// runBeforeHooks 按文档的最终状态调用 BeforeInsert、BeforeUpdate 或 BeforeDelete 钩子，
+// 钩子返回的文档替换 cur。新文档的默认值与 Schema 验证在写入时进行。
+func (s *txDocState) runBeforeHooks(ctx context.Context, hooks SchemaHooks) error {
+	c := s.collection
+	switch {
+	case s.orig == nil && s.cur == nil:
+		return nil
+	case s.cur == nil:
+		return runBeforeDelete(ctx, hooks, s.id)
+	case s.orig == nil:
+		if hooks.BeforeInsert == nil {
+			return nil
+		}
+		doc, err := runBeforeInsert(ctx, hooks, s.cur)
+		if err != nil {
+			return err
+		}
+		c.stripVirtualFields(doc)
+		if err := c.checkHookPrimaryKey(s.id, doc); err != nil {
+			return err
+		}
+		s.cur = doc
+	default:
+		doc, err := c.runBeforeUpdate(ctx, hooks, s.id, s.orig, s.cur)
+		if err != nil {
+			return err
+		}
+		s.cur = doc
+	}
+	return nil
+}
+
+// load 在首次访问时从存储事务中读取文档。
+func (s *txDocState) load(txn kvTxn) error {
+	if s.loaded {
+		return nil
+	}
+	s.loaded = true
+	val, err := txn.Get(bstore.BucketKey(s.collection.name, s.id))
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	doc, err := s.collection.decodeStoredDocument(val)
+	if err != nil {
+		return err
+	}
+	s.orig = doc
+	s.cur = DeepCloneMap(doc)
+	return nil
+}
+
+// apply 将一个缓存操作应用到文档状态。
+func (s *txDocState) apply(ctx context.Context, txn kvTxn, op txOp) error {
+	if err := s.load(txn); err != nil {
+		return err
+	}
+	c := s.collection
+
+	switch op.kind {
+	case txOpInsert:
+		if s.cur != nil {
+			return NewError(ErrorTypeAlreadyExists, fmt.Sprintf("document with id %s already exists", s.id), nil).
+				WithContext("document_id", s.id)
+		}
+		s.cur = DeepCloneMap(op.doc)
+	case txOpUpsert:
+		s.cur = DeepCloneMap(op.doc)
+	case txOpUpdate:
+		if s.cur == nil {
+			return NewError(ErrorTypeNotFound, fmt.Sprintf("document with id %s not found", s.id), nil)
+		}
+		if hasUpdateOperators(op.doc) {
+			if err := applyUpdateOperators(s.cur, op.doc, c.isPrimaryKeyField); err != nil {
+				return err
+			}
+		} else {
+			for k, v := range op.doc {
+				if !c.isPrimaryKeyField(k) {
+					s.cur[k] = deepCloneValue(v)
+				}
+			}
+		}
+	case txOpRemove:
+		if s.cur == nil {
+			return NewError(ErrorTypeNotFound, fmt.Sprintf("document with id %s not found", s.id), nil)
+		}
+		s.cur = nil
+	}
+	return nil
+}
+
+// write 校验文档的最终状态并写入存储事务；文档在事务前后都不存在时 skip 为 true。
+func (s *txDocState) write(ctx context.Context, txn kvTxn) (result txResult, skip bool, err error) {
+	c := s.collection
+	result.state = s
+	if s.orig == nil && s.cur == nil {
+		return result, true, nil
+	}
+
+	if s.cur == nil {
+		for _, hook := range c.preRemove {
+			if err := hook(ctx, nil, s.orig); err != nil {
+				return result, false, fmt.Errorf("preRemove hook failed: %w", err)
+			}
+		}
+		result.attachments, err = c.deleteDocumentInTx(txn, s.id, s.orig)
+		return result, false, err
+	}
+
+	doc := s.cur
+	if s.orig == nil {
+		ApplyDefaults(c.schema, doc)
+	}
+	if err := c.validateDocument(doc); err != nil {
+		return result, false, NewError(ErrorTypeValidation, "schema validation failed", err)
+	}
+	if s.orig != nil {
+		if err := ValidateFinalFields(c.schema, s.orig, doc); err != nil {
+			return result, false, fmt.Errorf("final field validation failed: %w", err)
+		}
+	}
+	if err := runValidatorList(ctx, c.validators, doc); err != nil {
+		return result, false, err
+	}
+	if s.orig == nil {
+		for _, hook := range c.preInsert {
+			if err := hook(ctx, doc, nil); err != nil {
+				return result, false, fmt.Errorf("preInsert hook failed: %w", err)
+			}
+		}
+	}
+	for _, hook := range c.preSave {
+		if err := hook(ctx, doc, s.orig); err != nil {
+			return result, false, fmt.Errorf("preSave hook failed: %w", err)
+		}
+	}
+
+	// 同一文档在事务中的多次写入只生成一个新修订号
+	var oldRev string
+	if s.orig != nil {
+		if r, ok := s.orig[c.schema.RevField]; ok {
+			oldRev = fmt.Sprintf("%v", r)
+		}
+	}
+	if result.rev, err = c.nextRevision(oldRev, doc); err != nil {
+		return result, false, fmt.Errorf("failed to generate revision: %w", err)
+	}
+	doc[c.schema.RevField] = result.rev
+
+	return result, false, c.writeDocInTx(txn, s.id, doc, s.orig)
+}
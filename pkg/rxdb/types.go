@@ -2,6 +2,7 @@ package rxdb
 
 import (
 	"context"
+	"fmt"
 )
 
 // Operation 表示文档变更类型。
@@ -11,6 +12,8 @@ const (
 	OperationInsert Operation = "insert"
 	OperationUpdate Operation = "update"
 	OperationDelete Operation = "delete"
+	// OperationTruncate 集合被 Truncate 清空，事件不携带 ID 与文档
+	OperationTruncate Operation = "truncate"
 )
 
 // ChangeEvent 与 RxDB 变更事件概念对齐，用于本地事件流与同步。
@@ -21,6 +24,7 @@ type ChangeEvent struct {
 	Doc        map[string]any         // 新文档数据（delete 时可为空）
 	Old        map[string]any         // 旧文档数据（insert 时可为空）
 	Meta       map[string]interface{} // 额外元数据（修订号等）
+	Source     string                 // 变更来源：本地写入为空，复制等远程写入为 ChangeSourceRemote
 }
 
 // FieldChangeEvent 表示字段级别的变更事件。
@@ -30,6 +34,20 @@ type FieldChangeEvent struct {
 	New   interface{} // 新值
 }
 
+// 字段差异类型，用于 DiffEntry.Type。
+const (
+	DiffAdded   = "added"   // 字段仅存在于新文档
+	DiffRemoved = "removed" // 字段仅存在于旧文档
+	DiffChanged = "changed" // 字段值发生变化
+)
+
+// DiffEntry 表示两个文档间单个字段的差异。
+type DiffEntry struct {
+	Old  any    // 旧值（added 时为 nil）
+	New  any    // 新值（removed 时为 nil）
+	Type string // added / removed / changed
+}
+
 // Attachment 表示文档附件
 type Attachment struct {
 	ID       string // 附件 ID
@@ -49,6 +67,9 @@ type Attachment struct {
 // 参数：oldDoc 是旧版本的文档数据，返回迁移后的新文档数据
 type MigrationStrategy func(oldDoc map[string]any) (map[string]any, error)
 
+// MigrationFn 数据库级迁移函数，将数据库从 fromVersion 迁移到 fromVersion+1。
+type MigrationFn func(ctx context.Context, db Database, fromVersion int) error
+
 // Schema 采用 RxDB JSON schema 的子集，后续根据需要扩展。
 type Schema struct {
 	JSON                map[string]any            // 原始 JSON Schema
@@ -58,12 +79,33 @@ type Schema struct {
 	MigrationStrategies map[int]MigrationStrategy // 版本迁移策略，key 为目标版本号
 	EncryptedFields     []string                  // 需要加密的字段列表
 	KeyCompression      *bool                     // 是否启用键压缩
+	CoerceTypes         bool                      // 是否按 JSON Schema 声明的类型自动转换字段值（写入时在验证前）与查询值
+	DropMissingIndexes  bool                      // 重新打开集合时是否删除 Indexes 中未声明的已有索引（默认保留）
+	// Virtual 计算型只读字段，读取时按函数计算，写入时会被剔除
+	Virtual map[string]func(doc map[string]any) any
+	// TTL 文档过期配置（可选），检查间隔由 DatabaseOptions.TTLPollInterval 控制
+	TTL *TTLOptions
+	// DefaultGenerators 计算型默认值（如当前时间），键为字段路径（嵌套字段使用点号），
+	// 插入时字段缺失才会调用；同一字段同时在 JSON 中声明 default 时以生成函数为准。
+	// BulkInsert 会并发调用生成函数，函数需要是并发安全的
+	DefaultGenerators map[string]func() any
+	// Options 写入期选项（如 JSON Schema 验证模式）
+	Options SchemaOptions
+	// FulltextIndexes 声明式全文索引，打开集合时自动创建并同步，通过 GetFulltextIndex 获取
+	FulltextIndexes []FulltextIndexDeclaration
+	// VectorIndexes 声明式向量索引，打开集合时自动创建并同步，通过 GetVectorIndex 获取
+	VectorIndexes []VectorIndexDeclaration
+	// Changelog 持久化变更日志（可选）。启用后写操作在同一事务中记录文档 ID 与操作类型，
+	// Watch/WatchFrom 可从任意序列号恢复；为 nil 时 Watch 只转发实时事件，WatchFrom 返回错误
+	Changelog *ChangelogOptions
 }
 
 // Index 定义索引结构。
 type Index struct {
 	Fields []string // 索引字段列表（支持复合索引）
 	Name   string   // 索引名称（可选，用于唯一标识）
+	// Type 索引类型：空字符串为普通索引，IndexTypeGeo（"geo"）为单字段 GeoJSON Point 地理索引
+	Type string
 }
 
 // GraphDatabase 图数据库接口
@@ -78,10 +120,84 @@ type GraphDatabase interface {
 	FindPath(ctx context.Context, from, to string, maxDepth int, relations ...string) ([][]string, error)
 	// Query 创建查询对象
 	Query() GraphQuery
+	// Recommend 基于多跳加权随机游走推荐与起始节点相关的节点
+	Recommend(ctx context.Context, nodeID string, hops int, limit int) ([]string, error)
+	// Stats 返回图的节点数与边数统计
+	Stats(ctx context.Context) (*GraphStats, error)
+	// MergeNode 创建节点或按 RFC 7396 合并语义更新节点属性（值为 nil 的属性会被删除）
+	MergeNode(ctx context.Context, id string, attrs map[string]any) error
+	// GetNode 获取节点属性，节点不存在时返回 nil
+	GetNode(ctx context.Context, id string) (map[string]any, error)
+	// GetEdges 返回节点的所有出边
+	GetEdges(ctx context.Context, nodeID string) ([]GraphEdge, error)
+	// GetInEdges 返回指向节点的所有入边
+	GetInEdges(ctx context.Context, nodeID string) ([]GraphEdge, error)
+	// DeleteEdge 删除一条边，边不存在时不报错
+	DeleteEdge(ctx context.Context, subject, predicate, object string) error
+	// DeleteNode 删除节点属性；cascade 为 true 时同时删除所有涉及该节点的边，
+	// 为 false 时节点仍有边则返回错误
+	DeleteNode(ctx context.Context, nodeID string, cascade bool) error
+	// GetAllNodes 返回图中所有节点（出现在边中或设置过属性的节点），按 ID 排序
+	GetAllNodes(ctx context.Context) ([]string, error)
+	// PageRank 使用幂迭代计算节点的 PageRank 分数，返回节点 ID 到分数的映射
+	PageRank(ctx context.Context, opts PageRankOptions) (map[string]float64, error)
+	// Subgraph 从种子节点出发按广度优先提取子图
+	Subgraph(ctx context.Context, seedIDs []string, opts SubgraphOptions) (*GraphSubgraph, error)
 	// Close 关闭图数据库
 	Close() error
 }
 
+// GraphEdge 图中的一条边（subject -predicate-> object）
+type GraphEdge struct {
+	Subject   string
+	Predicate string
+	Object    string
+	// Properties 边属性（可选），当前存储后端不保存边属性时为 nil
+	Properties map[string]any
+}
+
+// PageRankOptions PageRank 计算选项
+type PageRankOptions struct {
+	// DampingFactor 阻尼系数，取值 (0, 1)，默认 0.85
+	DampingFactor float64
+	// MaxIterations 最大迭代次数，默认 100
+	MaxIterations int
+	// Tolerance 收敛阈值：两次迭代分数差的 L1 范数小于该值时停止，默认 1e-6
+	Tolerance float64
+	// Relation 只使用指定谓词的边，为空时使用所有边
+	Relation string
+	// Undirected 将每条边视为双向边（默认按边的方向传递分数）
+	Undirected bool
+	// Parallel 按节点分片并发计算每轮迭代，适用于大图（边数超过十万）
+	Parallel bool
+}
+
+// SubgraphOptions 子图提取选项
+type SubgraphOptions struct {
+	// MaxDepth 从种子节点出发的最大跳数，默认 1
+	MaxDepth int
+	// Relation 只沿指定谓词的边扩展，为空时使用所有边
+	Relation string
+	// MaxNodes 子图节点数上限（包括种子节点），<= 0 表示不限制
+	MaxNodes int
+	// Direction 扩展方向："out"（沿出边）、"in"（沿入边）或 "both"（默认）
+	Direction string
+}
+
+// GraphSubgraph 提取出的子图
+type GraphSubgraph struct {
+	// Nodes 子图节点，按广度优先的访问顺序排列（种子节点在前）
+	Nodes []string
+	// Edges 扩展过程中经过且两端都在子图中的边
+	Edges []GraphEdge
+}
+
+// GraphStats 图统计信息
+type GraphStats struct {
+	NodeCount int64 // 节点数量
+	EdgeCount int64 // 边数量
+}
+
 // GraphQuery 图查询接口（使用指针类型避免值复制）
 type GraphQuery interface {
 	// V 从指定节点开始查询
@@ -125,7 +241,7 @@ type GraphQueryResult struct {
 type GraphOptions struct {
 	// Enabled 是否启用图数据库
 	Enabled bool
-	// Backend 存储后端类型：bolt, leveldb, badger, memory
+	// Backend 存储后端类型：badger（默认）, leveldb, memory
 	Backend string
 	// Path 存储路径
 	Path string
@@ -139,12 +255,26 @@ type Database interface {
 	Close(ctx context.Context) error
 	Destroy(ctx context.Context) error
 	Collection(ctx context.Context, name string, schema Schema) (Collection, error)
+	// BeginTx 开始跨集合的多文档事务
+	BeginTx(ctx context.Context) (Tx, error)
+	// Collections 返回当前已打开集合的名称（按名称排序）
+	Collections() []string
+	// ExportSchema 导出所有已打开集合的 schema 与索引定义
+	ExportSchema(ctx context.Context) ([]SchemaExport, error)
+	// ImportSchema 按导出结果创建集合与索引；force 为 false 时跳过已存在的集合与索引
+	ImportSchema(ctx context.Context, schemas []SchemaExport, force bool) error
 	Changes() <-chan ChangeEvent
 	ExportJSON(ctx context.Context) (map[string]any, error)
 	ImportJSON(ctx context.Context, data map[string]any) error
 	Backup(ctx context.Context, backupPath string) error
 	WaitForLeadership(ctx context.Context) error
 	RequestIdle(ctx context.Context) error
+	// Ping 对底层存储执行一次最小读取，用于健康检查
+	Ping(ctx context.Context) error
+	// Compact 压缩底层存储，回收已删除数据占用的空间
+	Compact(ctx context.Context) error
+	// Migrate 按顺序执行数据库级迁移，将数据库版本从 from 升级到 to
+	Migrate(ctx context.Context, from, to int, migrations []MigrationFn) error
 	Password() string
 	MultiInstance() bool
 	// Graph 返回图数据库实例（如果已启用）
@@ -175,6 +305,88 @@ type GraphRelationMapping struct {
 	TargetField string
 	// AutoLink 是否自动创建链接
 	AutoLink bool
+	// Weight 关系权重（用于 Recommend 等加权算法），<= 0 时使用默认权重 1
+	Weight float64
+}
+
+// KeyValue 表示 MapReduce 中 map 阶段输出的键值对。
+type KeyValue struct {
+	Key   string
+	Value any
+}
+
+// MapFunc 对单个文档执行 map，输出零个或多个键值对。
+type MapFunc func(doc Document) []KeyValue
+
+// ReduceFunc 合并同一个键的所有值。
+type ReduceFunc func(key string, values []any) any
+
+// MapReduceOptions MapReduce 选项。
+type MapReduceOptions struct {
+	// NumWorkers 并行 worker 数量，<= 0 时使用 CPU 核数。
+	NumWorkers int
+}
+
+// BulkInsert 冲突处理策略。
+const (
+	// BulkInsertOnConflictError 遇到已存在的 ID 时整批回滚（默认）。
+	BulkInsertOnConflictError = "error"
+	// BulkInsertOnConflictSkip 跳过已存在的 ID，其余文档正常插入。
+	BulkInsertOnConflictSkip = "skip"
+	// BulkInsertOnConflictReplace 以 upsert 语义覆盖已存在的文档。
+	BulkInsertOnConflictReplace = "replace"
+)
+
+// BulkInsertOptions 批量插入选项。
+type BulkInsertOptions struct {
+	// OnConflict 冲突处理策略：error（默认）、skip、replace。
+	OnConflict string
+}
+
+// BulkInsertError 描述批量插入中单个文档的失败原因。
+type BulkInsertError struct {
+	Index int    // 文档在输入切片中的位置
+	ID    string // 文档主键
+	Err   error
+}
+
+// Error 实现 error 接口。
+func (e BulkInsertError) Error() string {
+	return fmt.Sprintf("document %s (index %d): %v", e.ID, e.Index, e.Err)
+}
+
+// Unwrap 返回底层错误。
+func (e BulkInsertError) Unwrap() error {
+	return e.Err
+}
+
+// SchemaExport 单个集合的 schema 导出，用于在新数据库中重建集合。
+// Schema 中的函数字段（Virtual、MigrationStrategies）无法序列化，导出时被清空。
+type SchemaExport struct {
+	CollectionName   string
+	Schema           Schema
+	IndexDefinitions []Index
+}
+
+// ConflictResolutionField 文档中保存同步冲突处理策略的字段名。
+const ConflictResolutionField = "_conflictResolution"
+
+// 单文档同步冲突处理策略，由复制层在本地与远程版本冲突时读取。
+const (
+	// ConflictServerWins 以远程版本为准。
+	ConflictServerWins = "server-wins"
+	// ConflictClientWins 保留本地版本。
+	ConflictClientWins = "client-wins"
+	// ConflictLastWriteWins 按更新时间字段保留较新的版本。
+	ConflictLastWriteWins = "last-write-wins"
+	// ConflictCustom 使用复制配置中的 ConflictHandler（未设置策略时的默认行为）。
+	ConflictCustom = "custom"
+)
+
+// DocumentOptions 单文档写入选项。
+type DocumentOptions struct {
+	// ConflictResolution 同步冲突处理策略，写入文档的 _conflictResolution 字段。
+	ConflictResolution string
 }
 
 // Collection 接口对齐 RxCollection 常用能力，后续再扩充。
@@ -182,19 +394,38 @@ type Collection interface {
 	Name() string
 	Schema() Schema
 	Insert(ctx context.Context, doc map[string]any) (Document, error)
+	InsertWithOptions(ctx context.Context, doc map[string]any, opts DocumentOptions) (Document, error)
 	Upsert(ctx context.Context, doc map[string]any) (Document, error)
 	IncrementalUpsert(ctx context.Context, patch map[string]any) (Document, error)
 	IncrementalModify(ctx context.Context, id string, modifier func(doc map[string]any) error) (Document, error)
+	Patch(ctx context.Context, id string, patch []JSONPatchOp) (Document, error)
 	Find(selector map[string]any) *Query
 	FindOne(ctx context.Context, selector map[string]any) (Document, error)
+	FindOneAndDelete(ctx context.Context, selector map[string]any) (Document, error)
+	FindOrCreate(ctx context.Context, selector, defaults map[string]any) (Document, bool, error)
+	FindAndModify(ctx context.Context, filter, update map[string]any, opts FindAndModifyOptions) (Document, error)
 	FindByID(ctx context.Context, id string) (Document, error)
+	FindByIDs(ctx context.Context, ids []string) ([]Document, error)
 	Exists(id string) bool
 	Remove(ctx context.Context, id string) error
 	All(ctx context.Context) ([]Document, error)
+	Iterator(ctx context.Context) (*DocumentIterator, error)
+	ForEach(ctx context.Context, filter map[string]any, fn func(Document) error) error
+	Stream(ctx context.Context, filter map[string]any) (<-chan Document, <-chan error)
 	Count(ctx context.Context) (int, error)
+	MapReduce(ctx context.Context, mapFn MapFunc, reduceFn ReduceFunc, opts MapReduceOptions) (map[string]any, error)
+	CountBy(ctx context.Context, field string) (map[string]int, error)
+	Distinct(ctx context.Context, field string, filter map[string]any) ([]any, error)
+	Max(ctx context.Context, field string, selector map[string]any) (any, error)
+	Min(ctx context.Context, field string, selector map[string]any) (any, error)
+	Aggregate(ctx context.Context, pipeline []AggregateStage) ([]map[string]any, error)
+	Pipeline() *AggregatePipeline
+	ObserveAggregate(ctx context.Context, pipeline []AggregateStage) <-chan map[string]any
 	BulkInsert(ctx context.Context, docs []map[string]any) ([]Document, error)
+	BulkInsertWithOptions(ctx context.Context, docs []map[string]any, opts BulkInsertOptions) ([]Document, []BulkInsertError, error)
 	BulkUpsert(ctx context.Context, docs []map[string]any) ([]Document, error)
 	BulkRemove(ctx context.Context, ids []string) error
+	Truncate(ctx context.Context) error
 	ExportJSON(ctx context.Context) ([]map[string]any, error)
 	ImportJSON(ctx context.Context, docs []map[string]any) error
 	Migrate(ctx context.Context) error
@@ -205,9 +436,19 @@ type Collection interface {
 	Dump(ctx context.Context) (map[string]any, error)
 	ImportDump(ctx context.Context, dump map[string]any) error
 	Changes() <-chan ChangeEvent
+	Watch(ctx context.Context, selector map[string]any) <-chan CDCEvent
+	WatchFrom(ctx context.Context, selector map[string]any, since int64) (<-chan CDCEvent, error)
+	WatchInserts(ctx context.Context) <-chan ChangeEvent
+	WatchUpdates(ctx context.Context) <-chan ChangeEvent
+	WatchDeletes(ctx context.Context) <-chan ChangeEvent
 	CreateIndex(ctx context.Context, index Index) error
 	DropIndex(ctx context.Context, indexName string) error
 	ListIndexes() []Index
+	GetFulltextIndex(identifier string) (*FulltextSearch, error)
+	GetVectorIndex(identifier string) (*VectorSearch, error)
+	AddValidator(v Validator)
+	SetReadTransformer(t ReadTransformer)
+	SetHooks(hooks SchemaHooks)
 	RegisterResyncHandler(handler func(ctx context.Context, docID string) error)
 	RegisterSyncStatusHandler(handler func() bool)
 	Synced(ctx context.Context) <-chan bool
@@ -224,10 +465,19 @@ type Document interface {
 	GetBool(field string) bool
 	GetArray(field string) []any
 	GetObject(field string) map[string]any
-	Set(ctx context.Context, field string, value any) error
+	GetNested(path string) (any, bool)
+	SetNested(ctx context.Context, path string, value any) error
+	GetNestedString(path string) (string, bool)
+	GetNestedFloat(path string) (float64, bool)
+	GetNestedInt(path string) (int, bool)
+	GetNestedBool(path string) (bool, bool)
+	GetNestedSlice(path string) ([]any, bool)
+	Set(field string, value any) Document
+	Apply(ctx context.Context) error
 	Update(ctx context.Context, updates map[string]any) error
 	Remove(ctx context.Context) error
 	Save(ctx context.Context) error
+	Clone() Document
 	Changes() <-chan ChangeEvent
 	ToJSON() ([]byte, error)
 	ToMutableJSON() (map[string]any, error)
@@ -244,5 +494,6 @@ type Document interface {
 	Populate(ctx context.Context, field string) (Document, error)
 	Resync(ctx context.Context) error
 	Synced(ctx context.Context) <-chan bool
+	Diff(other Document) map[string]DiffEntry
 	// TODO: 支持 reactive/getter/setter、同步状态观察等扩展
 }
@@ -0,0 +1,249 @@
+package rxdb
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// updateOperatorOrder 更新操作符的执行顺序。同一次更新中各操作符作用的字段路径不能重叠，因此顺序不影响结果。
+var updateOperatorOrder = []string{"$set", "$unset", "$inc", "$push", "$addToSet", "$pull", "$rename"}
+
+// hasUpdateOperators 报告 updates 是否使用更新操作符（键以 $ 开头）。
+func hasUpdateOperators(updates map[string]any) bool {
+	for k := range updates {
+		if strings.HasPrefix(k, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// applyUpdateOperators 将 MongoDB 风格的更新操作符应用到 doc，字段支持点号路径。
+// 支持 $set、$unset、$inc、$push、$addToSet（二者支持 {"$each": [...]}）、$pull、$rename；
+// 操作符不能与普通字段混用，不能修改主键，同一路径不能被多个操作修改。出错时 doc 可能已被部分修改。
+func applyUpdateOperators(doc map[string]any, updates map[string]any, isPrimaryKey func(field string) bool) error {
+	fieldsByOp := make(map[string]map[string]any, len(updates))
+	for op, spec := range updates {
+		if !strings.HasPrefix(op, "$") {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("cannot mix update operators with plain field %q", op), nil)
+		}
+		fields, ok := spec.(map[string]any)
+		if !ok {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("%s requires an object of fields", op), nil)
+		}
+		fieldsByOp[op] = fields
+	}
+	for op := range fieldsByOp {
+		if !isUpdateOperator(op) {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("unsupported update operator %s", op), nil)
+		}
+	}
+
+	// 校验路径：不能修改主键，路径之间不能重叠
+	var paths []string
+	for op, fields := range fieldsByOp {
+		for field, value := range fields {
+			targets := []string{field}
+			if op == "$rename" {
+				to, ok := value.(string)
+				if !ok || to == "" {
+					return NewError(ErrorTypeValidation, fmt.Sprintf("$rename target for %s must be a non-empty string", field), nil)
+				}
+				targets = append(targets, to)
+			}
+			for _, path := range targets {
+				if isPrimaryKey(strings.Split(path, ".")[0]) {
+					return NewError(ErrorTypeValidation, fmt.Sprintf("%s cannot modify primary key field %s", op, path), nil)
+				}
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	for i := 1; i < len(paths); i++ {
+		if paths[i] == paths[i-1] || strings.HasPrefix(paths[i], paths[i-1]+".") {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("conflicting update paths %s and %s", paths[i-1], paths[i]), nil)
+		}
+	}
+
+	for _, op := range updateOperatorOrder {
+		fields := fieldsByOp[op]
+		names := make([]string, 0, len(fields))
+		for field := range fields {
+			names = append(names, field)
+		}
+		sort.Strings(names)
+		for _, field := range names {
+			if err := applyUpdateOperator(doc, op, field, fields[field]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isUpdateOperator 报告 op 是否为支持的更新操作符。
+func isUpdateOperator(op string) bool {
+	for _, known := range updateOperatorOrder {
+		if op == known {
+			return true
+		}
+	}
+	return false
+}
+
+// applyUpdateOperator 对单个字段应用更新操作符。
+func applyUpdateOperator(doc map[string]any, op, field string, value any) error {
+	parts := strings.Split(field, ".")
+	parent, err := updateParent(doc, parts, op != "$unset" && op != "$pull" && op != "$rename")
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		// 路径不存在，$unset/$pull/$rename 无需处理
+		return nil
+	}
+	key := parts[len(parts)-1]
+	current, exists := parent[key]
+
+	switch op {
+	case "$set":
+		parent[key] = deepCloneValue(value)
+
+	case "$unset":
+		delete(parent, key)
+
+	case "$inc":
+		if !isNumeric(value) {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("$inc value for %s must be numeric", field), nil)
+		}
+		if !exists || current == nil {
+			current = int64(0)
+		}
+		if !isNumeric(current) {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("$inc cannot be applied to non-numeric field %s", field), nil)
+		}
+		parent[key] = incNumber(current, value)
+
+	case "$push", "$addToSet":
+		var arr []any
+		if exists && current != nil {
+			var ok bool
+			if arr, ok = current.([]any); !ok {
+				return NewError(ErrorTypeValidation, fmt.Sprintf("%s cannot be applied to non-array field %s", op, field), nil)
+			}
+		}
+		items, err := updateEachValues(op, field, value)
+		if err != nil {
+			return err
+		}
+		arr = append([]any(nil), arr...)
+		for _, item := range items {
+			if op == "$addToSet" && containsValue(arr, item) {
+				continue
+			}
+			arr = append(arr, item)
+		}
+		parent[key] = arr
+
+	case "$pull":
+		if !exists || current == nil {
+			return nil
+		}
+		arr, ok := current.([]any)
+		if !ok {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("$pull cannot be applied to non-array field %s", field), nil)
+		}
+		target := deepCloneValue(value)
+		kept := make([]any, 0, len(arr))
+		for _, item := range arr {
+			if !reflect.DeepEqual(item, target) {
+				kept = append(kept, item)
+			}
+		}
+		parent[key] = kept
+
+	case "$rename":
+		if !exists {
+			return nil
+		}
+		toParts := strings.Split(value.(string), ".")
+		toParent, err := updateParent(doc, toParts, true)
+		if err != nil {
+			return err
+		}
+		delete(parent, key)
+		toParent[toParts[len(toParts)-1]] = current
+	}
+	return nil
+}
+
+// updateEachValues 返回 $push/$addToSet 要追加的值，支持 {"$each": [...]}。
+func updateEachValues(op, field string, value any) ([]any, error) {
+	if m, ok := value.(map[string]any); ok {
+		if each, ok := m["$each"]; ok {
+			items, ok := each.([]any)
+			if !ok || len(m) != 1 {
+				return nil, NewError(ErrorTypeValidation, fmt.Sprintf("%s.$each for %s must be the only key and an array", op, field), nil)
+			}
+			out := make([]any, len(items))
+			for i, item := range items {
+				out[i] = deepCloneValue(item)
+			}
+			return out, nil
+		}
+	}
+	return []any{deepCloneValue(value)}, nil
+}
+
+// containsValue 报告数组中是否存在与 value 相等的元素。
+func containsValue(arr []any, value any) bool {
+	for _, item := range arr {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// updateParent 返回路径最后一段所在的 map。create 为 true 时创建缺失的中间对象，
+// 否则路径不存在时返回 nil；中间段不是对象时返回验证错误。
+func updateParent(doc map[string]any, parts []string, create bool) (map[string]any, error) {
+	current := doc
+	for i, part := range parts[:len(parts)-1] {
+		next, ok := current[part]
+		if !ok || next == nil {
+			if !create {
+				return nil, nil
+			}
+			m := make(map[string]any)
+			current[part] = m
+			current = m
+			continue
+		}
+		m, ok := next.(map[string]any)
+		if !ok {
+			return nil, NewError(ErrorTypeValidation, fmt.Sprintf("cannot traverse non-object field %s", strings.Join(parts[:i+1], ".")), nil)
+		}
+		current = m
+	}
+	return current, nil
+}
+
+// incNumber 返回 current + delta。delta 为整数类型且 current 可无损表示为整数（包括从 JSON 读出的整数值 float64）时
+// 按 int64 精确计算，结果为 int64；否则（含小数或溢出）按 float64 计算。
+func incNumber(current, delta any) any {
+	switch delta.(type) {
+	case float32, float64:
+	default:
+		c, cok := exactInt64(current)
+		d, dok := exactInt64(delta)
+		if cok && dok && !((d > 0 && c > math.MaxInt64-d) || (d < 0 && c < math.MinInt64-d)) {
+			return c + d
+		}
+	}
+	return numberToFloat64(current) + numberToFloat64(delta)
+}
@@ -60,6 +60,8 @@ func releaseDocument(d *document) {
 	d.collection = nil
 	d.revField = ""
 	d.changes = nil
+	d.detached = false
+	d.pending = nil
 	documentPool.Put(d)
 }
 
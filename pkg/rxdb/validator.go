@@ -1,12 +1,27 @@
 package rxdb
 
 import (
+	"context"
 	"fmt"
-	"reflect"
-	"regexp"
+	"math"
+	"strconv"
 	"strings"
 )
 
+// Validator 自定义文档验证器，在 JSON Schema 验证之后执行。
+// 可用于 Schema 无法表达的规则，例如检查引用的文档是否存在于其他集合中。
+type Validator interface {
+	Validate(ctx context.Context, doc map[string]any) error
+}
+
+// ValidatorFunc 允许将普通函数用作 Validator。
+type ValidatorFunc func(ctx context.Context, doc map[string]any) error
+
+// Validate 实现 Validator 接口。
+func (f ValidatorFunc) Validate(ctx context.Context, doc map[string]any) error {
+	return f(ctx, doc)
+}
+
 // getPrimaryKeyFields 获取主键字段列表（支持单个和复合主键）。
 func getPrimaryKeyFields(schema Schema) []string {
 	switch pk := schema.PrimaryKey.(type) {
@@ -31,558 +46,321 @@ func getPrimaryKeyFields(schema Schema) []string {
 	}
 }
 
-// ValidateDocument 根据 Schema 验证文档。
+// ValidateDocument 根据 Schema 验证文档：检查主键字段是否存在，并按 Schema.JSON（JSON Schema Draft 7）
+// 验证文档。存在违反的约束时返回 ValidationErrors，包含全部失败的约束而不仅是第一个。
+// 该函数总是严格验证，不受 Schema.Options.Validation 影响。
 func ValidateDocument(schema Schema, doc map[string]any) error {
-	if schema.JSON == nil {
-		// 如果没有 JSON Schema，只验证主键存在
-		fields := getPrimaryKeyFields(schema)
-		for _, field := range fields {
-			if _, ok := doc[field]; !ok {
-				return fmt.Errorf("missing required field: %s", field)
-			}
-		}
-		return nil
+	if errs := ValidateDocumentWithPath(schema, doc); len(errs) > 0 {
+		return ValidationErrors(errs)
 	}
-
-	// 验证 required 字段
-	if required, ok := schema.JSON["required"].([]any); ok {
-		for _, req := range required {
-			if field, ok := req.(string); ok {
-				if _, exists := doc[field]; !exists {
-					return fmt.Errorf("missing required field: %s", field)
-				}
-			}
-		}
-	}
-
-	// 验证 properties
-	if properties, ok := schema.JSON["properties"].(map[string]any); ok {
-		for field, propDef := range properties {
-			if propMap, ok := propDef.(map[string]any); ok {
-				if err := validateField(doc[field], propMap, field); err != nil {
-					return err
-				}
-			}
-		}
-	}
-
 	return nil
 }
 
-// validateField 验证单个字段。
-func validateField(value any, propDef map[string]any, fieldName string) error {
-	// 如果值为 nil，检查是否允许 null
-	if value == nil {
-		if typeVal, ok := propDef["type"].(string); ok {
-			if typeVal == "null" {
-				return nil
-			}
-			// 检查是否允许 null（通过 type 数组）
-			if types, ok := propDef["type"].([]any); ok {
-				for _, t := range types {
-					if tStr, ok := t.(string); ok && tStr == "null" {
-						return nil
-					}
-				}
-			}
-		}
-		// 如果字段不存在且不是 required，则跳过验证
-		return nil
-	}
+// ValidateDocumentWithPath 验证文档并返回带路径的全部验证错误，验证规则与 ValidateDocument 相同。
+// 主键与修订号字段不参与 JSON Schema 验证（包括 properties 中为它们声明的约束），缺少主键时单独报错。
+func ValidateDocumentWithPath(schema Schema, doc map[string]any) []ValidationError {
+	var errors []ValidationError
 
-	// 获取类型定义
-	typeVal, ok := propDef["type"].(string)
-	if !ok {
-		// 可能是类型数组（联合类型），尝试匹配任意一个类型
-		if types, ok := propDef["type"].([]any); ok {
-			// 对于联合类型，尝试每个类型，只要有一个匹配就通过
-			for _, t := range types {
-				if tStr, ok := t.(string); ok {
-					if tStr == "null" {
-						// value不可能是nil，因为validateField已经在前面处理了nil的情况
-						continue
-					}
-					// 创建单类型属性定义来验证
-					singleTypeDef := make(map[string]any)
-					for k, v := range propDef {
-						singleTypeDef[k] = v
-					}
-					singleTypeDef["type"] = tStr
-					if validateSingleType(value, singleTypeDef, fieldName) == nil {
-						return nil // 至少有一个类型匹配
-					}
-				}
-			}
-			// 没有任何类型匹配
-			return fmt.Errorf("field %s: expected %v, got %T", fieldName, propDef["type"], value)
+	for _, field := range getPrimaryKeyFields(schema) {
+		if _, ok := doc[field]; !ok {
+			errors = append(errors, ValidationError{
+				Path:    field,
+				Keyword: "required",
+				Message: fmt.Sprintf("missing required field: %s", field),
+			})
 		}
 	}
-
-	if typeVal == "" {
-		return nil // 没有类型定义，跳过验证
+	if schema.JSON == nil {
+		return errors
 	}
+	return append(errors, validateJSONSchema(schema, doc)...)
+}
 
-	// 类型验证
-	switch typeVal {
-	case "string":
-		if _, ok := value.(string); !ok {
-			return fmt.Errorf("field %s: expected string, got %T", fieldName, value)
-		}
-		strVal := value.(string)
-		// 验证 maxLength
-		if maxLen, ok := propDef["maxLength"].(float64); ok {
-			if len(strVal) > int(maxLen) {
-				return fmt.Errorf("field %s: string length %d exceeds maxLength %d", fieldName, len(strVal), int(maxLen))
-			}
-		}
-		// 验证 minLength
-		if minLen, ok := propDef["minLength"].(float64); ok {
-			if len(strVal) < int(minLen) {
-				return fmt.Errorf("field %s: string length %d is less than minLength %d", fieldName, len(strVal), int(minLen))
-			}
-		}
-		// 验证 pattern (正则表达式)
-		if pattern, ok := propDef["pattern"].(string); ok {
-			re, err := regexp.Compile(pattern)
-			if err != nil {
-				// 如果正则表达式本身无效，返回错误
-				return fmt.Errorf("field %s: invalid pattern '%s': %v", fieldName, pattern, err)
-			}
-			if !re.MatchString(strVal) {
-				return fmt.Errorf("field %s: value '%s' does not match pattern '%s'", fieldName, strVal, pattern)
-			}
-		}
-
-	case "number", "integer":
-		var numVal float64
-		switch v := value.(type) {
-		case int:
-			numVal = float64(v)
-		case int64:
-			numVal = float64(v)
-		case float64:
-			numVal = v
-		case float32:
-			numVal = float64(v)
-		default:
-			return fmt.Errorf("field %s: expected number, got %T", fieldName, value)
-		}
-		// 验证 maximum
-		if max, ok := propDef["maximum"].(float64); ok {
-			if numVal > max {
-				return fmt.Errorf("field %s: value %f exceeds maximum %f", fieldName, numVal, max)
-			}
-		}
-		// 验证 minimum
-		if min, ok := propDef["minimum"].(float64); ok {
-			if numVal < min {
-				return fmt.Errorf("field %s: value %f is less than minimum %f", fieldName, numVal, min)
-			}
-		}
-		// integer 类型额外验证
-		if typeVal == "integer" {
-			if numVal != float64(int64(numVal)) {
-				return fmt.Errorf("field %s: expected integer, got float", fieldName)
-			}
-		}
-
-	case "boolean":
-		if _, ok := value.(bool); !ok {
-			return fmt.Errorf("field %s: expected boolean, got %T", fieldName, value)
-		}
-
-	case "array":
-		// 检查是否为数组/切片类型
-		rv := reflect.ValueOf(value)
-		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
-			return fmt.Errorf("field %s: expected array, got %T", fieldName, value)
-		}
-
-		// 将任意类型的切片转换为 []any
-		var arrVal []any
-		if arr, ok := value.([]any); ok {
-			arrVal = arr
-		} else {
-			// 转换其他类型的切片为 []any
-			arrVal = make([]any, rv.Len())
-			for i := 0; i < rv.Len(); i++ {
-				arrVal[i] = rv.Index(i).Interface()
-			}
-		}
+// ValidationError 表示验证错误。
+type ValidationError struct {
+	Path    string // 出错的字段路径（如 "address.city"、"tags[0]"），根对象为空
+	Keyword string // 失败的 JSON Schema 关键字（如 required、type、pattern）
+	Message string
+}
 
-		// 验证 items schema
-		if items, ok := propDef["items"].(map[string]any); ok {
-			for i, item := range arrVal {
-				if err := validateField(item, items, fmt.Sprintf("%s[%d]", fieldName, i)); err != nil {
-					return err
-				}
-			}
-		}
-		// 验证 minItems
-		if minItems, ok := propDef["minItems"].(float64); ok {
-			if len(arrVal) < int(minItems) {
-				return fmt.Errorf("field %s: array length %d is less than minItems %d", fieldName, len(arrVal), int(minItems))
-			}
-		}
-		// 验证 maxItems
-		if maxItems, ok := propDef["maxItems"].(float64); ok {
-			if len(arrVal) > int(maxItems) {
-				return fmt.Errorf("field %s: array length %d exceeds maxItems %d", fieldName, len(arrVal), int(maxItems))
-			}
-		}
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
 
-	case "object":
-		if _, ok := value.(map[string]any); !ok {
-			return fmt.Errorf("field %s: expected object, got %T", fieldName, value)
-		}
-		// 验证 properties
-		if properties, ok := propDef["properties"].(map[string]any); ok {
-			objVal := value.(map[string]any)
-			for propField, propDef := range properties {
-				if propMap, ok := propDef.(map[string]any); ok {
-					if err := validateField(objVal[propField], propMap, fmt.Sprintf("%s.%s", fieldName, propField)); err != nil {
-						return err
-					}
-				}
-			}
+// validateDocument 按 Schema.Options.Validation 模式验证写入的文档：strict 返回验证错误，
+// warn 记录警告后放行，off 跳过 JSON Schema 验证。缺少主键字段时总是返回错误。
+// 启用 Schema.CoerceTypes 时先就地转换字段类型，所有写路径都经过这里，验证的是转换后的文档。
+func (c *collection) validateDocument(doc map[string]any) error {
+	CoerceDocumentTypes(c.schema, doc)
+	if err := c.validateVectorFields(doc); err != nil {
+		return err
+	}
+	var errs []ValidationError
+	switch c.schema.Options.Validation {
+	case SchemaValidationOff:
+		errs = ValidateDocumentWithPath(Schema{PrimaryKey: c.schema.PrimaryKey}, doc)
+	case SchemaValidationWarn:
+		errs = ValidateDocumentWithPath(c.schema, doc)
+		if len(errs) > 0 && !hasMissingPrimaryKey(c.schema, doc) {
+			c.logger.Warn("Document does not match schema", "collection", c.name, "errors", ValidationErrors(errs).Error())
+			return nil
 		}
-
-	case "null":
-		// value不可能是nil，因为validateField已经在第75-91行处理了nil的情况
-		// 如果执行到这里，说明value不是nil，但type是"null"，这是错误的
-		return fmt.Errorf("field %s: expected null, got %T", fieldName, value)
+	default:
+		errs = ValidateDocumentWithPath(c.schema, doc)
+	}
+	if len(errs) > 0 {
+		return ValidationErrors(errs)
 	}
-
 	return nil
 }
 
-// validateSingleType 验证值是否匹配单一类型
-func validateSingleType(value any, propDef map[string]any, fieldName string) error {
-	// 如果值为nil，只允许type为"null"
-	if value == nil {
-		typeVal, ok := propDef["type"].(string)
-		if ok && typeVal == "null" {
-			return nil
+// hasMissingPrimaryKey 判断文档是否缺少主键字段。
+func hasMissingPrimaryKey(schema Schema, doc map[string]any) bool {
+	for _, field := range getPrimaryKeyFields(schema) {
+		if _, ok := doc[field]; !ok {
+			return true
 		}
-		return fmt.Errorf("expected non-null value for type %v", propDef["type"])
 	}
+	return false
+}
 
-	typeVal, ok := propDef["type"].(string)
-	if !ok {
-		return nil
+// ApplyDefaults 根据 Schema 为缺失的字段填充默认值，已有字段（包括值为 null 的字段）不会被覆盖。
+//
+// 先按 Schema.DefaultGenerators 生成计算型默认值，再应用 Schema.JSON 中声明的 "default"：
+// 嵌套对象按 properties 递归应用（父对象缺失时只有父对象本身声明了 default 才会创建），
+// 数组元素按 items 中的对象 schema 应用。默认值会被深拷贝，不同文档之间互不影响。
+// 修订号字段由数据库维护，不会被任何默认值覆盖。
+func ApplyDefaults(schema Schema, doc map[string]any) {
+	if doc == nil {
+		return
+	}
+	applyDefaultGenerators(schema, doc)
+	if schema.JSON != nil {
+		applySchemaDefaults(schema.JSON, doc, schema.RevField)
 	}
+}
 
-	switch typeVal {
-	case "string":
-		if _, ok := value.(string); !ok {
-			return fmt.Errorf("expected string, got %T", value)
-		}
-	case "number":
-		switch value.(type) {
-		case int, int64, float64, float32:
-			// OK
-		default:
-			return fmt.Errorf("expected number, got %T", value)
+// applyDefaultGenerators 为缺失的字段调用 Schema.DefaultGenerators 中的生成函数。
+// 键为字段路径（嵌套字段使用点号，如 "meta.createdAt"），中间对象缺失时自动创建；
+// 路径上存在非对象值时跳过该生成函数。
+func applyDefaultGenerators(schema Schema, doc map[string]any) {
+	for path, generate := range schema.DefaultGenerators {
+		if generate == nil || path == "" || path == schema.RevField {
+			continue
 		}
-	case "integer":
-		switch v := value.(type) {
-		case int, int64:
-			// OK
-		case float64:
-			if v != float64(int64(v)) {
-				return fmt.Errorf("expected integer, got float")
+		parts := strings.Split(path, ".")
+		current := doc
+		for _, part := range parts[:len(parts)-1] {
+			next, exists := current[part]
+			if !exists {
+				created := make(map[string]any)
+				current[part] = created
+				current = created
+				continue
 			}
-		default:
-			return fmt.Errorf("expected integer, got %T", value)
-		}
-	case "boolean":
-		if _, ok := value.(bool); !ok {
-			return fmt.Errorf("expected boolean, got %T", value)
-		}
-	case "array":
-		if _, ok := value.([]any); !ok {
-			rv := reflect.ValueOf(value)
-			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
-				return fmt.Errorf("expected array, got %T", value)
+			nextMap, ok := next.(map[string]any)
+			if !ok {
+				current = nil
+				break
 			}
+			current = nextMap
+		}
+		if current == nil {
+			continue
 		}
-	case "object":
-		if _, ok := value.(map[string]any); !ok {
-			return fmt.Errorf("expected object, got %T", value)
+		last := parts[len(parts)-1]
+		if _, exists := current[last]; !exists {
+			current[last] = generate()
 		}
-	case "null":
-		// value已经在函数开头检查过了，如果type是"null"而value不是nil，这里会执行
-		// 但由于函数开头已经处理了nil的情况，这里实际上不会被执行到
-		return fmt.Errorf("expected null, got %T", value)
 	}
-
-	return nil
 }
 
-// ValidateDocumentWithPath 验证文档并返回详细的错误路径。
-func ValidateDocumentWithPath(schema Schema, doc map[string]any) []ValidationError {
-	var errors []ValidationError
+// applySchemaDefaults 按对象 schema 的 properties 递归填充默认值。skipField 为需要跳过的顶层字段（修订号）。
+func applySchemaDefaults(schema map[string]any, doc map[string]any, skipField string) {
+	properties, ok := schema["properties"].(map[string]any)
+	if !ok {
+		return
+	}
 
-	if schema.JSON == nil {
-		fields := getPrimaryKeyFields(schema)
-		for _, field := range fields {
-			if _, ok := doc[field]; !ok {
-				errors = append(errors, ValidationError{
-					Path:    field,
-					Message: fmt.Sprintf("missing required field: %s", field),
-				})
-			}
+	for field, propDef := range properties {
+		propMap, ok := propDef.(map[string]any)
+		if !ok || (skipField != "" && field == skipField) {
+			continue
 		}
-		return errors
-	}
 
-	// 验证 required 字段
-	if required, ok := schema.JSON["required"].([]any); ok {
-		for _, req := range required {
-			if field, ok := req.(string); ok {
-				if _, exists := doc[field]; !exists {
-					errors = append(errors, ValidationError{
-						Path:    field,
-						Message: fmt.Sprintf("missing required field: %s", field),
-					})
-				}
+		// 如果字段不存在，应用默认值
+		if _, exists := doc[field]; !exists {
+			if defaultValue, hasDefault := propMap["default"]; hasDefault {
+				doc[field] = cloneDefaultValue(defaultValue)
 			}
 		}
-	}
 
-	// 验证 properties
-	if properties, ok := schema.JSON["properties"].(map[string]any); ok {
-		for field, propDef := range properties {
-			if propMap, ok := propDef.(map[string]any); ok {
-				fieldErrors := validateFieldWithPath(doc[field], propMap, field)
-				errors = append(errors, fieldErrors...)
+		switch value := doc[field].(type) {
+		case map[string]any:
+			applySchemaDefaults(propMap, value, "")
+		case []any:
+			if items, ok := propMap["items"].(map[string]any); ok {
+				for _, item := range value {
+					if obj, ok := item.(map[string]any); ok {
+						applySchemaDefaults(items, obj, "")
+					}
+				}
 			}
 		}
 	}
-
-	return errors
 }
 
-// ValidationError 表示验证错误。
-type ValidationError struct {
-	Path    string
-	Message string
+// cloneDefaultValue 深拷贝对象与数组类型的默认值，标量原样返回（保留数值的原始类型）。
+func cloneDefaultValue(v any) any {
+	switch v.(type) {
+	case map[string]any, []any, []map[string]any:
+		return deepCloneValue(v)
+	}
+	return v
 }
 
-func (e ValidationError) Error() string {
-	return fmt.Sprintf("%s: %s", e.Path, e.Message)
-}
+// CoerceDocumentTypes 在启用 Schema.CoerceTypes 时，按 properties 中声明的类型转换字段值。
+// 无法安全转换的值保持不变，交由后续验证报错。只处理顶层字段，嵌套对象中的字段不转换。
+// 查询时数值比较按 float64 进行，int 与 float64 之间无需转换即可匹配。
+func CoerceDocumentTypes(schema Schema, doc map[string]any) {
+	if !schema.CoerceTypes || schema.JSON == nil {
+		return
+	}
 
-// validateFieldWithPath 验证字段并返回带路径的错误列表。
-func validateFieldWithPath(value any, propDef map[string]any, fieldName string) []ValidationError {
-	var errors []ValidationError
+	properties, ok := schema.JSON["properties"].(map[string]any)
+	if !ok {
+		return
+	}
 
-	if value == nil {
-		if typeVal, ok := propDef["type"].(string); ok {
-			if typeVal == "null" {
-				return nil
-			}
+	for field, value := range doc {
+		if typeVal := schemaPropertyType(properties, field); typeVal != "" {
+			doc[field] = coerceValue(value, typeVal)
 		}
-		return nil
 	}
+}
 
-	typeVal, ok := propDef["type"].(string)
+// schemaPropertyType 返回顶层字段声明的单一类型，未声明时返回空字符串。
+func schemaPropertyType(properties map[string]any, field string) string {
+	propMap, ok := properties[field].(map[string]any)
 	if !ok {
-		if types, ok := propDef["type"].([]any); ok {
-			for _, t := range types {
-				if tStr, ok := t.(string); ok && tStr != "null" {
-					typeVal = tStr
-					break
-				}
-			}
-		}
+		return ""
 	}
+	typeVal, _ := propMap["type"].(string)
+	return typeVal
+}
 
-	if typeVal == "" {
+// coerceValue 将值转换为指定的 JSON Schema 类型。
+func coerceValue(value any, typeVal string) any {
+	if value == nil {
 		return nil
 	}
 
 	switch typeVal {
-	case "string":
-		if _, ok := value.(string); !ok {
-			errors = append(errors, ValidationError{
-				Path:    fieldName,
-				Message: fmt.Sprintf("expected string, got %T", value),
-			})
-			return errors
-		}
-		strVal := value.(string)
-		if maxLen, ok := propDef["maxLength"].(float64); ok {
-			if len(strVal) > int(maxLen) {
-				errors = append(errors, ValidationError{
-					Path:    fieldName,
-					Message: fmt.Sprintf("string length %d exceeds maxLength %d", len(strVal), int(maxLen)),
-				})
+	case "integer":
+		switch v := value.(type) {
+		case string:
+			if i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+				return int(i)
 			}
-		}
-		if minLen, ok := propDef["minLength"].(float64); ok {
-			if len(strVal) < int(minLen) {
-				errors = append(errors, ValidationError{
-					Path:    fieldName,
-					Message: fmt.Sprintf("string length %d is less than minLength %d", len(strVal), int(minLen)),
-				})
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && f == math.Trunc(f) {
+				return int(f)
 			}
-		}
-		// 验证 pattern (正则表达式)
-		if pattern, ok := propDef["pattern"].(string); ok {
-			re, err := regexp.Compile(pattern)
-			if err != nil {
-				// 如果正则表达式本身无效，返回错误
-				errors = append(errors, ValidationError{
-					Path:    fieldName,
-					Message: fmt.Sprintf("invalid pattern '%s': %v", pattern, err),
-				})
-			} else if !re.MatchString(strVal) {
-				errors = append(errors, ValidationError{
-					Path:    fieldName,
-					Message: fmt.Sprintf("value '%s' does not match pattern '%s'", strVal, pattern),
-				})
+		default:
+			if isNumeric(v) {
+				if f := toFloat64(v); f == math.Trunc(f) {
+					return int(f)
+				}
 			}
 		}
-
-	case "number", "integer":
-		var numVal float64
+	case "number":
 		switch v := value.(type) {
-		case int:
-			numVal = float64(v)
-		case int64:
-			numVal = float64(v)
-		case float64:
-			numVal = v
-		case float32:
-			numVal = float64(v)
-		default:
-			errors = append(errors, ValidationError{
-				Path:    fieldName,
-				Message: fmt.Sprintf("expected number, got %T", value),
-			})
-			return errors
-		}
-		if max, ok := propDef["maximum"].(float64); ok {
-			if numVal > max {
-				errors = append(errors, ValidationError{
-					Path:    fieldName,
-					Message: fmt.Sprintf("value %f exceeds maximum %f", numVal, max),
-				})
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return f
 			}
-		}
-		if min, ok := propDef["minimum"].(float64); ok {
-			if numVal < min {
-				errors = append(errors, ValidationError{
-					Path:    fieldName,
-					Message: fmt.Sprintf("value %f is less than minimum %f", numVal, min),
-				})
+		default:
+			if isNumeric(v) {
+				return toFloat64(v)
 			}
 		}
-		if typeVal == "integer" {
-			if numVal != float64(int64(numVal)) {
-				errors = append(errors, ValidationError{
-					Path:    fieldName,
-					Message: "expected integer, got float",
-				})
+	case "string":
+		switch v := value.(type) {
+		case bool:
+			return strconv.FormatBool(v)
+		default:
+			if isNumeric(v) {
+				return strconv.FormatFloat(toFloat64(v), 'f', -1, 64)
 			}
 		}
-
 	case "boolean":
-		if _, ok := value.(bool); !ok {
-			errors = append(errors, ValidationError{
-				Path:    fieldName,
-				Message: fmt.Sprintf("expected boolean, got %T", value),
-			})
-		}
-
-	case "array":
-		arrVal, ok := value.([]any)
-		if !ok {
-			rv := reflect.ValueOf(value)
-			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
-				errors = append(errors, ValidationError{
-					Path:    fieldName,
-					Message: fmt.Sprintf("expected array, got %T", value),
-				})
-				return errors
-			}
-			// 转换其他数组类型
-			arrVal = make([]any, rv.Len())
-			for i := 0; i < rv.Len(); i++ {
-				arrVal[i] = rv.Index(i).Interface()
-			}
-		}
-		if items, ok := propDef["items"].(map[string]any); ok {
-			for i, item := range arrVal {
-				itemErrors := validateFieldWithPath(item, items, fmt.Sprintf("%s[%d]", fieldName, i))
-				errors = append(errors, itemErrors...)
-			}
-		}
-		if minItems, ok := propDef["minItems"].(float64); ok {
-			if len(arrVal) < int(minItems) {
-				errors = append(errors, ValidationError{
-					Path:    fieldName,
-					Message: fmt.Sprintf("array length %d is less than minItems %d", len(arrVal), int(minItems)),
-				})
-			}
-		}
-		if maxItems, ok := propDef["maxItems"].(float64); ok {
-			if len(arrVal) > int(maxItems) {
-				errors = append(errors, ValidationError{
-					Path:    fieldName,
-					Message: fmt.Sprintf("array length %d exceeds maxItems %d", len(arrVal), int(maxItems)),
-				})
+		if v, ok := value.(string); ok {
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b
 			}
 		}
+	}
+	return value
+}
 
-	case "object":
-		objVal, ok := value.(map[string]any)
-		if !ok {
-			errors = append(errors, ValidationError{
-				Path:    fieldName,
-				Message: fmt.Sprintf("expected object, got %T", value),
-			})
-			return errors
-		}
-		if properties, ok := propDef["properties"].(map[string]any); ok {
-			for propField, propDef := range properties {
-				if propMap, ok := propDef.(map[string]any); ok {
-					propPath := strings.Join([]string{fieldName, propField}, ".")
-					propErrors := validateFieldWithPath(objVal[propField], propMap, propPath)
-					errors = append(errors, propErrors...)
+// coerceSelector 按 Schema 声明的字段类型转换查询选择器中的比较值，返回新的选择器。
+func coerceSelector(properties map[string]any, selector map[string]any) map[string]any {
+	result := make(map[string]any, len(selector))
+	for key, value := range selector {
+		switch key {
+		case "$and", "$or", "$nor":
+			if arr, ok := value.([]any); ok {
+				items := make([]any, len(arr))
+				for i, item := range arr {
+					if m, ok := item.(map[string]any); ok {
+						items[i] = coerceSelector(properties, m)
+					} else {
+						items[i] = item
+					}
 				}
+				result[key] = items
+				continue
 			}
+			result[key] = value
+			continue
 		}
-	}
-
-	return errors
-}
-
-// ApplyDefaults 根据 Schema 应用字段默认值。
-func ApplyDefaults(schema Schema, doc map[string]any) {
-	if schema.JSON == nil {
-		return
-	}
 
-	properties, ok := schema.JSON["properties"].(map[string]any)
-	if !ok {
-		return
-	}
+		typeVal := schemaPropertyType(properties, key)
+		if typeVal == "" {
+			result[key] = value
+			continue
+		}
 
-	for field, propDef := range properties {
-		propMap, ok := propDef.(map[string]any)
+		ops, ok := value.(map[string]any)
 		if !ok {
+			result[key] = coerceValue(value, typeVal)
 			continue
 		}
 
-		// 如果字段不存在，应用默认值
-		if _, exists := doc[field]; !exists {
-			if defaultValue, hasDefault := propMap["default"]; hasDefault {
-				doc[field] = defaultValue
+		coercedOps := make(map[string]any, len(ops))
+		for op, opValue := range ops {
+			switch op {
+			case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte":
+				coercedOps[op] = coerceValue(opValue, typeVal)
+			case "$in", "$nin":
+				if arr, ok := opValue.([]any); ok {
+					items := make([]any, len(arr))
+					for i, item := range arr {
+						items[i] = coerceValue(item, typeVal)
+					}
+					coercedOps[op] = items
+				} else {
+					coercedOps[op] = opValue
+				}
+			default:
+				coercedOps[op] = opValue
 			}
 		}
+		result[key] = coercedOps
 	}
+	return result
 }
 
 // ValidateFinalFields 验证不可变字段（final fields）是否被修改。
@@ -1,6 +1,10 @@
 package rxdb
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -750,7 +754,7 @@ func TestValidator_ValidationError(t *testing.T) {
 
 	// 测试多个验证错误
 	doc := map[string]any{
-		"id":   "ab",               // 太短
+		"id":   "ab",               // 主键不受 minLength 约束
 		"name": "This is too long", // 太长
 		"age":  150,                // 超过最大值
 	}
@@ -786,8 +790,9 @@ func TestValidator_ValidationError(t *testing.T) {
 		}
 	}
 
-	if !foundIDError {
-		t.Error("Should have error for 'id' field")
+	// 主键由主键检查负责，properties 中为其声明的约束不参与验证
+	if foundIDError {
+		t.Error("Should not validate constraints on primary key 'id'")
 	}
 	if !foundNameError {
 		t.Error("Should have error for 'name' field")
@@ -1050,9 +1055,294 @@ func TestValidator_ApplyDefaults_Nested(t *testing.T) {
 	}
 
 	// retries 应该应用默认值
-	// 注意：当前实现可能不支持嵌套默认值，这里测试基本功能
-	if config["retries"] == nil {
-		// 如果嵌套默认值未实现，至少验证不会 panic
-		t.Log("Nested defaults may not be fully implemented")
+	if config["retries"] != float64(3) {
+		t.Errorf("Expected nested default retries 3, got %v", config["retries"])
+	}
+
+	// 父对象缺失且未声明 default 时不会被创建
+	doc = map[string]any{"id": "doc2"}
+	ApplyDefaults(schema, doc)
+	if _, ok := doc["config"]; ok {
+		t.Errorf("Expected config to stay absent, got %v", doc["config"])
+	}
+}
+
+func TestValidator_ApplyDefaults_DeepAndGenerated(t *testing.T) {
+	calls := 0
+	schema := Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		JSON: map[string]any{
+			"properties": map[string]any{
+				"id":   map[string]any{"type": "string"},
+				"_rev": map[string]any{"type": "string", "default": "1-user"},
+				"settings": map[string]any{
+					"type":    "object",
+					"default": map[string]any{},
+					"properties": map[string]any{
+						"theme": map[string]any{"type": "string", "default": "light"},
+						"tags":  map[string]any{"type": "array", "default": []any{"new"}},
+					},
+				},
+				"items": map[string]any{
+					"type": "array",
+					"items": map[string]any{
+						"type":       "object",
+						"properties": map[string]any{"qty": map[string]any{"type": "integer", "default": 1}},
+					},
+				},
+				"createdAt": map[string]any{"type": "string", "default": "static"},
+			},
+		},
+		DefaultGenerators: map[string]func() any{
+			"createdAt":    func() any { calls++; return "generated" },
+			"meta.version": func() any { return 1 },
+			"_rev":         func() any { return "1-generated" },
+		},
+	}
+
+	doc := map[string]any{"id": "a", "items": []any{map[string]any{}, map[string]any{"qty": 5}}}
+	ApplyDefaults(schema, doc)
+
+	settings, _ := doc["settings"].(map[string]any)
+	if settings["theme"] != "light" || !reflect.DeepEqual(settings["tags"], []any{"new"}) {
+		t.Errorf("Expected defaults inside created settings object, got %v", doc["settings"])
+	}
+	items := doc["items"].([]any)
+	if items[0].(map[string]any)["qty"] != 1 || items[1].(map[string]any)["qty"] != 5 {
+		t.Errorf("Unexpected array item defaults: %v", items)
+	}
+	if doc["createdAt"] != "generated" || calls != 1 {
+		t.Errorf("Expected generator to take precedence, got %v (%d calls)", doc["createdAt"], calls)
+	}
+	if meta, _ := doc["meta"].(map[string]any); meta["version"] != 1 {
+		t.Errorf("Expected nested generated default, got %v", doc["meta"])
+	}
+	if _, ok := doc["_rev"]; ok {
+		t.Errorf("_rev must not be set by defaults, got %v", doc["_rev"])
+	}
+
+	// 默认值是深拷贝，修改一个文档不影响其他文档
+	settings["tags"].([]any)[0] = "changed"
+	other := map[string]any{"id": "b", "createdAt": "given"}
+	ApplyDefaults(schema, other)
+	if tags := other["settings"].(map[string]any)["tags"].([]any); tags[0] != "new" {
+		t.Errorf("Default value was shared between documents: %v", tags)
+	}
+	if other["createdAt"] != "given" || calls != 1 {
+		t.Errorf("Existing field must not be overwritten, got %v", other["createdAt"])
+	}
+}
+
+func TestValidator_CollectionDefaults(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "defaults", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		JSON: map[string]any{
+			"properties": map[string]any{
+				"id":     map[string]any{"type": "string"},
+				"_rev":   map[string]any{"type": "string", "default": "1-user"},
+				"status": map[string]any{"type": "string", "default": "active"},
+				"profile": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"locale": map[string]any{"type": "string", "default": "en"},
+					},
+				},
+			},
+		},
+		DefaultGenerators: map[string]func() any{
+			"createdAt": func() any { return "2026-01-01" },
+			"_rev":      func() any { return "1-generated" },
+		},
+	})
+
+	doc, err := collection.Insert(ctx, map[string]any{"id": "a", "profile": map[string]any{}})
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if doc.GetString("status") != "active" || doc.GetString("createdAt") != "2026-01-01" {
+		t.Errorf("Expected defaults on insert, got %v", doc.Data())
+	}
+	if locale, _ := doc.GetNestedString("profile.locale"); locale != "en" {
+		t.Errorf("Expected nested default, got %v", doc.Data())
+	}
+	rev := doc.GetString("_rev")
+	if rev == "1-user" || rev == "1-generated" || !strings.HasPrefix(rev, "1-") {
+		t.Errorf("_rev must be generated by the database, got %q", rev)
+	}
+
+	// Upsert 插入时填充缺失字段，不覆盖调用方提供的字段
+	doc, err = collection.Upsert(ctx, map[string]any{"id": "b", "status": "disabled"})
+	if err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if doc.GetString("status") != "disabled" || doc.GetString("createdAt") != "2026-01-01" {
+		t.Errorf("Unexpected upserted document: %v", doc.Data())
+	}
+	// 替换已有文档时不会用默认值覆盖字段
+	doc, err = collection.Upsert(ctx, map[string]any{"id": "b", "status": "archived", "createdAt": "2020-01-01"})
+	if err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if doc.GetString("status") != "archived" || doc.GetString("createdAt") != "2020-01-01" {
+		t.Errorf("Upsert overwrote existing fields with defaults: %v", doc.Data())
+	}
+	if rev := doc.GetString("_rev"); !strings.HasPrefix(rev, "2-") {
+		t.Errorf("Expected second revision, got %q", rev)
+	}
+}
+
+func TestValidator_UpsertRequiredDefault(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	collection := newTestCollection(t, db, "upsert_defaults", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		JSON: map[string]any{
+			"type":     "object",
+			"required": []any{"id", "status"},
+			"properties": map[string]any{
+				"id":     map[string]any{"type": "string"},
+				"status": map[string]any{"type": "string", "default": "active"},
+			},
+		},
+	})
+
+	// 与 Insert 一致，新文档先应用默认值再验证
+	doc, err := collection.Upsert(ctx, map[string]any{"id": "b"})
+	if err != nil {
+		t.Fatalf("Upsert of new document relying on a default failed: %v", err)
+	}
+	if doc.GetString("status") != "active" {
+		t.Errorf("Expected default status, got %v", doc.Data())
+	}
+
+	// 替换已有文档时不应用默认值，缺少必需字段仍然报错
+	if _, err := collection.Upsert(ctx, map[string]any{"id": "b"}); !IsValidationError(err) {
+		t.Errorf("Expected validation error when replacing without required field, got %v", err)
+	}
+}
+
+// RequiredFieldValidator 要求文档包含指定的非空字段
+type RequiredFieldValidator struct {
+	Fields []string
+}
+
+func (v RequiredFieldValidator) Validate(ctx context.Context, doc map[string]any) error {
+	for _, field := range v.Fields {
+		if val, ok := doc[field]; !ok || val == nil || val == "" {
+			return fmt.Errorf("field %s is required", field)
+		}
+	}
+	return nil
+}
+
+// ForeignKeyValidator 要求 Field 引用的 ID 存在于 Ref 集合中
+type ForeignKeyValidator struct {
+	Field string
+	Ref   Collection
+}
+
+func (v ForeignKeyValidator) Validate(ctx context.Context, doc map[string]any) error {
+	refID, _ := doc[v.Field].(string)
+	if refID == "" {
+		return nil
+	}
+	if _, err := v.Ref.FindByID(ctx, refID); err != nil {
+		if IsNotFoundError(err) {
+			return fmt.Errorf("%s references missing %s document %q", v.Field, v.Ref.Name(), refID)
+		}
+		return err
+	}
+	return nil
+}
+
+func TestValidator_CollectionValidators(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "../../data/test_validator_collection.db"
+	defer os.RemoveAll(dbPath)
+
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "testdb",
+		Path: dbPath,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	users, err := db.Collection(ctx, "users", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("Failed to create users collection: %v", err)
+	}
+	posts, err := db.Collection(ctx, "posts", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("Failed to create posts collection: %v", err)
+	}
+
+	if _, err := users.Insert(ctx, map[string]any{"id": "alice", "name": "Alice"}); err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+
+	// 记录验证器调用顺序
+	var calls []string
+	posts.AddValidator(ValidatorFunc(func(ctx context.Context, doc map[string]any) error {
+		calls = append(calls, "required")
+		return RequiredFieldValidator{Fields: []string{"title", "author"}}.Validate(ctx, doc)
+	}))
+	posts.AddValidator(ValidatorFunc(func(ctx context.Context, doc map[string]any) error {
+		calls = append(calls, "foreign_key")
+		return ForeignKeyValidator{Field: "author", Ref: users}.Validate(ctx, doc)
+	}))
+
+	// 两个验证器都通过
+	if _, err := posts.Insert(ctx, map[string]any{"id": "p1", "title": "Hello", "author": "alice"}); err != nil {
+		t.Fatalf("Valid insert should succeed: %v", err)
+	}
+	if strings.Join(calls, ",") != "required,foreign_key" {
+		t.Errorf("Expected validators to run in registration order, got %v", calls)
+	}
+
+	// 第一个验证器失败时中止，后续验证器不再执行
+	calls = nil
+	_, err = posts.Insert(ctx, map[string]any{"id": "p2", "author": "alice"})
+	if err == nil {
+		t.Fatal("Insert without title should fail")
+	}
+	if !IsValidationError(err) {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+	if strings.Join(calls, ",") != "required" {
+		t.Errorf("Expected only first validator to run, got %v", calls)
+	}
+
+	// 外键不存在
+	if _, err := posts.Insert(ctx, map[string]any{"id": "p3", "title": "Orphan", "author": "bob"}); err == nil {
+		t.Fatal("Insert with missing author should fail")
+	}
+	if _, err := posts.FindByID(ctx, "p3"); !IsNotFoundError(err) {
+		t.Errorf("Rejected document should not be stored, got %v", err)
+	}
+
+	// Upsert 同样执行验证器
+	if _, err := posts.Upsert(ctx, map[string]any{"id": "p1", "title": "Hello", "author": "bob"}); err == nil {
+		t.Fatal("Upsert with missing author should fail")
+	}
+	doc, err := posts.FindByID(ctx, "p1")
+	if err != nil {
+		t.Fatalf("Failed to find p1: %v", err)
+	}
+	if doc.GetString("author") != "alice" {
+		t.Errorf("Rejected upsert should not modify document, author = %s", doc.GetString("author"))
+	}
+
+	if _, err := users.Insert(ctx, map[string]any{"id": "bob", "name": "Bob"}); err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+	if _, err := posts.Upsert(ctx, map[string]any{"id": "p1", "title": "Hello", "author": "bob"}); err != nil {
+		t.Fatalf("Valid upsert should succeed: %v", err)
 	}
 }
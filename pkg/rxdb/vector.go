@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"sort"
 	"strings"
 	"sync"
@@ -15,7 +16,6 @@ import (
 	"github.com/blevesearch/bleve/v2"
 	"github.com/blevesearch/bleve/v2/search"
 	lru "github.com/hashicorp/golang-lru/v2"
-	"github.com/sirupsen/logrus"
 )
 
 // Vector 表示一个嵌入向量。
@@ -31,13 +31,21 @@ type VectorSearchConfig struct {
 	DocToEmbedding func(doc map[string]any) (Vector, error)
 	// Dimensions 向量维度。
 	Dimensions int
-	// DistanceMetric 距离度量方式："euclidean"（欧几里得）、"cosine"（余弦）、"dot"（点积）。
+	// DistanceMetric 距离度量方式："euclidean"（欧几里得）、"cosine"（余弦）、"dot"（点积）、
+	// "inner_product"（内积，Score 为原始点积，结果按点积降序排列）。
 	// 默认为 "cosine"。
 	DistanceMetric string
-	// IndexType 索引类型："flat"（平面/暴力搜索）、"ivf"（倒排文件）。
-	// 默认为 "flat"。
-	// 注意：bleve 使用自己的索引优化，此选项保留用于兼容性。
+	// IndexType 索引类型："flat"（默认，bleve 索引或暴力搜索）、"hnsw"（HNSW 近似最近邻图）。
+	// "ivf" 为兼容保留，行为与 "flat" 相同。
+	// "hnsw" 的图结构保存在集合所在的存储中，重启后只为变更过的文档重新写入向量；
+	// 带 Selector 的查询仍使用 "flat" 的检索路径。
 	IndexType string
+	// M HNSW 每个节点每层的邻居数上限（第 0 层为 2*M），默认 16。
+	M int
+	// EfConstruction HNSW 建图时的候选队列大小，越大召回越高、写入越慢，默认 200。
+	EfConstruction int
+	// EfSearch HNSW 查询时的候选队列大小（不小于 Limit），越大召回越高、查询越慢，默认 50。
+	EfSearch int
 	// NumIndexes 用于索引的采样向量数量（用于 IVF 索引）。
 	// 默认为 5。
 	// 注意：bleve 使用自己的索引优化，此选项保留用于兼容性。
@@ -58,13 +66,57 @@ type VectorSearchConfig struct {
 	// CacheSize 向量缓存的最大容量（条目数）。
 	// 默认为 10000。设置为 -1 则禁用缓存。
 	CacheSize int
+	// Normalize 是否在索引和查询前自动将向量归一化为单位向量。
+	// 使用 cosine 度量时，启用后 VectorSearchResult.Score 为余弦相似度（负相关截断为 0），
+	// 未启用时为 1 - 余弦距离/2。
+	Normalize bool
+	// AutoSync 是否监听集合的 Changes() 自动更新索引，nil 时默认为 true。
+	// 为 false 时索引只在构建、Reindex 以及调用 Upsert / Delete 时更新。
+	AutoSync *bool
 }
 
 // VectorSearchResult 向量搜索结果。
 type VectorSearchResult struct {
 	Document Document
 	Distance float64 // 与查询向量的距离
-	Score    float64 // 相似度分数（1 - 归一化距离）
+	// Score 相似度分数，越大越相似：cosine 为 1 - 距离/2（启用 Normalize 时为截断到 [0, 1] 的余弦相似度），
+	// euclidean 为 1/(1+距离)，inner_product 为原始点积
+	Score float64
+}
+
+// 向量搜索实际使用的索引类型。
+const (
+	VectorIndexTypeANN  = "ann"  // bleve 近似最近邻（kNN）索引
+	VectorIndexTypeFlat = "flat" // 暴力搜索，距离为精确值
+	VectorIndexTypeHNSW = "hnsw" // HNSW 图，结果为近似最近邻，距离为精确值
+)
+
+// VectorCluster K-means 聚类结果。
+type VectorCluster struct {
+	CentroidID string   // 向量离质心最近的成员文档 ID
+	Members    []string // 成员文档 ID（按 ID 排序）
+	Centroid   Vector   // 质心向量（成员向量的均值）
+}
+
+// vectorClusterMaxIterations K-means 的最大迭代次数
+const vectorClusterMaxIterations = 100
+
+// VectorSearchExplainEntry 单个搜索结果的距离明细。
+type VectorSearchExplainEntry struct {
+	Document Document
+	Distance float64 // 按配置的距离度量重新计算的精确距离
+	Score    float64 // 由精确距离换算的相似度分数
+	// IndexType 产生该结果的索引类型：VectorIndexTypeANN 或 VectorIndexTypeFlat。
+	IndexType string
+	// ApproximateDistance 索引返回的距离，暴力搜索时与 Distance 相同。
+	ApproximateDistance float64
+	// QuantizationError 近似距离与精确距离之差的绝对值，仅 ANN 索引时非 nil。
+	QuantizationError *float64
+}
+
+// VectorSearchExplanation 向量搜索的解释结果。
+type VectorSearchExplanation struct {
+	TopK []VectorSearchExplainEntry
 }
 
 // VectorSearchOptions 向量搜索选项。
@@ -79,7 +131,7 @@ type VectorSearchOptions struct {
 	// 注意：bleve 使用自己的索引优化，此选项保留用于兼容性。
 	DocsPerIndexSide int
 	// UseFullScan 是否使用全表扫描（而不是索引）。
-	// 注意：bleve 总是使用索引，此选项保留用于兼容性。
+	// 仅对 HNSW 索引生效；bleve 总是使用索引。
 	UseFullScan bool
 	// Selector 元数据过滤选择器（Mango 语法）。
 	// 如果提供，将在向量搜索时进行前置过滤。
@@ -87,6 +139,11 @@ type VectorSearchOptions struct {
 	// Partition 指定搜索的分区值。
 	// 仅当 VectorSearch 配置了 PartitionField 时有效。
 	Partition string
+	// MMR 是否使用最大边际相关性（Maximal Marginal Relevance）重排结果以提高多样性。
+	// 启用后先取 Limit*4 个候选，再逐个选出 MMRLambda*sim(query, doc) - (1-MMRLambda)*max sim(doc, 已选) 最大的文档。
+	MMR bool
+	// MMRLambda MMR 的相关性权重，取值 [0, 1]：0 为纯多样性，1 为纯相似度。
+	MMRLambda float64
 }
 
 // VectorSearch 向量搜索实例。
@@ -104,6 +161,7 @@ type VectorSearch struct {
 	initMode       string
 	metadataFields []string
 	partitionField string
+	normalize      bool
 
 	index                 bleve.Index // 默认索引（未启用分区或作为后备）
 	partitions            map[string]bleve.Index
@@ -119,6 +177,16 @@ type VectorSearch struct {
 	idBloomFilter              *BloomFilter // 已索引向量的布隆过滤器
 	idBloomNeedsRebuild        bool
 	partitionBloomNeedsRebuild map[string]bool
+
+	// 通过 Upsert/Delete 手动维护的向量，文档发生变更后失效
+	manualVectors  map[string]Vector
+	removedVectors map[string]struct{}
+
+	// IndexType 为 "hnsw" 时按分区维护的 HNSW 图，否则为 nil
+	hnsw               map[string]*hnswIndex
+	hnswM              int
+	hnswEfConstruction int
+	hnswEfSearch       int
 }
 
 // AddVectorSearch 在集合上创建向量搜索实例。
@@ -149,6 +217,12 @@ func AddVectorSearch(coll Collection, config VectorSearchConfig) (*VectorSearch,
 	if indexType == "" {
 		indexType = "flat"
 	}
+	if indexType != "flat" && indexType != "ivf" && indexType != "hnsw" {
+		return nil, fmt.Errorf("unsupported index type: %s", indexType)
+	}
+	if config.M < 0 || config.EfConstruction < 0 || config.EfSearch < 0 {
+		return nil, fmt.Errorf("hnsw parameters must not be negative")
+	}
 
 	numIndexes := config.NumIndexes
 	if numIndexes <= 0 {
@@ -175,21 +249,24 @@ func AddVectorSearch(coll Collection, config VectorSearchConfig) (*VectorSearch,
 		cacheSize = 2000 // 默认 2000 条
 	}
 
-	// 确定索引存储路径
-	storePath := col.store.Path()
-	var indexPath string
-	if storePath != "" {
-		// 使用数据库路径下的子目录存储 bleve 索引
-		indexPath = filepath.Join(storePath, "vector", col.name, config.Identifier)
-	} else {
-		// 内存模式，使用临时目录
-		indexPath = filepath.Join(os.TempDir(), "rxdb-vector", col.name, config.Identifier)
+	indexPath := vectorIndexPath(col, config.Identifier)
+
+	docToEmbedding := config.DocToEmbedding
+	if config.Normalize {
+		// 包装嵌入函数，使所有入库向量都为单位向量
+		docToEmbedding = func(doc map[string]any) (Vector, error) {
+			embedding, err := config.DocToEmbedding(doc)
+			if err != nil {
+				return nil, err
+			}
+			return NormalizeVector(embedding), nil
+		}
 	}
 
 	vs := &VectorSearch{
 		identifier:                 config.Identifier,
 		collection:                 col,
-		docToEmbedding:             config.DocToEmbedding,
+		docToEmbedding:             docToEmbedding,
 		dimensions:                 config.Dimensions,
 		distanceMetric:             distanceMetric,
 		indexType:                  indexType,
@@ -199,6 +276,7 @@ func AddVectorSearch(coll Collection, config VectorSearchConfig) (*VectorSearch,
 		initMode:                   initMode,
 		metadataFields:             config.MetadataFields,
 		partitionField:             config.PartitionField,
+		normalize:                  config.Normalize,
 		partitions:                 make(map[string]bleve.Index),
 		partitionBloomFilters:      make(map[string]*BloomFilter),
 		partitionBloomNeedsRebuild: make(map[string]bool),
@@ -206,6 +284,20 @@ func AddVectorSearch(coll Collection, config VectorSearchConfig) (*VectorSearch,
 		indexPath:                  indexPath,
 		closeChan:                  make(chan struct{}),
 		idBloomFilter:              NewBloomFilter(20000, 0.01),
+		manualVectors:              make(map[string]Vector),
+		removedVectors:             make(map[string]struct{}),
+		hnswM:                      defaultHNSWM,
+		hnswEfConstruction:         defaultHNSWEfConstruction,
+		hnswEfSearch:               defaultHNSWEfSearch,
+	}
+	if config.M > 0 {
+		vs.hnswM = config.M
+	}
+	if config.EfConstruction > 0 {
+		vs.hnswEfConstruction = config.EfConstruction
+	}
+	if config.EfSearch > 0 {
+		vs.hnswEfSearch = config.EfSearch
 	}
 
 	if cacheSize > 0 {
@@ -225,7 +317,15 @@ func AddVectorSearch(coll Collection, config VectorSearchConfig) (*VectorSearch,
 
 	// 加载持久化的布隆过滤器
 	if err := vs.loadBloomFilters(context.Background()); err != nil {
-		logrus.WithField("identifier", vs.identifier).WithError(err).Debug("Failed to load vector bloom filters")
+		vs.collection.logger.Debug("Failed to load vector bloom filters", "identifier", vs.identifier, "error", err)
+	}
+
+	// 加载持久化的 HNSW 图，buildIndex 只需补写变更过的文档
+	if indexType == "hnsw" {
+		vs.hnsw = make(map[string]*hnswIndex)
+		if err := vs.loadHNSW(context.Background()); err != nil {
+			vs.collection.logger.Debug("Failed to load hnsw graph", "identifier", vs.identifier, "error", err)
+		}
 	}
 
 	// 根据初始化模式决定是否立即建立索引
@@ -237,11 +337,26 @@ func AddVectorSearch(coll Collection, config VectorSearchConfig) (*VectorSearch,
 	}
 
 	// 启动监听变更的 goroutine
-	go vs.watchChanges()
+	// 在返回前订阅，避免遗漏创建后立即发生的变更
+	if config.AutoSync == nil || *config.AutoSync {
+		go vs.watchChanges(vs.collection.Changes())
+	}
 
+	col.registerVector(vs)
 	return vs, nil
 }
 
+// vectorIndexPath 返回向量索引的 bleve 索引目录。
+func vectorIndexPath(col *collection, identifier string) string {
+	storePath := col.store.Path()
+	if storePath != "" {
+		// 使用数据库路径下的子目录存储 bleve 索引
+		return filepath.Join(storePath, "vector", col.name, identifier)
+	}
+	// 内存模式，使用临时目录
+	return filepath.Join(os.TempDir(), "rxdb-vector", col.name, identifier)
+}
+
 // openOrCreateIndex 打开或创建 bleve 索引。
 // 如果 partition 为空，打开默认索引。
 func (vs *VectorSearch) openOrCreateIndex(partition string) error {
@@ -346,6 +461,9 @@ func (vs *VectorSearch) getOrCreateIndex(partition string) (bleve.Index, error)
 
 // getEmbeddingWithCache 获取文档的嵌入向量，优先从缓存获取。
 func (vs *VectorSearch) getEmbeddingWithCache(docID string, docData map[string]any) (Vector, error) {
+	if vec, ok := vs.manualVectors[docID]; ok {
+		return vec, nil
+	}
 	if docID != "" && !vs.idBloomFilter.Test(docID) {
 		return nil, fmt.Errorf("embedding for document %s definitely does not exist", docID)
 	}
@@ -373,7 +491,7 @@ func (vs *VectorSearch) buildIndex(ctx context.Context) error {
 	defer vs.mu.Unlock()
 
 	// 获取所有文档
-	docs, err := vs.collection.All(ctx)
+	docs, err := vs.collection.all(ctx)
 	if err != nil {
 		return err
 	}
@@ -385,6 +503,8 @@ func (vs *VectorSearch) buildIndex(ctx context.Context) error {
 		count int
 	}
 	pInfos := make(map[string]*partitionInfo)
+	// 本次构建中写入 HNSW 图的文档，构建结束后删除图中其余的过期节点
+	inGraph := make(map[string]struct{})
 
 	getPartitionInfo := func(p string) (*partitionInfo, error) {
 		if info, ok := pInfos[p]; ok {
@@ -403,6 +523,11 @@ func (vs *VectorSearch) buildIndex(ctx context.Context) error {
 	}
 
 	for _, doc := range docs {
+		// 跳过通过 Delete 手动移除的向量
+		if _, removed := vs.removedVectors[doc.ID()]; removed {
+			continue
+		}
+
 		// 确定文档分区
 		partition := ""
 		if vs.partitionField != "" {
@@ -434,6 +559,16 @@ func (vs *VectorSearch) buildIndex(ctx context.Context) error {
 		if len(embedding) != vs.dimensions {
 			continue // 跳过维度不匹配的向量
 		}
+		if g := vs.hnswGraph(partition); g != nil {
+			rev := vs.docRevision(doc.Data())
+			if _, manual := vs.manualVectors[doc.ID()]; manual {
+				rev = ""
+			}
+			if !g.upToDate(doc.ID(), rev) {
+				g.Insert(doc.ID(), rev, embedding)
+			}
+			inGraph[doc.ID()] = struct{}{}
+		}
 
 		// 转换为 float32（bleve 要求）
 		vec32 := make([]float32, len(embedding))
@@ -478,12 +613,19 @@ func (vs *VectorSearch) buildIndex(ctx context.Context) error {
 		}
 	}
 
+	for _, g := range vs.hnsw {
+		for id := range g.ids {
+			if _, ok := inGraph[id]; !ok {
+				g.Delete(id)
+			}
+		}
+	}
+
 	return nil
 }
 
 // watchChanges 监听集合变更并更新索引。
-func (vs *VectorSearch) watchChanges() {
-	changes := vs.collection.Changes()
+func (vs *VectorSearch) watchChanges(changes <-chan ChangeEvent) {
 	for {
 		select {
 		case <-vs.closeChan:
@@ -502,6 +644,11 @@ func (vs *VectorSearch) handleChange(event ChangeEvent) {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
+	if event.Op == OperationTruncate {
+		_ = vs.resetIndexes()
+		return
+	}
+
 	// 确定文档分区
 	partition := ""
 	if vs.partitionField != "" {
@@ -529,6 +676,10 @@ func (vs *VectorSearch) handleChange(event ChangeEvent) {
 	// 更新全局 ID 布隆过滤器
 	vs.idBloomFilter.Add(event.ID)
 
+	// 文档变更后，手动写入或删除的向量失效，以 DocToEmbedding 的结果为准
+	delete(vs.manualVectors, event.ID)
+	delete(vs.removedVectors, event.ID)
+
 	switch event.Op {
 	case OperationInsert, OperationUpdate:
 		if event.Doc != nil {
@@ -563,12 +714,19 @@ func (vs *VectorSearch) handleChange(event ChangeEvent) {
 			}
 
 			_ = idx.Index(event.ID, bleveDoc)
+
+			if g := vs.hnswGraph(partition); g != nil {
+				// 分区字段可能已变更，先从其他分区的图中移除
+				vs.hnswDelete(event.ID, g)
+				g.Insert(event.ID, vs.docRevision(event.Doc), embedding)
+			}
 		}
 	case OperationDelete:
 		if vs.embeddingCache != nil {
 			vs.embeddingCache.Remove(event.ID)
 		}
 		_ = idx.Delete(event.ID)
+		vs.hnswDelete(event.ID, nil)
 
 		// 标记布隆过滤器需要重建
 		vs.idBloomNeedsRebuild = true
@@ -578,6 +736,46 @@ func (vs *VectorSearch) handleChange(event ChangeEvent) {
 	}
 }
 
+// resetIndexes 将默认索引与所有分区索引重置为空（保留配置），并清空缓存与布隆过滤器。
+// 调用方需持有 vs.mu。
+func (vs *VectorSearch) resetIndexes() error {
+	// 实例已关闭时索引也已关闭，不再重建
+	select {
+	case <-vs.closeChan:
+		return nil
+	default:
+	}
+	if vs.index != nil {
+		_ = vs.index.Close()
+		vs.index = nil
+	}
+	for partition, idx := range vs.partitions {
+		_ = idx.Close()
+		delete(vs.partitions, partition)
+	}
+	if err := os.RemoveAll(vs.indexPath); err != nil {
+		return fmt.Errorf("failed to remove index directory: %w", err)
+	}
+
+	vs.partitionBloomFilters = make(map[string]*BloomFilter)
+	vs.partitionBloomNeedsRebuild = make(map[string]bool)
+	vs.idBloomFilter = NewBloomFilter(20000, 0.01)
+	vs.idBloomNeedsRebuild = false
+	vs.manualVectors = make(map[string]Vector)
+	vs.removedVectors = make(map[string]struct{})
+	if vs.embeddingCache != nil {
+		vs.embeddingCache.Purge()
+	}
+	if vs.hnsw != nil {
+		vs.hnsw = make(map[string]*hnswIndex)
+	}
+
+	if vs.partitionField == "" {
+		return vs.openOrCreateIndex("")
+	}
+	return nil
+}
+
 // ensureInitialized 确保索引已初始化。
 func (vs *VectorSearch) ensureInitialized(ctx context.Context) error {
 	if vs.initialized {
@@ -615,6 +813,20 @@ func (vs *VectorSearch) Search(ctx context.Context, queryEmbedding Vector, optio
 		opts = options[0]
 	}
 
+	return vs.searchQuery(ctx, queryEmbedding, opts)
+}
+
+// searchQuery 按选项执行单个查询（MMR 或普通搜索）。调用方需持有 vs.mu 读锁。
+func (vs *VectorSearch) searchQuery(ctx context.Context, queryEmbedding Vector, opts VectorSearchOptions) ([]VectorSearchResult, error) {
+	if opts.MMR {
+		return vs.searchMMR(ctx, queryEmbedding, opts)
+	}
+	results, _, err := vs.search(ctx, queryEmbedding, opts)
+	return results, err
+}
+
+// search 执行向量搜索，同时返回实际使用的索引类型。调用方需持有 vs.mu 读锁。
+func (vs *VectorSearch) search(ctx context.Context, queryEmbedding Vector, opts VectorSearchOptions) ([]VectorSearchResult, string, error) {
 	// 选择索引（支持物理分区）
 	var idx bleve.Index
 	if vs.partitionField != "" && opts.Partition != "" {
@@ -622,19 +834,31 @@ func (vs *VectorSearch) Search(ctx context.Context, queryEmbedding Vector, optio
 		idx, ok = vs.partitions[opts.Partition]
 		if !ok {
 			// 分区不存在，返回空结果
-			return []VectorSearchResult{}, nil
+			return []VectorSearchResult{}, VectorIndexTypeANN, nil
 		}
 	} else {
 		idx = vs.index
 	}
 
 	if idx == nil {
-		return nil, fmt.Errorf("no index available for search")
+		return nil, "", fmt.Errorf("no index available for search")
 	}
 
 	// 验证查询向量维度
 	if len(queryEmbedding) != vs.dimensions {
-		return nil, fmt.Errorf("query embedding dimension mismatch: expected %d, got %d", vs.dimensions, len(queryEmbedding))
+		return nil, "", fmt.Errorf("query embedding dimension mismatch: expected %d, got %d", vs.dimensions, len(queryEmbedding))
+	}
+	if vs.normalize {
+		queryEmbedding = NormalizeVector(queryEmbedding)
+	}
+
+	if vs.hnsw != nil && len(opts.Selector) == 0 {
+		if opts.UseFullScan {
+			results, err := vs.searchWithoutKNN(ctx, queryEmbedding, opts)
+			return results, VectorIndexTypeFlat, err
+		}
+		results, err := vs.searchHNSW(ctx, queryEmbedding, opts)
+		return results, VectorIndexTypeHNSW, err
 	}
 
 	// 转换为 float32
@@ -656,7 +880,8 @@ func (vs *VectorSearch) Search(ctx context.Context, queryEmbedding Vector, optio
 		res, err := idx.Search(countRequest)
 		if err == nil && res.Total < 100 {
 			// 策略切换：对于极少量的结果（< 100），执行前置过滤后的暴力搜索
-			return vs.searchWithMetadataFilteredBruteForce(ctx, queryEmbedding, opts, res.Hits)
+			results, err := vs.searchWithMetadataFilteredBruteForce(ctx, queryEmbedding, opts, res.Hits)
+			return results, VectorIndexTypeFlat, err
 		}
 	}
 
@@ -673,13 +898,14 @@ func (vs *VectorSearch) Search(ctx context.Context, queryEmbedding Vector, optio
 		addKNN("_vector", queryVec32, k, 1.0)
 	} else {
 		// 回退到全表扫描（如果没有向量搜索支持）
-		return vs.searchWithoutKNN(ctx, queryEmbedding, opts)
+		results, err := vs.searchWithoutKNN(ctx, queryEmbedding, opts)
+		return results, VectorIndexTypeFlat, err
 	}
 
 	// 执行搜索
 	searchResult, err := idx.Search(searchRequest)
 	if err != nil {
-		return nil, fmt.Errorf("bleve vector search failed: %w", err)
+		return nil, "", fmt.Errorf("bleve vector search failed: %w", err)
 	}
 
 	// 转换结果
@@ -725,12 +951,73 @@ func (vs *VectorSearch) Search(ctx context.Context, queryEmbedding Vector, optio
 		results = results[:opts.Limit]
 	}
 
-	return results, nil
+	return results, VectorIndexTypeANN, nil
+}
+
+// ExplainSearch 执行与 Search 相同的搜索，并返回每个结果的距离明细，用于排查结果排序。
+// 对每个结果都会重新生成嵌入向量并计算精确距离；使用 ANN 索引时同时给出索引返回的近似距离。
+func (vs *VectorSearch) ExplainSearch(ctx context.Context, queryEmbedding Vector, opts VectorSearchOptions) (*VectorSearchExplanation, error) {
+	if err := vs.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	results, indexType, err := vs.search(ctx, queryEmbedding, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if vs.normalize {
+		queryEmbedding = NormalizeVector(queryEmbedding)
+	}
+
+	explanation := &VectorSearchExplanation{TopK: make([]VectorSearchExplainEntry, 0, len(results))}
+	for _, result := range results {
+		entry := VectorSearchExplainEntry{
+			Document:            result.Document,
+			Distance:            result.Distance,
+			Score:               result.Score,
+			IndexType:           indexType,
+			ApproximateDistance: result.Distance,
+		}
+
+		// 使用存储中的原始文档生成嵌入，避免读取转换器影响计算
+		raw, err := vs.collection.findByID(ctx, result.Document.ID())
+		if err != nil {
+			return nil, fmt.Errorf("failed to load document %s: %w", result.Document.ID(), err)
+		}
+		embedding, ok := vs.manualVectors[raw.ID()]
+		if !ok {
+			embedding, err = vs.docToEmbedding(raw.Data())
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute embedding for document %s: %w", result.Document.ID(), err)
+			}
+			if vs.normalize {
+				embedding = NormalizeVector(embedding)
+			}
+		}
+
+		entry.Distance = vs.calculateDistance(queryEmbedding, embedding)
+		entry.Score = vs.distanceToScore(entry.Distance)
+		if indexType == VectorIndexTypeANN {
+			quantizationError := math.Abs(result.Distance - entry.Distance)
+			entry.QuantizationError = &quantizationError
+		}
+		explanation.TopK = append(explanation.TopK, entry)
+	}
+
+	return explanation, nil
 }
 
 // scoreToDistance 将 bleve 的分数转换为距离。
 // bleve 的 kNN 分数是 1 / (1 + squared_distance)
 func (vs *VectorSearch) scoreToDistance(score float64) float64 {
+	if vs.distanceMetric == "inner_product" {
+		// dot_product 相似度下 bleve 的分数即点积，距离取其负值
+		return -score
+	}
 	if score <= 0 {
 		return math.MaxFloat64
 	}
@@ -747,6 +1034,10 @@ func (vs *VectorSearch) scoreToDistance(score float64) float64 {
 func (vs *VectorSearch) distanceToScore(distance float64) float64 {
 	switch vs.distanceMetric {
 	case "cosine":
+		if vs.normalize {
+			// 启用 Normalize 时分数即余弦相似度：相同方向为 1，正交为 0，负相关截断为 0
+			return math.Max(0, math.Min(1, 1.0-distance))
+		}
 		// 余弦距离范围 [0, 2]，转换为分数 [0, 1]
 		return 1.0 - distance/2.0
 	case "euclidean", "l2":
@@ -755,6 +1046,9 @@ func (vs *VectorSearch) distanceToScore(distance float64) float64 {
 	case "dot", "dot_product":
 		// 点积距离是负值，直接使用 sigmoid
 		return 1.0 / (1.0 + math.Exp(distance))
+	case "inner_product":
+		// 分数即原始点积，不做归一化
+		return -distance
 	default:
 		return 1.0 - distance
 	}
@@ -781,7 +1075,7 @@ func (vs *VectorSearch) SearchByDocument(ctx context.Context, doc map[string]any
 // 查找与指定文档相似的其他文档。
 func (vs *VectorSearch) SearchByID(ctx context.Context, docID string, options ...VectorSearchOptions) ([]VectorSearchResult, error) {
 	// 获取文档
-	doc, err := vs.collection.FindByID(ctx, docID)
+	doc, err := vs.collection.findByID(ctx, docID)
 	if err != nil {
 		return nil, fmt.Errorf("document %s not found: %w", docID, err)
 	}
@@ -812,7 +1106,7 @@ func (vs *VectorSearch) SearchByID(ctx context.Context, docID string, options ..
 // 注意：bleve 不直接存储原始向量，此方法需要重新生成。
 func (vs *VectorSearch) GetEmbedding(docID string) (Vector, bool) {
 	// 从集合获取文档
-	doc, err := vs.collection.FindByID(context.Background(), docID)
+	doc, err := vs.collection.findByID(context.Background(), docID)
 	if err != nil {
 		return nil, false
 	}
@@ -830,6 +1124,9 @@ func (vs *VectorSearch) SetEmbedding(docID string, embedding Vector) error {
 	if len(embedding) != vs.dimensions {
 		return fmt.Errorf("embedding dimension mismatch: expected %d, got %d", vs.dimensions, len(embedding))
 	}
+	if vs.normalize {
+		embedding = NormalizeVector(embedding)
+	}
 
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
@@ -844,9 +1141,119 @@ func (vs *VectorSearch) SetEmbedding(docID string, embedding Vector) error {
 		"_vector": vec32,
 	}
 
+	if g := vs.hnswGraph(""); g != nil {
+		g.Insert(docID, "", embedding)
+	}
 	return vs.index.Index(docID, bleveDoc)
 }
 
+// Upsert 写入或替换单个文档的向量，无需重建整个索引。
+// 文档存在于集合中时，分区与元数据字段取自该文档；注意搜索结果只返回集合中存在的文档，
+// 且该文档后续的变更事件会按 DocToEmbedding 重新生成向量并覆盖此处写入的值。
+func (vs *VectorSearch) Upsert(ctx context.Context, docID string, vec Vector) error {
+	if len(vec) != vs.dimensions {
+		return fmt.Errorf("embedding dimension mismatch: expected %d, got %d", vs.dimensions, len(vec))
+	}
+	if err := vs.ensureInitialized(ctx); err != nil {
+		return err
+	}
+	if vs.normalize {
+		vec = NormalizeVector(vec)
+	}
+
+	// 在获取 vs.mu 之前读取文档，与 buildIndex 的加锁顺序保持一致
+	var docData map[string]any
+	doc, err := vs.collection.findByID(ctx, docID)
+	if err == nil {
+		docData = doc.Data()
+	} else if !IsNotFoundError(err) {
+		return err
+	}
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	partition := ""
+	if vs.partitionField != "" {
+		if p, ok := docData[vs.partitionField].(string); ok {
+			partition = p
+		}
+	}
+	idx, err := vs.getOrCreateIndex(partition)
+	if err != nil {
+		return err
+	}
+
+	vec32 := make([]float32, len(vec))
+	for i, v := range vec {
+		vec32[i] = float32(v)
+	}
+	bleveDoc := map[string]interface{}{
+		"_vector": vec32,
+	}
+	for _, field := range vs.metadataFields {
+		if val, ok := docData[field]; ok {
+			bleveDoc[field] = val
+		}
+	}
+	if err := idx.Index(docID, bleveDoc); err != nil {
+		return fmt.Errorf("failed to index vector %s: %w", docID, err)
+	}
+
+	if partition != "" {
+		if _, ok := vs.partitionBloomFilters[partition]; !ok {
+			vs.partitionBloomFilters[partition] = NewBloomFilter(1000, 0.01)
+		}
+		vs.partitionBloomFilters[partition].Add(docID)
+	}
+	vs.idBloomFilter.Add(docID)
+	vs.manualVectors[docID] = vec
+	delete(vs.removedVectors, docID)
+	if g := vs.hnswGraph(partition); g != nil {
+		vs.hnswDelete(docID, g)
+		g.Insert(docID, "", vec)
+	}
+	if vs.embeddingCache != nil {
+		vs.embeddingCache.Add(docID, vec)
+	}
+	return nil
+}
+
+// Delete 从向量索引中移除单个文档的向量，不影响集合中的文档。
+func (vs *VectorSearch) Delete(ctx context.Context, docID string) error {
+	if err := vs.ensureInitialized(ctx); err != nil {
+		return err
+	}
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	// 文档所在分区未知，从默认索引和所有分区中删除
+	if vs.index != nil {
+		if err := vs.index.Delete(docID); err != nil {
+			return fmt.Errorf("failed to delete vector %s: %w", docID, err)
+		}
+	}
+	for partition, idx := range vs.partitions {
+		if idx == vs.index {
+			continue
+		}
+		if err := idx.Delete(docID); err != nil {
+			return fmt.Errorf("failed to delete vector %s from partition %s: %w", docID, partition, err)
+		}
+		vs.partitionBloomNeedsRebuild[partition] = true
+	}
+
+	vs.hnswDelete(docID, nil)
+	delete(vs.manualVectors, docID)
+	vs.removedVectors[docID] = struct{}{}
+	if vs.embeddingCache != nil {
+		vs.embeddingCache.Remove(docID)
+	}
+	vs.idBloomNeedsRebuild = true
+	return nil
+}
+
 // Reindex 重建向量索引。
 func (vs *VectorSearch) Reindex(ctx context.Context) error {
 	vs.mu.Lock()
@@ -866,6 +1273,9 @@ func (vs *VectorSearch) Reindex(ctx context.Context) error {
 	if err := vs.openOrCreateIndex(""); err != nil {
 		return fmt.Errorf("failed to recreate index: %w", err)
 	}
+	if vs.hnsw != nil {
+		vs.hnsw = make(map[string]*hnswIndex)
+	}
 
 	return vs.buildIndex(ctx)
 }
@@ -889,10 +1299,16 @@ func (vs *VectorSearch) Close() {
 		_ = vs.rebuildBloomFilters(context.Background())
 	}
 
-	// 保存布隆过滤器
+	// 保存布隆过滤器与 HNSW 图
 	_ = vs.saveBloomFilters(context.Background())
+	if vs.hnsw != nil {
+		if err := vs.persistHNSW(context.Background()); err != nil {
+			vs.collection.logger.Debug("Failed to persist hnsw graph", "identifier", vs.identifier, "error", err)
+		}
+	}
 
 	close(vs.closeChan)
+	vs.collection.unregisterVector(vs)
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 	if vs.index != nil {
@@ -912,20 +1328,221 @@ func (vs *VectorSearch) Count() int {
 }
 
 // Persist 持久化向量索引到存储。
-// bleve 索引会自动持久化，此方法主要用于兼容性。
+// bleve 索引会自动持久化；HNSW 图写入集合所在的存储（Close 时也会自动写入）。
 func (vs *VectorSearch) Persist(ctx context.Context) error {
-	// bleve 索引已经持久化到磁盘，无需额外操作
-	return nil
+	if vs.hnsw == nil {
+		return nil
+	}
+	return vs.persistHNSW(ctx)
 }
 
-// Load 从存储加载持久化的向量索引。
-// bleve 索引在打开时自动加载，此方法主要用于兼容性。
+// Load 从存储加载持久化的向量索引，之后不再执行初始化构建。
+// bleve 索引在打开时自动加载；HNSW 图替换为上次 Persist 的结果。
 func (vs *VectorSearch) Load(ctx context.Context) error {
-	// bleve 索引在 openOrCreateIndex 时已经加载
+	if vs.hnsw != nil {
+		if err := vs.loadHNSW(ctx); err != nil {
+			return err
+		}
+	}
+	vs.mu.Lock()
 	vs.initialized = true
+	vs.mu.Unlock()
 	return nil
 }
 
+// vectorManifestFile Export 数据中保存元信息的文件名。
+const vectorManifestFile = "rxdb_vector_manifest.json"
+
+// vectorIndexManifest Export 数据的元信息，Import 时据此校验兼容性并恢复手动写入的向量。
+type vectorIndexManifest struct {
+	Version        int               `json:"version"`
+	Dimensions     int               `json:"dimensions"`
+	DistanceMetric string            `json:"distance_metric"`
+	IndexType      string            `json:"index_type"`
+	Partitions     []string          `json:"partitions,omitempty"`
+	ManualVectors  map[string]Vector `json:"manual_vectors,omitempty"`
+	RemovedVectors []string          `json:"removed_vectors,omitempty"`
+}
+
+// Export 将向量索引（默认索引、所有分区索引以及通过 Upsert/Delete 手动维护的向量）
+// 序列化为自包含的 zip 数据，可通过 Import 加载到其他实例。导出期间仅持有读锁，不阻塞查询。
+func (vs *VectorSearch) Export(ctx context.Context) ([]byte, error) {
+	if err := vs.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	tmpDir, err := os.MkdirTemp("", "rxdb-vector-export-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// 导出目录与 indexPath 布局一致：默认索引位于根目录，分区索引位于 partition_ 子目录
+	if vs.index != nil {
+		if err := copyBleveIndex(vs.index, tmpDir); err != nil {
+			return nil, err
+		}
+	}
+	manifest := vectorIndexManifest{
+		Version:        1,
+		Dimensions:     vs.dimensions,
+		DistanceMetric: vs.distanceMetric,
+		IndexType:      vs.indexType,
+		ManualVectors:  vs.manualVectors,
+	}
+	for partition, idx := range vs.partitions {
+		if idx == vs.index {
+			continue
+		}
+		if err := copyBleveIndex(idx, filepath.Join(tmpDir, "partition_"+partition)); err != nil {
+			return nil, err
+		}
+		manifest.Partitions = append(manifest.Partitions, partition)
+	}
+	for id := range vs.removedVectors {
+		manifest.RemovedVectors = append(manifest.RemovedVectors, id)
+	}
+	sort.Strings(manifest.Partitions)
+	sort.Strings(manifest.RemovedVectors)
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal vector index manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, vectorManifestFile), data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write vector index manifest: %w", err)
+	}
+	return zipDirectory(ctx, tmpDir)
+}
+
+// Import 用 Export 导出的数据替换当前向量索引。
+// 导出数据的维度或距离度量与当前配置不一致时，分别返回 DimensionMismatchError 或 MetricMismatchError，
+// 且当前索引保持不变。
+func (vs *VectorSearch) Import(ctx context.Context, data []byte) error {
+	// 先解压到索引目录旁的临时目录并校验，避免损坏当前索引
+	if err := os.MkdirAll(filepath.Dir(vs.indexPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(vs.indexPath), ".import-")
+	if err != nil {
+		return fmt.Errorf("failed to create import directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := unzipDirectory(ctx, data, tmpDir); err != nil {
+		return err
+	}
+	manifestPath := filepath.Join(tmpDir, vectorManifestFile)
+	raw, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return fmt.Errorf("invalid vector index data: missing manifest")
+	}
+	var manifest vectorIndexManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return fmt.Errorf("invalid vector index manifest: %w", err)
+	}
+	if manifest.Version != 1 {
+		return fmt.Errorf("unsupported vector index version: %d", manifest.Version)
+	}
+	if manifest.Dimensions != vs.dimensions {
+		return &DimensionMismatchError{Expected: vs.dimensions, Actual: manifest.Dimensions}
+	}
+	if !sameDistanceMetric(manifest.DistanceMetric, vs.distanceMetric) {
+		return &MetricMismatchError{Expected: vs.distanceMetric, Actual: manifest.DistanceMetric}
+	}
+	for id, vec := range manifest.ManualVectors {
+		if len(vec) != vs.dimensions {
+			return fmt.Errorf("invalid vector %s in index data: %w", id, &DimensionMismatchError{Expected: vs.dimensions, Actual: len(vec)})
+		}
+	}
+	if err := os.Remove(manifestPath); err != nil {
+		return fmt.Errorf("failed to remove vector index manifest: %w", err)
+	}
+
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	select {
+	case <-vs.closeChan:
+		return fmt.Errorf("vector search is closed")
+	default:
+	}
+
+	if vs.index != nil {
+		_ = vs.index.Close()
+		vs.index = nil
+	}
+	for partition, idx := range vs.partitions {
+		_ = idx.Close()
+		delete(vs.partitions, partition)
+	}
+	if err := os.RemoveAll(vs.indexPath); err != nil {
+		return fmt.Errorf("failed to remove index directory: %w", err)
+	}
+	if err := os.Rename(tmpDir, vs.indexPath); err != nil {
+		return fmt.Errorf("failed to move imported index: %w", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(vs.indexPath, "index_meta.json")); err == nil || vs.partitionField == "" {
+		if err := vs.openOrCreateIndex(""); err != nil {
+			return fmt.Errorf("failed to open imported index: %w", err)
+		}
+	}
+	vs.partitionBloomFilters = make(map[string]*BloomFilter)
+	vs.partitionBloomNeedsRebuild = make(map[string]bool)
+	for _, partition := range manifest.Partitions {
+		if err := vs.openOrCreateIndex(partition); err != nil {
+			return fmt.Errorf("failed to open imported partition %s: %w", partition, err)
+		}
+		vs.partitionBloomFilters[partition] = NewBloomFilter(1000, 0.01)
+	}
+
+	vs.manualVectors = make(map[string]Vector, len(manifest.ManualVectors))
+	for id, vec := range manifest.ManualVectors {
+		vs.manualVectors[id] = vec
+	}
+	vs.removedVectors = make(map[string]struct{}, len(manifest.RemovedVectors))
+	for _, id := range manifest.RemovedVectors {
+		vs.removedVectors[id] = struct{}{}
+	}
+	if vs.embeddingCache != nil {
+		vs.embeddingCache.Purge()
+	}
+	if err := vs.rebuildBloomFilters(ctx); err != nil {
+		return err
+	}
+	// 手动写入的向量可能不对应集合文档，单独加入布隆过滤器
+	for id := range vs.manualVectors {
+		vs.idBloomFilter.Add(id)
+	}
+	// 导出数据不含 HNSW 图，按导入后的向量重建
+	if err := vs.rebuildHNSW(ctx); err != nil {
+		return err
+	}
+	vs.initialized = true
+	return nil
+}
+
+// copyBleveIndex 将 bleve 索引的一致性快照复制到目录 dir。
+func copyBleveIndex(idx bleve.Index, dir string) error {
+	copyable, ok := idx.(bleve.IndexCopyable)
+	if !ok {
+		return fmt.Errorf("vector index does not support export")
+	}
+	if err := copyable.CopyTo(bleve.FileSystemDirectory(dir)); err != nil {
+		return fmt.Errorf("failed to copy index: %w", err)
+	}
+	return nil
+}
+
+// sameDistanceMetric 判断两个距离度量名称是否等价（如 "euclidean" 与 "l2"）。
+func sameDistanceMetric(a, b string) bool {
+	return (&VectorSearch{distanceMetric: a}).getSimilarityMetric() == (&VectorSearch{distanceMetric: b}).getSimilarityMetric()
+}
+
 // KNNSearch K 近邻搜索。
 // 返回与查询向量最接近的 K 个文档。
 func (vs *VectorSearch) KNNSearch(ctx context.Context, queryEmbedding Vector, k int) ([]VectorSearchResult, error) {
@@ -942,16 +1559,71 @@ func (vs *VectorSearch) RangeSearch(ctx context.Context, queryEmbedding Vector,
 	})
 }
 
-// BatchSearch 批量搜索。
-// 对多个查询向量执行搜索。
-func (vs *VectorSearch) BatchSearch(ctx context.Context, queryEmbeddings []Vector, options ...VectorSearchOptions) ([][]VectorSearchResult, error) {
-	results := make([][]VectorSearchResult, len(queryEmbeddings))
-	for i, query := range queryEmbeddings {
-		result, err := vs.Search(ctx, query, options...)
-		if err != nil {
-			return nil, fmt.Errorf("failed to search for query %d: %w", i, err)
+// BatchSearch 批量搜索，返回与 queries 一一对应的结果。
+// 全部查询共享同一把读锁，由 runtime.NumCPU() 个 goroutine 组成的工作池并行执行
+// （HNSW 图遍历与 bleve 索引查询都支持并发读）。
+// ctx 被取消时不再分派新的查询，返回已完成的结果（未完成的位置为 nil）与 ctx.Err()；
+// 任一查询失败时停止分派并返回该错误。
+func (vs *VectorSearch) BatchSearch(ctx context.Context, queries []Vector, opts VectorSearchOptions) ([][]VectorSearchResult, error) {
+	if err := vs.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	results := make([][]VectorSearchResult, len(queries))
+	if len(queries) == 0 {
+		return results, nil
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	jobs := make(chan int)
+	numWorkers := min(runtime.NumCPU(), len(queries))
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				result, err := vs.searchQuery(runCtx, queries[i], opts)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("failed to search for query %d: %w", i, err)
+						cancel()
+					})
+					continue
+				}
+				// 取消后完成的查询可能因读取文档失败而缺少结果，不计入已完成
+				if runCtx.Err() == nil {
+					results[i] = result
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i := range queries {
+		select {
+		case <-runCtx.Done():
+			break dispatch
+		case jobs <- i:
 		}
-		results[i] = result
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	if firstErr != nil {
+		return nil, firstErr
 	}
 	return results, nil
 }
@@ -971,7 +1643,7 @@ func (vs *VectorSearch) ComputeSimilarityMatrix(docIDs []string) ([][]float64, e
 	// 获取所有文档的嵌入向量
 	embeddings := make(map[string]Vector)
 	for _, docID := range docIDs {
-		doc, err := vs.collection.FindByID(context.Background(), docID)
+		doc, err := vs.collection.findByID(context.Background(), docID)
 		if err != nil {
 			continue
 		}
@@ -1005,6 +1677,164 @@ func (vs *VectorSearch) ComputeSimilarityMatrix(docIDs []string) ([][]float64, e
 	return matrix, nil
 }
 
+// Cluster 对所有已索引向量执行 K-means 聚类，直到分配不再变化或达到最大迭代次数（100）。
+// 初始质心采用最远点优先策略选取，结果可复现。k 超过已索引向量数量时返回错误。
+// 返回的簇按成员数量降序排列。
+func (vs *VectorSearch) Cluster(ctx context.Context, k int) ([]VectorCluster, error) {
+	if k <= 0 {
+		return nil, fmt.Errorf("k must be positive")
+	}
+	if err := vs.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+
+	ids, vectors, err := vs.allVectors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if k > len(vectors) {
+		return nil, fmt.Errorf("k (%d) exceeds number of indexed vectors (%d)", k, len(vectors))
+	}
+
+	// 最远点优先初始化：首个质心为 ID 最小的向量，之后每次选取距已选质心最远的向量
+	centroids := make([]Vector, 0, k)
+	centroids = append(centroids, append(Vector(nil), vectors[0]...))
+	minDist := make([]float64, len(vectors))
+	for i, v := range vectors {
+		minDist[i] = vs.calculateDistance(v, centroids[0])
+	}
+	for len(centroids) < k {
+		farthest := 0
+		for i := range vectors {
+			if minDist[i] > minDist[farthest] {
+				farthest = i
+			}
+		}
+		c := append(Vector(nil), vectors[farthest]...)
+		centroids = append(centroids, c)
+		for i, v := range vectors {
+			if d := vs.calculateDistance(v, c); d < minDist[i] {
+				minDist[i] = d
+			}
+		}
+	}
+
+	assignments := make([]int, len(vectors))
+	for i := range assignments {
+		assignments[i] = -1
+	}
+	for iter := 0; iter < vectorClusterMaxIterations; iter++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		changed := false
+		for i, v := range vectors {
+			best, bestDist := 0, math.Inf(1)
+			for c, centroid := range centroids {
+				if d := vs.calculateDistance(v, centroid); d < bestDist {
+					best, bestDist = c, d
+				}
+			}
+			if assignments[i] != best {
+				assignments[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		// 重新计算质心；空簇保留原质心
+		sums := make([]Vector, k)
+		counts := make([]int, k)
+		for i, v := range vectors {
+			c := assignments[i]
+			if sums[c] == nil {
+				sums[c] = make(Vector, vs.dimensions)
+			}
+			for d, x := range v {
+				sums[c][d] += x
+			}
+			counts[c]++
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				continue
+			}
+			for d := range sums[c] {
+				sums[c][d] /= float64(counts[c])
+			}
+			centroids[c] = sums[c]
+		}
+	}
+
+	clusters := make([]VectorCluster, 0, k)
+	for c, centroid := range centroids {
+		cluster := VectorCluster{Centroid: centroid}
+		bestDist := math.Inf(1)
+		for i, v := range vectors {
+			if assignments[i] != c {
+				continue
+			}
+			cluster.Members = append(cluster.Members, ids[i])
+			if d := vs.calculateDistance(v, centroid); d < bestDist {
+				cluster.CentroidID, bestDist = ids[i], d
+			}
+		}
+		if len(cluster.Members) > 0 {
+			clusters = append(clusters, cluster)
+		}
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if len(clusters[i].Members) != len(clusters[j].Members) {
+			return len(clusters[i].Members) > len(clusters[j].Members)
+		}
+		return clusters[i].CentroidID < clusters[j].CentroidID
+	})
+	return clusters, nil
+}
+
+// allVectors 返回所有已索引文档的 ID 与向量（按 ID 排序），手动维护的向量优先。
+func (vs *VectorSearch) allVectors(ctx context.Context) ([]string, []Vector, error) {
+	docs, err := vs.collection.All(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	byID := make(map[string]Vector, len(docs)+len(vs.manualVectors))
+	for _, doc := range docs {
+		if _, removed := vs.removedVectors[doc.ID()]; removed {
+			continue
+		}
+		embedding, err := vs.docToEmbedding(doc.Data())
+		if err != nil || len(embedding) != vs.dimensions {
+			continue
+		}
+		if vs.normalize {
+			embedding = NormalizeVector(embedding)
+		}
+		byID[doc.ID()] = embedding
+	}
+	for id, vec := range vs.manualVectors {
+		byID[id] = vec
+	}
+
+	ids := make([]string, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	vectors := make([]Vector, len(ids))
+	for i, id := range ids {
+		vectors[i] = byID[id]
+	}
+	return ids, vectors, nil
+}
+
 // calculateDistance 计算两个向量之间的距离。
 func (vs *VectorSearch) calculateDistance(a, b Vector) float64 {
 	switch vs.distanceMetric {
@@ -1012,7 +1842,7 @@ func (vs *VectorSearch) calculateDistance(a, b Vector) float64 {
 		return EuclideanDistance(a, b)
 	case "cosine":
 		return CosineDistance(a, b)
-	case "dot", "dot_product":
+	case "dot", "dot_product", "inner_product":
 		return DotProductDistance(a, b)
 	default:
 		return CosineDistance(a, b)
@@ -1077,6 +1907,78 @@ func CosineSimilarity(a, b Vector) float64 {
 	return dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
 }
 
+// batchCosineParallelThreshold 候选数量达到该值时才并行计算，避免小批量的调度开销。
+const batchCosineParallelThreshold = 1024
+
+// BatchCosineDistances 一次性计算查询向量与所有候选向量的余弦距离，结果与 candidates 一一对应。
+// 查询向量的模长只计算一次；候选数量较多时按 CPU 核数分段并行。
+// 维度不一致或模长为 0 的候选距离为 1，与 CosineDistance 保持一致。
+func BatchCosineDistances(query Vector, candidates []Vector) []float64 {
+	distances := make([]float64, len(candidates))
+	if len(candidates) == 0 {
+		return distances
+	}
+
+	var queryNorm float64
+	for _, v := range query {
+		queryNorm += v * v
+	}
+	queryNorm = math.Sqrt(queryNorm)
+
+	compute := func(start, end int) {
+		for j := start; j < end; j++ {
+			distances[j] = 1.0 - cosineSimilarityWithNorm(query, queryNorm, candidates[j])
+		}
+	}
+
+	numWorkers := runtime.NumCPU()
+	if len(candidates) < batchCosineParallelThreshold || numWorkers <= 1 {
+		compute(0, len(candidates))
+		return distances
+	}
+
+	chunk := (len(candidates) + numWorkers - 1) / numWorkers
+	var wg sync.WaitGroup
+	for start := 0; start < len(candidates); start += chunk {
+		end := start + chunk
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			compute(start, end)
+		}(start, end)
+	}
+	wg.Wait()
+	return distances
+}
+
+// cosineSimilarityWithNorm 使用预先计算的查询向量模长计算余弦相似度。
+func cosineSimilarityWithNorm(query Vector, queryNorm float64, b Vector) float64 {
+	n := len(query)
+	if n != len(b) || queryNorm == 0 {
+		return 0
+	}
+
+	var dotProduct, normB float64
+	i := 0
+	// 循环展开以辅助编译器自动向量化
+	for ; i <= n-4; i += 4 {
+		dotProduct += query[i]*b[i] + query[i+1]*b[i+1] + query[i+2]*b[i+2] + query[i+3]*b[i+3]
+		normB += b[i]*b[i] + b[i+1]*b[i+1] + b[i+2]*b[i+2] + b[i+3]*b[i+3]
+	}
+	for ; i < n; i++ {
+		dotProduct += query[i] * b[i]
+		normB += b[i] * b[i]
+	}
+
+	if normB == 0 {
+		return 0
+	}
+	return dotProduct / (queryNorm * math.Sqrt(normB))
+}
+
 // DotProductDistance 计算点积距离（负点积）。
 func DotProductDistance(a, b Vector) float64 {
 	n := len(a)
@@ -1122,7 +2024,7 @@ func (vs *VectorSearch) getSimilarityMetric() string {
 		return "cosine"
 	case "euclidean", "l2":
 		return "l2_norm"
-	case "dot", "dot_product":
+	case "dot", "dot_product", "inner_product":
 		return "dot_product"
 	default:
 		return "cosine"
@@ -1231,10 +2133,17 @@ func (vs *VectorSearch) searchWithoutKNN(ctx context.Context, queryEmbedding Vec
 			}
 		}
 
-		embedding, err := vs.docToEmbedding(doc.Data())
-		if err != nil {
+		if _, removed := vs.removedVectors[doc.ID()]; removed {
 			continue
 		}
+		embedding, ok := vs.manualVectors[doc.ID()]
+		if !ok {
+			var err error
+			embedding, err = vs.docToEmbedding(doc.Data())
+			if err != nil {
+				continue
+			}
+		}
 		if len(embedding) != vs.dimensions {
 			continue
 		}
@@ -1322,7 +2231,7 @@ func (vs *VectorSearch) rebuildBloomFilters(ctx context.Context) error {
 	}
 
 	// 遍历所有文档重新构建
-	docs, err := vs.collection.All(ctx)
+	docs, err := vs.collection.all(ctx)
 	if err != nil {
 		return err
 	}
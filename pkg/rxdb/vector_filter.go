@@ -0,0 +1,143 @@
+package rxdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// filterSearchBruteForceMax 过滤后的候选文档不超过该数量时直接暴力计算距离。
+const filterSearchBruteForceMax = 1000
+
+// filterSearchBruteForceRatio 候选文档占已索引向量的比例低于该值时直接暴力计算距离，
+// 此时带过滤的图遍历需要跳过大量不匹配的节点，效率低于逐个计算。
+const filterSearchBruteForceRatio = 0.05
+
+// FilterSearch 在满足 filter（Mango 选择器）的文档中执行向量相似性搜索，结果只包含匹配的文档。
+// 先通过集合查询得到候选文档集合，候选较少（不超过 1000 个或不足已索引向量的 5%）时在候选中暴力搜索；
+// 否则在 HNSW 图上搜索并只接受候选节点。未启用 HNSW（IndexType 不为 "hnsw"）时总是暴力搜索。
+// opts.Selector 若非空，与 filter 同时生效。
+func (vs *VectorSearch) FilterSearch(ctx context.Context, query Vector, filter map[string]any, opts VectorSearchOptions) ([]VectorSearchResult, error) {
+	if len(query) != vs.dimensions {
+		return nil, fmt.Errorf("query embedding dimension mismatch: expected %d, got %d", vs.dimensions, len(query))
+	}
+	if err := vs.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+	if vs.normalize {
+		query = NormalizeVector(query)
+	}
+
+	selector := filter
+	if len(opts.Selector) > 0 {
+		selector = map[string]any{"$and": []any{filter, opts.Selector}}
+	}
+	if vs.partitionField != "" && opts.Partition != "" {
+		selector = map[string]any{"$and": []any{selector, map[string]any{vs.partitionField: opts.Partition}}}
+	}
+	docs, err := vs.collection.Find(selector).Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate filter: %w", err)
+	}
+	candidates := make(map[string]Document, len(docs))
+	for _, doc := range docs {
+		candidates[doc.ID()] = doc
+	}
+
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	var results []VectorSearchResult
+	g := vs.hnsw[opts.Partition]
+	if vs.partitionField == "" {
+		g = vs.hnsw[""]
+	}
+	if g != nil && len(candidates) > filterSearchBruteForceMax &&
+		float64(len(candidates)) >= filterSearchBruteForceRatio*float64(g.Len()) {
+		results = vs.filterSearchHNSW(g, query, candidates, opts)
+	} else {
+		results = vs.filterSearchBruteForce(g, query, docs, opts)
+	}
+
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
+
+// filterSearchHNSW 在 HNSW 图上搜索，只接受候选文档对应的节点。调用方需持有 vs.mu 读锁。
+func (vs *VectorSearch) filterSearchHNSW(g *hnswIndex, query Vector, candidates map[string]Document, opts VectorSearchOptions) []VectorSearchResult {
+	// 将候选 ID 转换为按节点下标寻址的位图，遍历时 O(1) 判断
+	bits := make([]uint64, (len(g.nodes)+63)/64)
+	for id := range candidates {
+		if node, ok := g.ids[id]; ok {
+			bits[node/64] |= 1 << (uint(node) % 64)
+		}
+	}
+
+	k := opts.Limit
+	if k <= 0 {
+		k = 10
+	}
+	var results []VectorSearchResult
+	for _, c := range g.SearchFiltered(query, k, vs.hnswEfSearch, func(node int32) bool {
+		return bits[node/64]&(1<<(uint(node)%64)) != 0
+	}) {
+		if result, ok := vs.filterSearchResult(candidates[g.nodes[c.node].ID], c.dist, opts); ok {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// filterSearchBruteForce 逐个计算候选文档与查询向量的距离。启用 HNSW 时向量直接取自图节点。
+// 调用方需持有 vs.mu 读锁。
+func (vs *VectorSearch) filterSearchBruteForce(g *hnswIndex, query Vector, docs []Document, opts VectorSearchOptions) []VectorSearchResult {
+	var results []VectorSearchResult
+	for _, doc := range docs {
+		if _, removed := vs.removedVectors[doc.ID()]; removed {
+			continue
+		}
+		var embedding Vector
+		if g != nil {
+			node, ok := g.ids[doc.ID()]
+			if !ok {
+				continue
+			}
+			embedding = g.nodes[node].Vector
+		} else if vec, ok := vs.manualVectors[doc.ID()]; ok {
+			embedding = vec
+		} else {
+			var err error
+			if embedding, err = vs.getEmbeddingWithCache(doc.ID(), doc.Data()); err != nil {
+				continue
+			}
+		}
+		if len(embedding) != vs.dimensions {
+			continue
+		}
+		if result, ok := vs.filterSearchResult(doc, vs.calculateDistance(query, embedding), opts); ok {
+			results = append(results, result)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Distance < results[j].Distance
+	})
+	if opts.Limit <= 0 && len(results) > 10 {
+		results = results[:10]
+	}
+	return results
+}
+
+// filterSearchResult 应用 MaxDistance 与 MinScore 阈值并构造结果。
+func (vs *VectorSearch) filterSearchResult(doc Document, distance float64, opts VectorSearchOptions) (VectorSearchResult, bool) {
+	if doc == nil || (opts.MaxDistance > 0 && distance > opts.MaxDistance) {
+		return VectorSearchResult{}, false
+	}
+	score := vs.distanceToScore(distance)
+	if opts.MinScore > 0 && score < opts.MinScore {
+		return VectorSearchResult{}, false
+	}
+	return VectorSearchResult{Document: doc, Distance: distance, Score: score}, true
+}
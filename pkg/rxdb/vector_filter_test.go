@@ -0,0 +1,135 @@
+package rxdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestVectorSearch_FilterSearch(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "products", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+		Indexes:    []Index{{Fields: []string{"category"}}},
+	})
+
+	// 2400 个文档：electronics 与 books 各 1190 个，toys 20 个
+	vectors := randomVectors(2400, 8, 11)
+	category := func(i int) string {
+		switch {
+		case i%120 == 0:
+			return "toys"
+		case i%2 == 0:
+			return "electronics"
+		default:
+			return "books"
+		}
+	}
+	docs := make([]map[string]any, len(vectors))
+	for i, v := range vectors {
+		docs[i] = map[string]any{"id": fmt.Sprintf("p%d", i), "category": category(i), "embedding": v}
+	}
+	if _, err := coll.BulkInsert(ctx, docs); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+
+	embed := func(doc map[string]any) (Vector, error) { return toFloat64Slice(doc["embedding"]), nil }
+	hnswSearch, err := AddVectorSearch(coll, VectorSearchConfig{
+		Identifier:     "filter-hnsw",
+		Dimensions:     8,
+		DistanceMetric: "euclidean",
+		IndexType:      "hnsw",
+		EfSearch:       64,
+		DocToEmbedding: embed,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create vector search: %v", err)
+	}
+	defer hnswSearch.Close()
+	flatSearch, err := AddVectorSearch(coll, VectorSearchConfig{
+		Identifier:     "filter-flat",
+		Dimensions:     8,
+		DistanceMetric: "euclidean",
+		DocToEmbedding: embed,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create vector search: %v", err)
+	}
+	defer flatSearch.Close()
+
+	// 过滤子集内的精确最近邻
+	groundTruth := func(query Vector, cat string, k int) []string {
+		var subset []Vector
+		var ids []string
+		for i, v := range vectors {
+			if category(i) == cat {
+				subset = append(subset, v)
+				ids = append(ids, fmt.Sprintf("p%d", i))
+			}
+		}
+		var out []string
+		for _, i := range bruteForceKNN(subset, query, k) {
+			out = append(out, ids[i])
+		}
+		return out
+	}
+
+	queries := randomVectors(20, 8, 12)
+	for _, cat := range []string{"electronics", "toys"} {
+		for name, vs := range map[string]*VectorSearch{"hnsw": hnswSearch, "flat": flatSearch} {
+			var hits, total int
+			for _, q := range queries {
+				results, err := vs.FilterSearch(ctx, q, map[string]any{"category": cat}, VectorSearchOptions{Limit: 10})
+				if err != nil {
+					t.Fatalf("FilterSearch failed: %v", err)
+				}
+				if len(results) != 10 {
+					t.Fatalf("%s/%s: expected 10 results, got %d", name, cat, len(results))
+				}
+				want := make(map[string]bool)
+				for _, id := range groundTruth(q, cat, 10) {
+					want[id] = true
+				}
+				for i, r := range results {
+					if got := r.Document.Data()["category"]; got != cat {
+						t.Fatalf("%s/%s: result %s has category %v", name, cat, r.Document.ID(), got)
+					}
+					if i > 0 && r.Distance < results[i-1].Distance {
+						t.Errorf("%s/%s: results not sorted by distance", name, cat)
+					}
+					if want[r.Document.ID()] {
+						hits++
+					}
+				}
+				total += 10
+			}
+			recall := float64(hits) / float64(total)
+			// 暴力路径（flat 与 toys 小子集）结果精确，HNSW 路径召回率应与无过滤搜索相当
+			minRecall := 1.0
+			if name == "hnsw" && cat == "electronics" {
+				minRecall = 0.95
+			}
+			if recall < minRecall {
+				t.Errorf("%s/%s: expected recall >= %.2f, got %.3f", name, cat, minRecall, recall)
+			}
+		}
+	}
+
+	// Selector 与 filter 同时生效
+	results, err := hnswSearch.FilterSearch(ctx, queries[0], map[string]any{"category": "toys"}, VectorSearchOptions{
+		Limit:    50,
+		Selector: map[string]any{"id": map[string]any{"$in": []any{"p0", "p120", "p1"}}},
+	})
+	if err != nil {
+		t.Fatalf("FilterSearch failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results for combined filter, got %v", resultIDs(results))
+	}
+
+	if _, err := hnswSearch.FilterSearch(ctx, Vector{1, 2}, map[string]any{}, VectorSearchOptions{}); err == nil {
+		t.Error("Expected dimension mismatch error")
+	}
+}
@@ -0,0 +1,622 @@
+package rxdb
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HNSW 索引参数的默认值。
+const (
+	defaultHNSWM              = 16
+	defaultHNSWEfConstruction = 200
+	defaultHNSWEfSearch       = 50
+)
+
+// hnswChunkSize 持久化时单个存储值的最大字节数，避免超出 Badger 单事务大小限制。
+const hnswChunkSize = 1 << 20
+
+// hnswNode HNSW 图中的节点。被删除的节点保留在图中继续参与导航，但不会出现在搜索结果里。
+type hnswNode struct {
+	ID      string
+	Rev     string // 写入时文档的修订号，重启后据此判断向量是否需要重新生成
+	Vector  Vector
+	Friends [][]int32 // 每层的邻居节点下标，长度为节点层数 + 1
+	Deleted bool
+}
+
+// hnswCandidate 搜索过程中的候选节点。
+type hnswCandidate struct {
+	node int32
+	dist float64
+}
+
+// hnswIndex 分层可导航小世界图（Hierarchical Navigable Small World）近似最近邻索引。
+// 非并发安全，由 VectorSearch.mu 保护。
+type hnswIndex struct {
+	m              int
+	efConstruction int
+	efSearch       int
+	levelMult      float64
+	distance       func(a, b Vector) float64
+
+	nodes    []hnswNode
+	ids      map[string]int32 // 未删除节点的 ID -> 下标
+	entry    int32            // 入口节点，-1 表示空图
+	maxLevel int
+	deleted  int
+	rng      *rand.Rand
+}
+
+func newHNSWIndex(m, efConstruction, efSearch int, distance func(a, b Vector) float64) *hnswIndex {
+	return &hnswIndex{
+		m:              m,
+		efConstruction: efConstruction,
+		efSearch:       efSearch,
+		levelMult:      1 / math.Log(float64(m)),
+		distance:       distance,
+		ids:            make(map[string]int32),
+		entry:          -1,
+		rng:            rand.New(rand.NewSource(1)),
+	}
+}
+
+// Len 返回未删除的向量数量。
+func (h *hnswIndex) Len() int {
+	return len(h.ids)
+}
+
+// upToDate 判断 id 的向量是否以修订号 rev 写入，rev 为空时总是返回 false。
+func (h *hnswIndex) upToDate(id, rev string) bool {
+	i, ok := h.ids[id]
+	return ok && rev != "" && h.nodes[i].Rev == rev
+}
+
+// Insert 写入或替换 id 的向量。
+func (h *hnswIndex) Insert(id, rev string, vec Vector) {
+	if h.remove(id) {
+		h.maybeCompact()
+	}
+
+	level := int(math.Floor(-math.Log(1-h.rng.Float64()) * h.levelMult))
+	n := int32(len(h.nodes))
+	h.nodes = append(h.nodes, hnswNode{ID: id, Rev: rev, Vector: vec, Friends: make([][]int32, level+1)})
+	h.ids[id] = n
+	if h.entry < 0 {
+		h.entry, h.maxLevel = n, level
+		return
+	}
+
+	ep := hnswCandidate{node: h.entry, dist: h.distance(vec, h.nodes[h.entry].Vector)}
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.greedy(vec, ep, l)
+	}
+	eps := []hnswCandidate{ep}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(vec, eps, h.efConstruction, l, nil)
+		neighbors := h.selectNeighbors(candidates, h.m)
+		friends := make([]int32, len(neighbors))
+		for i, c := range neighbors {
+			friends[i] = c.node
+			h.link(c.node, n, l)
+		}
+		h.nodes[n].Friends[l] = friends
+		eps = candidates
+	}
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = n, level
+	}
+}
+
+// Delete 删除 id 的向量。
+func (h *hnswIndex) Delete(id string) bool {
+	if !h.remove(id) {
+		return false
+	}
+	h.maybeCompact()
+	return true
+}
+
+// maybeCompact 在已删除节点多于存活节点时压缩图，避免失效节点拖慢搜索。
+func (h *hnswIndex) maybeCompact() {
+	if h.deleted > len(h.ids) && h.deleted >= 64 {
+		h.compact()
+	}
+}
+
+// remove 将节点标记为已删除。
+func (h *hnswIndex) remove(id string) bool {
+	i, ok := h.ids[id]
+	if !ok {
+		return false
+	}
+	h.nodes[i].Deleted = true
+	delete(h.ids, id)
+	h.deleted++
+	return true
+}
+
+// compact 按写入顺序重新插入所有存活节点，丢弃已删除节点。
+func (h *hnswIndex) compact() {
+	nodes := h.nodes
+	fresh := newHNSWIndex(h.m, h.efConstruction, h.efSearch, h.distance)
+	fresh.rng = h.rng
+	for _, node := range nodes {
+		if !node.Deleted {
+			fresh.Insert(node.ID, node.Rev, node.Vector)
+		}
+	}
+	*h = *fresh
+}
+
+// Search 返回与 query 最近的 k 个未删除节点（按距离升序），ef 为搜索时的候选队列大小。
+func (h *hnswIndex) Search(query Vector, k, ef int) []hnswCandidate {
+	return h.SearchFiltered(query, k, ef, nil)
+}
+
+// SearchFiltered 与 Search 相同，但只返回 accept 接受的节点（accept 为 nil 时接受全部）。
+// 被拒绝的节点仍参与图遍历，过滤条件越严格，需要访问的节点越多。
+func (h *hnswIndex) SearchFiltered(query Vector, k, ef int, accept func(node int32) bool) []hnswCandidate {
+	if h.entry < 0 || k <= 0 {
+		return nil
+	}
+	ep := hnswCandidate{node: h.entry, dist: h.distance(query, h.nodes[h.entry].Vector)}
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.greedy(query, ep, l)
+	}
+	candidates := h.searchLayer(query, []hnswCandidate{ep}, max(ef, k), 0, func(node int32) bool {
+		return !h.nodes[node].Deleted && (accept == nil || accept(node))
+	})
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	return candidates
+}
+
+// greedy 在第 level 层从 ep 出发贪心移动到离 query 最近的节点。
+func (h *hnswIndex) greedy(query Vector, ep hnswCandidate, level int) hnswCandidate {
+	for changed := true; changed; {
+		changed = false
+		for _, f := range h.nodes[ep.node].Friends[level] {
+			if d := h.distance(query, h.nodes[f].Vector); d < ep.dist {
+				ep = hnswCandidate{node: f, dist: d}
+				changed = true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer 在第 level 层执行束搜索，返回 accept 接受的最多 ef 个最近节点（按距离升序）。
+// accept 为 nil 时接受全部节点（包括已删除节点，建图时需要经过它们连接）。
+func (h *hnswIndex) searchLayer(query Vector, eps []hnswCandidate, ef, level int, accept func(node int32) bool) []hnswCandidate {
+	visited := make(map[int32]struct{}, ef*h.m)
+	candidates := &hnswMinHeap{}
+	results := &hnswMaxHeap{}
+	for _, ep := range eps {
+		if _, ok := visited[ep.node]; ok {
+			continue
+		}
+		visited[ep.node] = struct{}{}
+		heap.Push(candidates, ep)
+		if accept == nil || accept(ep.node) {
+			heap.Push(results, ep)
+			if results.Len() > ef {
+				heap.Pop(results)
+			}
+		}
+	}
+
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(hnswCandidate)
+		if results.Len() >= ef && c.dist > (*results)[0].dist {
+			break
+		}
+		for _, f := range h.nodes[c.node].Friends[level] {
+			if _, ok := visited[f]; ok {
+				continue
+			}
+			visited[f] = struct{}{}
+			d := h.distance(query, h.nodes[f].Vector)
+			if results.Len() < ef || d < (*results)[0].dist {
+				heap.Push(candidates, hnswCandidate{node: f, dist: d})
+				if accept == nil || accept(f) {
+					heap.Push(results, hnswCandidate{node: f, dist: d})
+					if results.Len() > ef {
+						heap.Pop(results)
+					}
+				}
+			}
+		}
+	}
+
+	out := make([]hnswCandidate, results.Len())
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(results).(hnswCandidate)
+	}
+	return out
+}
+
+// selectNeighbors 按启发式规则从升序候选中选出至多 m 个邻居：
+// 优先保留离基准节点比离已选邻居更近的候选以覆盖不同方向，不足时再用被跳过的候选补齐。
+func (h *hnswIndex) selectNeighbors(candidates []hnswCandidate, m int) []hnswCandidate {
+	if len(candidates) <= m {
+		return candidates
+	}
+	selected := make([]hnswCandidate, 0, m)
+	var skipped []hnswCandidate
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		diverse := true
+		for _, s := range selected {
+			if h.distance(h.nodes[c.node].Vector, h.nodes[s.node].Vector) < c.dist {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c)
+		} else {
+			skipped = append(skipped, c)
+		}
+	}
+	for _, c := range skipped {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, c)
+	}
+	return selected
+}
+
+// link 在第 level 层为节点 from 添加邻居 to，超出上限时重新挑选邻居。
+func (h *hnswIndex) link(from, to int32, level int) {
+	node := &h.nodes[from]
+	node.Friends[level] = append(node.Friends[level], to)
+
+	limit := h.m
+	if level == 0 {
+		limit = 2 * h.m
+	}
+	if len(node.Friends[level]) <= limit {
+		return
+	}
+	candidates := make([]hnswCandidate, len(node.Friends[level]))
+	for i, f := range node.Friends[level] {
+		candidates[i] = hnswCandidate{node: f, dist: h.distance(node.Vector, h.nodes[f].Vector)}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+	selected := h.selectNeighbors(candidates, limit)
+	friends := make([]int32, len(selected))
+	for i, c := range selected {
+		friends[i] = c.node
+	}
+	node.Friends[level] = friends
+}
+
+// hnswSnapshot HNSW 图的序列化格式。
+type hnswSnapshot struct {
+	Dimensions     int
+	DistanceMetric string
+	M              int
+	EfConstruction int
+	Entry          int32
+	MaxLevel       int
+	Nodes          []hnswNode
+}
+
+// marshal 序列化图结构，dimensions 与 metric 用于加载时校验兼容性。
+func (h *hnswIndex) marshal(dimensions int, metric string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(hnswSnapshot{
+		Dimensions:     dimensions,
+		DistanceMetric: metric,
+		M:              h.m,
+		EfConstruction: h.efConstruction,
+		Entry:          h.entry,
+		MaxLevel:       h.maxLevel,
+		Nodes:          h.nodes,
+	})
+	return buf.Bytes(), err
+}
+
+// unmarshalHNSWIndex 反序列化图结构，参数或维度与当前配置不一致时返回错误。
+func unmarshalHNSWIndex(data []byte, dimensions int, metric string, m, efConstruction, efSearch int, distance func(a, b Vector) float64) (*hnswIndex, error) {
+	var snap hnswSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("failed to decode hnsw graph: %w", err)
+	}
+	if snap.Dimensions != dimensions {
+		return nil, &DimensionMismatchError{Expected: dimensions, Actual: snap.Dimensions}
+	}
+	if !sameDistanceMetric(snap.DistanceMetric, metric) {
+		return nil, &MetricMismatchError{Expected: metric, Actual: snap.DistanceMetric}
+	}
+	if snap.M != m || snap.EfConstruction != efConstruction {
+		return nil, fmt.Errorf("hnsw parameters changed: M %d -> %d, EfConstruction %d -> %d", snap.M, m, snap.EfConstruction, efConstruction)
+	}
+
+	h := newHNSWIndex(m, efConstruction, efSearch, distance)
+	h.nodes = snap.Nodes
+	h.entry = snap.Entry
+	h.maxLevel = snap.MaxLevel
+	for i, node := range h.nodes {
+		if node.Deleted {
+			h.deleted++
+			continue
+		}
+		h.ids[node.ID] = int32(i)
+	}
+	if len(h.nodes) == 0 {
+		h.entry = -1
+	}
+	return h, nil
+}
+
+type hnswMinHeap []hnswCandidate
+
+func (h hnswMinHeap) Len() int           { return len(h) }
+func (h hnswMinHeap) Less(i, j int) bool { return h[i].dist < h[j].dist }
+func (h hnswMinHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hnswMinHeap) Push(x any)        { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswMinHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type hnswMaxHeap []hnswCandidate
+
+func (h hnswMaxHeap) Len() int           { return len(h) }
+func (h hnswMaxHeap) Less(i, j int) bool { return h[i].dist > h[j].dist }
+func (h hnswMaxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hnswMaxHeap) Push(x any)        { *h = append(*h, x.(hnswCandidate)) }
+func (h *hnswMaxHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// hnswGraph 返回分区对应的 HNSW 图，必要时创建；未启用 HNSW 时返回 nil。调用方需持有 vs.mu 写锁。
+func (vs *VectorSearch) hnswGraph(partition string) *hnswIndex {
+	if vs.hnsw == nil {
+		return nil
+	}
+	g, ok := vs.hnsw[partition]
+	if !ok {
+		g = newHNSWIndex(vs.hnswM, vs.hnswEfConstruction, vs.hnswEfSearch, vs.calculateDistance)
+		vs.hnsw[partition] = g
+	}
+	return g
+}
+
+// hnswDelete 从所有分区的 HNSW 图中删除 id，except 分区除外。调用方需持有 vs.mu 写锁。
+func (vs *VectorSearch) hnswDelete(id string, except *hnswIndex) {
+	for _, g := range vs.hnsw {
+		if g != except {
+			g.Delete(id)
+		}
+	}
+}
+
+// docRevision 返回文档的修订号字符串。
+func (vs *VectorSearch) docRevision(data map[string]any) string {
+	if rev, ok := data[vs.collection.schema.RevField]; ok && rev != nil {
+		return fmt.Sprint(rev)
+	}
+	return ""
+}
+
+// searchHNSW 使用 HNSW 图执行搜索，距离为按配置度量计算的精确值。调用方需持有 vs.mu 读锁。
+func (vs *VectorSearch) searchHNSW(ctx context.Context, queryEmbedding Vector, opts VectorSearchOptions) ([]VectorSearchResult, error) {
+	g := vs.hnsw[opts.Partition]
+	if vs.partitionField == "" {
+		g = vs.hnsw[""]
+	}
+	if g == nil {
+		return []VectorSearchResult{}, nil
+	}
+
+	k := opts.Limit
+	if k <= 0 {
+		k = 10
+	}
+	var results []VectorSearchResult
+	for _, c := range g.Search(queryEmbedding, k, vs.hnswEfSearch) {
+		if opts.MaxDistance > 0 && c.dist > opts.MaxDistance {
+			continue
+		}
+		score := vs.distanceToScore(c.dist)
+		if opts.MinScore > 0 && score < opts.MinScore {
+			continue
+		}
+		doc, err := vs.collection.FindByID(ctx, g.nodes[c.node].ID)
+		if err != nil {
+			continue
+		}
+		results = append(results, VectorSearchResult{
+			Document: doc,
+			Distance: c.dist,
+			Score:    score,
+		})
+	}
+	return results, nil
+}
+
+// rebuildHNSW 丢弃现有 HNSW 图，按集合文档（及手动写入的向量）重新构建。调用方需持有 vs.mu 写锁。
+func (vs *VectorSearch) rebuildHNSW(ctx context.Context) error {
+	if vs.hnsw == nil {
+		return nil
+	}
+	vs.hnsw = make(map[string]*hnswIndex)
+
+	docs, err := vs.collection.all(ctx)
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if _, removed := vs.removedVectors[doc.ID()]; removed {
+			continue
+		}
+		partition := ""
+		if vs.partitionField != "" {
+			if p, ok := doc.Data()[vs.partitionField].(string); ok {
+				partition = p
+			}
+		}
+		rev := vs.docRevision(doc.Data())
+		embedding, ok := vs.manualVectors[doc.ID()]
+		if ok {
+			rev = ""
+		} else if embedding, err = vs.docToEmbedding(doc.Data()); err != nil {
+			continue
+		}
+		if len(embedding) != vs.dimensions {
+			continue
+		}
+		vs.hnswGraph(partition).Insert(doc.ID(), rev, embedding)
+	}
+	return nil
+}
+
+// hnswBucket 返回保存 HNSW 图的存储 bucket。
+func (vs *VectorSearch) hnswBucket() string {
+	return fmt.Sprintf("%s_vector_hnsw", vs.collection.name)
+}
+
+// hnswKeyPrefix 返回分区图在 bucket 中的键前缀，分区名经过转义以免包含分隔符。
+func (vs *VectorSearch) hnswKeyPrefix(partition string) string {
+	return vs.identifier + "/" + url.PathEscape(partition) + "/"
+}
+
+// hnswManifest 持久化的 HNSW 图元信息。图数据按代写入多个分块，元信息最后写入，
+// 因此中途失败时仍能加载上一代完整的数据。
+type hnswManifest struct {
+	Generation int64 `json:"generation"`
+	Chunks     int   `json:"chunks"`
+}
+
+// persistHNSW 将所有分区的 HNSW 图写入存储，并清理旧代的分块。
+func (vs *VectorSearch) persistHNSW(ctx context.Context) error {
+	vs.mu.RLock()
+	snapshots := make(map[string][]byte, len(vs.hnsw))
+	for partition, g := range vs.hnsw {
+		data, err := g.marshal(vs.dimensions, vs.distanceMetric)
+		if err != nil {
+			vs.mu.RUnlock()
+			return fmt.Errorf("failed to encode hnsw graph: %w", err)
+		}
+		snapshots[partition] = data
+	}
+	vs.mu.RUnlock()
+
+	store := vs.collection.store
+	bucket := vs.hnswBucket()
+	generation := time.Now().UnixNano()
+	keep := make(map[string]bool)
+	for partition, data := range snapshots {
+		prefix := vs.hnswKeyPrefix(partition)
+		chunks := 0
+		for off := 0; off < len(data); off += hnswChunkSize {
+			end := min(off+hnswChunkSize, len(data))
+			if err := store.Set(ctx, bucket, fmt.Sprintf("%s%d/%06d", prefix, generation, chunks), data[off:end]); err != nil {
+				return fmt.Errorf("failed to persist hnsw graph: %w", err)
+			}
+			chunks++
+		}
+		manifest, _ := json.Marshal(hnswManifest{Generation: generation, Chunks: chunks})
+		if err := store.Set(ctx, bucket, prefix+"manifest", manifest); err != nil {
+			return fmt.Errorf("failed to persist hnsw manifest: %w", err)
+		}
+		keep[prefix+"manifest"] = true
+	}
+
+	// 删除旧代分块以及已不存在的分区
+	var stale []string
+	err := store.Iterate(ctx, bucket, func(key, _ []byte) error {
+		k := string(key)
+		if strings.HasPrefix(k, vs.identifier+"/") && !keep[k] && !strings.Contains(k, fmt.Sprintf("/%d/", generation)) {
+			stale = append(stale, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range stale {
+		if err := store.Delete(ctx, bucket, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadHNSW 从存储加载持久化的 HNSW 图。与当前配置不兼容的图被忽略，之后由 buildIndex 重建。
+func (vs *VectorSearch) loadHNSW(ctx context.Context) error {
+	store := vs.collection.store
+	bucket := vs.hnswBucket()
+	var partitions []string
+	err := store.Iterate(ctx, bucket, func(key, _ []byte) error {
+		k := string(key)
+		if strings.HasPrefix(k, vs.identifier+"/") && strings.HasSuffix(k, "/manifest") {
+			escaped := strings.TrimSuffix(strings.TrimPrefix(k, vs.identifier+"/"), "/manifest")
+			if partition, err := url.PathUnescape(escaped); err == nil {
+				partitions = append(partitions, partition)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	graphs := make(map[string]*hnswIndex, len(partitions))
+	for _, partition := range partitions {
+		prefix := vs.hnswKeyPrefix(partition)
+		raw, err := store.Get(ctx, bucket, prefix+"manifest")
+		if err != nil || raw == nil {
+			continue
+		}
+		var manifest hnswManifest
+		if err := json.Unmarshal(raw, &manifest); err != nil {
+			continue
+		}
+		var data []byte
+		complete := true
+		for i := 0; i < manifest.Chunks; i++ {
+			chunk, err := store.Get(ctx, bucket, fmt.Sprintf("%s%d/%06d", prefix, manifest.Generation, i))
+			if err != nil || chunk == nil {
+				complete = false
+				break
+			}
+			data = append(data, chunk...)
+		}
+		if !complete {
+			continue
+		}
+		g, err := unmarshalHNSWIndex(data, vs.dimensions, vs.distanceMetric, vs.hnswM, vs.hnswEfConstruction, vs.hnswEfSearch, vs.calculateDistance)
+		if err != nil {
+			vs.collection.logger.Debug("Discarding persisted hnsw graph", "identifier", vs.identifier, "partition", partition, "error", err)
+			continue
+		}
+		graphs[partition] = g
+	}
+
+	vs.mu.Lock()
+	vs.hnsw = graphs
+	vs.mu.Unlock()
+	return nil
+}
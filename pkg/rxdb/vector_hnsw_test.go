@@ -0,0 +1,305 @@
+package rxdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// bruteForceKNN 返回与 query 距离最近的 k 个向量下标，作为召回率的基准。
+func bruteForceKNN(vectors []Vector, query Vector, k int) []int {
+	idx := make([]int, len(vectors))
+	dist := make([]float64, len(vectors))
+	for i, v := range vectors {
+		idx[i] = i
+		dist[i] = EuclideanDistance(query, v)
+	}
+	sort.Slice(idx, func(a, b int) bool { return dist[idx[a]] < dist[idx[b]] })
+	if len(idx) > k {
+		idx = idx[:k]
+	}
+	return idx
+}
+
+// hnswRecall 计算 HNSW 搜索结果相对暴力搜索的平均召回率。
+func hnswRecall(g *hnswIndex, vectors, queries []Vector, k int) float64 {
+	var hits, total int
+	for _, q := range queries {
+		want := make(map[string]bool, k)
+		for _, i := range bruteForceKNN(vectors, q, k) {
+			want[fmt.Sprintf("v%d", i)] = true
+		}
+		for _, c := range g.Search(q, k, g.efSearch) {
+			if want[g.nodes[c.node].ID] {
+				hits++
+			}
+		}
+		total += len(want)
+	}
+	return float64(hits) / float64(total)
+}
+
+func newTestHNSW(vectors []Vector, m, efConstruction, efSearch int) *hnswIndex {
+	g := newHNSWIndex(m, efConstruction, efSearch, EuclideanDistance)
+	for i, v := range vectors {
+		g.Insert(fmt.Sprintf("v%d", i), "", v)
+	}
+	return g
+}
+
+func TestHNSWIndex_Recall(t *testing.T) {
+	vectors := randomVectors(3000, 16, 1)
+	queries := randomVectors(50, 16, 2)
+	g := newTestHNSW(vectors, 16, 100, 64)
+
+	if g.Len() != len(vectors) {
+		t.Fatalf("Expected %d nodes, got %d", len(vectors), g.Len())
+	}
+	if recall := hnswRecall(g, vectors, queries, 10); recall < 0.95 {
+		t.Errorf("Expected recall >= 0.95, got %.3f", recall)
+	}
+}
+
+func TestHNSWIndex_DeleteAndReplace(t *testing.T) {
+	vectors := randomVectors(500, 8, 3)
+	g := newTestHNSW(vectors, 8, 64, 64)
+
+	// 删除全部偶数向量后，结果中不应出现已删除的 ID，并触发压缩
+	for i := 0; i < len(vectors); i += 2 {
+		if !g.Delete(fmt.Sprintf("v%d", i)) {
+			t.Fatalf("Expected v%d to be deleted", i)
+		}
+	}
+	g.Delete("v1")
+	if g.Len() != 249 {
+		t.Fatalf("Expected 249 nodes, got %d", g.Len())
+	}
+	if len(g.nodes) >= len(vectors) {
+		t.Errorf("Expected graph to be compacted, still has %d nodes", len(g.nodes))
+	}
+	for _, c := range g.Search(vectors[0], 20, 64) {
+		id := g.nodes[c.node].ID
+		var n int
+		fmt.Sscanf(id, "v%d", &n)
+		if n%2 == 0 || n == 1 {
+			t.Errorf("Deleted vector %s returned", id)
+		}
+	}
+
+	// 替换向量后按新位置检索
+	g.Insert("v3", "2", vectors[0])
+	results := g.Search(vectors[0], 1, 64)
+	if len(results) != 1 || g.nodes[results[0].node].ID != "v3" || results[0].dist != 0 {
+		t.Errorf("Expected replaced v3 as nearest neighbour, got %+v", results)
+	}
+	if !g.upToDate("v3", "2") || g.upToDate("v3", "1") || g.upToDate("v5", "") {
+		t.Error("Unexpected upToDate result")
+	}
+}
+
+func TestHNSWIndex_Marshal(t *testing.T) {
+	vectors := randomVectors(200, 4, 4)
+	g := newTestHNSW(vectors, 8, 32, 32)
+	g.Delete("v7")
+
+	data, err := g.marshal(4, "euclidean")
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	loaded, err := unmarshalHNSWIndex(data, 4, "l2", 8, 32, 32, EuclideanDistance)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if loaded.Len() != g.Len() {
+		t.Fatalf("Expected %d nodes, got %d", g.Len(), loaded.Len())
+	}
+	q := randomVectors(1, 4, 5)[0]
+	a, b := g.Search(q, 5, 32), loaded.Search(q, 5, 32)
+	if fmt.Sprint(a) != fmt.Sprint(b) {
+		t.Errorf("Expected identical results after reload: %v vs %v", a, b)
+	}
+
+	if _, err := unmarshalHNSWIndex(data, 5, "euclidean", 8, 32, 32, EuclideanDistance); err == nil {
+		t.Error("Expected dimension mismatch error")
+	}
+	if _, err := unmarshalHNSWIndex(data, 4, "cosine", 8, 32, 32, EuclideanDistance); err == nil {
+		t.Error("Expected metric mismatch error")
+	}
+	if _, err := unmarshalHNSWIndex(data, 4, "euclidean", 16, 32, 32, EuclideanDistance); err == nil {
+		t.Error("Expected parameter mismatch error")
+	}
+}
+
+func TestVectorSearch_HNSW(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "hnsw_docs", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	vectors := randomVectors(300, 8, 6)
+	for i, v := range vectors {
+		if _, err := coll.Insert(ctx, map[string]any{"id": fmt.Sprintf("d%d", i), "embedding": v}); err != nil {
+			t.Fatalf("Failed to insert: %v", err)
+		}
+	}
+
+	config := VectorSearchConfig{
+		Identifier:     "hnsw",
+		Dimensions:     8,
+		DistanceMetric: "euclidean",
+		IndexType:      "hnsw",
+		CacheSize:      -1,
+		DocToEmbedding: func(doc map[string]any) (Vector, error) {
+			return toFloat64Slice(doc["embedding"]), nil
+		},
+	}
+	vs, err := AddVectorSearch(coll, config)
+	if err != nil {
+		t.Fatalf("Failed to create vector search: %v", err)
+	}
+
+	query := randomVectors(1, 8, 7)[0]
+	explain, err := vs.ExplainSearch(ctx, query, VectorSearchOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	want := bruteForceKNN(vectors, query, 5)
+	if len(explain.TopK) != 5 {
+		t.Fatalf("Expected 5 results, got %d", len(explain.TopK))
+	}
+	for i, entry := range explain.TopK {
+		if entry.IndexType != VectorIndexTypeHNSW {
+			t.Errorf("Expected hnsw index type, got %s", entry.IndexType)
+		}
+		if entry.Document.ID() != fmt.Sprintf("d%d", want[i]) {
+			t.Errorf("Result %d: expected d%d, got %s", i, want[i], entry.Document.ID())
+		}
+	}
+
+	// 变更事件同步到图中
+	if err := coll.Remove(ctx, fmt.Sprintf("d%d", want[0])); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	graphLen := func() int {
+		vs.mu.RLock()
+		defer vs.mu.RUnlock()
+		return vs.hnsw[""].Len()
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for graphLen() != 299 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := graphLen(); got != 299 {
+		t.Fatalf("Expected 299 vectors after remove, got %d", got)
+	}
+
+	if err := vs.Persist(ctx); err != nil {
+		t.Fatalf("Failed to persist: %v", err)
+	}
+	vs.Close()
+
+	// 重启后加载持久化的图，并补写关闭期间插入的文档
+	if _, err := coll.Insert(ctx, map[string]any{"id": "new", "embedding": query}); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	vs2, err := AddVectorSearch(coll, config)
+	if err != nil {
+		t.Fatalf("Failed to reopen vector search: %v", err)
+	}
+	defer vs2.Close()
+	if got := vs2.hnsw[""].Len(); got != 300 {
+		t.Errorf("Expected 300 vectors after reload, got %d", got)
+	}
+
+	results, err := vs2.Search(ctx, query, VectorSearchOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if len(results) != 2 || results[0].Document.ID() != "new" || results[1].Document.ID() != fmt.Sprintf("d%d", want[1]) {
+		t.Errorf("Unexpected results after reload: %v", resultIDs(results))
+	}
+
+	// UseFullScan 绕过 HNSW 图
+	full, err := vs2.Search(ctx, query, VectorSearchOptions{Limit: 2, UseFullScan: true})
+	if err != nil {
+		t.Fatalf("Failed to search: %v", err)
+	}
+	if fmt.Sprint(resultIDs(full)) != fmt.Sprint(resultIDs(results)) {
+		t.Errorf("Expected full scan to agree with hnsw: %v vs %v", resultIDs(full), resultIDs(results))
+	}
+
+	if _, err := AddVectorSearch(coll, VectorSearchConfig{Identifier: "bad", Dimensions: 8, IndexType: "lsh", DocToEmbedding: config.DocToEmbedding}); err == nil {
+		t.Error("Expected error for unsupported index type")
+	}
+}
+
+// toFloat64Slice 将文档中的数值数组转换为向量。
+func toFloat64Slice(v any) Vector {
+	switch arr := v.(type) {
+	case []float64:
+		return arr
+	case []any:
+		out := make(Vector, len(arr))
+		for i, x := range arr {
+			out[i], _ = x.(float64)
+		}
+		return out
+	}
+	return nil
+}
+
+func resultIDs(results []VectorSearchResult) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.Document.ID()
+	}
+	return ids
+}
+
+// hnswBenchCache 缓存已构建的基准数据，避免每次调整 b.N 时重新建图。
+var hnswBenchCache = struct {
+	sync.Mutex
+	graphs  map[int]*hnswIndex
+	vectors map[int][]Vector
+}{graphs: map[int]*hnswIndex{}, vectors: map[int][]Vector{}}
+
+func hnswBenchData(n int) (*hnswIndex, []Vector) {
+	hnswBenchCache.Lock()
+	defer hnswBenchCache.Unlock()
+	if g, ok := hnswBenchCache.graphs[n]; ok {
+		return g, hnswBenchCache.vectors[n]
+	}
+	vectors := randomVectors(n, 16, 42)
+	g := newTestHNSW(vectors, 16, 100, 80)
+	hnswBenchCache.graphs[n] = g
+	hnswBenchCache.vectors[n] = vectors
+	return g, vectors
+}
+
+// BenchmarkVectorSearch_HNSW 对比暴力搜索与 HNSW 的查询耗时：
+// 数据量增加 10 倍时 HNSW 的单次查询耗时应远小于 10 倍，且召回率不低于 0.95。
+func BenchmarkVectorSearch_HNSW(b *testing.B) {
+	queries := randomVectors(100, 16, 43)
+	for _, n := range []int{10000, 100000} {
+		g, vectors := hnswBenchData(n)
+
+		b.Run(fmt.Sprintf("flat-%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				bruteForceKNN(vectors, queries[i%len(queries)], 10)
+			}
+		})
+		b.Run(fmt.Sprintf("hnsw-%d", n), func(b *testing.B) {
+			recall := hnswRecall(g, vectors, queries, 10)
+			if recall < 0.95 {
+				b.Fatalf("Expected recall >= 0.95 at %d vectors, got %.3f", n, recall)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				g.Search(queries[i%len(queries)], 10, g.efSearch)
+			}
+			b.ReportMetric(recall, "recall")
+		})
+	}
+}
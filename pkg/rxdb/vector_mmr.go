@@ -0,0 +1,103 @@
+package rxdb
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// mmrCandidateFactor 启用 MMR 时候选集合相对 Limit 的倍数。
+const mmrCandidateFactor = 4
+
+// SearchMMR 返回 k 个兼顾相似度与多样性的结果，等价于 Search 时设置 MMR 为 true、MMRLambda 为 lambda。
+func (vs *VectorSearch) SearchMMR(ctx context.Context, query Vector, k int, lambda float64) ([]VectorSearchResult, error) {
+	return vs.Search(ctx, query, VectorSearchOptions{Limit: k, MMR: true, MMRLambda: lambda})
+}
+
+// searchMMR 先按相似度取 Limit*4 个候选，再按最大边际相关性逐个选出 Limit 个结果。
+// 调用方需持有 vs.mu 读锁。
+func (vs *VectorSearch) searchMMR(ctx context.Context, query Vector, opts VectorSearchOptions) ([]VectorSearchResult, error) {
+	lambda := opts.MMRLambda
+	if lambda < 0 || lambda > 1 {
+		return nil, fmt.Errorf("mmr lambda must be between 0 and 1, got %v", lambda)
+	}
+	k := opts.Limit
+	if k <= 0 {
+		k = 10
+	}
+
+	candidateOpts := opts
+	candidateOpts.Limit = k * mmrCandidateFactor
+	candidates, _, err := vs.search(ctx, query, candidateOpts)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) <= 1 {
+		return candidates, nil
+	}
+
+	// 取候选向量，无法获取向量的候选不参与重排
+	type mmrCandidate struct {
+		result    VectorSearchResult
+		embedding Vector
+		relevance float64
+	}
+	pool := make([]mmrCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		embedding, ok := vs.indexedVector(c.Document)
+		if !ok {
+			continue
+		}
+		pool = append(pool, mmrCandidate{result: c, embedding: embedding, relevance: c.Score})
+	}
+
+	// maxSim[i] 记录候选 i 与已选结果的最大相似度
+	maxSim := make([]float64, len(pool))
+	for i := range maxSim {
+		maxSim[i] = math.Inf(-1)
+	}
+	selected := make([]VectorSearchResult, 0, k)
+	used := make([]bool, len(pool))
+	for len(selected) < k && len(selected) < len(pool) {
+		best, bestValue := -1, math.Inf(-1)
+		for i, c := range pool {
+			if used[i] {
+				continue
+			}
+			value := lambda * c.relevance
+			if len(selected) > 0 {
+				value -= (1 - lambda) * maxSim[i]
+			}
+			if value > bestValue {
+				best, bestValue = i, value
+			}
+		}
+		used[best] = true
+		selected = append(selected, pool[best].result)
+		for i, c := range pool {
+			if !used[i] {
+				sim := vs.distanceToScore(vs.calculateDistance(c.embedding, pool[best].embedding))
+				maxSim[i] = math.Max(maxSim[i], sim)
+			}
+		}
+	}
+	return selected, nil
+}
+
+// indexedVector 返回文档已索引的向量：优先取 HNSW 图节点与手动写入的向量，否则重新生成。
+// 调用方需持有 vs.mu 读锁。
+func (vs *VectorSearch) indexedVector(doc Document) (Vector, bool) {
+	for _, g := range vs.hnsw {
+		if node, ok := g.ids[doc.ID()]; ok {
+			return g.nodes[node].Vector, true
+		}
+	}
+	if vec, ok := vs.manualVectors[doc.ID()]; ok {
+		return vec, true
+	}
+	embedding, err := vs.getEmbeddingWithCache(doc.ID(), doc.Data())
+	if err != nil || len(embedding) != vs.dimensions {
+		return nil, false
+	}
+	return embedding, true
+}
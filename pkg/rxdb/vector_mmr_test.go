@@ -0,0 +1,90 @@
+package rxdb
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestVectorSearch_MMR(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "mmr_docs", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	// 5 个簇，每簇 10 个近似重复的向量
+	rng := rand.New(rand.NewSource(21))
+	centers := randomVectors(5, 8, 22)
+	for c, center := range centers {
+		for i := 0; i < 10; i++ {
+			v := make(Vector, len(center))
+			for j := range v {
+				v[j] = center[j] + rng.Float64()*0.02
+			}
+			if _, err := coll.Insert(ctx, map[string]any{"id": fmt.Sprintf("c%d-%d", c, i), "embedding": v}); err != nil {
+				t.Fatalf("Failed to insert: %v", err)
+			}
+		}
+	}
+
+	for _, indexType := range []string{"flat", "hnsw"} {
+		t.Run(indexType, func(t *testing.T) {
+			vs, err := AddVectorSearch(coll, VectorSearchConfig{
+				Identifier:     "mmr-" + indexType,
+				Dimensions:     8,
+				DistanceMetric: "euclidean",
+				IndexType:      indexType,
+				DocToEmbedding: func(doc map[string]any) (Vector, error) { return toFloat64Slice(doc["embedding"]), nil },
+			})
+			if err != nil {
+				t.Fatalf("Failed to create vector search: %v", err)
+			}
+			defer vs.Close()
+
+			// 平均两两相似度，越低说明结果越分散
+			meanSimilarity := func(results []VectorSearchResult) float64 {
+				var sum float64
+				var n int
+				for i := range results {
+					for j := i + 1; j < len(results); j++ {
+						a := toFloat64Slice(results[i].Document.Data()["embedding"])
+						b := toFloat64Slice(results[j].Document.Data()["embedding"])
+						sum += vs.distanceToScore(EuclideanDistance(a, b))
+						n++
+					}
+				}
+				return sum / float64(n)
+			}
+
+			relevant, err := vs.SearchMMR(ctx, centers[0], 5, 1.0)
+			if err != nil {
+				t.Fatalf("SearchMMR failed: %v", err)
+			}
+			plain, err := vs.Search(ctx, centers[0], VectorSearchOptions{Limit: 5})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if fmt.Sprint(resultIDs(relevant)) != fmt.Sprint(resultIDs(plain)) {
+				t.Errorf("Expected lambda=1 to match plain search: %v vs %v", resultIDs(relevant), resultIDs(plain))
+			}
+
+			diverse, err := vs.SearchMMR(ctx, centers[0], 5, 0.2)
+			if err != nil {
+				t.Fatalf("SearchMMR failed: %v", err)
+			}
+			if len(diverse) != 5 {
+				t.Fatalf("Expected 5 results, got %d", len(diverse))
+			}
+			if diverse[0].Document.ID() != relevant[0].Document.ID() {
+				t.Errorf("Expected most relevant document first, got %s", diverse[0].Document.ID())
+			}
+			if d, r := meanSimilarity(diverse), meanSimilarity(relevant); d >= r {
+				t.Errorf("Expected lambda=0.2 results to be less correlated: %.4f vs %.4f", d, r)
+			}
+
+			if _, err := vs.SearchMMR(ctx, centers[0], 5, 1.5); err == nil {
+				t.Error("Expected error for lambda out of range")
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package rxdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// VectorIndexDeclaration Schema 中声明的向量索引。db.Collection 打开集合时自动创建，
+// 并随集合变更增量更新，数据库重新打开后无需再调用 AddVectorSearch。
+//
+// 声明的维度会与索引数据一起保存在集合所在的存储中：重新打开时维度未变则直接加载
+// 持久化的索引（IndexType 为 "hnsw" 时包括 HNSW 图），维度变化时丢弃旧索引并重建。
+type VectorIndexDeclaration struct {
+	// Identifier 索引标识符，集合内唯一，用于 GetVectorIndex 与持久化数据的命名。
+	Identifier string
+	// Field 保存嵌入向量的字段（支持点号路径），值为数值数组。
+	// 设置后写入文档时会检查向量长度，不等于 Dimensions 时返回 DimensionMismatchError。
+	Field string
+	// Dimensions 向量维度。
+	Dimensions int
+	// DistanceMetric 距离度量方式，与 VectorSearchConfig.DistanceMetric 相同，默认为 "cosine"。
+	DistanceMetric string
+	// IndexType 索引类型，与 VectorSearchConfig.IndexType 相同，默认为 "flat"。
+	IndexType string
+	// DocToEmbedding 将文档转换为嵌入向量的函数，非空时优先于 Field。
+	DocToEmbedding func(doc map[string]any) (Vector, error)
+	// AutoSync 是否监听集合的 Changes() 自动更新索引，nil 时默认为 true。
+	AutoSync *bool
+}
+
+// docToEmbedding 返回声明使用的嵌入向量提取函数。
+func (d VectorIndexDeclaration) docToEmbedding() func(doc map[string]any) (Vector, error) {
+	if d.DocToEmbedding != nil {
+		return d.DocToEmbedding
+	}
+	field := d.Field
+	return func(doc map[string]any) (Vector, error) {
+		value := getNestedValue(doc, field)
+		if value == nil {
+			return nil, fmt.Errorf("vector field %s is missing", field)
+		}
+		return vectorFromValue(field, value)
+	}
+}
+
+// vectorFromValue 将文档中的数值数组转换为向量。
+func vectorFromValue(field string, value any) (Vector, error) {
+	switch v := value.(type) {
+	case Vector:
+		return v, nil
+	case []float32:
+		vec := make(Vector, len(v))
+		for i, x := range v {
+			vec[i] = float64(x)
+		}
+		return vec, nil
+	case []any:
+		vec := make(Vector, len(v))
+		for i, x := range v {
+			n, ok := jsonNumber(x)
+			if !ok {
+				return nil, fmt.Errorf("vector field %s: element %d is a %s, not a number", field, i, jsonTypeOf(x))
+			}
+			vec[i] = n
+		}
+		return vec, nil
+	}
+	return nil, fmt.Errorf("vector field %s must be an array of numbers, got %s", field, jsonTypeOf(value))
+}
+
+// validateVectorDeclarations 检查声明的 Identifier 唯一、维度为正且提供了向量来源。
+func validateVectorDeclarations(decls []VectorIndexDeclaration) error {
+	seen := make(map[string]struct{}, len(decls))
+	for i, decl := range decls {
+		id := decl.Identifier
+		if id == "" {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("vector index %d: identifier is required", i), nil)
+		}
+		if _, ok := seen[id]; ok {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("duplicate vector index identifier: %s", id), nil)
+		}
+		seen[id] = struct{}{}
+		if decl.Dimensions <= 0 {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("vector index %s: dimensions must be positive", id), nil)
+		}
+		if decl.Field == "" && decl.DocToEmbedding == nil {
+			return NewError(ErrorTypeValidation, fmt.Sprintf("vector index %s: field or DocToEmbedding is required", id), nil)
+		}
+	}
+	return nil
+}
+
+// validateVectorFields 检查文档中声明的向量字段长度与索引维度一致，字段缺失时不检查。
+func (c *collection) validateVectorFields(doc map[string]any) error {
+	for _, decl := range c.schema.VectorIndexes {
+		if decl.Field == "" {
+			continue
+		}
+		value := getNestedValue(doc, decl.Field)
+		if value == nil {
+			continue
+		}
+		vec, err := vectorFromValue(decl.Field, value)
+		if err != nil {
+			return err
+		}
+		if len(vec) != decl.Dimensions {
+			return fmt.Errorf("vector field %s: %w", decl.Field, &DimensionMismatchError{Expected: decl.Dimensions, Actual: len(vec)})
+		}
+	}
+	return nil
+}
+
+// vectorIndexMeta 持久化的向量索引声明信息，用于判断重新打开时是否需要重建索引。
+type vectorIndexMeta struct {
+	Dimensions     int    `json:"dimensions"`
+	DistanceMetric string `json:"distanceMetric"`
+}
+
+// vectorMetaBucket 返回保存向量索引声明信息的存储 bucket。
+func (c *collection) vectorMetaBucket() string {
+	return fmt.Sprintf("%s_vector_meta", c.name)
+}
+
+// prepareVectorIndex 比较持久化的声明信息，维度或距离度量变化时删除旧的 bleve 索引目录，
+// 使其按新配置重建（HNSW 图加载时会自行丢弃维度不一致的数据）。
+func (c *collection) prepareVectorIndex(ctx context.Context, decl VectorIndexDeclaration) error {
+	meta := vectorIndexMeta{Dimensions: decl.Dimensions, DistanceMetric: decl.DistanceMetric}
+	if meta.DistanceMetric == "" {
+		meta.DistanceMetric = "cosine"
+	}
+	bucket := c.vectorMetaBucket()
+	if raw, err := c.store.Get(ctx, bucket, decl.Identifier); err == nil && raw != nil {
+		var stored vectorIndexMeta
+		if err := json.Unmarshal(raw, &stored); err == nil && stored == meta {
+			return nil
+		}
+		c.logger.Info("Vector index declaration changed, rebuilding index",
+			"collection", c.name, "identifier", decl.Identifier, "dimensions", decl.Dimensions)
+		if err := os.RemoveAll(vectorIndexPath(c, decl.Identifier)); err != nil {
+			return fmt.Errorf("failed to remove vector index: %w", err)
+		}
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return c.store.Set(ctx, bucket, decl.Identifier, data)
+}
+
+// attachVectorIndexes 为 Schema 中声明、尚未添加到集合的向量索引创建 VectorSearch 实例。
+// 任一索引创建失败时关闭本次已创建的实例并返回错误。
+func (c *collection) attachVectorIndexes(ctx context.Context, decls []VectorIndexDeclaration) error {
+	if err := validateVectorDeclarations(decls); err != nil {
+		return err
+	}
+	var created []*VectorSearch
+	fail := func(err error) error {
+		for _, vs := range created {
+			vs.Close()
+		}
+		return err
+	}
+	for _, decl := range decls {
+		if _, err := c.GetVectorIndex(decl.Identifier); err == nil {
+			continue
+		}
+		if err := c.prepareVectorIndex(ctx, decl); err != nil {
+			return fail(fmt.Errorf("failed to prepare vector index %s: %w", decl.Identifier, err))
+		}
+		vs, err := AddVectorSearch(c, VectorSearchConfig{
+			Identifier:     decl.Identifier,
+			DocToEmbedding: decl.docToEmbedding(),
+			Dimensions:     decl.Dimensions,
+			DistanceMetric: decl.DistanceMetric,
+			IndexType:      decl.IndexType,
+			AutoSync:       decl.AutoSync,
+		})
+		if err != nil {
+			return fail(fmt.Errorf("failed to create vector index %s: %w", decl.Identifier, err))
+		}
+		created = append(created, vs)
+	}
+
+	c.vectorMu.Lock()
+	c.declaredVectors = append(c.declaredVectors, created...)
+	c.vectorMu.Unlock()
+	return nil
+}
+
+// closeDeclaredVectors 关闭由 Schema 声明创建、仍处于打开状态的向量索引，在数据库关闭时调用。
+// 关闭时会持久化 HNSW 图与布隆过滤器。
+func (c *collection) closeDeclaredVectors() {
+	c.vectorMu.Lock()
+	declared := c.declaredVectors
+	c.declaredVectors = nil
+	c.vectorMu.Unlock()
+	for _, vs := range declared {
+		if _, err := c.GetVectorIndex(vs.identifier); err == nil {
+			vs.Close()
+		}
+	}
+}
+
+// registerVector 记录集合上已打开的向量搜索实例。
+func (c *collection) registerVector(vs *VectorSearch) {
+	c.vectorMu.Lock()
+	defer c.vectorMu.Unlock()
+	c.vectors = append(c.vectors, vs)
+}
+
+// unregisterVector 移除已关闭的向量搜索实例。
+func (c *collection) unregisterVector(vs *VectorSearch) {
+	c.vectorMu.Lock()
+	defer c.vectorMu.Unlock()
+	for i, v := range c.vectors {
+		if v == vs {
+			c.vectors = append(c.vectors[:i], c.vectors[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetVectorIndex 返回集合上指定 Identifier 的向量搜索实例，
+// 包括 Schema.VectorIndexes 声明的索引与通过 AddVectorSearch 添加的实例。
+func (c *collection) GetVectorIndex(identifier string) (*VectorSearch, error) {
+	c.vectorMu.RLock()
+	defer c.vectorMu.RUnlock()
+	for _, vs := range c.vectors {
+		if vs.identifier == identifier {
+			return vs, nil
+		}
+	}
+	return nil, NewError(ErrorTypeNotFound, fmt.Sprintf("vector index %q not found in collection %s", identifier, c.name), nil)
+}
+
+// mergeVectorDeclarations 合并已有与新声明的向量索引，Identifier 相同时保留已有声明。
+func mergeVectorDeclarations(existing, declared []VectorIndexDeclaration) []VectorIndexDeclaration {
+	merged := append([]VectorIndexDeclaration(nil), existing...)
+	for _, decl := range declared {
+		found := false
+		for _, e := range existing {
+			if e.Identifier == decl.Identifier {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, decl)
+		}
+	}
+	return merged
+}
+
+// exportVectorDeclarations 复制声明并去掉无法导出的 DocToEmbedding，导入后按 Field 重建索引。
+func exportVectorDeclarations(decls []VectorIndexDeclaration) []VectorIndexDeclaration {
+	if decls == nil {
+		return nil
+	}
+	exported := make([]VectorIndexDeclaration, len(decls))
+	for i, decl := range decls {
+		decl.DocToEmbedding = nil
+		exported[i] = decl
+	}
+	return exported
+}
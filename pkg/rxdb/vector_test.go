@@ -2,10 +2,15 @@ package rxdb
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"math/rand"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestVectorSearch_Basic(t *testing.T) {
@@ -510,6 +515,117 @@ func TestVectorSearch_NormalizeVector(t *testing.T) {
 	}
 }
 
+func TestVectorSearch_Normalize(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rxdb-vector-normalize-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	db, err := CreateDatabase(context.Background(), DatabaseOptions{
+		Name: "test-vector-normalize",
+		Path: tmpDir,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close(context.Background())
+
+	coll, err := db.Collection(context.Background(), "vectors", Schema{
+		PrimaryKey: "id",
+		RevField:   "_rev",
+	})
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+
+	// 未归一化的向量
+	testDocs := []map[string]any{
+		{"id": "same", "embedding": []float64{3.0, 4.0, 0.0}},
+		{"id": "orthogonal", "embedding": []float64{0.0, 0.0, 7.0}},
+	}
+	for _, doc := range testDocs {
+		if _, err := coll.Insert(context.Background(), doc); err != nil {
+			t.Fatalf("failed to insert document: %v", err)
+		}
+	}
+
+	vs, err := AddVectorSearch(coll, VectorSearchConfig{
+		Identifier: "normalize-search",
+		Dimensions: 3,
+		DocToEmbedding: func(doc map[string]any) (Vector, error) {
+			embAny, _ := doc["embedding"].([]any)
+			emb := make([]float64, len(embAny))
+			for i, v := range embAny {
+				emb[i], _ = v.(float64)
+			}
+			return emb, nil
+		},
+		DistanceMetric: "cosine",
+		Normalize:      true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create vector search: %v", err)
+	}
+	defer vs.Close()
+
+	// 入库向量应为单位向量
+	emb, ok := vs.GetEmbedding("same")
+	if !ok {
+		t.Fatal("expected embedding for document 'same'")
+	}
+	if math.Abs(emb[0]-0.6) > 1e-9 || math.Abs(emb[1]-0.8) > 1e-9 {
+		t.Errorf("expected normalized embedding [0.6 0.8 0], got %v", emb)
+	}
+
+	// 查询向量与 "same" 方向相同但长度不同
+	results, err := vs.Search(context.Background(), []float64{6.0, 8.0, 0.0}, VectorSearchOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+
+	scores := make(map[string]float64)
+	for _, r := range results {
+		scores[r.Document.ID()] = r.Score
+	}
+	if math.Abs(scores["same"]-1.0) > 1e-9 {
+		t.Errorf("expected score 1.0 for identical direction, got %f", scores["same"])
+	}
+	if math.Abs(scores["orthogonal"]) > 1e-9 {
+		t.Errorf("expected score 0.0 for orthogonal vector, got %f", scores["orthogonal"])
+	}
+
+	// 未启用 Normalize 时 cosine 分数保持 1 - 距离/2
+	raw, err := AddVectorSearch(coll, VectorSearchConfig{
+		Identifier: "raw-search",
+		Dimensions: 3,
+		DocToEmbedding: func(doc map[string]any) (Vector, error) {
+			return vectorFromValue("embedding", doc["embedding"])
+		},
+		DistanceMetric: "cosine",
+	})
+	if err != nil {
+		t.Fatalf("failed to create vector search: %v", err)
+	}
+	defer raw.Close()
+
+	results, err = raw.Search(context.Background(), []float64{6.0, 8.0, 0.0}, VectorSearchOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	for _, r := range results {
+		if want := 1 - r.Distance/2; math.Abs(r.Score-want) > 1e-9 {
+			t.Errorf("expected score %f for %s, got %f", want, r.Document.ID(), r.Score)
+		}
+		if r.Document.ID() == "orthogonal" && math.Abs(r.Score-0.5) > 1e-6 {
+			t.Errorf("expected score 0.5 for orthogonal vector, got %f", r.Score)
+		}
+	}
+}
+
 func TestVectorSearch_IVFIndex(t *testing.T) {
 	// 创建临时目录
 	tmpDir, err := os.MkdirTemp("", "rxdb-vector-ivf-test-*")
@@ -610,3 +726,710 @@ func TestVectorSearch_IVFIndex(t *testing.T) {
 		t.Logf("IVF search returned %d results (may be less precise than full scan)", len(results))
 	}
 }
+
+func TestVectorSearch_ExplainSearch(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rxdb-vector-explain-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "test-vector-explain",
+		Path: tmpDir,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	coll, err := db.Collection(ctx, "items", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+
+	embeddings := make(map[string]Vector)
+	for i := 0; i < 10; i++ {
+		id := fmt.Sprintf("item%d", i)
+		angle := float64(i) * math.Pi / 20
+		embeddings[id] = Vector{math.Cos(angle), math.Sin(angle), float64(i%3) * 0.1}
+		if _, err := coll.Insert(ctx, map[string]any{"id": id}); err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+	}
+
+	vs, err := AddVectorSearch(coll, VectorSearchConfig{
+		Identifier: "item-explain",
+		Dimensions: 3,
+		DocToEmbedding: func(doc map[string]any) (Vector, error) {
+			id, _ := doc["id"].(string)
+			return embeddings[id], nil
+		},
+		DistanceMetric: "cosine",
+	})
+	if err != nil {
+		t.Fatalf("failed to create vector search: %v", err)
+	}
+	defer vs.Close()
+
+	query := Vector{1, 0.2, 0}
+	explanation, err := vs.ExplainSearch(ctx, query, VectorSearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("failed to explain search: %v", err)
+	}
+	if len(explanation.TopK) != 10 {
+		t.Fatalf("expected 10 entries, got %d", len(explanation.TopK))
+	}
+
+	for _, entry := range explanation.TopK {
+		want := CosineDistance(query, embeddings[entry.Document.ID()])
+		if math.Abs(entry.Distance-want) > 1e-9 {
+			t.Errorf("%s: distance = %v, want %v", entry.Document.ID(), entry.Distance, want)
+		}
+		if entry.IndexType != VectorIndexTypeANN && entry.IndexType != VectorIndexTypeFlat {
+			t.Errorf("%s: unexpected index type %q", entry.Document.ID(), entry.IndexType)
+		}
+		if entry.IndexType == VectorIndexTypeANN {
+			if entry.QuantizationError == nil {
+				t.Errorf("%s: ANN entry should report quantization error", entry.Document.ID())
+			} else if got := math.Abs(entry.ApproximateDistance - entry.Distance); math.Abs(*entry.QuantizationError-got) > 1e-9 {
+				t.Errorf("%s: quantization error = %v, want %v", entry.Document.ID(), *entry.QuantizationError, got)
+			}
+		}
+	}
+}
+
+func TestVectorSearch_UpsertDelete(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rxdb-vector-upsert-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "test-vector-upsert",
+		Path: tmpDir,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	coll, err := db.Collection(ctx, "points", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	for _, p := range []map[string]any{
+		{"id": "p1", "x": 1.0, "y": 0.0},
+		{"id": "p2", "x": 0.0, "y": 1.0},
+		{"id": "p3", "x": -1.0, "y": 0.0},
+	} {
+		if _, err := coll.Insert(ctx, p); err != nil {
+			t.Fatalf("failed to insert point: %v", err)
+		}
+	}
+
+	vs, err := AddVectorSearch(coll, VectorSearchConfig{
+		Identifier: "point-upsert",
+		Dimensions: 2,
+		DocToEmbedding: func(doc map[string]any) (Vector, error) {
+			x, _ := doc["x"].(float64)
+			y, _ := doc["y"].(float64)
+			return Vector{x, y}, nil
+		},
+		DistanceMetric: "euclidean",
+	})
+	if err != nil {
+		t.Fatalf("failed to create vector search: %v", err)
+	}
+	defer vs.Close()
+
+	query := Vector{0, -5}
+	results, err := vs.Search(ctx, query, VectorSearchOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) != 1 || results[0].Document.ID() == "p2" {
+		t.Fatalf("unexpected initial nearest result: %v", results)
+	}
+
+	// 手动写入新向量，不重建索引即可被搜索到
+	if err := vs.Upsert(ctx, "p2", Vector{0, -5}); err != nil {
+		t.Fatalf("failed to upsert vector: %v", err)
+	}
+	results, err = vs.Search(ctx, query, VectorSearchOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) != 1 || results[0].Document.ID() != "p2" {
+		t.Fatalf("expected p2 as nearest after upsert, got %v", results)
+	}
+	if results[0].Distance > 1e-6 {
+		t.Errorf("expected zero distance for upserted vector, got %v", results[0].Distance)
+	}
+
+	if err := vs.Upsert(ctx, "p2", Vector{1, 2, 3}); err == nil {
+		t.Error("expected dimension mismatch error")
+	}
+
+	// 删除后不再出现在结果中
+	if err := vs.Delete(ctx, "p2"); err != nil {
+		t.Fatalf("failed to delete vector: %v", err)
+	}
+	results, err = vs.Search(ctx, query, VectorSearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	for _, r := range results {
+		if r.Document.ID() == "p2" {
+			t.Errorf("p2 should not be returned after delete")
+		}
+	}
+	if len(results) != 2 {
+		t.Errorf("expected 2 remaining results, got %d", len(results))
+	}
+}
+
+// randomVectors 生成确定性的伪随机向量，用于距离计算测试和基准。
+func randomVectors(n, dim int, seed int64) []Vector {
+	rng := rand.New(rand.NewSource(seed))
+	vectors := make([]Vector, n)
+	for i := range vectors {
+		v := make(Vector, dim)
+		for j := range v {
+			v[j] = rng.Float64()*2 - 1
+		}
+		vectors[i] = v
+	}
+	return vectors
+}
+
+func TestVectorSearch_InnerProduct(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rxdb-vector-inner-product-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "test-vector-inner-product",
+		Path: tmpDir,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	coll, err := db.Collection(ctx, "points", Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	// a、b 为正交单位向量，c 与两者都不正交且模长大于 1
+	points := []map[string]any{
+		{"id": "a", "x": 1.0, "y": 0.0},
+		{"id": "b", "x": 0.0, "y": 1.0},
+		{"id": "c", "x": 2.0, "y": 1.0},
+	}
+	for _, p := range points {
+		if _, err := coll.Insert(ctx, p); err != nil {
+			t.Fatalf("failed to insert point: %v", err)
+		}
+	}
+
+	vs, err := AddVectorSearch(coll, VectorSearchConfig{
+		Identifier: "point-inner-product",
+		Dimensions: 2,
+		DocToEmbedding: func(doc map[string]any) (Vector, error) {
+			x, _ := doc["x"].(float64)
+			y, _ := doc["y"].(float64)
+			return Vector{x, y}, nil
+		},
+		DistanceMetric: "inner_product",
+	})
+	if err != nil {
+		t.Fatalf("failed to create vector search: %v", err)
+	}
+	defer vs.Close()
+
+	// 以 c 作为查询文档：分数为原始点积 5、2、1，按降序排列
+	results, err := vs.SearchByDocument(ctx, points[2])
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	want := []struct {
+		id    string
+		score float64
+	}{{"c", 5}, {"a", 2}, {"b", 1}}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(results))
+	}
+	for i, w := range want {
+		if results[i].Document.ID() != w.id || math.Abs(results[i].Score-w.score) > 1e-9 {
+			t.Errorf("result %d: expected %s with score %v, got %s with score %v",
+				i, w.id, w.score, results[i].Document.ID(), results[i].Score)
+		}
+	}
+
+	// 与余弦不同，内积会让模长更大的 c 排在 a 自身之前
+	results, err = vs.Search(ctx, Vector{1, 0})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(results) == 0 || results[0].Document.ID() != "c" || results[0].Score != 2 {
+		t.Errorf("expected c with score 2 as top result, got %+v", results)
+	}
+}
+
+func TestBatchCosineDistances(t *testing.T) {
+	query := randomVectors(1, 768, 1)[0]
+	// 超过并行阈值，覆盖并行分段路径
+	candidates := randomVectors(3000, 768, 2)
+	// 边界情况：零向量与维度不一致
+	candidates = append(candidates, make(Vector, 768), Vector{1, 2, 3})
+
+	batch := BatchCosineDistances(query, candidates)
+	if len(batch) != len(candidates) {
+		t.Fatalf("expected %d distances, got %d", len(candidates), len(batch))
+	}
+	for i, c := range candidates {
+		want := CosineDistance(query, c)
+		if math.Abs(batch[i]-want) > 1e-12 {
+			t.Fatalf("candidate %d: batch distance %v, sequential %v", i, batch[i], want)
+		}
+	}
+
+	if got := BatchCosineDistances(query, nil); len(got) != 0 {
+		t.Errorf("expected empty result for no candidates, got %v", got)
+	}
+}
+
+func BenchmarkCosineDistances(b *testing.B) {
+	query := randomVectors(1, 768, 1)[0]
+	candidates := randomVectors(10000, 768, 2)
+
+	b.Run("Sequential", func(b *testing.B) {
+		distances := make([]float64, len(candidates))
+		for i := 0; i < b.N; i++ {
+			for j, c := range candidates {
+				distances[j] = CosineDistance(query, c)
+			}
+		}
+	})
+
+	b.Run("Batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			BatchCosineDistances(query, candidates)
+		}
+	})
+}
+
+// newExportTestVectorSearch 创建包含二维点的集合及其向量搜索实例，供导出导入测试使用。
+func newExportTestVectorSearch(t *testing.T, db Database, name string, dimensions int, metric string) *VectorSearch {
+	t.Helper()
+	ctx := context.Background()
+	coll, err := db.Collection(ctx, name, Schema{PrimaryKey: "id", RevField: "_rev"})
+	if err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	for _, p := range []map[string]any{
+		{"id": "p1", "x": 1.0, "y": 0.0},
+		{"id": "p2", "x": 0.0, "y": 1.0},
+		{"id": "p3", "x": -1.0, "y": 0.0},
+		{"id": "p4", "x": 0.5, "y": 0.5},
+	} {
+		if _, err := coll.Insert(ctx, p); err != nil {
+			t.Fatalf("failed to insert point: %v", err)
+		}
+	}
+	vs, err := AddVectorSearch(coll, VectorSearchConfig{
+		Identifier: "points",
+		Dimensions: dimensions,
+		DocToEmbedding: func(doc map[string]any) (Vector, error) {
+			x, _ := doc["x"].(float64)
+			y, _ := doc["y"].(float64)
+			return Vector{x, y}, nil
+		},
+		DistanceMetric: metric,
+	})
+	if err != nil {
+		t.Fatalf("failed to create vector search: %v", err)
+	}
+	t.Cleanup(vs.Close)
+	return vs
+}
+
+func TestVectorSearch_ExportImport(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rxdb-vector-export-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "test-vector-export",
+		Path: tmpDir,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	source := newExportTestVectorSearch(t, db, "source", 2, "euclidean")
+	// 手动维护的向量也应随索引一并导出
+	if err := source.Upsert(ctx, "p3", Vector{0.9, 0.1}); err != nil {
+		t.Fatalf("failed to upsert vector: %v", err)
+	}
+	if err := source.Delete(ctx, "p2"); err != nil {
+		t.Fatalf("failed to delete vector: %v", err)
+	}
+
+	data, err := source.Export(ctx)
+	if err != nil {
+		t.Fatalf("failed to export index: %v", err)
+	}
+
+	replica := newExportTestVectorSearch(t, db, "replica", 2, "l2")
+	if err := replica.Import(ctx, data); err != nil {
+		t.Fatalf("failed to import index: %v", err)
+	}
+
+	query := Vector{1.0, 0.0}
+	want, err := source.Search(ctx, query, VectorSearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("failed to search source: %v", err)
+	}
+	got, err := replica.Search(ctx, query, VectorSearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatalf("failed to search replica: %v", err)
+	}
+	if len(want) != 3 {
+		t.Fatalf("expected 3 source results, got %d", len(want))
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d results after import, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].Document.ID() != want[i].Document.ID() || math.Abs(got[i].Distance-want[i].Distance) > 1e-9 {
+			t.Errorf("result %d: expected %s (%f), got %s (%f)", i,
+				want[i].Document.ID(), want[i].Distance, got[i].Document.ID(), got[i].Distance)
+		}
+		if got[i].Document.ID() == "p2" {
+			t.Errorf("deleted vector p2 should not be returned after import")
+		}
+	}
+	if replica.Count() != source.Count() {
+		t.Errorf("expected %d indexed documents after import, got %d", source.Count(), replica.Count())
+	}
+}
+
+func TestVectorSearch_ImportMismatch(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "rxdb-vector-import-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ctx := context.Background()
+	db, err := CreateDatabase(ctx, DatabaseOptions{
+		Name: "test-vector-import",
+		Path: tmpDir,
+	})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close(ctx)
+
+	source := newExportTestVectorSearch(t, db, "source", 2, "euclidean")
+	data, err := source.Export(ctx)
+	if err != nil {
+		t.Fatalf("failed to export index: %v", err)
+	}
+
+	wrongDims := newExportTestVectorSearch(t, db, "wrong_dims", 3, "euclidean")
+	err = wrongDims.Import(ctx, data)
+	var dimErr *DimensionMismatchError
+	if !errors.As(err, &dimErr) {
+		t.Fatalf("expected DimensionMismatchError, got %v", err)
+	}
+	if dimErr.Expected != 3 || dimErr.Actual != 2 {
+		t.Errorf("expected dimensions 3/2, got %d/%d", dimErr.Expected, dimErr.Actual)
+	}
+
+	wrongMetric := newExportTestVectorSearch(t, db, "wrong_metric", 2, "cosine")
+	before := wrongMetric.Count()
+	err = wrongMetric.Import(ctx, data)
+	if !IsMetricMismatchError(err) {
+		t.Fatalf("expected MetricMismatchError, got %v", err)
+	}
+	if wrongMetric.Count() != before {
+		t.Errorf("failed import should keep the current index, got %d documents", wrongMetric.Count())
+	}
+
+	if err := wrongMetric.Import(ctx, []byte("not a zip archive")); err == nil {
+		t.Error("expected error for invalid index data")
+	}
+}
+
+func TestVectorSearch_Cluster(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "points", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	// 两组明显分离的点：a* 聚集在 (10, 10) 附近，b* 聚集在 (-10, -10) 附近
+	points := []map[string]any{
+		{"id": "a1", "x": 10.0, "y": 10.0},
+		{"id": "a2", "x": 11.0, "y": 9.5},
+		{"id": "a3", "x": 9.0, "y": 10.5},
+		{"id": "a4", "x": 10.5, "y": 11.0},
+		{"id": "b1", "x": -10.0, "y": -10.0},
+		{"id": "b2", "x": -9.0, "y": -11.0},
+		{"id": "b3", "x": -11.0, "y": -9.5},
+	}
+	for _, p := range points {
+		if _, err := coll.Insert(ctx, p); err != nil {
+			t.Fatalf("failed to insert point: %v", err)
+		}
+	}
+
+	vs, err := AddVectorSearch(coll, VectorSearchConfig{
+		Identifier: "points",
+		Dimensions: 2,
+		DocToEmbedding: func(doc map[string]any) (Vector, error) {
+			x, _ := doc["x"].(float64)
+			y, _ := doc["y"].(float64)
+			return Vector{x, y}, nil
+		},
+		DistanceMetric: "euclidean",
+	})
+	if err != nil {
+		t.Fatalf("failed to create vector search: %v", err)
+	}
+	defer vs.Close()
+
+	clusters, err := vs.Cluster(ctx, 2)
+	if err != nil {
+		t.Fatalf("failed to cluster: %v", err)
+	}
+	if len(clusters) != 2 {
+		t.Fatalf("expected 2 clusters, got %d", len(clusters))
+	}
+
+	want := [][]string{{"a1", "a2", "a3", "a4"}, {"b1", "b2", "b3"}}
+	for i, cluster := range clusters {
+		if !reflect.DeepEqual(cluster.Members, want[i]) {
+			t.Errorf("cluster %d: expected members %v, got %v", i, want[i], cluster.Members)
+		}
+		if cluster.CentroidID == "" || cluster.CentroidID[0] != want[i][0][0] {
+			t.Errorf("cluster %d: unexpected centroid document %q", i, cluster.CentroidID)
+		}
+		if len(cluster.Centroid) != 2 {
+			t.Errorf("cluster %d: expected 2-dimensional centroid, got %v", i, cluster.Centroid)
+		}
+	}
+	if clusters[0].CentroidID != "a1" {
+		t.Errorf("expected a1 to be closest to the first centroid, got %s", clusters[0].CentroidID)
+	}
+
+	if _, err := vs.Cluster(ctx, len(points)+1); err == nil {
+		t.Error("expected error when k exceeds the number of vectors")
+	}
+}
+
+func TestVectorSearch_BatchSearch(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	coll := newTestCollection(t, db, "batch_vectors", Schema{PrimaryKey: "id", RevField: "_rev"})
+
+	vectors := randomVectors(300, 8, 31)
+	docs := make([]map[string]any, len(vectors))
+	for i, v := range vectors {
+		docs[i] = map[string]any{"id": fmt.Sprintf("v%03d", i), "embedding": v}
+	}
+	if _, err := coll.BulkInsert(ctx, docs); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	queries := randomVectors(40, 8, 32)
+
+	for _, indexType := range []string{"flat", "hnsw"} {
+		t.Run(indexType, func(t *testing.T) {
+			vs, err := AddVectorSearch(coll, VectorSearchConfig{
+				Identifier:     "batch-" + indexType,
+				Dimensions:     8,
+				DistanceMetric: "euclidean",
+				IndexType:      indexType,
+				DocToEmbedding: func(doc map[string]any) (Vector, error) { return toFloat64Slice(doc["embedding"]), nil },
+			})
+			if err != nil {
+				t.Fatalf("Failed to create vector search: %v", err)
+			}
+			defer vs.Close()
+
+			opts := VectorSearchOptions{Limit: 5}
+			batch, err := vs.BatchSearch(ctx, queries, opts)
+			if err != nil {
+				t.Fatalf("BatchSearch failed: %v", err)
+			}
+			if len(batch) != len(queries) {
+				t.Fatalf("Expected %d result sets, got %d", len(queries), len(batch))
+			}
+			// 与逐个调用 Search 的结果一致
+			for i, query := range queries {
+				want, err := vs.Search(ctx, query, opts)
+				if err != nil {
+					t.Fatalf("Search failed: %v", err)
+				}
+				if len(batch[i]) != len(want) {
+					t.Fatalf("query %d: expected %d results, got %d", i, len(want), len(batch[i]))
+				}
+				for j := range want {
+					if batch[i][j].Document.ID() != want[j].Document.ID() || batch[i][j].Distance != want[j].Distance {
+						t.Errorf("query %d result %d: expected %s (%f), got %s (%f)", i, j,
+							want[j].Document.ID(), want[j].Distance, batch[i][j].Document.ID(), batch[i][j].Distance)
+					}
+				}
+			}
+
+			// 任一查询出错时返回该错误
+			bad := append([]Vector{Vector{1, 2}}, queries...)
+			if _, err := vs.BatchSearch(ctx, bad, opts); err == nil || !strings.Contains(err.Error(), "query 0") {
+				t.Errorf("Expected dimension error for query 0, got %v", err)
+			}
+
+			// 已取消的 ctx：返回与查询数量相同的结果切片（均未完成）与 ctx.Err()
+			cancelled, cancel := context.WithCancel(ctx)
+			cancel()
+			partial, err := vs.BatchSearch(cancelled, queries, opts)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("Expected context.Canceled, got %v", err)
+			}
+			if len(partial) != len(queries) {
+				t.Fatalf("Expected %d result slots, got %d", len(queries), len(partial))
+			}
+			for i, r := range partial {
+				if r != nil {
+					t.Errorf("query %d: expected no result after cancellation, got %d", i, len(r))
+				}
+			}
+
+			if results, err := vs.BatchSearch(ctx, nil, opts); err != nil || len(results) != 0 {
+				t.Errorf("Expected empty results, got %v (%v)", results, err)
+			}
+		})
+	}
+}
+
+func TestSchema_VectorIndexes(t *testing.T) {
+	ctx := context.Background()
+	dbPath := t.TempDir()
+	declaration := VectorIndexDeclaration{
+		Identifier:     "embeddings",
+		Field:          "embedding",
+		Dimensions:     3,
+		DistanceMetric: "euclidean",
+		IndexType:      "hnsw",
+	}
+	openDB := func(decl VectorIndexDeclaration) (Database, Collection, *VectorSearch) {
+		t.Helper()
+		db, err := CreateDatabase(ctx, DatabaseOptions{Name: "vector-schema", Path: dbPath})
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		coll, err := db.Collection(ctx, "docs", Schema{PrimaryKey: "id", RevField: "_rev", VectorIndexes: []VectorIndexDeclaration{decl}})
+		if err != nil {
+			db.Close(ctx)
+			t.Fatalf("Failed to open collection: %v", err)
+		}
+		vs, err := coll.GetVectorIndex("embeddings")
+		if err != nil {
+			db.Close(ctx)
+			t.Fatalf("GetVectorIndex failed: %v", err)
+		}
+		return db, coll, vs
+	}
+	graphLen := func(vs *VectorSearch) int {
+		vs.mu.RLock()
+		defer vs.mu.RUnlock()
+		if g, ok := vs.hnsw[""]; ok {
+			return g.Len()
+		}
+		return 0
+	}
+	// 自动同步在后台 goroutine 中处理变更事件，等待索引追上写入
+	waitForNearest := func(vs *VectorSearch, query Vector, want string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			results, err := vs.Search(ctx, query, VectorSearchOptions{Limit: 1})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(results) == 1 && results[0].Document.ID() == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected nearest document %s, got %v", want, resultIDs(results))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	db, coll, vs := openDB(declaration)
+	for id, v := range map[string][]float64{"a": {1, 0, 0}, "b": {0, 1, 0}} {
+		if _, err := coll.Insert(ctx, map[string]any{"id": id, "embedding": v}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+	// 插入的文档增量写入索引
+	waitForNearest(vs, Vector{0.9, 0.1, 0}, "a")
+	waitForNearest(vs, Vector{0.1, 0.9, 0}, "b")
+
+	// 向量长度与声明的维度不一致时写入失败
+	_, err := coll.Insert(ctx, map[string]any{"id": "bad", "embedding": []float64{1, 2}})
+	if !IsDimensionMismatchError(err) {
+		t.Errorf("Expected dimension mismatch error on insert, got %v", err)
+	}
+	if _, err := coll.Upsert(ctx, map[string]any{"id": "a", "embedding": []any{1.0, 2.0, 3.0, 4.0}}); !IsDimensionMismatchError(err) {
+		t.Errorf("Expected dimension mismatch error on upsert, got %v", err)
+	}
+	if _, err := coll.GetVectorIndex("missing"); !IsNotFoundError(err) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+	if err := db.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// 重新打开后加载持久化的 HNSW 图，无需再调用 AddVectorSearch
+	db, coll, vs = openDB(declaration)
+	if got := graphLen(vs); got != 2 {
+		t.Errorf("Expected 2 vectors in restored graph, got %d", got)
+	}
+	if _, err := coll.Insert(ctx, map[string]any{"id": "c", "embedding": []float64{0, 0, 1}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	waitForNearest(vs, Vector{0, 0.1, 0.9}, "c")
+	if err := db.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// 维度变化时丢弃旧索引并重建，旧维度的文档不再被索引
+	changed := declaration
+	changed.Dimensions = 4
+	db, coll, vs = openDB(changed)
+	defer db.Close(ctx)
+	if got := graphLen(vs); got != 0 {
+		t.Errorf("Expected graph to be rebuilt for new dimensions, got %d vectors", got)
+	}
+	if _, err := coll.Insert(ctx, map[string]any{"id": "d", "embedding": []float64{0, 0, 0, 1}}); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	waitForNearest(vs, Vector{0, 0, 0, 1}, "d")
+
+	// 声明不合法时打开集合失败
+	if _, err := db.Collection(ctx, "invalid", Schema{VectorIndexes: []VectorIndexDeclaration{{Identifier: "x", Dimensions: 3}}}); err == nil {
+		t.Error("Expected error for declaration without field")
+	}
+}
@@ -0,0 +1,507 @@
+package rxdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	bstore "github.com/mozhou-tech/rxdb-go/pkg/storage/badger"
+)
+
+// cdcReplayBatchSize 每次从变更日志中读取的最大事件数，避免长时间持有读事务。
+const cdcReplayBatchSize = 256
+
+// CDCEvent 是带有持久化序列号的变更事件，用于可恢复的变更流。
+type CDCEvent struct {
+	ChangeEvent
+	Sequence int64 // 集合内单调递增的序列号，从 1 开始；未启用 Schema.Changelog 时为 0
+}
+
+// ChangeSourceRemote 表示由复制等远程同步写入本地集合所产生的变更。
+const ChangeSourceRemote = "remote"
+
+type changeSourceKey struct{}
+
+// WithChangeSource 返回携带变更来源的 context。
+// 使用该 context 执行的写操作所产生的 ChangeEvent（及 CDCEvent）的 Source 字段为 source，
+// 订阅者可据此区分本地写入与远程同步，例如避免把拉取到的变更再次推送回远端。
+func WithChangeSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, changeSourceKey{}, source)
+}
+
+// ChangeSourceFromContext 返回 WithChangeSource 设置的变更来源，未设置时为空字符串。
+func ChangeSourceFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	source, _ := ctx.Value(changeSourceKey{}).(string)
+	return source
+}
+
+// ChangelogOptions 持久化变更日志配置，见 Schema.Changelog。
+type ChangelogOptions struct {
+	// MaxEntries 最多保留的日志条数，<= 0 表示不限制
+	MaxEntries int
+	// MaxAge 日志的最长保留时间，<= 0 表示不限制
+	MaxAge time.Duration
+}
+
+// changelogEntry 变更日志中的一条记录，只包含文档 ID 与操作信息，不保存文档内容。
+type changelogEntry struct {
+	Sequence int64
+	ID       string
+	Op       Operation
+	Source   string
+	Time     int64 // 写入时间（Unix 纳秒）
+}
+
+// changelogBucket 返回集合变更日志所在的 bucket。
+func (c *collection) changelogBucket() string {
+	return fmt.Sprintf("%s_changelog", c.name)
+}
+
+// formatSequence 将序列号编码为定长字符串，保证键的字典序与数值顺序一致。
+func formatSequence(seq int64) string {
+	return fmt.Sprintf("%020d", seq)
+}
+
+// loadChangelogRange 从存储中恢复最早与最新的变更序列号。
+func (c *collection) loadChangelogRange(ctx context.Context) error {
+	var first, last int64
+	err := c.store.Iterate(ctx, c.changelogBucket(), func(k, _ []byte) error {
+		seq, err := strconv.ParseInt(string(k), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid changelog key %q: %w", k, err)
+		}
+		if first == 0 {
+			first = seq
+		}
+		last = seq
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.cdcNext = last
+	c.cdcCommitted = last
+	c.cdcFirst = first
+	if first == 0 {
+		c.cdcFirst = last + 1
+	}
+	return nil
+}
+
+// changelogTxn 在一个读写事务中追加变更日志。为 nil 时（集合未启用变更日志）所有方法均为空操作。
+//
+// 序列号在写入日志时分配，所在事务结束后调用 finish；并发事务的提交顺序可能与序列号顺序不同，
+// 因此订阅者只读取水位线（所有更小的序列号都已提交或已放弃）之前的日志，保证重放顺序与不丢事件。
+// 事务失败时其序列号被放弃，序列号单调递增但可能不连续。
+type changelogTxn struct {
+	c        *collection
+	txn      kvTxn
+	source   string
+	seqs     []int64
+	trimming bool
+	first    int64 // 清理后最早保留的序列号，0 表示未清理
+}
+
+// changelog 返回在 txn 中追加变更日志的 changelogTxn，集合未启用变更日志时返回 nil。
+func (c *collection) changelog(ctx context.Context, txn kvTxn) *changelogTxn {
+	if c.schema.Changelog == nil {
+		return nil
+	}
+	return &changelogTxn{c: c, txn: txn, source: ChangeSourceFromContext(ctx)}
+}
+
+// updateWithChangelog 在读写事务中执行 fn，fn 通过 changes 记录的变更日志与文档写入在同一事务中提交。
+func (c *collection) updateWithChangelog(ctx context.Context, fn func(txn kvTxn, changes *changelogTxn) error) error {
+	var changes *changelogTxn
+	err := c.store.WithUpdate(ctx, func(txn kvTxn) error {
+		changes = c.changelog(ctx, txn)
+		if err := fn(txn, changes); err != nil {
+			return err
+		}
+		return changes.trim()
+	})
+	changes.finish(err == nil)
+	return err
+}
+
+// add 为一次文档变更分配序列号并写入日志，Truncate 的 id 为空。
+func (l *changelogTxn) add(id string, op Operation) error {
+	if l == nil {
+		return nil
+	}
+	c := l.c
+	c.cdcMu.Lock()
+	c.cdcNext++
+	seq := c.cdcNext
+	c.cdcInflight[seq] = struct{}{}
+	c.cdcMu.Unlock()
+	l.seqs = append(l.seqs, seq)
+
+	data, err := json.Marshal(changelogEntry{
+		Sequence: seq,
+		ID:       id,
+		Op:       op,
+		Source:   l.source,
+		Time:     time.Now().UnixNano(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode changelog entry: %w", err)
+	}
+	return l.txn.Set(bstore.BucketKey(c.changelogBucket(), formatSequence(seq)), data)
+}
+
+// cdcTrimBatchSize 单个事务最多清理的日志条数，避免事务过大，剩余的在后续写入时继续清理。
+const cdcTrimBatchSize = 1000
+
+// trim 在当前事务中按 ChangelogOptions 删除过期日志。
+// 同一时间只有一个事务执行清理，且只处理水位线之前的已提交日志，不会与并发写入的事务冲突；
+// 本事务追加了新日志，因此清理后至少保留一条日志，重新打开集合时序列号可以据此恢复。
+func (l *changelogTxn) trim() error {
+	if l == nil || len(l.seqs) == 0 {
+		return nil
+	}
+	c := l.c
+	opts := c.schema.Changelog
+	if opts.MaxEntries <= 0 && opts.MaxAge <= 0 {
+		return nil
+	}
+	if !c.cdcTrimMu.TryLock() {
+		return nil
+	}
+	l.trimming = true
+
+	c.cdcMu.Lock()
+	first, committed := c.cdcFirst, c.cdcCommitted
+	c.cdcMu.Unlock()
+	newest := l.seqs[len(l.seqs)-1]
+	cutoff := time.Now().Add(-opts.MaxAge).UnixNano()
+
+	seq := first
+	for ; seq <= committed && seq-first < cdcTrimBatchSize; seq++ {
+		key := bstore.BucketKey(c.changelogBucket(), formatSequence(seq))
+		expired := opts.MaxEntries > 0 && newest-seq >= int64(opts.MaxEntries)
+		if !expired && opts.MaxAge > 0 {
+			data, err := l.txn.Get(key)
+			if errors.Is(err, ErrKeyNotFound) {
+				// 事务失败留下的空缺
+				continue
+			} else if err != nil {
+				return err
+			}
+			var entry changelogEntry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return fmt.Errorf("failed to decode changelog entry: %w", err)
+			}
+			expired = entry.Time < cutoff
+		}
+		if !expired {
+			break
+		}
+		if err := l.txn.Delete(key); err != nil {
+			return err
+		}
+	}
+	l.first = seq
+	return nil
+}
+
+// finish 在事务结束后调用：释放本事务的序列号，推进水位线并通知 Watch 订阅者。
+func (l *changelogTxn) finish(committed bool) {
+	if l == nil {
+		return
+	}
+	c := l.c
+	c.cdcMu.Lock()
+	defer c.cdcMu.Unlock()
+
+	if l.trimming {
+		if committed && l.first > c.cdcFirst {
+			c.cdcFirst = l.first
+		}
+		c.cdcTrimMu.Unlock()
+	}
+	if len(l.seqs) == 0 {
+		return
+	}
+	for _, seq := range l.seqs {
+		delete(c.cdcInflight, seq)
+	}
+
+	watermark := c.cdcNext
+	for seq := range c.cdcInflight {
+		if seq-1 < watermark {
+			watermark = seq - 1
+		}
+	}
+	if watermark <= c.cdcCommitted {
+		return
+	}
+	c.cdcCommitted = watermark
+
+	for _, notify := range c.cdcSubscribers {
+		select {
+		case notify <- struct{}{}:
+		default:
+			// 已有待处理的通知，订阅者会一次性读取所有新事件
+		}
+	}
+}
+
+// Watch 返回从当前位置开始的变更流，仅包含匹配 selector 的事件（selector 为空时包含全部事件）。
+// 集合未启用 Schema.Changelog 时只转发实时事件，CDCEvent.Sequence 为 0；需要断点恢复时请启用变更日志并使用 WatchFrom。
+func (c *collection) Watch(ctx context.Context, selector map[string]any) <-chan CDCEvent {
+	if c.schema.Changelog == nil {
+		return c.watchLive(ctx, selector)
+	}
+	c.cdcMu.Lock()
+	since := c.cdcCommitted
+	c.cdcMu.Unlock()
+	return c.watchFrom(ctx, selector, since)
+}
+
+// WatchFrom 先重放序列号大于 since 的历史事件，再切换为实时推送。
+// 断线重连时传入最后处理的序列号即可恢复，事件既不会丢失也不会重复；
+// since 之后的日志已按 ChangelogOptions 被清理时，从最早保留的日志开始重放。
+//
+// 变更日志不保存文档内容，事件的 Doc 为投递时文档的当前状态，Old 与 Meta 为空。
+// 文档已被删除时 Doc 为 nil，此类事件（包括删除与 Truncate 事件）无法按 selector 判断，总会发送。
+// 集合未启用 Schema.Changelog 时没有可重放的历史，返回 ErrorTypeValidation 错误。
+func (c *collection) WatchFrom(ctx context.Context, selector map[string]any, since int64) (<-chan CDCEvent, error) {
+	if c.schema.Changelog == nil {
+		return nil, NewError(ErrorTypeValidation, "changelog is not enabled, cannot resume change stream", nil).
+			WithContext("collection", c.name)
+	}
+	return c.watchFrom(ctx, selector, since), nil
+}
+
+// watchFrom 实现 WatchFrom，调用方需保证集合已启用变更日志。
+func (c *collection) watchFrom(ctx context.Context, selector map[string]any, since int64) <-chan CDCEvent {
+	out := make(chan CDCEvent, 100)
+
+	var q *Query
+	if len(selector) > 0 {
+		q = c.Find(selector)
+	}
+
+	// 先注册通知再读取日志，保证注册之后提交的事件一定会触发通知
+	c.cdcMu.Lock()
+	select {
+	case <-c.closeChan:
+		c.cdcMu.Unlock()
+		close(out)
+		return out
+	default:
+	}
+	c.cdcSubIDGen++
+	id := c.cdcSubIDGen
+	notify := make(chan struct{}, 1)
+	c.cdcSubscribers[id] = notify
+	c.cdcMu.Unlock()
+
+	go func() {
+		defer close(out)
+		defer func() {
+			c.cdcMu.Lock()
+			delete(c.cdcSubscribers, id)
+			c.cdcMu.Unlock()
+		}()
+
+		last := since
+		for {
+			c.cdcMu.Lock()
+			committed := c.cdcCommitted
+			c.cdcMu.Unlock()
+
+			for last < committed {
+				entries, err := c.readChangelog(ctx, last, committed, cdcReplayBatchSize)
+				if err != nil {
+					if ctx.Err() == nil {
+						c.logger.Warn("Failed to read changelog", "collection", c.name, "error", err)
+					}
+					return
+				}
+				for _, entry := range entries {
+					event := c.changelogEvent(ctx, entry)
+					if !matchCDCEvent(q, event) {
+						continue
+					}
+					select {
+					case out <- event:
+					case <-ctx.Done():
+						return
+					case <-c.closeChan:
+						return
+					}
+				}
+				if len(entries) < cdcReplayBatchSize {
+					break
+				}
+				last = entries[len(entries)-1].Sequence
+			}
+			last = committed
+
+			select {
+			case <-notify:
+			case <-ctx.Done():
+				return
+			case <-c.closeChan:
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// watchLive 订阅 Changes 并转发匹配 selector 的实时事件，用于未启用变更日志的集合。
+func (c *collection) watchLive(ctx context.Context, selector map[string]any) <-chan CDCEvent {
+	var q *Query
+	if len(selector) > 0 {
+		q = c.Find(selector)
+	}
+	id, changes := c.subscribeWithID()
+	out := make(chan CDCEvent, 100)
+
+	go func() {
+		defer close(out)
+		defer c.unsubscribe(id)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-changes:
+				if !ok {
+					return
+				}
+				cdc := CDCEvent{ChangeEvent: event}
+				if !matchCDCEvent(q, cdc) {
+					continue
+				}
+				select {
+				case out <- cdc:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// WatchInserts 返回仅包含插入事件的变更流，ctx 取消或集合关闭时关闭。
+func (c *collection) WatchInserts(ctx context.Context) <-chan ChangeEvent {
+	return c.watchOp(ctx, OperationInsert)
+}
+
+// WatchUpdates 返回仅包含更新事件的变更流，ctx 取消或集合关闭时关闭。
+func (c *collection) WatchUpdates(ctx context.Context) <-chan ChangeEvent {
+	return c.watchOp(ctx, OperationUpdate)
+}
+
+// WatchDeletes 返回仅包含删除事件的变更流，ctx 取消或集合关闭时关闭。
+func (c *collection) WatchDeletes(ctx context.Context) <-chan ChangeEvent {
+	return c.watchOp(ctx, OperationDelete)
+}
+
+// watchOp 订阅 Changes 并只转发 op 类型的事件。
+func (c *collection) watchOp(ctx context.Context, op Operation) <-chan ChangeEvent {
+	id, changes := c.subscribeWithID()
+	out := make(chan ChangeEvent, 100)
+
+	go func() {
+		defer close(out)
+		defer c.unsubscribe(id)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-changes:
+				if !ok {
+					return
+				}
+				if event.Op != op {
+					continue
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// readChangelog 按顺序读取序列号在 (after, upTo] 范围内的最多 limit 条日志。
+func (c *collection) readChangelog(ctx context.Context, after, upTo int64, limit int) ([]changelogEntry, error) {
+	prefix := bstore.BucketPrefix(c.changelogBucket())
+	start := bstore.BucketKey(c.changelogBucket(), formatSequence(after+1))
+	end := bstore.BucketKey(c.changelogBucket(), formatSequence(upTo))
+
+	// 水位线之前的日志已全部提交，直接定位到 start，无需事务快照
+	it, err := c.store.ScanFrom(ctx, prefix, start)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	entries := make([]changelogEntry, 0)
+	for len(entries) < limit && it.Next() {
+		if bytes.Compare(it.Key(), end) > 0 {
+			break
+		}
+		var entry changelogEntry
+		if err := json.Unmarshal(it.Value(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode changelog entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, it.Err()
+}
+
+// changelogEvent 将日志转换为 CDCEvent，Doc 为文档的当前状态（已删除时为 nil）。
+func (c *collection) changelogEvent(ctx context.Context, entry changelogEntry) CDCEvent {
+	event := CDCEvent{
+		ChangeEvent: ChangeEvent{
+			Collection: c.name,
+			ID:         entry.ID,
+			Op:         entry.Op,
+			Source:     entry.Source,
+		},
+		Sequence: entry.Sequence,
+	}
+	if entry.ID != "" && entry.Op != OperationDelete {
+		if doc, err := c.FindByID(ctx, entry.ID); err == nil && doc != nil {
+			event.Doc = doc.Data()
+		}
+	}
+	return event
+}
+
+// matchCDCEvent 判断事件是否匹配 selector，q 为 nil 时匹配全部事件。
+// 删除事件按删除前的文档匹配；没有文档内容的事件（如 Truncate）无法判断，总是匹配。
+func matchCDCEvent(q *Query, event CDCEvent) bool {
+	if q == nil {
+		return true
+	}
+	doc := event.Doc
+	if doc == nil {
+		doc = event.Old
+	}
+	if doc == nil {
+		return true
+	}
+	return q.match(doc)
+}
@@ -189,6 +189,27 @@ func (s *Store) startGC() {
 	}()
 }
 
+// RunValueLogGC 循环执行 Value Log GC，直到没有可回收的日志文件。
+// discardRatio 为日志文件中可丢弃数据的最小比例。没有可回收文件、
+// 其他 GC 正在进行或处于内存模式时视为成功。
+func (s *Store) RunValueLogGC(discardRatio float64) error {
+	db := s.db
+	if db == nil {
+		return errors.New("badger store not opened")
+	}
+	for {
+		err := db.RunValueLogGC(discardRatio)
+		switch {
+		case err == nil:
+			continue
+		case errors.Is(err, badger.ErrNoRewrite), errors.Is(err, badger.ErrRejected), errors.Is(err, badger.ErrGCInMemoryMode):
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
 // Close 关闭 Badger DB。如果是共享实例，减少引用计数，只有计数为 0 时才真正关闭。
 func (s *Store) Close() error {
 	s.mu.Lock()
@@ -266,6 +287,16 @@ func (s *Store) WithView(ctx context.Context, fn func(txn *badger.Txn) error) er
 	})
 }
 
+// NewReadTxn 开启一个只读事务，事务读取的是开启时刻的一致性快照。
+// 调用方负责在使用完毕后调用 txn.Discard()。
+func (s *Store) NewReadTxn() (*badger.Txn, error) {
+	db := s.db
+	if db == nil {
+		return nil, errors.New("badger store not opened")
+	}
+	return db.NewTransaction(false), nil
+}
+
 // Path 返回数据库文件路径。
 func (s *Store) Path() string {
 	return s.path
@@ -291,6 +322,43 @@ func (s *Store) Backup(ctx context.Context, backupPath string) error {
 	return err
 }
 
+// DropBuckets 删除指定 bucket 中的所有键。
+// 只扫描键（不读取值）并通过 WriteBatch 批量删除，不受单个事务大小限制。
+func (s *Store) DropBuckets(ctx context.Context, buckets ...string) error {
+	db := s.db
+	if db == nil {
+		return errors.New("badger store not opened")
+	}
+
+	wb := db.NewWriteBatch()
+	defer wb.Cancel()
+
+	for _, bucket := range buckets {
+		var keys [][]byte
+		err := s.WithView(ctx, func(txn *badger.Txn) error {
+			opts := badger.DefaultIteratorOptions
+			opts.Prefix = BucketPrefix(bucket)
+			opts.PrefetchValues = false
+			it := txn.NewIterator(opts)
+			defer it.Close()
+
+			for it.Rewind(); it.Valid(); it.Next() {
+				keys = append(keys, it.Item().KeyCopy(nil))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := wb.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	return wb.Flush()
+}
+
 // DB 返回底层 Badger 数据库实例（供高级用法）。
 func (s *Store) DB() *badger.DB {
 	return s.db